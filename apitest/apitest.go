@@ -0,0 +1,119 @@
+// Package apitest starts a deterministic robot API for end-to-end tests: a
+// fresh in-memory world, a fake clock and a fixed random seed, served over
+// HTTP and called through the typed client.
+//
+//	func TestMove(t *testing.T) {
+//		api := apitest.StartTestServer(t, apitest.Options{})
+//		result, err := api.Robots.Move(context.Background(), "robot1", client.DirectionUp)
+//		...
+//	}
+//
+// The same options give the same responses on every run, down to dice rolls
+// and timestamps. The background simulation does not run; move time with
+// Clock.Advance where a test needs it. The world's invariants are checked
+// after every change, and a test fails if one was broken.
+package apitest
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"aufgabe-2/client"
+	"aufgabe-2/pkg/robotapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultStart is the time the clock of a test server starts at
+var DefaultStart = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+// Options configure a test server; the zero value gives the example world
+type Options struct {
+	Seed       int64     // seed of all randomness, default 1
+	Start      time.Time // start of the fake clock, default DefaultStart
+	EmptyWorld bool      // leave out the example robots and items
+	Robots     []robotapi.Robot
+	Items      []robotapi.Item
+	APIKey     string // if set the server requires it and the client sends it
+	// Configure adjusts the configuration before the server is created,
+	// e.g. to pick combat rules or to turn off CheckInvariants for a test
+	// that breaks the world on purpose
+	Configure func(config *robotapi.Config)
+}
+
+// TestServer is a running test server with a client of it
+type TestServer struct {
+	*client.Client
+	URL     string
+	Server  *robotapi.Server
+	Storage *robotapi.RobotStorage
+	Clock   *robotapi.FakeClock
+}
+
+// StartTestServer starts a server for the test and stops it when the test
+// ends
+func StartTestServer(t testing.TB, options Options) *TestServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	if options.Seed == 0 {
+		options.Seed = 1
+	}
+	if options.Start.IsZero() {
+		options.Start = DefaultStart
+	}
+
+	clock := robotapi.NewFakeClock(options.Start)
+	storage := robotapi.NewRobotStorage()
+	storage.SetClock(clock)
+	if !options.EmptyWorld {
+		storage.Initialize()
+	}
+	for _, robot := range options.Robots {
+		robot := robot
+		if err := storage.CreateRobot(&robot); err != nil {
+			t.Fatalf("cannot place robot %s: %v", robot.ID, err)
+		}
+	}
+	for _, item := range options.Items {
+		storage.SaveItem(item)
+	}
+
+	config := robotapi.DefaultConfig()
+	config.Clock = clock
+	config.Storage = storage
+	config.RandomSeed = options.Seed
+	config.InstanceID = "apitest"
+	config.CheckInvariants = true
+	if options.APIKey != "" {
+		config.APIKeys = "apitest=" + options.APIKey
+	}
+	if options.Configure != nil {
+		options.Configure(&config)
+	}
+	server, err := robotapi.New(config)
+	if err != nil {
+		t.Fatalf("cannot start the test server: %v", err)
+	}
+
+	httpServer := httptest.NewServer(server.Router())
+	t.Cleanup(httpServer.Close)
+	if invariants := server.Invariants(); invariants != nil {
+		t.Cleanup(func() {
+			for _, violation := range invariants.Violations() {
+				t.Errorf("invariant %s violated by %s at world version %d: %s", violation.Invariant, violation.Subject, violation.Version, violation.Details)
+			}
+		})
+	}
+	clientOptions := []client.Option{client.WithHTTPClient(httpServer.Client())}
+	if options.APIKey != "" {
+		clientOptions = append(clientOptions, client.WithAPIKey(options.APIKey))
+	}
+	return &TestServer{
+		Client:  client.New(httpServer.URL, clientOptions...),
+		URL:     httpServer.URL,
+		Server:  server,
+		Storage: storage,
+		Clock:   clock,
+	}
+}
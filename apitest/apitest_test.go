@@ -0,0 +1,85 @@
+package apitest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"aufgabe-2/client"
+	"aufgabe-2/pkg/robotapi"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartTestServer(t *testing.T) {
+	api := StartTestServer(t, Options{})
+	ctx := context.Background()
+
+	result, err := api.Robots.Move(ctx, "robot1", client.DirectionUp)
+	require.NoError(t, err)
+	assert.Equal(t, client.Position{X: 0, Y: 1}, result.Position)
+
+	// Actions are dated by the fake clock
+	api.Clock.Advance(time.Minute)
+	_, err = api.Robots.Move(ctx, "robot1", client.DirectionRight)
+	require.NoError(t, err)
+	actions, err := api.Robots.Actions(ctx, "robot1", &client.GetActionsParams{Size: intPtr(100)})
+	require.NoError(t, err)
+	last := actions.Actions[len(actions.Actions)-2:]
+	assert.True(t, DefaultStart.Equal(last[0].Timestamp))
+	assert.True(t, DefaultStart.Add(time.Minute).Equal(last[1].Timestamp))
+}
+
+func TestTestServersAreDeterministic(t *testing.T) {
+	attacks := func() []client.AttackResult {
+		api := StartTestServer(t, Options{Seed: 7, Configure: func(config *robotapi.Config) {
+			config.CombatRules = "dice"
+		}})
+		// robot2 is brought within attack range
+		_, err := api.Storage.UpdateRobot("robot2", func(robot *robotapi.Robot) error {
+			robot.Position = robotapi.Position{X: 1}
+			return nil
+		})
+		require.NoError(t, err)
+		results := []client.AttackResult{}
+		for i := 0; i < 5; i++ {
+			result, err := api.Robots.Attack(context.Background(), "robot1", "robot2")
+			require.NoError(t, err)
+			results = append(results, *result)
+		}
+		return results
+	}
+	assert.Equal(t, attacks(), attacks())
+}
+
+func TestCustomWorld(t *testing.T) {
+	api := StartTestServer(t, Options{
+		EmptyWorld: true,
+		Robots:     []robotapi.Robot{{ID: "solo", Energy: 50, Inventory: []string{}}},
+		Items:      []robotapi.Item{{ID: "gem", Type: "tool", Weight: 1, Position: robotapi.Position{X: 1}}},
+		APIKey:     "test-key",
+	})
+	ctx := context.Background()
+
+	status, err := api.Robots.Status(ctx, "solo")
+	require.NoError(t, err)
+	assert.Equal(t, 50, status.Energy)
+	_, err = api.Robots.Status(ctx, "robot1")
+	var apiErr *client.Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+
+	items, err := api.Items.List(ctx, nil)
+	require.NoError(t, err)
+	require.Len(t, items.Items, 1)
+	assert.Equal(t, "gem", items.Items[0].ID)
+
+	// The server requires the key the client sends
+	_, err = client.New(api.URL).Robots.Status(ctx, "solo")
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+func intPtr(value int) *int { return &value }
@@ -0,0 +1,121 @@
+// Package client is a typed Go client of the robot API. The types and
+// methods are generated from the OpenAPI spec of the server (see
+// generate.go), this file holds the transport:
+//
+//	api := client.New("http://localhost:8080", client.WithAPIKey(key))
+//	result, err := api.Robots.Move(ctx, "robot1", client.DirectionUp)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Client calls the robot API. Its endpoint groups, e.g. Robots and Items,
+// are embedded.
+type Client struct {
+	services
+	baseURL string
+	http    *http.Client
+	headers http.Header
+}
+
+// Option configures a client
+type Option func(*Client)
+
+// WithHTTPClient sends requests with the given HTTP client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) { c.http = httpClient }
+}
+
+// WithAPIKey authenticates requests with an API key
+func WithAPIKey(key string) Option {
+	return WithHeader("X-API-Key", key)
+}
+
+// WithHeader adds a header to every request
+func WithHeader(name, value string) Option {
+	return func(c *Client) { c.headers.Set(name, value) }
+}
+
+// New creates a client of the API at baseURL, including any base path
+func New(baseURL string, options ...Option) *Client {
+	c := &Client{baseURL: strings.TrimSuffix(baseURL, "/"), http: http.DefaultClient, headers: http.Header{}}
+	for _, option := range options {
+		option(c)
+	}
+	c.services = newServices(c)
+	return c
+}
+
+// Error is a response with an error status
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("robot API: %d %s", e.StatusCode, e.Message)
+}
+
+// do sends a request with an optional JSON body and decodes the response
+// into out
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body, out interface{}) error {
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return err
+	}
+	for name, values := range c.headers {
+		request.Header[name] = values
+	}
+	request.Header.Set("Accept", "application/json")
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode >= http.StatusBadRequest {
+		return responseError(response)
+	}
+	return json.NewDecoder(response.Body).Decode(out)
+}
+
+// responseError reads the message of an error body, which is either
+// {"error": ...} or an RFC 7807 problem
+func responseError(response *http.Response) error {
+	var body struct {
+		Error  string `json:"error"`
+		Detail string `json:"detail"`
+	}
+	json.NewDecoder(response.Body).Decode(&body)
+	message := body.Error
+	if message == "" {
+		message = body.Detail
+	}
+	if message == "" {
+		message = http.StatusText(response.StatusCode)
+	}
+	return &Error{StatusCode: response.StatusCode, Message: message}
+}
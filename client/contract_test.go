@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"aufgabe-2/pkg/codegen"
+	"aufgabe-2/pkg/robotapi"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testServer serves a fresh robot API that requires the API key "key1"
+func testServer(t *testing.T) (*robotapi.Server, *Client) {
+	gin.SetMode(gin.TestMode)
+	config := robotapi.DefaultConfig()
+	config.APIKeys = "alice=key1"
+	server, err := robotapi.New(config)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(server.Router())
+	t.Cleanup(httpServer.Close)
+	return server, New(httpServer.URL, WithAPIKey("key1"))
+}
+
+func TestGeneratedClientIsUpToDate(t *testing.T) {
+	spec, err := codegen.Parse(robotapi.OpenAPISpec())
+	require.NoError(t, err)
+	generated, err := codegen.Go(spec, "client", "openapi.json")
+	require.NoError(t, err)
+
+	current, err := os.ReadFile("zz_generated.go")
+	require.NoError(t, err)
+	assert.Equal(t, string(generated), string(current), "run go generate ./client after changing the spec")
+}
+
+func TestSpecMatchesRoutes(t *testing.T) {
+	server, _ := testServer(t)
+	routes := map[string]bool{}
+	for _, route := range server.Router().Routes() {
+		routes[route.Method+" "+route.Path] = true
+	}
+
+	spec, err := codegen.Parse(robotapi.OpenAPISpec())
+	require.NoError(t, err)
+	for _, endpoint := range spec.Endpoints() {
+		path := endpoint.Path
+		for _, param := range endpoint.PathParams() {
+			path = strings.Replace(path, "{"+param.Name+"}", ":"+param.Name, 1)
+		}
+		assert.True(t, routes[endpoint.Verb+" "+path], "%s %s is in the spec but not served", endpoint.Verb, endpoint.Path)
+	}
+	assert.True(t, routes["GET /openapi.json"])
+}
+
+func TestClientAgainstServer(t *testing.T) {
+	_, api := testServer(t)
+	ctx := context.Background()
+
+	created, err := api.Robots.Create(ctx, CreateRobotRequest{ID: stringPtr("rover"), Position: &Position{X: 1, Y: 1}})
+	require.NoError(t, err)
+	assert.Equal(t, "rover", created.Robot.ID)
+
+	moved, err := api.Robots.Move(ctx, "robot1", DirectionRight)
+	require.NoError(t, err)
+	assert.Equal(t, Position{X: 1, Y: 0}, moved.Position)
+
+	status, err := api.Robots.Status(ctx, "robot1")
+	require.NoError(t, err)
+	assert.Equal(t, moved.Energy, status.Energy)
+
+	picked, err := api.Robots.Pickup(ctx, "robot1", "item2")
+	require.NoError(t, err)
+	assert.Contains(t, picked.Inventory, "item2")
+	put, err := api.Robots.Putdown(ctx, "robot1", "item2")
+	require.NoError(t, err)
+	assert.NotContains(t, put.Inventory, "item2")
+
+	updated, err := api.Robots.UpdateState(ctx, "robot1", StateUpdateRequest{Energy: intPtr(80)})
+	require.NoError(t, err)
+	assert.Equal(t, 80, updated.Robot.Energy)
+
+	actions, err := api.Robots.Actions(ctx, "robot1", &GetActionsParams{Size: intPtr(2)})
+	require.NoError(t, err)
+	assert.Len(t, actions.Actions, 2)
+	assert.True(t, actions.Page.HasNext)
+
+	attack, err := api.Robots.Attack(ctx, "robot1", "rover")
+	require.NoError(t, err)
+	assert.NotEmpty(t, attack.Message)
+
+	scan, err := api.Robots.Scan(ctx, "robot1", &ScanParams{Radius: intPtr(5)})
+	require.NoError(t, err)
+	assert.Equal(t, 5, scan.Radius)
+
+	items, err := api.Items.List(ctx, &ListItemsParams{Type: "tool"})
+	require.NoError(t, err)
+	for _, item := range items.Items {
+		assert.Equal(t, "tool", item.Type)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	_, api := testServer(t)
+	ctx := context.Background()
+
+	_, err := api.Robots.Status(ctx, "missing")
+	var apiErr *Error
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+	assert.NotEmpty(t, apiErr.Message)
+
+	_, err = api.Robots.Move(ctx, "robot1", Direction("sideways"))
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusBadRequest, apiErr.StatusCode)
+
+	anonymous := New(api.baseURL)
+	_, err = anonymous.Robots.Status(ctx, "robot1")
+	require.ErrorAs(t, err, &apiErr)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+func stringPtr(value string) *string { return &value }
+
+func intPtr(value int) *int { return &value }
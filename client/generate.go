@@ -0,0 +1,3 @@
+package client
+
+//go:generate go run ../pkg/codegen/clientgen -spec ../pkg/robotapi/openapi.json -out zz_generated.go
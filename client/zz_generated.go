@@ -0,0 +1,387 @@
+// Code generated by clientgen from openapi.json; DO NOT EDIT.
+
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Action is the Action schema of the API
+type Action struct {
+	CommandID string         `json:"command_id,omitempty"`
+	Data      map[string]any `json:"data,omitempty"` // structured details, depending on the type
+	Details   string         `json:"details"`
+	Links     []Link         `json:"links"`
+	Timestamp time.Time      `json:"timestamp"`
+	Type      string         `json:"type"`
+}
+
+// ActionPage is the ActionPage schema of the API
+type ActionPage struct {
+	Actions []Action `json:"actions"`
+	Links   []Link   `json:"links"`
+	Page    PageInfo `json:"page"`
+}
+
+// AttackResult is the AttackResult schema of the API
+type AttackResult struct {
+	AttackerEnergy int           `json:"attacker_energy"`
+	CombatRules    string        `json:"combat_rules"`
+	DamageDealt    int           `json:"damage_dealt"`
+	EffectApplied  *StatusEffect `json:"effect_applied,omitempty"`
+	Hit            bool          `json:"hit"`
+	Message        string        `json:"message"`
+	TargetEnergy   int           `json:"target_energy"`
+}
+
+// CreateRobotRequest is the CreateRobotRequest schema of the API
+type CreateRobotRequest struct {
+	Armor    *int      `json:"armor,omitempty"`
+	Class    *string   `json:"class,omitempty"` // standard or solar
+	Firmware *string   `json:"firmware,omitempty"`
+	ID       *string   `json:"id,omitempty"`
+	Name     *string   `json:"name,omitempty"`
+	Position *Position `json:"position,omitempty"`
+	Sensor   *string   `json:"sensor,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+}
+
+// CreatedRobot is the CreatedRobot schema of the API
+type CreatedRobot struct {
+	Links   []Link `json:"links"`
+	Message string `json:"message"`
+	Robot   Robot  `json:"robot"`
+}
+
+// Direction is a movement direction; ascend and descend need an elevator or ramp on multi-floor maps
+type Direction string
+
+// Direction values
+const (
+	DirectionUp      Direction = "up"
+	DirectionDown    Direction = "down"
+	DirectionLeft    Direction = "left"
+	DirectionRight   Direction = "right"
+	DirectionAscend  Direction = "ascend"
+	DirectionDescend Direction = "descend"
+)
+
+// ErrorBody is the ErrorBody schema of the API
+type ErrorBody struct {
+	Details string `json:"details,omitempty"` // why a request body or parameter was rejected
+	Error   string `json:"error"`
+}
+
+// Hazard is the Hazard schema of the API
+type Hazard struct {
+	Center        Position `json:"center"`
+	DamagePerTick int      `json:"damage_per_tick"`
+	Radius        int      `json:"radius"`
+	Type          string   `json:"type"`
+}
+
+// InventoryResult is the InventoryResult schema of the API
+type InventoryResult struct {
+	Inventory []string `json:"inventory"`
+	Message   string   `json:"message"`
+}
+
+// Item is the Item schema of the API
+type Item struct {
+	ID       string   `json:"id"`
+	Position Position `json:"position"`
+	Type     string   `json:"type"`
+	Weight   int      `json:"weight"`
+}
+
+// ItemPage is the ItemPage schema of the API
+type ItemPage struct {
+	AvailableItems []string `json:"available_items"`
+	Items          []Item   `json:"items"`
+	Links          []Link   `json:"links"`
+	Page           PageInfo `json:"page"`
+	TotalCount     int      `json:"total_count"`
+}
+
+// Link is the Link schema of the API
+type Link struct {
+	Href string `json:"href"`
+	Rel  string `json:"rel"`
+}
+
+// MoveRequest is the MoveRequest schema of the API
+type MoveRequest struct {
+	Direction Direction `json:"direction"`
+}
+
+// MoveResult is the MoveResult schema of the API
+type MoveResult struct {
+	Energy     int      `json:"energy"`
+	EnergyCost int      `json:"energy_cost"`
+	Hazard     *Hazard  `json:"hazard,omitempty"`
+	Message    string   `json:"message"`
+	Position   Position `json:"position"`
+}
+
+// PageInfo is the PageInfo schema of the API
+type PageInfo struct {
+	HasNext       bool `json:"hasNext"`
+	HasPrevious   bool `json:"hasPrevious"`
+	Number        int  `json:"number"`
+	Size          int  `json:"size"`
+	TotalElements int  `json:"totalElements"`
+	TotalPages    int  `json:"totalPages"`
+}
+
+// Position is the Position schema of the API
+type Position struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	Z int `json:"z,omitempty"` // floor, 0 on single-floor maps
+}
+
+// Robot is the Robot schema of the API
+type Robot struct {
+	Armor       int            `json:"armor,omitempty"`
+	Class       string         `json:"class,omitempty"`
+	Direction   string         `json:"direction"`
+	Effects     []StatusEffect `json:"effects,omitempty"`
+	Energy      int            `json:"energy"`
+	Firmware    string         `json:"firmware,omitempty"`
+	ID          string         `json:"id"`
+	Inventory   []string       `json:"inventory"`
+	Maintenance bool           `json:"maintenance,omitempty"`
+	Name        string         `json:"name,omitempty"`
+	Owner       string         `json:"owner,omitempty"`
+	Position    Position       `json:"position"`
+	Sensor      string         `json:"sensor,omitempty"`
+	Tags        []string       `json:"tags,omitempty"`
+}
+
+// RobotSighting is the RobotSighting schema of the API
+type RobotSighting struct {
+	Distance          int      `json:"distance,omitempty"`
+	EstimatedPosition *Vector  `json:"estimated_position,omitempty"`
+	ID                string   `json:"id"`
+	Maintenance       bool     `json:"maintenance,omitempty"`
+	Position          Position `json:"position"`
+	Uncertainty       float64  `json:"uncertainty,omitempty"`
+}
+
+// RobotStatus is the RobotStatus schema of the API
+type RobotStatus struct {
+	BatteryCycles float64        `json:"battery_cycles,omitempty"`
+	Effects       []StatusEffect `json:"effects"`
+	Energy        int            `json:"energy"`
+	ExactPosition *Vector        `json:"exact_position,omitempty"`
+	Firmware      string         `json:"firmware,omitempty"`
+	Heading       float64        `json:"heading,omitempty"`
+	ID            string         `json:"id"`
+	Inventory     []string       `json:"inventory"`
+	Links         []Link         `json:"links"`
+	Maintenance   bool           `json:"maintenance,omitempty"`
+	MaxEnergy     int            `json:"max_energy"`
+	Position      Position       `json:"position"`
+	Velocity      *Vector        `json:"velocity,omitempty"`
+}
+
+// ScanResult is the ScanResult schema of the API
+type ScanResult struct {
+	Hazards  []Hazard        `json:"hazards"`
+	Position Position        `json:"position"`
+	Radius   int             `json:"radius"`
+	Robots   []RobotSighting `json:"robots"`
+	Sensor   string          `json:"sensor,omitempty"`
+}
+
+// StateUpdateRequest is the StateUpdateRequest schema of the API
+type StateUpdateRequest struct {
+	Energy   *int      `json:"energy,omitempty"`
+	Firmware *string   `json:"firmware,omitempty"`
+	Position *Position `json:"position,omitempty"`
+}
+
+// StateUpdateResult is the StateUpdateResult schema of the API
+type StateUpdateResult struct {
+	Message string `json:"message"`
+	Robot   Robot  `json:"robot"`
+}
+
+// StatusEffect is the StatusEffect schema of the API
+type StatusEffect struct {
+	DamagePerTick  int    `json:"damage_per_tick,omitempty"`
+	RemainingTicks int    `json:"remaining_ticks"`
+	Type           string `json:"type"`
+}
+
+// Vector is the Vector schema of the API
+type Vector struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// ItemsService calls the Items endpoints
+type ItemsService struct {
+	client *Client
+}
+
+// RobotsService calls the Robots endpoints
+type RobotsService struct {
+	client *Client
+}
+
+// services are the endpoint groups of a client
+type services struct {
+	Items  *ItemsService
+	Robots *RobotsService
+}
+
+func newServices(client *Client) services {
+	return services{
+		Items:  &ItemsService{client: client},
+		Robots: &RobotsService{client: client},
+	}
+}
+
+// ListItemsParams are the optional query parameters of Items.List
+type ListItemsParams struct {
+	Page *int
+	Size *int
+	Type string
+	Sort string
+}
+
+// List calls GET /items: Items lying in the world
+func (s *ItemsService) List(ctx context.Context, params *ListItemsParams) (*ItemPage, error) {
+	query := url.Values{}
+	if params != nil {
+		if params.Page != nil {
+			query.Set("page", strconv.Itoa(*params.Page))
+		}
+		if params.Size != nil {
+			query.Set("size", strconv.Itoa(*params.Size))
+		}
+		if params.Type != "" {
+			query.Set("type", params.Type)
+		}
+		if params.Sort != "" {
+			query.Set("sort", params.Sort)
+		}
+	}
+	var result ItemPage
+	if err := s.client.do(ctx, http.MethodGet, "/items", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetActionsParams are the optional query parameters of Robots.Actions
+type GetActionsParams struct {
+	Page *int
+	Size *int
+}
+
+// Actions calls GET /robot/{id}/actions: Page through the action history of a robot
+func (s *RobotsService) Actions(ctx context.Context, id string, params *GetActionsParams) (*ActionPage, error) {
+	query := url.Values{}
+	if params != nil {
+		if params.Page != nil {
+			query.Set("page", strconv.Itoa(*params.Page))
+		}
+		if params.Size != nil {
+			query.Set("size", strconv.Itoa(*params.Size))
+		}
+	}
+	var result ActionPage
+	if err := s.client.do(ctx, http.MethodGet, "/robot/"+url.PathEscape(id)+"/actions", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Attack calls POST /robot/{id}/attack/{targetId}: Attack another robot
+func (s *RobotsService) Attack(ctx context.Context, id string, targetID string) (*AttackResult, error) {
+	var result AttackResult
+	if err := s.client.do(ctx, http.MethodPost, "/robot/"+url.PathEscape(id)+"/attack/"+url.PathEscape(targetID), nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Move calls POST /robot/{id}/move: Move a robot one cell
+func (s *RobotsService) Move(ctx context.Context, id string, direction Direction) (*MoveResult, error) {
+	var result MoveResult
+	if err := s.client.do(ctx, http.MethodPost, "/robot/"+url.PathEscape(id)+"/move", nil, MoveRequest{Direction: direction}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Pickup calls POST /robot/{id}/pickup/{itemId}: Pick up an item
+func (s *RobotsService) Pickup(ctx context.Context, id string, itemID string) (*InventoryResult, error) {
+	var result InventoryResult
+	if err := s.client.do(ctx, http.MethodPost, "/robot/"+url.PathEscape(id)+"/pickup/"+url.PathEscape(itemID), nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Putdown calls POST /robot/{id}/putdown/{itemId}: Put down an item
+func (s *RobotsService) Putdown(ctx context.Context, id string, itemID string) (*InventoryResult, error) {
+	var result InventoryResult
+	if err := s.client.do(ctx, http.MethodPost, "/robot/"+url.PathEscape(id)+"/putdown/"+url.PathEscape(itemID), nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ScanParams are the optional query parameters of Robots.Scan
+type ScanParams struct {
+	Radius *int
+}
+
+// Scan calls GET /robot/{id}/scan: Robots and hazards around a robot
+func (s *RobotsService) Scan(ctx context.Context, id string, params *ScanParams) (*ScanResult, error) {
+	query := url.Values{}
+	if params != nil {
+		if params.Radius != nil {
+			query.Set("radius", strconv.Itoa(*params.Radius))
+		}
+	}
+	var result ScanResult
+	if err := s.client.do(ctx, http.MethodGet, "/robot/"+url.PathEscape(id)+"/scan", query, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// UpdateState calls PATCH /robot/{id}/state: Set the energy, position or firmware of a robot
+func (s *RobotsService) UpdateState(ctx context.Context, id string, body StateUpdateRequest) (*StateUpdateResult, error) {
+	var result StateUpdateResult
+	if err := s.client.do(ctx, http.MethodPatch, "/robot/"+url.PathEscape(id)+"/state", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Status calls GET /robot/{id}/status: Status of a robot
+func (s *RobotsService) Status(ctx context.Context, id string) (*RobotStatus, error) {
+	var result RobotStatus
+	if err := s.client.do(ctx, http.MethodGet, "/robot/"+url.PathEscape(id)+"/status", nil, nil, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// Create calls POST /robots: Create a robot
+func (s *RobotsService) Create(ctx context.Context, body CreateRobotRequest) (*CreatedRobot, error) {
+	var result CreatedRobot
+	if err := s.client.do(ctx, http.MethodPost, "/robots", nil, body, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
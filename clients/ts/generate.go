@@ -0,0 +1,5 @@
+// Package ts holds the generated TypeScript client of the robot API and its
+// smoke test; the client itself is index.js with the types in index.d.ts.
+package ts
+
+//go:generate go run ../../pkg/codegen/clientgen -lang ts -spec ../../pkg/robotapi/openapi.json -out .
@@ -0,0 +1,259 @@
+// Code generated by clientgen from openapi.json; DO NOT EDIT.
+
+export interface Action {
+  command_id?: string;
+  /** structured details, depending on the type */
+  data?: Record<string, unknown>;
+  details: string;
+  links: Link[];
+  timestamp: string;
+  type: string;
+}
+
+export interface ActionPage {
+  actions: Action[];
+  links: Link[];
+  page: PageInfo;
+}
+
+export interface AttackResult {
+  attacker_energy: number;
+  combat_rules: string;
+  damage_dealt: number;
+  effect_applied?: StatusEffect;
+  hit: boolean;
+  message: string;
+  target_energy: number;
+}
+
+export interface CreateRobotRequest {
+  armor?: number;
+  /** standard or solar */
+  class?: string;
+  firmware?: string;
+  id?: string;
+  name?: string;
+  position?: Position;
+  sensor?: string;
+  tags?: string[];
+}
+
+export interface CreatedRobot {
+  links: Link[];
+  message: string;
+  robot: Robot;
+}
+
+/** a movement direction; ascend and descend need an elevator or ramp on multi-floor maps */
+export type Direction = "up" | "down" | "left" | "right" | "ascend" | "descend";
+export declare const Direction: {
+  readonly Up: "up";
+  readonly Down: "down";
+  readonly Left: "left";
+  readonly Right: "right";
+  readonly Ascend: "ascend";
+  readonly Descend: "descend";
+};
+
+export interface ErrorBody {
+  /** why a request body or parameter was rejected */
+  details?: string;
+  error: string;
+}
+
+export interface Hazard {
+  center: Position;
+  damage_per_tick: number;
+  radius: number;
+  type: string;
+}
+
+export interface InventoryResult {
+  inventory: string[];
+  message: string;
+}
+
+export interface Item {
+  id: string;
+  position: Position;
+  type: string;
+  weight: number;
+}
+
+export interface ItemPage {
+  available_items: string[];
+  items: Item[];
+  links: Link[];
+  page: PageInfo;
+  total_count: number;
+}
+
+export interface Link {
+  href: string;
+  rel: string;
+}
+
+export interface MoveRequest {
+  direction: Direction;
+}
+
+export interface MoveResult {
+  energy: number;
+  energy_cost: number;
+  hazard?: Hazard;
+  message: string;
+  position: Position;
+}
+
+export interface PageInfo {
+  hasNext: boolean;
+  hasPrevious: boolean;
+  number: number;
+  size: number;
+  totalElements: number;
+  totalPages: number;
+}
+
+export interface Position {
+  x: number;
+  y: number;
+  /** floor, 0 on single-floor maps */
+  z?: number;
+}
+
+export interface Robot {
+  armor?: number;
+  class?: string;
+  direction: string;
+  effects?: StatusEffect[];
+  energy: number;
+  firmware?: string;
+  id: string;
+  inventory: string[];
+  maintenance?: boolean;
+  name?: string;
+  owner?: string;
+  position: Position;
+  sensor?: string;
+  tags?: string[];
+}
+
+export interface RobotSighting {
+  distance?: number;
+  estimated_position?: Vector;
+  id: string;
+  maintenance?: boolean;
+  position: Position;
+  uncertainty?: number;
+}
+
+export interface RobotStatus {
+  battery_cycles?: number;
+  effects: StatusEffect[];
+  energy: number;
+  exact_position?: Vector;
+  firmware?: string;
+  heading?: number;
+  id: string;
+  inventory: string[];
+  links: Link[];
+  maintenance?: boolean;
+  max_energy: number;
+  position: Position;
+  velocity?: Vector;
+}
+
+export interface ScanResult {
+  hazards: Hazard[];
+  position: Position;
+  radius: number;
+  robots: RobotSighting[];
+  sensor?: string;
+}
+
+export interface StateUpdateRequest {
+  energy?: number;
+  firmware?: string;
+  position?: Position;
+}
+
+export interface StateUpdateResult {
+  message: string;
+  robot: Robot;
+}
+
+export interface StatusEffect {
+  damage_per_tick?: number;
+  remaining_ticks: number;
+  type: string;
+}
+
+export interface Vector {
+  x: number;
+  y: number;
+}
+
+export declare class ApiError extends Error {
+  readonly status: number;
+  readonly detail: string;
+}
+
+export interface ClientOptions {
+  apiKey?: string;
+  headers?: Record<string, string>;
+  fetch?: typeof fetch;
+}
+
+export declare class BaseClient {
+  constructor(baseURL: string, options?: ClientOptions);
+}
+
+export interface ListItemsParams {
+  page?: number;
+  size?: number;
+  type?: string;
+  sort?: string;
+}
+
+export declare class ItemsService {
+  constructor(client: Client);
+  /** GET /items: Items lying in the world */
+  list(params?: ListItemsParams): Promise<ItemPage>;
+}
+
+export interface GetActionsParams {
+  page?: number;
+  size?: number;
+}
+
+export interface ScanParams {
+  radius?: number;
+}
+
+export declare class RobotsService {
+  constructor(client: Client);
+  /** GET /robot/{id}/actions: Page through the action history of a robot */
+  actions(id: string, params?: GetActionsParams): Promise<ActionPage>;
+  /** POST /robot/{id}/attack/{targetId}: Attack another robot */
+  attack(id: string, targetId: string): Promise<AttackResult>;
+  /** POST /robot/{id}/move: Move a robot one cell */
+  move(id: string, direction: Direction): Promise<MoveResult>;
+  /** POST /robot/{id}/pickup/{itemId}: Pick up an item */
+  pickup(id: string, itemId: string): Promise<InventoryResult>;
+  /** POST /robot/{id}/putdown/{itemId}: Put down an item */
+  putdown(id: string, itemId: string): Promise<InventoryResult>;
+  /** GET /robot/{id}/scan: Robots and hazards around a robot */
+  scan(id: string, params?: ScanParams): Promise<ScanResult>;
+  /** PATCH /robot/{id}/state: Set the energy, position or firmware of a robot */
+  updateState(id: string, body: StateUpdateRequest): Promise<StateUpdateResult>;
+  /** GET /robot/{id}/status: Status of a robot */
+  status(id: string): Promise<RobotStatus>;
+  /** POST /robots: Create a robot */
+  create(body: CreateRobotRequest): Promise<CreatedRobot>;
+}
+
+export declare class Client extends BaseClient {
+  constructor(baseURL: string, options?: ClientOptions);
+  readonly items: ItemsService;
+  readonly robots: RobotsService;
+}
@@ -0,0 +1,126 @@
+// Code generated by clientgen from openapi.json; DO NOT EDIT.
+
+export const Direction = Object.freeze({
+  Up: "up",
+  Down: "down",
+  Left: "left",
+  Right: "right",
+  Ascend: "ascend",
+  Descend: "descend",
+});
+
+export class ApiError extends Error {
+  constructor(status, message) {
+    super(`robot API: ${status} ${message}`);
+    this.name = "ApiError";
+    this.status = status;
+    this.detail = message;
+  }
+}
+
+export class BaseClient {
+  constructor(baseURL, options = {}) {
+    this.baseURL = baseURL.replace(/\/$/, "");
+    this.headers = { Accept: "application/json", ...options.headers };
+    if (options.apiKey) {
+      this.headers["X-API-Key"] = options.apiKey;
+    }
+    this.fetch = options.fetch ?? globalThis.fetch.bind(globalThis);
+  }
+
+  async request(method, path, query, body) {
+    let url = this.baseURL + path;
+    const search = new URLSearchParams();
+    for (const [name, value] of Object.entries(query ?? {})) {
+      if (value !== undefined && value !== null && value !== "") {
+        search.set(name, String(value));
+      }
+    }
+    if (search.toString()) {
+      url += "?" + search.toString();
+    }
+    const init = { method, headers: { ...this.headers } };
+    if (body !== undefined) {
+      init.headers["Content-Type"] = "application/json";
+      init.body = JSON.stringify(body);
+    }
+    const response = await this.fetch(url, init);
+    const text = await response.text();
+    const data = text ? JSON.parse(text) : undefined;
+    if (!response.ok) {
+      // Errors are {"error": ...} or RFC 7807 problems
+      throw new ApiError(response.status, data?.error ?? data?.detail ?? response.statusText);
+    }
+    return data;
+  }
+}
+
+export class ItemsService {
+  constructor(client) {
+    this.client = client;
+  }
+
+  /** GET /items: Items lying in the world */
+  list(params) {
+    return this.client.request("GET", `/items`, params, undefined);
+  }
+}
+
+export class RobotsService {
+  constructor(client) {
+    this.client = client;
+  }
+
+  /** GET /robot/{id}/actions: Page through the action history of a robot */
+  actions(id, params) {
+    return this.client.request("GET", `/robot/${encodeURIComponent(id)}/actions`, params, undefined);
+  }
+
+  /** POST /robot/{id}/attack/{targetId}: Attack another robot */
+  attack(id, targetId) {
+    return this.client.request("POST", `/robot/${encodeURIComponent(id)}/attack/${encodeURIComponent(targetId)}`, undefined, undefined);
+  }
+
+  /** POST /robot/{id}/move: Move a robot one cell */
+  move(id, direction) {
+    return this.client.request("POST", `/robot/${encodeURIComponent(id)}/move`, undefined, { direction: direction });
+  }
+
+  /** POST /robot/{id}/pickup/{itemId}: Pick up an item */
+  pickup(id, itemId) {
+    return this.client.request("POST", `/robot/${encodeURIComponent(id)}/pickup/${encodeURIComponent(itemId)}`, undefined, undefined);
+  }
+
+  /** POST /robot/{id}/putdown/{itemId}: Put down an item */
+  putdown(id, itemId) {
+    return this.client.request("POST", `/robot/${encodeURIComponent(id)}/putdown/${encodeURIComponent(itemId)}`, undefined, undefined);
+  }
+
+  /** GET /robot/{id}/scan: Robots and hazards around a robot */
+  scan(id, params) {
+    return this.client.request("GET", `/robot/${encodeURIComponent(id)}/scan`, params, undefined);
+  }
+
+  /** PATCH /robot/{id}/state: Set the energy, position or firmware of a robot */
+  updateState(id, body) {
+    return this.client.request("PATCH", `/robot/${encodeURIComponent(id)}/state`, undefined, body);
+  }
+
+  /** GET /robot/{id}/status: Status of a robot */
+  status(id) {
+    return this.client.request("GET", `/robot/${encodeURIComponent(id)}/status`, undefined, undefined);
+  }
+
+  /** POST /robots: Create a robot */
+  create(body) {
+    return this.client.request("POST", `/robots`, undefined, body);
+  }
+}
+
+export class Client extends BaseClient {
+  constructor(baseURL, options = {}) {
+    super(baseURL, options);
+    this.items = new ItemsService(this);
+    this.robots = new RobotsService(this);
+  }
+}
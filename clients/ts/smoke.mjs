@@ -0,0 +1,38 @@
+// Smoke test of the generated client against a running server, whose URL
+// and API key are passed as arguments. Run by smoke_test.go.
+import assert from "node:assert/strict";
+import { ApiError, Client, Direction } from "./index.js";
+
+const [baseURL, apiKey] = process.argv.slice(2);
+const api = new Client(baseURL, { apiKey });
+
+const created = await api.robots.create({ id: "rover", position: { x: 1, y: 1 } });
+assert.equal(created.robot.id, "rover");
+
+const moved = await api.robots.move("robot1", Direction.Right);
+assert.deepEqual(moved.position, { x: 1, y: 0 });
+
+const status = await api.robots.status("robot1");
+assert.equal(status.energy, moved.energy);
+
+const picked = await api.robots.pickup("robot1", "item2");
+assert.ok(picked.inventory.includes("item2"));
+await api.robots.putdown("robot1", "item2");
+
+const updated = await api.robots.updateState("robot1", { energy: 80 });
+assert.equal(updated.robot.energy, 80);
+
+const actions = await api.robots.actions("robot1", { size: 2 });
+assert.equal(actions.actions.length, 2);
+
+await api.robots.attack("robot1", "rover");
+const scan = await api.robots.scan("robot1", { radius: 5 });
+assert.equal(scan.radius, 5);
+
+const items = await api.items.list({ type: "tool" });
+assert.ok(items.items.every((item) => item.type === "tool"));
+
+await assert.rejects(api.robots.status("missing"), (err) => err instanceof ApiError && err.status === 404);
+await assert.rejects(new Client(baseURL).robots.status("robot1"), (err) => err.status === 401);
+
+console.log("ok");
@@ -0,0 +1,47 @@
+package ts
+
+import (
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"testing"
+
+	"aufgabe-2/pkg/codegen"
+	"aufgabe-2/pkg/robotapi"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeneratedClientIsUpToDate(t *testing.T) {
+	spec, err := codegen.Parse(robotapi.OpenAPISpec())
+	require.NoError(t, err)
+	js, dts, err := codegen.TypeScript(spec, "openapi.json")
+	require.NoError(t, err)
+
+	for file, generated := range map[string][]byte{"index.js": js, "index.d.ts": dts} {
+		current, err := os.ReadFile(file)
+		require.NoError(t, err)
+		assert.Equal(t, string(generated), string(current), "run go generate ./clients/ts after changing the spec")
+	}
+}
+
+func TestSmoke(t *testing.T) {
+	node, err := exec.LookPath("node")
+	if err != nil {
+		t.Skip("node is not installed")
+	}
+
+	gin.SetMode(gin.TestMode)
+	config := robotapi.DefaultConfig()
+	config.APIKeys = "alice=key1"
+	server, err := robotapi.New(config)
+	require.NoError(t, err)
+	httpServer := httptest.NewServer(server.Router())
+	defer httpServer.Close()
+
+	output, err := exec.Command(node, "smoke.mjs", httpServer.URL, "key1").CombinedOutput()
+	require.NoError(t, err, string(output))
+	assert.Contains(t, string(output), "ok")
+}
@@ -2,7 +2,10 @@ module aufgabe-2
 
 go 1.21.5
 
-require github.com/gin-gonic/gin v1.9.1
+require (
+	github.com/gin-gonic/gin v1.9.1
+	github.com/tetratelabs/wazero v1.5.0
+)
 
 require (
 	github.com/bytedance/sonic v1.9.1
@@ -0,0 +1,58 @@
+// Command clientgen generates an API client from the OpenAPI spec. It is
+// run by go generate in the client packages.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"aufgabe-2/pkg/codegen"
+)
+
+func main() {
+	specPath := flag.String("spec", "", "path of the OpenAPI spec")
+	out := flag.String("out", "", "path of the generated file, or directory for TypeScript")
+	lang := flag.String("lang", "go", "language of the client: go or ts")
+	pkg := flag.String("package", "client", "package name of a Go client")
+	flag.Parse()
+
+	if err := generate(*specPath, *out, *lang, *pkg); err != nil {
+		fmt.Fprintln(os.Stderr, "clientgen:", err)
+		os.Exit(1)
+	}
+}
+
+func generate(specPath, out, lang, pkg string) error {
+	if specPath == "" || out == "" {
+		return fmt.Errorf("-spec and -out are required")
+	}
+	data, err := os.ReadFile(specPath)
+	if err != nil {
+		return err
+	}
+	spec, err := codegen.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	switch lang {
+	case "go":
+		code, err := codegen.Go(spec, pkg, filepath.Base(specPath))
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(out, code, 0o644)
+	case "ts":
+		js, dts, err := codegen.TypeScript(spec, filepath.Base(specPath))
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(out, "index.js"), js, 0o644); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(out, "index.d.ts"), dts, 0o644)
+	}
+	return fmt.Errorf("unknown language %q", lang)
+}
@@ -0,0 +1,40 @@
+package codegen
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGoNames(t *testing.T) {
+	assert.Equal(t, "ItemID", goName("itemId"))
+	assert.Equal(t, "CommandID", goName("command_id"))
+	assert.Equal(t, "TotalElements", goName("totalElements"))
+	assert.Equal(t, "itemID", goArg("itemId"))
+	assert.Equal(t, "id", goArg("id"))
+}
+
+func TestParseRequiresClientNames(t *testing.T) {
+	_, err := Parse([]byte(`{"paths": {"/robots": {"get": {"tags": ["Robots"], "operationId": "listRobots"}}}}`))
+	assert.ErrorContains(t, err, "x-go-method")
+
+	spec, err := Parse([]byte(`{"paths": {
+		"/robots": {"get": {"tags": ["Robots"], "operationId": "listRobots", "x-go-method": "List"}},
+		"/items": {"get": {"tags": ["Items"], "operationId": "listItems", "x-go-method": "List"}}
+	}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Items", "Robots"}, spec.Groups())
+}
+
+func TestFreeFormObjects(t *testing.T) {
+	data := &Schema{Type: "object"}
+	goType, err := (&goGenerator{spec: &Spec{}}).goType(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "map[string]any", goType)
+	tsType, err := (&tsGenerator{spec: &Spec{}}).tsType(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "Record<string, unknown>", tsType)
+
+	_, err = (&goGenerator{spec: &Spec{}}).goType(&Schema{Type: "object", Properties: map[string]*Schema{"x": {Type: "integer"}}})
+	assert.Error(t, err, "named objects must be referenced")
+}
@@ -0,0 +1,306 @@
+package codegen
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+)
+
+// goInitialisms are written in upper case in Go names
+var goInitialisms = map[string]bool{"id": true, "url": true, "api": true, "http": true}
+
+// Go generates a client package: a type per schema, a service per group
+// and a method per operation. The handwritten part of the package provides
+// Client with its embedded services and the do method.
+func Go(spec *Spec, pkg, source string) ([]byte, error) {
+	g := &goGenerator{spec: spec}
+	g.printf("// Code generated by clientgen from %s; DO NOT EDIT.\n\n", source)
+	g.printf("package %s\n\n", pkg)
+
+	var body strings.Builder
+	g.out, g.imports = &body, map[string]bool{"context": true, "net/http": true, "net/url": true}
+	for _, name := range spec.SchemaNames() {
+		if err := g.schema(name, spec.Components.Schemas[name]); err != nil {
+			return nil, err
+		}
+	}
+	g.services()
+	for _, endpoint := range spec.Endpoints() {
+		if err := g.method(endpoint); err != nil {
+			return nil, err
+		}
+	}
+
+	header := g.header.String()
+	header += "import (\n"
+	for _, path := range []string{"context", "net/http", "net/url", "strconv", "time"} {
+		if g.imports[path] {
+			header += fmt.Sprintf("\t%q\n", path)
+		}
+	}
+	header += ")\n\n"
+	source = header + body.String()
+	formatted, err := format.Source([]byte(source))
+	if err != nil {
+		return nil, fmt.Errorf("generated code does not compile: %w", err)
+	}
+	return formatted, nil
+}
+
+type goGenerator struct {
+	spec    *Spec
+	header  strings.Builder
+	out     *strings.Builder
+	imports map[string]bool
+}
+
+func (g *goGenerator) printf(format string, args ...interface{}) {
+	if g.out == nil {
+		fmt.Fprintf(&g.header, format, args...)
+		return
+	}
+	fmt.Fprintf(g.out, format, args...)
+}
+
+// schema declares the Go type of a component schema
+func (g *goGenerator) schema(name string, schema *Schema) error {
+	description := schema.Description
+	if description == "" {
+		description = "the " + name + " schema of the API"
+	}
+	g.printf("// %s is %s\n", name, description)
+
+	if schema.Type == "string" && len(schema.Enum) > 0 {
+		g.printf("type %s string\n\n", name)
+		g.printf("// %s values\nconst (\n", name)
+		for _, value := range schema.Enum {
+			g.printf("\t%s%s %s = %q\n", name, goName(value), name, value)
+		}
+		g.printf(")\n\n")
+		return nil
+	}
+	if schema.Type != "object" {
+		return fmt.Errorf("schema %s: only objects and string enums are supported", name)
+	}
+
+	request := strings.HasSuffix(name, "Request")
+	g.printf("type %s struct {\n", name)
+	for _, property := range schema.propertyNames() {
+		field := schema.Properties[property]
+		required := schema.isRequired(property)
+		fieldType, err := g.goType(field)
+		if err != nil {
+			return fmt.Errorf("schema %s, property %s: %w", name, property, err)
+		}
+		tag := property
+		if !required {
+			tag += ",omitempty"
+			// Optional objects, and optional scalars of requests, can be left out
+			if field.Ref != "" && g.spec.Components.Schemas[RefName(field.Ref)].Type == "object" ||
+				request && isScalar(field) {
+				fieldType = "*" + fieldType
+			}
+		}
+		comment := ""
+		if field.Description != "" {
+			comment = " // " + field.Description
+		}
+		g.printf("\t%s %s `json:%q`%s\n", goName(property), fieldType, tag, comment)
+	}
+	g.printf("}\n\n")
+	return nil
+}
+
+// services declares a service per group and the struct embedding them
+func (g *goGenerator) services() {
+	groups := g.spec.Groups()
+	for _, group := range groups {
+		g.printf("// %sService calls the %s endpoints\ntype %sService struct {\n\tclient *Client\n}\n\n", group, group, group)
+	}
+	g.printf("// services are the endpoint groups of a client\ntype services struct {\n")
+	for _, group := range groups {
+		g.printf("\t%s *%sService\n", group, group)
+	}
+	g.printf("}\n\nfunc newServices(client *Client) services {\n\treturn services{\n")
+	for _, group := range groups {
+		g.printf("\t\t%s: &%sService{client: client},\n", group, group)
+	}
+	g.printf("\t}\n}\n\n")
+}
+
+// method declares the client method of an endpoint, and the struct of its
+// query parameters if it has any
+func (g *goGenerator) method(endpoint Endpoint) error {
+	args := []string{"ctx context.Context"}
+	path := `"` + endpoint.Path + `"`
+	for _, param := range endpoint.PathParams() {
+		arg := goArg(param.Name)
+		args = append(args, arg+" string")
+		path = strings.Replace(path, "{"+param.Name+"}", `" + url.PathEscape(`+arg+`) + "`, 1)
+	}
+	path = strings.TrimSuffix(strings.ReplaceAll(path, ` + ""`, ""), ` + "`)
+
+	body := "nil"
+	if schema := endpoint.BodySchema(); schema != nil {
+		bodyType, err := g.goType(schema)
+		if err != nil {
+			return fmt.Errorf("%s: %w", endpoint.OperationID, err)
+		}
+		target := g.spec.Components.Schemas[RefName(schema.Ref)]
+		if target != nil && len(target.Properties) == 1 && len(target.Required) == 1 {
+			// A body with a single field is passed as that field
+			property := target.Required[0]
+			fieldType, err := g.goType(target.Properties[property])
+			if err != nil {
+				return fmt.Errorf("%s: %w", endpoint.OperationID, err)
+			}
+			args = append(args, goArg(property)+" "+fieldType)
+			body = fmt.Sprintf("%s{%s: %s}", bodyType, goName(property), goArg(property))
+		} else {
+			args = append(args, "body "+bodyType)
+			body = "body"
+		}
+	}
+
+	query := endpoint.QueryParams()
+	paramsType := goName(endpoint.OperationID) + "Params"
+	if len(query) > 0 {
+		g.printf("// %s are the optional query parameters of %s.%s\ntype %s struct {\n", paramsType, endpoint.Group, endpoint.Method, paramsType)
+		for _, param := range query {
+			fieldType := "string"
+			if param.Schema != nil && param.Schema.Type == "integer" {
+				fieldType = "*int"
+			}
+			g.printf("\t%s %s\n", goName(param.Name), fieldType)
+		}
+		g.printf("}\n\n")
+		args = append(args, "params *"+paramsType)
+	}
+
+	result := endpoint.ResultSchema()
+	if result == nil || result.Ref == "" {
+		return fmt.Errorf("%s: the response must reference a schema", endpoint.OperationID)
+	}
+	resultType := RefName(result.Ref)
+
+	g.printf("// %s calls %s %s: %s\n", endpoint.Method, endpoint.Verb, endpoint.Path, endpoint.Summary)
+	g.printf("func (s *%sService) %s(%s) (*%s, error) {\n", endpoint.Group, endpoint.Method, strings.Join(args, ", "), resultType)
+	queryArg := "nil"
+	if len(query) > 0 {
+		queryArg = "query"
+		g.printf("\tquery := url.Values{}\n\tif params != nil {\n")
+		for _, param := range query {
+			field := "params." + goName(param.Name)
+			if param.Schema != nil && param.Schema.Type == "integer" {
+				g.imports["strconv"] = true
+				g.printf("\t\tif %s != nil {\n\t\t\tquery.Set(%q, strconv.Itoa(*%s))\n\t\t}\n", field, param.Name, field)
+			} else {
+				g.printf("\t\tif %s != \"\" {\n\t\t\tquery.Set(%q, %s)\n\t\t}\n", field, param.Name, field)
+			}
+		}
+		g.printf("\t}\n")
+	}
+	g.printf("\tvar result %s\n", resultType)
+	g.printf("\tif err := s.client.do(ctx, http.Method%s, %s, %s, %s, &result); err != nil {\n\t\treturn nil, err\n\t}\n",
+		goName(strings.ToLower(endpoint.Verb)), path, queryArg, body)
+	g.printf("\treturn &result, nil\n}\n\n")
+	return nil
+}
+
+// goType returns the Go type of a schema
+func (g *goGenerator) goType(schema *Schema) (string, error) {
+	if schema.Ref != "" {
+		name := RefName(schema.Ref)
+		if g.spec.Components.Schemas[name] == nil {
+			return "", fmt.Errorf("unknown schema %s", name)
+		}
+		return name, nil
+	}
+	switch schema.Type {
+	case "string":
+		if schema.Format == "date-time" {
+			g.imports["time"] = true
+			return "time.Time", nil
+		}
+		return "string", nil
+	case "integer":
+		return "int", nil
+	case "number":
+		return "float64", nil
+	case "boolean":
+		return "bool", nil
+	case "array":
+		if schema.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		items, err := g.goType(schema.Items)
+		return "[]" + items, err
+	case "object":
+		// Inline objects are free-form; named ones are referenced
+		if len(schema.Properties) == 0 {
+			return "map[string]any", nil
+		}
+	}
+	return "", fmt.Errorf("unsupported schema type %q", schema.Type)
+}
+
+// isScalar reports whether the schema is a number, string or boolean
+func isScalar(schema *Schema) bool {
+	switch schema.Type {
+	case "string", "integer", "number", "boolean":
+		return true
+	}
+	return false
+}
+
+// words splits snake_case and camelCase names
+func words(name string) []string {
+	parts := []string{}
+	current := []rune{}
+	for i, r := range name {
+		switch {
+		case r == '_' || r == '-' || r == ' ':
+			if len(current) > 0 {
+				parts = append(parts, string(current))
+			}
+			current = nil
+			continue
+		case unicode.IsUpper(r) && i > 0 && len(current) > 0:
+			parts = append(parts, string(current))
+			current = nil
+		}
+		current = append(current, unicode.ToLower(r))
+	}
+	if len(current) > 0 {
+		parts = append(parts, string(current))
+	}
+	return parts
+}
+
+// goName returns the exported Go name of a JSON name, e.g. ItemID
+func goName(name string) string {
+	result := ""
+	for _, word := range words(name) {
+		if goInitialisms[word] {
+			result += strings.ToUpper(word)
+			continue
+		}
+		result += strings.ToUpper(word[:1]) + word[1:]
+	}
+	return result
+}
+
+// goArg returns the unexported Go name of a JSON name, e.g. itemID
+func goArg(name string) string {
+	parts := words(name)
+	result := parts[0]
+	for _, word := range parts[1:] {
+		if goInitialisms[word] {
+			result += strings.ToUpper(word)
+			continue
+		}
+		result += strings.ToUpper(word[:1]) + word[1:]
+	}
+	return result
+}
@@ -0,0 +1,210 @@
+// Package codegen generates API clients from the OpenAPI spec of the robot
+// API. It understands the subset of OpenAPI 3 the spec uses: object and
+// string enum schemas, references to them, path and query parameters and
+// JSON bodies.
+package codegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Spec is an OpenAPI 3 document
+type Spec struct {
+	Info struct {
+		Title   string `json:"title"`
+		Version string `json:"version"`
+	} `json:"info"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+	Paths map[string]map[string]*Operation `json:"paths"` // path -> method -> operation
+}
+
+// Schema describes a JSON value
+type Schema struct {
+	Ref         string             `json:"$ref"`
+	Type        string             `json:"type"`
+	Format      string             `json:"format"`
+	Enum        []string           `json:"enum"`
+	Description string             `json:"description"`
+	Required    []string           `json:"required"`
+	Properties  map[string]*Schema `json:"properties"`
+	Items       *Schema            `json:"items"`
+}
+
+// Operation is one method on a path
+type Operation struct {
+	Tags        []string             `json:"tags"`
+	OperationID string               `json:"operationId"`
+	Method      string               `json:"x-go-method"` // name of the client method
+	Summary     string               `json:"summary"`
+	Parameters  []Parameter          `json:"parameters"`
+	RequestBody *Body                `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path or query parameter
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// Body is a request body
+type Body struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response is the response of an operation for one status
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content"`
+}
+
+// MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Endpoint is an operation with its path and method, as the generators use
+// it
+type Endpoint struct {
+	Path  string
+	Verb  string // upper case HTTP method
+	Group string // first tag
+	*Operation
+}
+
+// Parse reads a spec and checks that every operation can be generated
+func Parse(data []byte) (*Spec, error) {
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("invalid spec: %w", err)
+	}
+	for _, endpoint := range spec.Endpoints() {
+		if endpoint.Group == "" || endpoint.Method == "" {
+			return nil, fmt.Errorf("%s %s needs a tag and x-go-method", endpoint.Verb, endpoint.Path)
+		}
+		if endpoint.OperationID == "" {
+			return nil, fmt.Errorf("%s %s needs an operationId", endpoint.Verb, endpoint.Path)
+		}
+	}
+	return &spec, nil
+}
+
+// Endpoints returns all operations ordered by group, then path and method
+func (s *Spec) Endpoints() []Endpoint {
+	endpoints := []Endpoint{}
+	for path, methods := range s.Paths {
+		for method, operation := range methods {
+			endpoint := Endpoint{Path: path, Verb: strings.ToUpper(method), Operation: operation}
+			if len(operation.Tags) > 0 {
+				endpoint.Group = operation.Tags[0]
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		a, b := endpoints[i], endpoints[j]
+		if a.Group != b.Group {
+			return a.Group < b.Group
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Verb < b.Verb
+	})
+	return endpoints
+}
+
+// Groups returns the names of the endpoint groups in order
+func (s *Spec) Groups() []string {
+	groups := []string{}
+	for _, endpoint := range s.Endpoints() {
+		if len(groups) == 0 || groups[len(groups)-1] != endpoint.Group {
+			groups = append(groups, endpoint.Group)
+		}
+	}
+	return groups
+}
+
+// SchemaNames returns the names of the component schemas in order
+func (s *Spec) SchemaNames() []string {
+	names := make([]string, 0, len(s.Components.Schemas))
+	for name := range s.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RefName returns the schema name a reference points to
+func RefName(ref string) string {
+	return strings.TrimPrefix(ref, "#/components/schemas/")
+}
+
+// PathParams returns the path parameters in the order they appear in the
+// path
+func (e Endpoint) PathParams() []Parameter {
+	params := []Parameter{}
+	for _, segment := range strings.Split(e.Path, "/") {
+		if name, found := strings.CutPrefix(segment, "{"); found {
+			params = append(params, Parameter{Name: strings.TrimSuffix(name, "}"), In: "path", Required: true})
+		}
+	}
+	return params
+}
+
+// QueryParams returns the query parameters
+func (e Endpoint) QueryParams() []Parameter {
+	params := []Parameter{}
+	for _, param := range e.Parameters {
+		if param.In == "query" {
+			params = append(params, param)
+		}
+	}
+	return params
+}
+
+// BodySchema returns the schema of the JSON request body, or nil
+func (e Endpoint) BodySchema() *Schema {
+	if e.RequestBody == nil || e.RequestBody.Content["application/json"] == nil {
+		return nil
+	}
+	return e.RequestBody.Content["application/json"].Schema
+}
+
+// ResultSchema returns the schema of the successful JSON response, or nil
+func (e Endpoint) ResultSchema() *Schema {
+	for _, status := range []string{"200", "201"} {
+		if response := e.Responses[status]; response != nil && response.Content["application/json"] != nil {
+			return response.Content["application/json"].Schema
+		}
+	}
+	return nil
+}
+
+// isRequired reports whether the schema requires the property
+func (s *Schema) isRequired(property string) bool {
+	for _, name := range s.Required {
+		if name == property {
+			return true
+		}
+	}
+	return false
+}
+
+// propertyNames returns the properties in order
+func (s *Schema) propertyNames() []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
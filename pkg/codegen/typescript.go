@@ -0,0 +1,286 @@
+package codegen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TypeScript generates a TypeScript client as an ES module with its type
+// declarations, index.js and index.d.ts, so it runs on Node and in browsers
+// without a compile step. The client mirrors the Go client:
+// client.robots.move(id, Direction.Up).
+func TypeScript(spec *Spec, source string) (js, dts []byte, err error) {
+	g := &tsGenerator{spec: spec}
+	header := fmt.Sprintf("// Code generated by clientgen from %s; DO NOT EDIT.\n\n", source)
+	g.js.WriteString(header)
+	g.dts.WriteString(header)
+
+	for _, name := range spec.SchemaNames() {
+		if err := g.schema(name, spec.Components.Schemas[name]); err != nil {
+			return nil, nil, err
+		}
+	}
+	g.js.WriteString(tsRuntime)
+	g.dts.WriteString(tsRuntimeTypes)
+
+	endpoints := spec.Endpoints()
+	for _, group := range spec.Groups() {
+		g.methods, g.declarations, g.params = strings.Builder{}, strings.Builder{}, strings.Builder{}
+		for _, endpoint := range endpoints {
+			if endpoint.Group != group {
+				continue
+			}
+			if err := g.method(endpoint); err != nil {
+				return nil, nil, err
+			}
+		}
+		g.printf(&g.js, "export class %sService {\n  constructor(client) {\n    this.client = client;\n  }\n%s}\n\n", group, g.methods.String())
+		g.printf(&g.dts, "%sexport declare class %sService {\n  constructor(client: Client);\n%s}\n\n", g.params.String(), group, g.declarations.String())
+	}
+
+	g.js.WriteString("export class Client extends BaseClient {\n  constructor(baseURL, options = {}) {\n    super(baseURL, options);\n")
+	g.dts.WriteString("export declare class Client extends BaseClient {\n  constructor(baseURL: string, options?: ClientOptions);\n")
+	for _, group := range spec.Groups() {
+		g.printf(&g.js, "    this.%s = new %sService(this);\n", tsName(group), group)
+		g.printf(&g.dts, "  readonly %s: %sService;\n", tsName(group), group)
+	}
+	g.js.WriteString("  }\n}\n")
+	g.dts.WriteString("}\n")
+	return []byte(g.js.String()), []byte(g.dts.String()), nil
+}
+
+// tsRuntime sends the requests of the generated methods
+const tsRuntime = `export class ApiError extends Error {
+  constructor(status, message) {
+    super(` + "`robot API: ${status} ${message}`" + `);
+    this.name = "ApiError";
+    this.status = status;
+    this.detail = message;
+  }
+}
+
+export class BaseClient {
+  constructor(baseURL, options = {}) {
+    this.baseURL = baseURL.replace(/\/$/, "");
+    this.headers = { Accept: "application/json", ...options.headers };
+    if (options.apiKey) {
+      this.headers["X-API-Key"] = options.apiKey;
+    }
+    this.fetch = options.fetch ?? globalThis.fetch.bind(globalThis);
+  }
+
+  async request(method, path, query, body) {
+    let url = this.baseURL + path;
+    const search = new URLSearchParams();
+    for (const [name, value] of Object.entries(query ?? {})) {
+      if (value !== undefined && value !== null && value !== "") {
+        search.set(name, String(value));
+      }
+    }
+    if (search.toString()) {
+      url += "?" + search.toString();
+    }
+    const init = { method, headers: { ...this.headers } };
+    if (body !== undefined) {
+      init.headers["Content-Type"] = "application/json";
+      init.body = JSON.stringify(body);
+    }
+    const response = await this.fetch(url, init);
+    const text = await response.text();
+    const data = text ? JSON.parse(text) : undefined;
+    if (!response.ok) {
+      // Errors are {"error": ...} or RFC 7807 problems
+      throw new ApiError(response.status, data?.error ?? data?.detail ?? response.statusText);
+    }
+    return data;
+  }
+}
+
+`
+
+// tsRuntimeTypes declares tsRuntime
+const tsRuntimeTypes = `export declare class ApiError extends Error {
+  readonly status: number;
+  readonly detail: string;
+}
+
+export interface ClientOptions {
+  apiKey?: string;
+  headers?: Record<string, string>;
+  fetch?: typeof fetch;
+}
+
+export declare class BaseClient {
+  constructor(baseURL: string, options?: ClientOptions);
+}
+
+`
+
+type tsGenerator struct {
+	spec *Spec
+	js   strings.Builder
+	dts  strings.Builder
+
+	// methods, their declarations and their query parameters of the
+	// current service
+	methods      strings.Builder
+	declarations strings.Builder
+	params       strings.Builder
+}
+
+func (g *tsGenerator) printf(out *strings.Builder, format string, args ...interface{}) {
+	fmt.Fprintf(out, format, args...)
+}
+
+// schema declares the type of a component schema; string enums also get a
+// const object with their values
+func (g *tsGenerator) schema(name string, schema *Schema) error {
+	if schema.Description != "" {
+		g.printf(&g.dts, "/** %s */\n", schema.Description)
+	}
+	if schema.Type == "string" && len(schema.Enum) > 0 {
+		values := make([]string, len(schema.Enum))
+		for i, value := range schema.Enum {
+			values[i] = fmt.Sprintf("%q", value)
+		}
+		g.printf(&g.dts, "export type %s = %s;\n", name, strings.Join(values, " | "))
+		g.printf(&g.dts, "export declare const %s: {\n", name)
+		g.printf(&g.js, "export const %s = Object.freeze({\n", name)
+		for _, value := range schema.Enum {
+			g.printf(&g.dts, "  readonly %s: %q;\n", goName(value), value)
+			g.printf(&g.js, "  %s: %q,\n", goName(value), value)
+		}
+		g.dts.WriteString("};\n\n")
+		g.js.WriteString("});\n\n")
+		return nil
+	}
+	if schema.Type != "object" {
+		return fmt.Errorf("schema %s: only objects and string enums are supported", name)
+	}
+
+	g.printf(&g.dts, "export interface %s {\n", name)
+	for _, property := range schema.propertyNames() {
+		field := schema.Properties[property]
+		fieldType, err := g.tsType(field)
+		if err != nil {
+			return fmt.Errorf("schema %s, property %s: %w", name, property, err)
+		}
+		if field.Description != "" {
+			g.printf(&g.dts, "  /** %s */\n", field.Description)
+		}
+		optional := ""
+		if !schema.isRequired(property) {
+			optional = "?"
+		}
+		g.printf(&g.dts, "  %s%s: %s;\n", property, optional, fieldType)
+	}
+	g.dts.WriteString("}\n\n")
+	return nil
+}
+
+// method declares the method of an endpoint on its service
+func (g *tsGenerator) method(endpoint Endpoint) error {
+	name := tsName(endpoint.Method)
+	args, types := []string{}, []string{}
+	path := "`" + endpoint.Path + "`"
+	for _, param := range endpoint.PathParams() {
+		arg := tsName(param.Name)
+		args = append(args, arg)
+		types = append(types, arg+": string")
+		path = strings.Replace(path, "{"+param.Name+"}", "${encodeURIComponent("+arg+")}", 1)
+	}
+
+	body := "undefined"
+	if schema := endpoint.BodySchema(); schema != nil {
+		bodyType, err := g.tsType(schema)
+		if err != nil {
+			return fmt.Errorf("%s: %w", endpoint.OperationID, err)
+		}
+		target := g.spec.Components.Schemas[RefName(schema.Ref)]
+		if target != nil && len(target.Properties) == 1 && len(target.Required) == 1 {
+			// A body with a single field is passed as that field, as in Go
+			property := target.Required[0]
+			fieldType, err := g.tsType(target.Properties[property])
+			if err != nil {
+				return fmt.Errorf("%s: %w", endpoint.OperationID, err)
+			}
+			args = append(args, tsName(property))
+			types = append(types, tsName(property)+": "+fieldType)
+			body = fmt.Sprintf("{ %s: %s }", property, tsName(property))
+		} else {
+			args = append(args, "body")
+			types = append(types, "body: "+bodyType)
+			body = "body"
+		}
+	}
+
+	query := "undefined"
+	if params := endpoint.QueryParams(); len(params) > 0 {
+		paramsType := goName(endpoint.OperationID) + "Params"
+		g.printf(&g.params, "export interface %s {\n", paramsType)
+		for _, param := range params {
+			paramType := "string"
+			if param.Schema != nil && param.Schema.Type == "integer" {
+				paramType = "number"
+			}
+			g.printf(&g.params, "  %s?: %s;\n", param.Name, paramType)
+		}
+		g.params.WriteString("}\n\n")
+		args = append(args, "params")
+		types = append(types, "params?: "+paramsType)
+		query = "params"
+	}
+
+	result := endpoint.ResultSchema()
+	if result == nil || result.Ref == "" {
+		return fmt.Errorf("%s: the response must reference a schema", endpoint.OperationID)
+	}
+
+	g.printf(&g.methods, "\n  /** %s %s: %s */\n", endpoint.Verb, endpoint.Path, endpoint.Summary)
+	g.printf(&g.methods, "  %s(%s) {\n    return this.client.request(%q, %s, %s, %s);\n  }\n",
+		name, strings.Join(args, ", "), endpoint.Verb, path, query, body)
+	g.printf(&g.declarations, "  /** %s %s: %s */\n", endpoint.Verb, endpoint.Path, endpoint.Summary)
+	g.printf(&g.declarations, "  %s(%s): Promise<%s>;\n", name, strings.Join(types, ", "), RefName(result.Ref))
+	return nil
+}
+
+// tsType returns the TypeScript type of a schema
+func (g *tsGenerator) tsType(schema *Schema) (string, error) {
+	if schema.Ref != "" {
+		name := RefName(schema.Ref)
+		if g.spec.Components.Schemas[name] == nil {
+			return "", fmt.Errorf("unknown schema %s", name)
+		}
+		return name, nil
+	}
+	switch schema.Type {
+	case "string":
+		return "string", nil // date-times stay ISO strings in JSON
+	case "integer", "number":
+		return "number", nil
+	case "boolean":
+		return "boolean", nil
+	case "array":
+		if schema.Items == nil {
+			return "", fmt.Errorf("array without items")
+		}
+		items, err := g.tsType(schema.Items)
+		return items + "[]", err
+	case "object":
+		// Inline objects are free-form; named ones are referenced
+		if len(schema.Properties) == 0 {
+			return "Record<string, unknown>", nil
+		}
+	}
+	return "", fmt.Errorf("unsupported schema type %q", schema.Type)
+}
+
+// tsName returns the camelCase name of a JSON or Go name, e.g. itemId
+func tsName(name string) string {
+	parts := words(name)
+	result := parts[0]
+	for _, word := range parts[1:] {
+		result += strings.ToUpper(word[:1]) + word[1:]
+	}
+	return result
+}
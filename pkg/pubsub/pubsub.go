@@ -0,0 +1,333 @@
+// Package pubsub is an in-process publish/subscribe bus in the style of
+// NATS. Messages are published on subjects of dot-separated tokens, e.g.
+// "robot.robot1.robot_moved", and subscriptions match them with patterns in
+// which "*" stands for one token and a trailing ">" for the rest.
+//
+// Subscribers either get messages synchronously, in the publisher's
+// goroutine, asynchronously through a buffer drained by a goroutine of
+// their own, or on a buffered channel. A full buffer never blocks the
+// publisher: the subscriber misses its oldest message or is disconnected.
+package pubsub
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// DefaultBuffer is the number of messages buffered per subscription unless
+// an option says otherwise
+const DefaultBuffer = 64
+
+// Policy decides what happens when a subscriber's buffer is full
+type Policy int
+
+// Policies for full buffers
+const (
+	DropOldest Policy = iota // the oldest buffered message makes room for the new one
+	Disconnect               // the subscription is removed and its channel closed
+)
+
+// ErrClosed is returned when subscribing to a closed bus
+var ErrClosed = errors.New("bus is closed")
+
+// options configure a subscription
+type options struct {
+	async  bool
+	buffer int
+	policy Policy
+}
+
+// Option configures a subscription
+type Option func(*options)
+
+// Async delivers messages to a handler through a buffer and a goroutine of
+// the subscription, so slow handlers do not hold up the publisher
+func Async() Option {
+	return func(o *options) { o.async = true }
+}
+
+// Buffer sets how many messages an asynchronous or channel subscription
+// buffers
+func Buffer(size int) Option {
+	return func(o *options) {
+		if size > 0 {
+			o.buffer = size
+		}
+	}
+}
+
+// OnFull sets what happens when the buffer of an asynchronous or channel
+// subscription is full
+func OnFull(policy Policy) Option {
+	return func(o *options) { o.policy = policy }
+}
+
+// envelope is a message buffered for an asynchronous handler
+type envelope[T any] struct {
+	subject string
+	message T
+}
+
+// Subscription is a registered interest in the subjects matching a pattern
+type Subscription[T any] struct {
+	id      uint64
+	pattern string
+	bus     *Bus[T]
+
+	handler func(subject string, message T) // synchronous handler, nil otherwise
+	queue   chan envelope[T]                // buffer of an asynchronous handler
+	ch      chan T                          // channel of a channel subscription
+	policy  Policy
+
+	dropped int64
+	closed  bool
+	mutex   sync.Mutex
+}
+
+// ID returns the subscription's ID, unique within its bus
+func (s *Subscription[T]) ID() uint64 {
+	return s.id
+}
+
+// Pattern returns the pattern the subscription matches subjects with
+func (s *Subscription[T]) Pattern() string {
+	return s.pattern
+}
+
+// Dropped returns how many messages the subscriber missed so far
+func (s *Subscription[T]) Dropped() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.dropped
+}
+
+// Pending returns the number of buffered messages not yet received
+func (s *Subscription[T]) Pending() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch {
+	case s.queue != nil:
+		return len(s.queue)
+	case s.ch != nil:
+		return len(s.ch)
+	}
+	return 0
+}
+
+// Closed reports whether the subscription was unsubscribed or disconnected
+func (s *Subscription[T]) Closed() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.closed
+}
+
+// Unsubscribe removes the subscription and closes its channel. Messages an
+// asynchronous handler has buffered are still handled.
+func (s *Subscription[T]) Unsubscribe() {
+	s.bus.remove(s.id)
+	s.close()
+}
+
+// close closes the buffer or channel once
+func (s *Subscription[T]) close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.queue != nil {
+		close(s.queue)
+	}
+	if s.ch != nil {
+		close(s.ch)
+	}
+}
+
+// deliver hands a message to the subscriber and reports whether the
+// subscription must be removed for falling behind
+func (s *Subscription[T]) deliver(subject string, message T) bool {
+	if s.handler != nil {
+		s.handler(subject, message)
+		return false
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.closed {
+		return false
+	}
+	if s.queue != nil {
+		return offer(s, s.queue, envelope[T]{subject: subject, message: message})
+	}
+	return offer(s, s.ch, message)
+}
+
+// offer puts a value into a buffer without blocking, applying the policy if
+// it is full; callers must hold the subscription's lock. Only the subscriber
+// receives from the buffer besides, so once the oldest value is taken out
+// there is room for the new one.
+func offer[T, V any](s *Subscription[T], buffer chan V, value V) bool {
+	select {
+	case buffer <- value:
+		return false
+	default:
+	}
+	s.dropped++
+	if s.policy == Disconnect {
+		return true
+	}
+	select {
+	case <-buffer:
+	default:
+	}
+	select {
+	case buffer <- value:
+	default:
+	}
+	return false
+}
+
+// Bus delivers the messages published on subjects to the subscriptions
+// whose patterns match them
+type Bus[T any] struct {
+	subscriptions map[uint64]*Subscription[T]
+	nextID        uint64
+	closed        bool
+	mutex         sync.RWMutex
+}
+
+// New creates a bus without subscriptions
+func New[T any]() *Bus[T] {
+	return &Bus[T]{subscriptions: make(map[uint64]*Subscription[T])}
+}
+
+// Subscribe calls the handler with every message published on a subject
+// matching the pattern. By default the handler runs in the publisher's
+// goroutine, before Publish returns; with Async it runs in a goroutine of
+// the subscription, in the order the messages were published.
+func (b *Bus[T]) Subscribe(pattern string, handler func(subject string, message T), opts ...Option) (*Subscription[T], error) {
+	o := newOptions(opts)
+	if !o.async {
+		return b.add(pattern, &Subscription[T]{handler: handler})
+	}
+
+	queue := make(chan envelope[T], o.buffer)
+	subscription, err := b.add(pattern, &Subscription[T]{queue: queue, policy: o.policy})
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		for envelope := range queue {
+			handler(envelope.subject, envelope.message)
+		}
+	}()
+	return subscription, nil
+}
+
+// Channel delivers the messages published on subjects matching the pattern
+// on a buffered channel. The channel is closed on Unsubscribe, or when the
+// subscriber is disconnected for falling behind.
+func (b *Bus[T]) Channel(pattern string, opts ...Option) (*Subscription[T], <-chan T, error) {
+	o := newOptions(opts)
+	ch := make(chan T, o.buffer)
+	subscription, err := b.add(pattern, &Subscription[T]{ch: ch, policy: o.policy})
+	if err != nil {
+		return nil, nil, err
+	}
+	return subscription, ch, nil
+}
+
+func newOptions(opts []Option) options {
+	o := options{buffer: DefaultBuffer, policy: DropOldest}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// add registers a subscription
+func (b *Bus[T]) add(pattern string, subscription *Subscription[T]) (*Subscription[T], error) {
+	if !ValidPattern(pattern) {
+		return nil, ErrInvalidPattern
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.closed {
+		return nil, ErrClosed
+	}
+	b.nextID++
+	subscription.id = b.nextID
+	subscription.pattern = pattern
+	subscription.bus = b
+	b.subscriptions[subscription.id] = subscription
+	return subscription, nil
+}
+
+// remove deregisters a subscription
+func (b *Bus[T]) remove(id uint64) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.subscriptions, id)
+}
+
+// Publish delivers the message to the subscriptions matching the subject,
+// in the order they subscribed, and returns how many matched. Publish never
+// blocks on slow subscribers, only on synchronous handlers.
+func (b *Bus[T]) Publish(subject string, message T) (int, error) {
+	if !ValidSubject(subject) {
+		return 0, ErrInvalidSubject
+	}
+
+	// Handlers run without the bus lock, so they may subscribe and unsubscribe
+	b.mutex.RLock()
+	matching := make([]*Subscription[T], 0, len(b.subscriptions))
+	for _, subscription := range b.subscriptions {
+		if Match(subscription.pattern, subject) {
+			matching = append(matching, subscription)
+		}
+	}
+	b.mutex.RUnlock()
+	sort.Slice(matching, func(i, j int) bool {
+		return matching[i].id < matching[j].id
+	})
+
+	for _, subscription := range matching {
+		if subscription.deliver(subject, message) {
+			subscription.Unsubscribe()
+		}
+	}
+	return len(matching), nil
+}
+
+// Subscriptions returns the current subscriptions in the order they
+// subscribed
+func (b *Bus[T]) Subscriptions() []*Subscription[T] {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	subscriptions := make([]*Subscription[T], 0, len(b.subscriptions))
+	for _, subscription := range b.subscriptions {
+		subscriptions = append(subscriptions, subscription)
+	}
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].id < subscriptions[j].id
+	})
+	return subscriptions
+}
+
+// Close removes all subscriptions and rejects new ones. Asynchronous
+// handlers still handle the messages they buffered.
+func (b *Bus[T]) Close() {
+	b.mutex.Lock()
+	subscriptions := b.subscriptions
+	b.subscriptions = make(map[uint64]*Subscription[T])
+	b.closed = true
+	b.mutex.Unlock()
+
+	for _, subscription := range subscriptions {
+		subscription.close()
+	}
+}
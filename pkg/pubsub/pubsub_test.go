@@ -0,0 +1,145 @@
+package pubsub
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatch(t *testing.T) {
+	for _, test := range []struct {
+		pattern, subject string
+		match            bool
+	}{
+		{"robot.robot1.robot_moved", "robot.robot1.robot_moved", true},
+		{"robot.robot1.robot_moved", "robot.robot2.robot_moved", false},
+		{"robot.*.robot_moved", "robot.robot2.robot_moved", true},
+		{"robot.*", "robot.robot2.robot_moved", false},
+		{"robot.>", "robot.robot2.robot_moved", true},
+		{"robot.>", "robot", false},
+		{">", "world.weather_changed", true},
+		{"*.*.robot_moved", "tenants.acme.robot_moved", true},
+		{"tenants.*.>", "tenants.acme.robot.robot1.robot_moved", true},
+		{"tenants.*.>", "robot.robot1.robot_moved", false},
+	} {
+		assert.Equal(t, test.match, Match(test.pattern, test.subject), "%s ~ %s", test.pattern, test.subject)
+	}
+}
+
+func TestSubjectsAndPatternsAreValidated(t *testing.T) {
+	assert.True(t, ValidSubject("robot.robot1.robot_moved"))
+	assert.False(t, ValidSubject("robot..robot_moved"))
+	assert.False(t, ValidSubject("robot.*"))
+	assert.False(t, ValidSubject("robot moved"))
+	assert.True(t, ValidPattern("robot.*.>"))
+	assert.False(t, ValidPattern("robot.>.moved"))
+	assert.False(t, ValidPattern(""))
+	assert.Equal(t, "my_robot_v2", Token("my robot.v2"))
+	assert.Equal(t, "_", Token(""))
+
+	bus := New[string]()
+	_, err := bus.Subscribe("robot.>.moved", func(string, string) {})
+	assert.ErrorIs(t, err, ErrInvalidPattern)
+	_, err = bus.Publish("robot.*", "")
+	assert.ErrorIs(t, err, ErrInvalidSubject)
+}
+
+func TestSynchronousHandlersRunBeforePublishReturns(t *testing.T) {
+	bus := New[int]()
+	var got []string
+	subscription, err := bus.Subscribe("robot.*.robot_moved", func(subject string, message int) {
+		got = append(got, subject)
+	})
+	require.NoError(t, err)
+
+	matched, err := bus.Publish("robot.robot1.robot_moved", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, matched)
+	bus.Publish("robot.robot1.item_picked_up", 2)
+	bus.Publish("robot.robot2.robot_moved", 3)
+	assert.Equal(t, []string{"robot.robot1.robot_moved", "robot.robot2.robot_moved"}, got)
+
+	subscription.Unsubscribe()
+	matched, _ = bus.Publish("robot.robot1.robot_moved", 4)
+	assert.Zero(t, matched)
+	assert.Empty(t, bus.Subscriptions())
+}
+
+func TestAsynchronousHandlersGetMessagesInOrder(t *testing.T) {
+	bus := New[int]()
+	received := make(chan int, 10)
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	_, err := bus.Subscribe(">", func(_ string, message int) {
+		started <- struct{}{}
+		<-release
+		received <- message
+	}, Async(), Buffer(2))
+	require.NoError(t, err)
+
+	// The handler is stuck on the first message, yet publishing does not block
+	bus.Publish("count", 1)
+	<-started
+	for message := 2; message <= 4; message++ {
+		bus.Publish("count", message)
+	}
+	close(release)
+
+	var got []int
+	timeout := time.After(time.Second)
+	for len(got) < 3 {
+		select {
+		case message := <-received:
+			got = append(got, message)
+		case <-timeout:
+			t.Fatalf("got only %v", got)
+		}
+	}
+	// The first message was taken by the handler, the second one dropped
+	assert.Equal(t, []int{1, 3, 4}, got)
+	assert.Equal(t, int64(1), bus.Subscriptions()[0].Dropped())
+}
+
+func TestChannelSubscriptions(t *testing.T) {
+	bus := New[string]()
+	dropping, messages, err := bus.Channel("world.>", Buffer(2))
+	require.NoError(t, err)
+	disconnecting, closing, err := bus.Channel("world.>", Buffer(1), OnFull(Disconnect))
+	require.NoError(t, err)
+
+	bus.Publish("world.first", "first")
+	bus.Publish("world.second", "second")
+	bus.Publish("world.third", "third")
+
+	assert.Equal(t, "second", <-messages)
+	assert.Equal(t, "third", <-messages)
+	assert.Equal(t, int64(1), dropping.Dropped())
+	assert.Equal(t, 0, dropping.Pending())
+
+	assert.Equal(t, "first", <-closing)
+	_, open := <-closing
+	assert.False(t, open)
+	assert.True(t, disconnecting.Closed())
+	assert.Len(t, bus.Subscriptions(), 1)
+
+	bus.Close()
+	_, open = <-messages
+	assert.False(t, open)
+	_, _, err = bus.Channel(">")
+	assert.ErrorIs(t, err, ErrClosed)
+}
+
+func TestHandlersMayUnsubscribeThemselves(t *testing.T) {
+	bus := New[int]()
+	calls := 0
+	var subscription *Subscription[int]
+	subscription, _ = bus.Subscribe(">", func(string, int) {
+		calls++
+		subscription.Unsubscribe()
+	})
+	bus.Publish("once", 1)
+	bus.Publish("once", 2)
+	assert.Equal(t, 1, calls)
+}
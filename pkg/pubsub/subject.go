@@ -0,0 +1,82 @@
+package pubsub
+
+import (
+	"errors"
+	"strings"
+)
+
+// Wildcards of subscription patterns
+const (
+	AnyToken  = "*" // matches exactly one token
+	AnyTokens = ">" // as the last token, matches one or more tokens
+)
+
+// ErrInvalidSubject is returned for subjects with empty tokens or wildcards
+var ErrInvalidSubject = errors.New("invalid subject")
+
+// ErrInvalidPattern is returned for patterns with empty tokens or a ">" that
+// is not the last token
+var ErrInvalidPattern = errors.New("invalid subject pattern")
+
+// ValidSubject reports whether messages can be published on the subject:
+// one or more dot-separated tokens without wildcards or whitespace
+func ValidSubject(subject string) bool {
+	for _, token := range strings.Split(subject, ".") {
+		if !validToken(token) || token == AnyToken || token == AnyTokens {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidPattern reports whether the pattern can be subscribed to: a subject
+// whose tokens may be "*", and whose last token may be ">"
+func ValidPattern(pattern string) bool {
+	tokens := strings.Split(pattern, ".")
+	for i, token := range tokens {
+		if !validToken(token) || (token == AnyTokens && i != len(tokens)-1) {
+			return false
+		}
+	}
+	return true
+}
+
+func validToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	if token == AnyToken || token == AnyTokens {
+		return true
+	}
+	return !strings.ContainsAny(token, "*> \t\r\n")
+}
+
+// Match reports whether the subject matches the pattern
+func Match(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+	for i, token := range patternTokens {
+		if token == AnyTokens {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || (token != AnyToken && token != subjectTokens[i]) {
+			return false
+		}
+	}
+	return len(patternTokens) == len(subjectTokens)
+}
+
+// Token turns a string into a valid subject token by replacing dots,
+// wildcards and whitespace with underscores. Empty strings become "_".
+func Token(s string) string {
+	if s == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, s)
+}
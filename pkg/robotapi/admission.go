@@ -0,0 +1,131 @@
+package robotapi
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// admissionRetryAfter is how long rejected clients are asked to wait
+const admissionRetryAfter = 5 * time.Second
+
+// AdmissionLimits are the queue depths beyond which mutating requests are
+// rejected; 0 means unlimited
+type AdmissionLimits struct {
+	MaxPendingTasks  int `json:"max_pending_tasks"`
+	MaxPendingEvents int `json:"max_pending_events"`
+}
+
+// QueueDepths are the gauges of the server's queues
+type QueueDepths struct {
+	Tasks    int             `json:"tasks"`    // tasks not finished yet
+	Events   int             `json:"events"`   // event deliveries waiting for their sinks
+	Shedding bool            `json:"shedding"` // mutating requests are rejected
+	Rejected int64           `json:"rejected"` // mutating requests rejected so far
+	Limits   AdmissionLimits `json:"limits"`
+}
+
+// AdmissionController sheds load when the task queue or the event
+// deliveries back up: beyond the limits, new mutating requests are rejected
+// until the queues drained, instead of letting them grow without bound
+type AdmissionController struct {
+	tasks    *TaskStore
+	outbox   *Outbox
+	limits   AdmissionLimits
+	shedding bool // as of the last mutating request, to log the changes
+	rejected int64
+	mutex    sync.Mutex
+}
+
+// NewAdmissionController watches the queues of the task store and the outbox
+func NewAdmissionController(tasks *TaskStore, outbox *Outbox, limits AdmissionLimits) *AdmissionController {
+	return &AdmissionController{tasks: tasks, outbox: outbox, limits: limits}
+}
+
+// Depths returns the current queue depths
+func (a *AdmissionController) Depths() QueueDepths {
+	queue, _, _ := a.full()
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return QueueDepths{
+		Tasks:    a.tasks.Pending(),
+		Events:   a.outbox.Pending(),
+		Shedding: queue != "",
+		Rejected: a.rejected,
+		Limits:   a.limits,
+	}
+}
+
+// full returns the first queue at its limit, if any, with its depth and limit
+func (a *AdmissionController) full() (queue string, depth, limit int) {
+	if limit = a.limits.MaxPendingTasks; limit > 0 {
+		if depth = a.tasks.Pending(); depth >= limit {
+			return "tasks", depth, limit
+		}
+	}
+	if limit = a.limits.MaxPendingEvents; limit > 0 {
+		if depth = a.outbox.Pending(); depth >= limit {
+			return "events", depth, limit
+		}
+	}
+	return "", 0, 0
+}
+
+// overloaded is full for a mutating request, which is counted if rejected
+func (a *AdmissionController) overloaded() (queue string, depth, limit int) {
+	queue, depth, limit = a.full()
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if shedding := queue != ""; shedding != a.shedding {
+		a.shedding = shedding
+		if shedding {
+			log.Printf("Shedding load: %d pending %s reached the limit of %d", depth, queue, limit)
+		} else {
+			log.Printf("Queues drained, accepting requests again")
+		}
+	}
+	if queue != "" {
+		a.rejected++
+	}
+	return queue, depth, limit
+}
+
+// Admit is the middleware rejecting mutating requests with 503 while a
+// queue is full. Reads and admin routes are always admitted, so the state
+// can be inspected and the queues drained.
+func (a *AdmissionController) Admit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if strings.HasPrefix(strings.TrimPrefix(c.Request.URL.Path, c.GetString("base_path")), "/admin/") {
+			c.Next()
+			return
+		}
+		if queue, depth, limit := a.overloaded(); queue != "" {
+			c.Header("Retry-After", strconv.Itoa(int(admissionRetryAfter.Seconds())))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": "Server overloaded, retry later",
+				"queue": queue,
+				"depth": depth,
+				"limit": limit,
+			})
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetQueueDepths reports the depths of the task queue and the event
+// deliveries, and whether load is shed
+func (h *RobotHandler) GetQueueDepths(c *gin.Context) {
+	c.JSON(http.StatusOK, h.admission.Depths())
+}
@@ -0,0 +1,79 @@
+package robotapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func queueDepths(t *testing.T, router *gin.Engine) QueueDepths {
+	w := adminRequest(router, "GET", "/admin/queues", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var depths QueueDepths
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &depths))
+	return depths
+}
+
+func TestAdmissionRejectsWhileTasksBackUp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	config.Admission.MaxPendingTasks = 1
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+	assert.Equal(t, http.StatusOK, adminRequest(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+
+	task := server.handler.tasks.Create("test")
+	w := adminRequest(router, "POST", "/robot/robot1/move", `{"direction": "up"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error": "Server overloaded, retry later", "queue": "tasks", "depth": 1, "limit": 1}`, w.Body.String())
+
+	// Reads and admin routes are still served
+	assert.Equal(t, http.StatusOK, adminRequest(router, "GET", "/robot/robot1/status", "").Code)
+	assert.NotEqual(t, http.StatusServiceUnavailable, adminRequest(router, "DELETE", "/admin/world/bounds", "").Code)
+	depths := queueDepths(t, router)
+	assert.Equal(t, QueueDepths{Tasks: 1, Shedding: true, Rejected: 1, Limits: config.Admission}, depths)
+
+	server.handler.tasks.Update(task.ID, func(task *Task) { task.Status = TaskSucceeded })
+	assert.Equal(t, http.StatusOK, adminRequest(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	assert.False(t, queueDepths(t, router).Shedding)
+}
+
+func TestAdmissionRejectsWhileEventsBackUp(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	config.Webhooks = []string{"http://localhost:1"}
+	config.DeliveryAttempts = 1
+	config.Admission.MaxPendingEvents = 2
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, adminRequest(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	}
+	w := adminRequest(router, "POST", "/robot/robot1/move", `{"direction": "up"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"queue":"events"`)
+	assert.Equal(t, 2, queueDepths(t, router).Events)
+
+	// The deliveries are dead-lettered after their only attempt
+	server.outbox.Flush(context.Background())
+	assert.Equal(t, 0, queueDepths(t, router).Events)
+	assert.Equal(t, http.StatusOK, adminRequest(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+}
+
+func TestAdmissionWithoutLimits(t *testing.T) {
+	router := setupMigrationServer(t).Router()
+	depths := queueDepths(t, router)
+	assert.Equal(t, QueueDepths{}, depths)
+	assert.Equal(t, http.StatusOK, adminRequest(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+}
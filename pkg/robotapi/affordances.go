@@ -0,0 +1,104 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AttackRange is the distance up to which robots can attack each other and
+// are offered as attack targets in links
+const AttackRange = 1
+
+// ErrOutOfRange is returned for attacks on robots beyond AttackRange
+var ErrOutOfRange = errors.New("target is out of range")
+
+// inAttackRange reports whether the target can be attacked from the
+// attacker's position
+func inAttackRange(layout MapLayout, attacker, target *Robot) bool {
+	return layout.Distance(attacker.Position, target.Position) <= AttackRange
+}
+
+// Affordances computes which actions a robot can take right now, so the
+// HATEOAS links of responses only offer what would be accepted: no pickup
+// link unless an item lies on the robot's cell, no attack link unless a
+// robot is in range, no battery replacement without a battery. There is no
+// charge link: robots recharge on every tick and the world has no docks.
+type Affordances struct {
+	world   *RobotStorage
+	baseURL string
+}
+
+// affordances returns the affordances of the request's world
+func (h *RobotHandler) affordances(c *gin.Context) Affordances {
+	return Affordances{world: h.world(c), baseURL: requestBaseURL(c)}
+}
+
+// Links returns a link for every action the robot can take right now
+func (a Affordances) Links(robot *Robot) []Link {
+	var links []Link
+	if robot.CanPerform("move") == nil && robot.Energy > 0 {
+		links = append(links, a.link("move", robot, "move"))
+	}
+
+	if robot.CanPerform("pickup") == nil {
+		var lying []string
+		for _, item := range a.world.GetWorldItems() {
+			if item.Position == robot.Position {
+				lying = append(lying, item.ID)
+			}
+		}
+		sort.Strings(lying)
+		for _, itemID := range lying {
+			links = append(links, a.link("pickup", robot, "pickup/"+itemID))
+		}
+	}
+
+	// Several batteries share the one replacement link
+	offered := make(map[string]bool)
+	for _, itemID := range robot.Inventory {
+		item, err := a.world.GetItem(itemID)
+		if err != nil {
+			continue
+		}
+		for _, link := range a.ItemLinks(robot, item) {
+			if !offered[link.Href] {
+				offered[link.Href] = true
+				links = append(links, link)
+			}
+		}
+	}
+
+	if robot.CanPerform("attack") == nil && robot.Energy > 0 {
+		layout := a.world.GetLayout()
+		for _, target := range a.world.GetAllRobots() {
+			if target.ID == robot.ID || target.Maintenance || !inAttackRange(layout, robot, target) {
+				continue
+			}
+			if safeZone(layout, robot, target) == "" {
+				links = append(links, a.link("attack", robot, "attack/"+target.ID))
+			}
+		}
+	}
+	return links
+}
+
+// ItemLinks returns links to the actions the robot can take right now with
+// an item it carries
+func (a Affordances) ItemLinks(robot *Robot, item Item) []Link {
+	links := []Link{}
+	if robot.CanPerform("putdown") == nil {
+		links = append(links, a.link("putdown", robot, "putdown/"+item.ID))
+	}
+	if item.Type == "battery" && robot.CanPerform("maintenance") == nil {
+		links = append(links, a.link("replace-battery", robot, "replace-battery"))
+	}
+	return links
+}
+
+// link returns a link to an action of the robot
+func (a Affordances) link(rel string, robot *Robot, path string) Link {
+	return Link{Rel: rel, Href: fmt.Sprintf("%s/robot/%s/%s", a.baseURL, robot.ID, path)}
+}
@@ -0,0 +1,74 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// statusLinks returns the rels and paths of the links in a robot's status
+func statusLinks(t *testing.T, router *gin.Engine, id string) map[string][]string {
+	w := send(router, "GET", "/robot/"+id+"/status", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var status struct {
+		Links []Link `json:"links"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	links := make(map[string][]string)
+	for _, link := range status.Links {
+		links[link.Rel] = append(links[link.Rel], link.Href[len("http://"):])
+	}
+	return links
+}
+
+func TestLinksOfferOnlyPossibleActions(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	router := gin.New()
+	router.GET("/robot/:id/status", handler.GetStatus)
+
+	// robot1 stands alone on an empty cell
+	links := statusLinks(t, router, "robot1")
+	assert.Equal(t, []string{"/robot/robot1/move"}, links["move"])
+	assert.NotContains(t, links, "pickup")
+	assert.NotContains(t, links, "attack")
+	assert.NotContains(t, links, "replace-battery")
+
+	// Next to robot2 and on item1, carrying item5
+	storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Position = Position{X: 1, Y: 1}
+		return nil
+	})
+	storage.UpdateRobot("robot2", func(robot *Robot) error {
+		robot.Position = Position{X: 1, Y: 2}
+		return nil
+	})
+	_, err := storage.PickupItem("robot1", "item5", func(robot *Robot) error {
+		robot.Inventory = append(robot.Inventory, "item5")
+		return nil
+	})
+	require.NoError(t, err)
+	links = statusLinks(t, router, "robot1")
+	assert.Equal(t, []string{"/robot/robot1/pickup/item1"}, links["pickup"])
+	assert.Equal(t, []string{"/robot/robot1/putdown/item5"}, links["putdown"])
+	assert.Equal(t, []string{"/robot/robot1/replace-battery"}, links["replace-battery"])
+	assert.Equal(t, []string{"/robot/robot1/attack/robot2"}, links["attack"])
+
+	// Targets in safe zones or maintenance cannot be attacked
+	_, err = storage.SetZone(Zone{Name: "haven", Type: ZoneSafe, Area: Bounds{MinX: 1, MinY: 2, MaxX: 1, MaxY: 2}})
+	require.NoError(t, err)
+	assert.NotContains(t, statusLinks(t, router, "robot1"), "attack")
+
+	// A stunned robot can do nothing
+	require.NoError(t, storage.ApplyEffect("robot1", Stunned(3)))
+	links = statusLinks(t, router, "robot1")
+	for _, rel := range []string{"move", "pickup", "putdown", "replace-battery", "attack"} {
+		assert.NotContains(t, links, rel)
+	}
+	assert.Contains(t, links, "self")
+}
@@ -0,0 +1,652 @@
+package robotapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Heatmap metrics
+const (
+	HeatVisits  = "visits"  // moves onto a cell
+	HeatAttacks = "attacks" // attacks on robots standing on a cell
+)
+
+// Analytics limits
+const (
+	analyticsRetention = 7 * 24 * time.Hour // windowed data is kept this long
+	maxHeatGridCells   = 250000
+	maxAttackRecords   = 100000 // attacks kept for combat windows
+	maxMostAttacked    = 10
+)
+
+// RobotStats are the lifetime counters of a robot
+type RobotStats struct {
+	RobotID         string     `json:"robot_id"`
+	Moves           int        `json:"moves"`
+	CellsVisited    int        `json:"cells_visited"` // distinct cells moved to
+	Pickups         int        `json:"pickups"`
+	Putdowns        int        `json:"putdowns"`
+	Attacks         int        `json:"attacks"`
+	Hits            int        `json:"hits"`
+	DamageDealt     int        `json:"damage_dealt"`
+	AttacksReceived int        `json:"attacks_received"`
+	DamageTaken     int        `json:"damage_taken"`
+	LastActive      *time.Time `json:"last_active,omitempty"`
+}
+
+// HeatCell counts the activity on a cell
+type HeatCell struct {
+	Position Position `json:"position"`
+	Count    int      `json:"count"` // of the requested metric
+}
+
+// heatBucket identifies the activity on a cell within one hour
+type heatBucket struct {
+	metric   string
+	hour     int64 // hours since the Unix epoch
+	position Position
+}
+
+// HeatGrid is a heatmap as a matrix of counts, ready to be rendered
+type HeatGrid struct {
+	Origin Position `json:"origin"` // cell of rows[0][0]
+	Width  int      `json:"width"`
+	Height int      `json:"height"`
+	Max    int      `json:"max"`
+	Rows   [][]int  `json:"rows"` // rows[y][x], relative to the origin
+}
+
+// NewHeatGrid lays the cells of a floor out in a grid covering the world
+// bounds, or the cells themselves in an unbounded world
+func NewHeatGrid(cells []HeatCell, bounds *Bounds, floor int) (HeatGrid, error) {
+	grid := HeatGrid{Origin: Position{Z: floor}, Rows: [][]int{}}
+	area := bounds
+	if area == nil && len(cells) > 0 {
+		first := cells[0].Position
+		area = &Bounds{MinX: first.X, MinY: first.Y, MaxX: first.X, MaxY: first.Y}
+		for _, cell := range cells {
+			area.MinX, area.MaxX = min(area.MinX, cell.Position.X), max(area.MaxX, cell.Position.X)
+			area.MinY, area.MaxY = min(area.MinY, cell.Position.Y), max(area.MaxY, cell.Position.Y)
+		}
+	}
+	if area == nil {
+		return grid, nil
+	}
+
+	grid.Origin.X, grid.Origin.Y = area.MinX, area.MinY
+	grid.Width, grid.Height = area.MaxX-area.MinX+1, area.MaxY-area.MinY+1
+	if grid.Width*grid.Height > maxHeatGridCells {
+		return grid, fmt.Errorf("the grid would have %d cells, at most %d are supported", grid.Width*grid.Height, maxHeatGridCells)
+	}
+	grid.Rows = make([][]int, grid.Height)
+	for y := range grid.Rows {
+		grid.Rows[y] = make([]int, grid.Width)
+	}
+	for _, cell := range cells {
+		if !area.Contains(cell.Position) {
+			continue
+		}
+		grid.Rows[cell.Position.Y-area.MinY][cell.Position.X-area.MinX] = cell.Count
+		grid.Max = max(grid.Max, cell.Count)
+	}
+	return grid, nil
+}
+
+// DamageEntry sums up the attacks of one robot on another
+type DamageEntry struct {
+	Attacker string `json:"attacker"`
+	Target   string `json:"target"`
+	Attacks  int    `json:"attacks"`
+	Hits     int    `json:"hits"`
+	Damage   int    `json:"damage"`
+}
+
+// attackRecord is one attack, kept for windowed combat summaries
+type attackRecord struct {
+	at       time.Time
+	attacker string
+	target   string
+	hit      bool
+	damage   int
+}
+
+// Rivalry is a pair of robots that attacked each other
+type Rivalry struct {
+	Robots  [2]string `json:"robots"`
+	Attacks int       `json:"attacks"` // in both directions
+	Damage  [2]int    `json:"damage"`  // dealt by each robot
+}
+
+// AttackedRobot counts the attacks a robot received
+type AttackedRobot struct {
+	RobotID string `json:"robot_id"`
+	Attacks int    `json:"attacks"`
+	Damage  int    `json:"damage"`
+}
+
+// CombatStreak tracks the hits (wins) and misses (losses) of an attacker
+type CombatStreak struct {
+	RobotID     string `json:"robot_id"`
+	Current     int    `json:"current"` // wins in a row if positive, losses if negative
+	LongestWin  int    `json:"longest_win"`
+	LongestLoss int    `json:"longest_loss"`
+}
+
+// CombatSummary sums up the attacks within a window
+type CombatSummary struct {
+	Damage       []DamageEntry   `json:"damage"`
+	Rivalries    []Rivalry       `json:"rivalries"`     // most attacks first
+	MostAttacked []AttackedRobot `json:"most_attacked"` // the top 10
+	Streaks      []CombatStreak  `json:"streaks"`
+}
+
+// Projection folds the events of one bus into read models for dashboards.
+// It follows the event log from a checkpoint, so reads never scan history
+// and may lag behind the latest event.
+type Projection struct {
+	events     *EventBus
+	checkpoint int64 // sequence of the last applied event
+	missed     int64 // events dropped from the log before they were applied
+	robots     map[string]*RobotStats
+	cells      map[string]map[Position]bool // robot ID -> visited cells
+	heat       map[string]map[Position]int  // metric -> all-time counts
+	buckets    map[heatBucket]int           // hourly counts within analyticsRetention
+	latestHour int64                        // buckets before it are pruned
+	damage     map[[2]string]*DamageEntry   // attacker, target
+	attacks    []attackRecord               // oldest first, within analyticsRetention
+	now        func() time.Time
+	mutex      sync.RWMutex
+}
+
+// NewProjection creates an empty projection of the bus
+func NewProjection(events *EventBus) *Projection {
+	return &Projection{
+		events: events,
+		robots: make(map[string]*RobotStats),
+		cells:  make(map[string]map[Position]bool),
+		heat: map[string]map[Position]int{
+			HeatVisits:  make(map[Position]int),
+			HeatAttacks: make(map[Position]int),
+		},
+		buckets: make(map[heatBucket]int),
+		damage:  make(map[[2]string]*DamageEntry),
+		now:     time.Now,
+	}
+}
+
+// CatchUp applies all events published since the checkpoint
+func (p *Projection) CatchUp() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, event := range p.events.Since(p.checkpoint) {
+		if event.Sequence > p.checkpoint+1 {
+			p.missed += event.Sequence - p.checkpoint - 1
+		}
+		p.apply(event)
+		p.checkpoint = event.Sequence
+	}
+}
+
+// apply folds one event into the read models
+func (p *Projection) apply(event Event) {
+	data, _ := event.Data.(gin.H)
+	switch event.Type {
+	case "robot_created":
+		p.stats(event.RobotID, event.Timestamp)
+	case "robot_moved":
+		stats := p.stats(event.RobotID, event.Timestamp)
+		stats.Moves++
+		position, ok := data["position"].(Position)
+		if !ok {
+			return
+		}
+		p.record(HeatVisits, position, event.Timestamp)
+		if p.cells[event.RobotID] == nil {
+			p.cells[event.RobotID] = make(map[Position]bool)
+		}
+		p.cells[event.RobotID][position] = true
+		stats.CellsVisited = len(p.cells[event.RobotID])
+	case "item_picked_up":
+		p.stats(event.RobotID, event.Timestamp).Pickups++
+	case "item_put_down":
+		p.stats(event.RobotID, event.Timestamp).Putdowns++
+	case "robot_attacked":
+		attackerID, _ := data["attacker"].(string)
+		hit, _ := data["hit"].(bool)
+		damage, _ := data["damage"].(int)
+		if !hit {
+			damage = 0
+		}
+		attacker, target := p.stats(attackerID, event.Timestamp), p.stats(event.RobotID, time.Time{})
+		attacker.Attacks++
+		attacker.DamageDealt += damage
+		target.AttacksReceived++
+		target.DamageTaken += damage
+		if position, ok := data["position"].(Position); ok {
+			p.record(HeatAttacks, position, event.Timestamp)
+		}
+
+		key := [2]string{attackerID, event.RobotID}
+		entry, exists := p.damage[key]
+		if !exists {
+			entry = &DamageEntry{Attacker: attackerID, Target: event.RobotID}
+			p.damage[key] = entry
+		}
+		entry.Attacks++
+		entry.Damage += damage
+		if hit {
+			attacker.Hits++
+			entry.Hits++
+		}
+
+		p.attacks = append(p.attacks, attackRecord{at: event.Timestamp, attacker: attackerID, target: event.RobotID, hit: hit, damage: damage})
+		expired := 0
+		for expired < len(p.attacks) && (len(p.attacks)-expired > maxAttackRecords || event.Timestamp.Sub(p.attacks[expired].at) > analyticsRetention) {
+			expired++
+		}
+		p.attacks = p.attacks[expired:]
+	}
+}
+
+// record counts activity on a cell, in total and for its hour
+func (p *Projection) record(metric string, position Position, at time.Time) {
+	p.heat[metric][position]++
+	hour := at.Unix() / 3600
+	p.buckets[heatBucket{metric: metric, hour: hour, position: position}]++
+	if hour <= p.latestHour {
+		return
+	}
+	p.latestHour = hour
+	oldest := hour - int64(analyticsRetention/time.Hour)
+	for bucket := range p.buckets {
+		if bucket.hour < oldest {
+			delete(p.buckets, bucket)
+		}
+	}
+}
+
+// stats returns the counters of a robot, which was active at the given
+// time unless it is zero
+func (p *Projection) stats(robotID string, active time.Time) *RobotStats {
+	stats, exists := p.robots[robotID]
+	if !exists {
+		stats = &RobotStats{RobotID: robotID}
+		p.robots[robotID] = stats
+	}
+	if !active.IsZero() {
+		active := active.UTC()
+		stats.LastActive = &active
+	}
+	return stats
+}
+
+// RobotStats returns the counters of all robots ordered by ID
+func (p *Projection) RobotStats() []RobotStats {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	stats := make([]RobotStats, 0, len(p.robots))
+	for _, robot := range p.robots {
+		stats = append(stats, *robot)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].RobotID < stats[j].RobotID
+	})
+	return stats
+}
+
+// Stats returns the counters of one robot
+func (p *Projection) Stats(robotID string) (RobotStats, bool) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	stats, exists := p.robots[robotID]
+	if !exists {
+		return RobotStats{}, false
+	}
+	return *stats, true
+}
+
+// Heatmap returns the active cells of a floor, most active first. A window
+// of 0 counts all activity, any other counts the activity in the hours
+// that overlap the window.
+func (p *Projection) Heatmap(metric string, floor int, window time.Duration) []HeatCell {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	counts := p.heat[metric]
+	if window > 0 {
+		counts = make(map[Position]int)
+		since := p.now().Add(-window).Unix() / 3600
+		for bucket, count := range p.buckets {
+			if bucket.metric == metric && bucket.hour >= since {
+				counts[bucket.position] += count
+			}
+		}
+	}
+	cells := []HeatCell{}
+	for position, count := range counts {
+		if position.Z == floor {
+			cells = append(cells, HeatCell{Position: position, Count: count})
+		}
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Count != cells[j].Count {
+			return cells[i].Count > cells[j].Count
+		}
+		if cells[i].Position.Y != cells[j].Position.Y {
+			return cells[i].Position.Y < cells[j].Position.Y
+		}
+		return cells[i].Position.X < cells[j].Position.X
+	})
+	return cells
+}
+
+// DamageMatrix returns the attacks between all pairs of robots, ordered by
+// attacker and target
+func (p *Projection) DamageMatrix() []DamageEntry {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	entries := make([]DamageEntry, 0, len(p.damage))
+	for _, entry := range p.damage {
+		entries = append(entries, *entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Attacker != entries[j].Attacker {
+			return entries[i].Attacker < entries[j].Attacker
+		}
+		return entries[i].Target < entries[j].Target
+	})
+	return entries
+}
+
+// Combat sums up the attacks within the window
+func (p *Projection) Combat(window time.Duration) CombatSummary {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	since := p.now().Add(-window)
+	damage := make(map[[2]string]*DamageEntry)
+	attacked := make(map[string]*AttackedRobot)
+	streaks := make(map[string]*CombatStreak)
+	for _, attack := range p.attacks {
+		if attack.at.Before(since) {
+			continue
+		}
+		key := [2]string{attack.attacker, attack.target}
+		entry, exists := damage[key]
+		if !exists {
+			entry = &DamageEntry{Attacker: attack.attacker, Target: attack.target}
+			damage[key] = entry
+		}
+		entry.Attacks++
+		entry.Damage += attack.damage
+
+		target, exists := attacked[attack.target]
+		if !exists {
+			target = &AttackedRobot{RobotID: attack.target}
+			attacked[attack.target] = target
+		}
+		target.Attacks++
+		target.Damage += attack.damage
+
+		streak, exists := streaks[attack.attacker]
+		if !exists {
+			streak = &CombatStreak{RobotID: attack.attacker}
+			streaks[attack.attacker] = streak
+		}
+		if attack.hit {
+			entry.Hits++
+			streak.Current = max(streak.Current, 0) + 1
+			streak.LongestWin = max(streak.LongestWin, streak.Current)
+		} else {
+			streak.Current = min(streak.Current, 0) - 1
+			streak.LongestLoss = max(streak.LongestLoss, -streak.Current)
+		}
+	}
+
+	summary := CombatSummary{
+		Damage:       []DamageEntry{},
+		Rivalries:    []Rivalry{},
+		MostAttacked: []AttackedRobot{},
+		Streaks:      []CombatStreak{},
+	}
+	for key, entry := range damage {
+		summary.Damage = append(summary.Damage, *entry)
+		back, mutual := damage[[2]string{key[1], key[0]}]
+		if mutual && key[0] < key[1] {
+			summary.Rivalries = append(summary.Rivalries, Rivalry{
+				Robots:  key,
+				Attacks: entry.Attacks + back.Attacks,
+				Damage:  [2]int{entry.Damage, back.Damage},
+			})
+		}
+	}
+	sort.Slice(summary.Damage, func(i, j int) bool {
+		if summary.Damage[i].Attacker != summary.Damage[j].Attacker {
+			return summary.Damage[i].Attacker < summary.Damage[j].Attacker
+		}
+		return summary.Damage[i].Target < summary.Damage[j].Target
+	})
+	sort.Slice(summary.Rivalries, func(i, j int) bool {
+		if summary.Rivalries[i].Attacks != summary.Rivalries[j].Attacks {
+			return summary.Rivalries[i].Attacks > summary.Rivalries[j].Attacks
+		}
+		return summary.Rivalries[i].Robots[0]+"/"+summary.Rivalries[i].Robots[1] <
+			summary.Rivalries[j].Robots[0]+"/"+summary.Rivalries[j].Robots[1]
+	})
+
+	for _, robot := range attacked {
+		summary.MostAttacked = append(summary.MostAttacked, *robot)
+	}
+	sort.Slice(summary.MostAttacked, func(i, j int) bool {
+		if summary.MostAttacked[i].Attacks != summary.MostAttacked[j].Attacks {
+			return summary.MostAttacked[i].Attacks > summary.MostAttacked[j].Attacks
+		}
+		return summary.MostAttacked[i].RobotID < summary.MostAttacked[j].RobotID
+	})
+	if len(summary.MostAttacked) > maxMostAttacked {
+		summary.MostAttacked = summary.MostAttacked[:maxMostAttacked]
+	}
+
+	for _, streak := range streaks {
+		summary.Streaks = append(summary.Streaks, *streak)
+	}
+	sort.Slice(summary.Streaks, func(i, j int) bool {
+		return summary.Streaks[i].RobotID < summary.Streaks[j].RobotID
+	})
+	return summary
+}
+
+// freshness tells clients how far the projection is behind the event log
+func (p *Projection) freshness() gin.H {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return gin.H{
+		"as_of":         p.checkpoint,
+		"lag":           p.events.Sequence() - p.checkpoint,
+		"missed_events": p.missed,
+	}
+}
+
+// Analytics keeps a projection for the event bus of every tenant
+type Analytics struct {
+	projections map[*EventBus]*Projection
+	clock       Clock
+	mutex       sync.Mutex
+}
+
+// NewAnalytics creates analytics without any projections
+func NewAnalytics() *Analytics {
+	return &Analytics{projections: make(map[*EventBus]*Projection), clock: SystemClock{}}
+}
+
+// SetClock replaces the clock that windows end at and Run ticks with; call
+// it before the first projection is started
+func (a *Analytics) SetClock(clock Clock) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.clock = clock
+}
+
+// For returns the projection of the bus, starting one if needed
+func (a *Analytics) For(events *EventBus) *Projection {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	projection, exists := a.projections[events]
+	if !exists {
+		projection = NewProjection(events)
+		projection.now = a.clock.Now
+		a.projections[events] = projection
+	}
+	return projection
+}
+
+// CatchUp brings all projections up to date
+func (a *Analytics) CatchUp() {
+	a.mutex.Lock()
+	projections := make([]*Projection, 0, len(a.projections))
+	for _, projection := range a.projections {
+		projections = append(projections, projection)
+	}
+	a.mutex.Unlock()
+
+	for _, projection := range projections {
+		projection.CatchUp()
+	}
+}
+
+// Run catches up every interval until ctx is done
+func (a *Analytics) Run(ctx context.Context, interval time.Duration) {
+	a.mutex.Lock()
+	ticker := a.clock.NewTicker(interval)
+	a.mutex.Unlock()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			a.CatchUp()
+		}
+	}
+}
+
+// analyticsResponse adds the freshness of the projection to a response
+func analyticsResponse(projection *Projection, key string, value interface{}) gin.H {
+	response := projection.freshness()
+	response[key] = value
+	return response
+}
+
+// GetRobotStats returns the counters of all robots
+func (h *RobotHandler) GetRobotStats(c *gin.Context) {
+	projection := h.analytics.For(h.bus(c))
+	c.JSON(http.StatusOK, analyticsResponse(projection, "robots", projection.RobotStats()))
+}
+
+// GetRobotStatsByID returns the counters of one robot
+func (h *RobotHandler) GetRobotStatsByID(c *gin.Context) {
+	projection := h.analytics.For(h.bus(c))
+	stats, exists := projection.Stats(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No statistics for this robot yet"})
+		return
+	}
+	c.JSON(http.StatusOK, analyticsResponse(projection, "robot", stats))
+}
+
+// GetHeatmap returns the activity on the cells of ?floor=, as a list and
+// as a grid. ?metric= is visits or attacks, ?window= limits the activity
+// to the last hours, e.g. 24h.
+func (h *RobotHandler) GetHeatmap(c *gin.Context) {
+	floor := 0
+	if value := c.Query("floor"); value != "" {
+		var err error
+		if floor, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "floor must be an integer"})
+			return
+		}
+	}
+	metric := c.DefaultQuery("metric", HeatVisits)
+	if metric != HeatVisits && metric != HeatAttacks {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "metric must be visits or attacks"})
+		return
+	}
+	window, err := parseWindow(c.Query("window"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	projection := h.analytics.For(h.bus(c))
+	cells := projection.Heatmap(metric, floor, window)
+	grid, err := NewHeatGrid(cells, h.world(c).GetLayout().Bounds, floor)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	response := analyticsResponse(projection, "cells", cells)
+	response["metric"] = metric
+	response["floor"] = floor
+	response["grid"] = grid
+	if window > 0 {
+		response["window"] = window.String()
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetCombat sums up the attacks of the last ?window=, by default of the
+// whole retention
+func (h *RobotHandler) GetCombat(c *gin.Context) {
+	window, err := parseWindow(c.Query("window"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if window == 0 {
+		window = analyticsRetention
+	}
+
+	projection := h.analytics.For(h.bus(c))
+	summary := projection.Combat(window)
+	response := analyticsResponse(projection, "damage", summary.Damage)
+	response["window"] = window.String()
+	response["rivalries"] = summary.Rivalries
+	response["most_attacked"] = summary.MostAttacked
+	response["streaks"] = summary.Streaks
+	c.JSON(http.StatusOK, response)
+}
+
+// parseWindow parses a window like 24h; empty means no window
+func parseWindow(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	window, err := time.ParseDuration(value)
+	if err != nil || window <= 0 {
+		return 0, errors.New("window must be a positive duration like 24h")
+	}
+	if window > analyticsRetention {
+		return 0, fmt.Errorf("window must be at most %s", analyticsRetention)
+	}
+	return window, nil
+}
+
+// GetDamageMatrix returns the attacks between all pairs of robots
+func (h *RobotHandler) GetDamageMatrix(c *gin.Context) {
+	projection := h.analytics.For(h.bus(c))
+	c.JSON(http.StatusOK, analyticsResponse(projection, "damage", projection.DamageMatrix()))
+}
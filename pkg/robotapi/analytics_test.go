@@ -0,0 +1,197 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjection(t *testing.T) {
+	events := NewEventBus()
+	projection := NewProjection(events)
+	events.Publish("robot_created", "robot3", nil)
+	events.Publish("robot_moved", "robot1", gin.H{"position": Position{X: 1, Y: 0}})
+	events.Publish("robot_moved", "robot1", gin.H{"position": Position{X: 1, Y: 1}})
+	events.Publish("robot_moved", "robot1", gin.H{"position": Position{X: 1, Y: 0}})
+	events.Publish("robot_moved", "robot2", gin.H{"position": Position{X: 1, Y: 0, Z: 1}})
+	events.Publish("item_picked_up", "robot1", gin.H{"item": "item1"})
+	events.Publish("robot_attacked", "robot2", gin.H{"attacker": "robot1", "hit": true, "damage": 15})
+	events.Publish("robot_attacked", "robot2", gin.H{"attacker": "robot1", "hit": false, "damage": 0})
+	events.Publish("robot_attacked", "robot1", gin.H{"attacker": "robot2", "hit": true, "damage": 5})
+
+	// Nothing is projected before the projection caught up
+	assert.Empty(t, projection.RobotStats())
+	assert.Equal(t, int64(9), projection.freshness()["lag"])
+	projection.CatchUp()
+	assert.Equal(t, int64(0), projection.freshness()["lag"])
+
+	stats, exists := projection.Stats("robot1")
+	assert.True(t, exists)
+	assert.Equal(t, 3, stats.Moves)
+	assert.Equal(t, 2, stats.CellsVisited)
+	assert.Equal(t, 1, stats.Pickups)
+	assert.Equal(t, 2, stats.Attacks)
+	assert.Equal(t, 1, stats.Hits)
+	assert.Equal(t, 15, stats.DamageDealt)
+	assert.Equal(t, 5, stats.DamageTaken)
+	assert.NotNil(t, stats.LastActive)
+	assert.Len(t, projection.RobotStats(), 3)
+
+	assert.Equal(t, []HeatCell{
+		{Position: Position{X: 1, Y: 0}, Count: 2},
+		{Position: Position{X: 1, Y: 1}, Count: 1},
+	}, projection.Heatmap(HeatVisits, 0, 0))
+	assert.Len(t, projection.Heatmap(HeatVisits, 1, 0), 1)
+
+	assert.Equal(t, []DamageEntry{
+		{Attacker: "robot1", Target: "robot2", Attacks: 2, Hits: 1, Damage: 15},
+		{Attacker: "robot2", Target: "robot1", Attacks: 1, Hits: 1, Damage: 5},
+	}, projection.DamageMatrix())
+}
+
+func TestProjectionCountsMissedEvents(t *testing.T) {
+	events := NewEventBus()
+	projection := NewProjection(events)
+	for i := 0; i < maxEventLog+5; i++ {
+		events.Publish("robot_moved", "robot1", gin.H{"position": Position{X: 1}})
+	}
+	projection.CatchUp()
+	assert.Equal(t, int64(5), projection.freshness()["missed_events"])
+	stats, _ := projection.Stats("robot1")
+	assert.Equal(t, maxEventLog, stats.Moves)
+}
+
+func TestHeatmapWindow(t *testing.T) {
+	events := NewEventBus()
+	projection := NewProjection(events)
+	events.Publish("robot_moved", "robot1", gin.H{"position": Position{X: 2, Y: 3}})
+	events.Publish("robot_attacked", "robot2", gin.H{"attacker": "robot1", "hit": true, "damage": 5, "position": Position{X: 4, Y: 1}})
+	projection.CatchUp()
+
+	assert.Equal(t, []HeatCell{{Position: Position{X: 4, Y: 1}, Count: 1}}, projection.Heatmap(HeatAttacks, 0, 24*time.Hour))
+	projection.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	assert.Empty(t, projection.Heatmap(HeatVisits, 0, 24*time.Hour))
+	assert.Len(t, projection.Heatmap(HeatVisits, 0, 72*time.Hour), 1)
+	assert.Len(t, projection.Heatmap(HeatVisits, 0, 0), 1, "all-time counts ignore the window")
+}
+
+func TestHeatGrid(t *testing.T) {
+	cells := []HeatCell{{Position: Position{X: 3, Y: 1}, Count: 4}, {Position: Position{X: 1, Y: 2}, Count: 1}}
+	grid, err := NewHeatGrid(cells, nil, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, Position{X: 1, Y: 1}, grid.Origin)
+	assert.Equal(t, [][]int{{0, 0, 4}, {1, 0, 0}}, grid.Rows)
+	assert.Equal(t, 4, grid.Max)
+
+	grid, err = NewHeatGrid(cells, &Bounds{MinX: 0, MinY: 0, MaxX: 3, MaxY: 1}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]int{{0, 0, 0, 0}, {0, 0, 0, 4}}, grid.Rows)
+
+	grid, err = NewHeatGrid(nil, nil, 0)
+	assert.NoError(t, err)
+	assert.Empty(t, grid.Rows)
+	_, err = NewHeatGrid(nil, &Bounds{MaxX: 1000, MaxY: 1000}, 0)
+	assert.Error(t, err)
+}
+
+func TestCombatSummary(t *testing.T) {
+	events := NewEventBus()
+	projection := NewProjection(events)
+	attack := func(attacker, target string, hit bool, damage int) {
+		events.Publish("robot_attacked", target, gin.H{"attacker": attacker, "hit": hit, "damage": damage})
+	}
+	attack("robot1", "robot2", true, 10)
+	attack("robot1", "robot2", true, 10)
+	attack("robot1", "robot3", false, 0)
+	attack("robot2", "robot1", true, 5)
+	attack("robot3", "robot2", true, 7)
+	attack("robot1", "robot2", true, 10)
+	projection.CatchUp()
+
+	summary := projection.Combat(time.Hour)
+	assert.Len(t, summary.Damage, 4)
+	assert.Equal(t, DamageEntry{Attacker: "robot1", Target: "robot2", Attacks: 3, Hits: 3, Damage: 30}, summary.Damage[0])
+	assert.Equal(t, []Rivalry{{Robots: [2]string{"robot1", "robot2"}, Attacks: 4, Damage: [2]int{30, 5}}}, summary.Rivalries)
+	assert.Equal(t, AttackedRobot{RobotID: "robot2", Attacks: 4, Damage: 37}, summary.MostAttacked[0])
+	assert.Equal(t, CombatStreak{RobotID: "robot1", Current: 1, LongestWin: 2, LongestLoss: 1}, summary.Streaks[0])
+
+	// Attacks before the window are left out
+	projection.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+	summary = projection.Combat(time.Hour)
+	assert.Empty(t, summary.Damage)
+	assert.Empty(t, summary.Streaks)
+}
+
+func TestAnalyticsEndpoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, err := New(DefaultConfig())
+	assert.NoError(t, err)
+	router := server.Router()
+
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	placeInRange(t, server.Storage(), "robot1", "robot2")
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/attack/robot2", "").Code)
+	assert.Equal(t, http.StatusNotFound, send(router, "GET", "/analytics/robots/robot1", "").Code)
+	server.analytics.CatchUp()
+
+	var robot struct {
+		Robot RobotStats `json:"robot"`
+		AsOf  int64      `json:"as_of"`
+		Lag   int64      `json:"lag"`
+	}
+	w := send(router, "GET", "/analytics/robots/robot1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &robot)
+	assert.Equal(t, 1, robot.Robot.Moves)
+	assert.Equal(t, 1, robot.Robot.Attacks)
+	assert.Equal(t, server.Events().Sequence(), robot.AsOf)
+	assert.Zero(t, robot.Lag)
+
+	var heatmap struct {
+		Cells []HeatCell `json:"cells"`
+	}
+	w = send(router, "GET", "/analytics/heatmap", "")
+	json.Unmarshal(w.Body.Bytes(), &heatmap)
+	assert.Len(t, heatmap.Cells, 1)
+	assert.Equal(t, 1, heatmap.Cells[0].Count)
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/analytics/heatmap?floor=up", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/analytics/heatmap?metric=pickups", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/analytics/heatmap?window=30d", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/analytics/heatmap?window=200h", "").Code)
+
+	var attacks struct {
+		Metric string   `json:"metric"`
+		Window string   `json:"window"`
+		Grid   HeatGrid `json:"grid"`
+	}
+	w = send(router, "GET", "/analytics/heatmap?metric=attacks&window=24h", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &attacks)
+	assert.Equal(t, "attacks", attacks.Metric)
+	assert.Equal(t, "24h0m0s", attacks.Window)
+	robot2, _ := server.Storage().GetRobot("robot2")
+	assert.Equal(t, Position{X: robot2.Position.X, Y: robot2.Position.Y}, attacks.Grid.Origin)
+	assert.Equal(t, [][]int{{1}}, attacks.Grid.Rows)
+
+	var combat struct {
+		Window       string          `json:"window"`
+		MostAttacked []AttackedRobot `json:"most_attacked"`
+	}
+	w = send(router, "GET", "/analytics/combat", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &combat)
+	assert.Equal(t, "168h0m0s", combat.Window)
+	assert.Equal(t, "robot2", combat.MostAttacked[0].RobotID)
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/analytics/combat?window=-1h", "").Code)
+
+	var damage struct {
+		Damage []DamageEntry `json:"damage"`
+	}
+	json.Unmarshal(send(router, "GET", "/analytics/damage", "").Body.Bytes(), &damage)
+	assert.Len(t, damage.Damage, 1)
+	assert.Equal(t, "robot2", damage.Damage[0].Target)
+}
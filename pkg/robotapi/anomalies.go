@@ -0,0 +1,241 @@
+package robotapi
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Anomaly types
+const (
+	AnomalyMovementRate = "movement_rate" // more moves per second than a robot can drive
+	AnomalyEnergyGain   = "energy_gain"   // energy gained other than by charging
+	AnomalyActionBurst  = "action_burst"  // more actions per second than allowed
+)
+
+// Anomaly detector limits
+const (
+	maxAnomalies    = 1000        // newest flags kept
+	anomalyCooldown = time.Minute // a robot is flagged once per type and cooldown
+)
+
+// anomalyActions are the events of actions a robot takes, counted towards
+// bursts
+var anomalyActions = map[string]bool{
+	"robot_moved":            true,
+	"item_picked_up":         true,
+	"item_put_down":          true,
+	"robot_attacked":         true, // counted for the attacker
+	"robot_velocity_changed": true,
+	"custom_action":          true,
+	"battery_replaced":       true,
+}
+
+// AnomalyThresholds are the rates above which robots are flagged
+type AnomalyThresholds struct {
+	MovesPerSecond   int
+	ActionsPerSecond int
+}
+
+// DefaultAnomalyThresholds allow a little more than a client sending
+// commands as fast as the API answers them
+func DefaultAnomalyThresholds() AnomalyThresholds {
+	return AnomalyThresholds{MovesPerSecond: 5, ActionsPerSecond: 10}
+}
+
+// Anomaly is a suspicious pattern of a robot
+type Anomaly struct {
+	ID         string    `json:"id"`
+	Tenant     string    `json:"tenant,omitempty"`
+	RobotID    string    `json:"robot_id"`
+	Type       string    `json:"type"`
+	Details    string    `json:"details"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// anomalyWatch follows the events and energy of one world
+type anomalyWatch struct {
+	tenant     string
+	storage    *RobotStorage
+	events     *EventBus
+	checkpoint int64
+	moves      map[string][]time.Time // per robot, within the last second
+	actions    map[string][]time.Time
+}
+
+// AnomalyDetector flags robots whose activity is impossible in the game,
+// like moving faster than robots drive or gaining energy without charging
+type AnomalyDetector struct {
+	thresholds AnomalyThresholds
+	watches    []*anomalyWatch
+	anomalies  []Anomaly               // oldest first
+	flagged    map[[3]string]time.Time // tenant, robot and type
+	clock      Clock
+	mutex      sync.Mutex
+}
+
+// NewAnomalyDetector creates a detector watching no worlds yet
+func NewAnomalyDetector(thresholds AnomalyThresholds) *AnomalyDetector {
+	return &AnomalyDetector{thresholds: thresholds, anomalies: []Anomaly{}, flagged: make(map[[3]string]time.Time), clock: SystemClock{}}
+}
+
+// SetClock replaces the clock Run ticks with
+func (d *AnomalyDetector) SetClock(clock Clock) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.clock = clock
+}
+
+// Watch analyzes the world of a tenant from now on
+func (d *AnomalyDetector) Watch(tenant string, storage *RobotStorage, events *EventBus) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, watch := range d.watches {
+		if watch.events == events {
+			return
+		}
+	}
+	d.watches = append(d.watches, &anomalyWatch{
+		tenant:     tenant,
+		storage:    storage,
+		events:     events,
+		checkpoint: events.Sequence(),
+		moves:      make(map[string][]time.Time),
+		actions:    make(map[string][]time.Time),
+	})
+}
+
+// Analyze checks the events and energy gains since the last call and
+// publishes an anomaly_detected event for every robot flagged
+func (d *AnomalyDetector) Analyze() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	for _, watch := range d.watches {
+		for _, event := range watch.events.Since(watch.checkpoint) {
+			watch.checkpoint = event.Sequence
+			d.analyzeEvent(watch, event)
+		}
+		for _, gain := range watch.storage.EnergyLedger().TakeGains() {
+			d.flag(watch, gain.RobotID, AnomalyEnergyGain, gain.At,
+				fmt.Sprintf("gained %d energy by %s", gain.Amount, gain.Source))
+		}
+	}
+}
+
+// analyzeEvent counts the event towards the rates of the robot that caused it
+func (d *AnomalyDetector) analyzeEvent(watch *anomalyWatch, event Event) {
+	if !anomalyActions[event.Type] {
+		return
+	}
+	robotID := event.RobotID
+	if event.Type == "robot_attacked" {
+		data, _ := event.Data.(gin.H)
+		robotID, _ = data["attacker"].(string)
+	}
+	if robotID == "" {
+		return
+	}
+
+	if count := countRecent(watch.actions, robotID, event.Timestamp); count > d.thresholds.ActionsPerSecond {
+		d.flag(watch, robotID, AnomalyActionBurst, event.Timestamp,
+			fmt.Sprintf("%d actions within a second, at most %d are allowed", count, d.thresholds.ActionsPerSecond))
+	}
+	if event.Type != "robot_moved" {
+		return
+	}
+	if count := countRecent(watch.moves, robotID, event.Timestamp); count > d.thresholds.MovesPerSecond {
+		d.flag(watch, robotID, AnomalyMovementRate, event.Timestamp,
+			fmt.Sprintf("%d moves within a second, at most %d are possible", count, d.thresholds.MovesPerSecond))
+	}
+}
+
+// countRecent adds at to the robot's times and returns how many of them are
+// within the second before at
+func countRecent(times map[string][]time.Time, robotID string, at time.Time) int {
+	recent := []time.Time{}
+	for _, previous := range times[robotID] {
+		if at.Sub(previous) < time.Second {
+			recent = append(recent, previous)
+		}
+	}
+	times[robotID] = append(recent, at)
+	return len(times[robotID])
+}
+
+// flag records an anomaly unless the robot was flagged for the same type
+// within the cooldown; callers must hold the lock
+func (d *AnomalyDetector) flag(watch *anomalyWatch, robotID, anomalyType string, at time.Time, details string) {
+	key := [3]string{watch.tenant, robotID, anomalyType}
+	if last, exists := d.flagged[key]; exists && at.Sub(last) < anomalyCooldown {
+		return
+	}
+	d.flagged[key] = at
+
+	anomaly := Anomaly{
+		ID:         UUIDGenerator{}.NewID(),
+		Tenant:     watch.tenant,
+		RobotID:    robotID,
+		Type:       anomalyType,
+		Details:    details,
+		DetectedAt: at,
+	}
+	d.anomalies = append(d.anomalies, anomaly)
+	if len(d.anomalies) > maxAnomalies {
+		d.anomalies = d.anomalies[len(d.anomalies)-maxAnomalies:]
+	}
+	watch.events.Publish("anomaly_detected", robotID, gin.H{"id": anomaly.ID, "type": anomalyType, "details": details})
+}
+
+// Anomalies returns the flagged anomalies, newest first
+func (d *AnomalyDetector) Anomalies() []Anomaly {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	anomalies := make([]Anomaly, 0, len(d.anomalies))
+	for i := len(d.anomalies) - 1; i >= 0; i-- {
+		anomalies = append(anomalies, d.anomalies[i])
+	}
+	return anomalies
+}
+
+// Run analyzes every interval until ctx is done
+func (d *AnomalyDetector) Run(ctx context.Context, interval time.Duration) {
+	d.mutex.Lock()
+	ticker := d.clock.NewTicker(interval)
+	d.mutex.Unlock()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			d.Analyze()
+		}
+	}
+}
+
+// ListAnomalies returns the flagged anomalies, filtered by ?tenant=,
+// ?robot= and ?type=
+func (h *RobotHandler) ListAnomalies(c *gin.Context) {
+	anomalies := []Anomaly{}
+	for _, anomaly := range h.anomalies.Anomalies() {
+		if tenant, set := c.GetQuery("tenant"); set && anomaly.Tenant != tenant {
+			continue
+		}
+		if robotID := c.Query("robot"); robotID != "" && anomaly.RobotID != robotID {
+			continue
+		}
+		if anomalyType := c.Query("type"); anomalyType != "" && anomaly.Type != anomalyType {
+			continue
+		}
+		anomalies = append(anomalies, anomaly)
+	}
+	c.JSON(http.StatusOK, gin.H{"anomalies": anomalies})
+}
@@ -0,0 +1,93 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func adminRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAnomalyDetector(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.SaveRobot(&Robot{ID: "robot1", Energy: 50, Inventory: []string{}, Actions: []Action{}})
+	events := NewEventBus()
+	events.Publish("robot_moved", "robot1", gin.H{"position": Position{X: 1}}) // before watching
+	detector := NewAnomalyDetector(AnomalyThresholds{MovesPerSecond: 2, ActionsPerSecond: 3})
+	detector.Watch("", storage, events)
+
+	events.Publish("robot_moved", "robot1", gin.H{"position": Position{X: 2}})
+	events.Publish("robot_moved", "robot1", gin.H{"position": Position{X: 3}})
+	events.Publish("robot_attacked", "robot1", gin.H{"attacker": "robot2", "hit": true, "damage": 5})
+	detector.Analyze()
+	assert.Empty(t, detector.Anomalies())
+
+	events.Publish("robot_moved", "robot1", gin.H{"position": Position{X: 4}})
+	events.Publish("robot_moved", "robot1", gin.H{"position": Position{X: 5}})
+	storage.Recharge(func(robot *Robot) int { return 5 })
+	_, err := storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Energy = 100
+		appendAction(robot, "update", "Updated energy to 100", nil)
+		return nil
+	})
+	assert.NoError(t, err)
+	detector.Analyze()
+
+	anomalies := detector.Anomalies()
+	assert.Len(t, anomalies, 3, "a robot is flagged once per type")
+	assert.Equal(t, AnomalyEnergyGain, anomalies[0].Type)
+	assert.Equal(t, "gained 45 energy by update", anomalies[0].Details)
+	assert.Equal(t, AnomalyActionBurst, anomalies[1].Type)
+	assert.Equal(t, AnomalyMovementRate, anomalies[2].Type)
+	for _, anomaly := range anomalies {
+		assert.Equal(t, "robot1", anomaly.RobotID)
+	}
+
+	// Flagged robots are published, and the detector ignores its own events
+	flagged := 0
+	for _, event := range events.Since(0) {
+		if event.Type == "anomaly_detected" {
+			flagged++
+		}
+	}
+	assert.Equal(t, 3, flagged)
+	detector.Analyze()
+	assert.Len(t, detector.Anomalies(), 3)
+}
+
+func TestListAnomalies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	config.Anomalies = AnomalyThresholds{MovesPerSecond: 1, ActionsPerSecond: 10}
+	server, err := New(config)
+	assert.NoError(t, err)
+	router := server.Router()
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	server.anomalies.Analyze()
+
+	var response struct {
+		Anomalies []Anomaly `json:"anomalies"`
+	}
+	w := adminRequest(router, "GET", "/admin/anomalies?robot=robot1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Len(t, response.Anomalies, 1)
+	assert.Equal(t, AnomalyMovementRate, response.Anomalies[0].Type)
+
+	json.Unmarshal(adminRequest(router, "GET", "/admin/anomalies?type=energy_gain", "").Body.Bytes(), &response)
+	assert.Empty(t, response.Anomalies)
+}
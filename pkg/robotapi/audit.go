@@ -0,0 +1,91 @@
+package robotapi
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AuditRecord names the request an action was caused by. Actions only
+// carry their command ID publicly, the rest is shown to admins alone.
+type AuditRecord struct {
+	RobotID   string    `json:"robot_id"`
+	Number    int       `json:"number"` // of the action in the robot's history, from 1
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	CommandID string    `json:"command_id,omitempty"`
+	Actor     string    `json:"actor,omitempty"`
+	ClientIP  string    `json:"client_ip,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+}
+
+// auditRecord describes the origin of a robot's action, the bool is false
+// for actions of the simulation
+func auditRecord(robotID string, number int, action Action) (AuditRecord, bool) {
+	if action.Actor == "" && action.ClientIP == "" && action.UserAgent == "" {
+		return AuditRecord{}, false
+	}
+	return AuditRecord{
+		RobotID:   robotID,
+		Number:    number,
+		Type:      action.Type,
+		Timestamp: action.Timestamp,
+		CommandID: action.CommandID,
+		Actor:     action.Actor,
+		ClientIP:  action.ClientIP,
+		UserAgent: action.UserAgent,
+	}, true
+}
+
+// Audit returns the origins of the actions caused by requests, by robot and
+// number. An empty robot ID or actor matches all.
+func (s *RobotStorage) Audit(robotID, actor string) []AuditRecord {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.audit(robotID, actor)
+}
+
+// audit collects the audit records; callers must hold the lock
+func (s *RobotStorage) audit(robotID, actor string) []AuditRecord {
+	records := []AuditRecord{}
+	for _, id := range s.sortedRobotIDs() {
+		if robotID != "" && id != robotID {
+			continue
+		}
+		for i, action := range s.robots[id].Actions {
+			record, caused := auditRecord(id, i+1, action)
+			if caused && (actor == "" || record.Actor == actor) {
+				records = append(records, record)
+			}
+		}
+	}
+	return records
+}
+
+// restoreAudit puts the origins back onto the actions they were taken
+// from; callers must hold the lock
+func (s *RobotStorage) restoreAudit(records []AuditRecord) {
+	for _, record := range records {
+		robot, exists := s.robots[record.RobotID]
+		if !exists || record.Number < 1 || record.Number > len(robot.Actions) {
+			continue
+		}
+		action := &robot.Actions[record.Number-1]
+		action.Actor = record.Actor
+		action.ClientIP = record.ClientIP
+		action.UserAgent = record.UserAgent
+	}
+}
+
+// GetAudit lists who caused the actions of the world's robots, filtered by
+// robot and actor
+func (h *RobotHandler) GetAudit(c *gin.Context) {
+	page, size, ok := parsePagination(c, 50)
+	if !ok {
+		return
+	}
+	records := h.world(c).Audit(c.Query("robot"), c.Query("actor"))
+	pageInfo, startIndex, endIndex := paginate(len(records), page, size)
+	c.JSON(http.StatusOK, gin.H{"page": pageInfo, "records": records[startIndex:endIndex]})
+}
@@ -0,0 +1,92 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditIsForAdminsOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.APIKeys = "alice=alice-key,bob=bob-key"
+	config.AdminToken = "secret"
+	config.CacheTTL = 0
+	server, err := New(config)
+	assert.NoError(t, err)
+	router := server.Router()
+
+	send := func(method, path, key, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "alice-laptop/1.0")
+		if key == "secret" {
+			req.Header.Set("Authorization", "Bearer secret")
+		} else if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusOK, send("POST", "/robot/robot1/move", "alice-key", `{"direction": "up"}`).Code)
+
+	// Histories and search results only carry the command ID
+	for _, path := range []string{"/robot/robot1/actions?size=100", "/search?q=moved"} {
+		w := send("GET", path, "bob-key", "")
+		assert.Equal(t, http.StatusOK, w.Code, path)
+		assert.Contains(t, w.Body.String(), "command_id", path)
+		for _, field := range []string{"alice-laptop", "client_ip", "user_agent", `"actor"`} {
+			assert.NotContains(t, w.Body.String(), field, path)
+		}
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, send("GET", "/admin/audit", "", "").Code)
+	assert.Equal(t, http.StatusForbidden, send("GET", "/admin/audit", "bob-key", "").Code)
+
+	var audit struct {
+		Page    PageInfo      `json:"page"`
+		Records []AuditRecord `json:"records"`
+	}
+	w := send("GET", "/admin/audit?robot=robot1&actor=alice", "secret", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &audit)
+	if assert.Len(t, audit.Records, 1) {
+		record := audit.Records[0]
+		assert.Equal(t, "robot1", record.RobotID)
+		assert.Equal(t, "move", record.Type)
+		assert.Equal(t, "alice-laptop/1.0", record.UserAgent)
+		assert.Equal(t, "192.0.2.1", record.ClientIP)
+		assert.NotEmpty(t, record.CommandID)
+	}
+	json.Unmarshal(send("GET", "/admin/audit?actor=bob", "secret", "").Body.Bytes(), &audit)
+	assert.Empty(t, audit.Records)
+}
+
+func TestSnapshotsKeepTheAudit(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	_, err := storage.UpdateRobot("robot1", func(robot *Robot) error {
+		appendCommandAction(robot, ActionOrigin{Actor: "alice", ClientIP: "203.0.113.7"}, "move", "Robot moved up", nil)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	encoded, err := json.Marshal(storage.Snapshot())
+	assert.NoError(t, err)
+	var snapshot WorldSnapshot
+	assert.NoError(t, json.Unmarshal(encoded, &snapshot))
+
+	restored := NewRobotStorage()
+	restored.Restore(snapshot)
+	assert.Len(t, restored.Audit("", ""), len(storage.Audit("", "")))
+	records := restored.Audit("robot1", "alice")
+	if assert.Len(t, records, 1) {
+		assert.Equal(t, "203.0.113.7", records[0].ClientIP)
+	}
+}
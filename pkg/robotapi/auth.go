@@ -0,0 +1,347 @@
+package robotapi
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permissions that can be delegated to other API keys
+const (
+	PermMove   = "move"
+	PermItems  = "items" // pick up and put down
+	PermAttack = "attack"
+	PermUpdate = "update"
+	PermScan   = "scan"
+)
+
+// allPermissions lists every permission a robot owner can grant
+var allPermissions = []string{PermMove, PermItems, PermAttack, PermUpdate, PermScan}
+
+// Roles of authenticated callers
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Principal is an authenticated caller
+type Principal struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name,omitempty"`
+	Email    string   `json:"email,omitempty"`
+	Provider string   `json:"provider"` // "api_key", "signature", "oidc", "certificate" or "session"
+	Roles    []string `json:"roles"`
+	Robot    string   `json:"robot,omitempty"`  // session principals are bound to one robot
+	Tenant   string   `json:"tenant,omitempty"` // empty for the default tenant
+}
+
+// HasRole checks whether the principal has the given role
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyStore maps API keys to the principals they belong to
+type APIKeyStore struct {
+	keys  map[string]string // key -> principal ID
+	mutex sync.RWMutex
+}
+
+// NewAPIKeyStore creates a key store from a comma separated list of
+// "name=key" pairs, e.g. "alice=secret1,bob=secret2"
+func NewAPIKeyStore(config string) (*APIKeyStore, error) {
+	store := &APIKeyStore{keys: make(map[string]string)}
+	for _, pair := range strings.Split(config, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		name, key, found := strings.Cut(pair, "=")
+		if !found || name == "" || key == "" {
+			return nil, errors.New("API keys must be given as name=key pairs")
+		}
+		if strings.Contains(name, ":") {
+			// Prefixed IDs like "oidc:..." are reserved for other providers
+			return nil, errors.New("API key names must not contain ':'")
+		}
+		store.keys[key] = name
+	}
+	return store, nil
+}
+
+// Add issues an API key to a principal, e.g. a newly registered device
+func (s *APIKeyStore) Add(name, key string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.keys[key] = name
+}
+
+// Enabled reports whether any API keys are configured
+func (s *APIKeyStore) Enabled() bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.keys) > 0
+}
+
+// Lookup returns the principal ID for an API key
+func (s *APIKeyStore) Lookup(key string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for candidate, name := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// Has reports whether an API key with the given principal name exists
+func (s *APIKeyStore) Has(name string) bool {
+	_, exists := s.Secret(name)
+	return exists
+}
+
+// Secret returns the API key of a principal, used to verify signed requests
+func (s *APIKeyStore) Secret(name string) (string, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for key, candidate := range s.keys {
+		if candidate == name && name != "" {
+			return key, true
+		}
+	}
+	return "", false
+}
+
+// Authenticator resolves request credentials to principals. It accepts API
+// keys in the X-API-Key header, requests signed with an API key (X-Signature),
+// session tokens in the X-Session-Token header and, if configured, OIDC ID
+// tokens as bearer tokens in the Authorization header and TLS client
+// certificates.
+type Authenticator struct {
+	keys         *APIKeyStore
+	signatures   *SignatureVerifier
+	oidc         *OIDCVerifier // nil if OIDC is not configured
+	sessions     *SessionStore
+	certificates bool
+	tenants      *TenantStore // nil if there are no tenants besides the default one
+}
+
+// NewAuthenticator creates an authenticator; oidc may be nil
+func NewAuthenticator(keys *APIKeyStore, oidc *OIDCVerifier, sessions *SessionStore) *Authenticator {
+	return &Authenticator{
+		keys:       keys,
+		signatures: NewSignatureVerifier(keys),
+		oidc:       oidc,
+		sessions:   sessions,
+	}
+}
+
+// EnableClientCertificates accepts verified TLS client certificates as
+// credentials. The server's TLS configuration must request them.
+func (a *Authenticator) EnableClientCertificates() {
+	a.certificates = true
+}
+
+// SetTenants lets principals be scoped to the given tenants
+func (a *Authenticator) SetTenants(tenants *TenantStore) {
+	a.tenants = tenants
+}
+
+// Enabled reports whether any authentication method is configured
+func (a *Authenticator) Enabled() bool {
+	return a.keys.Enabled() || a.oidc != nil || a.certificates
+}
+
+// Identify returns the principal for the request's credentials. It returns
+// nil without an error if the request carries no credentials.
+func (a *Authenticator) Identify(c *gin.Context) (*Principal, error) {
+	if c.GetHeader("X-Signature") != "" {
+		name, err := a.signatures.Verify(c.Writer, c.Request)
+		if err != nil {
+			return nil, err
+		}
+		return &Principal{ID: name, Provider: "signature", Roles: []string{RoleUser}, Tenant: tenantOfName(name)}, nil
+	}
+
+	if key := c.GetHeader("X-API-Key"); key != "" {
+		name, ok := a.keys.Lookup(key)
+		if !ok {
+			return nil, errors.New("Invalid API key")
+		}
+		return &Principal{ID: name, Provider: "api_key", Roles: []string{RoleUser}, Tenant: tenantOfName(name)}, nil
+	}
+
+	if token := c.GetHeader("X-Session-Token"); token != "" {
+		principal, ok := a.sessions.Lookup(token)
+		if !ok {
+			return nil, errors.New("Invalid or expired session token")
+		}
+		return principal, nil
+	}
+
+	if token, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); found && a.oidc != nil {
+		principal, err := a.oidc.Verify(token)
+		if err != nil {
+			return nil, errors.New("Invalid ID token: " + err.Error())
+		}
+		return principal, nil
+	}
+
+	// Explicit credentials take precedence over the connection's certificate
+	if a.certificates {
+		if principal := certificatePrincipal(c.Request.TLS); principal != nil {
+			return principal, nil
+		}
+	}
+	return nil, nil
+}
+
+// Authenticate resolves the caller to a principal. When no authentication
+// method is configured, authentication is disabled and all requests pass.
+func Authenticate(auth *Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.Enabled() {
+			c.Next()
+			return
+		}
+
+		principal, err := auth.Identify(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if principal == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key or ID token required"})
+			return
+		}
+		if principal.Robot != "" && c.Param("id") != principal.Robot {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Session token is bound to robot " + principal.Robot})
+			return
+		}
+		if !scopeToPrincipal(c, principal, auth.tenants) {
+			return
+		}
+
+		c.Set("principal", principal)
+		c.Next()
+	}
+}
+
+// currentPrincipal returns the authenticated caller or nil if auth is disabled
+func currentPrincipal(c *gin.Context) *Principal {
+	if value, exists := c.Get("principal"); exists {
+		return value.(*Principal)
+	}
+	return nil
+}
+
+// RequirePermission only lets the request through if the caller owns the
+// robot in the :id path parameter or was granted the given permission.
+// Robots without an owner can be controlled by everyone.
+func (h *RobotHandler) RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal := currentPrincipal(c)
+		if principal == nil {
+			c.Next()
+			return
+		}
+
+		robot, err := h.world(c).GetRobot(c.Param("id"))
+		if err != nil {
+			// Let the handler answer with its usual 404
+			c.Next()
+			return
+		}
+
+		if robot.Owner == "" || robot.Owner == principal.ID ||
+			h.world(c).HasPermission(robot.ID, principal.ID, permission) {
+			c.Next()
+			return
+		}
+
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing permission: " + permission})
+	}
+}
+
+// validPermission checks whether a permission name is known
+func validPermission(permission string) bool {
+	for _, known := range allPermissions {
+		if permission == known {
+			return true
+		}
+	}
+	return false
+}
+
+// GrantPermissions stores the permissions a grantee holds on a robot,
+// replacing any earlier grant. An empty list revokes all permissions.
+func (s *RobotStorage) GrantPermissions(robotID, grantee string, permissions []string) error {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if _, exists := s.robots[robotID]; !exists {
+		return ErrRobotNotFound
+	}
+
+	if len(permissions) == 0 {
+		delete(s.grants[robotID], grantee)
+		return nil
+	}
+	if s.grants[robotID] == nil {
+		s.grants[robotID] = make(map[string][]string)
+	}
+	s.grants[robotID][grantee] = append([]string{}, permissions...)
+	return nil
+}
+
+// RevokeAllPermissions revokes every grant on a robot and returns the
+// grantees that lost their permissions
+func (s *RobotStorage) RevokeAllPermissions(robotID string) []string {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	grantees := make([]string, 0, len(s.grants[robotID]))
+	for grantee := range s.grants[robotID] {
+		grantees = append(grantees, grantee)
+	}
+	sort.Strings(grantees)
+	delete(s.grants, robotID)
+	return grantees
+}
+
+// GetPermissions returns all grants on a robot, keyed by grantee
+func (s *RobotStorage) GetPermissions(robotID string) map[string][]string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	grants := make(map[string][]string)
+	for grantee, permissions := range s.grants[robotID] {
+		grants[grantee] = append([]string{}, permissions...)
+	}
+	return grants
+}
+
+// HasPermission checks whether a grantee holds a permission on a robot
+func (s *RobotStorage) HasPermission(robotID, grantee, permission string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, granted := range s.grants[robotID][grantee] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
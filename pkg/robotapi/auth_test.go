@@ -0,0 +1,112 @@
+package robotapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAPIKeys = "alice=alice-key,bob=bob-key"
+
+func doRequest(router *gin.Engine, method, path, apiKey, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestNewAPIKeyStore(t *testing.T) {
+	store, err := NewAPIKeyStore(testAPIKeys)
+	assert.NoError(t, err)
+	assert.True(t, store.Enabled())
+
+	name, ok := store.Lookup("bob-key")
+	assert.True(t, ok)
+	assert.Equal(t, "bob", name)
+
+	_, err = NewAPIKeyStore("alice")
+	assert.Error(t, err)
+
+	store, _ = NewAPIKeyStore("")
+	assert.False(t, store.Enabled())
+}
+
+func TestAuthenticationRequired(t *testing.T) {
+	router, _ := setupTestRouterWithKeys(testAPIKeys)
+
+	assert.Equal(t, http.StatusUnauthorized, doRequest(router, "GET", "/robot/robot1/status", "", "").Code)
+	assert.Equal(t, http.StatusUnauthorized, doRequest(router, "GET", "/robot/robot1/status", "wrong", "").Code)
+	assert.Equal(t, http.StatusOK, doRequest(router, "GET", "/robot/robot1/status", "alice-key", "").Code)
+}
+
+func TestPermissionDelegation(t *testing.T) {
+	router, storage := setupTestRouterWithKeys(testAPIKeys)
+
+	w := doRequest(router, "POST", "/robots", "alice-key", `{"id": "shared"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	robot, _ := storage.GetRobot("shared")
+	assert.Equal(t, "alice", robot.Owner)
+
+	move := `{"direction": "up"}`
+	assert.Equal(t, http.StatusForbidden, doRequest(router, "POST", "/robot/shared/move", "bob-key", move).Code)
+
+	// Only the owner may grant permissions
+	grant := `{"grantee": "bob", "permissions": ["move"]}`
+	assert.Equal(t, http.StatusForbidden, doRequest(router, "POST", "/robot/shared/permissions", "bob-key", grant).Code)
+	assert.Equal(t, http.StatusOK, doRequest(router, "POST", "/robot/shared/permissions", "alice-key", grant).Code)
+
+	// Bob can now move but still not attack
+	assert.Equal(t, http.StatusOK, doRequest(router, "POST", "/robot/shared/move", "bob-key", move).Code)
+	placeInRange(t, storage, "shared", "robot2")
+	assert.Equal(t, http.StatusForbidden, doRequest(router, "POST", "/robot/shared/attack/robot2", "bob-key", "").Code)
+	assert.Equal(t, http.StatusOK, doRequest(router, "POST", "/robot/shared/attack/robot2", "alice-key", "").Code)
+
+	// Revoking by granting an empty list
+	revoke := `{"grantee": "bob", "permissions": []}`
+	assert.Equal(t, http.StatusOK, doRequest(router, "POST", "/robot/shared/permissions", "alice-key", revoke).Code)
+	assert.Equal(t, http.StatusForbidden, doRequest(router, "POST", "/robot/shared/move", "bob-key", move).Code)
+	robot, _ = storage.GetRobot("shared")
+	assert.Equal(t, "Revoked all permissions of bob", robot.Actions[len(robot.Actions)-1].Details)
+
+	invalid := `{"grantee": "bob", "permissions": ["fly"]}`
+	assert.Equal(t, http.StatusBadRequest, doRequest(router, "POST", "/robot/shared/permissions", "alice-key", invalid).Code)
+
+	typo := `{"grantee": "bobb", "permissions": ["move"]}`
+	assert.Equal(t, http.StatusBadRequest, doRequest(router, "POST", "/robot/shared/permissions", "alice-key", typo).Code)
+}
+
+func TestActionsRecordTheirOrigin(t *testing.T) {
+	router, storage := setupTestRouterWithKeys(testAPIKeys)
+	assert.Equal(t, http.StatusCreated, doRequest(router, "POST", "/robots", "alice-key", `{"id": "shared"}`).Code)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/robot/shared/move", bytes.NewBufferString(`{"direction": "up"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "alice-key")
+	req.Header.Set("User-Agent", "grader/1.0")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	robot, _ := storage.GetRobot("shared")
+	created, moved := robot.Actions[0], robot.Actions[len(robot.Actions)-1]
+	assert.Equal(t, "alice", created.Actor)
+	assert.Equal(t, "move", moved.Type)
+	assert.Equal(t, "alice", moved.Actor)
+	assert.Equal(t, "192.0.2.1", moved.ClientIP)
+	assert.Equal(t, "grader/1.0", moved.UserAgent)
+	assert.Equal(t, w.Header().Get("X-Command-ID"), moved.CommandID)
+
+	// Actions of the simulation have no origin
+	storage.AddAction("shared", "test", "simulated")
+	robot, _ = storage.GetRobot("shared")
+	last := robot.Actions[len(robot.Actions)-1]
+	assert.Empty(t, last.Actor+last.ClientIP+last.UserAgent+last.CommandID)
+}
@@ -0,0 +1,301 @@
+package robotapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BatchTask is the type of the tasks batches run as
+const BatchTask = "batch"
+
+// maxBatchSteps limits the number of steps in one batch
+const maxBatchSteps = 100
+
+// Outcomes of a compensation
+const (
+	CompensationApplied = "compensated"
+	CompensationFailed  = "failed"
+)
+
+// oppositeDirections are the moves undoing each other
+var oppositeDirections = map[string]string{
+	"up": "down", "down": "up",
+	"left": "right", "right": "left",
+	"ascend": "descend", "descend": "ascend",
+}
+
+// BatchStep is one command of a batch: a move in a direction, or picking up
+// or putting down an item
+type BatchStep struct {
+	Action    string `json:"action" binding:"required"` // "move", "pickup" or "putdown"
+	Direction string `json:"direction,omitempty"`
+	Item      string `json:"item,omitempty"`
+}
+
+// BatchRequest is the payload of the batch endpoint
+type BatchRequest struct {
+	Steps []BatchStep `json:"steps" binding:"required,min=1,dive"`
+}
+
+// Compensation is an entry of a batch's compensation log: the step that
+// undid an applied one
+type Compensation struct {
+	Step   int       `json:"step"` // index of the undone step
+	Undo   BatchStep `json:"undo"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+}
+
+// BatchReport is the outcome of a batch. If a step failed, the steps before
+// it were compensated in reverse order.
+type BatchReport struct {
+	Status       string         `json:"status"`  // a task state
+	Applied      int            `json:"applied"` // steps that took effect and were kept
+	FailedStep   *int           `json:"failed_step,omitempty"`
+	Error        string         `json:"error,omitempty"`
+	Compensation []Compensation `json:"compensation,omitempty"`
+}
+
+// undo returns the step compensating the step
+func (s BatchStep) undo() BatchStep {
+	switch s.Action {
+	case "move":
+		return BatchStep{Action: "move", Direction: oppositeDirections[s.Direction]}
+	case "pickup":
+		return BatchStep{Action: "putdown", Item: s.Item}
+	default:
+		return BatchStep{Action: "pickup", Item: s.Item}
+	}
+}
+
+// validateStep checks a step before the batch starts
+func (h *RobotHandler) validateStep(c *gin.Context, step BatchStep) error {
+	switch step.Action {
+	case "move":
+		delta, ok := directions[step.Direction]
+		if !ok || (delta.Z != 0 && !h.multiFloor) {
+			return errors.New("Invalid direction")
+		}
+	case "pickup", "putdown":
+		if step.Item == "" {
+			return errors.New("item is required")
+		}
+		return h.checkID(c, ItemIDs, step.Item)
+	default:
+		return fmt.Errorf("unknown action %q", step.Action)
+	}
+	return nil
+}
+
+// runStep applies a step to the robot like the endpoint of its action does:
+// batches, programs and behavior modules all go through it. Compensating
+// steps cost no energy, do not count against quotas and also undo steps of
+// robots whose firmware is no longer supported. No step runs while the
+// emergency stop is engaged.
+func (h *RobotHandler) runStep(c *gin.Context, id string, step BatchStep, compensates *int) error {
+	if h.estop.Engaged() {
+		return errors.New("Emergency stop engaged")
+	}
+	robot, err := h.world(c).GetRobot(id)
+	if err != nil {
+		return err
+	}
+	if compensates == nil && !h.supportsFirmware(robot) {
+		return fmt.Errorf("Firmware %s is no longer supported", robot.Firmware)
+	}
+	extra := gin.H{"batch_step": step}
+	if c.GetBool("behavior") {
+		extra = gin.H{"behavior": true}
+	}
+	if compensates != nil {
+		extra = gin.H{"compensates": *compensates}
+	} else if limit, limited := h.quotas[step.Action]; limited {
+		if _, ok := h.world(c).ConsumeQuota(id, step.Action, limit, h.world(c).Clock().Now()); !ok {
+			return errors.New("Daily quota exceeded for action " + step.Action)
+		}
+	}
+	refund := func() {
+		if _, limited := h.quotas[step.Action]; limited && compensates == nil {
+			h.world(c).RefundQuota(id, step.Action)
+		}
+	}
+
+	switch step.Action {
+	case "move":
+		cost := h.weather.Current().MoveCost
+		if compensates != nil {
+			cost = 0
+		}
+		if name, err := h.hooks.each(func(hook interface{}) error {
+			if before, ok := hook.(BeforeMoveHook); ok {
+				return before.BeforeMove(c, robot.clone(), h.world(c).GetLayout().Step(robot.Position, directions[step.Direction]))
+			}
+			return nil
+		}); err != nil {
+			refund()
+			return fmt.Errorf("Rejected by %s: %v", name, err)
+		}
+		robot, err = h.moveRobot(c, id, step.Direction, cost, extra)
+		if err != nil {
+			refund()
+			return err
+		}
+		h.world(c).EnterHazard(id)
+		h.bus(c).PublishCommand(commandID(c), "robot_moved", id, gin.H{"position": robot.Position, "direction": step.Direction})
+		publishZoneEvents(h.bus(c), h.world(c).UpdateZones())
+		h.afterMove(c, robot)
+	case "pickup":
+		if name, err := h.hooks.each(func(hook interface{}) error {
+			if before, ok := hook.(BeforePickupHook); ok {
+				return before.BeforePickup(c, robot.clone(), step.Item)
+			}
+			return nil
+		}); err != nil {
+			refund()
+			return fmt.Errorf("Rejected by %s: %v", name, err)
+		}
+		robot, err = h.pickupItem(c, id, step.Item, extra)
+		if err != nil {
+			refund()
+			return err
+		}
+		h.bus(c).PublishCommand(commandID(c), "item_picked_up", id, gin.H{"item": step.Item})
+		h.afterPickup(c, robot, step.Item)
+	case "putdown":
+		if name, err := h.hooks.each(func(hook interface{}) error {
+			if before, ok := hook.(BeforePutdownHook); ok {
+				return before.BeforePutdown(c, robot.clone(), step.Item)
+			}
+			return nil
+		}); err != nil {
+			refund()
+			return fmt.Errorf("Rejected by %s: %v", name, err)
+		}
+		robot, err = h.putdownItem(c, id, step.Item, extra)
+		if err != nil {
+			refund()
+			return err
+		}
+		h.bus(c).PublishCommand(commandID(c), "item_put_down", id, gin.H{"item": step.Item, "position": robot.Position})
+		h.afterPutdown(c, robot, step.Item)
+	}
+	return nil
+}
+
+// runBatch applies the steps in order until one fails or ctx is cancelled,
+// then undoes the applied ones in reverse order
+func (h *RobotHandler) runBatch(ctx context.Context, c *gin.Context, id string, steps []BatchStep) BatchReport {
+	report := BatchReport{Status: TaskSucceeded}
+	for i, step := range steps {
+		err := ctx.Err()
+		var release func()
+		if err == nil {
+			release, err = h.acquireLane(ctx, c, id)
+		}
+		if err == nil {
+			err = h.runStep(c, id, step, nil)
+			release()
+		}
+		if err == nil {
+			report.Applied++
+			continue
+		}
+
+		report.Status = TaskFailed
+		if ctx.Err() != nil {
+			report.Status = TaskCancelled
+		}
+		failed := i
+		report.FailedStep = &failed
+		report.Error = err.Error()
+		break
+	}
+	if report.FailedStep == nil {
+		return report
+	}
+
+	// The log is built newest first so that every step is undone on the
+	// state it left behind, e.g. an item is put down where it was picked up
+	// Compensating goes on after the task was cancelled
+	ctx = context.WithoutCancel(ctx)
+	report.Compensation = []Compensation{}
+	for i := report.Applied - 1; i >= 0; i-- {
+		step := i
+		compensation := Compensation{Step: step, Undo: steps[step].undo(), Status: CompensationApplied}
+		release, _ := h.acquireLane(ctx, c, id)
+		err := h.runStep(c, id, compensation.Undo, &step)
+		release()
+		if err != nil {
+			compensation.Status = CompensationFailed
+			compensation.Error = err.Error()
+		} else {
+			report.Applied--
+		}
+		report.Compensation = append(report.Compensation, compensation)
+	}
+	return report
+}
+
+// RunBatch starts applying a sequence of steps to a robot as a task and
+// answers with 202 and the task right away, so the batch can be cancelled
+// while it runs. If a step fails, or the task is cancelled, the steps
+// applied so far are compensated in reverse order; the task's result
+// reports which were undone. A robot runs one batch at a time.
+func (h *RobotHandler) RunBatch(c *gin.Context) {
+	id := c.Param("id")
+	var request BatchRequest
+	if err := bindJSON(c, &request); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if len(request.Steps) > maxBatchSteps {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d steps per batch", maxBatchSteps)})
+		return
+	}
+	for i, step := range request.Steps {
+		if err := h.validateStep(c, step); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid step %d: %v", i, err)})
+			return
+		}
+	}
+	if _, err := h.world(c).GetRobot(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	if h.tasks.Active(currentTenant(c), BatchTask, id) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A batch is already running on this robot"})
+		return
+	}
+
+	task := h.tasks.CreateFor(currentTenant(c), BatchTask, requestPriority(c), id)
+	go h.runBatchTask(taskContext(c), task.ID, id, request.Steps)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"task":  task,
+		"links": robotTaskLinks(c, id, task.ID),
+	})
+}
+
+// runBatchTask runs a batch and records its report in the task
+func (h *RobotHandler) runBatchTask(c *gin.Context, taskID, id string, steps []BatchStep) {
+	h.tasks.Update(taskID, func(task *Task) {
+		task.Status = TaskRunning
+	})
+	report := h.runBatch(h.tasks.Context(taskID), c, id, steps)
+	h.tasks.Update(taskID, func(task *Task) {
+		task.Status = report.Status
+		task.Progress = 1
+		task.Error = report.Error
+		task.Result = report
+	})
+	if len(report.Compensation) > 0 {
+		log.Printf("Batch %s on robot %s failed at step %d and compensated %d steps", taskID, id, *report.FailedStep, len(report.Compensation))
+		h.bus(c).PublishCommand(commandID(c), "batch_compensated", id, gin.H{"task": taskID, "report": report})
+	}
+}
@@ -0,0 +1,174 @@
+package robotapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupBatchRouter() (*gin.Engine, *RobotHandler, *RobotStorage) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	router := gin.New()
+	router.POST("/robot/:id/batch", handler.RunBatch)
+	router.GET("/robot/:id/tasks/:taskId", handler.GetRobotTask)
+	router.DELETE("/robot/:id/tasks/:taskId", handler.CancelRobotTask)
+	return router, handler, storage
+}
+
+// startBatch starts a batch on robot1 and returns its task ID
+func startBatch(t *testing.T, router *gin.Engine, body string) string {
+	t.Helper()
+	w := send(router, "POST", "/robot/robot1/batch", body)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response struct {
+		Task  Task   `json:"task"`
+		Links []Link `json:"links"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, BatchTask, response.Task.Type)
+	assert.Equal(t, "http:///robot/robot1/tasks/"+response.Task.ID, response.Links[0].Href)
+	return response.Task.ID
+}
+
+// gatedMoves holds every move until it is let through
+type gatedMoves struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (g gatedMoves) BeforeMove(c *gin.Context, robot *Robot, target Position) error {
+	select {
+	case g.entered <- struct{}{}:
+	default:
+	}
+	<-g.release
+	return nil
+}
+
+func TestBatch(t *testing.T) {
+	router, handler, storage := setupBatchRouter()
+
+	taskID := startBatch(t, router, `{"steps": [{"action": "move", "direction": "up"}, {"action": "move", "direction": "right"}, {"action": "pickup", "item": "item1"}]}`)
+	var report BatchReport
+	task := awaitTask(t, router, "robot1", taskID, &report)
+	assert.Equal(t, BatchReport{Status: TaskSucceeded, Applied: 3}, report)
+	assert.Equal(t, TaskSucceeded, task.Status)
+	assert.Equal(t, []string{"robot1"}, task.Robots)
+
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 1, Y: 1}, robot.Position)
+	assert.Equal(t, []string{"item1"}, robot.Inventory)
+	assert.False(t, handler.tasks.Active("", BatchTask, "robot1"))
+
+	for _, body := range []string{
+		`{"steps": []}`,
+		`{"steps": [{"action": "jump"}]}`,
+		`{"steps": [{"action": "move", "direction": "north"}]}`,
+		`{"steps": [{"action": "pickup"}]}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/robot/robot1/batch", body).Code, body)
+	}
+	assert.Equal(t, http.StatusNotFound, send(router, "POST", "/robot/ghost/batch", `{"steps": [{"action": "move", "direction": "up"}]}`).Code)
+}
+
+func TestBatchCompensation(t *testing.T) {
+	router, handler, storage := setupBatchRouter()
+	require.NoError(t, storage.PlaceObstacle(Position{X: 1, Y: 2}))
+
+	// The last move runs into the obstacle, so everything before is undone
+	taskID := startBatch(t, router, `{"steps": [
+		{"action": "move", "direction": "up"},
+		{"action": "move", "direction": "right"},
+		{"action": "pickup", "item": "item1"},
+		{"action": "move", "direction": "up"}
+	]}`)
+	var report BatchReport
+	task := awaitTask(t, router, "robot1", taskID, &report)
+	assert.Equal(t, TaskFailed, task.Status)
+	assert.Equal(t, TaskFailed, report.Status)
+	require.NotNil(t, report.FailedStep)
+	assert.Equal(t, 3, *report.FailedStep)
+	assert.Equal(t, 0, report.Applied)
+	assert.Equal(t, []Compensation{
+		{Step: 2, Undo: BatchStep{Action: "putdown", Item: "item1"}, Status: CompensationApplied},
+		{Step: 1, Undo: BatchStep{Action: "move", Direction: "left"}, Status: CompensationApplied},
+		{Step: 0, Undo: BatchStep{Action: "move", Direction: "down"}, Status: CompensationApplied},
+	}, report.Compensation)
+
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 0, Y: 0}, robot.Position)
+	assert.Empty(t, robot.Inventory)
+	item, _ := storage.GetItem("item1")
+	assert.Equal(t, Position{X: 1, Y: 1}, item.Position)
+	compensating := robot.Actions[len(robot.Actions)-1]
+	assert.Equal(t, 0, compensating.Data.(gin.H)["compensates"])
+
+	stored, _ := handler.tasks.Get(taskID)
+	assert.Equal(t, report, stored.Result)
+}
+
+func TestBatchCancelledWhileRunning(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, err := New(DefaultConfig())
+	require.NoError(t, err)
+	router := server.Router()
+	gate := gatedMoves{entered: make(chan struct{}, 1), release: make(chan struct{})}
+	require.NoError(t, server.handler.hooks.Register("gate", 0, gate))
+
+	taskID := startBatch(t, router, `{"steps": [
+		{"action": "move", "direction": "up"},
+		{"action": "move", "direction": "up"},
+		{"action": "move", "direction": "up"}
+	]}`)
+	<-gate.entered
+	gate.release <- struct{}{}
+	<-gate.entered // the second move waits
+
+	// The cancellation is not queued behind the batch's commands
+	cancelled := make(chan *httptest.ResponseRecorder)
+	go func() {
+		cancelled <- send(router, "DELETE", "/robot/robot1/tasks/"+taskID, "")
+	}()
+	require.Eventually(t, func() bool {
+		return server.handler.tasks.Context(taskID).Err() != nil
+	}, 5*time.Second, time.Millisecond)
+	close(gate.release)
+
+	w := <-cancelled
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var task Task
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+	assert.Equal(t, TaskCancelled, task.Status)
+	report := task.Result.(map[string]interface{})
+	assert.Equal(t, 2.0, report["failed_step"], "the third move never ran")
+	assert.Equal(t, 0.0, report["applied"])
+	assert.Len(t, report["compensation"], 2)
+
+	robot, _ := server.storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 0, Y: 0}, robot.Position)
+}
+
+func TestBatchCancelled(t *testing.T) {
+	_, handler, storage := setupBatchRouter()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/robot/robot1/batch", nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report := handler.runBatch(ctx, c, "robot1", []BatchStep{{Action: "move", Direction: "up"}})
+	assert.Equal(t, TaskCancelled, report.Status)
+	assert.Equal(t, 0, *report.FailedStep)
+	assert.Empty(t, report.Compensation)
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 0, Y: 0}, robot.Position)
+}
@@ -0,0 +1,196 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// minCapacity is the share of the original capacity a battery never drops below
+const minCapacity = 0.2
+
+// DegradationCurve defines how the capacity of a battery shrinks with use.
+// A cycle is MaxEnergy energy charged into the battery, in any number of
+// partial charges.
+type DegradationCurve interface {
+	Name() string
+	// Capacity returns the share of the original capacity that is left
+	// after the given number of cycles
+	Capacity(cycles float64) float64
+}
+
+// NewDegradationCurve parses a curve given as "name" or "name:parameter"
+func NewDegradationCurve(spec string) (DegradationCurve, error) {
+	name, parameter, hasParameter := strings.Cut(spec, ":")
+	value, err := strconv.ParseFloat(parameter, 64)
+	if hasParameter && err != nil {
+		return nil, fmt.Errorf("invalid parameter %q for battery curve %s", parameter, name)
+	}
+
+	switch name {
+	case "", "none":
+		return NoDegradation{}, nil
+	case "linear":
+		if !hasParameter {
+			value = 0.005
+		}
+		if value < 0 || value > 1 {
+			return nil, errors.New("linear battery loss per cycle must be between 0 and 1")
+		}
+		return LinearDegradation{LossPerCycle: value}, nil
+	case "exponential":
+		if !hasParameter {
+			value = 0.99
+		}
+		if value <= 0 || value > 1 {
+			return nil, errors.New("exponential battery retention per cycle must be between 0 and 1")
+		}
+		return ExponentialDegradation{Retention: value}, nil
+	default:
+		return nil, fmt.Errorf("unknown battery curve %q", name)
+	}
+}
+
+// NoDegradation keeps batteries as good as new
+type NoDegradation struct{}
+
+// Name returns the config name of the curve
+func (NoDegradation) Name() string {
+	return "none"
+}
+
+// Capacity always returns the full capacity
+func (NoDegradation) Capacity(cycles float64) float64 {
+	return 1
+}
+
+// LinearDegradation loses the same share of the original capacity every cycle
+type LinearDegradation struct {
+	LossPerCycle float64
+}
+
+// Name returns the config name of the curve
+func (LinearDegradation) Name() string {
+	return "linear"
+}
+
+// Capacity returns the capacity left after the given cycles
+func (d LinearDegradation) Capacity(cycles float64) float64 {
+	return math.Max(1-d.LossPerCycle*cycles, minCapacity)
+}
+
+// ExponentialDegradation keeps a fixed share of the remaining capacity every
+// cycle, so batteries wear quickly at first and slower later
+type ExponentialDegradation struct {
+	Retention float64
+}
+
+// Name returns the config name of the curve
+func (ExponentialDegradation) Name() string {
+	return "exponential"
+}
+
+// Capacity returns the capacity left after the given cycles
+func (d ExponentialDegradation) Capacity(cycles float64) float64 {
+	return math.Max(math.Pow(d.Retention, cycles), minCapacity)
+}
+
+// maxEnergy returns the highest energy the robot's battery can hold
+func (r *Robot) maxEnergy() int {
+	if r.BatteryCapacity == 0 {
+		return MaxEnergy
+	}
+	return r.BatteryCapacity
+}
+
+// SetBatteryCurve replaces the curve batteries degrade by
+func (s *RobotStorage) SetBatteryCurve(curve DegradationCurve) {
+	s.mutex.Lock()
+	defer s.unlock()
+	s.battery = curve
+}
+
+// charged counts energy charged into the robot's battery towards its cycles,
+// shrinks the capacity accordingly and caps the energy at the new capacity;
+// callers must hold the lock
+func (s *RobotStorage) charged(robot *Robot, energy int) {
+	robot.BatteryCycles += float64(energy) / MaxEnergy
+	robot.BatteryCapacity = int(math.Round(MaxEnergy * s.battery.Capacity(robot.BatteryCycles)))
+	if robot.Energy >= robot.maxEnergy() {
+		robot.Energy, robot.EnergyFraction = robot.maxEnergy(), 0
+	}
+}
+
+// ReplaceBattery swaps the robot's worn battery for a battery item from its
+// inventory, which is used up. Without an item ID the first battery in the
+// inventory is taken.
+func (h *RobotHandler) ReplaceBattery(c *gin.Context) {
+	id := c.Param("id")
+	robot, err := h.world(c).GetRobot(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	var request struct {
+		ItemID string `json:"item_id"`
+	}
+	if err := bindJSON(c, &request); err != nil && !errors.Is(err, io.EOF) {
+		invalidRequest(c, err)
+		return
+	}
+
+	itemID := request.ItemID
+	if itemID == "" {
+		for _, carried := range robot.Inventory {
+			if item, err := h.world(c).GetItem(carried); err == nil && item.Type == "battery" {
+				itemID = carried
+				break
+			}
+		}
+	}
+	if itemID == "" || !containsString(robot.Inventory, itemID) {
+		c.JSON(http.StatusConflict, gin.H{"error": "No battery in the inventory"})
+		return
+	}
+	if item, err := h.world(c).GetItem(itemID); err != nil || item.Type != "battery" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Item is not a battery"})
+		return
+	}
+
+	robot, err = h.world(c).ConsumeItem(id, itemID, func(robot *Robot) error {
+		if err := robot.CanPerform("maintenance"); err != nil {
+			return errActionNotAllowed(err)
+		}
+		index := slices.Index(robot.Inventory, itemID)
+		if index < 0 {
+			return errors.New("No battery in the inventory")
+		}
+
+		robot.Inventory = slices.Delete(robot.Inventory, index, index+1)
+		robot.BatteryCycles = 0
+		robot.BatteryCapacity = 0
+		appendCommandAction(robot, requestOrigin(c), "maintenance", fmt.Sprintf("Replaced the battery with %s", itemID),
+			gin.H{"item_id": itemID})
+		return nil
+	})
+	if err != nil {
+		updateFailed(c, err)
+		return
+	}
+	h.bus(c).PublishCommand(commandID(c), "battery_replaced", id, gin.H{"item": itemID})
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Battery replaced",
+		"energy":     robot.Energy,
+		"max_energy": robot.maxEnergy(),
+		"inventory":  robot.Inventory,
+	})
+}
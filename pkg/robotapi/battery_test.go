@@ -0,0 +1,96 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDegradationCurves(t *testing.T) {
+	curve, err := NewDegradationCurve("")
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, curve.Capacity(1000))
+
+	curve, err = NewDegradationCurve("linear:0.01")
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.9, curve.Capacity(10), 1e-9)
+	assert.Equal(t, minCapacity, curve.Capacity(1000), "batteries never wear below the minimum")
+
+	curve, err = NewDegradationCurve("exponential")
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.99, curve.Capacity(1), 1e-9)
+
+	for _, spec := range []string{"lithium", "linear:x", "linear:2", "exponential:0"} {
+		_, err := NewDegradationCurve(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestBatteryDegradation(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	storage.SetBatteryCurve(LinearDegradation{LossPerCycle: 0.1})
+
+	// robot2 is drained and recharged twice
+	for cycle := 0; cycle < 2; cycle++ {
+		storage.UpdateRobot("robot2", func(robot *Robot) error {
+			robot.Energy = 0
+			return nil
+		})
+		storage.Recharge(func(robot *Robot) int { return MaxEnergy })
+	}
+	robot, _ := storage.GetRobot("robot2")
+	assert.InDelta(t, 1.9, robot.BatteryCycles, 1e-9, "the second charge only fills the worn battery")
+	assert.Equal(t, 81, robot.maxEnergy())
+	assert.Equal(t, 81, robot.Energy)
+
+	// Energy set through updates counts as charged as well and is capped
+	robot, _ = storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Energy = 0
+		return nil
+	})
+	assert.Zero(t, robot.BatteryCycles)
+	robot, _ = storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Energy = 100
+		return nil
+	})
+	assert.Equal(t, 1.0, robot.BatteryCycles)
+	assert.Equal(t, 90, robot.Energy)
+}
+
+func TestReplaceBattery(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	handler := NewRobotHandler(storage)
+	router.POST("/robot/:id/pickup/:itemId", handler.PickupItem)
+	router.POST("/robot/:id/replace-battery", handler.ReplaceBattery)
+	router.GET("/robot/:id/status", handler.GetStatus)
+
+	storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.BatteryCycles = 30
+		robot.BatteryCapacity = 70
+		robot.Energy = 70
+		return nil
+	})
+
+	assert.Equal(t, http.StatusNotFound, send(router, "POST", "/robot/nobody/replace-battery", "").Code)
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot1/replace-battery", "").Code)
+
+	// A tool is no battery
+	send(router, "POST", "/robot/robot1/pickup/item2", "")
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot1/replace-battery", `{"item_id": "item2"}`).Code)
+
+	send(router, "POST", "/robot/robot1/pickup/item1", "")
+	w := send(router, "POST", "/robot/robot1/replace-battery", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var status map[string]interface{}
+	json.Unmarshal(send(router, "GET", "/robot/robot1/status", "").Body.Bytes(), &status)
+	assert.Equal(t, 100.0, status["max_energy"])
+	assert.Equal(t, 0.0, status["battery_cycles"])
+	assert.Equal(t, []interface{}{"item2"}, status["inventory"])
+
+	_, err := storage.GetItem("item1")
+	assert.Error(t, err, "the battery is used up")
+}
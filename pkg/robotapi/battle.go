@@ -0,0 +1,206 @@
+package robotapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits of the battle simulator, so a single request cannot run for long
+const (
+	maxSimulatedBattles = 10000
+	maxSimulatedRounds  = 1000
+)
+
+// BattleContender is one side of a simulated battle: either an existing
+// robot referenced by ID or an inline robot spec
+type BattleContender struct {
+	ID     string `json:"id"`
+	Energy *int   `json:"energy"`
+	Armor  int    `json:"armor"`
+}
+
+// BattleRequest configures the battle simulator
+type BattleRequest struct {
+	A       BattleContender `json:"a"`
+	B       BattleContender `json:"b"`
+	Battles int             `json:"battles"` // number of simulated battles, default 1000
+	Rounds  int             `json:"rounds"`  // rounds per battle, default 50
+	Seed    int64           `json:"seed"`    // seed for the dice, default 1
+}
+
+// DamageStats summarizes the damage a robot dealt per battle
+type DamageStats struct {
+	Min    int     `json:"min"`
+	Mean   float64 `json:"mean"`
+	Median int     `json:"median"`
+	P90    int     `json:"p90"`
+	Max    int     `json:"max"`
+}
+
+// ContenderResult is the outcome of all simulated battles for one side
+type ContenderResult struct {
+	ID             string      `json:"id"`
+	WinProbability float64     `json:"win_probability"`
+	DamageDealt    DamageStats `json:"damage_dealt"`
+}
+
+// BattleResult is the response of the battle simulator
+type BattleResult struct {
+	CombatRules     string          `json:"combat_rules"`
+	Battles         int             `json:"battles"`
+	Rounds          int             `json:"rounds"`
+	Seed            int64           `json:"seed"`
+	A               ContenderResult `json:"a"`
+	B               ContenderResult `json:"b"`
+	DrawProbability float64         `json:"draw_probability"`
+}
+
+// contender resolves one side of the battle into a robot template
+func (h *RobotHandler) contender(c *gin.Context, spec BattleContender, defaultID string) (*Robot, error) {
+	if spec.Energy == nil {
+		if spec.ID == "" {
+			return nil, fmt.Errorf("robot %s needs an id or an energy value", defaultID)
+		}
+		robot, err := h.world(c).GetRobot(spec.ID)
+		if err != nil {
+			return nil, fmt.Errorf("robot %s not found", spec.ID)
+		}
+		return &Robot{ID: robot.ID, Class: robot.Class, Energy: robot.Energy, Armor: robot.Armor}, nil
+	}
+
+	if *spec.Energy <= 0 || *spec.Energy > MaxEnergy {
+		return nil, fmt.Errorf("energy of robot %s must be between 1 and %d", defaultID, MaxEnergy)
+	}
+	id := spec.ID
+	if id == "" {
+		id = defaultID
+	}
+	return &Robot{ID: id, Energy: *spec.Energy, Armor: spec.Armor}, nil
+}
+
+// simulateBattle fights one battle between copies of the two robots. Each
+// round both robots attack once, then their status effects tick. The battle
+// ends when a robot runs out of energy or after the given number of rounds;
+// the robot with more energy left wins. It returns the winner (0 for a
+// draw, 1 for a, 2 for b) and the damage each side dealt. If observe is
+// given, it sees every attack after it was applied.
+func simulateBattle(resolver CombatResolver, rounding EnergyRounding, weather *dice, accuracy int, a, b *Robot, rounds int, observe func(round int, attacker, target *Robot, result CombatResult)) (int, int, int) {
+	robots := [2]*Robot{a.clone(), b.clone()}
+	var dealt [2]int
+
+	for round := 0; round < rounds && robots[0].Energy > 0 && robots[1].Energy > 0; round++ {
+		for i, attacker := range robots {
+			target := robots[1-i]
+			if attacker.Energy == 0 || target.Energy == 0 || attacker.CanPerform("attack") != nil {
+				continue
+			}
+
+			result := resolver.Resolve(attacker, target)
+			if result.Hit && weather.roll(1, 100) > accuracy {
+				result = result.missed()
+			}
+			rounding.drain(attacker, result.cost())
+			before := target.Energy
+			dealt[i] += min(rounding.drain(target, result.damage()), before)
+			if result.Effect != nil {
+				applyEffect(target, *result.Effect)
+			}
+			if observe != nil {
+				observe(round+1, attacker, target, result)
+			}
+		}
+		for _, robot := range robots {
+			tickEffects(robot)
+		}
+	}
+
+	switch {
+	case robots[0].Energy > robots[1].Energy:
+		return 1, dealt[0], dealt[1]
+	case robots[1].Energy > robots[0].Energy:
+		return 2, dealt[0], dealt[1]
+	default:
+		return 0, dealt[0], dealt[1]
+	}
+}
+
+// damageStats summarizes per-battle damage values
+func damageStats(values []int) DamageStats {
+	sort.Ints(values)
+	total := 0
+	for _, value := range values {
+		total += value
+	}
+	return DamageStats{
+		Min:    values[0],
+		Mean:   float64(total) / float64(len(values)),
+		Median: values[len(values)/2],
+		P90:    values[len(values)*9/10],
+		Max:    values[len(values)-1],
+	}
+}
+
+// SimulateBattle runs many battles between two robots with the configured
+// combat rules and returns win probabilities and damage distributions. The
+// dice are seeded from the request, so the same request gives the same
+// result. Real robots are only read, never changed.
+func (h *RobotHandler) SimulateBattle(c *gin.Context) {
+	request := BattleRequest{Battles: 1000, Rounds: 50, Seed: 1}
+	if err := bindJSON(c, &request); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if request.Battles < 1 || request.Battles > maxSimulatedBattles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("battles must be between 1 and %d", maxSimulatedBattles)})
+		return
+	}
+	if request.Rounds < 1 || request.Rounds > maxSimulatedRounds {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("rounds must be between 1 and %d", maxSimulatedRounds)})
+		return
+	}
+
+	a, err := h.contender(c, request.A, "a")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	b, err := h.contender(c, request.B, "b")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	resolver, err := NewSeededCombatResolver(h.combat.Name(), request.Seed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	weather := newDice(request.Seed)
+	accuracy := h.weather.Current().Accuracy
+
+	var wins [3]int
+	dealtA := make([]int, request.Battles)
+	dealtB := make([]int, request.Battles)
+	for i := 0; i < request.Battles; i++ {
+		if c.Request.Context().Err() != nil {
+			return
+		}
+		winner, damageA, damageB := simulateBattle(resolver, h.rounding, weather, accuracy, a, b, request.Rounds, nil)
+		wins[winner]++
+		dealtA[i], dealtB[i] = damageA, damageB
+	}
+
+	battles := float64(request.Battles)
+	c.JSON(http.StatusOK, BattleResult{
+		CombatRules:     resolver.Name(),
+		Battles:         request.Battles,
+		Rounds:          request.Rounds,
+		Seed:            request.Seed,
+		A:               ContenderResult{ID: a.ID, WinProbability: float64(wins[1]) / battles, DamageDealt: damageStats(dealtA)},
+		B:               ContenderResult{ID: b.ID, WinProbability: float64(wins[2]) / battles, DamageDealt: damageStats(dealtB)},
+		DrawProbability: float64(wins[0]) / battles,
+	})
+}
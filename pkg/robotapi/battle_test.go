@@ -0,0 +1,79 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func simulate(t *testing.T, resolver CombatResolver, body string) (int, BattleResult) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	return simulateWith(storage, resolver, body)
+}
+
+func simulateWith(storage *RobotStorage, resolver CombatResolver, body string) (int, BattleResult) {
+	gin.SetMode(gin.TestMode)
+	handler := NewRobotHandler(storage)
+	handler.SetCombatResolver(resolver)
+	router := gin.New()
+	router.POST("/simulate/battle", handler.SimulateBattle)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/simulate/battle", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	var result BattleResult
+	json.Unmarshal(w.Body.Bytes(), &result)
+	return w.Code, result
+}
+
+func TestSimulateBattle(t *testing.T) {
+	body := `{"a": {"id": "robot1"}, "b": {"energy": 40, "armor": 15}, "battles": 200, "seed": 7}`
+	code, result := simulate(t, NewDiceCombat(1), body)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "dice", result.CombatRules)
+	assert.Equal(t, "robot1", result.A.ID)
+	assert.Equal(t, "b", result.B.ID)
+	assert.InDelta(t, 1, result.A.WinProbability+result.B.WinProbability+result.DrawProbability, 1e-9)
+	assert.Greater(t, result.A.WinProbability, result.B.WinProbability, "full energy beats 40 energy")
+	assert.LessOrEqual(t, result.A.DamageDealt.Max, 40)
+	assert.LessOrEqual(t, result.A.DamageDealt.Min, result.A.DamageDealt.Median)
+
+	// The same seed gives the same result, whatever the handler's own dice did
+	_, again := simulate(t, NewDiceCombat(99), body)
+	assert.Equal(t, result, again)
+}
+
+func TestSimulateBattleDoesNotChangeRobots(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	version := storage.Version()
+
+	code, result := simulateWith(storage, PercentageCombat{}, `{"a": {"id": "robot1"}, "b": {"id": "robot2"}, "rounds": 3}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 1.0, max(result.A.WinProbability, result.B.WinProbability, result.DrawProbability),
+		"without dice every battle ends the same")
+	assert.Equal(t, version, storage.Version())
+	robot, _ := storage.GetRobot("robot2")
+	assert.Equal(t, 100, robot.Energy)
+}
+
+func TestSimulateBattleValidation(t *testing.T) {
+	for _, body := range []string{
+		`{"a": {"id": "ghost"}, "b": {"id": "robot2"}}`,
+		`{"a": {}, "b": {"id": "robot2"}}`,
+		`{"a": {"energy": 500}, "b": {"id": "robot2"}}`,
+		`{"a": {"id": "robot1"}, "b": {"id": "robot2"}, "battles": 100000}`,
+		`{"a": {"id": "robot1"}, "b": {"id": "robot2"}, "rounds": 0}`,
+	} {
+		code, _ := simulate(t, PercentageCombat{}, body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+	}
+}
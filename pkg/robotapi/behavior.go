@@ -0,0 +1,439 @@
+package robotapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+)
+
+// maxBehaviorSize limits the size of uploaded behavior modules
+const maxBehaviorSize = 4 << 20
+
+// Behavior states
+const (
+	BehaviorActive = "active" // the module decides on every tick
+	BehaviorFailed = "failed" // the module trapped, ran too long or answered nonsense
+)
+
+// BehaviorLimits sandbox the behavior modules. A module can neither grow its
+// memory beyond MemoryPages nor decide for longer than Timeout; modules
+// exceeding the CPU time are stopped for good.
+type BehaviorLimits struct {
+	MemoryPages uint32        // pages of 64 KiB
+	Timeout     time.Duration // per decision
+}
+
+// DefaultBehaviorLimits allow 1 MiB of memory and 10 ms per decision
+func DefaultBehaviorLimits() BehaviorLimits {
+	return BehaviorLimits{MemoryPages: 16, Timeout: 10 * time.Millisecond}
+}
+
+// BehaviorState is what a behavior module's decide function is given,
+// encoded as JSON
+type BehaviorState struct {
+	Tick      int64    `json:"tick"`
+	RobotID   string   `json:"robot_id"`
+	Position  Position `json:"position"`
+	Direction string   `json:"direction"`
+	Energy    int      `json:"energy"`
+	Inventory []string `json:"inventory"`
+}
+
+// Behavior is a robot's behavior module and how its decisions went
+type Behavior struct {
+	RobotID    string     `json:"robot_id"`
+	Size       int        `json:"size"`
+	Checksum   string     `json:"checksum"` // "sha256:<hex>"
+	Status     string     `json:"status"`
+	Error      string     `json:"error,omitempty"` // why the module was stopped
+	Decisions  int        `json:"decisions"`
+	LastAction *BatchStep `json:"last_action,omitempty"`
+	Rejected   int        `json:"rejected"` // actions the world did not allow
+	LastReject string     `json:"last_reject,omitempty"`
+	UploadedAt time.Time  `json:"uploaded_at"`
+}
+
+// behaviorModule is an instantiated behavior module. Modules keep their
+// memory between decisions.
+type behaviorModule struct {
+	behavior Behavior
+	tenant   string
+	module   api.Module
+	alloc    api.Function
+	decide   api.Function
+	mutex    sync.Mutex // serializes decisions
+}
+
+// BehaviorEngine runs the WebAssembly behavior modules of robots in a
+// wazero runtime. Modules cannot import anything, so all they can do is
+// compute; they export
+//
+//	memory                         the memory the state and the action are passed in
+//	alloc(size i32) -> i32         returns the address of size free bytes
+//	decide(ptr i32, len i32) -> i64
+//
+// decide gets the BehaviorState as JSON and returns the address of the
+// action in the upper and its length in the lower 32 bits. The action is a
+// batch step like {"action": "move", "direction": "up"}; a length of 0 or
+// {"action": "wait"} leaves the robot alone for the tick.
+type BehaviorEngine struct {
+	runtime wazero.Runtime
+	limits  BehaviorLimits
+	modules map[string]*behaviorModule // by tenant and robot ID
+	mutex   sync.Mutex
+}
+
+// NewBehaviorEngine creates an engine whose modules run within the limits
+func NewBehaviorEngine(limits BehaviorLimits) *BehaviorEngine {
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(limits.MemoryPages).
+		WithCloseOnContextDone(true)
+	return &BehaviorEngine{
+		runtime: wazero.NewRuntimeWithConfig(context.Background(), config),
+		limits:  limits,
+		modules: make(map[string]*behaviorModule),
+	}
+}
+
+// Load compiles and instantiates a robot's behavior module, replacing the
+// one it had. Compiling stops when ctx is done.
+func (e *BehaviorEngine) Load(ctx context.Context, tenant, robotID string, binary []byte, now time.Time) (Behavior, error) {
+	compiled, err := e.runtime.CompileModule(ctx, binary)
+	if err != nil {
+		return Behavior{}, err
+	}
+	// The instance keeps what it needs of the compiled module
+	defer compiled.Close(ctx)
+	if imports := compiled.ImportedFunctions(); len(imports) > 0 {
+		module, name, _ := imports[0].Import()
+		return Behavior{}, fmt.Errorf("modules cannot import functions, but %s.%s is imported", module, name)
+	}
+	start, cancel := context.WithTimeout(ctx, e.limits.Timeout)
+	defer cancel()
+	instance, err := e.runtime.InstantiateModule(start, compiled,
+		wazero.NewModuleConfig().WithName("").WithStartFunctions("_initialize"))
+	if err != nil {
+		return Behavior{}, err
+	}
+	alloc, decide := instance.ExportedFunction("alloc"), instance.ExportedFunction("decide")
+	switch {
+	case instance.Memory() == nil:
+		err = errors.New("the module does not export its memory")
+	case alloc == nil || !signature(alloc, []api.ValueType{api.ValueTypeI32}, []api.ValueType{api.ValueTypeI32}):
+		err = errors.New("the module does not export alloc(i32) -> i32")
+	case decide == nil || !signature(decide, []api.ValueType{api.ValueTypeI32, api.ValueTypeI32}, []api.ValueType{api.ValueTypeI64}):
+		err = errors.New("the module does not export decide(i32, i32) -> i64")
+	}
+	if err != nil {
+		instance.Close(ctx)
+		return Behavior{}, err
+	}
+
+	checksum := sha256.Sum256(binary)
+	loaded := &behaviorModule{
+		behavior: Behavior{
+			RobotID:    robotID,
+			Size:       len(binary),
+			Checksum:   "sha256:" + hex.EncodeToString(checksum[:]),
+			Status:     BehaviorActive,
+			UploadedAt: now,
+		},
+		tenant: tenant,
+		module: instance,
+		alloc:  alloc,
+		decide: decide,
+	}
+
+	e.mutex.Lock()
+	previous := e.modules[programKey(tenant, robotID)]
+	e.modules[programKey(tenant, robotID)] = loaded
+	e.mutex.Unlock()
+	if previous != nil {
+		previous.close()
+	}
+	return loaded.behavior, nil
+}
+
+// signature reports whether a function has the parameter and result types
+func signature(function api.Function, params, results []api.ValueType) bool {
+	definition := function.Definition()
+	return string(definition.ParamTypes()) == string(params) && string(definition.ResultTypes()) == string(results)
+}
+
+// Get returns a robot's behavior
+func (e *BehaviorEngine) Get(tenant, robotID string) (Behavior, bool) {
+	e.mutex.Lock()
+	loaded, exists := e.modules[programKey(tenant, robotID)]
+	e.mutex.Unlock()
+	if !exists {
+		return Behavior{}, false
+	}
+	loaded.mutex.Lock()
+	defer loaded.mutex.Unlock()
+	return loaded.behavior, true
+}
+
+// Delete removes a robot's behavior and frees its module
+func (e *BehaviorEngine) Delete(tenant, robotID string) bool {
+	e.mutex.Lock()
+	loaded, exists := e.modules[programKey(tenant, robotID)]
+	delete(e.modules, programKey(tenant, robotID))
+	e.mutex.Unlock()
+	if exists {
+		loaded.close()
+	}
+	return exists
+}
+
+// drop removes a module that is still the robot's current one and frees it,
+// e.g. once the robot is gone. Callers must not hold the module's lock.
+func (e *BehaviorEngine) drop(module *behaviorModule) {
+	key := programKey(module.tenant, module.behavior.RobotID)
+	e.mutex.Lock()
+	current := e.modules[key] == module
+	if current {
+		delete(e.modules, key)
+	}
+	e.mutex.Unlock()
+	if current {
+		module.close()
+	}
+}
+
+// active returns the modules that still decide, ordered by tenant and robot
+func (e *BehaviorEngine) active() []*behaviorModule {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	keys := make([]string, 0, len(e.modules))
+	for key := range e.modules {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	modules := make([]*behaviorModule, 0, len(keys))
+	for _, key := range keys {
+		modules = append(modules, e.modules[key])
+	}
+	return modules
+}
+
+// Close frees all modules and the runtime
+func (e *BehaviorEngine) Close() error {
+	e.mutex.Lock()
+	e.modules = make(map[string]*behaviorModule)
+	e.mutex.Unlock()
+	return e.runtime.Close(context.Background())
+}
+
+// close frees the module once no decision runs
+func (m *behaviorModule) close() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.module.Close(context.Background())
+}
+
+// run asks the module for the robot's next action; callers must hold the
+// module's lock. An error stops the module for good.
+func (m *behaviorModule) run(state BehaviorState, timeout time.Duration) (BatchStep, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	input, _ := json.Marshal(state)
+	results, err := m.alloc.Call(ctx, uint64(len(input)))
+	if err != nil {
+		return BatchStep{}, timedOut(ctx, err)
+	}
+	ptr := uint32(results[0])
+	if !m.module.Memory().Write(ptr, input) {
+		return BatchStep{}, errors.New("alloc returned memory out of range")
+	}
+	results, err = m.decide.Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return BatchStep{}, timedOut(ctx, err)
+	}
+
+	ptr, length := uint32(results[0]>>32), uint32(results[0])
+	if length == 0 {
+		return BatchStep{Action: "wait"}, nil
+	}
+	output, ok := m.module.Memory().Read(ptr, length)
+	if !ok {
+		return BatchStep{}, errors.New("decide returned memory out of range")
+	}
+	var step BatchStep
+	if err := json.Unmarshal(output, &step); err != nil {
+		return BatchStep{}, fmt.Errorf("decide returned an invalid action: %w", err)
+	}
+	return step, nil
+}
+
+// timedOut explains errors of calls that ran out of time
+func timedOut(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return errors.New("the decision took longer than the time limit")
+	}
+	return err
+}
+
+// SetBehaviors replaces the engine running the robots' behavior modules
+func (h *RobotHandler) SetBehaviors(behaviors *BehaviorEngine) {
+	h.behaviors = behaviors
+}
+
+// TickBehaviors lets every active behavior module decide what its robot
+// does next and carries the action out like a batch step. Actions the world
+// does not allow are counted as rejected. The behaviors of robots that left
+// their world are dropped. It is registered as a simulation system.
+func (h *RobotHandler) TickBehaviors(tick int64) {
+	for _, module := range h.behaviors.active() {
+		gone := false
+		module.mutex.Lock()
+		if module.behavior.Status == BehaviorActive {
+			gone = h.tickBehavior(module, tick)
+		}
+		module.mutex.Unlock()
+		if gone {
+			h.behaviors.drop(module)
+		}
+	}
+}
+
+// tickBehavior runs one decision of a module and reports whether its robot
+// is gone; callers must hold its lock
+func (h *RobotHandler) tickBehavior(module *behaviorModule, tick int64) bool {
+	world, events := h.worldOf(module.tenant), h.busOf(module.tenant)
+	behavior := &module.behavior
+	fail := func(err error) {
+		behavior.Status = BehaviorFailed
+		behavior.Error = err.Error()
+		module.module.Close(context.Background())
+		log.Printf("Behavior of robot %s stopped: %v", behavior.RobotID, err)
+		events.Publish("behavior_failed", behavior.RobotID, gin.H{"error": behavior.Error})
+	}
+
+	robot, err := world.GetRobot(behavior.RobotID)
+	if errors.Is(err, ErrRobotNotFound) {
+		return true
+	}
+	if err != nil {
+		fail(err)
+		return false
+	}
+	step, err := module.run(BehaviorState{
+		Tick:      tick,
+		RobotID:   robot.ID,
+		Position:  robot.Position,
+		Direction: robot.Direction,
+		Energy:    robot.Energy,
+		Inventory: append([]string{}, robot.Inventory...),
+	}, h.behaviors.limits.Timeout)
+	if err != nil {
+		fail(err)
+		return false
+	}
+	behavior.Decisions++
+	behavior.LastAction = &step
+	if step.Action == "wait" {
+		return false
+	}
+	if err := h.applyBehaviorStep(module.tenant, behavior.RobotID, step); err != nil {
+		behavior.Rejected++
+		behavior.LastReject = err.Error()
+	}
+	return false
+}
+
+// behaviorEngine hands out the contexts of behavior steps; gin only creates
+// contexts outside of requests through its test helpers
+var behaviorEngine = gin.New()
+
+// behaviorContext stands in for a request in the steps of a behavior
+// module: the step path and its hooks see the module's tenant, no caller
+// and the deadline of ctx
+func behaviorContext(ctx context.Context, tenant string) *gin.Context {
+	c := gin.CreateTestContextOnly(newBufferedWriter(nil), behaviorEngine)
+	c.Request, _ = http.NewRequestWithContext(ctx, http.MethodPost, "/", nil) // cannot fail for this URL
+	c.Set("tenant", tenant)
+	c.Set("behavior", true)
+	return c
+}
+
+// applyBehaviorStep carries out an action a behavior module decided on. It
+// takes the same path as the steps of batches, with quotas, firmware checks
+// and hooks, in the robot's turn: while a command runs or waits for the
+// robot, the step is rejected and the module decides again next tick.
+func (h *RobotHandler) applyBehaviorStep(tenant, id string, step BatchStep) error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDeviceTimeout)
+	defer cancel()
+	c := behaviorContext(ctx, tenant)
+	if err := h.validateStep(c, step); err != nil {
+		return err
+	}
+	if h.lanes != nil {
+		release, acquired := h.lanes.TryAcquire(tenant + "/" + id)
+		if !acquired {
+			return ErrRobotBusy
+		}
+		defer release()
+	}
+	return h.runStep(c, id, step, nil)
+}
+
+// UploadBehavior replaces a robot's behavior module. The body is the
+// WebAssembly binary.
+func (h *RobotHandler) UploadBehavior(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.world(c).GetRobot(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+	binary, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBehaviorSize+1))
+	if err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if len(binary) > maxBehaviorSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The behavior module is too large"})
+		return
+	}
+
+	behavior, err := h.behaviors.Load(c.Request.Context(), currentTenant(c), id, binary, h.world(c).Clock().Now().UTC())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid behavior module: " + err.Error()})
+		return
+	}
+	h.bus(c).PublishCommand(commandID(c), "behavior_uploaded", id, gin.H{"checksum": behavior.Checksum})
+	c.JSON(http.StatusOK, gin.H{"behavior": behavior})
+}
+
+// GetBehavior returns a robot's behavior module and how its decisions went
+func (h *RobotHandler) GetBehavior(c *gin.Context) {
+	behavior, exists := h.behaviors.Get(currentTenant(c), c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No behavior uploaded"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"behavior": behavior})
+}
+
+// DeleteBehavior removes a robot's behavior module
+func (h *RobotHandler) DeleteBehavior(c *gin.Context) {
+	if !h.behaviors.Delete(currentTenant(c), c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No behavior uploaded"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
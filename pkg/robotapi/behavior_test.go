@@ -0,0 +1,249 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupBehaviorRouter() (*gin.Engine, *RobotHandler, *RobotStorage) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	handler.SetBehaviors(NewBehaviorEngine(BehaviorLimits{MemoryPages: 16, Timeout: 50 * time.Millisecond}))
+	router := gin.New()
+	router.GET("/robot/:id/behavior", handler.GetBehavior)
+	router.PUT("/robot/:id/behavior", handler.UploadBehavior)
+	router.DELETE("/robot/:id/behavior", handler.DeleteBehavior)
+	return router, handler, storage
+}
+
+func uploadBehavior(router *gin.Engine, id string, binary []byte) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/robot/"+id+"/behavior", bytes.NewReader(binary))
+	req.Header.Set("Content-Type", "application/wasm")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// assembleBehavior assembles a WebAssembly module with the given memory, whose
+// alloc always returns address 1024 and whose decide runs the given code.
+// The data is placed at address 0.
+func assembleBehavior(pages int, decide []byte, data string) []byte {
+	uleb := func(v uint64) []byte {
+		var out []byte
+		for {
+			b := byte(v & 0x7f)
+			v >>= 7
+			if v == 0 {
+				return append(out, b)
+			}
+			out = append(out, b|0x80)
+		}
+	}
+	vector := func(count int, items ...[]byte) []byte {
+		out := uleb(uint64(count))
+		for _, item := range items {
+			out = append(out, item...)
+		}
+		return out
+	}
+	name := func(s string) []byte {
+		return append(uleb(uint64(len(s))), s...)
+	}
+	section := func(id byte, payload []byte) []byte {
+		return append(append([]byte{id}, uleb(uint64(len(payload)))...), payload...)
+	}
+	body := func(code []byte) []byte {
+		code = append([]byte{0x00}, code...) // no locals
+		return append(uleb(uint64(len(code))), code...)
+	}
+
+	module := []byte{0x00, 'a', 's', 'm', 0x01, 0x00, 0x00, 0x00}
+	module = append(module, section(1, vector(2,
+		[]byte{0x60, 0x01, 0x7f, 0x01, 0x7f},       // (i32) -> i32
+		[]byte{0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e}, // (i32, i32) -> i64
+	))...)
+	module = append(module, section(3, vector(2, []byte{0x00}, []byte{0x01}))...)
+	module = append(module, section(5, vector(1, append([]byte{0x00}, uleb(uint64(pages))...)))...)
+	module = append(module, section(7, vector(3,
+		append(name("memory"), 0x02, 0x00),
+		append(name("alloc"), 0x00, 0x00),
+		append(name("decide"), 0x00, 0x01),
+	))...)
+	module = append(module, section(10, vector(2,
+		body([]byte{0x41, 0x80, 0x08, 0x0b}), // i32.const 1024
+		body(decide),
+	))...)
+	if data != "" {
+		segment := append([]byte{0x00, 0x41, 0x00, 0x0b}, name(data)...)
+		module = append(module, section(11, vector(1, segment))...)
+	}
+	return module
+}
+
+// returnAction assembles a module that always decides on the action
+func returnAction(action string) []byte {
+	// i64.const len; a single byte encodes lengths below 64
+	return assembleBehavior(1, []byte{0x42, byte(len(action)), 0x0b}, action)
+}
+
+func TestBehaviorMovesRobotOnEveryTick(t *testing.T) {
+	router, handler, storage := setupBehaviorRouter()
+	binary := returnAction(`{"action":"move","direction":"up"}`)
+
+	w := uploadBehavior(router, "robot1", binary)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Behavior Behavior `json:"behavior"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, BehaviorActive, response.Behavior.Status)
+	assert.Equal(t, len(binary), response.Behavior.Size)
+
+	handler.TickBehaviors(1)
+	handler.TickBehaviors(2)
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 0, Y: 2}, robot.Position)
+	move := robot.Actions[len(robot.Actions)-1]
+	assert.Equal(t, "move", move.Type)
+	assert.Equal(t, true, move.Data.(gin.H)["behavior"])
+	assert.Empty(t, move.CommandID)
+
+	// Blocked moves are rejected, the module keeps deciding
+	require.NoError(t, storage.PlaceObstacle(Position{X: 0, Y: 3}))
+	handler.TickBehaviors(3)
+	w = send(router, "GET", "/robot/robot1/behavior", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, BehaviorActive, response.Behavior.Status)
+	assert.Equal(t, 3, response.Behavior.Decisions)
+	assert.Equal(t, 1, response.Behavior.Rejected)
+	assert.NotEmpty(t, response.Behavior.LastReject)
+	assert.Equal(t, &BatchStep{Action: "move", Direction: "up"}, response.Behavior.LastAction)
+
+	assert.Equal(t, http.StatusNoContent, send(router, "DELETE", "/robot/robot1/behavior", "").Code)
+	assert.Equal(t, http.StatusNotFound, send(router, "GET", "/robot/robot1/behavior", "").Code)
+	handler.TickBehaviors(4)
+	robot, _ = storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 0, Y: 2}, robot.Position)
+}
+
+func TestBehaviorsWithoutActionWait(t *testing.T) {
+	router, handler, storage := setupBehaviorRouter()
+	require.Equal(t, http.StatusOK, uploadBehavior(router, "robot1", returnAction("")).Code)
+	before, _ := storage.GetRobot("robot1")
+
+	handler.TickBehaviors(1)
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, before.Position, robot.Position)
+	behavior, _ := handler.behaviors.Get("", "robot1")
+	assert.Equal(t, 1, behavior.Decisions)
+	assert.Equal(t, &BatchStep{Action: "wait"}, behavior.LastAction)
+}
+
+func TestBehaviorsAreStoppedAtTheLimits(t *testing.T) {
+	router, handler, _ := setupBehaviorRouter()
+
+	// loop; br 0; end; i64.const 0
+	endless := assembleBehavior(1, []byte{0x03, 0x40, 0x0c, 0x00, 0x0b, 0x42, 0x00, 0x0b}, "")
+	require.Equal(t, http.StatusOK, uploadBehavior(router, "robot1", endless).Code)
+	subscriber, events := handler.events.Subscribe()
+	defer handler.events.Unsubscribe(subscriber)
+	started := time.Now()
+	handler.TickBehaviors(1)
+	assert.Less(t, time.Since(started), time.Second)
+
+	behavior, _ := handler.behaviors.Get("", "robot1")
+	assert.Equal(t, BehaviorFailed, behavior.Status)
+	assert.Contains(t, behavior.Error, "time limit")
+	event := <-events
+	assert.Equal(t, "behavior_failed", event.Type)
+
+	// Failed modules are not asked again
+	handler.TickBehaviors(2)
+	behavior, _ = handler.behaviors.Get("", "robot1")
+	assert.Zero(t, behavior.Decisions)
+
+	// Actions outside the memory stop the module as well: 5 bytes at 64 KiB
+	outside := assembleBehavior(1, []byte{0x42, 0x85, 0x80, 0x80, 0x80, 0x80, 0x80, 0xc0, 0x00, 0x0b}, "")
+	require.Equal(t, http.StatusOK, uploadBehavior(router, "robot1", outside).Code)
+	handler.TickBehaviors(3)
+	behavior, _ = handler.behaviors.Get("", "robot1")
+	assert.Equal(t, BehaviorFailed, behavior.Status)
+	assert.Contains(t, behavior.Error, "out of range")
+}
+
+func TestBehaviorsOfRemovedRobotsAreDropped(t *testing.T) {
+	router, handler, storage := setupBehaviorRouter()
+	require.Equal(t, http.StatusOK, uploadBehavior(router, "robot1", returnAction(`{"action":"wait"}`)).Code)
+	require.Equal(t, http.StatusOK, uploadBehavior(router, "robot2", returnAction(`{"action":"wait"}`)).Code)
+
+	_, err := storage.RemoveRobot("robot1")
+	require.NoError(t, err)
+	handler.TickBehaviors(1)
+	assert.Equal(t, http.StatusNotFound, send(router, "GET", "/robot/robot1/behavior", "").Code)
+	assert.Equal(t, http.StatusOK, send(router, "GET", "/robot/robot2/behavior", "").Code)
+	assert.Len(t, handler.behaviors.active(), 1)
+}
+
+func TestInvalidBehaviors(t *testing.T) {
+	router, _, _ := setupBehaviorRouter()
+	for name, binary := range map[string][]byte{
+		"garbage":         []byte("not wasm"),
+		"too much memory": assembleBehavior(17, []byte{0x42, 0x00, 0x0b}, ""),
+		"no exports":      assembleBehavior(1, []byte{0x42, 0x00, 0x0b}, "")[:8],
+	} {
+		assert.Equal(t, http.StatusBadRequest, uploadBehavior(router, "robot1", binary).Code, name)
+	}
+	assert.Equal(t, http.StatusNotFound, uploadBehavior(router, "ghost", returnAction("")).Code)
+	assert.Equal(t, http.StatusNotFound, send(router, "DELETE", "/robot/robot1/behavior", "").Code)
+}
+
+func TestBehaviorStepsFollowTheRulesOfCommands(t *testing.T) {
+	_, handler, storage := setupBehaviorRouter()
+	handler.SetQuotas(map[string]int{"move": 2})
+	lanes := NewCommandLanes(nil, 0, SystemClock{})
+	handler.SetLanes(lanes)
+	up := BatchStep{Action: "move", Direction: "up"}
+
+	// A behavior stops at the daily quota like any other caller
+	assert.NoError(t, handler.applyBehaviorStep("", "robot1", up))
+	assert.NoError(t, handler.applyBehaviorStep("", "robot1", up))
+	err := handler.applyBehaviorStep("", "robot1", up)
+	assert.ErrorContains(t, err, "Daily quota exceeded")
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 0, Y: 2}, robot.Position)
+	assert.Equal(t, true, robot.Actions[len(robot.Actions)-1].Data.(gin.H)["behavior"])
+
+	// Before hooks reject steps of behaviors
+	hooks := NewHookRegistry()
+	assert.NoError(t, hooks.Register("teleport", 0, teleportGuard{}))
+	handler.SetHooks(hooks)
+	assert.ErrorContains(t, handler.applyBehaviorStep("", "robot2", up), "Rejected by teleport")
+	handler.SetHooks(NewHookRegistry())
+
+	// Steps wait for their turn: while a command has the robot, they are rejected
+	release, acquired := lanes.TryAcquire("/robot2")
+	require.True(t, acquired)
+	assert.ErrorIs(t, handler.applyBehaviorStep("", "robot2", BatchStep{Action: "move", Direction: "right"}), ErrRobotBusy)
+	release()
+	assert.NoError(t, handler.applyBehaviorStep("", "robot2", BatchStep{Action: "move", Direction: "right"}))
+
+	// Robots with unsupported firmware do not act on their own either
+	handler.SetMinFirmware(FirmwareVersion{2, 0, 0})
+	_, err = storage.UpdateRobot("robot2", func(robot *Robot) error {
+		robot.Firmware = "1.0.0"
+		return nil
+	})
+	require.NoError(t, err)
+	assert.ErrorContains(t, handler.applyBehaviorStep("", "robot2", BatchStep{Action: "move", Direction: "right"}), "no longer supported")
+}
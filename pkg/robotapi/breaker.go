@@ -0,0 +1,115 @@
+package robotapi
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Circuit breaker states
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// CircuitBreaker stops sending requests to a failing backend. After
+// threshold consecutive failures it opens and rejects requests for the
+// cooldown; then a single trial request decides whether it closes again.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	failures  int
+	state     string
+	openedAt  time.Time
+	trial     bool // a half-open trial request is in flight
+	now       func() time.Time
+	mutex     sync.Mutex
+}
+
+// NewCircuitBreaker creates a closed circuit breaker
+func NewCircuitBreaker(threshold int, cooldown time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		state:     BreakerClosed,
+		now:       time.Now,
+	}
+}
+
+// State returns the current state of the breaker
+func (b *CircuitBreaker) State() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.state
+}
+
+// Allow reports whether a request may pass
+func (b *CircuitBreaker) Allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.trial = true
+		return true
+	case BreakerHalfOpen:
+		if b.trial {
+			return false
+		}
+		b.trial = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Success records a successful request and closes the breaker
+func (b *CircuitBreaker) Success() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures = 0
+	b.trial = false
+	b.state = BreakerClosed
+}
+
+// Failure records a failed request and opens the breaker if needed
+func (b *CircuitBreaker) Failure() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.failures++
+	b.trial = false
+	if b.state == BreakerHalfOpen || b.failures >= b.threshold {
+		b.state = BreakerOpen
+		b.openedAt = b.now()
+	}
+}
+
+// CircuitBreak guards the routes behind it with a circuit breaker. Server
+// errors and timeouts count as failures; while the breaker is open requests
+// are rejected with 503 instead of piling up.
+func CircuitBreak(breaker *CircuitBreaker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !breaker.Allow() {
+			c.Header("Retry-After", strconv.Itoa(int(breaker.cooldown.Seconds())))
+			abortWithProblem(c, http.StatusServiceUnavailable, "Storage backend is unavailable, try again later")
+			return
+		}
+
+		c.Next()
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			breaker.Failure()
+		} else {
+			breaker.Success()
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package robotapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	breaker := NewCircuitBreaker(2, time.Minute)
+	now := time.Now()
+	breaker.now = func() time.Time { return now }
+
+	breaker.Failure()
+	assert.True(t, breaker.Allow())
+	breaker.Failure()
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.False(t, breaker.Allow())
+
+	// After the cooldown a single trial request is let through
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.Allow())
+	assert.False(t, breaker.Allow())
+	breaker.Failure()
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	now = now.Add(time.Minute)
+	assert.True(t, breaker.Allow())
+	breaker.Success()
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestTimeoutAndCircuitBreak(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	breaker := NewCircuitBreaker(1, time.Minute)
+
+	release := make(chan struct{})
+	defer close(release)
+	slow := func(c *gin.Context) {
+		select {
+		case <-release:
+		case <-c.Request.Context().Done():
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "too late"})
+	}
+	fast := func(c *gin.Context) {
+		c.Header("X-Handler", "fast")
+		c.JSON(http.StatusCreated, gin.H{"message": "done"})
+	}
+	router.GET("/slow", CircuitBreak(breaker), WithTimeout(10*time.Millisecond, slow))
+	router.GET("/fast", WithTimeout(time.Second, fast))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/fast", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "fast", w.Header().Get("X-Handler"))
+	assert.Contains(t, w.Body.String(), "done")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/slow", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.NotContains(t, w.Body.String(), "too late")
+
+	// The timeout tripped the breaker
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/slow", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+}
+
+func TestWithTimeoutRecoversPanics(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/panic", WithTimeout(time.Second, func(c *gin.Context) {
+		c.Header("X-Handler", "half done")
+		c.String(http.StatusOK, "partial")
+		panic("handler bug")
+	}))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/panic", nil)
+	require.NotPanics(t, func() { router.ServeHTTP(w, req) })
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Empty(t, w.Header().Get("X-Handler"))
+	assert.NotContains(t, w.Body.String(), "partial")
+}
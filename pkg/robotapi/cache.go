@@ -0,0 +1,143 @@
+package robotapi
+
+import (
+	"bytes"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxCacheEntries bounds the memory used by the response cache
+const maxCacheEntries = 1000
+
+// cacheEntry is a cached response, valid while the storage version matches
+type cacheEntry struct {
+	contentType string
+	body        []byte
+	version     uint64
+	expires     time.Time
+}
+
+// CacheStats reports the effectiveness of the response cache
+type CacheStats struct {
+	Hits    uint64 `json:"hits"`
+	Misses  uint64 `json:"misses"`
+	Entries int    `json:"entries"`
+}
+
+// ResponseCache caches responses of read endpoints in memory. Entries expire
+// after the TTL and are invalidated as soon as the storage changes.
+type ResponseCache struct {
+	ttl     time.Duration
+	version func() uint64
+	entries map[string]cacheEntry
+	hits    uint64
+	misses  uint64
+	now     func() time.Time
+	mutex   sync.Mutex
+}
+
+// NewResponseCache creates a cache that checks freshness against the given
+// storage version. A TTL of 0 disables caching.
+func NewResponseCache(ttl time.Duration, version func() uint64) *ResponseCache {
+	return &ResponseCache{
+		ttl:     ttl,
+		version: version,
+		entries: make(map[string]cacheEntry),
+		now:     time.Now,
+	}
+}
+
+// Stats returns hit and miss counters
+func (rc *ResponseCache) Stats() CacheStats {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+	return CacheStats{Hits: rc.hits, Misses: rc.misses, Entries: len(rc.entries)}
+}
+
+func (rc *ResponseCache) lookup(key string, version uint64) (cacheEntry, bool) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	entry, exists := rc.entries[key]
+	if exists && entry.version == version && rc.now().Before(entry.expires) {
+		rc.hits++
+		return entry, true
+	}
+	delete(rc.entries, key)
+	rc.misses++
+	return cacheEntry{}, false
+}
+
+func (rc *ResponseCache) store(key string, entry cacheEntry) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if len(rc.entries) >= maxCacheEntries {
+		now := rc.now()
+		for k, e := range rc.entries {
+			if !now.Before(e.expires) || e.version != entry.version {
+				delete(rc.entries, k)
+			}
+		}
+		if len(rc.entries) >= maxCacheEntries {
+			return
+		}
+	}
+	rc.entries[key] = entry
+}
+
+// teeWriter passes a response through while keeping a copy of the body
+type teeWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *teeWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *teeWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Cached serves GET requests from the cache. Successful responses are stored
+// under the request URL; the X-Cache header tells whether it was a hit.
+func (rc *ResponseCache) Cached() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if rc.ttl <= 0 || c.Request.Method != http.MethodGet {
+			c.Next()
+			return
+		}
+
+		// Responses contain absolute links, so the link base is part of the
+		// key, and every tenant sees its own world
+		key := currentTenant(c) + " " + requestBaseURL(c) + c.Request.URL.RequestURI()
+		version := rc.version()
+		if entry, hit := rc.lookup(key, version); hit {
+			c.Header("X-Cache", "HIT")
+			c.Data(http.StatusOK, entry.contentType, entry.body)
+			c.Abort()
+			return
+		}
+
+		c.Header("X-Cache", "MISS")
+		writer := &teeWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		if writer.Status() == http.StatusOK {
+			rc.store(key, cacheEntry{
+				contentType: writer.Header().Get("Content-Type"),
+				body:        writer.body.Bytes(),
+				version:     version,
+				expires:     rc.now().Add(rc.ttl),
+			})
+		}
+	}
+}
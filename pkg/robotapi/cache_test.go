@@ -0,0 +1,69 @@
+package robotapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResponseCache(t *testing.T) {
+	router, storage := setupTestRouter()
+	handler := NewRobotHandler(storage)
+	cache := NewResponseCache(time.Minute, storage.Version)
+	router.GET("/cached/:id/status", cache.Cached(), handler.GetStatus)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/cached/robot1/status", nil)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := get()
+	assert.Equal(t, "MISS", first.Header().Get("X-Cache"))
+	second := get()
+	assert.Equal(t, "HIT", second.Header().Get("X-Cache"))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, "application/json; charset=utf-8", second.Header().Get("Content-Type"))
+
+	// Writes invalidate the cache
+	storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Energy = 42
+		return nil
+	})
+	third := get()
+	assert.Equal(t, "MISS", third.Header().Get("X-Cache"))
+	assert.Contains(t, third.Body.String(), `"energy":42`)
+
+	assert.Equal(t, CacheStats{Hits: 1, Misses: 2, Entries: 1}, cache.Stats())
+}
+
+func TestResponseCacheExpiresAndSkipsErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	cache := NewResponseCache(time.Second, storage.Version)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+
+	router := gin.New()
+	router.GET("/robot/:id/status", cache.Cached(), handler.GetStatus)
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+		return w.Header().Get("X-Cache")
+	}
+
+	get("/robot/nope/status")
+	assert.Equal(t, "MISS", get("/robot/nope/status"), "errors are not cached")
+
+	get("/robot/robot1/status")
+	now = now.Add(2 * time.Second)
+	assert.Equal(t, "MISS", get("/robot/robot1/status"))
+}
@@ -0,0 +1,92 @@
+package robotapi
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ChangesSince returns the robots, items and hazards that changed after the
+// given world version. Version 0 returns the complete world.
+func (s *RobotStorage) ChangesSince(since uint64) WorldChanges {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	changes := WorldChanges{
+		Version:      s.version,
+		Robots:       []RobotState{},
+		Items:        []Item{},
+		RemovedItems: []string{},
+	}
+	for id, version := range s.robotVersions {
+		if version <= since {
+			continue
+		}
+		robot, exists := s.robots[id]
+		if !exists {
+			changes.RemovedRobots = append(changes.RemovedRobots, id)
+			continue
+		}
+		robot = robot.clone()
+		state := RobotState{
+			ID:        robot.ID,
+			Position:  robot.Position,
+			Direction: robot.Direction,
+			Energy:    robot.Energy,
+			Effects:   robot.Effects,
+			Exact:     robot.Exact,
+			Velocity:  robot.Velocity,
+			Commanded: robot.Commanded,
+
+			Maintenance: robot.Maintenance,
+		}
+		if robot.Exact != nil {
+			heading := robot.currentHeading()
+			state.Heading = &heading
+		}
+		changes.Robots = append(changes.Robots, state)
+	}
+	for id, version := range s.itemVersions {
+		if version <= since {
+			continue
+		}
+		item, exists := s.items[id]
+		if !exists || item.carried {
+			changes.RemovedItems = append(changes.RemovedItems, id)
+		} else {
+			changes.Items = append(changes.Items, *item)
+		}
+	}
+	if s.hazardsVersion > since {
+		changes.Hazards = append([]Hazard{}, s.hazards...)
+	}
+	if s.layoutVersion > since {
+		layout := s.layout()
+		changes.Layout = &layout
+	}
+
+	sort.Slice(changes.Robots, func(i, j int) bool { return changes.Robots[i].ID < changes.Robots[j].ID })
+	sort.Slice(changes.Items, func(i, j int) bool { return changes.Items[i].ID < changes.Items[j].ID })
+	sort.Strings(changes.RemovedItems)
+	sort.Strings(changes.RemovedRobots)
+	return changes
+}
+
+// GetWorldChanges returns the entities changed since the version in the
+// since parameter. Clients pass the version of the previous response to stay
+// in sync without fetching the whole world.
+func (h *RobotHandler) GetWorldChanges(c *gin.Context) {
+	var since uint64
+	if value := c.Query("since"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be a world version"})
+			return
+		}
+		since = parsed
+	}
+
+	c.JSON(http.StatusOK, h.world(c).ChangesSince(since))
+}
@@ -0,0 +1,58 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorldChanges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	router := gin.New()
+	router.GET("/world/changes", handler.GetWorldChanges)
+
+	changes := func(query string) (int, WorldChanges) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/world/changes"+query, nil)
+		router.ServeHTTP(w, req)
+		var response WorldChanges
+		json.Unmarshal(w.Body.Bytes(), &response)
+		return w.Code, response
+	}
+
+	// Without since the whole world is returned
+	code, full := changes("")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, full.Robots, 2)
+	assert.Len(t, full.Items, 5)
+	assert.Len(t, full.Hazards, 2)
+
+	_, none := changes(fmt.Sprintf("?since=%d", full.Version))
+	assert.Empty(t, none.Robots)
+	assert.Empty(t, none.Items)
+	assert.Nil(t, none.Hazards)
+
+	storage.UpdateRobot("robot2", func(robot *Robot) error {
+		robot.Position = Position{X: 3, Y: 3}
+		return nil
+	})
+	storage.RemoveItem("item2")
+
+	_, delta := changes(fmt.Sprintf("?since=%d", full.Version))
+	assert.Equal(t, []RobotState{{ID: "robot2", Position: Position{X: 3, Y: 3}, Direction: "south", Energy: 100}}, delta.Robots)
+	assert.Empty(t, delta.Items)
+	assert.Equal(t, []string{"item2"}, delta.RemovedItems)
+	assert.Nil(t, delta.Hazards)
+	assert.Greater(t, delta.Version, full.Version)
+
+	code, _ = changes("?since=yesterday")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
@@ -0,0 +1,237 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	minutesPerDay = 24 * 60
+	dawn          = 6 * 60  // 06:00
+	dusk          = 18 * 60 // 18:00
+)
+
+// ClockState is the public view of the world clock
+type ClockState struct {
+	Day   int     `json:"day"`
+	Time  string  `json:"time"` // "HH:MM"
+	IsDay bool    `json:"is_day"`
+	Speed float64 `json:"speed"` // world minutes per simulation tick
+}
+
+// WorldClock tracks the in-game time of day. It is advanced by the
+// simulation and drives the day/night cycle.
+type WorldClock struct {
+	day     int
+	minutes float64
+	speed   float64
+	events  *EventBus
+	mutex   sync.RWMutex
+}
+
+// NewWorldClock creates a clock starting at 08:00 on day 1
+func NewWorldClock(events *EventBus, speed float64) *WorldClock {
+	return &WorldClock{
+		day:     1,
+		minutes: 8 * 60,
+		speed:   speed,
+		events:  events,
+	}
+}
+
+// Now returns the current state of the clock
+func (c *WorldClock) Now() ClockState {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	minutes := int(c.minutes)
+	return ClockState{
+		Day:   c.day,
+		Time:  fmt.Sprintf("%02d:%02d", minutes/60, minutes%60),
+		IsDay: isDaytime(c.minutes),
+		Speed: c.speed,
+	}
+}
+
+// IsDay reports whether the sun is up
+func (c *WorldClock) IsDay() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return isDaytime(c.minutes)
+}
+
+// SetSpeed changes how many world minutes pass per simulation tick
+func (c *WorldClock) SetSpeed(speed float64) error {
+	if speed < 0 || speed > minutesPerDay {
+		return errors.New("speed must be between 0 and 1440")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.speed = speed
+	return nil
+}
+
+// Tick advances the clock and publishes an event when day or night begins.
+// It is registered as a simulation system.
+func (c *WorldClock) Tick(tick int64) {
+	c.mutex.Lock()
+	wasDay := isDaytime(c.minutes)
+	c.minutes += c.speed
+	for c.minutes >= minutesPerDay {
+		c.minutes -= minutesPerDay
+		c.day++
+	}
+	isDay := isDaytime(c.minutes)
+	day := c.day
+	c.mutex.Unlock()
+
+	if wasDay != isDay {
+		eventType := "night_started"
+		if isDay {
+			eventType = "day_started"
+		}
+		c.events.Publish(eventType, "", map[string]int{"day": day})
+	}
+}
+
+// RechargeRate returns how much energy a robot regains per tick.
+// Solar robots recharge quickly by day and slowly at night.
+func (c *WorldClock) RechargeRate(robot *Robot) int {
+	if robot.Class != ClassSolar {
+		return 0
+	}
+	if c.IsDay() {
+		return 3
+	}
+	return 1
+}
+
+// ScanRadius limits a requested scan radius; at night sensors reach only half as far
+func (c *WorldClock) ScanRadius(requested int) int {
+	if c.IsDay() {
+		return requested
+	}
+	return requested / 2
+}
+
+func isDaytime(minutes float64) bool {
+	return minutes >= dawn && minutes < dusk
+}
+
+// Clock tells the time and makes tickers. Storage, the event bus, the
+// simulation and the background jobs take a Clock instead of calling
+// time.Now, so tests can control time with a FakeClock.
+type Clock interface {
+	Now() time.Time
+	NewTicker(interval time.Duration) Ticker
+}
+
+// Ticker delivers the time on C every interval, like time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// SystemClock is the real time
+type SystemClock struct{}
+
+// Now returns the current time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTicker returns a time.Ticker
+func (SystemClock) NewTicker(interval time.Duration) Ticker {
+	return systemTicker{time.NewTicker(interval)}
+}
+
+type systemTicker struct {
+	ticker *time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time { return t.ticker.C }
+func (t systemTicker) Stop()               { t.ticker.Stop() }
+
+// FakeClock only moves when it is advanced. Its tickers fire for every
+// interval that passed, dropping ticks that are not received in time like
+// time.Ticker does.
+type FakeClock struct {
+	now     time.Time
+	tickers []*fakeTicker
+	mutex   sync.Mutex
+}
+
+// NewFakeClock creates a clock standing at start
+func NewFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+// Now returns the clock's time
+func (c *FakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// NewTicker creates a ticker that fires when the clock is advanced past
+// its next tick
+func (c *FakeClock) NewTicker(interval time.Duration) Ticker {
+	if interval <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	ticker := &fakeTicker{clock: c, interval: interval, next: c.now.Add(interval), c: make(chan time.Time, 1)}
+	c.tickers = append(c.tickers, ticker)
+	return ticker
+}
+
+// Tickers returns the number of tickers that were not stopped, so tests
+// can wait for a loop to start before advancing the clock
+func (c *FakeClock) Tickers() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.tickers)
+}
+
+// Advance moves the clock forward and fires the tickers that are due
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+	for _, ticker := range c.tickers {
+		for !ticker.next.After(c.now) {
+			select {
+			case ticker.c <- ticker.next:
+			default:
+			}
+			ticker.next = ticker.next.Add(ticker.interval)
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock    *FakeClock
+	interval time.Duration
+	next     time.Time
+	c        chan time.Time
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Stop() {
+	t.clock.mutex.Lock()
+	defer t.clock.mutex.Unlock()
+
+	for i, ticker := range t.clock.tickers {
+		if ticker == t {
+			t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package robotapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorldClockDayNightCycle(t *testing.T) {
+	events := NewEventBus()
+	clock := NewWorldClock(events, 60)
+
+	assert.Equal(t, "08:00", clock.Now().Time)
+	assert.True(t, clock.IsDay())
+
+	// Ten hours later it is 18:00 and night begins
+	for tick := int64(1); tick <= 10; tick++ {
+		clock.Tick(tick)
+	}
+	assert.Equal(t, "18:00", clock.Now().Time)
+	assert.False(t, clock.IsDay())
+
+	logged := events.Since(0)
+	assert.Len(t, logged, 1)
+	assert.Equal(t, "night_started", logged[0].Type)
+
+	// Another fourteen hours wrap around to the next day
+	for tick := int64(11); tick <= 24; tick++ {
+		clock.Tick(tick)
+	}
+	assert.Equal(t, 2, clock.Now().Day)
+	assert.Equal(t, "08:00", clock.Now().Time)
+}
+
+func TestSolarRecharge(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	clock := NewWorldClock(NewEventBus(), 0)
+
+	solar, _ := storage.GetRobot("robot2")
+	standard, _ := storage.GetRobot("robot1")
+	solar.Energy = 50
+	standard.Energy = 50
+	storage.SaveRobot(solar)
+	storage.SaveRobot(standard)
+
+	storage.Recharge(clock.RechargeRate)
+	solar, _ = storage.GetRobot("robot2")
+	standard, _ = storage.GetRobot("robot1")
+	assert.Equal(t, 53, solar.Energy)
+	assert.Equal(t, 50, standard.Energy)
+
+	clock.SetSpeed(600)
+	clock.Tick(1)
+	storage.Recharge(clock.RechargeRate)
+	solar, _ = storage.GetRobot("robot2")
+	assert.Equal(t, 54, solar.Energy)
+}
+
+func TestScanRadiusShrinksAtNight(t *testing.T) {
+	clock := NewWorldClock(NewEventBus(), 12*60)
+	assert.Equal(t, 10, clock.ScanRadius(10))
+
+	clock.Tick(1)
+	assert.Equal(t, 5, clock.ScanRadius(10))
+}
+
+func TestSetTimeSpeedRequiresAdmin(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/admin/world/time", bytes.NewBufferString(`{"speed": 5}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PATCH", "/admin/world/time", bytes.NewBufferString(`{"speed": 5}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+testAdminToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/world/time", nil)
+	router.ServeHTTP(w, req)
+
+	var state ClockState
+	err := json.Unmarshal(w.Body.Bytes(), &state)
+	assert.NoError(t, err)
+	assert.Equal(t, 5.0, state.Speed)
+}
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Advance(999 * time.Millisecond)
+	assert.Empty(t, ticker.C())
+	clock.Advance(time.Millisecond)
+	assert.Equal(t, start.Add(time.Second), <-ticker.C())
+
+	// Ticks nobody received are dropped like with time.Ticker
+	clock.Advance(3 * time.Second)
+	assert.Len(t, ticker.C(), 1)
+	<-ticker.C()
+	assert.Equal(t, start.Add(4*time.Second), clock.Now())
+
+	ticker.Stop()
+	clock.Advance(time.Second)
+	assert.Empty(t, ticker.C())
+}
+
+func TestSimulationRunsOnTheClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	simulation := NewSimulation(time.Second)
+	simulation.SetClock(clock)
+	ticks := make(chan int64, 10)
+	simulation.AddSystem(func(tick int64) { ticks <- tick })
+
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go simulation.Run(ctx)
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Second)
+		select {
+		case tick := <-ticks:
+			return tick == 1
+		case <-time.After(10 * time.Millisecond):
+			return false
+		}
+	}, time.Second, time.Millisecond)
+}
+
+func TestQuotaResetsOnTheServerClock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clock := NewFakeClock(time.Date(2026, 10, 16, 23, 59, 0, 0, time.UTC))
+	config := DefaultConfig()
+	config.Clock = clock
+	config.Quotas = map[string]int{"move": 1}
+	server, err := New(config)
+	assert.NoError(t, err)
+	router := server.Router()
+
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+
+	clock.Advance(time.Minute)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	events := server.Events().Since(0)
+	assert.Equal(t, clock.Now(), events[len(events)-1].Timestamp)
+}
+
+func TestAdminStateIsDatedOnTheServerClock(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clock := NewFakeClock(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	config := DefaultConfig()
+	config.Clock = clock
+	config.AdminToken = "secret"
+	server, err := New(config)
+	assert.NoError(t, err)
+	router := server.Router()
+
+	clock.Advance(time.Hour)
+	w := sendAdmin(router, "/admin/random")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var seed struct {
+		SeededAt time.Time `json:"seeded_at"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &seed))
+	assert.True(t, clock.Now().Equal(seed.SeededAt))
+
+	w = sendAdmin(router, "/admin/estop")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stop struct {
+		EStop EStopState `json:"estop"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &stop))
+	assert.True(t, clock.Now().Equal(*stop.EStop.Since))
+}
@@ -0,0 +1,239 @@
+package robotapi
+
+import (
+	"fmt"
+	"math/rand"
+	"sync"
+)
+
+// CombatResult describes the outcome of a single attack
+type CombatResult struct {
+	Hit          bool
+	Damage       int
+	AttackerCost int
+	Effect       *StatusEffect // optional status effect applied to the target
+
+	// Exact amounts in thousandths of energy, for rules whose amounts are not
+	// whole; 0 if Damage and AttackerCost are exact
+	DamageMilli int
+	CostMilli   int
+}
+
+// damage returns the damage in thousandths of energy
+func (r CombatResult) damage() int {
+	if r.DamageMilli != 0 {
+		return r.DamageMilli
+	}
+	return r.Damage * energyScale
+}
+
+// cost returns the attacker's cost in thousandths of energy
+func (r CombatResult) cost() int {
+	if r.CostMilli != 0 {
+		return r.CostMilli
+	}
+	return r.AttackerCost * energyScale
+}
+
+// missed turns the result into a miss: the attacker pays all the same
+func (r CombatResult) missed() CombatResult {
+	return CombatResult{AttackerCost: r.AttackerCost, CostMilli: r.CostMilli}
+}
+
+// CombatEstimate describes the possible outcomes of an attack without
+// rolling any dice. The damage range applies if the attack hits.
+type CombatEstimate struct {
+	HitChance    float64 `json:"hit_chance"`
+	MinDamage    int     `json:"min_damage"`
+	MaxDamage    int     `json:"max_damage"`
+	AttackerCost int     `json:"attacker_cost"`
+}
+
+// CombatResolver decides how an attack between two robots plays out.
+// Implementations only calculate the outcome; applying it is up to the caller.
+type CombatResolver interface {
+	Name() string
+	Resolve(attacker, target *Robot) CombatResult
+	Estimate(attacker, target *Robot) CombatEstimate
+}
+
+// NewCombatResolver returns the resolver registered under the given name,
+// rolling its dice from a random seed
+func NewCombatResolver(name string) (CombatResolver, error) {
+	return NewSeededCombatResolver(name, rand.Int63())
+}
+
+// NewSeededCombatResolver returns the resolver registered under the given
+// name, rolling its dice from the given seed
+func NewSeededCombatResolver(name string, seed int64) (CombatResolver, error) {
+	return newCombatResolver(name, newDice(seed))
+}
+
+// NewRandomCombatResolver returns the resolver registered under the given
+// name, rolling its dice from the combat stream of the random service
+func NewRandomCombatResolver(name string, random *RandomService) (CombatResolver, error) {
+	return newCombatResolver(name, random.stream(RandomCombat))
+}
+
+func newCombatResolver(name string, dice *dice) (CombatResolver, error) {
+	switch name {
+	case "", "percentage":
+		return PercentageCombat{}, nil
+	case "dice":
+		return &DiceCombat{dice: dice}, nil
+	case "armor":
+		return &ArmorClassCombat{dice: dice}, nil
+	default:
+		return nil, fmt.Errorf("unknown combat rules %q", name)
+	}
+}
+
+// PercentageCombat is the original rule set: the attacker pays 5% of its
+// energy and the target loses 15% of its energy. Every attack hits.
+type PercentageCombat struct{}
+
+// Name returns the config name of the resolver
+func (PercentageCombat) Name() string {
+	return "percentage"
+}
+
+// Resolve calculates the outcome of an attack; the exact amounts are left
+// to the energy rounding
+func (PercentageCombat) Resolve(attacker, target *Robot) CombatResult {
+	return CombatResult{
+		Hit:          true,
+		Damage:       target.Energy * 15 / 100,
+		AttackerCost: attacker.Energy * 5 / 100,
+		DamageMilli:  preciseEnergy(target) * 15 / 100,
+		CostMilli:    preciseEnergy(attacker) * 5 / 100,
+	}
+}
+
+// Estimate returns the certain outcome of an attack
+func (PercentageCombat) Estimate(attacker, target *Robot) CombatEstimate {
+	damage := target.Energy * 15 / 100
+	return CombatEstimate{
+		HitChance:    1,
+		MinDamage:    damage,
+		MaxDamage:    damage,
+		AttackerCost: attacker.Energy * 5 / 100,
+	}
+}
+
+// dice is a goroutine-safe random source for dice rolls
+type dice struct {
+	mutex sync.Mutex
+	rng   *rand.Rand
+}
+
+func newDice(seed int64) *dice {
+	return &dice{rng: rand.New(rand.NewSource(seed))}
+}
+
+// roll throws count dice with the given number of sides and returns the sum
+func (d *dice) roll(count, sides int) int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	total := 0
+	for i := 0; i < count; i++ {
+		total += d.rng.Intn(sides) + 1
+	}
+	return total
+}
+
+// DiceCombat rolls a d20 to hit (10 or more) and 2d6 for damage. A natural
+// 20 sets the target on fire. Attacking costs a flat 3 energy.
+type DiceCombat struct {
+	dice *dice
+}
+
+// NewDiceCombat creates a dice-based resolver with the given seed
+func NewDiceCombat(seed int64) *DiceCombat {
+	return &DiceCombat{dice: newDice(seed)}
+}
+
+// Name returns the config name of the resolver
+func (c *DiceCombat) Name() string {
+	return "dice"
+}
+
+// Resolve calculates the outcome of an attack
+func (c *DiceCombat) Resolve(attacker, target *Robot) CombatResult {
+	result := CombatResult{AttackerCost: 3}
+	roll := c.dice.roll(1, 20)
+	if roll >= 10 {
+		result.Hit = true
+		result.Damage = c.dice.roll(2, 6)
+	}
+	if roll == 20 {
+		burning := Burning(3, 2)
+		result.Effect = &burning
+	}
+	return result
+}
+
+// Estimate returns the odds of an attack: 11 of 20 rolls hit for 2 to 12
+func (c *DiceCombat) Estimate(attacker, target *Robot) CombatEstimate {
+	return CombatEstimate{HitChance: 0.55, MinDamage: 2, MaxDamage: 12, AttackerCost: 3}
+}
+
+// defaultArmorClass is used for robots without an explicit armor value
+const defaultArmorClass = 10
+
+// ArmorClassCombat rolls a d20 against the target's armor class and deals
+// 1d8 damage on a hit. A natural 20 always hits, doubles the damage and
+// stuns the target with an EMP. Attacking costs a flat 2 energy.
+type ArmorClassCombat struct {
+	dice *dice
+}
+
+// NewArmorClassCombat creates an armor-class resolver with the given seed
+func NewArmorClassCombat(seed int64) *ArmorClassCombat {
+	return &ArmorClassCombat{dice: newDice(seed)}
+}
+
+// Name returns the config name of the resolver
+func (c *ArmorClassCombat) Name() string {
+	return "armor"
+}
+
+// Resolve calculates the outcome of an attack
+func (c *ArmorClassCombat) Resolve(attacker, target *Robot) CombatResult {
+	armor := target.Armor
+	if armor == 0 {
+		armor = defaultArmorClass
+	}
+
+	result := CombatResult{AttackerCost: 2}
+	roll := c.dice.roll(1, 20)
+	switch {
+	case roll == 20:
+		result.Hit = true
+		result.Damage = 2 * c.dice.roll(1, 8)
+		stunned := Stunned(2)
+		result.Effect = &stunned
+	case roll >= armor:
+		result.Hit = true
+		result.Damage = c.dice.roll(1, 8)
+	}
+	return result
+}
+
+// Estimate returns the odds of an attack against the target's armor class.
+// The damage range includes the doubled damage of a natural 20.
+func (c *ArmorClassCombat) Estimate(attacker, target *Robot) CombatEstimate {
+	armor := target.Armor
+	if armor == 0 {
+		armor = defaultArmorClass
+	}
+
+	// Rolls from the armor class up to 19 hit, a 20 always does
+	hits := 1 + max(0, 19-max(armor, 1)+1)
+	return CombatEstimate{
+		HitChance:    float64(hits) / 20,
+		MinDamage:    1,
+		MaxDamage:    16,
+		AttackerCost: 2,
+	}
+}
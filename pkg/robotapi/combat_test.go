@@ -0,0 +1,95 @@
+package robotapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewCombatResolver(t *testing.T) {
+	for _, name := range []string{"", "percentage", "dice", "armor"} {
+		resolver, err := NewCombatResolver(name)
+		assert.NoError(t, err)
+		assert.NotNil(t, resolver)
+	}
+
+	_, err := NewCombatResolver("laser")
+	assert.Error(t, err)
+}
+
+func TestPercentageCombat(t *testing.T) {
+	attacker := &Robot{Energy: 100}
+	target := &Robot{Energy: 80}
+
+	result := PercentageCombat{}.Resolve(attacker, target)
+
+	assert.True(t, result.Hit)
+	assert.Equal(t, 5, result.AttackerCost)
+	assert.Equal(t, 12, result.Damage)
+}
+
+func TestDiceCombatBounds(t *testing.T) {
+	resolver := NewDiceCombat(42)
+	attacker := &Robot{Energy: 100}
+	target := &Robot{Energy: 100}
+
+	for i := 0; i < 100; i++ {
+		result := resolver.Resolve(attacker, target)
+		assert.Equal(t, 3, result.AttackerCost)
+		if result.Hit {
+			assert.GreaterOrEqual(t, result.Damage, 2)
+			assert.LessOrEqual(t, result.Damage, 12)
+		} else {
+			assert.Equal(t, 0, result.Damage)
+		}
+	}
+}
+
+func TestArmorClassCombatHighArmor(t *testing.T) {
+	resolver := NewArmorClassCombat(7)
+	attacker := &Robot{Energy: 100}
+	target := &Robot{Energy: 100, Armor: 21}
+
+	// With armor above 20 only a natural 20 can hit, dealing 2-16 damage
+	for i := 0; i < 100; i++ {
+		result := resolver.Resolve(attacker, target)
+		if result.Hit {
+			assert.GreaterOrEqual(t, result.Damage, 2)
+			assert.LessOrEqual(t, result.Damage, 16)
+		}
+	}
+}
+
+func TestAttackRobotUsesConfiguredResolver(t *testing.T) {
+	router, storage := setupTestRouter()
+	handler := NewRobotHandler(storage)
+	handler.SetCombatResolver(NewDiceCombat(1))
+	router.POST("/dice/:id/attack/:targetId", handler.AttackRobot)
+	placeInRange(t, storage, "robot1", "robot2")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/dice/robot1/attack/robot2", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"combat_rules":"dice"`)
+
+	attacker, _ := storage.GetRobot("robot1")
+	assert.Equal(t, 97, attacker.Energy)
+}
+
+func TestCombatEstimate(t *testing.T) {
+	attacker := &Robot{Energy: 100}
+	target := &Robot{Energy: 80}
+
+	assert.Equal(t, CombatEstimate{HitChance: 1, MinDamage: 12, MaxDamage: 12, AttackerCost: 5},
+		PercentageCombat{}.Estimate(attacker, target))
+	assert.Equal(t, 0.55, NewDiceCombat(1).Estimate(attacker, target).HitChance)
+
+	armor := NewArmorClassCombat(1)
+	assert.Equal(t, 0.55, armor.Estimate(attacker, target).HitChance, "default armor class 10")
+	target.Armor = 25
+	assert.Equal(t, 0.05, armor.Estimate(attacker, target).HitChance, "only a natural 20 hits")
+}
@@ -0,0 +1,183 @@
+package robotapi
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamRetryAfter is how long clients rejected for too many streams are
+// asked to wait
+const streamRetryAfter = 10 * time.Second
+
+// StreamLimits cap the long-lived requests, like long polls and streamed
+// exports; 0 means unlimited
+type StreamLimits struct {
+	MaxStreams          int `json:"max_streams"`            // of all clients together
+	MaxStreamsPerClient int `json:"max_streams_per_client"` // per authenticated caller, or IP address
+}
+
+// ConnectionStats are the gauges of open streams and connections
+type ConnectionStats struct {
+	Streams     int            `json:"streams"`     // long-lived requests running
+	Clients     int            `json:"clients"`     // clients with at least one stream
+	Rejected    int64          `json:"rejected"`    // streams refused so far
+	Connections int            `json:"connections"` // open HTTP connections
+	Idle        int            `json:"idle"`        // connections waiting for their next request
+	Limits      StreamLimits   `json:"limits"`
+	PerClient   map[string]int `json:"per_client,omitempty"`
+
+	Subscribers   []SubscriberStats `json:"subscribers,omitempty"` // of the event buses
+	DroppedEvents int64             `json:"dropped_events"`        // missed by the current subscribers
+}
+
+// ConnectionLimiter keeps a server's long-lived requests within budget, so
+// a classroom of dashboards cannot exhaust its file descriptors and
+// goroutines. Streams beyond the limits are refused with a Retry-After
+// header; it also counts the HTTP connections for the gauges.
+type ConnectionLimiter struct {
+	limits      StreamLimits
+	streams     map[string]int // client -> running streams
+	total       int
+	rejected    int64
+	connections map[net.Conn]http.ConnState
+	mutex       sync.Mutex
+}
+
+// NewConnectionLimiter creates a limiter without running streams
+func NewConnectionLimiter(limits StreamLimits) *ConnectionLimiter {
+	return &ConnectionLimiter{
+		limits:      limits,
+		streams:     make(map[string]int),
+		connections: make(map[net.Conn]http.ConnState),
+	}
+}
+
+// streamClient names the caller of a request: the authenticated principal,
+// or the IP address without authentication
+func streamClient(c *gin.Context) string {
+	if principal := currentPrincipal(c); principal != nil {
+		return principal.ID
+	}
+	return c.ClientIP()
+}
+
+// acquire reserves a stream for the client. It returns the status to
+// refuse the stream with, or 0.
+func (l *ConnectionLimiter) acquire(client string) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	status := 0
+	switch {
+	case l.limits.MaxStreams > 0 && l.total >= l.limits.MaxStreams:
+		status = http.StatusServiceUnavailable
+	case l.limits.MaxStreamsPerClient > 0 && l.streams[client] >= l.limits.MaxStreamsPerClient:
+		status = http.StatusTooManyRequests
+	}
+	if status != 0 {
+		l.rejected++
+		return status
+	}
+	l.streams[client]++
+	l.total++
+	return 0
+}
+
+// release frees a stream of the client
+func (l *ConnectionLimiter) release(client string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.total--
+	if l.streams[client]--; l.streams[client] <= 0 {
+		delete(l.streams, client)
+	}
+}
+
+// Limit is the middleware of the long-lived routes. It must run after
+// authentication, so streams are counted per caller.
+func (l *ConnectionLimiter) Limit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		client := streamClient(c)
+		switch l.acquire(client) {
+		case http.StatusServiceUnavailable:
+			c.Header("Retry-After", strconv.Itoa(int(streamRetryAfter.Seconds())))
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": "Too many open streams, retry later",
+				"limit": l.limits.MaxStreams,
+			})
+			return
+		case http.StatusTooManyRequests:
+			c.Header("Retry-After", strconv.Itoa(int(streamRetryAfter.Seconds())))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "Too many open streams for this client",
+				"limit": l.limits.MaxStreamsPerClient,
+			})
+			return
+		}
+		defer l.release(client)
+		c.Next()
+	}
+}
+
+// TrackConnection follows the state of the HTTP connections; it is meant
+// to be the ConnState hook of the http.Server
+func (l *ConnectionLimiter) TrackConnection(conn net.Conn, state http.ConnState) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	switch state {
+	case http.StateClosed, http.StateHijacked:
+		delete(l.connections, conn)
+	default:
+		l.connections[conn] = state
+	}
+}
+
+// Stats returns the current gauges
+func (l *ConnectionLimiter) Stats() ConnectionStats {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	stats := ConnectionStats{
+		Streams:     l.total,
+		Clients:     len(l.streams),
+		Rejected:    l.rejected,
+		Connections: len(l.connections),
+		Limits:      l.limits,
+	}
+	if len(l.streams) > 0 {
+		stats.PerClient = make(map[string]int, len(l.streams))
+		for client, streams := range l.streams {
+			stats.PerClient[client] = streams
+		}
+	}
+	for _, state := range l.connections {
+		if state == http.StateIdle {
+			stats.Idle++
+		}
+	}
+	return stats
+}
+
+// GetConnectionStats reports the open streams and connections, and the
+// buffers of the event subscribers
+func (h *RobotHandler) GetConnectionStats(c *gin.Context) {
+	stats := h.streams.Stats()
+	stats.Subscribers = h.events.Subscribers()
+	for _, tenant := range h.tenants.All() {
+		for _, subscriber := range tenant.Events.Subscribers() {
+			subscriber.Tenant = tenant.ID
+			stats.Subscribers = append(stats.Subscribers, subscriber)
+		}
+	}
+	for _, subscriber := range stats.Subscribers {
+		stats.DroppedEvents += subscriber.DroppedEvents
+	}
+	c.JSON(http.StatusOK, stats)
+}
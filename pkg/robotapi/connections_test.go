@@ -0,0 +1,109 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamFrom sends a request to the stream route from a client address
+func streamFrom(router *gin.Engine, remoteAddr string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/stream", nil)
+	req.RemoteAddr = remoteAddr
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestConnectionLimiter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limits := StreamLimits{MaxStreams: 2, MaxStreamsPerClient: 1}
+	limiter := NewConnectionLimiter(limits)
+	release := make(chan struct{})
+	router := gin.New()
+	router.GET("/stream", limiter.Limit(), func(c *gin.Context) {
+		<-release
+		c.Status(http.StatusOK)
+	})
+
+	done := make(chan int, 2)
+	go func() { done <- streamFrom(router, "192.0.2.1:1000").Code }()
+	require.Eventually(t, func() bool { return limiter.Stats().Streams == 1 }, 5*time.Second, time.Millisecond)
+
+	// A client's second stream is too many
+	w := streamFrom(router, "192.0.2.1:2000")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+
+	// So is any stream beyond the total
+	go func() { done <- streamFrom(router, "192.0.2.2:1000").Code }()
+	require.Eventually(t, func() bool { return limiter.Stats().Streams == 2 }, 5*time.Second, time.Millisecond)
+	w = streamFrom(router, "192.0.2.3:1000")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+
+	stats := limiter.Stats()
+	assert.Equal(t, 2, stats.Clients)
+	assert.Equal(t, map[string]int{"192.0.2.1": 1, "192.0.2.2": 1}, stats.PerClient)
+	assert.Equal(t, int64(2), stats.Rejected)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, ConnectionStats{Rejected: 2, Limits: limits}, limiter.Stats())
+}
+
+func TestStreamsPerClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	config.Streams.MaxStreamsPerClient = 1
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+
+	polled := make(chan int)
+	go func() { polled <- send(router, "GET", "/robot/robot1/events/poll?wait=5", "").Code }()
+	require.Eventually(t, func() bool { return server.streams.Stats().Streams == 1 }, 5*time.Second, time.Millisecond)
+
+	w := send(router, "GET", "/robot/robot1/actions/export", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+
+	w = adminRequest(router, "GET", "/admin/connections", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats ConnectionStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, 1, stats.Streams)
+	assert.Equal(t, int64(1), stats.Rejected)
+
+	// The poll ends with the next event
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	assert.Equal(t, http.StatusOK, <-polled)
+	assert.Equal(t, http.StatusOK, send(router, "GET", "/robot/robot1/actions/export", "").Code)
+}
+
+func TestTrackConnections(t *testing.T) {
+	limiter := NewConnectionLimiter(StreamLimits{})
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Config.ConnState = limiter.TrackConnection
+	server.Start()
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Eventually(t, func() bool {
+		stats := limiter.Stats()
+		return stats.Connections == 1 && stats.Idle == 1
+	}, 5*time.Second, time.Millisecond)
+
+	server.CloseClientConnections()
+	assert.Eventually(t, func() bool { return limiter.Stats().Connections == 0 }, 5*time.Second, time.Millisecond)
+}
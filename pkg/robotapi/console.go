@@ -0,0 +1,25 @@
+package robotapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// consolePage is a single-page console for trying the API by hand: pick a
+// robot, steer it with direction buttons or the arrow keys and read the JSON
+// responses. It calls the real endpoints next to /console, with the API key
+// entered on the page.
+//
+//go:embed console.html
+var consolePage []byte
+
+// consolePolicy only lets the console run its own script
+var consolePolicy = pageContentSecurityPolicy(consolePage)
+
+// GetConsole serves the API console
+func GetConsole(c *gin.Context) {
+	c.Header("Cache-Control", "no-cache")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", consolePage)
+}
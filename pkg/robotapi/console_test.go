@@ -0,0 +1,44 @@
+package robotapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.BasePath = "/robots-api"
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/robots-api/console", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "Robot API Console")
+
+	// The routes the console calls exist next to it
+	routes := map[string]bool{}
+	for _, route := range router.Routes() {
+		routes[route.Method+" "+route.Path] = true
+	}
+	for _, route := range []string{
+		"GET /robots-api/world/map",
+		"POST /robots-api/robot/:id/move",
+		"GET /robots-api/robot/:id/status",
+		"GET /robots-api/robot/:id/scan",
+		"GET /robots-api/robot/:id/actions",
+		"POST /robots-api/robot/:id/pickup/:itemId",
+		"POST /robots-api/robot/:id/putdown/:itemId",
+		"POST /robots-api/robot/:id/attack/:targetId",
+	} {
+		assert.True(t, routes[route], route)
+	}
+}
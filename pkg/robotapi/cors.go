@@ -0,0 +1,112 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// CORS presets for the environments the API runs in
+const (
+	CORSPublic      = "public"      // any origin, without credentials
+	CORSDevelopment = "development" // local web apps on any port, with credentials
+	CORSProduction  = "production"  // only the listed origins, with credentials
+)
+
+// localOrigins are the origins of web apps served from the developer's machine
+var localOrigins = []string{"http://localhost:*", "http://127.0.0.1:*"}
+
+// CORSPolicy decides which web apps may call the API from a browser. Origins
+// are exact, like https://app.example.com, may have a single * for a variable
+// part, like https://*.example.com, or are "*" for any origin.
+type CORSPolicy struct {
+	Origins     []string
+	Credentials bool // allow cookies and client certificates; not together with "*"
+	// AllowOrigin is asked about origins that are not listed, e.g. to look
+	// them up in a database
+	AllowOrigin func(origin string) bool
+}
+
+// NewCORSPolicy returns the policy of a preset with extra allowed origins;
+// an empty preset is CORSPublic
+func NewCORSPolicy(preset string, origins []string) (CORSPolicy, error) {
+	switch preset {
+	case "", CORSPublic:
+		if len(origins) == 0 {
+			origins = []string{"*"}
+		}
+		return CORSPolicy{Origins: origins}, nil
+	case CORSDevelopment:
+		return CORSPolicy{Origins: append(append([]string{}, localOrigins...), origins...), Credentials: true}, nil
+	case CORSProduction:
+		return CORSPolicy{Origins: origins, Credentials: true}, nil
+	default:
+		return CORSPolicy{}, fmt.Errorf("unknown CORS preset %q", preset)
+	}
+}
+
+// Validate rejects policies browsers would not accept
+func (p CORSPolicy) Validate() error {
+	for _, origin := range p.Origins {
+		if origin == "*" {
+			if p.Credentials {
+				return errors.New(`the "*" origin cannot be used with credentials`)
+			}
+			continue
+		}
+		if !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return fmt.Errorf("origin %q has no http or https scheme", origin)
+		}
+		if strings.Count(origin, "*") > 1 {
+			return fmt.Errorf("origin %q has more than one *", origin)
+		}
+	}
+	return nil
+}
+
+// allowAll reports whether any origin is allowed
+func (p CORSPolicy) allowAll() bool {
+	for _, origin := range p.Origins {
+		if origin == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether a web app from the origin may call the API
+func (p CORSPolicy) Allows(origin string) bool {
+	for _, allowed := range p.Origins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if prefix, suffix, found := strings.Cut(allowed, "*"); found &&
+			len(origin) > len(prefix)+len(suffix) &&
+			strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+			return true
+		}
+	}
+	return p.AllowOrigin != nil && p.AllowOrigin(origin)
+}
+
+// Middleware answers preflight requests and sets the CORS headers.
+// Requests from other origins are rejected with 403.
+func (p CORSPolicy) Middleware() gin.HandlerFunc {
+	config := cors.Config{
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE"},                                         // Allowed methods
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-CSRF-Token", "X-Command-Priority"}, // Allowed headers
+		ExposeHeaders:    []string{"Content-Length", "X-Command-ID"},                                                // Exposed headers
+		AllowCredentials: p.Credentials,                                                                             // Allow cookies
+		MaxAge:           12 * time.Hour,                                                                            // Preflight request cache duration
+	}
+	if p.allowAll() {
+		config.AllowAllOrigins = true
+	} else {
+		config.AllowOriginFunc = p.Allows
+	}
+	return cors.New(config)
+}
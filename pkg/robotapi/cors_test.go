@@ -0,0 +1,127 @@
+package robotapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// preflight sends a CORS preflight for a POST from the origin
+func preflight(router *gin.Engine, origin, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("OPTIONS", path, nil)
+	req.Header.Set("Origin", origin)
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	req.Header.Set("Access-Control-Request-Headers", "Content-Type, Authorization")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func corsRouter(t *testing.T, policy CORSPolicy) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.CORS = policy
+	server, err := New(config)
+	require.NoError(t, err)
+	return server.Router()
+}
+
+func TestCORSPresets(t *testing.T) {
+	policy, err := NewCORSPolicy("", nil)
+	require.NoError(t, err)
+	assert.Equal(t, CORSPolicy{Origins: []string{"*"}}, policy)
+
+	policy, err = NewCORSPolicy(CORSDevelopment, []string{"https://staging.example.com"})
+	require.NoError(t, err)
+	assert.True(t, policy.Credentials)
+	assert.True(t, policy.Allows("http://localhost:5173"))
+	assert.True(t, policy.Allows("http://127.0.0.1:3000"))
+	assert.True(t, policy.Allows("https://staging.example.com"))
+	assert.False(t, policy.Allows("https://evil.example"))
+
+	policy, err = NewCORSPolicy(CORSProduction, []string{"https://*.example.com"})
+	require.NoError(t, err)
+	assert.True(t, policy.Allows("https://app.example.com"))
+	assert.False(t, policy.Allows("https://example.com"))
+	assert.False(t, policy.Allows("https://app.example.com.evil.example"))
+	assert.False(t, policy.Allows("http://localhost:5173"))
+
+	_, err = NewCORSPolicy("staging", nil)
+	assert.Error(t, err)
+}
+
+func TestCORSPolicyValidate(t *testing.T) {
+	assert.NoError(t, CORSPolicy{Origins: []string{"*"}}.Validate())
+	assert.Error(t, CORSPolicy{Origins: []string{"*"}, Credentials: true}.Validate())
+	assert.Error(t, CORSPolicy{Origins: []string{"app.example.com"}}.Validate())
+	assert.Error(t, CORSPolicy{Origins: []string{"https://*.*.example.com"}}.Validate())
+
+	config := DefaultConfig()
+	config.CORS.Credentials = true
+	_, err := New(config)
+	assert.ErrorContains(t, err, "invalid CORS configuration")
+}
+
+func TestCORSFromEnv(t *testing.T) {
+	t.Setenv("CORS_PRESET", CORSProduction)
+	t.Setenv("CORS_ORIGINS", "https://app.example.com, https://admin.example.com")
+	config, err := ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, CORSPolicy{
+		Origins:     []string{"https://app.example.com", "https://admin.example.com"},
+		Credentials: true,
+	}, config.CORS)
+
+	t.Setenv("CORS_CREDENTIALS", "false")
+	config, err = ConfigFromEnv()
+	require.NoError(t, err)
+	assert.False(t, config.CORS.Credentials)
+
+	t.Setenv("CORS_PRESET", "staging")
+	_, err = ConfigFromEnv()
+	assert.ErrorContains(t, err, "invalid CORS configuration")
+}
+
+func TestCORSPreflightAnyOrigin(t *testing.T) {
+	router := corsRouter(t, CORSPolicy{Origins: []string{"*"}})
+
+	w := preflight(router, "https://app.example.com", "/robot/robot1/move")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "POST")
+}
+
+func TestCORSPreflightAllowlist(t *testing.T) {
+	router := corsRouter(t, CORSPolicy{
+		Origins:     []string{"https://app.example.com"},
+		Credentials: true,
+		AllowOrigin: func(origin string) bool { return origin == "https://tenant.example.org" },
+	})
+
+	for _, origin := range []string{"https://app.example.com", "https://tenant.example.org"} {
+		w := preflight(router, origin, "/robot/robot1/move")
+		assert.Equal(t, http.StatusNoContent, w.Code, origin)
+		assert.Equal(t, origin, w.Header().Get("Access-Control-Allow-Origin"), origin)
+		assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"), origin)
+		assert.Contains(t, w.Header().Values("Vary"), "Origin", origin)
+	}
+
+	w := preflight(router, "https://evil.example", "/robot/robot1/move")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// Simple requests are answered with the origin too
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, strings.ToLower(w.Header().Get("Access-Control-Expose-Headers")), "x-command-id")
+}
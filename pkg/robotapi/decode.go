@@ -0,0 +1,161 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// defaultMaxBodyBytes limits request bodies unless another limit is
+// configured
+const defaultMaxBodyBytes = 1 << 20
+
+// errBodyTooLarge is returned for request bodies beyond the limit
+var errBodyTooLarge = errors.New("the body is too large")
+
+// LimitBody sets the limit of the request bodies read by bindJSON, the
+// mirror and the recorder. Uploads with limits of their own, like behavior
+// modules and replays, keep those.
+func LimitBody(limit int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("body_limit", limit)
+		c.Next()
+	}
+}
+
+// readBody reads the request body up to the limit set by LimitBody, or
+// defaultMaxBodyBytes without it. Longer bodies give errBodyTooLarge.
+func readBody(c *gin.Context) ([]byte, error) {
+	limit := c.GetInt64("body_limit")
+	if limit <= 0 {
+		limit = defaultMaxBodyBytes
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, errBodyTooLarge
+	}
+	return data, err
+}
+
+// bodyTooLarge answers a request whose body is beyond the limit
+func bodyTooLarge(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The request body is too large"})
+}
+
+// bindJSON decodes the request body into obj and validates its binding
+// tags. Unlike gin's binding it only accepts a single JSON object: null,
+// other JSON values and trailing data are rejected instead of leaving obj
+// at its defaults. An empty body gives io.EOF, for handlers where the body
+// is optional. Bodies beyond the limit give errBodyTooLarge. Other errors
+// say what is wrong in terms of the JSON.
+func bindJSON(c *gin.Context, obj interface{}) error {
+	if c.Request.Body == nil {
+		return io.EOF
+	}
+	data, err := readBody(c)
+	if errors.Is(err, errBodyTooLarge) {
+		return err
+	}
+	if err != nil {
+		return errors.New("the body could not be read")
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return io.EOF
+	}
+	if data[0] != '{' {
+		return errors.New("the body must be a JSON object")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if err := decoder.Decode(obj); err != nil {
+		return describeJSONError(err)
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return errors.New("unexpected data after the JSON object")
+	}
+	if err := binding.Validator.ValidateStruct(obj); err != nil {
+		return describeValidationError(err)
+	}
+	return nil
+}
+
+// describeJSONError turns a decoding error into a message for clients
+func describeJSONError(err error) error {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntaxErr):
+		return fmt.Errorf("malformed JSON at byte %d", syntaxErr.Offset)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return fmt.Errorf("%s must be of type %s, not %s", typeErr.Field, jsonType(typeErr.Type.String()), typeErr.Value)
+	case errors.As(err, &typeErr):
+		return errors.New("the body must be a JSON object")
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return errors.New("the JSON object is incomplete")
+	}
+	return errors.New("the body is not valid JSON")
+}
+
+// jsonType names a Go type the way the JSON it accepts is called
+func jsonType(goType string) string {
+	switch goType {
+	case "int", "int64", "int32", "uint", "uint64":
+		return "integer"
+	case "float64", "float32":
+		return "number"
+	case "bool":
+		return "boolean"
+	}
+	if len(goType) > 2 && goType[:2] == "[]" {
+		return "array"
+	}
+	if len(goType) > 4 && goType[:4] == "map[" {
+		return "object"
+	}
+	return goType
+}
+
+// describeValidationError names the first field that breaks a binding rule
+func describeValidationError(err error) error {
+	var fieldErrors validator.ValidationErrors
+	if !errors.As(err, &fieldErrors) || len(fieldErrors) == 0 {
+		return errors.New("the body is invalid")
+	}
+	field := fieldErrors[0]
+	if field.Tag() == "required" {
+		return fmt.Errorf("%s is required", field.Field())
+	}
+	return fmt.Errorf("%s fails the %s=%s rule", field.Field(), field.Tag(), field.Param())
+}
+
+// bindErrorDetails explains why bindJSON failed
+func bindErrorDetails(err error) string {
+	if errors.Is(err, io.EOF) {
+		return "a JSON body is required"
+	}
+	return err.Error()
+}
+
+// invalidRequest answers a request whose body could not be bound. The error
+// says why, unless it is nil because the body decoded but its values are
+// unusable. Bodies beyond the limit are answered with 413.
+func invalidRequest(c *gin.Context, err error) {
+	if errors.Is(err, errBodyTooLarge) {
+		bodyTooLarge(c)
+		return
+	}
+	response := gin.H{"error": "Invalid request format"}
+	if err != nil {
+		response["details"] = bindErrorDetails(err)
+	}
+	c.JSON(http.StatusBadRequest, response)
+}
@@ -0,0 +1,193 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bindBody runs bindJSON on a request with the given body
+func bindBody(body string, obj interface{}) error {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/", bytes.NewBufferString(body))
+	return bindJSON(c, obj)
+}
+
+func TestBindJSON(t *testing.T) {
+	var move MoveRequest
+	require.NoError(t, bindBody(` {"direction": "up", "unknown": 1} `, &move))
+	assert.Equal(t, "up", move.Direction)
+
+	for body, details := range map[string]string{
+		``:                         "EOF",
+		`null`:                     "the body must be a JSON object",
+		`["up"]`:                   "the body must be a JSON object",
+		`{"direction": "up"} {}`:   "unexpected data after the JSON object",
+		`{"direction": `:           "the JSON object is incomplete",
+		`{"direction": up}`:        "malformed JSON at byte 15",
+		`{"energy": "full"}`:       "energy must be of type integer, not string",
+		`{"position": {"x": 1.5}}`: "position.x must be of type integer, not number 1.5",
+	} {
+		var state StateUpdateRequest
+		err := bindBody(body, &state)
+		require.Error(t, err, body)
+		assert.Equal(t, details, err.Error(), body)
+	}
+
+	var artifact struct {
+		Version string `json:"version" binding:"required"`
+	}
+	err := bindBody(`{}`, &artifact)
+	require.Error(t, err)
+	assert.Equal(t, "Version is required", err.Error())
+}
+
+func TestBodyLimit(t *testing.T) {
+	router, _ := setupTestRouter()
+	w := send(router, "POST", "/robot/robot1/move", `{"direction": "up", "padding": "`+strings.Repeat("x", defaultMaxBodyBytes)+`"}`)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+
+	limited := gin.New()
+	limited.Use(LimitBody(32))
+	limited.POST("/move", func(c *gin.Context) {
+		var move MoveRequest
+		if err := bindJSON(c, &move); err != nil {
+			invalidRequest(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	})
+	assert.Equal(t, http.StatusNoContent, send(limited, "POST", "/move", `{"direction": "up"}`).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, send(limited, "POST", "/move", `{"direction": "up", "padding": "xxxxxxxxxx"}`).Code)
+}
+
+func TestInvalidRequestDetails(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := send(router, "POST", "/robot/robot1/move", `{"direction": 1}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "Invalid request format", "details": "direction must be of type string, not number"}`, w.Body.String())
+
+	w = send(router, "POST", "/robot/robot1/move", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error": "Invalid request format", "details": "a JSON body is required"}`, w.Body.String())
+
+	// null used to bind as an empty request
+	w = send(router, "PATCH", "/robot/robot1/state", "null")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPaginationRejectsInvalidValues(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	assert.Equal(t, http.StatusOK, send(router, "GET", "/robot/robot1/actions?page=1&size=5", "").Code)
+	assert.Equal(t, http.StatusOK, send(router, "GET", "/robot/robot1/actions", "").Code)
+	for _, query := range []string{"page=0", "page=-1", "page=abc", "page=", "size=0", "size=x", "size=1001", "page=99999999999999999999"} {
+		w := send(router, "GET", "/robot/robot1/actions?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		assert.Contains(t, w.Body.String(), "must be", query)
+	}
+}
+
+// FuzzBindJSON checks that no body makes the decoder panic and that every
+// rejection carries a message
+func FuzzBindJSON(f *testing.F) {
+	for _, seed := range []string{``, `null`, `{}`, `{"energy": 5, "position": {"x": 1, "y": 2}}`, `{"energy": 1e400}`, `{"position": null}`, `[{}]`, `{"a":`} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, body string) {
+		var targets = []interface{}{&MoveRequest{}, &StateUpdateRequest{}, &CreateRobotRequest{}, &map[string]interface{}{}}
+		for _, target := range targets {
+			if err := bindBody(body, target); err != nil && err.Error() == "" {
+				t.Fatalf("empty error for %q", body)
+			}
+		}
+	})
+}
+
+// FuzzPagination checks that the pagination parameters either answer 400 or
+// give slice bounds inside the list
+func FuzzPagination(f *testing.F) {
+	f.Add("1", "5", 12)
+	f.Add("3", "5", 12)
+	f.Add("0", "", 0)
+	f.Add("-7", "1000", 3)
+	f.Add("9223372036854775807", "1000", 1)
+	f.Fuzz(func(t *testing.T, page, size string, total int) {
+		if total < 0 || total > 10000 {
+			return
+		}
+		gin.SetMode(gin.TestMode)
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		query := url.Values{"page": {page}, "size": {size}}
+		c.Request = httptest.NewRequest("GET", "/?"+query.Encode(), nil)
+
+		parsedPage, parsedSize, ok := parsePagination(c, 5)
+		if !ok {
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("rejected with %d", w.Code)
+			}
+			return
+		}
+		info, start, end := paginate(total, parsedPage, parsedSize)
+		if start < 0 || start > end || end > total {
+			t.Fatalf("page %q size %q of %d: bounds %d..%d", page, size, total, start, end)
+		}
+		if info.Number < 1 {
+			t.Fatalf("page number %d", info.Number)
+		}
+	})
+}
+
+// FuzzPathParams sends arbitrary path parameters to the routes that parse
+// them; none may fail with a server error
+func FuzzPathParams(f *testing.F) {
+	f.Add("robot1", "0", "1")
+	f.Add("", "-1", "x")
+	f.Add("%00", "99999999999999999999", "1e3")
+	f.Add("../robot2", " 1", "0x10")
+	f.Fuzz(func(t *testing.T, id, x, y string) {
+		router, _, _ := setupMapRouter()
+		paths := []string{
+			"/robot/" + url.PathEscape(id) + "/move",
+			"/admin/world/obstacles/" + url.PathEscape(x) + "/" + url.PathEscape(y),
+		}
+		for _, path := range paths {
+			for _, method := range []string{"POST", "PUT", "DELETE"} {
+				w := send(router, method, path, `{"direction": "up"}`)
+				if w.Code >= 500 {
+					t.Fatalf("%s %s: %d %s", method, path, w.Code, w.Body.String())
+				}
+			}
+		}
+	})
+}
+
+// FuzzMoveBody checks that a move either succeeds or is rejected with a
+// structured error
+func FuzzMoveBody(f *testing.F) {
+	for _, seed := range []string{`{"direction": "up"}`, `{"direction": "sideways"}`, `{"direction": null}`, `{}`, `"up"`, ``} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, body string) {
+		router, _, _ := setupMapRouter()
+		w := send(router, "POST", "/robot/robot1/move", body)
+		if w.Code == http.StatusOK {
+			return
+		}
+		var response map[string]interface{}
+		if w.Code >= 500 || json.Unmarshal(w.Body.Bytes(), &response) != nil || response["error"] == nil {
+			t.Fatalf("%q: %d %s", body, w.Code, w.Body.String())
+		}
+	})
+}
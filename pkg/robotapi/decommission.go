@@ -0,0 +1,386 @@
+package robotapi
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Decommissioning states
+const (
+	DecommissionPending  = "pending"  // frozen, archived once the grace period is over
+	DecommissionArchived = "archived" // removed from the world, the bundle is kept until it expires
+)
+
+// Ways the export bundle of a decommissioned robot is delivered
+const (
+	ExportDownload = "download"
+	ExportWebhook  = "webhook"
+)
+
+// DefaultDecommissionGrace is how long decommissioned robots stay frozen in
+// the world before they are archived
+const DefaultDecommissionGrace = 24 * time.Hour
+
+// DefaultDecommissionRetention is how long the bundles of archived robots
+// are kept
+const DefaultDecommissionRetention = 30 * 24 * time.Hour
+
+// ErrDecommissionNotFound is returned for unknown decommissioning IDs
+var ErrDecommissionNotFound = errors.New("decommissioning not found")
+
+// Decommission records the retirement of a robot. The robot is frozen right
+// away and taken out of its world once the grace period is over; its export
+// bundle stays available for the retention period after that.
+type Decommission struct {
+	ID         string     `json:"id"`
+	RobotID    string     `json:"robot_id"`
+	Tenant     string     `json:"tenant,omitempty"`
+	By         string     `json:"by,omitempty"` // principal that decommissioned the robot
+	Delivery   string     `json:"delivery"`
+	Status     string     `json:"status"`
+	Checksum   string     `json:"checksum"` // SHA-256 of the export bundle
+	CreatedAt  time.Time  `json:"created_at"`
+	ArchiveAt  time.Time  `json:"archive_at"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // when the bundle is deleted, set once archived
+
+	token  string // secret of the download link
+	bundle []byte
+}
+
+// DecommissionRequest is the optional payload of the decommission endpoint
+type DecommissionRequest struct {
+	Delivery string `json:"delivery"` // "download" (default) or "webhook"
+}
+
+// DecommissionBundle is everything exported about a decommissioned robot
+type DecommissionBundle struct {
+	RobotID    string      `json:"robot_id"`
+	Tenant     string      `json:"tenant,omitempty"`
+	ExportedAt time.Time   `json:"exported_at"`
+	State      *Robot      `json:"state"` // without the actions, they are in History
+	History    []Action    `json:"history"`
+	Stats      *RobotStats `json:"stats,omitempty"` // nil if the robot never caused an event
+}
+
+// DecommissionStore keeps track of decommissioned robots and their bundles.
+// The times it records come from the clock of the robot's world.
+type DecommissionStore struct {
+	decommissions map[string]*Decommission
+	grace         time.Duration
+	retention     time.Duration
+	ids           IDGenerator
+	mutex         sync.Mutex
+}
+
+// NewDecommissionStore creates an empty store whose robots are archived
+// after the grace period. Bundles are kept for DefaultDecommissionRetention
+// after that.
+func NewDecommissionStore(grace time.Duration) *DecommissionStore {
+	return &DecommissionStore{
+		decommissions: make(map[string]*Decommission),
+		grace:         grace,
+		retention:     DefaultDecommissionRetention,
+		ids:           UUIDGenerator{},
+	}
+}
+
+// SetGrace sets the grace period of decommissionings started from now on
+func (s *DecommissionStore) SetGrace(grace time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.grace = grace
+}
+
+// SetRetention sets how long bundles are kept after their robot was
+// archived, for robots archived from now on
+func (s *DecommissionStore) SetRetention(retention time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.retention = retention
+}
+
+// Start registers a pending decommissioning with its export bundle at the
+// given time and returns it with the token of the download link
+func (s *DecommissionStore) Start(decommission Decommission, bundle []byte, now time.Time) (Decommission, string, error) {
+	token, err := randomSecret()
+	if err != nil {
+		return Decommission{}, "", err
+	}
+	checksum := sha256.Sum256(bundle)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	decommission.ID = s.ids.NewID()
+	decommission.Status = DecommissionPending
+	decommission.Checksum = hex.EncodeToString(checksum[:])
+	decommission.CreatedAt = now.UTC()
+	decommission.ArchiveAt = decommission.CreatedAt.Add(s.grace)
+	decommission.token = token
+	decommission.bundle = bundle
+	s.decommissions[decommission.ID] = &decommission
+	return decommission, token, nil
+}
+
+// Bundle returns a decommissioning and its export bundle to the holder of
+// the download link's token
+func (s *DecommissionStore) Bundle(id, token string) (Decommission, []byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	decommission, exists := s.decommissions[id]
+	if !exists || subtle.ConstantTimeCompare([]byte(decommission.token), []byte(token)) != 1 {
+		return Decommission{}, nil, ErrDecommissionNotFound
+	}
+	return *decommission, decommission.bundle, nil
+}
+
+// Export returns the export bundle of a decommissioning, for deliveries
+// that do not go through the download link
+func (s *DecommissionStore) Export(id string) ([]byte, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	decommission, exists := s.decommissions[id]
+	if !exists {
+		return nil, ErrDecommissionNotFound
+	}
+	return decommission.bundle, nil
+}
+
+// Due returns the pending decommissionings whose grace period is over at
+// the given time
+func (s *DecommissionStore) Due(now time.Time) []Decommission {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	due := []Decommission{}
+	for _, decommission := range s.decommissions {
+		if decommission.Status == DecommissionPending && !now.Before(decommission.ArchiveAt) {
+			due = append(due, *decommission)
+		}
+	}
+	return due
+}
+
+// Archive marks a pending decommissioning as archived at the given time
+// once archive, which takes the robot out of its world, succeeded
+func (s *DecommissionStore) Archive(id string, now time.Time, archive func(decommission Decommission) error) (Decommission, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	decommission, exists := s.decommissions[id]
+	if !exists || decommission.Status != DecommissionPending {
+		return Decommission{}, ErrDecommissionNotFound
+	}
+	if err := archive(*decommission); err != nil {
+		return *decommission, err
+	}
+	archivedAt := now.UTC()
+	expiresAt := archivedAt.Add(s.retention)
+	decommission.Status = DecommissionArchived
+	decommission.ArchivedAt = &archivedAt
+	decommission.ExpiresAt = &expiresAt
+	return *decommission, nil
+}
+
+// Expire deletes the archived decommissionings whose retention period is
+// over at the given time, together with their bundles, and returns them
+func (s *DecommissionStore) Expire(now time.Time) []Decommission {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	expired := []Decommission{}
+	for id, decommission := range s.decommissions {
+		if decommission.ExpiresAt != nil && !now.Before(*decommission.ExpiresAt) {
+			expired = append(expired, *decommission)
+			delete(s.decommissions, id)
+		}
+	}
+	return expired
+}
+
+// errDecommissionForbidden is returned when somebody else than the owner
+// decommissions a robot
+var errDecommissionForbidden = errors.New("only the owner can decommission the robot")
+
+// SetDecommissionGrace sets how long decommissioned robots stay frozen
+// before they are archived
+func (h *RobotHandler) SetDecommissionGrace(grace time.Duration) {
+	h.decommissions.SetGrace(grace)
+}
+
+// SetDecommissionRetention sets how long the bundles of archived robots are
+// kept
+func (h *RobotHandler) SetDecommissionRetention(retention time.Duration) {
+	h.decommissions.SetRetention(retention)
+}
+
+// DecommissionRobot retires the robot in the :id path parameter. The robot
+// is frozen in maintenance, and an export bundle with its state, its full
+// history and its statistics is made available through a download link.
+// With webhook delivery the bundle is sent to the configured webhooks as
+// well. The robot is archived after the grace period, the bundle expires
+// after the retention period. Only the owner can decommission a robot.
+func (h *RobotHandler) DecommissionRobot(c *gin.Context) {
+	request := DecommissionRequest{Delivery: ExportDownload}
+	if err := bindJSON(c, &request); err != nil && !errors.Is(err, io.EOF) {
+		invalidRequest(c, err)
+		return
+	}
+	switch request.Delivery {
+	case ExportDownload:
+	case ExportWebhook:
+		// Only the default world's events reach the webhooks
+		if !h.outbox.HasSinks() || currentTenant(c) != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No webhooks receive the events of this world"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "delivery must be download or webhook"})
+		return
+	}
+
+	principal := currentPrincipal(c)
+	robot, err := h.world(c).UpdateRobot(c.Param("id"), func(robot *Robot) error {
+		// Checked under the world's lock, so the robot cannot change owners in between
+		if principal != nil && robot.Owner != principal.ID {
+			return errDecommissionForbidden
+		}
+		if robot.Decommissioned {
+			return errActionNotAllowed(errors.New("robot is decommissioned"))
+		}
+		robot.Decommissioned = true
+		robot.Maintenance = true
+		robot.Velocity = nil
+		robot.Commanded = nil
+		appendCommandAction(robot, requestOrigin(c), "decommission", "Decommissioned", gin.H{"delivery": request.Delivery})
+		return nil
+	})
+	if errors.Is(err, errDecommissionForbidden) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can decommission the robot"})
+		return
+	}
+	if err != nil {
+		updateFailed(c, err)
+		return
+	}
+
+	now := h.world(c).Clock().Now()
+	bundle := DecommissionBundle{
+		RobotID:    robot.ID,
+		Tenant:     currentTenant(c),
+		ExportedAt: now.UTC(),
+		State:      robot.clone(),
+		History:    robot.Actions,
+	}
+	bundle.State.Actions = nil
+	if stats, exists := h.analytics.For(h.bus(c)).Stats(robot.ID); exists {
+		bundle.Stats = &stats
+	}
+	encoded, err := json.Marshal(bundle)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export the robot"})
+		return
+	}
+
+	decommission := Decommission{RobotID: robot.ID, Tenant: currentTenant(c), Delivery: request.Delivery}
+	if principal != nil {
+		decommission.By = principal.ID
+	}
+	decommission, token, err := h.decommissions.Start(decommission, encoded, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export the robot"})
+		return
+	}
+
+	h.bus(c).PublishCommand(commandID(c), "robot_decommissioned", robot.ID,
+		gin.H{"decommission": decommission.ID, "delivery": decommission.Delivery, "archive_at": decommission.ArchiveAt})
+	if decommission.Delivery == ExportWebhook {
+		// The event log only gets a reference, the webhooks get the bundle
+		// attached by AttachBundle. The download link needs the token of
+		// the response.
+		h.bus(c).PublishCommand(commandID(c), "robot_exported", robot.ID, gin.H{
+			"decommission": decommission.ID,
+			"checksum":     decommission.Checksum,
+			"links": []Link{
+				{Rel: "bundle", Href: fmt.Sprintf("%s/decommissions/%s/bundle", requestBaseURL(c), decommission.ID)},
+			},
+		})
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"decommission": decommission,
+		"links": []Link{
+			{Rel: "bundle", Href: fmt.Sprintf("%s/decommissions/%s/bundle?token=%s", requestBaseURL(c), decommission.ID, token)},
+		},
+	})
+}
+
+// GetDecommissionBundle downloads the export bundle of a decommissioned
+// robot. The link carries the token, so it works without credentials, also
+// after the robot was archived.
+func (h *RobotHandler) GetDecommissionBundle(c *gin.Context) {
+	decommission, bundle, err := h.decommissions.Bundle(c.Param("decommissionId"), c.Query("token"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Decommissioning not found"})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="robot-%s.json"`, decommission.RobotID))
+	c.Header("ETag", `"`+decommission.Checksum+`"`)
+	c.Data(http.StatusOK, "application/json", bundle)
+}
+
+// AttachBundle adds the export bundle to robot_exported events on their way
+// to the webhooks. It is registered with Outbox.SetAttachments.
+func (h *RobotHandler) AttachBundle(event Event) Event {
+	data, ok := event.Data.(gin.H)
+	if event.Type != "robot_exported" || !ok {
+		return event
+	}
+	id, _ := data["decommission"].(string)
+	bundle, err := h.decommissions.Export(id)
+	if err != nil {
+		return event
+	}
+	attached := gin.H{"bundle": json.RawMessage(bundle)}
+	for key, value := range data {
+		attached[key] = value
+	}
+	event.Data = attached
+	return event
+}
+
+// TickDecommissions archives the decommissioned robots whose grace period
+// is over, so they are taken out of their world for good, and deletes the
+// bundles whose retention period is over
+func (h *RobotHandler) TickDecommissions(tick int64) {
+	now := h.storage.Clock().Now()
+	for _, due := range h.decommissions.Due(now) {
+		archived, err := h.decommissions.Archive(due.ID, now, func(decommission Decommission) error {
+			_, err := h.worldOf(decommission.Tenant).RemoveRobot(decommission.RobotID)
+			if errors.Is(err, ErrRobotNotFound) {
+				// Gone already, e.g. restored from a snapshot without it
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			continue
+		}
+		h.behaviors.Delete(archived.Tenant, archived.RobotID)
+		h.busOf(archived.Tenant).Publish("robot_archived", archived.RobotID, gin.H{"decommission": archived.ID})
+	}
+	h.decommissions.Expire(now)
+}
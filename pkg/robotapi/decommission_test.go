@@ -0,0 +1,177 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupDecommissions returns a router with the decommission endpoints on
+// top of the map router
+func setupDecommissions() (*gin.Engine, *RobotHandler, *RobotStorage, *EventBus) {
+	router, storage, events := setupMapRouter()
+	handler := NewRobotHandler(storage)
+	handler.SetEventBus(events)
+	router.POST("/robot/:id/decommission", handler.DecommissionRobot)
+	router.POST("/robot/:id/maintenance", handler.SetMaintenance)
+	router.PATCH("/robot/:id/state", handler.UpdateState)
+	router.GET("/decommissions/:decommissionId/bundle", handler.GetDecommissionBundle)
+	return router, handler, storage, events
+}
+
+type decommissionResponse struct {
+	Decommission Decommission `json:"decommission"`
+	Links        []Link       `json:"links"`
+}
+
+func eventTypes(events *EventBus) []string {
+	var types []string
+	for _, event := range events.Since(0) {
+		types = append(types, event.Type)
+	}
+	return types
+}
+
+func TestDecommissionFreezesExportsAndArchives(t *testing.T) {
+	router, handler, storage, events := setupDecommissions()
+	clock := NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	storage.SetClock(clock)
+	send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`)
+
+	w := send(router, "POST", "/robot/robot1/decommission", "")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response decommissionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	decommission := response.Decommission
+	assert.Equal(t, DecommissionPending, decommission.Status)
+	assert.Equal(t, ExportDownload, decommission.Delivery)
+	assert.Equal(t, clock.Now(), decommission.CreatedAt)
+	assert.Equal(t, decommission.CreatedAt.Add(DefaultDecommissionGrace), decommission.ArchiveAt)
+	require.Len(t, response.Links, 1)
+	assert.Equal(t, "bundle", response.Links[0].Rel)
+	assert.Contains(t, eventTypes(events), "robot_decommissioned")
+
+	// The robot is frozen
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot1/maintenance", `{"enabled": false}`).Code)
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot1/decommission", "").Code)
+	assert.Equal(t, http.StatusConflict, send(router, "PATCH", "/robot/robot1/state", `{"energy": 50, "position": {"x": 5, "y": 5}}`).Code)
+	robot, err := storage.GetRobot("robot1")
+	require.NoError(t, err)
+	assert.True(t, robot.Decommissioned)
+	assert.True(t, robot.Maintenance)
+
+	// The download link carries its token
+	bundlePath := strings.TrimPrefix(response.Links[0].Href, "http://")
+	assert.Equal(t, http.StatusNotFound, send(router, "GET", "/decommissions/"+decommission.ID+"/bundle?token=guessed", "").Code)
+	w = send(router, "GET", bundlePath, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "robot-robot1.json")
+	var bundle DecommissionBundle
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &bundle))
+	assert.Equal(t, "robot1", bundle.RobotID)
+	assert.Equal(t, Position{X: 0, Y: 1}, bundle.State.Position)
+	assert.Empty(t, bundle.State.Actions)
+	require.NotEmpty(t, bundle.History)
+	assert.Equal(t, "decommission", bundle.History[len(bundle.History)-1].Type)
+
+	// Nothing happens before the grace period is over
+	clock.Advance(DefaultDecommissionGrace - time.Second)
+	handler.TickDecommissions(1)
+	_, err = storage.GetRobot("robot1")
+	assert.NoError(t, err)
+
+	clock.Advance(time.Second)
+	handler.TickDecommissions(2)
+	_, err = storage.GetRobot("robot1")
+	assert.ErrorIs(t, err, ErrRobotNotFound)
+	assert.Contains(t, eventTypes(events), "robot_archived")
+
+	// The bundle outlives the robot
+	assert.Equal(t, http.StatusOK, send(router, "GET", bundlePath, "").Code)
+	archived, _, err := handler.decommissions.Bundle(decommission.ID, strings.SplitN(bundlePath, "token=", 2)[1])
+	require.NoError(t, err)
+	assert.Equal(t, DecommissionArchived, archived.Status)
+	require.NotNil(t, archived.ArchivedAt)
+	assert.Equal(t, decommission.ArchiveAt, *archived.ArchivedAt)
+	require.NotNil(t, archived.ExpiresAt)
+	assert.Equal(t, archived.ArchivedAt.Add(DefaultDecommissionRetention), *archived.ExpiresAt)
+
+	// ... until the retention period is over
+	clock.Advance(DefaultDecommissionRetention - time.Second)
+	handler.TickDecommissions(3)
+	assert.Equal(t, http.StatusOK, send(router, "GET", bundlePath, "").Code)
+	clock.Advance(time.Second)
+	handler.TickDecommissions(4)
+	assert.Equal(t, http.StatusNotFound, send(router, "GET", bundlePath, "").Code)
+	assert.Empty(t, handler.decommissions.decommissions)
+}
+
+func TestDecommissionSettingsKeepTheStore(t *testing.T) {
+	router, handler, _, _ := setupDecommissions()
+	require.Equal(t, http.StatusAccepted, send(router, "POST", "/robot/robot1/decommission", "").Code)
+
+	handler.SetDecommissionGrace(time.Minute)
+	handler.SetDecommissionRetention(time.Hour)
+	assert.Len(t, handler.decommissions.decommissions, 1, "pending decommissionings are not forgotten")
+	w := send(router, "POST", "/robot/robot2/decommission", "")
+	require.Equal(t, http.StatusAccepted, w.Code)
+	var response decommissionResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, response.Decommission.CreatedAt.Add(time.Minute), response.Decommission.ArchiveAt)
+}
+
+func TestDecommissionWebhookDelivery(t *testing.T) {
+	router, handler, _, events := setupDecommissions()
+
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/robot/robot1/decommission", `{"delivery": "fax"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/robot/robot1/decommission", `{"delivery": "webhook"}`).Code,
+		"no webhooks are configured")
+	robot, _ := handler.storage.GetRobot("robot1")
+	assert.False(t, robot.Decommissioned, "rejected requests freeze nothing")
+
+	outbox := NewOutbox([]Sink{NewWebhookSink("http://hooks.invalid/")}, 1)
+	outbox.SetAttachments(handler.AttachBundle)
+	events.OnPublish(outbox.Record)
+	handler.SetOutbox(outbox)
+	assert.Equal(t, http.StatusAccepted, send(router, "POST", "/robot/robot1/decommission", `{"delivery": "webhook"}`).Code)
+
+	var exported *Event
+	for _, delivery := range outbox.List(DeliveryPending) {
+		if delivery.Event.Type == "robot_exported" {
+			exported = &delivery.Event
+		}
+	}
+	require.NotNil(t, exported, "the bundle goes out to the webhooks")
+	encoded, _ := json.Marshal(exported.Data)
+	assert.Contains(t, string(encoded), `"history"`)
+
+	// The event log only has a reference to the bundle
+	for _, event := range events.Since(0) {
+		if event.Type == "robot_exported" {
+			encoded, _ = json.Marshal(event.Data)
+			assert.NotContains(t, string(encoded), `"history"`)
+			assert.Contains(t, string(encoded), "/bundle")
+			assert.NotContains(t, string(encoded), "token")
+		}
+	}
+	_, shown := spectatorView(*exported)
+	assert.False(t, shown, "spectators do not see the bundle")
+}
+
+func TestOnlyTheOwnerDecommissions(t *testing.T) {
+	router := setupTransferServer(t)
+	require.Equal(t, http.StatusCreated, sendAs(router, "alice-key", "", "POST", "/robots", `{"id": "rover"}`).Code)
+	require.Equal(t, http.StatusOK, sendAs(router, "alice-key", "", "POST", "/robot/rover/permissions", `{"grantee": "bob", "permissions": ["update"]}`).Code)
+
+	assert.Equal(t, http.StatusForbidden, sendAs(router, "bob-key", "", "POST", "/robot/rover/decommission", "").Code)
+	assert.NotContains(t, sendAs(router, "alice-key", "", "GET", "/robot/rover/status", "").Body.String(), `"decommissioned"`)
+	assert.Equal(t, http.StatusNotFound, sendAs(router, "alice-key", "", "POST", "/robot/ghost/decommission", "").Code)
+	assert.Equal(t, http.StatusAccepted, sendAs(router, "alice-key", "", "POST", "/robot/rover/decommission", "").Code)
+}
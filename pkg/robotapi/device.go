@@ -0,0 +1,152 @@
+package robotapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultDeviceTimeout bounds device commands whose caller has no deadline
+const DefaultDeviceTimeout = 5 * time.Second
+
+var (
+	// ErrRobotBusy is returned for device commands on a robot whose device
+	// is still carrying out another one
+	ErrRobotBusy = errors.New("Robot is busy with another device command")
+	// ErrRobotChanged is returned if a robot changed while its device
+	// carried out a command; the new state is not stored
+	ErrRobotChanged = errors.New("Robot changed while the device carried out the command")
+)
+
+// DeviceAdapter carries out validated commands on the robot itself. The
+// handlers reserve the robot once all checks passed and call the adapter
+// without holding the storage lock, so a slow device stalls neither other
+// requests nor the simulation. The new state is stored afterwards; a
+// failing device leaves the robot unchanged. The context always has a
+// deadline, hardware implementations (serial, Modbus, CAN) must respect it.
+type DeviceAdapter interface {
+	Name() string
+	Move(ctx context.Context, robot *Robot, target Position) error
+	Pickup(ctx context.Context, robot *Robot, itemID string) error
+	Putdown(ctx context.Context, robot *Robot, itemID string) error
+}
+
+// DeviceError reports a command the device failed to carry out
+type DeviceError struct {
+	Device string
+	Err    error
+}
+
+func (e *DeviceError) Error() string {
+	return fmt.Sprintf("Device %s failed: %v", e.Device, e.Err)
+}
+
+func (e *DeviceError) Unwrap() error {
+	return e.Err
+}
+
+// runOnDevice calls the device and wraps its error
+func runOnDevice(device DeviceAdapter, command func() error) error {
+	if err := command(); err != nil {
+		return &DeviceError{Device: device.Name(), Err: err}
+	}
+	return nil
+}
+
+// Reserve checks a device command on a copy of the robot and marks the
+// robot busy until Release. It returns the copy and the robot's version,
+// which commits compare with robotVersion.
+func (s *RobotStorage) Reserve(id string, check func(robot *Robot) error) (*Robot, uint64, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stored, exists := s.robots[id]
+	if !exists {
+		return nil, 0, ErrRobotNotFound
+	}
+	if s.reserved[id] {
+		return nil, 0, ErrRobotBusy
+	}
+	robot := stored.clone()
+	if err := check(robot); err != nil {
+		return nil, 0, err
+	}
+	s.reserved[id] = true
+	return robot, s.robotVersions[id], nil
+}
+
+// Release ends the reservation of a robot
+func (s *RobotStorage) Release(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.reserved, id)
+}
+
+// robotVersion returns the version of a robot's last change; callers must
+// hold the lock
+func (s *RobotStorage) robotVersion(id string) uint64 {
+	return s.robotVersions[id]
+}
+
+// runDeviceCommand carries out a command on a robot's device in three
+// steps. check validates the command while the robot is reserved, the
+// device runs outside the storage lock within the device timeout, and
+// commit stores the new state. The update commit gets first verifies that
+// the robot did not change meanwhile and checks the command again.
+func (h *RobotHandler) runDeviceCommand(ctx context.Context, world *RobotStorage, id string, check func(robot *Robot) error,
+	command func(ctx context.Context, robot *Robot) error, commit func(verify func(robot *Robot) error) (*Robot, error)) (*Robot, error) {
+	robot, version, err := world.Reserve(id, check)
+	if err != nil {
+		return nil, err
+	}
+	defer world.Release(id)
+
+	ctx, cancel := deviceContext(ctx)
+	defer cancel()
+	if err := runOnDevice(h.device, func() error {
+		return command(ctx, robot)
+	}); err != nil {
+		return nil, err
+	}
+
+	return commit(func(robot *Robot) error {
+		if world.robotVersion(id) != version {
+			return ErrRobotChanged
+		}
+		return check(robot)
+	})
+}
+
+// deviceContext bounds ctx by the device timeout unless it has an earlier
+// deadline
+func deviceContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, set := ctx.Deadline(); set && time.Until(deadline) < DefaultDeviceTimeout {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, DefaultDeviceTimeout)
+}
+
+// SimulatedDevice is the default adapter: the in-memory world is the only
+// robot there is, so every command succeeds
+type SimulatedDevice struct{}
+
+// Name returns the name of the adapter
+func (SimulatedDevice) Name() string {
+	return "simulation"
+}
+
+// Move succeeds immediately
+func (SimulatedDevice) Move(ctx context.Context, robot *Robot, target Position) error {
+	return nil
+}
+
+// Pickup succeeds immediately
+func (SimulatedDevice) Pickup(ctx context.Context, robot *Robot, itemID string) error {
+	return nil
+}
+
+// Putdown succeeds immediately
+func (SimulatedDevice) Putdown(ctx context.Context, robot *Robot, itemID string) error {
+	return nil
+}
@@ -0,0 +1,143 @@
+package robotapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// recordingDevice logs the commands it gets and fails while broken is set
+type recordingDevice struct {
+	commands []string
+	broken   bool
+}
+
+func (d *recordingDevice) Name() string {
+	return "recording"
+}
+
+func (d *recordingDevice) run(command string) error {
+	if d.broken {
+		return errors.New("no response")
+	}
+	d.commands = append(d.commands, command)
+	return nil
+}
+
+func (d *recordingDevice) Move(ctx context.Context, robot *Robot, target Position) error {
+	return d.run("move " + robot.ID)
+}
+
+func (d *recordingDevice) Pickup(ctx context.Context, robot *Robot, itemID string) error {
+	return d.run("pickup " + itemID)
+}
+
+func (d *recordingDevice) Putdown(ctx context.Context, robot *Robot, itemID string) error {
+	return d.run("putdown " + itemID)
+}
+
+func TestDeviceAdapter(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	device := &recordingDevice{}
+	handler := NewRobotHandler(storage)
+	handler.SetDevice(device)
+	router.POST("/dev/robot/:id/move", handler.MoveRobot)
+	router.POST("/dev/robot/:id/pickup/:itemId", handler.PickupItem)
+	router.POST("/dev/robot/:id/putdown/:itemId", handler.PutdownItem)
+
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/dev/robot/robot1/move", `{"direction": "up"}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/dev/robot/robot1/pickup/item1", "").Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/dev/robot/robot1/putdown/item1", "").Code)
+	assert.Equal(t, []string{"move robot1", "pickup item1", "putdown item1"}, device.commands)
+
+	// Dry runs never reach the device
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/dev/robot/robot1/move?dryRun=true", `{"direction": "up"}`).Code)
+	assert.Len(t, device.commands, 3)
+
+	// A failing device leaves the robot where it was
+	before, _ := storage.GetRobot("robot1")
+	device.broken = true
+	w := send(router, "POST", "/dev/robot/robot1/move", `{"direction": "up"}`)
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), "Device recording failed: no response")
+	after, _ := storage.GetRobot("robot1")
+	assert.Equal(t, before.Position, after.Position)
+	assert.Equal(t, before.Energy, after.Energy)
+}
+
+// blockingDevice holds every move until it is released
+type blockingDevice struct {
+	started  chan struct{}
+	release  chan struct{}
+	deadline bool // whether the last command had a deadline
+}
+
+func (d *blockingDevice) Name() string {
+	return "blocking"
+}
+
+func (d *blockingDevice) Move(ctx context.Context, robot *Robot, target Position) error {
+	_, d.deadline = ctx.Deadline()
+	d.started <- struct{}{}
+	<-d.release
+	return nil
+}
+
+func (d *blockingDevice) Pickup(ctx context.Context, robot *Robot, itemID string) error {
+	return nil
+}
+
+func (d *blockingDevice) Putdown(ctx context.Context, robot *Robot, itemID string) error {
+	return nil
+}
+
+func TestDeviceRunsOutsideTheStorageLock(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	device := &blockingDevice{started: make(chan struct{}), release: make(chan struct{})}
+	handler := NewRobotHandler(storage)
+	handler.SetDevice(device)
+	router.POST("/dev/robot/:id/move", handler.MoveRobot)
+
+	moved := make(chan int)
+	go func() {
+		moved <- send(router, "POST", "/dev/robot/robot1/move", `{"direction": "up"}`).Code
+	}()
+	<-device.started
+	assert.True(t, device.deadline, "device commands always have a deadline")
+
+	// The world stays usable while the device works, only robot1 is busy
+	before, err := storage.GetRobot("robot1")
+	assert.NoError(t, err)
+	_, err = storage.UpdateRobot("robot2", func(robot *Robot) error {
+		robot.Energy--
+		return nil
+	})
+	assert.NoError(t, err)
+	w := send(router, "POST", "/dev/robot/robot1/move", `{"direction": "up"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "busy")
+
+	device.release <- struct{}{}
+	assert.Equal(t, http.StatusOK, <-moved)
+	after, _ := storage.GetRobot("robot1")
+	assert.Equal(t, before.Position.Y+1, after.Position.Y)
+
+	// A robot that changed while its device worked is not overwritten
+	go func() {
+		moved <- send(router, "POST", "/dev/robot/robot1/move", `{"direction": "up"}`).Code
+	}()
+	<-device.started
+	_, err = storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Energy = 50
+		return nil
+	})
+	assert.NoError(t, err)
+	device.release <- struct{}{}
+	assert.Equal(t, http.StatusConflict, <-moved)
+	changed, _ := storage.GetRobot("robot1")
+	assert.Equal(t, after.Position, changed.Position)
+	assert.Equal(t, 50, changed.Energy)
+}
@@ -0,0 +1,158 @@
+package robotapi
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// postmanSchema is the collection format the export follows
+const postmanSchema = "https://schema.getpostman.com/json/collection/v2.1.0/collection.json"
+
+// postmanExamples are the example bodies of the routes that take one, keyed
+// by method and route path without the base path
+var postmanExamples = map[string]string{
+	"POST /robots":                   `{"id": "rover", "name": "Rover", "position": {"x": 3, "y": 3}}`,
+	"POST /robots/status":            `{"ids": ["robot1", "robot2"]}`,
+	"POST /sessions":                 `{"robot_id": "robot1", "ttl_seconds": 900}`,
+	"POST /registrations":            `{"hardware_id": "SN-0042", "name": "Rover", "class": "standard"}`,
+	"POST /simulate/battle":          `{"a": {"id": "robot1"}, "b": {"id": "robot2"}, "battles": 100}`,
+	"POST /robot/:id/move":           `{"direction": "up"}`,
+	"POST /robot/:id/velocity":       `{"x": 1, "y": 0}`,
+	"POST /robot/:id/maintenance":    `{"enabled": true}`,
+	"PATCH /robot/:id/state":         `{"energy": 80}`,
+	"POST /robot/:id/permissions":    `{"grantee": "bob", "permissions": ["move", "scan"]}`,
+	"POST /robot/:id/decommission":   `{"delivery": "download"}`,
+	"PATCH /admin/world/time":        `{"speed": 2}`,
+	"PUT /admin/world/bounds":        `{"min_x": -10, "min_y": -10, "max_x": 10, "max_y": 10}`,
+	"PUT /admin/world/spawns":        `{"spawn_points": [{"x": 0, "y": 0}]}`,
+	"POST /admin/world/generate":     `{"width": 20, "height": 20, "resources": 5, "hazards": 3}`,
+	"POST /admin/world/links":        `{"type": "elevator", "position": {"x": 0, "y": 0}}`,
+	"POST /admin/estop":              `{"reason": "inspection"}`,
+	"POST /admin/rollouts":           `{"version": "1.1.0", "stages": [10, 50, 100]}`,
+	"POST /admin/tenants":            `{"id": "class-a", "limits": {"max_robots": 20}}`,
+	"PUT /admin/tenants/:id/limits":  `{"max_robots": 20, "max_items": 50, "max_actions": 10000}`,
+	"POST /admin/random":             `{"seed": 42}`,
+	"POST /admin/faults":             `{"route": "/robot/:id/move", "type": "error", "percent": 20, "status": 503}`,
+	"POST /robot/:id/custom/:action": `{}`,
+}
+
+// postmanVariables are the defaults of the path parameter variables; other
+// parameters start out empty
+var postmanVariables = map[string]string{
+	"id":       "robot1",
+	"itemId":   "item1",
+	"targetId": "robot2",
+	"x":        "0",
+	"y":        "0",
+	"z":        "0",
+}
+
+// PostmanCollection builds a Postman collection of the routes. Requests are
+// grouped into folders by their first path segment; path parameters become
+// collection variables, and the admin folder authenticates with the admin
+// token instead of the API key.
+func PostmanCollection(routes gin.RoutesInfo, basePath, baseURL string) gin.H {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+		return routes[i].Method < routes[j].Method
+	})
+
+	folders := map[string][]gin.H{}
+	params := map[string]bool{}
+	for _, route := range routes {
+		path := strings.TrimPrefix(route.Path, basePath)
+		if path == "" {
+			path = "/"
+		}
+		segments := strings.Split(strings.Trim(path, "/"), "/")
+		for i, segment := range segments {
+			if name, found := strings.CutPrefix(segment, ":"); found {
+				params[name] = true
+				segments[i] = "{{" + name + "}}"
+			}
+		}
+
+		request := gin.H{
+			"method": route.Method,
+			"header": []gin.H{},
+			"url": gin.H{
+				"raw":  "{{url}}/" + strings.Join(segments, "/"),
+				"host": []string{"{{url}}"},
+				"path": segments,
+			},
+		}
+		if body := postmanExamples[route.Method+" "+path]; body != "" {
+			request["header"] = []gin.H{{"key": "Content-Type", "value": "application/json"}}
+			request["body"] = gin.H{"mode": "raw", "raw": body, "options": gin.H{"raw": gin.H{"language": "json"}}}
+		}
+		folder := segments[0]
+		folders[folder] = append(folders[folder], gin.H{"name": route.Method + " " + path, "request": request, "response": []gin.H{}})
+	}
+
+	// Segments with a single route, like /health, share the general folder
+	names := []string{}
+	for name := range folders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	general, grouped := []gin.H{}, []string{}
+	for _, name := range names {
+		if len(folders[name]) == 1 && name != "admin" {
+			general = append(general, folders[name]...)
+			continue
+		}
+		grouped = append(grouped, name)
+	}
+
+	items := []gin.H{{"name": "general", "item": general}}
+	for _, name := range grouped {
+		folder := gin.H{"name": name, "item": folders[name]}
+		if name == "admin" {
+			folder["auth"] = gin.H{"type": "bearer", "bearer": []gin.H{{"key": "token", "value": "{{adminToken}}", "type": "string"}}}
+		}
+		items = append(items, folder)
+	}
+
+	variables := []gin.H{
+		{"key": "url", "value": baseURL, "type": "string"},
+		{"key": "apiKey", "value": "", "type": "string"},
+		{"key": "adminToken", "value": "", "type": "string"},
+	}
+	paramNames := []string{}
+	for name := range params {
+		paramNames = append(paramNames, name)
+	}
+	sort.Strings(paramNames)
+	for _, name := range paramNames {
+		variables = append(variables, gin.H{"key": name, "value": postmanVariables[name], "type": "string"})
+	}
+
+	return gin.H{
+		"info": gin.H{
+			"name":        "Robot API",
+			"description": "Generated from the routes of the running server.",
+			"schema":      postmanSchema,
+		},
+		"auth": gin.H{"type": "apikey", "apikey": []gin.H{
+			{"key": "key", "value": "X-API-Key", "type": "string"},
+			{"key": "value", "value": "{{apiKey}}", "type": "string"},
+			{"key": "in", "value": "header", "type": "string"},
+		}},
+		"variable": variables,
+		"item":     items,
+	}
+}
+
+// GetPostmanCollection serves a Postman collection of all routes of the
+// router, pointing at the server the request reached
+func GetPostmanCollection(router *gin.Engine, basePath string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Disposition", `attachment; filename="robot-api.postman_collection.json"`)
+		c.JSON(http.StatusOK, PostmanCollection(router.Routes(), basePath, requestBaseURL(c)))
+	}
+}
@@ -0,0 +1,77 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type postmanItem struct {
+	Name    string        `json:"name"`
+	Item    []postmanItem `json:"item"`
+	Auth    *struct{ Type string }
+	Request *struct {
+		Method string `json:"method"`
+		URL    struct {
+			Raw string `json:"raw"`
+		} `json:"url"`
+		Body *struct {
+			Raw string `json:"raw"`
+		} `json:"body"`
+	} `json:"request"`
+}
+
+func TestPostmanCollection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.BasePath = "/robots-api"
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "http://api.example/robots-api/devtools/postman", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var collection struct {
+		Info     struct{ Schema string }
+		Variable []struct{ Key, Value string }
+		Item     []postmanItem
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &collection))
+	assert.Equal(t, postmanSchema, collection.Info.Schema)
+
+	variables := map[string]string{}
+	for _, variable := range collection.Variable {
+		variables[variable.Key] = variable.Value
+	}
+	assert.Equal(t, "http://api.example/robots-api", variables["url"])
+	assert.Equal(t, "robot1", variables["id"])
+	assert.Equal(t, "item1", variables["itemId"])
+
+	// Every route of the server is in the collection
+	requests := map[string]postmanItem{}
+	for _, folder := range collection.Item {
+		for _, item := range folder.Item {
+			requests[item.Name] = item
+		}
+		if folder.Name == "admin" {
+			assert.Equal(t, "bearer", folder.Auth.Type)
+		}
+	}
+	assert.Len(t, requests, len(router.Routes()))
+	for route := range postmanExamples {
+		assert.Contains(t, requests, route, "example of a route that does not exist")
+	}
+
+	move := requests["POST /robot/:id/move"]
+	require.NotNil(t, move.Request)
+	assert.Equal(t, "{{url}}/robot/{{id}}/move", move.Request.URL.Raw)
+	assert.JSONEq(t, `{"direction": "up"}`, move.Request.Body.Raw)
+	assert.Nil(t, requests["GET /health"].Request.Body)
+}
@@ -0,0 +1,241 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Energy sources that are not robot actions
+const (
+	EnergyCharging = "charging" // simulation recharge, by day or solar
+	EnergyHazard   = "hazard"   // drained by standing in a hazard
+	EnergyEffect   = "effect"   // drained by status effects like burning
+	EnergyMotion   = "move"     // driving in continuous mode
+	EnergyOther    = "other"    // changes without an action, e.g. admin edits
+)
+
+// Energy ledger limits
+const (
+	maxEnergyBuckets = 1000  // per report
+	maxEnergyGains   = 10000 // gains kept until they are taken
+)
+
+// EnergyFlow is the energy that went through one source or action type
+type EnergyFlow struct {
+	Energy int `json:"energy"`
+	Count  int `json:"count"` // actions, or ticks that changed energy
+}
+
+// EnergyBucket is the energy economy of one period
+type EnergyBucket struct {
+	Start    time.Time             `json:"start"`
+	Produced map[string]EnergyFlow `json:"produced"`
+	Consumed map[string]EnergyFlow `json:"consumed"` // by action type
+	Net      int                   `json:"net"`
+}
+
+// EnergyReport is the energy economy over a window in buckets
+type EnergyReport struct {
+	Window  string         `json:"window"`
+	Bucket  string         `json:"bucket"`
+	Buckets []EnergyBucket `json:"buckets"` // oldest first, including empty ones
+	Totals  EnergyBucket   `json:"totals"`
+}
+
+// EnergyGain is energy a robot gained other than by charging
+type EnergyGain struct {
+	RobotID string
+	Source  string
+	Amount  int
+	At      time.Time
+}
+
+// energyMinute holds the flows of one minute
+type energyMinute struct {
+	produced map[string]*EnergyFlow
+	consumed map[string]*EnergyFlow
+}
+
+// EnergyLedger counts the energy robots gain and spend per minute, for
+// analyticsRetention
+type EnergyLedger struct {
+	minutes map[int64]*energyMinute // minutes since the Unix epoch
+	latest  int64
+	gains   []EnergyGain // not taken yet, oldest first
+	now     func() time.Time
+	mutex   sync.Mutex
+}
+
+// NewEnergyLedger creates an empty ledger
+func NewEnergyLedger() *EnergyLedger {
+	return &EnergyLedger{minutes: make(map[int64]*energyMinute), now: time.Now}
+}
+
+// Record books a change of a robot's energy: positive amounts were
+// produced by the source, others consumed
+func (l *EnergyLedger) Record(robotID, source string, amount int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	if amount > 0 && source != EnergyCharging {
+		l.gains = append(l.gains, EnergyGain{RobotID: robotID, Source: source, Amount: amount, At: now})
+		if len(l.gains) > maxEnergyGains {
+			l.gains = l.gains[len(l.gains)-maxEnergyGains:]
+		}
+	}
+
+	minute := now.Unix() / 60
+	entry, exists := l.minutes[minute]
+	if !exists {
+		entry = &energyMinute{produced: make(map[string]*EnergyFlow), consumed: make(map[string]*EnergyFlow)}
+		l.minutes[minute] = entry
+	}
+	flows, energy := entry.consumed, -amount
+	if amount > 0 {
+		flows, energy = entry.produced, amount
+	}
+	flow, exists := flows[source]
+	if !exists {
+		flow = &EnergyFlow{}
+		flows[source] = flow
+	}
+	flow.Energy += energy
+	flow.Count++
+
+	if minute > l.latest {
+		l.latest = minute
+		oldest := minute - int64(analyticsRetention/time.Minute)
+		for m := range l.minutes {
+			if m < oldest {
+				delete(l.minutes, m)
+			}
+		}
+	}
+}
+
+// setClock makes the ledger book energy at the clock's time
+func (l *EnergyLedger) setClock(clock Clock) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.now = clock.Now
+}
+
+// TakeGains returns the gains other than charging since the last call
+func (l *EnergyLedger) TakeGains() []EnergyGain {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	gains := l.gains
+	l.gains = nil
+	return gains
+}
+
+// Report sums up the ledger over the window in buckets aligned to the
+// bucket size
+func (l *EnergyLedger) Report(window, bucket time.Duration) EnergyReport {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	first := now.Add(-window).Truncate(bucket)
+	report := EnergyReport{Window: window.String(), Bucket: bucket.String(), Buckets: []EnergyBucket{}, Totals: newEnergyBucket(first)}
+	for start := first; !start.After(now); start = start.Add(bucket) {
+		report.Buckets = append(report.Buckets, newEnergyBucket(start))
+	}
+	for minute, entry := range l.minutes {
+		at := time.Unix(minute*60, 0)
+		if at.Before(first) || at.After(now) {
+			continue
+		}
+		index := int(at.Sub(first) / bucket)
+		for _, target := range []*EnergyBucket{&report.Buckets[index], &report.Totals} {
+			target.add(entry)
+		}
+	}
+	return report
+}
+
+func newEnergyBucket(start time.Time) EnergyBucket {
+	return EnergyBucket{Start: start.UTC(), Produced: make(map[string]EnergyFlow), Consumed: make(map[string]EnergyFlow)}
+}
+
+// add sums the flows of a minute into the bucket
+func (b *EnergyBucket) add(entry *energyMinute) {
+	for source, flow := range entry.produced {
+		sum := b.Produced[source]
+		sum.Energy += flow.Energy
+		sum.Count += flow.Count
+		b.Produced[source] = sum
+		b.Net += flow.Energy
+	}
+	for action, flow := range entry.consumed {
+		sum := b.Consumed[action]
+		sum.Energy += flow.Energy
+		sum.Count += flow.Count
+		b.Consumed[action] = sum
+		b.Net -= flow.Energy
+	}
+}
+
+// EnergyLedger returns the ledger of the world's energy economy
+func (s *RobotStorage) EnergyLedger() *EnergyLedger {
+	return s.energy
+}
+
+// bookEnergy records how the robot's energy changed since before, blaming
+// the source; callers must hold the lock
+func (s *RobotStorage) bookEnergy(robotID, source string, before, after int) {
+	if after != before {
+		s.energy.Record(robotID, source, after-before)
+	}
+}
+
+// energySource names the action that an update appended last, or other
+func energySource(before, after *Robot) string {
+	if len(after.Actions) > len(before.Actions) {
+		return after.Actions[len(after.Actions)-1].Type
+	}
+	return EnergyOther
+}
+
+// GetEnergyReport returns the energy produced and consumed per action type
+// over ?window= (default 24h) in buckets of ?bucket= (default 1h)
+func (h *RobotHandler) GetEnergyReport(c *gin.Context) {
+	window, err := parseWindow(c.Query("window"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if window == 0 {
+		window = 24 * time.Hour
+	}
+	bucket := time.Hour
+	if value := c.Query("bucket"); value != "" {
+		if bucket, err = time.ParseDuration(value); err != nil || bucket < time.Minute || bucket%time.Minute != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "bucket must be a whole number of minutes like 15m"})
+			return
+		}
+	}
+	if err := checkEnergyBuckets(window, bucket); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, h.world(c).EnergyLedger().Report(window, bucket))
+}
+
+// checkEnergyBuckets keeps reports to a reasonable number of buckets
+func checkEnergyBuckets(window, bucket time.Duration) error {
+	if bucket > window {
+		return errors.New("bucket must not be longer than the window")
+	}
+	if buckets := window / bucket; buckets > maxEnergyBuckets {
+		return fmt.Errorf("%d buckets requested, at most %d are supported", buckets, maxEnergyBuckets)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEnergyLedgerBuckets(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 40, 0, 0, time.UTC)
+	ledger := NewEnergyLedger()
+	ledger.now = func() time.Time { return now }
+
+	ledger.Record("r1", "move", -3)
+	ledger.Record("r1", "move", -3)
+	ledger.Record("r1", EnergyCharging, 10)
+	now = now.Add(-50 * time.Minute)
+	ledger.Record("r1", "attack", -5)
+	now = now.Add(-2 * time.Hour)
+	ledger.Record("r1", "attack", -5) // before the window
+	now = time.Date(2026, 10, 16, 12, 40, 0, 0, time.UTC)
+
+	report := ledger.Report(time.Hour, 30*time.Minute)
+	assert.Equal(t, "1h0m0s", report.Window)
+	assert.Len(t, report.Buckets, 3)
+	assert.Equal(t, time.Date(2026, 10, 16, 11, 30, 0, 0, time.UTC), report.Buckets[0].Start)
+	assert.Equal(t, EnergyFlow{Energy: 5, Count: 1}, report.Buckets[0].Consumed["attack"])
+	assert.Empty(t, report.Buckets[1].Consumed)
+	assert.Equal(t, EnergyFlow{Energy: 6, Count: 2}, report.Buckets[2].Consumed["move"])
+	assert.Equal(t, 4, report.Buckets[2].Net)
+	assert.Equal(t, EnergyFlow{Energy: 10, Count: 1}, report.Totals.Produced[EnergyCharging])
+	assert.Equal(t, -1, report.Totals.Net)
+}
+
+func TestStorageBooksEnergy(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.SaveRobot(&Robot{ID: "r1", Energy: 50, Inventory: []string{}, Actions: []Action{}})
+
+	_, err := storage.UpdateRobot("r1", func(robot *Robot) error {
+		robot.Energy -= 4
+		appendAction(robot, "move", "Moved up", nil)
+		return nil
+	})
+	assert.NoError(t, err)
+	_, err = storage.UpdateRobot("r1", func(robot *Robot) error {
+		robot.Energy = 60
+		return nil
+	})
+	assert.NoError(t, err)
+	_, err = storage.UpdateRobot("r1", func(robot *Robot) error {
+		robot.Tags = []string{"scout"}
+		return nil
+	})
+	assert.NoError(t, err)
+	storage.Recharge(func(robot *Robot) int { return 5 })
+	storage.AddHazard(Hazard{Type: HazardRadiation, Radius: 1, DamagePerTick: 2})
+	storage.TickHazards(1)
+
+	totals := storage.EnergyLedger().Report(time.Hour, time.Hour).Totals
+	assert.Equal(t, EnergyFlow{Energy: 4, Count: 1}, totals.Consumed["move"])
+	assert.Equal(t, EnergyFlow{Energy: 14, Count: 1}, totals.Produced[EnergyOther])
+	assert.Equal(t, EnergyFlow{Energy: 5, Count: 1}, totals.Produced[EnergyCharging])
+	assert.Equal(t, EnergyFlow{Energy: 2, Count: 1}, totals.Consumed[EnergyHazard])
+}
+
+func TestEnergyReportEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, err := New(DefaultConfig())
+	assert.NoError(t, err)
+	router := server.Router()
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	placeInRange(t, server.Storage(), "robot1", "robot2")
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/attack/robot2", "").Code)
+
+	var report EnergyReport
+	w := send(router, "GET", "/analytics/energy?window=1h&bucket=15m", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Equal(t, "15m0s", report.Bucket)
+	assert.Len(t, report.Buckets, 5)
+	assert.Equal(t, EnergyFlow{Energy: 0, Count: 1}, report.Totals.Consumed["move"], "moving is free in the sun")
+	assert.Equal(t, 1, report.Totals.Consumed["attack"].Count)
+	assert.Positive(t, report.Totals.Consumed["attack"].Energy)
+
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/analytics/energy?bucket=30s", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/analytics/energy?window=1h&bucket=2h", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/analytics/energy?window=168h&bucket=1m", "").Code)
+}
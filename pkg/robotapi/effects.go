@@ -0,0 +1,143 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Status effect types
+const (
+	EffectBurning = "burning"
+	EffectStunned = "emp_stunned"
+	EffectSlowed  = "slowed"
+)
+
+// StatusEffect is a temporary condition on a robot that wears off after a
+// number of simulation ticks
+type StatusEffect struct {
+	Type           string `json:"type"`
+	RemainingTicks int    `json:"remaining_ticks"`
+	DamagePerTick  int    `json:"damage_per_tick,omitempty"`
+}
+
+// Burning returns a burning effect dealing damage every tick
+func Burning(ticks, damage int) StatusEffect {
+	return StatusEffect{Type: EffectBurning, RemainingTicks: ticks, DamagePerTick: damage}
+}
+
+// Stunned returns an EMP stun that blocks all actions
+func Stunned(ticks int) StatusEffect {
+	return StatusEffect{Type: EffectStunned, RemainingTicks: ticks}
+}
+
+// Slowed returns an effect that limits the robot to one move per tick
+func Slowed(ticks int) StatusEffect {
+	return StatusEffect{Type: EffectSlowed, RemainingTicks: ticks}
+}
+
+// HasEffect reports whether the robot currently suffers from the given effect
+func (r *Robot) HasEffect(effectType string) bool {
+	for _, effect := range r.Effects {
+		if effect.Type == effectType {
+			return true
+		}
+	}
+	return false
+}
+
+// CanPerform checks the robot's status effects, maintenance and
+// decommissioning and returns an error if the given action type is currently not allowed
+func (r *Robot) CanPerform(actionType string) error {
+	if r.Decommissioned {
+		return errors.New("robot is decommissioned")
+	}
+	if r.Maintenance && actionType != "maintenance" {
+		return errors.New("robot is in maintenance")
+	}
+	if r.HasEffect(EffectStunned) {
+		return errors.New("robot is stunned by an EMP")
+	}
+	if actionType == "move" && r.HasEffect(EffectSlowed) && r.movedThisTick {
+		return errors.New("robot is slowed and already moved this tick")
+	}
+	return nil
+}
+
+// ApplyEffect adds a status effect to a robot. An effect of the same type
+// is refreshed rather than stacked.
+func (s *RobotStorage) ApplyEffect(robotID string, effect StatusEffect) error {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	robot, exists := s.robots[robotID]
+	if !exists {
+		return ErrRobotNotFound
+	}
+
+	applyEffect(robot, effect)
+	s.reindexRobot(robot)
+	s.robotChanged(robotID)
+	return nil
+}
+
+func applyEffect(robot *Robot, effect StatusEffect) {
+	for i := range robot.Effects {
+		if robot.Effects[i].Type == effect.Type {
+			if effect.RemainingTicks > robot.Effects[i].RemainingTicks {
+				robot.Effects[i].RemainingTicks = effect.RemainingTicks
+			}
+			if effect.DamagePerTick > robot.Effects[i].DamagePerTick {
+				robot.Effects[i].DamagePerTick = effect.DamagePerTick
+			}
+			return
+		}
+	}
+	robot.Effects = append(robot.Effects, effect)
+	appendAction(robot, "effect", fmt.Sprintf("Became %s", effect.Type), gin.H{"effect": effect.Type, "active": true})
+}
+
+// TickEffects applies damage over time, counts down all active effects and
+// removes the ones that expired. It is registered as a simulation system.
+func (s *RobotStorage) TickEffects(tick int64) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	for _, robot := range s.robots {
+		robot.movedThisTick = false
+		before := robot.Energy
+		if tickEffects(robot) {
+			s.robotChanged(robot.ID)
+		}
+		s.bookEnergy(robot.ID, EnergyEffect, before, robot.Energy)
+		s.reindexRobot(robot)
+	}
+}
+
+// tickEffects advances the effects of a single robot by one tick and
+// reports whether it had any
+func tickEffects(robot *Robot) bool {
+	if len(robot.Effects) == 0 {
+		return false
+	}
+
+	var remaining []StatusEffect
+	for _, effect := range robot.Effects {
+		if effect.DamagePerTick > 0 {
+			robot.Energy -= effect.DamagePerTick
+			if robot.Energy < 0 {
+				robot.Energy, robot.EnergyFraction = 0, 0
+			}
+		}
+
+		effect.RemainingTicks--
+		if effect.RemainingTicks > 0 {
+			remaining = append(remaining, effect)
+		} else {
+			appendAction(robot, "effect", fmt.Sprintf("No longer %s", effect.Type), gin.H{"effect": effect.Type, "active": false})
+		}
+	}
+	robot.Effects = remaining
+	return true
+}
@@ -0,0 +1,116 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStunnedRobotCannotMove(t *testing.T) {
+	router, storage := setupTestRouter()
+	storage.ApplyEffect("robot1", Stunned(2))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/robot/robot1/move", bytes.NewBufferString(`{"direction": "up"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "stunned")
+}
+
+func TestSlowedRobotMovesOncePerTick(t *testing.T) {
+	router, storage := setupTestRouter()
+	storage.ApplyEffect("robot1", Slowed(3))
+
+	move := func() int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/robot/robot1/move", bytes.NewBufferString(`{"direction": "up"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, move())
+	assert.Equal(t, http.StatusConflict, move())
+
+	storage.TickEffects(1)
+	assert.Equal(t, http.StatusOK, move())
+}
+
+func TestTickEffects(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	storage.ApplyEffect("robot2", Burning(2, 5))
+
+	simulation := NewSimulation(0)
+	simulation.AddSystem(storage.TickEffects)
+
+	simulation.Step()
+	robot, _ := storage.GetRobot("robot2")
+	assert.Equal(t, 95, robot.Energy)
+	assert.True(t, robot.HasEffect(EffectBurning))
+
+	simulation.Step()
+	robot, _ = storage.GetRobot("robot2")
+	assert.Equal(t, 90, robot.Energy)
+	assert.False(t, robot.HasEffect(EffectBurning))
+	assert.Equal(t, int64(2), simulation.Tick())
+}
+
+func TestStatusShowsEffects(t *testing.T) {
+	router, storage := setupTestRouter()
+	storage.ApplyEffect("robot1", Burning(3, 1))
+	storage.ApplyEffect("robot1", Burning(5, 1))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/robot/robot1/status", nil)
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Effects []StatusEffect `json:"effects"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	// Reapplying an effect refreshes it instead of stacking
+	assert.Len(t, response.Effects, 1)
+	assert.Equal(t, EffectBurning, response.Effects[0].Type)
+	assert.Equal(t, 5, response.Effects[0].RemainingTicks)
+}
+
+func TestSimulationRunsConcurrentlyWithHandlers(t *testing.T) {
+	router, storage := setupTestRouter()
+	storage.ApplyEffect("robot1", Burning(1000, 0))
+
+	simulation := NewSimulation(0)
+	simulation.AddSystem(storage.TickHazards)
+	simulation.AddSystem(storage.TickEffects)
+	simulation.AddSystem(func(tick int64) {
+		storage.Recharge(func(*Robot) int { return 1 })
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			simulation.Step()
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/robot/robot1/move", bytes.NewBufferString(`{"direction": "up"}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	<-done
+
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, 100, robot.Position.Y)
+}
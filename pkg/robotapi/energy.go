@@ -0,0 +1,64 @@
+package robotapi
+
+import "fmt"
+
+// energyScale is the precision of energy accounting: amounts are exact to a
+// thousandth of a unit, while the API only ever shows whole units
+const energyScale = 1000
+
+// Rules for amounts of energy that are not whole, like 5% of 73
+const (
+	EnergyTruncate EnergyRounding = "truncate" // the fraction is cut off, like the integer math always did
+	EnergyRound    EnergyRounding = "round"    // rounded to the nearest unit, halves up
+	EnergyCarry    EnergyRounding = "carry"    // the fraction is kept by the robot and adds up with later ones
+)
+
+// EnergyRounding decides how fractional energy amounts are applied to robots.
+// Truncating every amount makes long matches drift, so with EnergyCarry the
+// robot keeps the fraction in EnergyFraction and nothing is lost.
+type EnergyRounding string
+
+// NewEnergyRounding returns the rounding rule with the given config name
+func NewEnergyRounding(name string) (EnergyRounding, error) {
+	switch rounding := EnergyRounding(name); rounding {
+	case "":
+		return EnergyTruncate, nil
+	case EnergyTruncate, EnergyRound, EnergyCarry:
+		return rounding, nil
+	default:
+		return "", fmt.Errorf("unknown energy rounding %q", name)
+	}
+}
+
+// amount applies the rule to an amount in thousandths
+func (r EnergyRounding) amount(milli int) int {
+	switch r {
+	case EnergyCarry:
+		return milli
+	case EnergyRound:
+		return (milli + energyScale/2) / energyScale * energyScale
+	default:
+		return milli / energyScale * energyScale
+	}
+}
+
+// drain takes an amount in thousandths from a robot's energy, which never
+// goes below 0. It returns the whole units taken, counting those the robot
+// did not have, like the integer math did.
+func (r EnergyRounding) drain(robot *Robot, milli int) int {
+	remaining := preciseEnergy(robot) - r.amount(milli)
+	whole := remaining / energyScale
+	if remaining < 0 && remaining%energyScale != 0 {
+		whole-- // round towards minus infinity
+	}
+	taken := robot.Energy - whole
+
+	remaining = max(remaining, 0)
+	robot.Energy, robot.EnergyFraction = remaining/energyScale, remaining%energyScale
+	return taken
+}
+
+// preciseEnergy is a robot's energy in thousandths
+func preciseEnergy(robot *Robot) int {
+	return robot.Energy*energyScale + robot.EnergyFraction
+}
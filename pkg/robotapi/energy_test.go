@@ -0,0 +1,83 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnergyRoundingDrain(t *testing.T) {
+	// 5% of 73 is 3.65
+	for rounding, expected := range map[EnergyRounding]Robot{
+		EnergyTruncate: {Energy: 70},
+		EnergyRound:    {Energy: 69},
+		EnergyCarry:    {Energy: 69, EnergyFraction: 350},
+	} {
+		robot := &Robot{Energy: 73}
+		taken := rounding.drain(robot, 73*energyScale*5/100)
+		assert.Equal(t, expected, *robot, rounding)
+		assert.Equal(t, 73-expected.Energy, taken, rounding)
+	}
+
+	// Energy never goes below 0, but the whole amount counts as taken
+	robot := &Robot{Energy: 3, EnergyFraction: 500}
+	assert.Equal(t, 7, EnergyCarry.drain(robot, 7*energyScale))
+	assert.Equal(t, Robot{}, *robot)
+}
+
+func TestCarriedFractionsDoNotDrift(t *testing.T) {
+	truncated, carried := &Robot{Energy: 100}, &Robot{Energy: 100}
+	for i := 0; i < 20; i++ {
+		EnergyTruncate.drain(truncated, 1700) // 1.7 units
+		EnergyCarry.drain(carried, 1700)
+	}
+	assert.Equal(t, 80, truncated.Energy)
+	assert.Equal(t, 66, carried.Energy)
+	assert.Equal(t, 0, carried.EnergyFraction)
+}
+
+func TestNewEnergyRounding(t *testing.T) {
+	rounding, err := NewEnergyRounding("")
+	require.NoError(t, err)
+	assert.Equal(t, EnergyTruncate, rounding)
+	_, err = NewEnergyRounding("banker")
+	assert.Error(t, err)
+
+	config := DefaultConfig()
+	config.EnergyRounding = "banker"
+	_, err = New(config)
+	assert.ErrorContains(t, err, "invalid energy configuration")
+}
+
+func TestAttackCarriesEnergyFractions(t *testing.T) {
+	router, storage := setupTestRouter()
+	handler := NewRobotHandler(storage)
+	handler.SetEnergyRounding(EnergyCarry)
+	router.POST("/carry/:id/attack/:targetId", handler.AttackRobot)
+	storage.UpdateRobot("robot2", func(robot *Robot) error {
+		robot.Position = Position{X: 1}
+		robot.Energy = 73
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/carry/robot1/attack/robot2", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		TargetEnergy int `json:"target_energy"`
+		DamageDealt  int `json:"damage_dealt"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	// 15% of 73 is 10.95
+	target, _ := storage.GetRobot("robot2")
+	assert.Equal(t, 62, target.Energy)
+	assert.Equal(t, 50, target.EnergyFraction)
+	assert.Equal(t, 62, response.TargetEnergy)
+	assert.Equal(t, 11, response.DamageDealt)
+}
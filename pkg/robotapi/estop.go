@@ -0,0 +1,158 @@
+package robotapi
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EStopState is the public view of the emergency stop
+type EStopState struct {
+	Engaged bool       `json:"engaged"`
+	Since   *time.Time `json:"since,omitempty"`
+	Reason  string     `json:"reason,omitempty"`
+}
+
+// EmergencyStop halts the whole world at once. While it is engaged the
+// simulation loops are paused and mutating requests are rejected.
+type EmergencyStop struct {
+	simulations []*Simulation
+	state       EStopState
+	clock       Clock
+	mutex       sync.RWMutex
+}
+
+// NewEmergencyStop creates a released emergency stop for the simulations
+func NewEmergencyStop(simulations ...*Simulation) *EmergencyStop {
+	return &EmergencyStop{simulations: simulations, clock: SystemClock{}}
+}
+
+// SetClock replaces the clock the stop is dated with
+func (e *EmergencyStop) SetClock(clock Clock) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.clock = clock
+}
+
+// State returns the current state of the emergency stop
+func (e *EmergencyStop) State() EStopState {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.state
+}
+
+// Engaged reports whether the emergency stop is engaged
+func (e *EmergencyStop) Engaged() bool {
+	return e.State().Engaged
+}
+
+// Engage pauses the simulations and reports whether the stop was released before
+func (e *EmergencyStop) Engage(reason string) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if e.state.Engaged {
+		return false
+	}
+	now := e.clock.Now().UTC()
+	e.state = EStopState{Engaged: true, Since: &now, Reason: reason}
+	for _, simulation := range e.simulations {
+		simulation.Pause()
+	}
+	return true
+}
+
+// Clear resumes the simulations and reports whether the stop was engaged before
+func (e *EmergencyStop) Clear() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if !e.state.Engaged {
+		return false
+	}
+	e.state = EStopState{}
+	for _, simulation := range e.simulations {
+		simulation.Resume()
+	}
+	return true
+}
+
+// RejectDuringEStop answers mutating requests with 503 while the emergency
+// stop is engaged. The emergency stop endpoints themselves stay available.
+func RejectDuringEStop(estop *EmergencyStop) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			if estop.Engaged() && !strings.HasPrefix(strings.TrimPrefix(c.Request.URL.Path, c.GetString("base_path")), "/admin/estop") {
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Emergency stop engaged"})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// HaltAll stops every driving robot at once and returns the IDs of the
+// robots that were driving
+func (s *RobotStorage) HaltAll(origin ActionOrigin) []string {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	halted := []string{}
+	for _, id := range s.sortedRobotIDs() {
+		robot := s.robots[id]
+		if robot.Velocity == nil && robot.Commanded == nil {
+			continue
+		}
+		robot.Velocity = nil
+		robot.Commanded = nil
+		appendCommandAction(robot, origin, "stop", "Stopped: emergency stop", gin.H{"reason": "emergency stop"})
+		s.reindexRobot(robot)
+		s.robotChanged(id)
+		halted = append(halted, id)
+	}
+	return halted
+}
+
+// EngageEStop engages the emergency stop: the simulation stops ticking,
+// every driving robot of every tenant stops at once, every unfinished task
+// is cancelled and mutating requests are rejected with 503 until the stop
+// is cleared
+func (h *RobotHandler) EngageEStop(c *gin.Context) {
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	// A stop must never be refused: a malformed body only loses the reason
+	_ = bindJSON(c, &request)
+
+	engaged := h.estop.Engage(request.Reason)
+	halted := h.storage.HaltAll(requestOrigin(c))
+	h.tenants.EachWorld(func(storage *RobotStorage) {
+		halted = append(halted, storage.HaltAll(requestOrigin(c))...)
+	})
+	// Batches, programs and rollouts would keep moving robots otherwise
+	cancelled := h.tasks.CancelAll()
+	if engaged {
+		h.events.PublishCommand(commandID(c), "estop_engaged", "", gin.H{"reason": request.Reason, "halted": halted, "cancelled": cancelled})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"estop": h.estop.State(), "halted": halted, "cancelled": cancelled})
+}
+
+// ClearEStop releases the emergency stop and resumes the simulation. Robots
+// stay stopped until they get new commands.
+func (h *RobotHandler) ClearEStop(c *gin.Context) {
+	if h.estop.Clear() {
+		h.events.PublishCommand(commandID(c), "estop_cleared", "", nil)
+	}
+	c.JSON(http.StatusOK, gin.H{"estop": h.estop.State()})
+}
+
+// GetEStop returns the state of the emergency stop
+func (h *RobotHandler) GetEStop(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"estop": h.estop.State()})
+}
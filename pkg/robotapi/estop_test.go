@@ -0,0 +1,99 @@
+package robotapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmergencyStop(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	events := NewEventBus()
+	handler.SetEventBus(events)
+	simulation := NewSimulation(time.Second)
+	estop := NewEmergencyStop(simulation)
+	handler.SetEmergencyStop(estop)
+
+	router := gin.New()
+	router.Use(RejectDuringEStop(estop))
+	router.GET("/robot/:id/status", handler.GetStatus)
+	router.POST("/robot/:id/move", handler.MoveRobot)
+	router.GET("/admin/estop", handler.GetEStop)
+	router.POST("/admin/estop", handler.EngageEStop)
+	router.POST("/admin/estop/clear", handler.ClearEStop)
+
+	storage.UpdateRobot("robot2", func(robot *Robot) error {
+		robot.Commanded = &Vector{X: 1}
+		return nil
+	})
+
+	var response struct {
+		EStop  EStopState `json:"estop"`
+		Halted []string   `json:"halted"`
+	}
+	w := send(router, "POST", "/admin/estop", `{"reason": "operator in the arena"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.True(t, response.EStop.Engaged)
+	assert.Equal(t, "operator in the arena", response.EStop.Reason)
+	assert.Equal(t, []string{"robot2"}, response.Halted)
+	assert.True(t, simulation.Paused())
+
+	robot, _ := storage.GetRobot("robot2")
+	assert.Nil(t, robot.Commanded)
+
+	// Mutating requests are rejected, reads still work
+	assert.Equal(t, http.StatusServiceUnavailable, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "GET", "/robot/robot1/status", "").Code)
+
+	w = send(router, "POST", "/admin/estop/clear", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, simulation.Paused())
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+
+	var types []string
+	for _, event := range events.Since(0) {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{"estop_engaged", "estop_cleared", "robot_moved"}, types)
+}
+
+func TestEmergencyStopHaltsTasks(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	handler := NewRobotHandler(storage)
+	handler.SetEmergencyStop(NewEmergencyStop(NewSimulation(time.Second)))
+	router.POST("/admin/estop", handler.EngageEStop)
+
+	pending := handler.tasks.Create("test")
+	running := handler.tasks.CreateFor("", BatchTask, PriorityNormal, "robot1")
+	handler.tasks.Update(running.ID, func(task *Task) { task.Status = TaskRunning })
+
+	var response struct {
+		Cancelled []string `json:"cancelled"`
+	}
+	json.Unmarshal(send(router, "POST", "/admin/estop", "").Body.Bytes(), &response)
+	assert.ElementsMatch(t, []string{pending.ID, running.ID}, response.Cancelled)
+	task, _ := handler.tasks.Get(pending.ID)
+	assert.Equal(t, TaskCancelled, task.Status)
+	assert.Error(t, handler.tasks.Context(running.ID).Err(), "the running task is asked to stop")
+
+	// A batch that is still running applies no more steps
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/robot/robot1/batch", nil)
+	steps := []BatchStep{{Action: "move", Direction: "up"}, {Action: "move", Direction: "up"}}
+	report := handler.runBatch(context.Background(), c, "robot1", steps)
+	assert.Equal(t, TaskFailed, report.Status)
+	assert.Equal(t, 0, report.Applied)
+	assert.Equal(t, "Emergency stop engaged", report.Error)
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 0, Y: 0}, robot.Position)
+}
@@ -0,0 +1,301 @@
+package robotapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"aufgabe-2/pkg/pubsub"
+)
+
+// maxEventLog limits how many past events are kept in memory
+const maxEventLog = 1000
+
+// Policies for subscribers whose buffer is full
+const (
+	SlowConsumerDropOldest = "drop_oldest" // the oldest buffered event makes room for the new one
+	SlowConsumerDisconnect = "disconnect"  // the subscriber is removed and its channel closed
+)
+
+// ErrSlowConsumer is returned by subscribers that were disconnected for
+// falling behind
+var ErrSlowConsumer = errors.New("event subscriber disconnected for falling behind")
+
+// SubscriberLimits bound the events buffered for each subscriber, so a
+// stuck consumer cannot hold up the bus or grow without bound
+type SubscriberLimits struct {
+	Buffer int    // events buffered per subscriber
+	Policy string // SlowConsumerDropOldest or SlowConsumerDisconnect
+}
+
+// DefaultSubscriberLimits buffer 64 events and drop the oldest beyond
+func DefaultSubscriberLimits() SubscriberLimits {
+	return SubscriberLimits{Buffer: 64, Policy: SlowConsumerDropOldest}
+}
+
+// validate checks the buffer size and policy
+func (l SubscriberLimits) validate() error {
+	if l.Buffer < 1 {
+		return errors.New("the event buffer must hold at least one event")
+	}
+	if l.Policy != SlowConsumerDropOldest && l.Policy != SlowConsumerDisconnect {
+		return fmt.Errorf("invalid slow consumer policy %q", l.Policy)
+	}
+	return nil
+}
+
+// SubscriberStats describe a subscriber's buffer
+type SubscriberStats struct {
+	Tenant        string `json:"tenant,omitempty"` // of the bus
+	ID            int    `json:"id"`
+	Buffered      int    `json:"buffered"`       // events waiting to be received
+	DroppedEvents int64  `json:"dropped_events"` // events it missed
+}
+
+// Event is a domain event published on the event bus
+type Event struct {
+	Sequence  int64       `json:"sequence"`
+	Type      string      `json:"type"`
+	RobotID   string      `json:"robot_id,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data,omitempty"`
+	CommandID string      `json:"command_id,omitempty"` // request that caused the event
+}
+
+// EventSubject returns the subject an event is delivered on:
+// "robot.<robot ID>.<type>" for events of a robot, "world.<type>" for others
+func EventSubject(event Event) string {
+	if event.RobotID == "" {
+		return "world." + pubsub.Token(event.Type)
+	}
+	return "robot." + pubsub.Token(event.RobotID) + "." + pubsub.Token(event.Type)
+}
+
+// EventBus keeps a log of recent events and delivers them to subscribers
+// through a pubsub bus, on the subjects EventSubject names
+type EventBus struct {
+	sequence    int64
+	log         []Event
+	topics      *pubsub.Bus[Event]
+	subscribers map[int]*pubsub.Subscription[Event]
+	limits      SubscriberLimits
+	nextID      int
+	clock       Clock
+	mutex       sync.RWMutex
+}
+
+// NewEventBus creates a new, empty event bus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		topics:      pubsub.New[Event](),
+		subscribers: make(map[int]*pubsub.Subscription[Event]),
+		limits:      DefaultSubscriberLimits(),
+		clock:       SystemClock{},
+	}
+}
+
+// Topics returns the pubsub bus the events are delivered on, for extensions
+// reacting to some of them. Synchronous handlers run while the event bus is
+// locked, so they must be quick and must not publish events.
+func (b *EventBus) Topics() *pubsub.Bus[Event] {
+	return b.topics
+}
+
+// Forward republishes every event on another bus, below the prefix if one
+// is given, e.g. "tenants.acme.robot.robot1.robot_moved"
+func (b *EventBus) Forward(to *pubsub.Bus[Event], prefix string) {
+	b.topics.Subscribe(pubsub.AnyTokens, func(subject string, event Event) {
+		if prefix != "" {
+			subject = prefix + "." + subject
+		}
+		to.Publish(subject, event)
+	})
+}
+
+// SetClock replaces the clock events are stamped with
+func (b *EventBus) SetClock(clock Clock) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.clock = clock
+}
+
+// Clock returns the clock events are stamped with
+func (b *EventBus) Clock() Clock {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.clock
+}
+
+// SetSubscriberLimits replaces the buffer size and slow consumer policy of
+// new subscribers
+func (b *EventBus) SetSubscriberLimits(limits SubscriberLimits) error {
+	if err := limits.validate(); err != nil {
+		return err
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.limits = limits
+	return nil
+}
+
+// Publish assigns the next sequence number to an event, stores it in the
+// log and delivers it to all subscribers. Subscribers that are not keeping
+// up never block the publisher: depending on the policy they miss their
+// oldest buffered event or are disconnected.
+func (b *EventBus) Publish(eventType, robotID string, data interface{}) Event {
+	return b.PublishCommand("", eventType, robotID, data)
+}
+
+// PublishCommand publishes an event caused by the request with the given
+// command ID
+func (b *EventBus) PublishCommand(commandID, eventType, robotID string, data interface{}) Event {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.sequence++
+	event := Event{
+		Sequence:  b.sequence,
+		Type:      eventType,
+		RobotID:   robotID,
+		Timestamp: b.clock.Now(),
+		Data:      data,
+		CommandID: commandID,
+	}
+
+	b.log = append(b.log, event)
+	if len(b.log) > maxEventLog {
+		b.log = b.log[len(b.log)-maxEventLog:]
+	}
+
+	// Delivered while locked, so every subscriber sees the events in order
+	b.topics.Publish(EventSubject(event), event)
+	return event
+}
+
+// OnPublish registers a listener that sees every event, unlike subscribers
+// that may miss events. Listeners run while the bus is locked, so they must
+// be quick and must not publish.
+func (b *EventBus) OnPublish(listener func(event Event)) {
+	b.topics.Subscribe(pubsub.AnyTokens, func(_ string, event Event) {
+		listener(event)
+	})
+}
+
+// Subscribe registers a new subscriber and returns its ID and channel. The
+// channel is closed when the subscriber is disconnected for falling behind.
+func (b *EventBus) Subscribe() (int, <-chan Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	policy := pubsub.DropOldest
+	if b.limits.Policy == SlowConsumerDisconnect {
+		policy = pubsub.Disconnect
+	}
+	subscription, ch, _ := b.topics.Channel(pubsub.AnyTokens, pubsub.Buffer(b.limits.Buffer), pubsub.OnFull(policy))
+	b.nextID++
+	b.subscribers[b.nextID] = subscription
+	return b.nextID, ch
+}
+
+// Unsubscribe removes a subscriber and closes its channel
+func (b *EventBus) Unsubscribe(id int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if subscription, exists := b.subscribers[id]; exists {
+		delete(b.subscribers, id)
+		subscription.Unsubscribe()
+	}
+}
+
+// Dropped returns how many events a subscriber missed so far
+func (b *EventBus) Dropped(id int) int64 {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if subscription, exists := b.subscribers[id]; exists && !subscription.Closed() {
+		return subscription.Dropped()
+	}
+	return 0
+}
+
+// Subscribers returns the buffers of the current subscribers, ordered by ID.
+// Subscribers disconnected for falling behind are left out.
+func (b *EventBus) Subscribers() []SubscriberStats {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	stats := make([]SubscriberStats, 0, len(b.subscribers))
+	for id, subscription := range b.subscribers {
+		if subscription.Closed() {
+			continue
+		}
+		stats = append(stats, SubscriberStats{ID: id, Buffered: subscription.Pending(), DroppedEvents: subscription.Dropped()})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].ID < stats[j].ID
+	})
+	return stats
+}
+
+// Since returns all logged events with a sequence number greater than seq
+func (b *EventBus) Since(seq int64) []Event {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	events := []Event{}
+	for _, event := range b.log {
+		if event.Sequence > seq {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Sequence returns the sequence number of the latest event
+func (b *EventBus) Sequence() int64 {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.sequence
+}
+
+// Retained reports whether every event after seq is still in the log, so a
+// client that has seen the events up to seq missed none
+func (b *EventBus) Retained(seq int64) bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return seq >= b.sequence-int64(len(b.log))
+}
+
+// Wait returns the logged events after seq that match the filter. If there
+// are none yet it blocks until a matching event is published or ctx is done,
+// in which case the result is empty.
+func (b *EventBus) Wait(ctx context.Context, seq int64, match func(Event) bool) []Event {
+	// Subscribe before reading the log so no event can slip through in between
+	id, ch := b.Subscribe()
+	defer b.Unsubscribe(id)
+
+	disconnected := false
+	for {
+		events := []Event{}
+		for _, event := range b.Since(seq) {
+			if match(event) {
+				events = append(events, event)
+			}
+		}
+		// Once disconnected for falling behind, the log is read a last time
+		if len(events) > 0 || disconnected {
+			return events
+		}
+
+		select {
+		case _, open := <-ch:
+			disconnected = !open
+		case <-ctx.Done():
+			return events
+		}
+	}
+}
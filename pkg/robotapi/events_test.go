@@ -0,0 +1,72 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowSubscribersMissTheOldestEvents(t *testing.T) {
+	events := NewEventBus()
+	require.NoError(t, events.SetSubscriberLimits(SubscriberLimits{Buffer: 2, Policy: SlowConsumerDropOldest}))
+	id, ch := events.Subscribe()
+	for _, eventType := range []string{"first", "second", "third", "fourth"} {
+		events.Publish(eventType, "", nil)
+	}
+
+	assert.Equal(t, "third", (<-ch).Type)
+	assert.Equal(t, "fourth", (<-ch).Type)
+	assert.Equal(t, int64(2), events.Dropped(id))
+	assert.Equal(t, []SubscriberStats{{ID: id, DroppedEvents: 2}}, events.Subscribers())
+}
+
+func TestSlowSubscribersAreDisconnected(t *testing.T) {
+	events := NewEventBus()
+	require.NoError(t, events.SetSubscriberLimits(SubscriberLimits{Buffer: 1, Policy: SlowConsumerDisconnect}))
+	id, ch := events.Subscribe()
+	events.Publish("first", "", nil)
+	events.Publish("second", "", nil)
+
+	assert.Equal(t, "first", (<-ch).Type)
+	_, open := <-ch
+	assert.False(t, open)
+	assert.Empty(t, events.Subscribers())
+	events.Unsubscribe(id) // already gone
+}
+
+func TestSubscriberLimitsAreValidated(t *testing.T) {
+	events := NewEventBus()
+	assert.Error(t, events.SetSubscriberLimits(SubscriberLimits{Buffer: 0, Policy: SlowConsumerDropOldest}))
+	assert.Error(t, events.SetSubscriberLimits(SubscriberLimits{Buffer: 1, Policy: "block"}))
+
+	config := DefaultConfig()
+	config.Subscribers.Policy = "block"
+	_, err := New(config)
+	assert.ErrorContains(t, err, "invalid slow consumer policy")
+}
+
+func TestConnectionStatsReportSubscribers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	config.Tenants = []string{"school"}
+	config.Subscribers = SubscriberLimits{Buffer: 1, Policy: SlowConsumerDropOldest}
+	server, err := New(config)
+	require.NoError(t, err)
+	server.Events().Subscribe()
+	tenant, _ := server.tenants.Get("school")
+	tenant.Events.Subscribe()
+	server.Events().Publish("first", "", nil)
+	server.Events().Publish("second", "", nil)
+
+	w := adminRequest(server.Router(), "GET", "/admin/connections", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var stats ConnectionStats
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stats))
+	assert.Equal(t, []SubscriberStats{{ID: 1, Buffered: 1, DroppedEvents: 1}, {Tenant: "school", ID: 1}}, stats.Subscribers)
+	assert.Equal(t, int64(1), stats.DroppedEvents)
+}
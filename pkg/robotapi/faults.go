@@ -0,0 +1,268 @@
+package robotapi
+
+import (
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Fault types
+const (
+	FaultLatency = "latency" // the request is delayed, then served
+	FaultError   = "error"   // the request fails without being served
+	FaultDrop    = "drop"    // the request is served, but the connection closes without a response
+)
+
+// maxFaultLatency caps the delay of a latency fault
+const maxFaultLatency = time.Minute
+
+// FaultRule injects a fault into a share of the requests to a route
+type FaultRule struct {
+	ID string `json:"id"`
+	// Route is a route as registered without the base path, like
+	// /robot/:id/move, or a pattern of request paths, like /robot/robot1/*
+	Route     string   `json:"route" binding:"required"`
+	Methods   []string `json:"methods,omitempty"` // all methods if empty
+	Type      string   `json:"type" binding:"required"`
+	Percent   float64  `json:"percent"`              // share of the matching requests, 0 to 100
+	LatencyMS int      `json:"latency_ms,omitempty"` // delay of latency faults
+	Status    int      `json:"status,omitempty"`     // status of error faults, 500 if 0
+	Injected  int      `json:"injected"`             // faults injected so far
+}
+
+// validate checks a new rule and fills in its defaults
+func (r *FaultRule) validate() error {
+	switch r.Type {
+	case FaultLatency:
+		if r.LatencyMS <= 0 || time.Duration(r.LatencyMS)*time.Millisecond > maxFaultLatency {
+			return fmt.Errorf("latency_ms must be between 1 and %d", maxFaultLatency.Milliseconds())
+		}
+	case FaultError:
+		if r.Status == 0 {
+			r.Status = http.StatusInternalServerError
+		}
+		if r.Status < 400 || r.Status > 599 {
+			return fmt.Errorf("status must be an error status between 400 and 599")
+		}
+	case FaultDrop:
+	default:
+		return fmt.Errorf("type must be %s, %s or %s", FaultLatency, FaultError, FaultDrop)
+	}
+	if r.Percent <= 0 || r.Percent > 100 {
+		return fmt.Errorf("percent must be above 0 and at most 100")
+	}
+	if !strings.HasPrefix(r.Route, "/") {
+		return fmt.Errorf("route must start with /")
+	}
+	if _, err := path.Match(r.Route, "/"); err != nil {
+		return fmt.Errorf("invalid route pattern: %w", err)
+	}
+	for i, method := range r.Methods {
+		r.Methods[i] = strings.ToUpper(method)
+	}
+	r.Injected = 0
+	return nil
+}
+
+// matches reports whether the rule applies to a request, given its route
+// and path without the base path
+func (r *FaultRule) matches(method, route, requestPath string) bool {
+	if len(r.Methods) > 0 && !containsString(r.Methods, method) {
+		return false
+	}
+	if r.Route == route {
+		return true
+	}
+	matched, _ := path.Match(r.Route, requestPath)
+	return matched
+}
+
+// FaultInjector fails requests on purpose, so client authors can test their
+// retry logic against the server. It injects nothing until rules are added.
+type FaultInjector struct {
+	rules  []*FaultRule
+	nextID int
+	dice   *dice
+	mutex  sync.Mutex
+}
+
+// NewFaultInjector creates an injector without rules whose rolls come from
+// the given random stream
+func NewFaultInjector(dice *dice) *FaultInjector {
+	return &FaultInjector{dice: dice}
+}
+
+// Add adds a rule and returns it with its ID
+func (f *FaultInjector) Add(rule FaultRule) (FaultRule, error) {
+	if err := rule.validate(); err != nil {
+		return FaultRule{}, err
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.nextID++
+	rule.ID = fmt.Sprintf("fault%d", f.nextID)
+	f.rules = append(f.rules, &rule)
+	return rule, nil
+}
+
+// Remove removes a rule, reporting whether it existed
+func (f *FaultInjector) Remove(id string) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for i, rule := range f.rules {
+		if rule.ID == id {
+			f.rules = append(f.rules[:i], f.rules[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Clear removes all rules
+func (f *FaultInjector) Clear() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.rules = nil
+}
+
+// Rules returns copies of the rules in the order they were added
+func (f *FaultInjector) Rules() []FaultRule {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	rules := make([]FaultRule, 0, len(f.rules))
+	for _, rule := range f.rules {
+		copied := *rule
+		copied.Methods = append([]string(nil), rule.Methods...)
+		rules = append(rules, copied)
+	}
+	return rules
+}
+
+// pick returns the fault to inject into a request, if any: the first
+// matching rule whose roll hits
+func (f *FaultInjector) pick(method, route, requestPath string) (FaultRule, bool) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, rule := range f.rules {
+		if !rule.matches(method, route, requestPath) {
+			continue
+		}
+		if float64(f.dice.int63()%10000) < rule.Percent*100 {
+			rule.Injected++
+			return *rule, true
+		}
+	}
+	return FaultRule{}, false
+}
+
+// Inject is the middleware injecting the faults. Admin routes are never
+// faulted, so the rules can always be removed again. Faulted responses carry
+// an X-Fault-Injected header naming the fault type.
+func (f *FaultInjector) Inject() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		basePath := c.GetString("base_path")
+		route := strings.TrimPrefix(c.FullPath(), basePath)
+		requestPath := strings.TrimPrefix(c.Request.URL.Path, basePath)
+		if strings.HasPrefix(requestPath, "/admin/") {
+			c.Next()
+			return
+		}
+		rule, inject := f.pick(c.Request.Method, route, requestPath)
+		if !inject {
+			c.Next()
+			return
+		}
+
+		switch rule.Type {
+		case FaultLatency:
+			c.Header("X-Fault-Injected", FaultLatency)
+			timer := time.NewTimer(time.Duration(rule.LatencyMS) * time.Millisecond)
+			defer timer.Stop()
+			select {
+			case <-timer.C:
+			case <-c.Request.Context().Done():
+				c.Abort()
+				return
+			}
+			c.Next()
+		case FaultError:
+			c.Header("X-Fault-Injected", FaultError)
+			c.AbortWithStatusJSON(rule.Status, gin.H{"error": "Injected fault", "fault": rule.ID})
+		case FaultDrop:
+			// The handler runs, so the request takes effect, but its
+			// response is lost, like when a connection breaks
+			writer := newBufferedWriter(c.Writer)
+			c.Writer = writer
+			c.Next()
+			c.Writer = writer.ResponseWriter
+			if conn, _, err := c.Writer.Hijack(); err == nil {
+				conn.Close()
+				return
+			}
+			// Connections that cannot be taken over, like HTTP/2 ones,
+			// get a gateway error instead
+			c.Header("X-Fault-Injected", FaultDrop)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Injected fault", "fault": rule.ID})
+		}
+	}
+}
+
+// GetFaults lists the fault rules
+func (h *RobotHandler) GetFaults(c *gin.Context) {
+	if h.faults == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fault injection is disabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"rules": h.faults.Rules()})
+}
+
+// AddFault adds a fault rule
+func (h *RobotHandler) AddFault(c *gin.Context) {
+	if h.faults == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fault injection is disabled"})
+		return
+	}
+	var rule FaultRule
+	if err := bindJSON(c, &rule); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	rule, err := h.faults.Add(rule)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, rule)
+}
+
+// RemoveFault removes a fault rule
+func (h *RobotHandler) RemoveFault(c *gin.Context) {
+	if h.faults == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fault injection is disabled"})
+		return
+	}
+	if !h.faults.Remove(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fault rule not found"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ClearFaults removes all fault rules
+func (h *RobotHandler) ClearFaults(c *gin.Context) {
+	if h.faults == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fault injection is disabled"})
+		return
+	}
+	h.faults.Clear()
+	c.Status(http.StatusNoContent)
+}
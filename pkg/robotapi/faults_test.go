@@ -0,0 +1,129 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupFaultServer(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	config.FaultInjection = true
+	config.RandomSeed = 1
+	server, err := New(config)
+	require.NoError(t, err)
+	return server.Router()
+}
+
+func addFault(t *testing.T, router *gin.Engine, rule string) FaultRule {
+	w := adminRequest(router, "POST", "/admin/faults", rule)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created FaultRule
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	return created
+}
+
+func TestFaultInjectionDisabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	server, err := New(config)
+	require.NoError(t, err)
+
+	w := adminRequest(server.Router(), "POST", "/admin/faults", `{"route": "/robot/:id/move", "type": "error", "percent": 100}`)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestErrorFaults(t *testing.T) {
+	router := setupFaultServer(t)
+	rule := addFault(t, router, `{"route": "/robot/:id/move", "methods": ["post"], "type": "error", "percent": 100, "status": 503}`)
+	assert.Equal(t, "fault1", rule.ID)
+
+	w := adminRequest(router, "POST", "/robot/robot1/move", `{"direction": "up"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "error", w.Header().Get("X-Fault-Injected"))
+	assert.JSONEq(t, `{"error": "Injected fault", "fault": "fault1"}`, w.Body.String())
+
+	// Other routes, and the robot that did not move, are untouched
+	w = adminRequest(router, "GET", "/robot/robot1/status", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-Fault-Injected"))
+	assert.Contains(t, w.Body.String(), `"position":{"x":0,"y":0`)
+
+	w = adminRequest(router, "GET", "/admin/faults", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"injected":1`)
+	assert.Contains(t, w.Body.String(), `"methods":["POST"]`)
+
+	assert.Equal(t, http.StatusNoContent, adminRequest(router, "DELETE", "/admin/faults/fault1", "").Code)
+	assert.Equal(t, http.StatusNotFound, adminRequest(router, "DELETE", "/admin/faults/fault1", "").Code)
+	assert.Equal(t, http.StatusOK, adminRequest(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+}
+
+func TestFaultRulesAreValidated(t *testing.T) {
+	router := setupFaultServer(t)
+	for _, rule := range []string{
+		`{"route": "/robot/:id/move", "type": "explode", "percent": 100}`,
+		`{"route": "/robot/:id/move", "type": "error", "percent": 0}`,
+		`{"route": "/robot/:id/move", "type": "error", "percent": 100, "status": 200}`,
+		`{"route": "/robot/:id/move", "type": "latency", "percent": 100}`,
+		`{"route": "robot", "type": "drop", "percent": 100}`,
+		`{"route": "/robot/[", "type": "drop", "percent": 100}`,
+		`{"type": "drop", "percent": 100}`,
+	} {
+		w := adminRequest(router, "POST", "/admin/faults", rule)
+		assert.Equal(t, http.StatusBadRequest, w.Code, rule)
+	}
+}
+
+func TestLatencyFaults(t *testing.T) {
+	router := setupFaultServer(t)
+	addFault(t, router, `{"route": "/robot/*/status", "type": "latency", "percent": 100, "latency_ms": 30}`)
+
+	started := time.Now()
+	w := adminRequest(router, "GET", "/robot/robot1/status", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "latency", w.Header().Get("X-Fault-Injected"))
+	assert.GreaterOrEqual(t, time.Since(started), 30*time.Millisecond)
+}
+
+func TestDropFaults(t *testing.T) {
+	router := setupFaultServer(t)
+	addFault(t, router, `{"route": "/robot/:id/move", "type": "drop", "percent": 100}`)
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	_, err := http.Post(server.URL+"/robot/robot1/move", "application/json", strings.NewReader(`{"direction": "up"}`))
+	require.Error(t, err)
+
+	// The move happened although its response was lost
+	assert.Equal(t, http.StatusNoContent, adminRequest(router, "DELETE", "/admin/faults", "").Code)
+	w := adminRequest(router, "GET", "/robot/robot1/status", "")
+	assert.Contains(t, w.Body.String(), `"position":{"x":0,"y":1`)
+}
+
+func TestFaultPercentAndAdminRoutes(t *testing.T) {
+	router := setupFaultServer(t)
+	addFault(t, router, `{"route": "/robot/:id/status", "type": "error", "percent": 50}`)
+	addFault(t, router, `{"route": "/admin/*", "type": "error", "percent": 100}`)
+
+	failed := 0
+	for i := 0; i < 200; i++ {
+		if adminRequest(router, "GET", "/robot/robot1/status", "").Code == http.StatusInternalServerError {
+			failed++
+		}
+	}
+	assert.InDelta(t, 100, failed, 30)
+
+	// Admin routes are never faulted, so the rules can be removed
+	assert.Equal(t, http.StatusOK, adminRequest(router, "GET", "/admin/faults", "").Code)
+}
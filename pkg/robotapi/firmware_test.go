@@ -0,0 +1,52 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFirmwareVersion(t *testing.T) {
+	version, err := ParseFirmwareVersion("v1.4")
+	assert.NoError(t, err)
+	assert.Equal(t, "1.4.0", version.String())
+
+	older, _ := ParseFirmwareVersion("1.3.12")
+	assert.True(t, older.Less(version))
+	assert.False(t, version.Less(version))
+
+	for _, invalid := range []string{"", "1.x", "1.2.3.4", "1.-2"} {
+		_, err := ParseFirmwareVersion(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRequireFirmware(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	handler := NewRobotHandler(storage)
+	handler.SetMinFirmware(FirmwareVersion{2, 0, 0})
+	router.POST("/fw/robots", handler.CreateRobot)
+	router.POST("/fw/robot/:id/move", handler.RequireFirmware(), handler.MoveRobot)
+	router.PATCH("/fw/robot/:id/state", handler.UpdateState)
+
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/fw/robots", `{"firmware": "latest"}`).Code)
+	assert.Equal(t, http.StatusCreated, send(router, "POST", "/fw/robots", `{"id": "arm", "firmware": "1.9.3", "position": {"x": 3, "y": 3}}`).Code)
+
+	// Simulated robots without firmware are not affected
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/fw/robot/robot1/move", `{"direction": "up"}`).Code)
+
+	w := send(router, "POST", "/fw/robot/arm/move", `{"direction": "up"}`)
+	assert.Equal(t, http.StatusUpgradeRequired, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, "1.9.3", response["firmware"])
+	assert.Equal(t, "2.0.0", response["min_firmware"])
+	assert.Contains(t, response["upgrade"], "PATCH /robot/arm/state")
+
+	// After the flash the new firmware is recorded and commands work again
+	assert.Equal(t, http.StatusBadRequest, send(router, "PATCH", "/fw/robot/arm/state", `{"firmware": "two"}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "PATCH", "/fw/robot/arm/state", `{"firmware": "2.0.1"}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/fw/robot/arm/move", `{"direction": "up"}`).Code)
+}
@@ -0,0 +1,63 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFloorsDisabledByDefault(t *testing.T) {
+	router, _, _ := setupMapRouter()
+
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/robot/robot1/move", `{"direction": "ascend"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/robots", `{"position": {"x": 0, "y": 0, "z": 1}}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "PUT", "/admin/world/obstacles/4/4?z=2", "").Code)
+
+	// Flat positions are serialized as before
+	w := send(router, "GET", "/world/map", "")
+	assert.NotContains(t, w.Body.String(), `"z"`)
+}
+
+func TestFloors(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	handler := NewRobotHandler(storage)
+	handler.SetMultiFloor(true)
+	router.POST("/floors/robot/:id/move", handler.MoveRobot)
+	router.GET("/floors/robot/:id/path", handler.GetPath)
+	router.POST("/floors/links", handler.AddFloorLink)
+	router.DELETE("/floors/links/:x/:y/:z", handler.RemoveFloorLink)
+
+	// Without a link the robot cannot change floors
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/floors/robot/robot1/move", `{"direction": "ascend"}`).Code)
+
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/floors/links", `{"type": "ladder", "position": {"x": 0, "y": 0}}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/floors/links", `{"type": "elevator", "position": {"x": 0, "y": 0}}`).Code)
+	assert.ErrorIs(t, storage.PlaceObstacle(Position{X: 0, Y: 0, Z: 1}), ErrCellOccupied, "elevators cannot be blocked")
+
+	// The route to the upper floor leads through the elevator
+	w := send(router, "GET", "/floors/robot/robot1/path?toX=1&toY=0&toZ=1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var route struct {
+		Moves []string `json:"moves"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &route)
+	assert.Equal(t, []string{"ascend", "right"}, route.Moves)
+
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/floors/robot/robot1/move", `{"direction": "ascend"}`).Code)
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 0, Y: 0, Z: 1}, robot.Position)
+
+	// Robots on other floors are further away
+	assert.Equal(t, 3, robot.Position.DistanceTo(Position{X: 1, Y: 1}))
+
+	assert.Equal(t, http.StatusOK, send(router, "DELETE", "/floors/links/0/0/0", "").Code)
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/floors/robot/robot1/move", `{"direction": "descend"}`).Code)
+}
+
+func TestHazardsStayOnTheirFloor(t *testing.T) {
+	lava := Hazard{Type: HazardLava, Center: Position{X: 5, Y: 5}, Radius: 1}
+	assert.True(t, lava.Covers(Position{X: 5, Y: 6}))
+	assert.False(t, lava.Covers(Position{X: 5, Y: 5, Z: 1}))
+}
@@ -0,0 +1,277 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Limits of generated maps
+const (
+	maxGeneratedSize    = 200
+	maxObstacleDensity  = 0.4
+	defaultObstacleRate = 0.15
+)
+
+// resourceTypes are the item types placed as resource nodes
+var resourceTypes = []string{"battery", "tool", "armor_plate"}
+
+// MapConfig describes the map to generate
+type MapConfig struct {
+	Generator       string   `json:"generator"` // default "scatter"
+	Width           int      `json:"width" binding:"required"`
+	Height          int      `json:"height" binding:"required"`
+	ObstacleDensity *float64 `json:"obstacle_density"` // share of blocked cells, default 0.15
+	Resources       int      `json:"resources"`        // number of items to place
+	Hazards         int      `json:"hazards"`          // number of hazard cells
+	Seed            int64    `json:"seed"`             // 0 draws one from the match's random service
+}
+
+// GeneratedMap is a complete board that replaces the current world
+type GeneratedMap struct {
+	Layout  MapLayout
+	Items   []Item
+	Hazards []Hazard
+}
+
+// MapGenerator builds boards. The same config must always give the same
+// board, so every random decision has to come from the seed.
+type MapGenerator interface {
+	Name() string
+	Generate(config MapConfig) (GeneratedMap, error)
+}
+
+// NewMapGenerator returns the generator registered under the given name
+func NewMapGenerator(name string) (MapGenerator, error) {
+	switch name {
+	case "", "scatter":
+		return ScatterGenerator{}, nil
+	default:
+		return nil, fmt.Errorf("unknown map generator %q", name)
+	}
+}
+
+// ScatterGenerator spawns robots in the corners and scatters obstacles,
+// resources and hazards uniformly over the rest of the board. Pockets that
+// cannot be reached from the first spawn point are filled with obstacles.
+type ScatterGenerator struct{}
+
+// Name returns the config name of the generator
+func (ScatterGenerator) Name() string {
+	return "scatter"
+}
+
+// Generate builds a board from the config
+func (ScatterGenerator) Generate(config MapConfig) (GeneratedMap, error) {
+	rng := rand.New(rand.NewSource(config.Seed))
+	bounds := Bounds{MaxX: config.Width - 1, MaxY: config.Height - 1}
+
+	// Spawn points in the corners; their neighbors stay free
+	spawns := []Position{}
+	reserved := map[Position]bool{}
+	for _, corner := range []Position{{X: 0, Y: 0}, {X: bounds.MaxX, Y: bounds.MaxY}, {X: bounds.MaxX, Y: 0}, {X: 0, Y: bounds.MaxY}} {
+		if reserved[corner] {
+			continue
+		}
+		spawns = append(spawns, corner)
+		for _, direction := range flatMoves {
+			reserved[corner.Add(directions[direction])] = true
+		}
+		reserved[corner] = true
+	}
+
+	density := defaultObstacleRate
+	if config.ObstacleDensity != nil {
+		density = *config.ObstacleDensity
+	}
+	obstacles := map[Position]bool{}
+	for x := 0; x <= bounds.MaxX; x++ {
+		for y := 0; y <= bounds.MaxY; y++ {
+			p := Position{X: x, Y: y}
+			if !reserved[p] && rng.Float64() < density {
+				obstacles[p] = true
+			}
+		}
+	}
+
+	// Fill everything that cannot be reached from the first spawn point
+	reachable := map[Position]bool{spawns[0]: true}
+	queue := []Position{spawns[0]}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		for _, direction := range flatMoves {
+			next := p.Add(directions[direction])
+			if bounds.Contains(next) && !obstacles[next] && !reachable[next] {
+				reachable[next] = true
+				queue = append(queue, next)
+			}
+		}
+	}
+
+	board := GeneratedMap{Layout: MapLayout{Bounds: &bounds, Obstacles: []Position{}}, Items: []Item{}, Hazards: []Hazard{}}
+	free := []Position{}
+	for x := 0; x <= bounds.MaxX; x++ {
+		for y := 0; y <= bounds.MaxY; y++ {
+			p := Position{X: x, Y: y}
+			switch {
+			case !reachable[p]:
+				board.Layout.Obstacles = append(board.Layout.Obstacles, p)
+			case !reserved[p]:
+				free = append(free, p)
+			}
+		}
+	}
+	for _, spawn := range spawns {
+		if reachable[spawn] {
+			board.Layout.SpawnPoints = append(board.Layout.SpawnPoints, spawn)
+		}
+	}
+
+	if config.Resources+config.Hazards > len(free) {
+		return GeneratedMap{}, fmt.Errorf("only %d free cells for %d resources and %d hazards", len(free), config.Resources, config.Hazards)
+	}
+	rng.Shuffle(len(free), func(i, j int) { free[i], free[j] = free[j], free[i] })
+
+	for i := 0; i < config.Resources; i++ {
+		itemType := resourceTypes[rng.Intn(len(resourceTypes))]
+		board.Items = append(board.Items, Item{
+			ID:       fmt.Sprintf("resource%d", i+1),
+			Type:     itemType,
+			Weight:   1 + rng.Intn(5),
+			Position: free[i],
+		})
+	}
+	for _, p := range free[config.Resources : config.Resources+config.Hazards] {
+		hazard := Hazard{Type: HazardLava, Center: p, DamagePerTick: 10}
+		if rng.Intn(2) == 0 {
+			hazard = Hazard{Type: HazardRadiation, Center: p, DamagePerTick: 3}
+		}
+		board.Hazards = append(board.Hazards, hazard)
+	}
+	return board, nil
+}
+
+// validate checks the config against the limits of generated maps
+func (config MapConfig) validate() error {
+	if config.Width < 1 || config.Width > maxGeneratedSize || config.Height < 1 || config.Height > maxGeneratedSize {
+		return fmt.Errorf("width and height must be between 1 and %d", maxGeneratedSize)
+	}
+	if config.ObstacleDensity != nil && (*config.ObstacleDensity < 0 || *config.ObstacleDensity > maxObstacleDensity) {
+		return fmt.Errorf("obstacle_density must be between 0 and %g", maxObstacleDensity)
+	}
+	if config.Resources < 0 || config.Hazards < 0 {
+		return errors.New("resources and hazards must not be negative")
+	}
+	return nil
+}
+
+// ApplyMap replaces the board: items lying in the world, hazards and the map
+// layout including elevators and ramps are swapped for the generated ones,
+// and every robot is moved to a spawn point in turn. Carried items stay in
+// the inventories. Boards with more items than the world's limit allows
+// are rejected with a LimitError.
+func (s *RobotStorage) ApplyMap(board GeneratedMap) error {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if limit := s.limits.MaxItems; limit > 0 {
+		carried := 0
+		for _, item := range s.items {
+			if item.carried {
+				carried++
+			}
+		}
+		if carried+len(board.Items) > limit {
+			return &LimitError{Resource: "items", Limit: limit, Used: len(s.items)}
+		}
+	}
+
+	for id, item := range s.items {
+		if !item.carried {
+			delete(s.items, id)
+			s.index.Remove(searchDoc{kind: searchItem, id: id})
+			s.itemChanged(id)
+		}
+	}
+	for _, generated := range board.Items {
+		item := generated
+		if existing, exists := s.items[item.ID]; exists && existing.carried {
+			item.ID = "generated-" + item.ID
+		}
+		s.items[item.ID] = &item
+		s.reindexItem(&item)
+		s.itemChanged(item.ID)
+	}
+
+	s.hazards = append([]Hazard{}, board.Hazards...)
+	s.hazardsChanged()
+
+	s.bounds = board.Layout.Bounds
+	s.floorLinks = make(map[Position]string)
+	s.obstacles = make(map[Position]bool)
+	for _, p := range board.Layout.Obstacles {
+		s.obstacles[p] = true
+	}
+	s.spawnPoints = append([]Position{}, board.Layout.SpawnPoints...)
+	s.nextSpawn = 0
+	s.setZones(board.Layout.Zones)
+	s.layoutChanged()
+
+	for _, id := range s.sortedRobotIDs() {
+		robot := s.robots[id]
+		robot.Position = s.spawnPoints[s.nextSpawn%len(s.spawnPoints)]
+		s.nextSpawn++
+		s.reindexRobot(robot)
+		s.robotChanged(id)
+	}
+	return nil
+}
+
+// GenerateWorld replaces the world with a generated board
+func (h *RobotHandler) GenerateWorld(c *gin.Context) {
+	var config MapConfig
+	if err := bindJSON(c, &config); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if err := config.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	generator, err := NewMapGenerator(config.Generator)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if config.Seed == 0 {
+		config.Seed = h.random.Int63(RandomMaps)
+	}
+
+	board, err := generator.Generate(config)
+	if err != nil {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.world(c).ApplyMap(board); err != nil {
+		var limitErr *LimitError
+		if errors.As(err, &limitErr) {
+			abortWithLimit(c, http.StatusForbidden, limitErr)
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.mapChanged(c, "generated", gin.H{"generator": generator.Name(), "seed": config.Seed})
+
+	c.JSON(http.StatusOK, gin.H{
+		"generator": generator.Name(),
+		"seed":      config.Seed,
+		"layout":    h.world(c).GetLayout(),
+		"items":     len(board.Items),
+		"hazards":   board.Hazards,
+	})
+}
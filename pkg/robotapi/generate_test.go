@@ -0,0 +1,77 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestScatterGeneratorIsReproducible(t *testing.T) {
+	density := 0.3
+	config := MapConfig{Width: 30, Height: 20, ObstacleDensity: &density, Resources: 10, Hazards: 5, Seed: 42}
+
+	first, err := ScatterGenerator{}.Generate(config)
+	assert.NoError(t, err)
+	second, _ := ScatterGenerator{}.Generate(config)
+	assert.Equal(t, first, second)
+
+	config.Seed = 43
+	other, _ := ScatterGenerator{}.Generate(config)
+	assert.NotEqual(t, first.Layout.Obstacles, other.Layout.Obstacles)
+
+	assert.Len(t, first.Items, 10)
+	assert.Len(t, first.Hazards, 5)
+	assert.NotEmpty(t, first.Layout.SpawnPoints)
+	for _, spawn := range first.Layout.SpawnPoints {
+		assert.Contains(t, []Position{{X: 0, Y: 0}, {X: 29, Y: 19}, {X: 29, Y: 0}, {X: 0, Y: 19}}, spawn)
+	}
+
+	// Every resource can be reached from every spawn point
+	for _, spawn := range first.Layout.SpawnPoints {
+		for _, item := range first.Items {
+			_, found := FindPath(spawn, item.Position, *first.Layout.Bounds, func(from, to Position) bool {
+				return first.Layout.CanMove(from, to) == nil
+			})
+			assert.True(t, found, "item %s at %v", item.ID, item.Position)
+		}
+	}
+}
+
+func TestScatterGeneratorRejectsOvercrowdedBoards(t *testing.T) {
+	_, err := ScatterGenerator{}.Generate(MapConfig{Width: 3, Height: 3, Resources: 20})
+	assert.Error(t, err)
+}
+
+func TestGenerateWorld(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	router.POST("/admin/world/generate", NewRobotHandler(storage).GenerateWorld)
+	storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Inventory = append(robot.Inventory, "item2")
+		return nil
+	})
+	storage.RemoveItem("item2")
+
+	w := send(router, "POST", "/admin/world/generate", `{"width": 12, "height": 12, "resources": 4, "hazards": 2, "seed": 7}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var worldMap WorldMap
+	json.Unmarshal(send(router, "GET", "/world/map", "").Body.Bytes(), &worldMap)
+	assert.Equal(t, &Bounds{MaxX: 11, MaxY: 11}, worldMap.Bounds)
+	assert.Len(t, worldMap.Hazards, 2)
+	for _, robot := range worldMap.Robots {
+		assert.Contains(t, worldMap.SpawnPoints, robot.Position)
+	}
+
+	// Old world items are gone, carried ones stay
+	items := storage.GetAvailableItems()
+	assert.Len(t, items, 4)
+	assert.NotContains(t, items, "item1")
+	_, err := storage.GetItem("item2")
+	assert.NoError(t, err)
+
+	for _, body := range []string{`{"width": 0, "height": 5}`, `{"width": 5, "height": 5, "obstacle_density": 0.9}`, `{"width": 5, "height": 5, "generator": "maze"}`} {
+		assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/admin/world/generate", body).Code, body)
+	}
+}
@@ -0,0 +1,1589 @@
+package robotapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RobotHandler handles robot-related requests
+type RobotHandler struct {
+	storage  *RobotStorage
+	combat   CombatResolver
+	device   DeviceAdapter
+	events   *EventBus
+	weather  *Weather
+	clock    *WorldClock
+	ids      IDGenerator
+	sessions *SessionStore
+	keys     *APIKeyStore
+	estop    *EmergencyStop
+	readOnly *ReadOnlyMode
+	tasks    *TaskStore
+	ota      *OTAManager
+	plugins  *PluginRegistry
+	hooks    *HookRegistry
+	tenants  *TenantStore
+	outbox   *Outbox
+
+	transfers     *TransferStore
+	programs      *ProgramStore
+	behaviors     *BehaviorEngine
+	tournaments   *TournamentStore
+	devices       *RegistrationStore
+	decommissions *DecommissionStore
+	spawner       *ItemSpawner   // nil if no items spawn
+	quotas        map[string]int // daily limit per action type
+
+	spectatorDelay time.Duration // events are shown to spectators this late
+
+	multiFloor bool  // allow floors other than 0
+	continuous bool  // robots also move by velocity in float coordinates
+	noise      *dice // sensor noise, nil if scans are exact
+
+	minFirmware *FirmwareVersion // commands to robots with older firmware are rejected
+
+	snapshots *SnapshotArchiver // nil if no archive is configured
+	analytics *Analytics
+	anomalies *AnomalyDetector
+	random    *RandomService
+
+	invariants *InvariantChecker // nil unless invariant checks are on
+	faults     *FaultInjector    // nil unless fault injection is on
+	mirror     *Mirror           // nil unless requests are mirrored
+	admission  *AdmissionController
+	streams    *ConnectionLimiter
+	slo        *SLOTracker
+	lanes      *CommandLanes   // nil runs commands without lanes
+	rounding   EnergyRounding  // of fractional energy amounts
+	idCheck    IDCheck         // nil if IDs only need the common format
+	selfTest   *SelfTestReport // nil if the self-test is off
+
+	migrating sync.Mutex // held while a storage migration runs
+}
+
+// NewRobotHandler creates a new handler with the given storage.
+// The weather defaults to permanently sunny and the clock stands still.
+func NewRobotHandler(storage *RobotStorage) *RobotHandler {
+	events := NewEventBus()
+	tasks := NewTaskStore()
+	return &RobotHandler{
+		storage:  storage,
+		combat:   PercentageCombat{},
+		rounding: EnergyTruncate,
+		device:   SimulatedDevice{},
+		events:   events,
+		weather:  NewWeather(events, 0, 0),
+		clock:    NewWorldClock(events, 0),
+		ids:      UUIDGenerator{},
+		sessions: NewSessionStore(15 * time.Minute),
+		keys:     &APIKeyStore{keys: make(map[string]string)},
+		estop:    NewEmergencyStop(NewSimulation(time.Second)),
+		readOnly: NewReadOnlyMode(),
+		tasks:    tasks,
+		ota:      NewOTAManager(storage, tasks, events, defaultRolloutStageTicks),
+		plugins:  NewPluginRegistry(),
+		hooks:    NewHookRegistry(),
+		tenants:  NewTenantStore(newExampleWorld),
+		outbox:   NewOutbox(nil, defaultDeliveryAttempts),
+
+		transfers:     NewTransferStore(storage.Clock()),
+		programs:      NewProgramStore(),
+		behaviors:     NewBehaviorEngine(DefaultBehaviorLimits()),
+		tournaments:   NewTournamentStore(),
+		devices:       NewRegistrationStore(storage.Clock()),
+		decommissions: NewDecommissionStore(DefaultDecommissionGrace),
+
+		spectatorDelay: DefaultSpectatorDelay,
+
+		analytics: NewAnalytics(),
+		anomalies: NewAnomalyDetector(DefaultAnomalyThresholds()),
+		random:    NewRandomService(rand.Int63()),
+		slo:       NewSLOTracker(nil, false, SystemClock{}),
+	}
+}
+
+// SetEnergyRounding sets how fractional energy amounts are applied
+func (h *RobotHandler) SetEnergyRounding(rounding EnergyRounding) {
+	h.rounding = rounding
+}
+
+// SetIDCheck adds a stricter rule for the IDs clients supply
+func (h *RobotHandler) SetIDCheck(check IDCheck) {
+	h.idCheck = check
+}
+
+// checkID checks an ID a client supplied, in the request's tenant
+func (h *RobotHandler) checkID(c *gin.Context, kind IDKind, id string) error {
+	if err := CheckID(kind, id); err != nil {
+		return err
+	}
+	if h.idCheck != nil {
+		if err := h.idCheck(currentTenant(c), kind, id); err != nil {
+			return fmt.Errorf("Invalid %s ID: %w", kind, err)
+		}
+	}
+	return nil
+}
+
+// RequireValidIDs rejects requests whose path names robots or items with IDs
+// that cannot exist
+func (h *RobotHandler) RequireValidIDs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, param := range c.Params {
+			kind, isID := idParams[param.Key]
+			if !isID {
+				continue
+			}
+			if err := h.checkID(c, kind, param.Value); err != nil {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// SetCombatResolver replaces the rules used to resolve attacks
+func (h *RobotHandler) SetCombatResolver(resolver CombatResolver) {
+	h.combat = resolver
+}
+
+// SetDevice replaces the adapter that moves and pickups are carried out on
+func (h *RobotHandler) SetDevice(device DeviceAdapter) {
+	h.device = device
+}
+
+// SetEventBus replaces the bus that handler events are published to
+func (h *RobotHandler) SetEventBus(events *EventBus) {
+	h.events = events
+}
+
+// SetWeather replaces the weather
+func (h *RobotHandler) SetWeather(weather *Weather) {
+	h.weather = weather
+}
+
+// SetClock replaces the world clock
+func (h *RobotHandler) SetClock(clock *WorldClock) {
+	h.clock = clock
+}
+
+// SetIDGenerator replaces the strategy used to generate robot IDs
+func (h *RobotHandler) SetIDGenerator(ids IDGenerator) {
+	h.ids = ids
+}
+
+// SetSessions replaces the store for play tokens
+func (h *RobotHandler) SetSessions(sessions *SessionStore) {
+	h.sessions = sessions
+}
+
+// SetQuotas sets the daily limits per action type and robot
+func (h *RobotHandler) SetQuotas(quotas map[string]int) {
+	h.quotas = quotas
+}
+
+// SetMultiFloor enables floors, elevators and ramps. Without it the world
+// stays flat on floor 0.
+func (h *RobotHandler) SetMultiFloor(enabled bool) {
+	h.multiFloor = enabled
+}
+
+// SetContinuous enables the continuous world mode, in which robots can also
+// be steered by velocity. The grid mode is the default.
+func (h *RobotHandler) SetContinuous(enabled bool) {
+	h.continuous = enabled
+}
+
+// SetSensorNoise makes scan results as noisy as the scanning robot's sensor
+// quality, with random numbers drawn from the given seed
+func (h *RobotHandler) SetSensorNoise(seed int64) {
+	h.noise = newDice(seed)
+}
+
+// EnableSensorNoise makes scan results noisy like SetSensorNoise, drawing
+// from the sensor stream of the random service
+func (h *RobotHandler) EnableSensorNoise() {
+	h.noise = h.random.stream(RandomSensors)
+}
+
+// SetRandom replaces the source of randomness of the match; call it before
+// EnableSensorNoise
+func (h *RobotHandler) SetRandom(random *RandomService) {
+	h.random = random
+}
+
+// SetMinFirmware sets the oldest firmware version robots may run to accept commands
+func (h *RobotHandler) SetMinFirmware(version FirmwareVersion) {
+	h.minFirmware = &version
+}
+
+// SetEmergencyStop replaces the emergency stop
+func (h *RobotHandler) SetEmergencyStop(estop *EmergencyStop) {
+	h.estop = estop
+}
+
+// SetSelfTestReport sets the report of the startup self-test
+func (h *RobotHandler) SetSelfTestReport(report *SelfTestReport) {
+	h.selfTest = report
+}
+
+// SetReadOnlyMode replaces the read-only mode
+func (h *RobotHandler) SetReadOnlyMode(mode *ReadOnlyMode) {
+	h.readOnly = mode
+}
+
+// SetTasks replaces the store that long-running tasks are tracked in
+func (h *RobotHandler) SetTasks(tasks *TaskStore) {
+	h.tasks = tasks
+}
+
+// SetOTA replaces the manager for firmware artifacts and rollouts
+func (h *RobotHandler) SetOTA(ota *OTAManager) {
+	h.ota = ota
+}
+
+// SetPlugins replaces the registry of custom actions
+func (h *RobotHandler) SetPlugins(plugins *PluginRegistry) {
+	h.plugins = plugins
+}
+
+// SetHooks replaces the registry of before and after action hooks
+func (h *RobotHandler) SetHooks(hooks *HookRegistry) {
+	h.hooks = hooks
+}
+
+// SetTenants replaces the worlds of tenants other than the default one
+func (h *RobotHandler) SetTenants(tenants *TenantStore) {
+	h.tenants = tenants
+}
+
+// SetOutbox replaces the outbox of event deliveries
+func (h *RobotHandler) SetOutbox(outbox *Outbox) {
+	h.outbox = outbox
+}
+
+// SetSnapshots sets the archiver that snapshots are uploaded with and
+// restored from
+func (h *RobotHandler) SetSnapshots(snapshots *SnapshotArchiver) {
+	h.snapshots = snapshots
+}
+
+// SetAnalytics replaces the projections dashboards read from
+func (h *RobotHandler) SetAnalytics(analytics *Analytics) {
+	h.analytics = analytics
+}
+
+// SetAnomalyDetector replaces the detector flagging suspicious activity
+func (h *RobotHandler) SetAnomalyDetector(anomalies *AnomalyDetector) {
+	h.anomalies = anomalies
+}
+
+// SetInvariantChecker turns on the invariant checks of the worlds of
+// tenants created from now on; the checker must already watch the others
+func (h *RobotHandler) SetInvariantChecker(invariants *InvariantChecker) {
+	h.invariants = invariants
+}
+
+// SetFaultInjector enables the admin endpoints managing the fault rules of
+// the injector
+func (h *RobotHandler) SetFaultInjector(faults *FaultInjector) {
+	h.faults = faults
+}
+
+// SetSLOTracker replaces the tracker of command latencies
+func (h *RobotHandler) SetSLOTracker(slo *SLOTracker) {
+	h.slo = slo
+}
+
+// SetLanes replaces the lanes that commands without a request, like the
+// steps of behavior modules, take their robot's turn in
+func (h *RobotHandler) SetLanes(lanes *CommandLanes) {
+	h.lanes = lanes
+}
+
+// SetAdmissionController replaces the controller reporting the queue depths
+func (h *RobotHandler) SetAdmissionController(admission *AdmissionController) {
+	h.admission = admission
+}
+
+// SetConnectionLimiter replaces the limiter reporting the open streams
+func (h *RobotHandler) SetConnectionLimiter(streams *ConnectionLimiter) {
+	h.streams = streams
+}
+
+// SetMirror enables the admin endpoint reporting the mirrored requests
+func (h *RobotHandler) SetMirror(mirror *Mirror) {
+	h.mirror = mirror
+}
+
+// SetAPIKeys replaces the key store used to validate permission grantees
+func (h *RobotHandler) SetAPIKeys(keys *APIKeyStore) {
+	h.keys = keys
+}
+
+// requestBaseURL returns scheme, host and base path of the current request
+// for HATEOAS links. Reverse proxies that route by path and strip their
+// prefix announce it with X-Forwarded-Prefix, their host with X-Forwarded-Host.
+func requestBaseURL(c *gin.Context) string {
+	scheme := c.GetString("scheme")
+	if scheme == "" {
+		if c.Request.TLS != nil {
+			scheme = "https"
+		} else {
+			scheme = "http"
+		}
+	}
+	host := c.Request.Host
+	if forwarded := c.GetHeader("X-Forwarded-Host"); forwarded != "" {
+		host, _, _ = strings.Cut(forwarded, ",")
+		host = strings.TrimSpace(host)
+	}
+	return fmt.Sprintf("%s://%s%s%s", scheme, host, forwardedPrefix(c), c.GetString("base_path"))
+}
+
+// forwardedPrefix returns the cleaned X-Forwarded-Prefix header. Values that
+// are not absolute paths are ignored.
+func forwardedPrefix(c *gin.Context) string {
+	prefix := strings.TrimSpace(c.GetHeader("X-Forwarded-Prefix"))
+	if !strings.HasPrefix(prefix, "/") || strings.HasPrefix(prefix, "//") || strings.ContainsAny(prefix, "?#\\ ") {
+		return ""
+	}
+	return strings.TrimRight(prefix, "/")
+}
+
+// checkEffects rejects the request if the robot's status effects forbid the action
+func checkEffects(c *gin.Context, robot *Robot, actionType string) bool {
+	if err := robot.CanPerform(actionType); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Action not allowed: " + err.Error()})
+		return false
+	}
+	return true
+}
+
+// isDryRun reports whether the request only asks for the would-be outcome
+// of an action. Dry runs are validated like real requests but change nothing.
+func isDryRun(c *gin.Context) bool {
+	return c.Query("dryRun") == "true"
+}
+
+// errActionNotAllowed wraps status effect errors raised inside robot updates
+func errActionNotAllowed(err error) error {
+	return fmt.Errorf("Action not allowed: %w", err)
+}
+
+// updateFailed answers a failed RobotStorage.UpdateRobot call. Unknown robots
+// are reported as 404, device failures as 502 and everything the update
+// itself rejected as 409.
+func updateFailed(c *gin.Context, err error) {
+	if errors.Is(err, ErrRobotNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+	if errors.Is(err, ErrItemNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+	var deviceErr *DeviceError
+	if errors.As(err, &deviceErr) {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+}
+
+// directions maps move directions to the position change they cause
+var directions = map[string]Position{
+	"up":      {X: 0, Y: 1},
+	"down":    {X: 0, Y: -1},
+	"left":    {X: -1, Y: 0},
+	"right":   {X: 1, Y: 0},
+	"ascend":  {Z: 1}, // multi-floor maps only, needs an elevator or ramp
+	"descend": {Z: -1},
+}
+
+// CreateRobot creates a new robot. Clients may pick an ID, otherwise the
+// configured ID generator assigns one.
+func (h *RobotHandler) CreateRobot(c *gin.Context) {
+	var createReq CreateRobotRequest
+	if err := bindJSON(c, &createReq); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+
+	if createReq.Class == "" {
+		createReq.Class = ClassStandard
+	}
+	if createReq.Class != ClassStandard && createReq.Class != ClassSolar {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid robot class"})
+		return
+	}
+	if _, ok := sensorQualities[createReq.Sensor]; createReq.Sensor != "" && !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sensor quality"})
+		return
+	}
+	if _, err := ParseFirmwareVersion(createReq.Firmware); createReq.Firmware != "" && err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid firmware version"})
+		return
+	}
+
+	robot := &Robot{
+		Name:      createReq.Name,
+		Tags:      createReq.Tags,
+		Direction: "north",
+		Class:     createReq.Class,
+		Energy:    MaxEnergy,
+		Armor:     createReq.Armor,
+		Sensor:    createReq.Sensor,
+		Firmware:  createReq.Firmware,
+		Inventory: []string{},
+	}
+	if createReq.Position != nil {
+		if createReq.Position.Z != 0 && !h.multiFloor {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Multi-floor maps are disabled"})
+			return
+		}
+		if h.world(c).GetLayout().Blocked(*createReq.Position) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Position is blocked or outside the map"})
+			return
+		}
+		robot.Position = *createReq.Position
+	} else if spawn, ok := h.world(c).NextSpawnPoint(); ok {
+		robot.Position = spawn
+	}
+	if principal := currentPrincipal(c); principal != nil {
+		robot.Owner = principal.ID
+	}
+
+	if createReq.ID != "" {
+		if err := h.checkID(c, RobotIDs, createReq.ID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		robot.ID = createReq.ID
+		var limitErr *LimitError
+		if err := h.world(c).CreateRobot(robot); errors.As(err, &limitErr) {
+			abortWithLimit(c, http.StatusForbidden, limitErr)
+			return
+		} else if err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": "Robot ID already taken"})
+			return
+		}
+	} else {
+		// Retry in the unlikely case that a generated ID collides
+		created := false
+		for attempt := 0; attempt < 3 && !created; attempt++ {
+			robot.ID = h.ids.NewID()
+			err := h.world(c).CreateRobot(robot)
+			var limitErr *LimitError
+			if errors.As(err, &limitErr) {
+				abortWithLimit(c, http.StatusForbidden, limitErr)
+				return
+			}
+			created = err == nil
+		}
+		if !created {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate a unique robot ID"})
+			return
+		}
+	}
+
+	h.world(c).AddCommandAction(robot.ID, requestOrigin(c), "create", "Robot was created",
+		gin.H{"position": robot.Position, "class": robot.Class})
+	h.bus(c).PublishCommand(commandID(c), "robot_created", robot.ID, nil)
+
+	baseURL := requestBaseURL(c)
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "Robot created successfully",
+		"robot":   robot,
+		"links": []Link{
+			{Rel: "self", Href: fmt.Sprintf("%s/robot/%s/status", baseURL, robot.ID)},
+			{Rel: "actions", Href: fmt.Sprintf("%s/robot/%s/actions?page=1&size=5", baseURL, robot.ID)},
+		},
+	})
+}
+
+// GetStatus returns the current status of a robot
+func (h *RobotHandler) GetStatus(c *gin.Context) {
+	id := c.Param("id")
+	robot, err := h.world(c).GetRobot(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.robotStatus(c, robot))
+}
+
+// robotStatus builds the status representation of a robot with links to
+// the actions it can take right now
+func (h *RobotHandler) robotStatus(c *gin.Context, robot *Robot) gin.H {
+	baseURL := requestBaseURL(c)
+	links := []Link{
+		{Rel: "self", Href: fmt.Sprintf("%s/robot/%s/status", baseURL, robot.ID)},
+		{Rel: "actions", Href: fmt.Sprintf("%s/robot/%s/actions?page=1&size=5", baseURL, robot.ID)},
+		{Rel: "inventory", Href: fmt.Sprintf("%s/robot/%s/inventory", baseURL, robot.ID)},
+	}
+	links = append(links, h.affordances(c).Links(robot)...)
+
+	effects := robot.Effects
+	if effects == nil {
+		effects = []StatusEffect{}
+	}
+
+	status := gin.H{
+		"id":        robot.ID,
+		"position":  robot.Position,
+		"energy":    robot.Energy,
+		"inventory": robot.Inventory,
+		"effects":   effects,
+		"links":     links,
+
+		"max_energy":     robot.maxEnergy(),
+		"battery_cycles": robot.BatteryCycles,
+		"maintenance":    robot.Maintenance,
+		"firmware":       robot.Firmware,
+	}
+	if robot.Exact != nil {
+		status["exact_position"] = robot.exactPosition()
+		status["velocity"] = robot.Velocity
+		status["commanded_velocity"] = robot.Commanded
+		status["heading"] = robot.currentHeading()
+		status["max_speed"] = limitsOf(robot).MaxSpeed
+	}
+	return status
+}
+
+// maxBatchStatusIDs limits the number of robots in one batch status request
+const maxBatchStatusIDs = 100
+
+// BatchStatus returns the status of several robots at once. Unknown IDs get
+// an error entry instead of failing the whole request.
+func (h *RobotHandler) BatchStatus(c *gin.Context) {
+	var request struct {
+		IDs []string `json:"ids" binding:"required,min=1"`
+	}
+	if err := bindJSON(c, &request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A non-empty list of robot IDs is required", "details": bindErrorDetails(err)})
+		return
+	}
+	if len(request.IDs) > maxBatchStatusIDs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("At most %d robot IDs per request", maxBatchStatusIDs)})
+		return
+	}
+
+	for _, id := range request.IDs {
+		if err := h.checkID(c, RobotIDs, id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "id": id})
+			return
+		}
+	}
+
+	results := make([]gin.H, 0, len(request.IDs))
+	for _, id := range request.IDs {
+		robot, err := h.world(c).GetRobot(id)
+		if err != nil {
+			results = append(results, gin.H{"id": id, "error": "Robot not found"})
+			continue
+		}
+		results = append(results, gin.H{"id": id, "status": h.robotStatus(c, robot)})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// MoveRobot moves a robot in the specified direction
+func (h *RobotHandler) MoveRobot(c *gin.Context) {
+	id := c.Param("id")
+	robot, err := h.world(c).GetRobot(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	if !checkEffects(c, robot, "move") {
+		return
+	}
+
+	var moveReq MoveRequest
+	if err := bindJSON(c, &moveReq); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+
+	delta, ok := directions[moveReq.Direction]
+	if !ok || (delta.Z != 0 && !h.multiFloor) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid direction"})
+		return
+	}
+
+	// Moving costs energy depending on the weather, less in boost zones
+	weatherCost := h.weather.Current().MoveCost
+	layout := h.world(c).GetLayout()
+	position := layout.Step(robot.Position, delta)
+	cost := layout.MoveCost(position, weatherCost)
+	if !h.beforeMove(c, robot, position) {
+		return
+	}
+	if isDryRun(c) {
+		if err := layout.CanMove(robot.Position, position); err != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if robot.Energy < cost {
+			c.JSON(http.StatusConflict, gin.H{"error": "Not enough energy to move"})
+			return
+		}
+		response := gin.H{
+			"dry_run":     true,
+			"position":    position,
+			"energy_cost": cost,
+			"energy":      robot.Energy - cost,
+		}
+		if hazard := h.world(c).HazardAt(position); hazard != nil {
+			response["hazard"] = hazard
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+	robot, err = h.moveRobot(c, id, moveReq.Direction, weatherCost, nil)
+	if err != nil {
+		updateFailed(c, err)
+		return
+	}
+
+	response := gin.H{
+		"message":     "Robot moved successfully",
+		"position":    robot.Position,
+		"energy_cost": cost,
+	}
+	if hazard := h.world(c).EnterHazard(id); hazard != nil {
+		response["hazard"] = hazard
+		if current, err := h.world(c).GetRobot(id); err == nil {
+			robot = current
+		}
+	}
+	response["energy"] = robot.Energy
+	h.bus(c).PublishCommand(commandID(c), "robot_moved", id, gin.H{"position": robot.Position, "direction": moveReq.Direction})
+	publishZoneEvents(h.bus(c), h.world(c).UpdateZones())
+	h.afterMove(c, robot)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// PickupItem allows a robot to pick up an item
+func (h *RobotHandler) PickupItem(c *gin.Context) {
+	id := c.Param("id")
+	itemID := c.Param("itemId")
+
+	robot, err := h.world(c).GetRobot(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	if !checkEffects(c, robot, "pickup") {
+		return
+	}
+
+	if !h.world(c).ItemExists(itemID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+	if !h.beforePickup(c, robot, itemID) {
+		return
+	}
+
+	if isDryRun(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run":   true,
+			"inventory": append(robot.Inventory, itemID),
+		})
+		return
+	}
+
+	// Add item to inventory
+	robot, err = h.pickupItem(c, id, itemID, nil)
+	if err != nil {
+		updateFailed(c, err)
+		return
+	}
+	h.bus(c).PublishCommand(commandID(c), "item_picked_up", id, gin.H{"item": itemID})
+	h.afterPickup(c, robot, itemID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Item picked up successfully",
+		"inventory": robot.Inventory,
+	})
+}
+
+// PutdownItem allows a robot to put down an item
+func (h *RobotHandler) PutdownItem(c *gin.Context) {
+	id := c.Param("id")
+	itemID := c.Param("itemId")
+
+	robot, err := h.world(c).GetRobot(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	if !checkEffects(c, robot, "putdown") {
+		return
+	}
+
+	// Check if robot has the item
+	if !containsString(robot.Inventory, itemID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Robot does not have this item"})
+		return
+	}
+	if !h.beforePutdown(c, robot, itemID) {
+		return
+	}
+
+	// Update robot and world
+	robot, err = h.putdownItem(c, id, itemID, nil)
+	if err != nil {
+		updateFailed(c, err)
+		return
+	}
+	h.bus(c).PublishCommand(commandID(c), "item_put_down", id, gin.H{"item": itemID, "position": robot.Position})
+	h.afterPutdown(c, robot, itemID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "Item put down successfully",
+		"inventory": robot.Inventory,
+	})
+}
+
+// moveRobot moves a robot one step in a direction in a single update. The
+// action's data is extended by extra, e.g. for compensating moves.
+func (h *RobotHandler) moveRobot(c *gin.Context, id, direction string, cost int, extra gin.H) (*Robot, error) {
+	return h.moveRobotIn(c.Request.Context(), h.world(c), requestOrigin(c), id, direction, cost, extra)
+}
+
+// moveRobotIn is moveRobot for callers without a request, e.g. the
+// simulation. The cost is lowered if the robot enters a boost zone.
+func (h *RobotHandler) moveRobotIn(ctx context.Context, world *RobotStorage, origin ActionOrigin, id, direction string, cost int, extra gin.H) (*Robot, error) {
+	delta := directions[direction]
+	layout := world.GetLayout()
+	var target Position
+	var moveCost int
+	check := func(robot *Robot) error {
+		if err := robot.CanPerform("move"); err != nil {
+			return errActionNotAllowed(err)
+		}
+		target = layout.Step(robot.Position, delta)
+		if err := layout.CanMove(robot.Position, target); err != nil {
+			return err
+		}
+		moveCost = layout.MoveCost(target, cost)
+		if robot.Energy < moveCost {
+			return errors.New("Not enough energy to move")
+		}
+		return nil
+	}
+	move := func(ctx context.Context, robot *Robot) error {
+		return h.device.Move(ctx, robot, target)
+	}
+
+	return h.runDeviceCommand(ctx, world, id, check, move, func(verify func(robot *Robot) error) (*Robot, error) {
+		layout = world.GetLayout() // the map may have changed while the device moved
+		return world.UpdateRobot(id, func(robot *Robot) error {
+			if err := verify(robot); err != nil {
+				return err
+			}
+			from := robot.Position
+			robot.Position = target
+			robot.Energy -= moveCost
+			robot.movedThisTick = true
+			data := gin.H{"from": from, "to": target, "direction": direction, "energy_cost": moveCost}
+			for key, value := range extra {
+				data[key] = value
+			}
+			appendCommandAction(robot, origin, "move", fmt.Sprintf("Moved %s", direction), data)
+			return nil
+		})
+	})
+}
+
+// pickupItem adds an item to a robot's inventory in a single update
+func (h *RobotHandler) pickupItem(c *gin.Context, id, itemID string, extra gin.H) (*Robot, error) {
+	return h.pickupItemIn(c.Request.Context(), h.world(c), requestOrigin(c), id, itemID, extra)
+}
+
+// pickupItemIn is pickupItem for callers without a request
+func (h *RobotHandler) pickupItemIn(ctx context.Context, world *RobotStorage, origin ActionOrigin, id, itemID string, extra gin.H) (*Robot, error) {
+	check := func(robot *Robot) error {
+		if err := robot.CanPerform("pickup"); err != nil {
+			return errActionNotAllowed(err)
+		}
+		return nil
+	}
+	pickup := func(ctx context.Context, robot *Robot) error {
+		return h.device.Pickup(ctx, robot, itemID)
+	}
+
+	return h.runDeviceCommand(ctx, world, id, check, pickup, func(verify func(robot *Robot) error) (*Robot, error) {
+		return world.PickupItem(id, itemID, func(robot *Robot) error {
+			if err := verify(robot); err != nil {
+				return err
+			}
+			robot.Inventory = append(robot.Inventory, itemID)
+			data := gin.H{"item_id": itemID, "position": robot.Position}
+			for key, value := range extra {
+				data[key] = value
+			}
+			appendCommandAction(robot, origin, "pickup", fmt.Sprintf("Picked up item %s", itemID), data)
+			return nil
+		})
+	})
+}
+
+// putdownItem places an item from a robot's inventory at its position in a
+// single update
+func (h *RobotHandler) putdownItem(c *gin.Context, id, itemID string, extra gin.H) (*Robot, error) {
+	return h.putdownItemIn(c.Request.Context(), h.world(c), requestOrigin(c), id, itemID, extra)
+}
+
+// putdownItemIn is putdownItem for callers without a request
+func (h *RobotHandler) putdownItemIn(ctx context.Context, world *RobotStorage, origin ActionOrigin, id, itemID string, extra gin.H) (*Robot, error) {
+	check := func(robot *Robot) error {
+		if err := robot.CanPerform("putdown"); err != nil {
+			return errActionNotAllowed(err)
+		}
+		if !containsString(robot.Inventory, itemID) {
+			return errors.New("Robot does not have this item")
+		}
+		return nil
+	}
+	putdown := func(ctx context.Context, robot *Robot) error {
+		return h.device.Putdown(ctx, robot, itemID)
+	}
+
+	return h.runDeviceCommand(ctx, world, id, check, putdown, func(verify func(robot *Robot) error) (*Robot, error) {
+		return world.PutdownItem(id, itemID, func(robot *Robot) error {
+			if err := verify(robot); err != nil {
+				return err
+			}
+			var newInventory []string
+			removed := false
+			for _, item := range robot.Inventory {
+				if item == itemID && !removed {
+					removed = true
+				} else {
+					newInventory = append(newInventory, item)
+				}
+			}
+			robot.Inventory = newInventory
+			data := gin.H{"item_id": itemID, "position": robot.Position}
+			for key, value := range extra {
+				data[key] = value
+			}
+			appendCommandAction(robot, origin, "putdown", fmt.Sprintf("Put down item %s", itemID), data)
+			return nil
+		})
+	})
+}
+
+// containsString reports whether the list contains the value
+func containsString(list []string, value string) bool {
+	for _, entry := range list {
+		if entry == value {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateState updates a robot's state
+func (h *RobotHandler) UpdateState(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.world(c).GetRobot(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	var stateReq StateUpdateRequest
+	if err := bindJSON(c, &stateReq); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if stateReq.Firmware != nil {
+		if _, err := ParseFirmwareVersion(*stateReq.Firmware); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid firmware version"})
+			return
+		}
+	}
+
+	robot, err := h.world(c).UpdateRobot(id, func(robot *Robot) error {
+		if robot.Decommissioned {
+			return errActionNotAllowed(errors.New("robot is decommissioned"))
+		}
+
+		// Update energy if provided
+		if stateReq.Energy != nil {
+			robot.Energy, robot.EnergyFraction = *stateReq.Energy, 0
+			appendCommandAction(robot, requestOrigin(c), "update", fmt.Sprintf("Updated energy to %d", *stateReq.Energy),
+				gin.H{"energy": *stateReq.Energy})
+		}
+
+		// Update position if provided
+		if stateReq.Position != nil {
+			from := robot.Position
+			robot.Position = *stateReq.Position
+			appendCommandAction(robot, requestOrigin(c), "update", fmt.Sprintf("Updated position to (%d,%d)",
+				stateReq.Position.X, stateReq.Position.Y), gin.H{"from": from, "to": robot.Position})
+		}
+
+		// Record the firmware after an OTA flash
+		if stateReq.Firmware != nil {
+			robot.Firmware = *stateReq.Firmware
+			appendCommandAction(robot, requestOrigin(c), "update", fmt.Sprintf("Updated firmware to %s", *stateReq.Firmware),
+				gin.H{"firmware": *stateReq.Firmware})
+		}
+		return nil
+	})
+	if err != nil {
+		updateFailed(c, err)
+		return
+	}
+
+	if stateReq.Position != nil && h.world(c).EnterHazard(id) != nil {
+		if current, err := h.world(c).GetRobot(id); err == nil {
+			robot = current
+		}
+	}
+	if stateReq.Position != nil {
+		publishZoneEvents(h.bus(c), h.world(c).UpdateZones())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Robot state updated successfully",
+		"robot":   robot,
+	})
+}
+
+// maxPageSize caps the size query parameter
+const maxPageSize = 1000
+
+// parsePagination reads the page and size query parameters; missing ones
+// default to the first page and the given size. Invalid values are answered
+// with 400 and ok is false.
+func parsePagination(c *gin.Context, defaultSize int) (page, size int, ok bool) {
+	page, size = 1, defaultSize
+	if value, given := c.GetQuery("page"); given {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "page must be a positive integer"})
+			return 0, 0, false
+		}
+		page = parsed
+	}
+	if value, given := c.GetQuery("size"); given {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxPageSize {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("size must be an integer from 1 to %d", maxPageSize)})
+			return 0, 0, false
+		}
+		size = parsed
+	}
+	return page, size, true
+}
+
+// paginate calculates the page info and the slice bounds for a page.
+// Pages beyond the last one are clamped to the last page; an empty result
+// always yields page 1.
+func paginate(totalElements, page, size int) (PageInfo, int, int) {
+	totalPages := int(math.Ceil(float64(totalElements) / float64(size)))
+
+	if page > totalPages {
+		page = totalPages
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	startIndex := (page - 1) * size
+	endIndex := startIndex + size
+	if endIndex > totalElements {
+		endIndex = totalElements
+	}
+
+	pageInfo := PageInfo{
+		Number:        page,
+		Size:          size,
+		TotalElements: totalElements,
+		TotalPages:    totalPages,
+		HasNext:       page < totalPages,
+		HasPrevious:   page > 1,
+	}
+	return pageInfo, startIndex, endIndex
+}
+
+// GetActions returns all actions performed by a robot with pagination
+func (h *RobotHandler) GetActions(c *gin.Context) {
+	id := c.Param("id")
+	robot, err := h.world(c).GetRobot(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	// Get pagination parameters
+	page, size, ok := parsePagination(c, 5)
+	if !ok {
+		return
+	}
+	pageInfo, startIndex, endIndex := paginate(len(robot.Actions), page, size)
+	page = pageInfo.Number
+
+	// Create paginated actions slice with proper scheme and base path
+	baseURL := requestBaseURL(c)
+
+	var paginatedActions []ActionWithLinks
+	for i := startIndex; i < endIndex; i++ {
+		action := robot.Actions[i]
+		actionWithLinks := ActionWithLinks{
+			Action: action,
+			Links: []Link{
+				{
+					Rel:  "self",
+					Href: fmt.Sprintf("%s/robot/%s/actions/%d", baseURL, id, i+1),
+				},
+			},
+		}
+		paginatedActions = append(paginatedActions, actionWithLinks)
+	}
+
+	// Create navigation links with proper scheme
+	var links []Link
+	if pageInfo.HasNext {
+		links = append(links, Link{
+			Rel:  "next",
+			Href: fmt.Sprintf("%s/robot/%s/actions?page=%d&size=%d", baseURL, id, page+1, size),
+		})
+	}
+
+	if pageInfo.HasPrevious {
+		links = append(links, Link{
+			Rel:  "previous",
+			Href: fmt.Sprintf("%s/robot/%s/actions?page=%d&size=%d", baseURL, id, page-1, size),
+		})
+	}
+
+	response := PaginatedActions{
+		Page:    pageInfo,
+		Actions: paginatedActions,
+		Links:   links,
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// AttackRobot handles one robot attacking another
+func (h *RobotHandler) AttackRobot(c *gin.Context) {
+	id := c.Param("id")
+	targetID := c.Param("targetId")
+
+	// Get attacker
+	attacker, err := h.world(c).GetRobot(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Attacker robot not found"})
+		return
+	}
+
+	// Get target
+	target, err := h.world(c).GetRobot(targetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Target robot not found"})
+		return
+	}
+
+	if !checkEffects(c, attacker, "attack") {
+		return
+	}
+	if target.Maintenance {
+		c.JSON(http.StatusConflict, gin.H{"error": "Target is in maintenance"})
+		return
+	}
+	layout := h.world(c).GetLayout()
+	if !inAttackRange(layout, attacker, target) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Target is out of range", "range": AttackRange,
+			"distance": layout.Distance(attacker.Position, target.Position)})
+		return
+	}
+	if zone := safeZone(layout, attacker, target); zone != "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Attacks are not allowed in safe zones", "zone": zone})
+		return
+	}
+	if !h.beforeAttack(c, attacker, target) {
+		return
+	}
+
+	if isDryRun(c) {
+		estimate := h.combat.Estimate(attacker, target)
+		// Bad weather lowers the chance to hit; damage never exceeds the target's energy
+		estimate.HitChance *= float64(h.weather.Current().Accuracy) / 100
+		estimate.MinDamage = min(estimate.MinDamage, target.Energy)
+		estimate.MaxDamage = min(estimate.MaxDamage, target.Energy)
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run":         true,
+			"combat_rules":    h.combat.Name(),
+			"estimate":        estimate,
+			"attacker_energy": max(attacker.Energy-estimate.AttackerCost, 0),
+		})
+		return
+	}
+
+	// Let the configured combat rules decide the outcome
+	result := h.combat.Resolve(attacker, target)
+
+	// Bad weather can make a hit miss after all
+	if result.Hit && !h.weather.Hits() {
+		result = result.missed()
+	}
+
+	message := "Attack successful"
+	if !result.Hit {
+		message = "Attack missed"
+	}
+
+	// Save both robots in one step, so the attacker never pays for an attack
+	// that did not reach the target; energy doesn't go below 0
+	robots, err := h.world(c).UpdateRobots([]string{id, targetID}, func(robots []*Robot) error {
+		attacker, target := robots[0], robots[1]
+		if err := attacker.CanPerform("attack"); err != nil {
+			return errActionNotAllowed(err)
+		}
+		// Either robot may have moved since the check above
+		if !inAttackRange(layout, attacker, target) {
+			return ErrOutOfRange
+		}
+		cost := h.rounding.drain(attacker, result.cost())
+		result.Damage = h.rounding.drain(target, result.damage())
+		data := gin.H{"target_id": targetID, "hit": result.Hit, "damage": result.Damage, "energy_cost": cost}
+		if result.Hit {
+			appendCommandAction(attacker, requestOrigin(c), "attack", fmt.Sprintf("Attacked robot %s", targetID), data)
+		} else {
+			appendCommandAction(attacker, requestOrigin(c), "attack", fmt.Sprintf("Missed robot %s", targetID), data)
+		}
+
+		if result.Hit {
+			appendCommandAction(target, requestOrigin(c), "damaged", fmt.Sprintf("Damaged by robot %s", id),
+				gin.H{"attacker_id": id, "damage": result.Damage})
+		}
+		if result.Effect != nil {
+			applyEffect(target, *result.Effect)
+		}
+		return nil
+	})
+	if err != nil {
+		updateFailed(c, err)
+		return
+	}
+	attacker, target = robots[0], robots[1]
+
+	h.bus(c).PublishCommand(commandID(c), "robot_attacked", targetID, gin.H{
+		"attacker": id, "hit": result.Hit, "damage": result.Damage, "position": target.Position,
+	})
+	h.afterAttack(c, attacker, target, result)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         message,
+		"hit":             result.Hit,
+		"effect_applied":  result.Effect,
+		"combat_rules":    h.combat.Name(),
+		"attacker_energy": attacker.Energy,
+		"target_energy":   target.Energy,
+		"damage_dealt":    result.Damage,
+	})
+}
+
+// Scan returns robots and hazards within a radius around a robot
+func (h *RobotHandler) Scan(c *gin.Context) {
+	id := c.Param("id")
+	robot, err := h.world(c).GetRobot(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	if !checkEffects(c, robot, "scan") {
+		return
+	}
+
+	radius, err := strconv.Atoi(c.DefaultQuery("radius", "5"))
+	if err != nil || radius < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid radius"})
+		return
+	}
+	radius = h.clock.ScanRadius(radius)
+
+	// A HEAD request learns whether the scan would succeed without scanning,
+	// which would draw sensor noise and record an action
+	if isHeadRequest(c) {
+		c.Status(http.StatusOK)
+		return
+	}
+
+	result := ScanResult{
+		Position: robot.Position,
+		Radius:   radius,
+		Robots:   []RobotSighting{},
+		Hazards:  h.world(c).HazardsNear(robot.Position, radius),
+	}
+	if h.noise != nil {
+		result.Sensor = robot.Sensor
+		if result.Sensor == "" {
+			result.Sensor = SensorPerfect
+		}
+	}
+	layout := h.world(c).GetLayout()
+	for _, other := range h.world(c).GetAllRobots() {
+		if other.ID == id || layout.Distance(robot.Position, other.Position) > radius {
+			continue
+		}
+		if sighting, detected := sense(h.noise, layout, robot, other); detected {
+			result.Robots = append(result.Robots, sighting)
+		}
+	}
+
+	h.world(c).AddAction(id, "scan", fmt.Sprintf("Scanned radius %d", radius))
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetWorldMap returns the positions of all robots and hazards
+func (h *RobotHandler) GetWorldMap(c *gin.Context) {
+	worldMap := WorldMap{
+		MapLayout: h.world(c).GetLayout(),
+		Robots:    []RobotSighting{},
+		Hazards:   h.world(c).GetHazards(),
+	}
+	for _, robot := range h.world(c).GetAllRobots() {
+		worldMap.Robots = append(worldMap.Robots, RobotSighting{
+			ID:          robot.ID,
+			Position:    robot.Position,
+			Maintenance: robot.Maintenance,
+		})
+	}
+
+	c.JSON(http.StatusOK, worldMap)
+}
+
+// GetWeather returns the current weather and its effects
+func (h *RobotHandler) GetWeather(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"weather": h.weather.Current(),
+		"since":   h.weather.Since(),
+	})
+}
+
+// GetTime returns the world clock
+func (h *RobotHandler) GetTime(c *gin.Context) {
+	c.JSON(http.StatusOK, h.clock.Now())
+}
+
+// SetTimeSpeed changes how fast the world clock runs
+func (h *RobotHandler) SetTimeSpeed(c *gin.Context) {
+	var speedReq ClockSpeedRequest
+	if err := bindJSON(c, &speedReq); err != nil || speedReq.Speed == nil {
+		invalidRequest(c, err)
+		return
+	}
+
+	if err := h.clock.SetSpeed(*speedReq.Speed); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, h.clock.Now())
+}
+
+// itemSorters maps the sort query parameter to a comparison; ties are broken by ID
+var itemSorters = map[string]func(a, b Item) bool{
+	"id":     func(a, b Item) bool { return a.ID < b.ID },
+	"type":   func(a, b Item) bool { return a.Type < b.Type },
+	"weight": func(a, b Item) bool { return a.Weight < b.Weight },
+}
+
+// ListItems returns the items lying in the world with filtering, sorting and pagination.
+// Supported query parameters: type, nearX/nearY/radius, sort (id, type, weight;
+// prefix with "-" for descending), page and size.
+func (h *RobotHandler) ListItems(c *gin.Context) {
+	items := h.world(c).GetWorldItems()
+
+	// Filter by type
+	if itemType := c.Query("type"); itemType != "" {
+		var filtered []Item
+		for _, item := range items {
+			if item.Type == itemType {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+
+	// Filter by distance, all three parameters are required together
+	nearX, hasX := c.GetQuery("nearX")
+	nearY, hasY := c.GetQuery("nearY")
+	radiusStr, hasRadius := c.GetQuery("radius")
+	if hasX || hasY || hasRadius {
+		x, errX := strconv.Atoi(nearX)
+		y, errY := strconv.Atoi(nearY)
+		radius, errRadius := strconv.Atoi(radiusStr)
+		if errX != nil || errY != nil || errRadius != nil || radius < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "nearX, nearY and radius must be given together as integers"})
+			return
+		}
+
+		center := Position{X: x, Y: y}
+		layout := h.world(c).GetLayout()
+		var filtered []Item
+		for _, item := range items {
+			if layout.Distance(item.Position, center) <= radius {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+
+	// Sort
+	sortBy := c.DefaultQuery("sort", "id")
+	descending := strings.HasPrefix(sortBy, "-")
+	less, exists := itemSorters[strings.TrimPrefix(sortBy, "-")]
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort field, use id, type or weight"})
+		return
+	}
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if descending {
+			a, b = b, a
+		}
+		if less(a, b) || less(b, a) {
+			return less(a, b)
+		}
+		return items[i].ID < items[j].ID
+	})
+
+	// Paginate
+	page, size, ok := parsePagination(c, 20)
+	if !ok {
+		return
+	}
+	pageInfo, startIndex, endIndex := paginate(len(items), page, size)
+
+	response := PaginatedItems{
+		AvailableItems: []string{},
+		TotalCount:     len(items),
+		Page:           pageInfo,
+		Items:          []Item{},
+	}
+	for _, item := range items[startIndex:endIndex] {
+		response.Items = append(response.Items, item)
+		response.AvailableItems = append(response.AvailableItems, item.ID)
+	}
+
+	// Navigation links keep all other query parameters
+	pageLink := func(rel string, number int) Link {
+		query := c.Request.URL.Query()
+		query.Set("page", strconv.Itoa(number))
+		query.Set("size", strconv.Itoa(size))
+		return Link{Rel: rel, Href: requestBaseURL(c) + "/items?" + query.Encode()}
+	}
+	if pageInfo.HasNext {
+		response.Links = append(response.Links, pageLink("next", pageInfo.Number+1))
+	}
+	if pageInfo.HasPrevious {
+		response.Links = append(response.Links, pageLink("previous", pageInfo.Number-1))
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// Search looks up robots, items and actions matching the query parameter q
+func (h *RobotHandler) Search(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter q is required"})
+		return
+	}
+
+	page, size, ok := parsePagination(c, 20)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, h.world(c).Search(query, page, size))
+}
+
+// ownerOnly checks that the caller owns the robot; it always passes when
+// authentication is disabled
+func ownerOnly(c *gin.Context, robot *Robot) bool {
+	principal := currentPrincipal(c)
+	if principal != nil && principal.Provider == "session" {
+		// Short-lived play tokens must not create lasting delegations
+		c.JSON(http.StatusForbidden, gin.H{"error": "Session tokens cannot manage permissions"})
+		return false
+	}
+	if principal != nil && robot.Owner != principal.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can manage permissions"})
+		return false
+	}
+	return true
+}
+
+// GrantPermissions lets a robot owner delegate control to another API key
+func (h *RobotHandler) GrantPermissions(c *gin.Context) {
+	id := c.Param("id")
+	robot, err := h.world(c).GetRobot(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	if !ownerOnly(c, robot) {
+		return
+	}
+
+	var grantReq PermissionGrantRequest
+	if err := bindJSON(c, &grantReq); err != nil || grantReq.Grantee == "" {
+		invalidRequest(c, err)
+		return
+	}
+	// Principals of other providers ("oidc:...") can't be checked up front,
+	// plain names must belong to a configured API key
+	if !strings.Contains(grantReq.Grantee, ":") && !h.keys.Has(grantReq.Grantee) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown grantee %q", grantReq.Grantee)})
+		return
+	}
+	for _, permission := range grantReq.Permissions {
+		if !validPermission(permission) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown permission %q", permission)})
+			return
+		}
+	}
+
+	h.world(c).GrantPermissions(id, grantReq.Grantee, grantReq.Permissions)
+	if len(grantReq.Permissions) == 0 {
+		h.world(c).AddCommandAction(id, requestOrigin(c), "permissions", fmt.Sprintf("Revoked all permissions of %s", grantReq.Grantee),
+			gin.H{"grantee": grantReq.Grantee, "permissions": []string{}})
+	} else {
+		h.world(c).AddCommandAction(id, requestOrigin(c), "permissions", fmt.Sprintf("Granted %s to %s",
+			strings.Join(grantReq.Permissions, ", "), grantReq.Grantee), gin.H{"grantee": grantReq.Grantee, "permissions": grantReq.Permissions})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Permissions updated successfully",
+		"permissions": h.world(c).GetPermissions(id),
+	})
+}
+
+// GetPermissions lists who may control a robot
+func (h *RobotHandler) GetPermissions(c *gin.Context) {
+	id := c.Param("id")
+	robot, err := h.world(c).GetRobot(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	if !ownerOnly(c, robot) {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"owner":       robot.Owner,
+		"permissions": h.world(c).GetPermissions(id),
+	})
+}
+
+// GetMe returns the caller's identity and the robots they own
+func (h *RobotHandler) GetMe(c *gin.Context) {
+	principal := currentPrincipal(c)
+	if principal == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication is disabled"})
+		return
+	}
+
+	owned := []string{}
+	for _, robot := range h.world(c).GetAllRobots() {
+		if robot.Owner == principal.ID {
+			owned = append(owned, robot.ID)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"principal": principal,
+		"robots":    owned,
+	})
+}
+
+// CreateSession issues a short-lived play token bound to a single robot.
+// The token acts on behalf of the caller, so it never grants more than
+// the caller's own permissions on that robot.
+func (h *RobotHandler) CreateSession(c *gin.Context) {
+	principal := currentPrincipal(c)
+	if principal == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication is disabled"})
+		return
+	}
+	if principal.Robot != "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Session tokens cannot issue sessions"})
+		return
+	}
+
+	var sessionReq SessionRequest
+	if err := bindJSON(c, &sessionReq); err != nil || sessionReq.RobotID == "" || sessionReq.TTLSeconds < 0 {
+		invalidRequest(c, err)
+		return
+	}
+	if _, err := h.world(c).GetRobot(sessionReq.RobotID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	// The session stays in the tenant it was issued for
+	owner := *principal
+	owner.Tenant = currentTenant(c)
+	session, err := h.sessions.Issue(owner, sessionReq.RobotID, time.Duration(sessionReq.TTLSeconds)*time.Second)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create session"})
+		return
+	}
+
+	baseURL := requestBaseURL(c)
+	c.JSON(http.StatusCreated, gin.H{
+		"token":      session.Token,
+		"robot_id":   session.RobotID,
+		"expires_at": session.ExpiresAt,
+		"links": []Link{
+			{Rel: "robot", Href: fmt.Sprintf("%s/robot/%s/status", baseURL, session.RobotID)},
+			{Rel: "revoke", Href: baseURL + "/sessions/current"},
+		},
+	})
+}
+
+// RevokeSession ends the session given in the X-Session-Token header.
+// Knowing the token is enough to revoke it. The token is never part of the
+// URL, so it does not end up in access logs.
+func (h *RobotHandler) RevokeSession(c *gin.Context) {
+	token := c.GetHeader("X-Session-Token")
+	if token == "" || !h.sessions.Revoke(token) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked successfully"})
+}
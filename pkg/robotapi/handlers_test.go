@@ -0,0 +1,440 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testAdminToken = "test-admin-token"
+
+// placeInRange puts the target right next to the attacker, so attacks reach it
+func placeInRange(t *testing.T, storage *RobotStorage, attackerID, targetID string) {
+	attacker, err := storage.GetRobot(attackerID)
+	require.NoError(t, err)
+	_, err = storage.UpdateRobot(targetID, func(robot *Robot) error {
+		robot.Position = attacker.Position.Add(Position{X: 1})
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func setupTestRouter() (*gin.Engine, *RobotStorage) {
+	return setupTestRouterWithKeys("")
+}
+
+// setupTestRouterWithKeys sets up the test router with API key authentication,
+// keys are given as "name=key" pairs like in the API_KEYS variable
+func setupTestRouterWithKeys(apiKeys string) (*gin.Engine, *RobotStorage) {
+	gin.SetMode(gin.TestMode)
+	router := gin.Default()
+	router.Use(AssignCommandID())
+
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+
+	keys, _ := NewAPIKeyStore(apiKeys)
+	handler.SetAPIKeys(keys)
+	sessions := NewSessionStore(15 * time.Minute)
+	handler.SetSessions(sessions)
+	auth := NewAuthenticator(keys, nil, sessions)
+	authenticate := Authenticate(auth)
+
+	router.GET("/me", authenticate, handler.GetMe)
+	router.GET("/items", handler.ListItems)
+	router.GET("/search", handler.Search)
+	router.POST("/robots", authenticate, handler.CreateRobot)
+	router.POST("/robots/status", authenticate, handler.BatchStatus)
+	router.POST("/sessions", authenticate, handler.CreateSession)
+	router.DELETE("/sessions/current", handler.RevokeSession)
+
+	api := router.Group("/robot", authenticate)
+	{
+		api.GET("/:id/status", handler.GetStatus)
+		api.POST("/:id/move", handler.RequirePermission(PermMove), handler.RequireQuota("move"), handler.MoveRobot)
+		api.POST("/:id/pickup/:itemId", handler.RequirePermission(PermItems), handler.RequireQuota("pickup"), handler.PickupItem)
+		api.POST("/:id/putdown/:itemId", handler.RequirePermission(PermItems), handler.RequireQuota("putdown"), handler.PutdownItem)
+		api.PATCH("/:id/state", handler.RequirePermission(PermUpdate), handler.UpdateState)
+		api.GET("/:id/actions", handler.GetActions)
+		api.POST("/:id/attack/:targetId", handler.RequirePermission(PermAttack), handler.RequireQuota("attack"), handler.AttackRobot)
+		api.GET("/:id/scan", handler.RequirePermission(PermScan), handler.RequireQuota("scan"), handler.Scan)
+		api.GET("/:id/permissions", handler.GetPermissions)
+		api.POST("/:id/permissions", handler.GrantPermissions)
+	}
+
+	world := router.Group("/world")
+	{
+		world.GET("/map", handler.GetWorldMap)
+		world.GET("/weather", handler.GetWeather)
+		world.GET("/time", handler.GetTime)
+	}
+
+	admin := router.Group("/admin", RequireAdmin(testAdminToken, auth))
+	{
+		admin.PATCH("/world/time", handler.SetTimeSpeed)
+	}
+
+	return router, storage
+}
+
+func TestGetStatus(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/robot/robot1/status", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	assert.Equal(t, "robot1", response["id"])
+	assert.NotNil(t, response["position"])
+	assert.NotNil(t, response["energy"])
+	assert.NotNil(t, response["inventory"])
+	assert.NotNil(t, response["links"])
+
+	links, ok := response["links"].([]interface{})
+	assert.True(t, ok)
+	assert.GreaterOrEqual(t, len(links), 1)
+}
+
+func TestCommandIDCorrelation(t *testing.T) {
+	router, storage := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/robot/robot1/move", bytes.NewBufferString(`{"direction": "up"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	commandID := w.Header().Get("X-Command-ID")
+	assert.NotEmpty(t, commandID)
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, commandID, robot.Actions[len(robot.Actions)-1].CommandID)
+
+	// Reads are not commands
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/robot/robot1/status", nil)
+	router.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("X-Command-ID"))
+}
+
+func TestDryRun(t *testing.T) {
+	router, storage := setupTestRouter()
+	before, _ := storage.GetRobot("robot1")
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/robot/robot1/move?dryRun=true", bytes.NewBufferString(`{"direction": "up"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var move struct {
+		DryRun   bool     `json:"dry_run"`
+		Position Position `json:"position"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &move))
+	assert.True(t, move.DryRun)
+	assert.Equal(t, Position{X: 0, Y: 1}, move.Position)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/robot/robot1/pickup/item1?dryRun=true", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"inventory":["item1"]`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/robot/robot1/attack/robot2?dryRun=true", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code, "dry runs check the range like attacks")
+
+	placeInRange(t, storage, "robot1", "robot2")
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/robot/robot1/attack/robot2?dryRun=true", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"max_damage":15`)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/robot/robot1/pickup/nothing?dryRun=true", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code, "dry runs are validated")
+
+	// Nothing changed
+	after, _ := storage.GetRobot("robot1")
+	assert.Equal(t, before, after)
+	assert.True(t, storage.ItemExists("item1"))
+	target, _ := storage.GetRobot("robot2")
+	assert.Equal(t, 100, target.Energy)
+}
+
+func TestBatchStatus(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/robots/status", bytes.NewBufferString(`{"ids": ["robot2", "ghost", "robot1"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Results []struct {
+			ID     string                 `json:"id"`
+			Status map[string]interface{} `json:"status"`
+			Error  string                 `json:"error"`
+		} `json:"results"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Results, 3)
+	assert.Equal(t, "robot2", response.Results[0].Status["id"])
+	assert.Equal(t, "Robot not found", response.Results[1].Error)
+	assert.Nil(t, response.Results[1].Status)
+	assert.Equal(t, "robot1", response.Results[2].Status["id"])
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/robots/status", bytes.NewBufferString(`{"ids": []}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestMoveRobot(t *testing.T) {
+	router, storage := setupTestRouter()
+
+	robot, _ := storage.GetRobot("robot1")
+	initialX := robot.Position.X
+	initialY := robot.Position.Y
+
+	moveBody := `{"direction": "up"}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/robot/robot1/move", bytes.NewBufferString(moveBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	assert.Contains(t, response["message"], "successfully")
+
+	robot, _ = storage.GetRobot("robot1")
+	assert.Equal(t, initialX, robot.Position.X)
+	assert.Equal(t, initialY+1, robot.Position.Y)
+}
+
+func TestPickupItem(t *testing.T) {
+	router, storage := setupTestRouter()
+
+	storage.AddItem("item1")
+
+	robot, _ := storage.GetRobot("robot1")
+	initialInventorySize := len(robot.Inventory)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/robot/robot1/pickup/item1", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	assert.Contains(t, response["message"], "successfully")
+
+	robot, _ = storage.GetRobot("robot1")
+	assert.Equal(t, initialInventorySize+1, len(robot.Inventory))
+	assert.Contains(t, robot.Inventory, "item1")
+
+	assert.False(t, storage.ItemExists("item1"))
+}
+
+func TestPutdownItem(t *testing.T) {
+	router, storage := setupTestRouter()
+
+	robot, _ := storage.GetRobot("robot1")
+	storage.RemoveItem("item2")
+	robot.Inventory = append(robot.Inventory, "item2")
+	storage.SaveRobot(robot)
+
+	initialInventorySize := len(robot.Inventory)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/robot/robot1/putdown/item2", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	assert.Contains(t, response["message"], "successfully")
+
+	robot, _ = storage.GetRobot("robot1")
+	assert.Equal(t, initialInventorySize-1, len(robot.Inventory))
+	assert.NotContains(t, robot.Inventory, "item2")
+
+	assert.True(t, storage.ItemExists("item2"))
+}
+
+func TestUpdateState(t *testing.T) {
+	router, storage := setupTestRouter()
+
+	updateBody := `{"energy": 75, "position": {"x": 5, "y": 8}}`
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PATCH", "/robot/robot1/state", bytes.NewBufferString(updateBody))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	assert.Contains(t, response["message"], "successfully")
+
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, 75, robot.Energy)
+	assert.Equal(t, 5, robot.Position.X)
+	assert.Equal(t, 8, robot.Position.Y)
+}
+
+func TestActionsHaveStructuredData(t *testing.T) {
+	router, storage := setupTestRouter()
+	storage.UpdateRobot("robot2", func(robot *Robot) error {
+		robot.Position = Position{X: 1, Y: 1}
+		return nil
+	})
+	require.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	require.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/attack/robot2", "").Code)
+
+	w := send(router, "GET", "/robot/robot1/actions?page=1&size=100", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Actions []struct {
+			Type    string                 `json:"type"`
+			Details string                 `json:"details"`
+			Data    map[string]interface{} `json:"data"`
+		} `json:"actions"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	actions := response.Actions
+	require.GreaterOrEqual(t, len(actions), 2)
+	move, attack := actions[len(actions)-2], actions[len(actions)-1]
+
+	assert.Equal(t, "Moved up", move.Details)
+	assert.Equal(t, map[string]interface{}{
+		"from":        map[string]interface{}{"x": 0.0, "y": 0.0},
+		"to":          map[string]interface{}{"x": 0.0, "y": 1.0},
+		"direction":   "up",
+		"energy_cost": 0.0,
+	}, move.Data)
+	assert.Equal(t, map[string]interface{}{"target_id": "robot2", "hit": true, "damage": 15.0, "energy_cost": 5.0}, attack.Data)
+
+	target, _ := storage.GetRobot("robot2")
+	damaged := target.Actions[len(target.Actions)-1]
+	assert.Equal(t, gin.H{"attacker_id": "robot1", "damage": 15}, damaged.Data)
+}
+
+func TestGetActions(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/robot/robot1/actions?size=2", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response PaginatedActions
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 1, response.Page.Number)
+	assert.Equal(t, 2, response.Page.Size)
+	assert.True(t, response.Page.HasNext)
+	assert.False(t, response.Page.HasPrevious)
+
+	assert.Len(t, response.Actions, 2)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/robot/robot1/actions?page=2&size=2", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	assert.Equal(t, 2, response.Page.Number)
+	assert.True(t, response.Page.HasNext)
+	assert.True(t, response.Page.HasPrevious)
+	assert.Len(t, response.Actions, 2)
+}
+
+func TestAttackRobot(t *testing.T) {
+	router, storage := setupTestRouter()
+
+	attacker, _ := storage.GetRobot("robot1")
+	target, _ := storage.GetRobot("robot2")
+	attackerEnergy := attacker.Energy
+	targetEnergy := target.Energy
+
+	// robot2 is far away
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/robot/robot1/attack/robot2", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "out of range")
+	attacker, _ = storage.GetRobot("robot1")
+	assert.Equal(t, attackerEnergy, attacker.Energy)
+
+	placeInRange(t, storage, "robot1", "robot2")
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/robot/robot1/attack/robot2", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+
+	assert.Contains(t, response["message"], "successful")
+	assert.Contains(t, response, "damage_dealt")
+
+	attacker, _ = storage.GetRobot("robot1")
+	target, _ = storage.GetRobot("robot2")
+
+	assert.Less(t, attacker.Energy, attackerEnergy)
+
+	assert.Less(t, target.Energy, targetEnergy)
+}
+
+func TestRobotNotFound(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/robot/nonexistent/status", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Contains(t, response["error"], "not found")
+}
@@ -0,0 +1,144 @@
+package robotapi
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Hazard types
+const (
+	HazardLava      = "lava"
+	HazardRadiation = "radiation"
+)
+
+// Hazard is a dangerous area on the map. Robots take damage when they enter
+// it and again every simulation tick they remain inside.
+type Hazard struct {
+	Type          string   `json:"type"`
+	Center        Position `json:"center"`
+	Radius        int      `json:"radius"` // 0 covers a single cell
+	DamagePerTick int      `json:"damage_per_tick"`
+}
+
+// Covers reports whether the hazard includes the given cell. Hazards never
+// spread to other floors.
+func (h Hazard) Covers(p Position) bool {
+	return h.coversOn(MapLayout{}, p)
+}
+
+// coversOn is Covers on a map that may wrap around its edges
+func (h Hazard) coversOn(layout MapLayout, p Position) bool {
+	return h.Center.Z == p.Z && layout.Distance(h.Center, p) <= h.Radius
+}
+
+// effect returns the status effect a robot receives inside the hazard
+func (h Hazard) effect() StatusEffect {
+	switch h.Type {
+	case HazardLava:
+		return Burning(2, h.DamagePerTick/2)
+	default:
+		return Slowed(2)
+	}
+}
+
+// GetHazards returns all hazards on the map
+func (s *RobotStorage) GetHazards() []Hazard {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	return append([]Hazard{}, s.hazards...)
+}
+
+// AddHazard places a hazard on the map
+func (s *RobotStorage) AddHazard(hazard Hazard) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	s.hazards = append(s.hazards, hazard)
+	s.hazardsChanged()
+}
+
+// HazardsNear returns all hazards that overlap the area around a position
+func (s *RobotStorage) HazardsNear(p Position, radius int) []Hazard {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	nearby := []Hazard{}
+	geometry := s.geometry()
+	for _, hazard := range s.hazards {
+		if geometry.Distance(hazard.Center, p) <= hazard.Radius+radius {
+			nearby = append(nearby, hazard)
+		}
+	}
+	return nearby
+}
+
+// HazardAt returns a copy of the hazard covering the cell, if any
+func (s *RobotStorage) HazardAt(p Position) *Hazard {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if hazard := s.hazardAt(p); hazard != nil {
+		found := *hazard
+		return &found
+	}
+	return nil
+}
+
+// EnterHazard applies the hazard at the robot's current position, if any.
+// It is called whenever a robot moves to a new cell.
+func (s *RobotStorage) EnterHazard(robotID string) *Hazard {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	robot, exists := s.robots[robotID]
+	if !exists {
+		return nil
+	}
+
+	hazard := s.hazardAt(robot.Position)
+	if hazard != nil {
+		applyHazard(robot, *hazard)
+		s.reindexRobot(robot)
+		s.robotChanged(robotID)
+	}
+	return hazard
+}
+
+// TickHazards damages every robot that is standing inside a hazard.
+// It is registered as a simulation system.
+func (s *RobotStorage) TickHazards(tick int64) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	for _, robot := range s.robots {
+		if hazard := s.hazardAt(robot.Position); hazard != nil {
+			before := robot.Energy
+			applyHazard(robot, *hazard)
+			s.bookEnergy(robot.ID, EnergyHazard, before, robot.Energy)
+			s.reindexRobot(robot)
+			s.robotChanged(robot.ID)
+		}
+	}
+}
+
+// hazardAt returns the first hazard covering the cell; callers must hold the lock
+func (s *RobotStorage) hazardAt(p Position) *Hazard {
+	geometry := s.geometry()
+	for i := range s.hazards {
+		if s.hazards[i].coversOn(geometry, p) {
+			return &s.hazards[i]
+		}
+	}
+	return nil
+}
+
+func applyHazard(robot *Robot, hazard Hazard) {
+	robot.Energy -= hazard.DamagePerTick
+	if robot.Energy < 0 {
+		robot.Energy, robot.EnergyFraction = 0, 0
+	}
+	appendAction(robot, "damaged", fmt.Sprintf("Damaged by %s", hazard.Type), gin.H{"hazard": hazard.Type, "damage": hazard.DamagePerTick})
+	applyEffect(robot, hazard.effect())
+}
@@ -0,0 +1,87 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMoveIntoHazard(t *testing.T) {
+	router, storage := setupTestRouter()
+	robot, _ := storage.GetRobot("robot1")
+	robot.Position = Position{X: 5, Y: 4}
+	storage.SaveRobot(robot)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/robot/robot1/move", bytes.NewBufferString(`{"direction": "up"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"hazard"`)
+	robot, _ = storage.GetRobot("robot1")
+	// Moving is free in sunny weather, lava deals 10 damage
+	assert.Equal(t, 90, robot.Energy)
+	assert.True(t, robot.HasEffect(EffectBurning))
+}
+
+func TestTickHazards(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	robot, _ := storage.GetRobot("robot2")
+	robot.Position = Position{X: -4, Y: 6}
+	storage.SaveRobot(robot)
+
+	storage.TickHazards(1)
+
+	robot, _ = storage.GetRobot("robot2")
+	assert.Equal(t, 97, robot.Energy)
+	assert.True(t, robot.HasEffect(EffectSlowed))
+}
+
+func TestScan(t *testing.T) {
+	router, storage := setupTestRouter()
+	robot, _ := storage.GetRobot("robot2")
+	robot.Position = Position{X: 2, Y: 2}
+	storage.SaveRobot(robot)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/robot/robot1/scan?radius=10", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var result ScanResult
+	err := json.Unmarshal(w.Body.Bytes(), &result)
+	assert.NoError(t, err)
+
+	assert.Len(t, result.Robots, 1)
+	assert.Equal(t, "robot2", result.Robots[0].ID)
+	assert.Equal(t, 4, result.Robots[0].Distance)
+	assert.Len(t, result.Hazards, 2)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/robot/robot1/scan?radius=-1", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestWorldMap(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/world/map", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var worldMap WorldMap
+	err := json.Unmarshal(w.Body.Bytes(), &worldMap)
+	assert.NoError(t, err)
+	assert.Len(t, worldMap.Robots, 2)
+	assert.Len(t, worldMap.Hazards, 2)
+}
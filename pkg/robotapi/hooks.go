@@ -0,0 +1,225 @@
+package robotapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Hooks let embedders add rules and side effects to robot actions without
+// changing the handlers. A hook implements any of the interfaces below.
+// Before hooks run after the handler's own checks, also for dry runs, and
+// can reject the action with an error; after hooks run once it succeeded.
+// Robots passed to hooks are copies.
+
+// BeforeMoveHook is called before a robot moves to the target cell
+type BeforeMoveHook interface {
+	BeforeMove(c *gin.Context, robot *Robot, target Position) error
+}
+
+// AfterMoveHook is called after a robot moved
+type AfterMoveHook interface {
+	AfterMove(c *gin.Context, robot *Robot)
+}
+
+// BeforePickupHook is called before a robot picks up an item
+type BeforePickupHook interface {
+	BeforePickup(c *gin.Context, robot *Robot, itemID string) error
+}
+
+// AfterPickupHook is called after a robot picked up an item
+type AfterPickupHook interface {
+	AfterPickup(c *gin.Context, robot *Robot, itemID string)
+}
+
+// BeforePutdownHook is called before a robot puts down an item
+type BeforePutdownHook interface {
+	BeforePutdown(c *gin.Context, robot *Robot, itemID string) error
+}
+
+// AfterPutdownHook is called after a robot put down an item
+type AfterPutdownHook interface {
+	AfterPutdown(c *gin.Context, robot *Robot, itemID string)
+}
+
+// BeforeAttackHook is called before a robot attacks another one
+type BeforeAttackHook interface {
+	BeforeAttack(c *gin.Context, attacker, target *Robot) error
+}
+
+// AfterAttackHook is called with the outcome of an attack
+type AfterAttackHook interface {
+	AfterAttack(c *gin.Context, attacker, target *Robot, result CombatResult)
+}
+
+// registeredHook is a hook with its name and position in the call order
+type registeredHook struct {
+	name     string
+	priority int
+	hook     interface{}
+}
+
+// HookRegistry keeps hooks in call order: lower priorities first, equal
+// priorities in the order they were registered
+type HookRegistry struct {
+	hooks []registeredHook
+	mutex sync.RWMutex
+}
+
+// NewHookRegistry creates an empty registry
+func NewHookRegistry() *HookRegistry {
+	return &HookRegistry{}
+}
+
+// Register adds a hook under a unique name
+func (r *HookRegistry) Register(name string, priority int, hook interface{}) error {
+	switch hook.(type) {
+	case BeforeMoveHook, AfterMoveHook, BeforePickupHook, AfterPickupHook,
+		BeforePutdownHook, AfterPutdownHook, BeforeAttackHook, AfterAttackHook:
+	default:
+		return fmt.Errorf("hook %s implements no hook interface", name)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, registered := range r.hooks {
+		if registered.name == name {
+			return fmt.Errorf("hook %s is already registered", name)
+		}
+	}
+	r.hooks = append(r.hooks, registeredHook{name: name, priority: priority, hook: hook})
+	sort.SliceStable(r.hooks, func(i, j int) bool {
+		return r.hooks[i].priority < r.hooks[j].priority
+	})
+	return nil
+}
+
+// Unregister removes a hook
+func (r *HookRegistry) Unregister(name string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i, registered := range r.hooks {
+		if registered.name == name {
+			r.hooks = append(r.hooks[:i], r.hooks[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Names returns the names of all hooks in call order
+func (r *HookRegistry) Names() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	names := make([]string, len(r.hooks))
+	for i, registered := range r.hooks {
+		names[i] = registered.name
+	}
+	return names
+}
+
+// each calls fn for every hook in order until one returns an error, and
+// returns the name of that hook with the error
+func (r *HookRegistry) each(fn func(hook interface{}) error) (string, error) {
+	r.mutex.RLock()
+	hooks := append([]registeredHook(nil), r.hooks...)
+	r.mutex.RUnlock()
+
+	for _, registered := range hooks {
+		if err := fn(registered.hook); err != nil {
+			return registered.name, err
+		}
+	}
+	return "", nil
+}
+
+// before runs before hooks and answers with 403 if one rejects the action
+func (h *RobotHandler) before(c *gin.Context, fn func(hook interface{}) error) bool {
+	name, err := h.hooks.each(fn)
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("Rejected by %s: %v", name, err)})
+		return false
+	}
+	return true
+}
+
+// after runs after hooks
+func (h *RobotHandler) after(fn func(hook interface{})) {
+	h.hooks.each(func(hook interface{}) error {
+		fn(hook)
+		return nil
+	})
+}
+
+func (h *RobotHandler) beforeMove(c *gin.Context, robot *Robot, target Position) bool {
+	return h.before(c, func(hook interface{}) error {
+		if before, ok := hook.(BeforeMoveHook); ok {
+			return before.BeforeMove(c, robot.clone(), target)
+		}
+		return nil
+	})
+}
+
+func (h *RobotHandler) afterMove(c *gin.Context, robot *Robot) {
+	h.after(func(hook interface{}) {
+		if after, ok := hook.(AfterMoveHook); ok {
+			after.AfterMove(c, robot.clone())
+		}
+	})
+}
+
+func (h *RobotHandler) beforePickup(c *gin.Context, robot *Robot, itemID string) bool {
+	return h.before(c, func(hook interface{}) error {
+		if before, ok := hook.(BeforePickupHook); ok {
+			return before.BeforePickup(c, robot.clone(), itemID)
+		}
+		return nil
+	})
+}
+
+func (h *RobotHandler) afterPickup(c *gin.Context, robot *Robot, itemID string) {
+	h.after(func(hook interface{}) {
+		if after, ok := hook.(AfterPickupHook); ok {
+			after.AfterPickup(c, robot.clone(), itemID)
+		}
+	})
+}
+
+func (h *RobotHandler) beforePutdown(c *gin.Context, robot *Robot, itemID string) bool {
+	return h.before(c, func(hook interface{}) error {
+		if before, ok := hook.(BeforePutdownHook); ok {
+			return before.BeforePutdown(c, robot.clone(), itemID)
+		}
+		return nil
+	})
+}
+
+func (h *RobotHandler) afterPutdown(c *gin.Context, robot *Robot, itemID string) {
+	h.after(func(hook interface{}) {
+		if after, ok := hook.(AfterPutdownHook); ok {
+			after.AfterPutdown(c, robot.clone(), itemID)
+		}
+	})
+}
+
+func (h *RobotHandler) beforeAttack(c *gin.Context, attacker, target *Robot) bool {
+	return h.before(c, func(hook interface{}) error {
+		if before, ok := hook.(BeforeAttackHook); ok {
+			return before.BeforeAttack(c, attacker.clone(), target.clone())
+		}
+		return nil
+	})
+}
+
+func (h *RobotHandler) afterAttack(c *gin.Context, attacker, target *Robot, result CombatResult) {
+	h.after(func(hook interface{}) {
+		if after, ok := hook.(AfterAttackHook); ok {
+			after.AfterAttack(c, attacker.clone(), target.clone(), result)
+		}
+	})
+}
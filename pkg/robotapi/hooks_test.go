@@ -0,0 +1,80 @@
+package robotapi
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// tracingHook records every call into a shared log
+type tracingHook struct {
+	name string
+	log  *[]string
+}
+
+func (h tracingHook) BeforeMove(c *gin.Context, robot *Robot, target Position) error {
+	*h.log = append(*h.log, h.name+" before move")
+	return nil
+}
+
+func (h tracingHook) AfterMove(c *gin.Context, robot *Robot) {
+	*h.log = append(*h.log, h.name+" after move")
+}
+
+func (h tracingHook) AfterAttack(c *gin.Context, attacker, target *Robot, result CombatResult) {
+	*h.log = append(*h.log, h.name+" after attack on "+target.ID)
+}
+
+// teleportGuard is an anti-cheat rule rejecting moves off the board's center
+type teleportGuard struct{}
+
+func (teleportGuard) BeforeMove(c *gin.Context, robot *Robot, target Position) error {
+	if target.Y > 1 {
+		return errors.New("too far north")
+	}
+	return nil
+}
+
+func TestHookOrdering(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	hooks := NewHookRegistry()
+	handler := NewRobotHandler(storage)
+	handler.SetHooks(hooks)
+	router.POST("/hooked/robot/:id/move", handler.MoveRobot)
+	router.POST("/hooked/robot/:id/attack/:targetId", handler.AttackRobot)
+
+	var calls []string
+	assert.NoError(t, hooks.Register("audit", 10, tracingHook{name: "audit", log: &calls}))
+	assert.NoError(t, hooks.Register("metrics", 10, tracingHook{name: "metrics", log: &calls}))
+	assert.NoError(t, hooks.Register("first", -5, tracingHook{name: "first", log: &calls}))
+	assert.NoError(t, hooks.Register("guard", 0, teleportGuard{}))
+	assert.Error(t, hooks.Register("audit", 0, tracingHook{}), "names are unique")
+	assert.Error(t, hooks.Register("nothing", 0, struct{}{}))
+	assert.Equal(t, []string{"first", "guard", "audit", "metrics"}, hooks.Names())
+
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/hooked/robot/robot1/move", `{"direction": "up"}`).Code)
+	assert.Equal(t, []string{
+		"first before move", "audit before move", "metrics before move",
+		"first after move", "audit after move", "metrics after move",
+	}, calls)
+
+	// A rejecting hook stops the hooks after it and the move itself
+	calls = nil
+	before, _ := storage.GetRobot("robot1")
+	w := send(router, "POST", "/hooked/robot/robot1/move", `{"direction": "up"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Rejected by guard: too far north")
+	assert.Equal(t, []string{"first before move"}, calls)
+	after, _ := storage.GetRobot("robot1")
+	assert.Equal(t, before.Position, after.Position)
+
+	calls = nil
+	assert.True(t, hooks.Unregister("first"))
+	assert.False(t, hooks.Unregister("first"))
+	placeInRange(t, storage, "robot1", "robot2")
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/hooked/robot/robot1/attack/robot2", "").Code)
+	assert.Equal(t, []string{"audit after attack on robot2", "metrics after attack on robot2"}, calls)
+}
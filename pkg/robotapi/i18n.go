@@ -0,0 +1,259 @@
+package robotapi
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed locales/*.json
+var localeFiles embed.FS
+
+// SourceLanguage is the language the messages are written in
+const SourceLanguage = "en"
+
+// Human-readable fields of JSON responses; codes, field names and the
+// problem type stay language-neutral
+var (
+	localizedFields        = []string{"error", "message"}
+	localizedProblemFields = []string{"title", "detail"}
+)
+
+// Catalog translates the human-readable messages of responses. Its entries
+// map English messages to their translation; %s stands for a variable part,
+// like an ID, which is translated too if it is a message itself.
+type Catalog struct {
+	languages map[string]*translations
+}
+
+// translations are the entries of one language
+type translations struct {
+	exact    map[string]string
+	patterns []messagePattern
+}
+
+// messagePattern is an entry with variable parts
+type messagePattern struct {
+	match       *regexp.Regexp
+	translation string
+}
+
+// LoadCatalog reads the embedded catalogs, one locales/<language>.json per
+// language besides English
+func LoadCatalog() (*Catalog, error) {
+	entries, err := localeFiles.ReadDir("locales")
+	if err != nil {
+		return nil, err
+	}
+	catalog := &Catalog{languages: make(map[string]*translations)}
+	for _, entry := range entries {
+		data, err := localeFiles.ReadFile(path.Join("locales", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var messages map[string]string
+		if err := json.Unmarshal(data, &messages); err != nil {
+			return nil, fmt.Errorf("catalog %s: %w", entry.Name(), err)
+		}
+		catalog.Add(strings.TrimSuffix(entry.Name(), ".json"), messages)
+	}
+	return catalog, nil
+}
+
+// Add adds translations of English messages to a language
+func (c *Catalog) Add(language string, messages map[string]string) {
+	t, exists := c.languages[language]
+	if !exists {
+		t = &translations{exact: make(map[string]string)}
+		c.languages[language] = t
+	}
+	for message, translation := range messages {
+		if !strings.Contains(message, "%s") {
+			t.exact[message] = translation
+			continue
+		}
+		parts := strings.Split(message, "%s")
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		t.patterns = append(t.patterns, messagePattern{
+			match:       regexp.MustCompile("^" + strings.Join(parts, "(.+?)") + "$"),
+			translation: translation,
+		})
+	}
+	// Longer patterns are more specific
+	sort.Slice(t.patterns, func(i, j int) bool {
+		return len(t.patterns[i].match.String()) > len(t.patterns[j].match.String())
+	})
+}
+
+// Languages returns the supported languages, English first
+func (c *Catalog) Languages() []string {
+	languages := []string{}
+	for language := range c.languages {
+		if language != SourceLanguage {
+			languages = append(languages, language)
+		}
+	}
+	sort.Strings(languages)
+	return append([]string{SourceLanguage}, languages...)
+}
+
+// Supports reports whether the catalog has the language
+func (c *Catalog) Supports(language string) bool {
+	_, exists := c.languages[language]
+	return language == SourceLanguage || exists
+}
+
+// Translate returns the message in the language, or unchanged if the
+// catalog has no translation
+func (c *Catalog) Translate(language, message string) string {
+	t, exists := c.languages[language]
+	if !exists {
+		return message
+	}
+	if translation, exists := t.exact[message]; exists {
+		return translation
+	}
+	for _, pattern := range t.patterns {
+		if parts := pattern.match.FindStringSubmatch(message); parts != nil {
+			args := make([]any, len(parts)-1)
+			for i, part := range parts[1:] {
+				if part != message {
+					args[i] = c.Translate(language, part)
+				} else {
+					args[i] = part
+				}
+			}
+			return fmt.Sprintf(pattern.translation, args...)
+		}
+	}
+	return message
+}
+
+// Negotiate picks the language for an Accept-Language header: the supported
+// language with the highest weight, or fallback if there is none
+func (c *Catalog) Negotiate(acceptLanguage, fallback string) string {
+	best, bestWeight := fallback, 0.0
+	for _, entry := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		weight := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			weight = parsed
+		}
+		language, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if weight > bestWeight && c.Supports(language) {
+			best, bestWeight = language, weight
+		}
+	}
+	return best
+}
+
+// localize translates the human-readable fields of a JSON object body. Other
+// bodies, and bodies without anything to translate, are returned unchanged.
+func (c *Catalog) localize(language string, body []byte, problem bool) []byte {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err != nil {
+		return body
+	}
+	fields := localizedFields
+	if problem {
+		fields = localizedProblemFields
+	}
+	changed := false
+	for _, field := range fields {
+		var message string
+		if raw, exists := object[field]; !exists || json.Unmarshal(raw, &message) != nil {
+			continue
+		}
+		if translation := c.Translate(language, message); translation != message {
+			object[field], _ = json.Marshal(translation)
+			changed = true
+		}
+	}
+	if !changed {
+		return body
+	}
+	localized, err := json.Marshal(object)
+	if err != nil {
+		return body
+	}
+	return localized
+}
+
+// localizingWriter holds back JSON bodies until the handler is done, so they
+// can be translated. Bodies of other types, and JSON that is streamed with
+// Flush, pass through as they are.
+type localizingWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	decided   bool
+	buffering bool
+}
+
+func (w *localizingWriter) Write(data []byte) (int, error) {
+	if !w.decided {
+		w.decided = true
+		contentType := w.Header().Get("Content-Type")
+		w.buffering = strings.HasPrefix(contentType, "application/json") || strings.HasPrefix(contentType, "application/problem+json")
+	}
+	if w.buffering {
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *localizingWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *localizingWriter) Written() bool {
+	return w.body.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// Flush gives up on translating a streamed body
+func (w *localizingWriter) Flush() {
+	if w.buffering {
+		w.buffering = false
+		w.ResponseWriter.Write(w.body.Bytes())
+		w.body.Reset()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Localize translates the messages of JSON responses into the language the
+// client prefers in Accept-Language, or fallback. The language is announced
+// in Content-Language; English responses are left as they are.
+func Localize(catalog *Catalog, fallback string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		language := catalog.Negotiate(c.GetHeader("Accept-Language"), fallback)
+		c.Writer.Header().Add("Vary", "Accept-Language")
+		c.Header("Content-Language", language)
+		if language == SourceLanguage {
+			c.Next()
+			return
+		}
+
+		writer := &localizingWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+		if writer.buffering {
+			problem := strings.HasPrefix(writer.Header().Get("Content-Type"), "application/problem+json")
+			writer.Header().Del("Content-Length")
+			writer.ResponseWriter.Write(catalog.localize(language, writer.body.Bytes(), problem))
+		}
+	}
+}
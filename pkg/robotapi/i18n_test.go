@@ -0,0 +1,112 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCatalogNegotiate(t *testing.T) {
+	catalog, err := LoadCatalog()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"en", "de"}, catalog.Languages())
+
+	for header, expected := range map[string]string{
+		"":                        "en",
+		"de":                      "de",
+		"de-DE,en;q=0.8":          "de",
+		"fr, de-AT;q=0.9, en;q=1": "en",
+		"fr, de-CH;q=0.5":         "de",
+		"fr, *;q=0.5":             "en",
+		"de;q=0":                  "en",
+	} {
+		assert.Equal(t, expected, catalog.Negotiate(header, "en"), header)
+	}
+	assert.Equal(t, "de", catalog.Negotiate("fr", "de"))
+}
+
+func TestCatalogTranslate(t *testing.T) {
+	catalog, err := LoadCatalog()
+	require.NoError(t, err)
+
+	assert.Equal(t, "Roboter nicht gefunden", catalog.Translate("de", "Robot not found"))
+	// Variable parts are translated when they are messages themselves
+	assert.Equal(t, "Aktion nicht erlaubt: der Roboter ist durch einen EMP betäubt",
+		catalog.Translate("de", "Action not allowed: robot is stunned by an EMP"))
+	assert.Equal(t, "Aktion nicht erlaubt: robot7",
+		catalog.Translate("de", "Action not allowed: robot7"))
+	// Unknown messages and languages stay English
+	assert.Equal(t, "Something new", catalog.Translate("de", "Something new"))
+	assert.Equal(t, "Robot not found", catalog.Translate("fr", "Robot not found"))
+}
+
+func TestLocalizeResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+
+	get := func(language string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/robot/missing/status", nil)
+		if language != "" {
+			req.Header.Set("Accept-Language", language)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("de-DE,de;q=0.9,en;q=0.8")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "de", w.Header().Get("Content-Language"))
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Language")
+	assert.JSONEq(t, `{"error":"Roboter nicht gefunden"}`, w.Body.String())
+
+	w = get("")
+	assert.Equal(t, "en", w.Header().Get("Content-Language"))
+	assert.JSONEq(t, `{"error":"Robot not found"}`, w.Body.String())
+
+	// The default language applies to clients without a preference
+	config.Language = "de"
+	server, err = New(config)
+	require.NoError(t, err)
+	router = server.Router()
+	w = get("")
+	assert.JSONEq(t, `{"error":"Roboter nicht gefunden"}`, w.Body.String())
+
+	config.Language = "fr"
+	_, err = New(config)
+	assert.ErrorContains(t, err, "invalid language configuration")
+}
+
+func TestLocalizeProblems(t *testing.T) {
+	catalog, err := LoadCatalog()
+	require.NoError(t, err)
+	router := gin.New()
+	router.Use(Localize(catalog, SourceLanguage))
+	router.GET("/down", func(c *gin.Context) {
+		abortWithProblem(c, http.StatusServiceUnavailable, "Storage backend is unavailable, try again later")
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/down", nil)
+	req.Header.Set("Accept-Language", "de")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	var problem map[string]any
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, map[string]any{
+		"type":   "about:blank",
+		"title":  "Dienst nicht verfügbar",
+		"status": float64(http.StatusServiceUnavailable),
+		"detail": "Das Speicher-Backend ist nicht verfügbar, später erneut versuchen",
+	}, problem)
+}
@@ -0,0 +1,113 @@
+package robotapi
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// robotIDPattern restricts client-supplied robot IDs
+var robotIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// ValidRobotID checks a client-supplied robot ID against the allowed format
+func ValidRobotID(id string) bool {
+	return CheckID(RobotIDs, id) == nil
+}
+
+// Kinds of IDs clients supply
+const (
+	RobotIDs IDKind = "robot"
+	ItemIDs  IDKind = "item"
+)
+
+// IDKind is what an ID names
+type IDKind string
+
+// IDCheck is a stricter rule for IDs on top of the format every ID has, e.g.
+// a prefix some tenants require. tenant is empty for the default tenant.
+type IDCheck func(tenant string, kind IDKind, id string) error
+
+// CheckID checks an ID against the format of all robot and item IDs: 1-64
+// letters, digits, '-' or '_'. That rules out anything that reads like a
+// path, such as "../robot1", and control characters.
+func CheckID(kind IDKind, id string) error {
+	if !robotIDPattern.MatchString(id) {
+		return fmt.Errorf("Invalid %s ID: use 1-64 letters, digits, '-' or '_'", kind)
+	}
+	return nil
+}
+
+// idParams are the path parameters holding robot and item IDs
+var idParams = map[string]IDKind{"id": RobotIDs, "targetId": RobotIDs, "itemId": ItemIDs}
+
+// IDGenerator creates IDs for new robots
+type IDGenerator interface {
+	NewID() string
+}
+
+// NewIDGenerator returns the generator registered under the given name
+func NewIDGenerator(name string) (IDGenerator, error) {
+	switch name {
+	case "", "uuid":
+		return UUIDGenerator{}, nil
+	case "snowflake":
+		return NewSnowflakeGenerator(1), nil
+	default:
+		return nil, fmt.Errorf("unknown ID strategy %q", name)
+	}
+}
+
+// UUIDGenerator creates random version 4 UUIDs
+type UUIDGenerator struct{}
+
+// NewID returns a new random UUID
+func (UUIDGenerator) NewID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// snowflakeEpoch is the custom epoch of snowflake IDs (2024-01-01 UTC)
+var snowflakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// SnowflakeGenerator creates time-ordered 63 bit IDs made of a millisecond
+// timestamp, a 10 bit node ID and a 12 bit sequence number
+type SnowflakeGenerator struct {
+	node     int64
+	lastMs   int64
+	sequence int64
+	mutex    sync.Mutex
+}
+
+// NewSnowflakeGenerator creates a generator for the given node (0-1023)
+func NewSnowflakeGenerator(node int64) *SnowflakeGenerator {
+	return &SnowflakeGenerator{node: node & 0x3ff}
+}
+
+// NewID returns the next snowflake ID as a decimal string
+func (g *SnowflakeGenerator) NewID() string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	now := time.Since(snowflakeEpoch).Milliseconds()
+	if now <= g.lastMs {
+		// Same millisecond (or clock went backwards): count up the sequence
+		now = g.lastMs
+		g.sequence = (g.sequence + 1) & 0xfff
+		if g.sequence == 0 {
+			now++
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastMs = now
+
+	return strconv.FormatInt(now<<22|g.node<<12|g.sequence, 10)
+}
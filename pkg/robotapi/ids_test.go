@@ -0,0 +1,132 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUUIDGenerator(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	id := UUIDGenerator{}.NewID()
+	assert.Regexp(t, uuidPattern, id)
+	assert.NotEqual(t, id, UUIDGenerator{}.NewID())
+}
+
+func TestSnowflakeGeneratorIsUnique(t *testing.T) {
+	generator := NewSnowflakeGenerator(7)
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		id := generator.NewID()
+		assert.False(t, seen[id], "duplicate ID %s", id)
+		seen[id] = true
+	}
+}
+
+func TestCreateRobotGeneratesID(t *testing.T) {
+	router, storage := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/robots", bytes.NewBufferString(`{"class": "solar", "position": {"x": 3, "y": 4}}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response struct {
+		Robot Robot  `json:"robot"`
+		Links []Link `json:"links"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, response.Robot.ID)
+	assert.Equal(t, ClassSolar, response.Robot.Class)
+	assert.NotEmpty(t, response.Links)
+
+	robot, err := storage.GetRobot(response.Robot.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, Position{X: 3, Y: 4}, robot.Position)
+}
+
+func TestCreateRobotWithClientID(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	create := func(body string) int {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/robots", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusCreated, create(`{"id": "robot3"}`))
+	assert.Equal(t, http.StatusConflict, create(`{"id": "robot3"}`))
+	assert.Equal(t, http.StatusConflict, create(`{"id": "robot1"}`))
+	assert.Equal(t, http.StatusBadRequest, create(`{"id": "../etc/passwd"}`))
+	assert.Equal(t, http.StatusBadRequest, create(`{"class": "nuclear"}`))
+}
+
+func TestCheckID(t *testing.T) {
+	for _, id := range []string{"robot1", "lab-7", "A_b-C", strings.Repeat("x", 64)} {
+		assert.NoError(t, CheckID(RobotIDs, id), id)
+	}
+	for _, id := range []string{"", "../robot1", "robot/1", `robot\1`, "robot.1", "robot\x00", "robot\n", "robö", strings.Repeat("x", 65)} {
+		assert.Error(t, CheckID(RobotIDs, id), id)
+	}
+	assert.EqualError(t, CheckID(ItemIDs, ".."), "Invalid item ID: use 1-64 letters, digits, '-' or '_'")
+}
+
+func TestIDsAreCheckedAtAllEntryPoints(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.IDCheck = func(tenant string, kind IDKind, id string) error {
+		if kind == RobotIDs && tenant == "" && !strings.HasPrefix(id, "robot") && !strings.HasPrefix(id, "lab-") {
+			return errors.New("must start with lab-")
+		}
+		return nil
+	}
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+
+	assert.Equal(t, http.StatusOK, send(router, "GET", "/robot/robot1/status", "").Code)
+	w := send(router, "GET", "/robot/robot.1/status", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"Invalid robot ID: use 1-64 letters, digits, '-' or '_'"}`, w.Body.String())
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/robot/robot%00/status", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/analytics/robots/robot%0A", "").Code)
+
+	w = send(router, "POST", "/robot/robot1/pickup/item%2E%2E", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid item ID")
+
+	w = send(router, "POST", "/robots/status", `{"ids": ["robot1", "robot 2"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"robot 2"`)
+
+	// The configured check comes on top
+	w = send(router, "POST", "/robots", `{"id": "rover"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":"Invalid robot ID: must start with lab-"}`, w.Body.String())
+	assert.Equal(t, http.StatusCreated, send(router, "POST", "/robots", `{"id": "lab-rover"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/robot/rover/status", "").Code)
+}
+
+func TestSnapshotsWithInvalidIDsAreRejected(t *testing.T) {
+	_, err := ReadSnapshot(strings.NewReader(`{"robots": [{"id": "robot1"}], "items": [{"id": "../item1"}]}`))
+	var invalid *invalidSnapshotError
+	assert.ErrorAs(t, err, &invalid)
+	assert.ErrorContains(t, err, `item "../item1"`)
+
+	_, err = ReadSnapshot(strings.NewReader(`{"robots": [{"id": "robot1"}], "items": [{"id": "item1"}]}`))
+	assert.NoError(t, err)
+}
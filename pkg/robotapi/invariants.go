@@ -0,0 +1,255 @@
+package robotapi
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Invariants of a world
+const (
+	InvariantItems    = "items"    // every item is either in one inventory or in the world
+	InvariantEnergy   = "energy"   // energy is between 0 and the battery's capacity
+	InvariantPosition = "position" // robots and items lie within the bounds, robots off obstacles
+)
+
+// maxInvariantViolations is the number of newest violations kept
+const maxInvariantViolations = 1000
+
+// InvariantViolation is a broken invariant of a world
+type InvariantViolation struct {
+	Tenant     string    `json:"tenant,omitempty"`
+	Invariant  string    `json:"invariant"`
+	Subject    string    `json:"subject"` // robot or item ID
+	Details    string    `json:"details"`
+	Version    uint64    `json:"version"` // world version the violation was found at
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+// invariantReport receives the violations found after a change of a world,
+// none if the world is consistent
+type invariantReport func(version uint64, violations []InvariantViolation)
+
+// setInvariantReport makes the storage check its invariants after every
+// change and report the result; nil stops the checks
+func (s *RobotStorage) setInvariantReport(report invariantReport) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.invariantReport = report
+	s.checkedVersion = 0
+}
+
+// unlock releases the write lock. If invariant checks are on and the world
+// changed while the lock was held, the invariants are checked first and
+// reported once the lock is released.
+func (s *RobotStorage) unlock() {
+	report := s.invariantReport
+	if report == nil || s.version == s.checkedVersion {
+		s.mutex.Unlock()
+		return
+	}
+	version := s.version
+	s.checkedVersion = version
+	violations := s.invariantViolations()
+	s.mutex.Unlock()
+	report(version, violations)
+}
+
+// CheckInvariants returns the violated invariants of the world, sorted by
+// invariant and subject
+func (s *RobotStorage) CheckInvariants() []InvariantViolation {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.invariantViolations()
+}
+
+// invariantViolations checks the invariants; callers must hold the lock
+func (s *RobotStorage) invariantViolations() []InvariantViolation {
+	violations := []InvariantViolation{}
+	violate := func(invariant, subject, format string, args ...interface{}) {
+		violations = append(violations, InvariantViolation{
+			Invariant: invariant,
+			Subject:   subject,
+			Details:   fmt.Sprintf(format, args...),
+			Version:   s.version,
+		})
+	}
+
+	carriers := make(map[string]string) // item ID -> robot carrying it
+	for _, id := range s.sortedRobotIDs() {
+		robot := s.robots[id]
+		for _, itemID := range robot.Inventory {
+			item, exists := s.items[itemID]
+			switch {
+			case carriers[itemID] != "":
+				violate(InvariantItems, itemID, "carried by %s and %s", carriers[itemID], id)
+			case !exists:
+				violate(InvariantItems, itemID, "carried by %s but unknown", id)
+			case !item.carried:
+				violate(InvariantItems, itemID, "carried by %s and lying in the world at (%d,%d)", id, item.Position.X, item.Position.Y)
+			}
+			carriers[itemID] = id
+		}
+
+		if robot.Energy < 0 || robot.Energy > robot.maxEnergy() {
+			violate(InvariantEnergy, id, "energy %d outside 0..%d", robot.Energy, robot.maxEnergy())
+		}
+		if s.bounds != nil && !s.bounds.Contains(robot.Position) {
+			violate(InvariantPosition, id, "robot at (%d,%d) outside the bounds", robot.Position.X, robot.Position.Y)
+		}
+		if s.obstacles[robot.Position] {
+			violate(InvariantPosition, id, "robot at (%d,%d) on an obstacle", robot.Position.X, robot.Position.Y)
+		}
+	}
+
+	for itemID, item := range s.items {
+		if item.carried && carriers[itemID] == "" {
+			violate(InvariantItems, itemID, "carried but in no inventory")
+		}
+		if !item.carried && s.bounds != nil && !s.bounds.Contains(item.Position) {
+			violate(InvariantPosition, itemID, "item at (%d,%d) outside the bounds", item.Position.X, item.Position.Y)
+		}
+	}
+
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].Invariant != violations[j].Invariant {
+			return violations[i].Invariant < violations[j].Invariant
+		}
+		return violations[i].Subject < violations[j].Subject
+	})
+	return violations
+}
+
+// invariantWatch follows the invariants of one world
+type invariantWatch struct {
+	tenant  string
+	storage *RobotStorage
+	events  *EventBus
+	version uint64          // newest version reported
+	active  map[string]bool // violations found at the last check
+}
+
+// InvariantChecker checks the invariants of worlds after every change:
+// no item is duplicated between inventories and the world, energy stays
+// within the battery's capacity and positions stay on the map. New
+// violations are logged and published as invariant_violated events. The
+// checks cost a pass over the world per change, so they are meant for tests
+// and staging.
+type InvariantChecker struct {
+	watches    []*invariantWatch
+	violations []InvariantViolation // oldest first
+	clock      Clock
+	mutex      sync.Mutex
+}
+
+// NewInvariantChecker creates a checker watching no worlds yet
+func NewInvariantChecker() *InvariantChecker {
+	return &InvariantChecker{violations: []InvariantViolation{}, clock: SystemClock{}}
+}
+
+// SetClock replaces the clock dating the violations
+func (c *InvariantChecker) SetClock(clock Clock) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.clock = clock
+}
+
+// Watch checks the world of a tenant after every change from now on. The
+// world is checked right away, so violations it already has are reported,
+// too.
+func (c *InvariantChecker) Watch(tenant string, storage *RobotStorage, events *EventBus) {
+	c.mutex.Lock()
+	for _, watch := range c.watches {
+		if watch.storage == storage {
+			c.mutex.Unlock()
+			return
+		}
+	}
+	watch := &invariantWatch{tenant: tenant, storage: storage, events: events, active: make(map[string]bool)}
+	c.watches = append(c.watches, watch)
+	c.mutex.Unlock()
+
+	storage.setInvariantReport(func(version uint64, violations []InvariantViolation) {
+		c.report(watch, version, violations)
+	})
+	c.report(watch, storage.Version(), storage.CheckInvariants())
+}
+
+// report records the violations of a check that were not there at the
+// previous one; a robot or item breaking an invariant is reported once until
+// it is fixed. Reports of older versions than the last one arrive late and
+// are dropped.
+func (c *InvariantChecker) report(watch *invariantWatch, version uint64, violations []InvariantViolation) {
+	c.mutex.Lock()
+	if version < watch.version {
+		c.mutex.Unlock()
+		return
+	}
+	watch.version = version
+
+	found := []InvariantViolation{}
+	active := make(map[string]bool, len(violations))
+	for _, violation := range violations {
+		key := violation.Invariant + "\x00" + violation.Subject
+		active[key] = true
+		if watch.active[key] {
+			continue
+		}
+		violation.Tenant = watch.tenant
+		violation.DetectedAt = c.clock.Now()
+		found = append(found, violation)
+	}
+	watch.active = active
+	c.violations = append(c.violations, found...)
+	if len(c.violations) > maxInvariantViolations {
+		c.violations = c.violations[len(c.violations)-maxInvariantViolations:]
+	}
+	c.mutex.Unlock()
+
+	for _, violation := range found {
+		log.Printf("Invariant %s violated by %s at world version %d: %s", violation.Invariant, violation.Subject, violation.Version, violation.Details)
+		watch.events.Publish("invariant_violated", "", gin.H{
+			"invariant": violation.Invariant,
+			"subject":   violation.Subject,
+			"details":   violation.Details,
+			"version":   violation.Version,
+		})
+	}
+}
+
+// Violations returns the violations found so far, newest first
+func (c *InvariantChecker) Violations() []InvariantViolation {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	violations := make([]InvariantViolation, 0, len(c.violations))
+	for i := len(c.violations) - 1; i >= 0; i-- {
+		violations = append(violations, c.violations[i])
+	}
+	return violations
+}
+
+// ListInvariantViolations returns the violations found by the invariant
+// checker, filtered by ?tenant= and ?invariant=
+func (h *RobotHandler) ListInvariantViolations(c *gin.Context) {
+	if h.invariants == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Invariant checks are disabled"})
+		return
+	}
+	violations := []InvariantViolation{}
+	for _, violation := range h.invariants.Violations() {
+		if tenant, set := c.GetQuery("tenant"); set && violation.Tenant != tenant {
+			continue
+		}
+		if invariant := c.Query("invariant"); invariant != "" && violation.Invariant != invariant {
+			continue
+		}
+		violations = append(violations, violation)
+	}
+	c.JSON(http.StatusOK, gin.H{"violations": violations})
+}
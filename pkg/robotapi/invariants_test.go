@@ -0,0 +1,151 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckInvariants(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	assert.Empty(t, storage.CheckInvariants())
+
+	require.NoError(t, storage.SetBounds(&Bounds{MinX: -20, MinY: -20, MaxX: 20, MaxY: 20}))
+	require.NoError(t, storage.PlaceObstacle(Position{X: 5, Y: 5}))
+
+	// Bypass the handlers to break every invariant
+	robot, _ := storage.GetRobot("robot1")
+	robot.Inventory = []string{"item1", "ghost"}
+	robot.Energy = MaxEnergy + 1
+	robot.Position = Position{X: 30, Y: 0}
+	storage.SaveRobot(robot)
+	robot, _ = storage.GetRobot("robot2")
+	robot.Inventory = []string{"item1"}
+	robot.Energy = -1
+	robot.Position = Position{X: 5, Y: 5}
+	storage.SaveRobot(robot)
+	storage.RemoveItem("item2")
+	storage.PlaceItem("item3", Position{X: -21, Y: 0})
+
+	details := []string{}
+	for _, violation := range storage.CheckInvariants() {
+		details = append(details, violation.Invariant+" "+violation.Subject+": "+violation.Details)
+	}
+	assert.Equal(t, []string{
+		"energy robot1: energy 101 outside 0..100",
+		"energy robot2: energy -1 outside 0..100",
+		"items ghost: carried by robot1 but unknown",
+		"items item1: carried by robot1 and lying in the world at (1,1)",
+		"items item1: carried by robot1 and robot2",
+		"items item2: carried but in no inventory",
+		"position item3: item at (-21,0) outside the bounds",
+		"position robot1: robot at (30,0) outside the bounds",
+		"position robot2: robot at (5,5) on an obstacle",
+	}, details)
+}
+
+func TestInvariantChecker(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	events := NewEventBus()
+	clock := NewFakeClock(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	checker := NewInvariantChecker()
+	checker.SetClock(clock)
+	checker.Watch("", storage, events)
+	assert.Empty(t, checker.Violations())
+
+	// Each change is checked, a violation is reported once while it lasts
+	_, err := storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Energy = -5
+		return nil
+	})
+	require.NoError(t, err)
+	require.NoError(t, storage.AddAction("robot1", "test", "still broken"))
+	violations := checker.Violations()
+	require.Len(t, violations, 1)
+	assert.Equal(t, InvariantEnergy, violations[0].Invariant)
+	assert.Equal(t, "robot1", violations[0].Subject)
+	assert.Equal(t, clock.Now(), violations[0].DetectedAt)
+
+	published := events.Since(0)
+	require.Len(t, published, 1)
+	assert.Equal(t, "invariant_violated", published[0].Type)
+
+	// Once fixed, the same violation is reported again
+	for _, energy := range []int{50, -5} {
+		energy := energy
+		_, err = storage.UpdateRobot("robot1", func(robot *Robot) error {
+			robot.Energy = energy
+			return nil
+		})
+		require.NoError(t, err)
+	}
+	assert.Len(t, checker.Violations(), 2)
+}
+
+func TestConcurrentPickupsTakeAnItemOnce(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	robot, _ := storage.GetRobot("robot2")
+	robot.Position = Position{X: 2, Y: 0} // next to robot1 at item2
+	storage.SaveRobot(robot)
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	succeeded := 0
+	for _, id := range []string{"robot1", "robot2", "robot1", "robot2"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			_, err := storage.PickupItem(id, "item2", func(robot *Robot) error {
+				robot.Inventory = append(robot.Inventory, "item2")
+				return nil
+			})
+			if err == nil {
+				mutex.Lock()
+				succeeded++
+				mutex.Unlock()
+			} else {
+				assert.ErrorIs(t, err, ErrItemNotFound)
+			}
+		}(id)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, succeeded)
+	assert.Empty(t, storage.CheckInvariants())
+}
+
+func TestInvariantsEndpoint(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	server, err := New(config)
+	require.NoError(t, err)
+	assert.Nil(t, server.Invariants())
+	assert.Equal(t, http.StatusNotFound, adminRequest(server.Router(), "GET", "/admin/invariants", "").Code)
+
+	config.CheckInvariants = true
+	server, err = New(config)
+	require.NoError(t, err)
+	router := server.Router()
+	assert.Equal(t, http.StatusOK, adminRequest(router, "POST", "/robot/robot1/pickup/item1", "").Code)
+	assert.Equal(t, http.StatusOK, adminRequest(router, "POST", "/robot/robot1/putdown/item1", "").Code)
+
+	w := adminRequest(router, "PATCH", "/robot/robot1/state", `{"energy": -5}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = adminRequest(router, "GET", "/admin/invariants?invariant=energy", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Violations []InvariantViolation `json:"violations"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	require.Len(t, response.Violations, 1)
+	assert.Equal(t, "robot1", response.Violations[0].Subject)
+}
@@ -0,0 +1,82 @@
+package robotapi
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// inventorySorters are the sort fields of an inventory, in addition to the
+// ones of world items
+var inventorySorters = map[string]func(a, b InventoryItem) bool{
+	"slot": func(a, b InventoryItem) bool { return a.Slot < b.Slot },
+}
+
+// GetInventory returns the items a robot carries with their details, an
+// aggregate summary and links to the actions currently possible with each
+// item.
+// Supported query parameters: sort (slot, id, type, weight; prefix with "-"
+// for descending).
+func (h *RobotHandler) GetInventory(c *gin.Context) {
+	id := c.Param("id")
+	world := h.world(c)
+	robot, err := world.GetRobot(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	sortBy := c.DefaultQuery("sort", "slot")
+	descending := strings.HasPrefix(sortBy, "-")
+	less, exists := inventorySorters[strings.TrimPrefix(sortBy, "-")]
+	if itemLess, isItemField := itemSorters[strings.TrimPrefix(sortBy, "-")]; isItemField {
+		less, exists = func(a, b InventoryItem) bool {
+			return itemLess(Item{ID: a.ID, Type: a.Type, Weight: a.Weight}, Item{ID: b.ID, Type: b.Type, Weight: b.Weight})
+		}, true
+	}
+	if !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid sort field, use slot, id, type or weight"})
+		return
+	}
+
+	baseURL := requestBaseURL(c)
+	affordances := h.affordances(c)
+	inventory := Inventory{
+		RobotID: robot.ID,
+		Items:   []InventoryItem{},
+		Summary: InventorySummary{SlotsUsed: len(robot.Inventory), Types: map[string]int{}},
+		Links: []Link{
+			{Rel: "self", Href: fmt.Sprintf("%s/robot/%s/inventory", baseURL, robot.ID)},
+			{Rel: "robot", Href: fmt.Sprintf("%s/robot/%s/status", baseURL, robot.ID)},
+		},
+	}
+	for slot, itemID := range robot.Inventory {
+		item, err := world.GetItem(itemID)
+		if err != nil {
+			// Items that vanished from the world are still listed by ID
+			item = Item{ID: itemID}
+		}
+		inventory.Items = append(inventory.Items, InventoryItem{ID: item.ID, Type: item.Type, Weight: item.Weight, Slot: slot, Links: affordances.ItemLinks(robot, item)})
+		inventory.Summary.TotalWeight += item.Weight
+		if item.Type != "" {
+			inventory.Summary.Types[item.Type]++
+		}
+	}
+
+	items := inventory.Items
+	sort.Slice(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if descending {
+			a, b = b, a
+		}
+		if less(a, b) || less(b, a) {
+			return less(a, b)
+		}
+		return items[i].Slot < items[j].Slot
+	})
+
+	c.JSON(http.StatusOK, inventory)
+}
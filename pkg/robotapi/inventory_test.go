@@ -0,0 +1,79 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupInventory returns a router whose robot1 carries item2, item1 and
+// item4 in this order
+func setupInventory(t *testing.T) *gin.Engine {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	for _, itemID := range []string{"item2", "item1", "item4"} {
+		_, err := storage.PickupItem("robot1", itemID, func(robot *Robot) error {
+			robot.Inventory = append(robot.Inventory, itemID)
+			return nil
+		})
+		require.NoError(t, err)
+	}
+
+	handler := NewRobotHandler(storage)
+	router := gin.New()
+	router.GET("/robot/:id/inventory", handler.GetInventory)
+	return router
+}
+
+func getInventory(t *testing.T, router *gin.Engine, query string) Inventory {
+	w := send(router, "GET", "/robot/robot1/inventory"+query, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var inventory Inventory
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &inventory))
+	return inventory
+}
+
+func TestInventoryListsItemDetails(t *testing.T) {
+	router := setupInventory(t)
+	inventory := getInventory(t, router, "")
+
+	require.Len(t, inventory.Items, 3)
+	assert.Equal(t, InventoryItem{ID: "item2", Type: "tool", Weight: 3, Slot: 0, Links: []Link{
+		{Rel: "putdown", Href: "http:///robot/robot1/putdown/item2"},
+	}}, inventory.Items[0])
+	assert.Equal(t, []Link{
+		{Rel: "putdown", Href: "http:///robot/robot1/putdown/item1"},
+		{Rel: "replace-battery", Href: "http:///robot/robot1/replace-battery"},
+	}, inventory.Items[1].Links, "batteries can replace the robot's battery")
+
+	assert.Equal(t, InventorySummary{
+		TotalWeight: 9,
+		SlotsUsed:   3,
+		Types:       map[string]int{"tool": 1, "battery": 1, "armor_plate": 1},
+	}, inventory.Summary)
+	assert.Equal(t, "self", inventory.Links[0].Rel)
+
+	assert.Equal(t, http.StatusNotFound, send(router, "GET", "/robot/ghost/inventory", "").Code)
+}
+
+func TestInventorySorting(t *testing.T) {
+	router := setupInventory(t)
+	ids := func(inventory Inventory) []string {
+		var ids []string
+		for _, item := range inventory.Items {
+			ids = append(ids, item.ID)
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"item2", "item1", "item4"}, ids(getInventory(t, router, "")))
+	assert.Equal(t, []string{"item4", "item1", "item2"}, ids(getInventory(t, router, "?sort=-slot")))
+	assert.Equal(t, []string{"item1", "item2", "item4"}, ids(getInventory(t, router, "?sort=weight")))
+	assert.Equal(t, []string{"item4", "item1", "item2"}, ids(getInventory(t, router, "?sort=type")))
+
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/robot/robot1/inventory?sort=color", "").Code)
+}
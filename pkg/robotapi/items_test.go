@@ -0,0 +1,72 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func getItems(t *testing.T, query string) (int, PaginatedItems) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/items"+query, nil)
+	router.ServeHTTP(w, req)
+
+	var response PaginatedItems
+	if w.Code == http.StatusOK {
+		err := json.Unmarshal(w.Body.Bytes(), &response)
+		assert.NoError(t, err)
+	}
+	return w.Code, response
+}
+
+func TestListItemsDefault(t *testing.T) {
+	code, response := getItems(t, "")
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, 5, response.TotalCount)
+	assert.Equal(t, []string{"item1", "item2", "item3", "item4", "item5"}, response.AvailableItems)
+	assert.False(t, response.Page.HasNext)
+}
+
+func TestListItemsPagination(t *testing.T) {
+	code, response := getItems(t, "?page=2&size=2")
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []string{"item3", "item4"}, response.AvailableItems)
+	assert.Equal(t, 3, response.Page.TotalPages)
+	assert.True(t, response.Page.HasNext)
+	assert.True(t, response.Page.HasPrevious)
+	assert.Len(t, response.Links, 2)
+}
+
+func TestListItemsSortingAndFilters(t *testing.T) {
+	_, response := getItems(t, "?sort=-weight")
+	assert.Equal(t, []string{"item4", "item2", "item3", "item1", "item5"}, response.AvailableItems)
+
+	_, response = getItems(t, "?type=tool&sort=weight")
+	assert.Equal(t, []string{"item3", "item2"}, response.AvailableItems)
+
+	_, response = getItems(t, "?nearX=0&nearY=0&radius=2")
+	assert.Equal(t, []string{"item1", "item2"}, response.AvailableItems)
+
+	code, _ := getItems(t, "?nearX=0&radius=2")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, _ = getItems(t, "?sort=color")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestListItemsEmptyResultBeyondFirstPage(t *testing.T) {
+	code, response := getItems(t, "?type=nope&page=2")
+
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.AvailableItems)
+	assert.Equal(t, 1, response.Page.Number)
+	assert.False(t, response.Page.HasNext)
+	assert.False(t, response.Page.HasPrevious)
+}
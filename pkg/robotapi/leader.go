@@ -0,0 +1,209 @@
+package robotapi
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// simulationLease is the name of the lease whose holder runs the simulation
+const simulationLease = "simulation"
+
+// Lease is a lock with an expiry held by one instance
+type Lease struct {
+	Holder    string    `json:"holder"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// LeaseStore keeps leases shared by all instances of a deployment. A Redis
+// implementation maps Acquire to SET NX PX plus a check-and-extend script,
+// an etcd one to a lease-bound key.
+type LeaseStore interface {
+	// Acquire takes the lease for holder if it is free or expired, or
+	// extends it if holder already has it. It returns the lease as it is
+	// afterwards, so a lease held by someone else is not an error.
+	Acquire(ctx context.Context, name, holder string, ttl time.Duration) (Lease, error)
+	// Release gives up the lease if holder has it
+	Release(ctx context.Context, name, holder string) error
+}
+
+// MemoryLeases keeps leases in memory. It elects a leader among servers in
+// one process; a single server always wins.
+type MemoryLeases struct {
+	leases map[string]Lease
+	now    func() time.Time
+	mutex  sync.Mutex
+}
+
+// NewMemoryLeases creates an empty in-memory lease store
+func NewMemoryLeases() *MemoryLeases {
+	return &MemoryLeases{leases: make(map[string]Lease), now: time.Now}
+}
+
+// SetClock replaces the clock that decides when leases expire
+func (m *MemoryLeases) SetClock(clock Clock) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.now = clock.Now
+}
+
+// Acquire takes or extends the lease
+func (m *MemoryLeases) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (Lease, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.now()
+	lease, exists := m.leases[name]
+	if !exists || lease.Holder == holder || !now.Before(lease.ExpiresAt) {
+		lease = Lease{Holder: holder, ExpiresAt: now.Add(ttl)}
+		m.leases[name] = lease
+	}
+	return lease, nil
+}
+
+// Release gives up the lease
+func (m *MemoryLeases) Release(ctx context.Context, name, holder string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.leases[name].Holder == holder {
+		delete(m.leases, name)
+	}
+	return nil
+}
+
+// LeaderStatus shows which instance runs the simulation
+type LeaderStatus struct {
+	Instance  string     `json:"instance"`
+	Leader    string     `json:"leader,omitempty"` // empty while unknown
+	IsLeader  bool       `json:"is_leader"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// LeaderElector makes sure only one instance runs singleton work like the
+// simulation loop. The leader renews its lease three times per TTL; if it
+// stops doing so, another instance takes over once the lease expires.
+type LeaderElector struct {
+	leases   LeaseStore
+	instance string
+	ttl      time.Duration
+	lease    Lease
+	leader   bool
+	clock    Clock
+	mutex    sync.Mutex
+}
+
+// NewLeaderElector creates an elector for the given instance ID
+func NewLeaderElector(leases LeaseStore, instance string, ttl time.Duration) *LeaderElector {
+	return &LeaderElector{leases: leases, instance: instance, ttl: ttl, clock: SystemClock{}}
+}
+
+// SetClock replaces the clock that paces the campaign
+func (e *LeaderElector) SetClock(clock Clock) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.clock = clock
+}
+
+// Status returns the last known leader
+func (e *LeaderElector) Status() LeaderStatus {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	status := LeaderStatus{Instance: e.instance, Leader: e.lease.Holder, IsLeader: e.leader}
+	if e.lease.Holder != "" {
+		expires := e.lease.ExpiresAt
+		status.ExpiresAt = &expires
+	}
+	return status
+}
+
+// IsLeader reports whether this instance currently leads
+func (e *LeaderElector) IsLeader() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.leader
+}
+
+// Run campaigns for the lease until ctx is done. While this instance
+// leads, lead runs with a context that is cancelled when leadership is
+// lost. On return the lease is released, so others can fail over at once.
+func (e *LeaderElector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	e.mutex.Lock()
+	ticker := e.clock.NewTicker(e.ttl / 3)
+	e.mutex.Unlock()
+	defer ticker.Stop()
+
+	var stopLeading context.CancelFunc
+	var done chan struct{}
+	step := func() {
+		elected := e.campaign(ctx)
+		switch {
+		case elected && stopLeading == nil:
+			log.Printf("Instance %s was elected leader", e.instance)
+			var leadCtx context.Context
+			leadCtx, stopLeading = context.WithCancel(ctx)
+			done = make(chan struct{})
+			go func() {
+				defer close(done)
+				lead(leadCtx)
+			}()
+		case !elected && stopLeading != nil:
+			log.Printf("Instance %s lost the leadership", e.instance)
+			stopLeading()
+			<-done
+			stopLeading = nil
+		}
+	}
+
+	step()
+	for {
+		select {
+		case <-ctx.Done():
+			if stopLeading != nil {
+				stopLeading()
+				<-done
+			}
+			e.resign()
+			return
+		case <-ticker.C():
+			step()
+		}
+	}
+}
+
+// campaign tries to take or renew the lease and reports whether this
+// instance leads. If the store fails, a leader keeps leading only while its
+// lease outlasts the next attempt, so it has stepped down before anyone
+// else can take over.
+func (e *LeaderElector) campaign(ctx context.Context) bool {
+	lease, err := e.leases.Acquire(ctx, simulationLease, e.instance, e.ttl)
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if err != nil {
+		log.Printf("Leader election failed: %v", err)
+		e.leader = e.leader && e.clock.Now().Add(e.ttl/3).Before(e.lease.ExpiresAt)
+		return e.leader
+	}
+	e.lease = lease
+	e.leader = lease.Holder == e.instance
+	return e.leader
+}
+
+// resign releases the lease if this instance holds it
+func (e *LeaderElector) resign() {
+	e.mutex.Lock()
+	leader := e.leader
+	e.leader = false
+	e.mutex.Unlock()
+
+	if leader {
+		ctx, cancel := context.WithTimeout(context.Background(), e.ttl)
+		defer cancel()
+		if err := e.leases.Release(ctx, simulationLease, e.instance); err != nil {
+			log.Printf("Could not release the leader lease: %v", err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package robotapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingLeases simulates an instance that lost its connection to the store
+type failingLeases struct {
+	LeaseStore
+	failing atomic.Bool
+}
+
+func (f *failingLeases) Acquire(ctx context.Context, name, holder string, ttl time.Duration) (Lease, error) {
+	if f.failing.Load() {
+		return Lease{}, errors.New("store unreachable")
+	}
+	return f.LeaseStore.Acquire(ctx, name, holder, ttl)
+}
+
+func TestMemoryLeases(t *testing.T) {
+	now := time.Unix(0, 0)
+	leases := NewMemoryLeases()
+	leases.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	lease, _ := leases.Acquire(ctx, "sim", "a", time.Minute)
+	assert.Equal(t, "a", lease.Holder)
+	lease, _ = leases.Acquire(ctx, "sim", "b", time.Minute)
+	assert.Equal(t, "a", lease.Holder, "held leases are not taken over")
+
+	now = now.Add(2 * time.Minute)
+	lease, _ = leases.Acquire(ctx, "sim", "b", time.Minute)
+	assert.Equal(t, "b", lease.Holder, "expired leases are")
+
+	leases.Release(ctx, "sim", "a")
+	lease, _ = leases.Acquire(ctx, "sim", "c", time.Minute)
+	assert.Equal(t, "b", lease.Holder, "only the holder can release")
+	leases.Release(ctx, "sim", "b")
+	lease, _ = leases.Acquire(ctx, "sim", "c", time.Minute)
+	assert.Equal(t, "c", lease.Holder)
+}
+
+func TestLeaderFailover(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	shared := NewMemoryLeases()
+	shared.SetClock(clock)
+	flaky := &failingLeases{LeaseStore: shared}
+	ttl := 30 * time.Second
+	a := NewLeaderElector(flaky, "a", ttl)
+	a.SetClock(clock)
+	b := NewLeaderElector(shared, "b", ttl)
+	b.SetClock(clock)
+
+	var leading atomic.Int32
+	var ranB atomic.Bool
+	lead := func(name string) func(ctx context.Context) {
+		return func(ctx context.Context) {
+			assert.Equal(t, int32(1), leading.Add(1), "only one instance leads")
+			if name == "b" {
+				ranB.Store(true)
+			}
+			<-ctx.Done()
+			leading.Add(-1)
+		}
+	}
+
+	ctxA, stopA := context.WithCancel(context.Background())
+	defer stopA()
+	go a.Run(ctxA, lead("a"))
+	assert.Eventually(t, a.IsLeader, time.Second, time.Millisecond)
+
+	ctxB, stopB := context.WithCancel(context.Background())
+	defer stopB()
+	go b.Run(ctxB, lead("b"))
+	assert.Eventually(t, func() bool { return b.Status().Leader == "a" }, time.Second, time.Millisecond)
+
+	// a renews on every tick, so b waits for two TTLs in vain
+	for i := 0; i < 6; i++ {
+		clock.Advance(ttl / 3)
+		renewed := clock.Now().Add(ttl)
+		assert.Eventually(t, func() bool {
+			expires := a.Status().ExpiresAt
+			return expires != nil && expires.Equal(renewed)
+		}, time.Second, time.Millisecond)
+	}
+	assert.False(t, b.IsLeader())
+	assert.Equal(t, "a", b.Status().Leader)
+
+	// a cannot renew anymore; b takes over once the lease has expired
+	flaky.failing.Store(true)
+	assert.Eventually(t, func() bool {
+		clock.Advance(ttl / 3)
+		return b.IsLeader()
+	}, time.Second, 5*time.Millisecond)
+	assert.False(t, a.IsLeader())
+	assert.Eventually(t, ranB.Load, time.Second, time.Millisecond)
+
+	// Shutting down releases the lease for an immediate failover
+	flaky.failing.Store(false)
+	stopB()
+	assert.Eventually(t, func() bool {
+		clock.Advance(ttl / 3)
+		return a.IsLeader()
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestServerLeaderStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	leases := NewMemoryLeases()
+	config := DefaultConfig()
+	config.Leases = leases
+	config.InstanceID = "api-1"
+	leases.Acquire(context.Background(), simulationLease, "api-0", time.Minute)
+
+	server, err := New(config)
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.elector.Run(ctx, server.simulation.Run)
+
+	var status LeaderStatus
+	assert.Eventually(t, func() bool {
+		w := send(server.Router(), "GET", "/leader", "")
+		json.Unmarshal(w.Body.Bytes(), &status)
+		return w.Code == http.StatusOK && status.Leader != ""
+	}, time.Second, 5*time.Millisecond)
+	assert.Equal(t, "api-1", status.Instance)
+	assert.Equal(t, "api-0", status.Leader)
+	assert.False(t, status.IsLeader)
+}
+
+func TestLeaderElectorFollowsTheClock(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	shared := NewMemoryLeases()
+	shared.SetClock(clock)
+	flaky := &failingLeases{LeaseStore: shared}
+	ttl := 30 * time.Second
+	a := NewLeaderElector(flaky, "a", ttl)
+	a.SetClock(clock)
+	b := NewLeaderElector(shared, "b", ttl)
+	b.SetClock(clock)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	idle := func(ctx context.Context) { <-ctx.Done() }
+	go a.Run(ctx, idle)
+	assert.Eventually(t, a.IsLeader, time.Second, time.Millisecond)
+	go b.Run(ctx, idle)
+	assert.Eventually(t, func() bool { return b.Status().Leader == "a" }, time.Second, time.Millisecond)
+
+	// Without the clock moving nobody steps down, however long it takes
+	flaky.failing.Store(true)
+	assert.Never(t, b.IsLeader, 50*time.Millisecond, 5*time.Millisecond)
+
+	// Once a's lease has run out on the clock, b takes over
+	assert.Eventually(t, func() bool {
+		clock.Advance(ttl / 3)
+		return b.IsLeader()
+	}, time.Second, 5*time.Millisecond)
+	assert.False(t, a.IsLeader())
+}
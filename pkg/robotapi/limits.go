@@ -0,0 +1,122 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TenantLimits caps the resources of a tenant's world; 0 means unlimited
+type TenantLimits struct {
+	MaxRobots  int `json:"max_robots"`
+	MaxItems   int `json:"max_items"`
+	MaxActions int `json:"max_actions"` // recorded actions of all robots together
+}
+
+// validate checks that no limit is negative
+func (l TenantLimits) validate() error {
+	if l.MaxRobots < 0 || l.MaxItems < 0 || l.MaxActions < 0 {
+		return errors.New("limits must not be negative")
+	}
+	return nil
+}
+
+// LimitError is returned when a change would exceed a tenant limit
+type LimitError struct {
+	Resource string // "robots", "items" or "actions"
+	Limit    int
+	Used     int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("tenant limit of %d %s reached", e.Limit, e.Resource)
+}
+
+// abortWithLimit answers with the details of an exceeded limit
+func abortWithLimit(c *gin.Context, status int, err *LimitError) {
+	c.AbortWithStatusJSON(status, gin.H{
+		"error":    "Tenant limit exceeded: " + err.Error(),
+		"resource": err.Resource,
+		"limit":    err.Limit,
+		"used":     err.Used,
+	})
+}
+
+// SetLimits replaces the limits of the world. Existing resources beyond the
+// new limits are kept, only further growth is rejected.
+func (s *RobotStorage) SetLimits(limits TenantLimits) {
+	s.mutex.Lock()
+	defer s.unlock()
+	s.limits = limits
+}
+
+// Limits returns the limits of the world
+func (s *RobotStorage) Limits() TenantLimits {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.limits
+}
+
+// ActionCount returns the number of recorded actions of all robots
+func (s *RobotStorage) ActionCount() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	count := 0
+	for _, robot := range s.robots {
+		count += len(robot.Actions)
+	}
+	return count
+}
+
+// checkActionLimit returns a LimitError once the action history is full
+func (s *RobotStorage) checkActionLimit() error {
+	limit := s.Limits().MaxActions
+	if limit == 0 {
+		return nil
+	}
+	if used := s.ActionCount(); used >= limit {
+		return &LimitError{Resource: "actions", Limit: limit, Used: used}
+	}
+	return nil
+}
+
+// RequireActionCapacity rejects commands with 429 once the tenant's action
+// history is full. Reads and dry runs stay possible.
+func (h *RobotHandler) RequireActionCapacity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || isDryRun(c) {
+			c.Next()
+			return
+		}
+		var limitErr *LimitError
+		if err := h.world(c).checkActionLimit(); errors.As(err, &limitErr) {
+			abortWithLimit(c, http.StatusTooManyRequests, limitErr)
+			return
+		}
+		c.Next()
+	}
+}
+
+// SetTenantLimits replaces the limits of a tenant
+func (h *RobotHandler) SetTenantLimits(c *gin.Context) {
+	var limits TenantLimits
+	if err := bindJSON(c, &limits); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if err := limits.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenant, exists := h.tenants.Get(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tenant not found"})
+		return
+	}
+	tenant.Storage.SetLimits(limits)
+	c.JSON(http.StatusOK, usage(tenant.ID, tenant.Storage, tenant.Events))
+}
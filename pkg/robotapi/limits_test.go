@@ -0,0 +1,60 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTenantLimits(t *testing.T) {
+	router := setupTenantServer(t)
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("X-Tenant-ID", "lab1")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var seeded TenantUsage
+	json.Unmarshal(admin("PUT", "/admin/tenants/lab1/limits", `{}`).Body.Bytes(), &seeded)
+	assert.Equal(t, http.StatusBadRequest, admin("PUT", "/admin/tenants/lab1/limits", `{"max_robots": -1}`).Code)
+	assert.Equal(t, http.StatusNotFound, admin("PUT", "/admin/tenants/lab9/limits", `{}`).Code)
+
+	// One more robot fits, the next one is rejected with details
+	limits := fmt.Sprintf(`{"max_robots": %d, "max_items": 2, "max_actions": %d}`, seeded.Robots+1, seeded.Actions+2)
+	assert.Equal(t, http.StatusOK, admin("PUT", "/admin/tenants/lab1/limits", limits).Code)
+	assert.Equal(t, http.StatusCreated, sendAs(router, "carol-key", "", "POST", "/robots", `{"id": "extra"}`).Code)
+	w := sendAs(router, "carol-key", "", "POST", "/robots", `{}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	var details struct {
+		Resource string `json:"resource"`
+		Limit    int    `json:"limit"`
+		Used     int    `json:"used"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &details)
+	assert.Equal(t, "robots", details.Resource)
+	assert.Equal(t, seeded.Robots+1, details.Limit)
+	assert.Equal(t, seeded.Robots+1, details.Used)
+
+	// Other tenants are not limited
+	assert.Equal(t, http.StatusCreated, sendAs(router, "dave-key", "", "POST", "/robots", `{}`).Code)
+
+	// Generated maps must stay within the item limit
+	w = admin("POST", "/admin/world/generate", `{"width": 10, "height": 10, "resources": 3, "seed": 1}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "items")
+
+	// Creating the robot recorded one action, so one more command fits
+	assert.Equal(t, http.StatusOK, sendAs(router, "carol-key", "", "POST", "/robot/extra/move", `{"direction": "up"}`).Code)
+	w = sendAs(router, "carol-key", "", "POST", "/robot/extra/move", `{"direction": "up"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "actions")
+	assert.Equal(t, http.StatusOK, sendAs(router, "carol-key", "", "GET", "/robot/extra/status", "").Code)
+}
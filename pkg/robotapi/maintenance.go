@@ -0,0 +1,70 @@
+package robotapi
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MaintenanceRequest is the payload for the maintenance endpoint. Without
+// a value the maintenance state is toggled.
+type MaintenanceRequest struct {
+	Enabled *bool `json:"enabled"`
+}
+
+// SetMaintenance puts a robot into maintenance or takes it out again. While
+// in maintenance the robot cannot act, stops driving and ignores attacks, so
+// operators can service the hardware safely.
+func (h *RobotHandler) SetMaintenance(c *gin.Context) {
+	var request MaintenanceRequest
+	if err := bindJSON(c, &request); err != nil && !errors.Is(err, io.EOF) {
+		invalidRequest(c, err)
+		return
+	}
+
+	changed := false
+	robot, err := h.world(c).UpdateRobot(c.Param("id"), func(robot *Robot) error {
+		if robot.Decommissioned {
+			// Decommissioned robots stay in maintenance until they are archived
+			return errActionNotAllowed(errors.New("robot is decommissioned"))
+		}
+		enabled := !robot.Maintenance
+		if request.Enabled != nil {
+			enabled = *request.Enabled
+		}
+		if enabled == robot.Maintenance {
+			return nil
+		}
+
+		changed = true
+		robot.Maintenance = enabled
+		if enabled {
+			robot.Velocity = nil
+			robot.Commanded = nil
+			appendCommandAction(robot, requestOrigin(c), "maintenance", "Entered maintenance", gin.H{"maintenance": true})
+		} else {
+			appendCommandAction(robot, requestOrigin(c), "maintenance", "Left maintenance", gin.H{"maintenance": false})
+		}
+		return nil
+	})
+	if err != nil {
+		updateFailed(c, err)
+		return
+	}
+
+	if changed {
+		eventType := "maintenance_ended"
+		if robot.Maintenance {
+			eventType = "maintenance_started"
+		}
+		h.bus(c).PublishCommand(commandID(c), eventType, robot.ID, nil)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"id":          robot.ID,
+		"maintenance": robot.Maintenance,
+		"changed":     changed,
+	})
+}
@@ -0,0 +1,53 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenance(t *testing.T) {
+	router, storage, events := setupMapRouter()
+	handler := NewRobotHandler(storage)
+	handler.SetEventBus(events)
+	router.POST("/robot/:id/maintenance", handler.SetMaintenance)
+	router.POST("/robot/:id/attack/:targetId", handler.AttackRobot)
+	router.GET("/robot/:id/scan", handler.Scan)
+	router.GET("/robot/:id/status", handler.GetStatus)
+
+	assert.Equal(t, http.StatusNotFound, send(router, "POST", "/robot/nobody/maintenance", "").Code)
+
+	// Without a body the state is toggled
+	var response map[string]interface{}
+	json.Unmarshal(send(router, "POST", "/robot/robot1/maintenance", "").Body.Bytes(), &response)
+	assert.Equal(t, true, response["maintenance"])
+	json.Unmarshal(send(router, "POST", "/robot/robot1/maintenance", `{"enabled": true}`).Body.Bytes(), &response)
+	assert.Equal(t, false, response["changed"])
+
+	// The robot can neither act nor be attacked
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	assert.Equal(t, http.StatusConflict, send(router, "GET", "/robot/robot1/scan", "").Code)
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot1/attack/robot2", "").Code)
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot2/attack/robot1", "").Code)
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, 100, robot.Energy)
+
+	var status map[string]interface{}
+	json.Unmarshal(send(router, "GET", "/robot/robot1/status", "").Body.Bytes(), &status)
+	assert.Equal(t, true, status["maintenance"])
+	var worldMap WorldMap
+	json.Unmarshal(send(router, "GET", "/world/map", "").Body.Bytes(), &worldMap)
+	assert.True(t, worldMap.Robots[0].Maintenance)
+
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/maintenance", `{"enabled": false}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+
+	// Both transitions were announced
+	var types []string
+	for _, event := range events.Since(0) {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{"maintenance_started", "maintenance_ended", "robot_moved"}, types)
+}
@@ -0,0 +1,92 @@
+package robotapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MethodOverrideHeader lets clients behind proxies that block some methods
+// send them as POST
+const MethodOverrideHeader = "X-HTTP-Method-Override"
+
+// overridableMethods are the methods POST requests may be overridden with
+var overridableMethods = []string{http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// headRequestKey marks GET requests that were HEAD requests
+type headRequestKey struct{}
+
+// headWriter drops the body of a response to a HEAD request but counts it
+// for the Content-Length header
+type headWriter struct {
+	gin.ResponseWriter
+	length int
+}
+
+func (w *headWriter) Write(data []byte) (int, error) {
+	w.length += len(data)
+	return len(data), nil
+}
+
+func (w *headWriter) WriteString(s string) (int, error) {
+	w.length += len(s)
+	return len(s), nil
+}
+
+// MethodOverride serves HEAD requests with the GET route of the path and
+// POST requests with an X-HTTP-Method-Override header of PUT, PATCH or
+// DELETE with the route of that method. Requests are routed again with the
+// new method, so it must be the router's first middleware; everything else
+// only sees the effective method. Responses to HEAD requests keep their
+// headers and lose their body; GET handlers with side effects skip them
+// for HEAD requests, see isHeadRequest.
+func MethodOverride(router *gin.Engine) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodHead:
+			c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), headRequestKey{}, true))
+			reroute(c, router, http.MethodGet)
+			return
+		case http.MethodPost:
+			override := strings.ToUpper(strings.TrimSpace(c.GetHeader(MethodOverrideHeader)))
+			if override == "" {
+				break
+			}
+			if !containsString(overridableMethods, override) {
+				c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": MethodOverrideHeader + " must be PUT, PATCH or DELETE"})
+				return
+			}
+			reroute(c, router, override)
+			return
+		}
+
+		if isHeadRequest(c) {
+			writer := &headWriter{ResponseWriter: c.Writer}
+			c.Writer = writer
+			c.Next()
+			if !writer.Written() && writer.Header().Get("Content-Length") == "" {
+				writer.Header().Set("Content-Length", strconv.Itoa(writer.length))
+			}
+			writer.WriteHeaderNow()
+			return
+		}
+		c.Next()
+	}
+}
+
+// isHeadRequest reports whether a GET request came in as a HEAD request,
+// which must not change anything
+func isHeadRequest(c *gin.Context) bool {
+	head, _ := c.Request.Context().Value(headRequestKey{}).(bool)
+	return head
+}
+
+// reroute handles the request again as a request of the method
+func reroute(c *gin.Context, router *gin.Engine, method string) {
+	c.Request.Method = method
+	router.HandleContext(c)
+	c.Abort()
+}
@@ -0,0 +1,85 @@
+package robotapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadRequestsGetHeadersWithoutBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+
+	get := send(router, "GET", "/items", "")
+	require.Equal(t, http.StatusOK, get.Code)
+
+	head := send(router, "HEAD", "/items", "")
+	assert.Equal(t, http.StatusOK, head.Code)
+	assert.Empty(t, head.Body.String())
+	assert.Equal(t, get.Header().Get("Content-Type"), head.Header().Get("Content-Type"))
+	assert.Equal(t, strconv.Itoa(get.Body.Len()), head.Header().Get("Content-Length"))
+
+	assert.Equal(t, http.StatusNotFound, send(router, "HEAD", "/robot/ghost/status", "").Code)
+	assert.Equal(t, http.StatusNotFound, send(router, "HEAD", "/nowhere", "").Code)
+}
+
+func TestMethodOverride(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(MethodOverride(router))
+	var calls []string
+	router.PATCH("/robot/:id/state", func(c *gin.Context) {
+		calls = append(calls, c.Request.Method+" "+c.Param("id"))
+		c.Status(http.StatusOK)
+	})
+	router.POST("/robots", func(c *gin.Context) {
+		calls = append(calls, c.Request.Method)
+		c.Status(http.StatusCreated)
+	})
+
+	override := func(path, method string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", path, nil)
+		req.Header.Set(MethodOverrideHeader, method)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	assert.Equal(t, http.StatusOK, override("/robot/robot1/state", "patch").Code)
+	assert.Equal(t, http.StatusCreated, send(router, "POST", "/robots", "").Code, "POST without override")
+	assert.Equal(t, http.StatusBadRequest, override("/robot/robot1/state", "GET").Code)
+	assert.Equal(t, http.StatusNotFound, override("/robots", "DELETE").Code)
+	assert.Equal(t, []string{"PATCH robot1", "POST"}, calls)
+}
+
+func TestHeadRequestsChangeNothing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.Quotas = map[string]int{"scan": 1}
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+	before, _ := server.Storage().GetRobot("robot1")
+	version := server.Storage().Version()
+
+	head := send(router, "HEAD", "/robot/robot1/scan", "")
+	assert.Equal(t, http.StatusOK, head.Code)
+	assert.Empty(t, head.Body.String())
+	assert.Equal(t, "0", head.Header().Get("X-Quota-Remaining"))
+	after, _ := server.Storage().GetRobot("robot1")
+	assert.Equal(t, before.Actions, after.Actions)
+	assert.Equal(t, version, server.Storage().Version())
+	assert.Equal(t, http.StatusBadRequest, send(router, "HEAD", "/robot/robot1/scan?radius=x", "").Code)
+
+	// The quota is still there for the scan itself
+	assert.Equal(t, http.StatusOK, send(router, "GET", "/robot/robot1/scan", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(router, "GET", "/robot/robot1/scan", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(router, "HEAD", "/robot/robot1/scan", "").Code)
+}
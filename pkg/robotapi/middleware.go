@@ -0,0 +1,181 @@
+package robotapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireAdmin protects admin endpoints. Callers either present the static
+// admin token as bearer token or authenticate as a principal with the admin
+// role. Without a token and without an authentication method admin endpoints
+// are disabled entirely.
+func RequireAdmin(token string, auth *Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" && !auth.Enabled() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin endpoints are disabled"})
+			return
+		}
+
+		if hasAdminToken(c, token) {
+			c.Next()
+			return
+		}
+
+		principal, err := auth.Identify(c)
+		if err != nil || principal == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid admin token"})
+			return
+		}
+		if !principal.HasRole(RoleAdmin) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin role required"})
+			return
+		}
+
+		c.Set("principal", principal)
+		c.Next()
+	}
+}
+
+// hasAdminToken reports whether the request carries the configured admin
+// token as bearer token
+func hasAdminToken(c *gin.Context, token string) bool {
+	provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return token != "" && subtle.ConstantTimeCompare([]byte(provided), []byte(token)) == 1
+}
+
+// AssignCommandID gives every mutating request a command ID. It is returned
+// in the X-Command-ID header and recorded with the actions and events the
+// request causes, so their effects can be traced back to the request.
+func AssignCommandID() gin.HandlerFunc {
+	ids := UUIDGenerator{}
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			id := ids.NewID()
+			c.Set("command_id", id)
+			c.Header("X-Command-ID", id)
+		}
+		c.Next()
+	}
+}
+
+// commandID returns the command ID of the current request, empty for reads
+func commandID(c *gin.Context) string {
+	return c.GetString("command_id")
+}
+
+// ActionOrigin is the request an action was caused by; the zero value
+// stands for the simulation itself
+type ActionOrigin struct {
+	CommandID string
+	Actor     string // principal ID, empty without authentication
+	ClientIP  string
+	UserAgent string
+}
+
+// requestOrigin describes the current request for the actions it causes,
+// as set up by AssignCommandID and the authentication
+func requestOrigin(c *gin.Context) ActionOrigin {
+	origin := ActionOrigin{
+		CommandID: commandID(c),
+		ClientIP:  c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+	}
+	if principal := currentPrincipal(c); principal != nil {
+		origin.Actor = principal.ID
+	}
+	return origin
+}
+
+// abortWithProblem answers with an RFC 7807 problem body
+func abortWithProblem(c *gin.Context, status int, detail string) {
+	c.Header("Content-Type", "application/problem+json")
+	c.AbortWithStatusJSON(status, gin.H{
+		"type":   "about:blank",
+		"title":  http.StatusText(status),
+		"status": status,
+		"detail": detail,
+	})
+}
+
+// bufferedWriter collects a handler's response so it can be discarded when
+// the handler times out
+type bufferedWriter struct {
+	gin.ResponseWriter
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func newBufferedWriter(w gin.ResponseWriter) *bufferedWriter {
+	return &bufferedWriter{ResponseWriter: w, header: make(http.Header), status: http.StatusOK}
+}
+
+func (w *bufferedWriter) Header() http.Header               { return w.header }
+func (w *bufferedWriter) WriteHeader(code int)              { w.status = code }
+func (w *bufferedWriter) WriteHeaderNow()                   {}
+func (w *bufferedWriter) Write(data []byte) (int, error)    { return w.body.Write(data) }
+func (w *bufferedWriter) WriteString(s string) (int, error) { return w.body.WriteString(s) }
+func (w *bufferedWriter) Status() int                       { return w.status }
+func (w *bufferedWriter) Size() int                         { return w.body.Len() }
+func (w *bufferedWriter) Written() bool                     { return w.body.Len() > 0 }
+
+// WithTimeout runs a handler with a deadline. If it does not finish in time
+// the client gets 504 with a problem body; the handler's late response is
+// discarded. The handler sees the deadline through its request context.
+// The handler runs outside gin's recovery, so a panic is recovered and logged
+// here and answered with 500.
+func WithTimeout(timeout time.Duration, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+		defer cancel()
+
+		writer := newBufferedWriter(c.Writer)
+		handlerCtx := c.Copy()
+		handlerCtx.Request = c.Request.WithContext(ctx)
+		handlerCtx.Writer = writer
+
+		done := make(chan struct{})
+		panicked := false
+		go func() {
+			defer close(done)
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					log.Printf("Handler of %s %s panicked: %v\n%s", c.Request.Method, c.Request.URL.Path, recovered, debug.Stack())
+					panicked = true
+				}
+			}()
+			handler(handlerCtx)
+		}()
+
+		select {
+		case <-done:
+			if panicked {
+				// The partial response was only buffered, the client has seen nothing yet
+				if !c.Writer.Written() {
+					abortWithProblem(c, http.StatusInternalServerError, "The request failed unexpectedly")
+				}
+				return
+			}
+			for key, values := range writer.header {
+				c.Writer.Header()[key] = values
+			}
+			c.Writer.WriteHeader(writer.status)
+			c.Writer.Write(writer.body.Bytes())
+			if handlerCtx.IsAborted() {
+				c.Abort()
+			}
+		case <-ctx.Done():
+			abortWithProblem(c, http.StatusGatewayTimeout, "The request did not complete within "+timeout.String())
+		}
+	}
+}
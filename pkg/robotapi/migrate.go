@@ -0,0 +1,404 @@
+package robotapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration modes
+const (
+	MigrateAuto = "auto" // migrate at startup, then serve
+	MigrateOnly = "only" // migrate and exit, e.g. in a deploy job
+	MigrateOff  = "off"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// Migration is one versioned step of the database schema
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// Migrations returns the embedded schema migrations ordered by version.
+// Files are named <version>_<name>.sql.
+func Migrations() ([]Migration, error) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, err
+	}
+	migrations := []Migration{}
+	for _, entry := range entries {
+		version, name, found := strings.Cut(strings.TrimSuffix(entry.Name(), ".sql"), "_")
+		number, err := strconv.Atoi(version)
+		if !found || err != nil {
+			return nil, fmt.Errorf("migration %s is not named <version>_<name>.sql", entry.Name())
+		}
+		data, err := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: number, Name: name, SQL: string(data)})
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrator applies the schema migrations to a database. Every migration
+// runs in a transaction together with its entry in schema_migrations.
+type Migrator struct {
+	db         *sql.DB
+	driver     string
+	migrations []Migration
+	clock      Clock
+}
+
+// NewMigrator creates a migrator for the database opened with the driver
+func NewMigrator(db *sql.DB, driver string) (*Migrator, error) {
+	migrations, err := Migrations()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, driver: driver, migrations: migrations, clock: SystemClock{}}, nil
+}
+
+// SetClock replaces the clock migrations and imports are dated with
+func (m *Migrator) SetClock(clock Clock) {
+	m.clock = clock
+}
+
+// Applied returns the versions already in the database
+func (m *Migrator) Applied(ctx context.Context) (map[int]bool, error) {
+	_, err := m.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version    INTEGER PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+)`)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := m.db.QueryContext(ctx, "SELECT version FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := make(map[int]bool)
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		applied[version] = true
+	}
+	return applied, rows.Err()
+}
+
+// Up applies all pending migrations in order and returns them
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	done := []Migration{}
+	for _, migration := range m.migrations {
+		if applied[migration.Version] {
+			continue
+		}
+		err := inTransaction(ctx, m.db, func(tx *sql.Tx) error {
+			for _, statement := range splitStatements(migration.SQL) {
+				if _, err := tx.ExecContext(ctx, statement); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx, bindParams(m.driver, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+				migration.Version, migration.Name, m.clock.Now().UTC())
+			return err
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// ImportSnapshot replaces the world in the database with the snapshot,
+// unless a snapshot of that name was imported before. It reports whether
+// the snapshot was imported.
+func (m *Migrator) ImportSnapshot(ctx context.Context, name string, snapshot WorldSnapshot) (bool, error) {
+	imported := false
+	err := inTransaction(ctx, m.db, func(tx *sql.Tx) error {
+		var count int
+		err := tx.QueryRowContext(ctx, bindParams(m.driver, "SELECT COUNT(*) FROM data_imports WHERE name = ?"), name).Scan(&count)
+		if err != nil || count > 0 {
+			return err
+		}
+		if err := m.insertWorld(ctx, tx, snapshot, nil); err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx, bindParams(m.driver, "INSERT INTO data_imports (name, imported_at) VALUES (?, ?)"), name, m.clock.Now().UTC())
+		imported = err == nil
+		return err
+	})
+	return imported, err
+}
+
+// insertWorld replaces all rows of the world tables. If inserted is set, it
+// is called after every row written.
+func (m *Migrator) insertWorld(ctx context.Context, tx *sql.Tx, snapshot WorldSnapshot, inserted func()) error {
+	exec := func(query string, args ...any) error {
+		_, err := tx.ExecContext(ctx, bindParams(m.driver, query), args...)
+		if err == nil && inserted != nil && strings.HasPrefix(query, "INSERT") {
+			inserted()
+		}
+		return err
+	}
+	for _, table := range []string{"grants", "robot_actions", "items", "hazards", "map_layout", "robots"} {
+		if err := exec("DELETE FROM " + table); err != nil {
+			return err
+		}
+	}
+
+	slots := make(map[string]int)
+	carriers := make(map[string]string)
+	for _, robot := range snapshot.Robots {
+		extra, err := json.Marshal(robotExtra{Tags: robot.Tags, Effects: robot.Effects, Exact: robot.Exact, Velocity: robot.Velocity, Commanded: robot.Commanded,
+			Decommissioned: robot.Decommissioned})
+		if err != nil {
+			return err
+		}
+		err = exec(`INSERT INTO robots (id, name, owner, class, direction, x, y, z, energy, armor, sensor,
+    battery_cycles, battery_capacity, maintenance, firmware, extra) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			robot.ID, robot.Name, robot.Owner, robot.Class, robot.Direction, robot.Position.X, robot.Position.Y, robot.Position.Z,
+			robot.Energy, robot.Armor, robot.Sensor, robot.BatteryCycles, robot.BatteryCapacity, robot.Maintenance, robot.Firmware,
+			string(extra))
+		if err != nil {
+			return fmt.Errorf("robot %s: %w", robot.ID, err)
+		}
+		for seq, action := range robot.Actions {
+			data := ""
+			if action.Data != nil {
+				encoded, err := json.Marshal(action.Data)
+				if err != nil {
+					return fmt.Errorf("action %d of robot %s: %w", seq, robot.ID, err)
+				}
+				data = string(encoded)
+			}
+			err := exec(`INSERT INTO robot_actions (robot_id, seq, type, details, data, command_id, actor, client_ip, user_agent,
+    created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				robot.ID, seq, action.Type, action.Details, data, action.CommandID, action.Actor, action.ClientIP, action.UserAgent,
+				action.Timestamp.UTC())
+			if err != nil {
+				return fmt.Errorf("action %d of robot %s: %w", seq, robot.ID, err)
+			}
+		}
+		for slot, itemID := range robot.Inventory {
+			slots[itemID] = slot
+			carriers[itemID] = robot.ID
+		}
+	}
+
+	for _, item := range snapshot.Items {
+		var carrier, slot any
+		if robotID, carried := carriers[item.ID]; carried {
+			carrier, slot = robotID, slots[item.ID]
+		}
+		err := exec("INSERT INTO items (id, type, weight, x, y, z, carried_by, inventory_slot) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			item.ID, item.Type, item.Weight, item.Position.X, item.Position.Y, item.Position.Z, carrier, slot)
+		if err != nil {
+			return fmt.Errorf("item %s: %w", item.ID, err)
+		}
+	}
+
+	for id, hazard := range snapshot.Hazards {
+		err := exec("INSERT INTO hazards (id, type, x, y, z, radius, damage_per_tick) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			id, hazard.Type, hazard.Center.X, hazard.Center.Y, hazard.Center.Z, hazard.Radius, hazard.DamagePerTick)
+		if err != nil {
+			return fmt.Errorf("hazard %d: %w", id, err)
+		}
+	}
+
+	robotIDs := make([]string, 0, len(snapshot.Grants))
+	for robotID := range snapshot.Grants {
+		robotIDs = append(robotIDs, robotID)
+	}
+	sort.Strings(robotIDs)
+	for _, robotID := range robotIDs {
+		grantees := make([]string, 0, len(snapshot.Grants[robotID]))
+		for grantee := range snapshot.Grants[robotID] {
+			grantees = append(grantees, grantee)
+		}
+		sort.Strings(grantees)
+		for _, grantee := range grantees {
+			for _, permission := range snapshot.Grants[robotID][grantee] {
+				if err := exec("INSERT INTO grants (robot_id, grantee, permission) VALUES (?, ?, ?)", robotID, grantee, permission); err != nil {
+					return fmt.Errorf("grant of robot %s: %w", robotID, err)
+				}
+			}
+		}
+	}
+
+	layout, err := json.Marshal(snapshot.Layout)
+	if err != nil {
+		return err
+	}
+	return exec("INSERT INTO map_layout (id, layout) VALUES (?, ?)", 1, string(layout))
+}
+
+// robotExtra holds the robot fields without a column of their own
+type robotExtra struct {
+	Tags      []string       `json:"tags,omitempty"`
+	Effects   []StatusEffect `json:"effects,omitempty"`
+	Exact     *Vector        `json:"exact_position,omitempty"`
+	Velocity  *Vector        `json:"velocity,omitempty"`
+	Commanded *Vector        `json:"commanded_velocity,omitempty"`
+
+	Decommissioned bool `json:"decommissioned,omitempty"`
+}
+
+// ReadSnapshot decodes a snapshot as written by the archiver, gzipped or
+// plain JSON
+func ReadSnapshot(r io.Reader) (WorldSnapshot, error) {
+	var snapshot WorldSnapshot
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return snapshot, err
+	}
+	if bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return snapshot, &invalidSnapshotError{err}
+		}
+		if data, err = io.ReadAll(reader); err != nil {
+			return snapshot, &invalidSnapshotError{err}
+		}
+	}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return snapshot, &invalidSnapshotError{err}
+	}
+	if err := snapshot.checkIDs(); err != nil {
+		return snapshot, &invalidSnapshotError{err}
+	}
+	return snapshot, nil
+}
+
+// Migrate brings the database of the configuration up to date and imports
+// the configured snapshot. Without a database there is nothing to do,
+// unless migrating is all the instance should do.
+func Migrate(ctx context.Context, config Config) error {
+	if config.DatabaseDriver == "" {
+		if config.Migrate == MigrateOnly {
+			return errors.New("migrating needs a database")
+		}
+		return nil
+	}
+	db, err := sql.Open(config.DatabaseDriver, config.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	if err := db.PingContext(ctx); err != nil {
+		return err
+	}
+
+	migrator, err := NewMigrator(db, config.DatabaseDriver)
+	if err != nil {
+		return err
+	}
+	if config.Clock != nil {
+		migrator.SetClock(config.Clock)
+	}
+	applied, err := migrator.Up(ctx)
+	for _, migration := range applied {
+		log.Printf("Applied migration %d_%s", migration.Version, migration.Name)
+	}
+	if err != nil {
+		return err
+	}
+
+	if config.MigrateImport == "" {
+		return nil
+	}
+	file, err := os.Open(config.MigrateImport)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	snapshot, err := ReadSnapshot(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", config.MigrateImport, err)
+	}
+	imported, err := migrator.ImportSnapshot(ctx, path.Base(config.MigrateImport), snapshot)
+	if imported {
+		log.Printf("Imported %d robots and %d items from %s", len(snapshot.Robots), len(snapshot.Items), config.MigrateImport)
+	}
+	return err
+}
+
+// inTransaction runs fn in a transaction, committed if fn succeeds
+func inTransaction(ctx context.Context, db *sql.DB, fn func(tx *sql.Tx) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// splitStatements splits a migration into its statements, as not every
+// driver executes several at once. Statements end with a semicolon at the
+// end of a line.
+func splitStatements(script string) []string {
+	statements := []string{}
+	for _, statement := range strings.Split(script, ";\n") {
+		lines := []string{}
+		for _, line := range strings.Split(statement, "\n") {
+			if !strings.HasPrefix(strings.TrimSpace(line), "--") {
+				lines = append(lines, line)
+			}
+		}
+		if statement = strings.TrimSpace(strings.TrimSuffix(strings.Join(lines, "\n"), ";")); statement != "" {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+// bindParams rewrites ? placeholders to $1, $2, ... for PostgreSQL drivers
+func bindParams(driver, query string) string {
+	if driver != "postgres" && driver != "pgx" {
+		return query
+	}
+	var rewritten strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			rewritten.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		rewritten.WriteRune(r)
+	}
+	return rewritten.String()
+}
@@ -0,0 +1,294 @@
+package robotapi
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSQL is a database/sql driver recording statements instead of
+// executing them. It answers the few queries the migrator makes.
+type fakeSQL struct {
+	mutex     sync.Mutex
+	databases map[string]*fakeDatabase
+}
+
+type fakeDatabase struct {
+	statements []fakeStatement
+	versions   []int64
+	imports    map[string]bool
+	tables     map[string][]string // table -> first value of each inserted row
+	failOn     string              // statements containing this fail
+	lose       string              // rows inserted with this first value are lost
+}
+
+type fakeStatement struct {
+	query string
+	args  []driver.Value
+}
+
+var fakeDriver = &fakeSQL{databases: make(map[string]*fakeDatabase)}
+
+func init() {
+	sql.Register("fakesql", fakeDriver)
+}
+
+// fakeDB returns a fresh database registered under the test's name
+func fakeDB(t *testing.T) (*sql.DB, *fakeDatabase) {
+	fakeDriver.mutex.Lock()
+	database := &fakeDatabase{imports: make(map[string]bool), tables: make(map[string][]string)}
+	fakeDriver.databases[t.Name()] = database
+	fakeDriver.mutex.Unlock()
+	db, err := sql.Open("fakesql", t.Name())
+	assert.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db, database
+}
+
+func (d *fakeSQL) Open(name string) (driver.Conn, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return &fakeConn{database: d.databases[name]}, nil
+}
+
+// executed returns the statements starting with prefix
+func (d *fakeDatabase) executed(prefix string) []fakeStatement {
+	fakeDriver.mutex.Lock()
+	defer fakeDriver.mutex.Unlock()
+	statements := []fakeStatement{}
+	for _, statement := range d.statements {
+		if strings.HasPrefix(statement.query, prefix) {
+			statements = append(statements, statement)
+		}
+	}
+	return statements
+}
+
+type fakeConn struct {
+	database *fakeDatabase
+	pending  []fakeStatement
+	inTx     bool
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{conn: c, query: query}, nil
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	c.pending = nil
+	return c, nil
+}
+
+func (c *fakeConn) Commit() error {
+	for _, statement := range c.pending {
+		c.apply(statement)
+	}
+	c.inTx = false
+	return nil
+}
+
+func (c *fakeConn) Rollback() error {
+	c.inTx = false
+	return nil
+}
+
+func (c *fakeConn) apply(statement fakeStatement) {
+	fakeDriver.mutex.Lock()
+	defer fakeDriver.mutex.Unlock()
+	c.database.statements = append(c.database.statements, statement)
+	switch {
+	case strings.HasPrefix(statement.query, "INSERT INTO schema_migrations"):
+		c.database.versions = append(c.database.versions, statement.args[0].(int64))
+	case strings.HasPrefix(statement.query, "INSERT INTO data_imports"):
+		c.database.imports[statement.args[0].(string)] = true
+	case strings.HasPrefix(statement.query, "INSERT INTO "):
+		table := strings.Fields(statement.query)[2]
+		if value := fmt.Sprint(statement.args[0]); value != c.database.lose {
+			c.database.tables[table] = append(c.database.tables[table], value)
+		}
+	case strings.HasPrefix(statement.query, "DELETE FROM "):
+		delete(c.database.tables, strings.TrimPrefix(statement.query, "DELETE FROM "))
+	}
+}
+
+type fakeStmt struct {
+	conn  *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	database := s.conn.database
+	if database.failOn != "" && strings.Contains(s.query, database.failOn) {
+		return nil, errors.New("syntax error")
+	}
+	statement := fakeStatement{query: s.query, args: args}
+	if s.conn.inTx {
+		s.conn.pending = append(s.conn.pending, statement)
+	} else {
+		s.conn.apply(statement)
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	fakeDriver.mutex.Lock()
+	defer fakeDriver.mutex.Unlock()
+	database := s.conn.database
+	switch {
+	case strings.HasPrefix(s.query, "SELECT version FROM schema_migrations"):
+		rows := &fakeRows{column: "version"}
+		for _, version := range database.versions {
+			rows.values = append(rows.values, version)
+		}
+		return rows, nil
+	case strings.HasPrefix(s.query, "SELECT COUNT(*) FROM data_imports"):
+		count := int64(0)
+		if database.imports[args[0].(string)] {
+			count = 1
+		}
+		return &fakeRows{column: "count", values: []driver.Value{count}}, nil
+	case strings.HasPrefix(s.query, "SELECT id FROM "):
+		rows := &fakeRows{column: "id"}
+		for _, id := range database.tables[strings.TrimPrefix(s.query, "SELECT id FROM ")] {
+			rows.values = append(rows.values, id)
+		}
+		return rows, nil
+	case strings.HasPrefix(s.query, "SELECT COUNT(*) FROM "):
+		count := int64(len(database.tables[strings.TrimPrefix(s.query, "SELECT COUNT(*) FROM ")]))
+		return &fakeRows{column: "count", values: []driver.Value{count}}, nil
+	}
+	return nil, errors.New("unexpected query " + s.query)
+}
+
+type fakeRows struct {
+	column string
+	values []driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return []string{r.column} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+func TestMigrations(t *testing.T) {
+	migrations, err := Migrations()
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 4)
+	assert.Equal(t, 1, migrations[0].Version)
+	assert.Equal(t, "world", migrations[0].Name)
+	assert.Equal(t, "grants_and_layout", migrations[1].Name)
+	assert.Equal(t, "action_data", migrations[2].Name)
+	assert.Equal(t, "action_origin", migrations[3].Name)
+
+	statements := splitStatements(migrations[0].SQL)
+	assert.Len(t, statements, 4)
+	assert.True(t, strings.HasPrefix(statements[0], "CREATE TABLE robots ("))
+	assert.True(t, strings.HasSuffix(statements[3], ")"))
+}
+
+func TestMigratorUp(t *testing.T) {
+	db, database := fakeDB(t)
+	migrator, err := NewMigrator(db, "fakesql")
+	assert.NoError(t, err)
+	ctx := context.Background()
+
+	// A failing migration is rolled back and stops the later ones
+	database.failOn = "CREATE TABLE grants"
+	applied, err := migrator.Up(ctx)
+	assert.ErrorContains(t, err, "migration 2_grants_and_layout")
+	assert.Len(t, applied, 1)
+	assert.Equal(t, []int64{1}, database.versions)
+	assert.Empty(t, database.executed("CREATE TABLE map_layout"))
+
+	database.failOn = ""
+	applied, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, applied, 3)
+	assert.Equal(t, 2, applied[0].Version)
+	assert.Equal(t, []int64{1, 2, 3, 4}, database.versions)
+
+	applied, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+	assert.Len(t, database.executed("CREATE TABLE robots"), 1)
+}
+
+func TestMigrateImportsSnapshotOnce(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	storage.GrantPermissions("robot1", "bob", []string{PermMove})
+	_, err := storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Inventory = append(robot.Inventory, "item1")
+		return nil
+	})
+	assert.NoError(t, err)
+	storage.RemoveItem("item1")
+	snapshot := storage.Snapshot()
+
+	file := filepath.Join(t.TempDir(), "world.json")
+	data, _ := json.Marshal(snapshot)
+	assert.NoError(t, os.WriteFile(file, data, 0o600))
+
+	_, database := fakeDB(t)
+	config := DefaultConfig()
+	config.DatabaseDriver = "fakesql"
+	config.DatabaseURL = t.Name()
+	config.MigrateImport = file
+	assert.NoError(t, Migrate(context.Background(), config))
+
+	robots := database.executed("INSERT INTO robots")
+	assert.Len(t, robots, len(snapshot.Robots))
+	assert.Len(t, database.executed("INSERT INTO items"), len(snapshot.Items))
+	assert.Len(t, database.executed("INSERT INTO grants"), 1)
+	assert.Len(t, database.executed("INSERT INTO map_layout"), 1)
+	for _, item := range database.executed("INSERT INTO items") {
+		if item.args[0] == "item1" {
+			assert.Equal(t, "robot1", item.args[6])
+			assert.Equal(t, int64(0), item.args[7])
+		} else {
+			assert.Nil(t, item.args[6])
+		}
+	}
+	assert.True(t, database.imports["world.json"])
+
+	// Restarting does not import the snapshot again
+	assert.NoError(t, Migrate(context.Background(), config))
+	assert.Len(t, database.executed("INSERT INTO robots"), len(robots))
+	assert.Len(t, database.executed("CREATE TABLE robots"), 1)
+}
+
+func TestMigrateOnlyNeedsDatabase(t *testing.T) {
+	config := DefaultConfig()
+	assert.NoError(t, Migrate(context.Background(), config))
+	config.Migrate = MigrateOnly
+	assert.Error(t, Migrate(context.Background(), config))
+}
+
+func TestBindParams(t *testing.T) {
+	query := "INSERT INTO grants (robot_id, grantee, permission) VALUES (?, ?, ?)"
+	assert.Equal(t, query, bindParams("sqlite3", query))
+	assert.Equal(t, "INSERT INTO grants (robot_id, grantee, permission) VALUES ($1, $2, $3)", bindParams("pgx", query))
+}
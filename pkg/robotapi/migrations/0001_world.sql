@@ -0,0 +1,50 @@
+-- Robots, items, actions and hazards of the world
+CREATE TABLE robots (
+    id               VARCHAR(64) PRIMARY KEY,
+    name             VARCHAR(255) NOT NULL DEFAULT '',
+    owner            VARCHAR(255) NOT NULL DEFAULT '',
+    class            VARCHAR(32) NOT NULL DEFAULT '',
+    direction        VARCHAR(16) NOT NULL,
+    x                INTEGER NOT NULL,
+    y                INTEGER NOT NULL,
+    z                INTEGER NOT NULL DEFAULT 0,
+    energy           INTEGER NOT NULL,
+    armor            INTEGER NOT NULL DEFAULT 0,
+    sensor           VARCHAR(32) NOT NULL DEFAULT '',
+    battery_cycles   DOUBLE PRECISION NOT NULL DEFAULT 0,
+    battery_capacity INTEGER NOT NULL DEFAULT 0,
+    maintenance      BOOLEAN NOT NULL DEFAULT FALSE,
+    firmware         VARCHAR(32) NOT NULL DEFAULT '',
+    extra            TEXT NOT NULL -- JSON: tags, effects and continuous motion
+);
+
+CREATE TABLE items (
+    id             VARCHAR(64) PRIMARY KEY,
+    type           VARCHAR(64) NOT NULL,
+    weight         INTEGER NOT NULL,
+    x              INTEGER NOT NULL,
+    y              INTEGER NOT NULL,
+    z              INTEGER NOT NULL DEFAULT 0,
+    carried_by     VARCHAR(64) REFERENCES robots (id),
+    inventory_slot INTEGER
+);
+
+CREATE TABLE robot_actions (
+    robot_id   VARCHAR(64) NOT NULL REFERENCES robots (id),
+    seq        INTEGER NOT NULL,
+    type       VARCHAR(64) NOT NULL,
+    details    TEXT NOT NULL,
+    command_id VARCHAR(64) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (robot_id, seq)
+);
+
+CREATE TABLE hazards (
+    id              INTEGER PRIMARY KEY,
+    type            VARCHAR(32) NOT NULL,
+    x               INTEGER NOT NULL,
+    y               INTEGER NOT NULL,
+    z               INTEGER NOT NULL DEFAULT 0,
+    radius          INTEGER NOT NULL,
+    damage_per_tick INTEGER NOT NULL
+);
@@ -0,0 +1,17 @@
+-- Permission grants, the map layout and the record of imported snapshots
+CREATE TABLE grants (
+    robot_id   VARCHAR(64) NOT NULL REFERENCES robots (id),
+    grantee    VARCHAR(255) NOT NULL,
+    permission VARCHAR(32) NOT NULL,
+    PRIMARY KEY (robot_id, grantee, permission)
+);
+
+CREATE TABLE map_layout (
+    id     INTEGER PRIMARY KEY,
+    layout TEXT NOT NULL -- JSON: bounds, obstacles, spawn points and floor links
+);
+
+CREATE TABLE data_imports (
+    name        VARCHAR(255) PRIMARY KEY,
+    imported_at TIMESTAMP NOT NULL
+);
@@ -0,0 +1,3 @@
+-- Structured details of the actions next to their text: JSON, empty if an
+-- action has none
+ALTER TABLE robot_actions ADD COLUMN data TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,4 @@
+-- Who caused an action, so histories double as audit trails
+ALTER TABLE robot_actions ADD COLUMN actor VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE robot_actions ADD COLUMN client_ip VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE robot_actions ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,206 @@
+package robotapi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Mirror limits
+const (
+	mirrorQueueSize = 1000            // requests waiting to be mirrored; more are dropped
+	mirrorWorkers   = 4               // requests mirrored at the same time
+	mirrorTimeout   = 5 * time.Second // per mirrored request
+)
+
+// mirrorSkippedHeaders are not copied to mirrored requests: hop-by-hop
+// headers and those the HTTP client sets itself
+var mirrorSkippedHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authorization", "Proxy-Connection", "Te", "Trailer",
+	"Transfer-Encoding", "Upgrade", "Content-Length", "Accept-Encoding",
+}
+
+// mirroredRequest is a request waiting to be mirrored with the status the
+// server answered it with
+type mirroredRequest struct {
+	method string
+	uri    string
+	header http.Header
+	body   []byte
+	status int
+}
+
+// MirrorStats counts the mirrored requests
+type MirrorStats struct {
+	Target     string `json:"target"`
+	Mirrored   int64  `json:"mirrored"`   // answered by the target
+	Mismatched int64  `json:"mismatched"` // answered with another status than here
+	Failed     int64  `json:"failed"`     // not answered by the target
+	Dropped    int64  `json:"dropped"`    // not mirrored because the queue was full
+	Queued     int    `json:"queued"`
+}
+
+// Mirror copies mutating requests to a secondary deployment, like a new
+// storage backend or a refactored version, so it can be validated against
+// real traffic. Mirroring is fire-and-forget: requests are queued after they
+// were answered and sent by background workers, and a slow or failing
+// target never delays or fails a request here. The target's responses are
+// discarded; only a different status is logged.
+type Mirror struct {
+	target string
+	client *http.Client
+	queue  chan mirroredRequest
+	stats  MirrorStats
+	mutex  sync.Mutex
+}
+
+// NewMirror mirrors to the base URL of the target; request paths and queries
+// are appended as they are
+func NewMirror(target string) (*Mirror, error) {
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return nil, fmt.Errorf("invalid mirror URL %q", target)
+	}
+	target = strings.TrimSuffix(target, "/")
+	return &Mirror{
+		target: target,
+		client: &http.Client{Timeout: mirrorTimeout},
+		queue:  make(chan mirroredRequest, mirrorQueueSize),
+		stats:  MirrorStats{Target: target},
+	}, nil
+}
+
+// Capture is the middleware queueing the mutating requests. Requests that
+// were mirrored themselves are not mirrored again, so two deployments can
+// mirror to each other.
+func (m *Mirror) Capture() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if c.GetHeader("X-Mirrored") != "" {
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			if body, err = readBody(c); errors.Is(err, errBodyTooLarge) {
+				bodyTooLarge(c)
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		header := c.Request.Header.Clone()
+
+		c.Next()
+
+		for _, name := range mirrorSkippedHeaders {
+			header.Del(name)
+		}
+		header.Set("X-Mirrored", "true")
+		if id := c.Writer.Header().Get("X-Command-ID"); id != "" {
+			header.Set("X-Mirrored-Command-ID", id)
+		}
+		request := mirroredRequest{
+			method: c.Request.Method,
+			uri:    c.Request.URL.RequestURI(),
+			header: header,
+			body:   body,
+			status: c.Writer.Status(),
+		}
+		select {
+		case m.queue <- request:
+		default:
+			m.count(func(stats *MirrorStats) { stats.Dropped++ })
+		}
+	}
+}
+
+// Run mirrors the queued requests until ctx is done
+func (m *Mirror) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < mirrorWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case request := <-m.queue:
+					m.send(ctx, request)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// send mirrors one request and compares the target's status
+func (m *Mirror) send(ctx context.Context, request mirroredRequest) {
+	req, err := http.NewRequestWithContext(ctx, request.method, m.target+request.uri, bytes.NewReader(request.body))
+	if err != nil {
+		m.count(func(stats *MirrorStats) { stats.Failed++ })
+		return
+	}
+	req.Header = request.header
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		m.count(func(stats *MirrorStats) { stats.Failed++ })
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	mismatched := resp.StatusCode != request.status
+	m.count(func(stats *MirrorStats) {
+		stats.Mirrored++
+		if mismatched {
+			stats.Mismatched++
+		}
+	})
+	if mismatched {
+		log.Printf("Mirrored %s %s answered %d, here %d", request.method, request.uri, resp.StatusCode, request.status)
+	}
+}
+
+// count updates the statistics
+func (m *Mirror) count(update func(stats *MirrorStats)) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	update(&m.stats)
+}
+
+// Stats returns the statistics of the mirrored requests
+func (m *Mirror) Stats() MirrorStats {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	stats := m.stats
+	stats.Queued = len(m.queue)
+	return stats
+}
+
+// GetMirrorStats reports how many requests were mirrored and how many of
+// them the target answered differently
+func (h *RobotHandler) GetMirrorStats(c *gin.Context) {
+	if h.mirror == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Request mirroring is disabled"})
+		return
+	}
+	c.JSON(http.StatusOK, h.mirror.Stats())
+}
@@ -0,0 +1,153 @@
+package robotapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receivedRequest is a request the mirror target got
+type receivedRequest struct {
+	method string
+	uri    string
+	header http.Header
+	body   string
+}
+
+// startMirrorTarget starts a target answering every request with status
+func startMirrorTarget(t *testing.T, status int) (string, <-chan receivedRequest) {
+	received := make(chan receivedRequest, 10)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedRequest{method: r.Method, uri: r.URL.RequestURI(), header: r.Header, body: string(body)}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(target.Close)
+	return target.URL, received
+}
+
+func setupMirrorServer(t *testing.T, target string) *Server {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	config.MirrorURL = target + "/"
+	server, err := New(config)
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go server.mirror.Run(ctx)
+	return server
+}
+
+func receive(t *testing.T, received <-chan receivedRequest) receivedRequest {
+	select {
+	case request := <-received:
+		return request
+	case <-time.After(5 * time.Second):
+		t.Fatal("no request mirrored")
+		return receivedRequest{}
+	}
+}
+
+func TestMirror(t *testing.T) {
+	target, received := startMirrorTarget(t, http.StatusOK)
+	server := setupMirrorServer(t, target)
+	router := server.Router()
+
+	// Reads are not mirrored
+	assert.Equal(t, http.StatusOK, send(router, "GET", "/robot/robot1/status", "").Code)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/robot/robot1/move?dry_run=false", strings.NewReader(`{"direction": "up"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "key1")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	request := receive(t, received)
+	assert.Equal(t, "POST", request.method)
+	assert.Equal(t, "/robot/robot1/move?dry_run=false", request.uri)
+	assert.Equal(t, `{"direction": "up"}`, request.body)
+	assert.Equal(t, "key1", request.header.Get("X-API-Key"))
+	assert.Equal(t, "true", request.header.Get("X-Mirrored"))
+	assert.Equal(t, w.Header().Get("X-Command-ID"), request.header.Get("X-Mirrored-Command-ID"))
+
+	assert.Eventually(t, func() bool { return server.mirror.Stats().Mirrored == 1 }, 5*time.Second, 10*time.Millisecond)
+	w = adminRequest(router, "GET", "/admin/mirror", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"target": "`+target+`", "mirrored": 1, "mismatched": 0, "failed": 0, "dropped": 0, "queued": 0}`, w.Body.String())
+}
+
+func TestMirrorCountsMismatches(t *testing.T) {
+	target, received := startMirrorTarget(t, http.StatusNotFound)
+	server := setupMirrorServer(t, target)
+	router := server.Router()
+
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	receive(t, received)
+	assert.Eventually(t, func() bool { return server.mirror.Stats().Mismatched == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// Requests that were mirrored themselves are not mirrored again
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/robot/robot1/move", strings.NewReader(`{"direction": "up"}`))
+	req.Header.Set("X-Mirrored", "true")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	select {
+	case <-received:
+		t.Fatal("a mirrored request was mirrored again")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestMirrorDropsWhenFull(t *testing.T) {
+	mirror, err := NewMirror("http://localhost:1")
+	require.NoError(t, err)
+	mirror.queue = make(chan mirroredRequest, 1) // no workers run
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(mirror.Capture())
+	router.POST("/ping", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusNoContent, send(router, "POST", "/ping", "").Code)
+	}
+	stats := mirror.Stats()
+	assert.Equal(t, 1, stats.Queued)
+	assert.Equal(t, int64(2), stats.Dropped)
+}
+
+func TestMirrorLimitsTheBody(t *testing.T) {
+	mirror, err := NewMirror("http://localhost:1")
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(LimitBody(16), mirror.Capture())
+	router.POST("/ping", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	assert.Equal(t, http.StatusNoContent, send(router, "POST", "/ping", `{"short": 1}`).Code)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, send(router, "POST", "/ping", `{"much": "longer than the limit"}`).Code)
+	assert.Equal(t, 1, mirror.Stats().Queued)
+}
+
+func TestMirrorConfig(t *testing.T) {
+	for _, target := range []string{"localhost:8080", "ftp://example.com", "http://"} {
+		_, err := NewMirror(target)
+		assert.Error(t, err, target)
+	}
+
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	server, err := New(config)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, adminRequest(server.Router(), "GET", "/admin/mirror", "").Code)
+}
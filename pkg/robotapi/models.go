@@ -0,0 +1,310 @@
+package robotapi
+
+import (
+	"slices"
+	"time"
+)
+
+// Position represents the robot's coordinates. Z is the floor; it stays 0
+// unless multi-floor maps are enabled.
+type Position struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+	Z int `json:"z,omitempty"`
+}
+
+// DistanceTo returns the Manhattan distance between two positions, counting
+// every floor in between as one step
+func (p Position) DistanceTo(other Position) int {
+	dx := p.X - other.X
+	if dx < 0 {
+		dx = -dx
+	}
+	dy := p.Y - other.Y
+	if dy < 0 {
+		dy = -dy
+	}
+	dz := p.Z - other.Z
+	if dz < 0 {
+		dz = -dz
+	}
+	return dx + dy + dz
+}
+
+// Add returns the position moved by the given offset
+func (p Position) Add(delta Position) Position {
+	return Position{X: p.X + delta.X, Y: p.Y + delta.Y, Z: p.Z + delta.Z}
+}
+
+// Item represents an item in the world
+type Item struct {
+	ID       string   `json:"id"`
+	Type     string   `json:"type"`
+	Weight   int      `json:"weight"`
+	Position Position `json:"position"`
+
+	carried bool // true while the item is in a robot's inventory
+}
+
+// Action represents an activity performed by a robot
+type Action struct {
+	Type      string      `json:"type"`
+	Timestamp time.Time   `json:"timestamp"`
+	Details   string      `json:"details"`
+	Data      interface{} `json:"data,omitempty"`       // structured details, depending on the type
+	CommandID string      `json:"command_id,omitempty"` // request that caused the action
+
+	// Who sent the request is audit data for admins, see AuditRecord
+	Actor     string `json:"-"` // authenticated caller of the request
+	ClientIP  string `json:"-"`
+	UserAgent string `json:"-"`
+}
+
+// Robot classes
+const (
+	ClassStandard = "standard"
+	ClassSolar    = "solar"
+)
+
+// MaxEnergy is the capacity of a new battery
+const MaxEnergy = 100
+
+// Robot represents a robot in the system
+type Robot struct {
+	ID        string         `json:"id"`
+	Name      string         `json:"name,omitempty"`
+	Owner     string         `json:"owner,omitempty"` // principal that created the robot
+	Tags      []string       `json:"tags,omitempty"`
+	Position  Position       `json:"position"`
+	Direction string         `json:"direction"`       // "north", "east", "south", "west"
+	Class     string         `json:"class,omitempty"` // "standard" or "solar"
+	Energy    int            `json:"energy"`
+	Armor     int            `json:"armor,omitempty"`  // armor class, used by the "armor" combat rules
+	Sensor    string         `json:"sensor,omitempty"` // sensor quality, perfect if empty
+	Inventory []string       `json:"inventory"`
+	Actions   []Action       `json:"actions"`
+	Effects   []StatusEffect `json:"effects,omitempty"`
+	Exact     *Vector        `json:"exact_position,omitempty"`     // continuous mode only
+	Velocity  *Vector        `json:"velocity,omitempty"`           // cells per tick, continuous mode only
+	Commanded *Vector        `json:"commanded_velocity,omitempty"` // velocity the robot accelerates towards
+
+	BatteryCycles   float64 `json:"battery_cycles,omitempty"`   // full charges since the last battery replacement
+	BatteryCapacity int     `json:"battery_capacity,omitempty"` // worn capacity, MaxEnergy if 0
+	EnergyFraction  int     `json:"energy_fraction,omitempty"`  // thousandths of a unit beyond Energy, see EnergyCarry
+
+	Maintenance    bool   `json:"maintenance,omitempty"`    // being serviced: cannot act and ignores attacks
+	Decommissioned bool   `json:"decommissioned,omitempty"` // frozen until it is archived, see DecommissionRobot
+	Firmware       string `json:"firmware,omitempty"`       // installed firmware version, empty for simulated robots
+
+	movedThisTick bool    // used to throttle slowed robots
+	heading       float64 // degrees clockwise from north, valid once hasHeading is set
+	hasHeading    bool
+}
+
+// clone returns a deep copy of the robot, so callers outside the storage
+// never share state with the simulation
+func (r *Robot) clone() *Robot {
+	copied := *r
+	copied.Tags = slices.Clone(r.Tags)
+	copied.Inventory = slices.Clone(r.Inventory)
+	copied.Actions = slices.Clone(r.Actions)
+	copied.Effects = slices.Clone(r.Effects)
+	copied.Exact = cloneVector(r.Exact)
+	copied.Velocity = cloneVector(r.Velocity)
+	copied.Commanded = cloneVector(r.Commanded)
+	return &copied
+}
+
+// CreateRobotRequest is the payload for creating a robot. The ID is optional;
+// the server generates one if it is left empty.
+type CreateRobotRequest struct {
+	ID       string    `json:"id,omitempty"`
+	Name     string    `json:"name,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+	Position *Position `json:"position,omitempty"`
+	Class    string    `json:"class,omitempty"`
+	Armor    int       `json:"armor,omitempty"`
+	Sensor   string    `json:"sensor,omitempty"`
+	Firmware string    `json:"firmware,omitempty"`
+}
+
+// PermissionGrantRequest is the payload for delegating control over a robot
+type PermissionGrantRequest struct {
+	Grantee     string   `json:"grantee"`
+	Permissions []string `json:"permissions"`
+}
+
+// SessionRequest is the payload for issuing a play token. The lifetime
+// defaults to and is capped at the configured session TTL.
+type SessionRequest struct {
+	RobotID    string `json:"robot_id"`
+	TTLSeconds int    `json:"ttl_seconds,omitempty"`
+}
+
+// MoveRequest is the payload for the move endpoint
+type MoveRequest struct {
+	Direction string `json:"direction"` // "up", "down", "left", "right"
+}
+
+// StateUpdateRequest is the payload for the state update endpoint
+type StateUpdateRequest struct {
+	Energy   *int      `json:"energy,omitempty"`
+	Position *Position `json:"position,omitempty"`
+	Firmware *string   `json:"firmware,omitempty"` // record the firmware after an OTA flash
+}
+
+// Link represents a HATEOAS link
+type Link struct {
+	Rel  string `json:"rel"`
+	Href string `json:"href"`
+}
+
+// PageInfo contains pagination information
+type PageInfo struct {
+	Number        int  `json:"number"`
+	Size          int  `json:"size"`
+	TotalElements int  `json:"totalElements"`
+	TotalPages    int  `json:"totalPages"`
+	HasNext       bool `json:"hasNext"`
+	HasPrevious   bool `json:"hasPrevious"`
+}
+
+// ActionWithLinks represents an action with HATEOAS links
+type ActionWithLinks struct {
+	Action
+	Links []Link `json:"links"`
+}
+
+// PaginatedActions represents a paginated list of actions with navigation links
+type PaginatedActions struct {
+	Page    PageInfo          `json:"page"`
+	Actions []ActionWithLinks `json:"actions"`
+	Links   []Link            `json:"links"`
+}
+
+// ClockSpeedRequest is the payload for changing the world clock speed
+type ClockSpeedRequest struct {
+	Speed *float64 `json:"speed"`
+}
+
+// RobotSighting is a robot detected by a scan or shown on the world map
+type RobotSighting struct {
+	ID       string   `json:"id"`
+	Position Position `json:"position"`
+	Distance int      `json:"distance,omitempty"`
+
+	Maintenance bool `json:"maintenance,omitempty"`
+
+	// Set by noisy sensors only
+	Estimate    *Vector `json:"estimated_position,omitempty"`
+	Uncertainty float64 `json:"uncertainty,omitempty"` // standard deviation in cells
+}
+
+// ScanResult is the response of the scan endpoint
+type ScanResult struct {
+	Position Position        `json:"position"`
+	Radius   int             `json:"radius"`
+	Sensor   string          `json:"sensor,omitempty"` // set if sensor noise is enabled
+	Robots   []RobotSighting `json:"robots"`
+	Hazards  []Hazard        `json:"hazards"`
+}
+
+// WorldMap is a snapshot of everything located on the map
+type WorldMap struct {
+	MapLayout
+	Robots  []RobotSighting `json:"robots"`
+	Hazards []Hazard        `json:"hazards"`
+}
+
+// RobotState is the part of a robot that world sync clients track
+type RobotState struct {
+	ID        string         `json:"id"`
+	Position  Position       `json:"position"`
+	Direction string         `json:"direction"`
+	Energy    int            `json:"energy"`
+	Effects   []StatusEffect `json:"effects,omitempty"`
+	Exact     *Vector        `json:"exact_position,omitempty"`
+	Velocity  *Vector        `json:"velocity,omitempty"`
+	Commanded *Vector        `json:"commanded_velocity,omitempty"`
+	Heading   *float64       `json:"heading,omitempty"` // continuous mode only
+
+	Maintenance bool `json:"maintenance,omitempty"`
+}
+
+// WorldChanges lists everything that changed after a world version. Hazards
+// have no IDs, so they are sent as a whole whenever one of them changed; the
+// same goes for the map layout.
+type WorldChanges struct {
+	Version       uint64       `json:"version"`
+	Robots        []RobotState `json:"robots"`
+	Items         []Item       `json:"items"`
+	RemovedItems  []string     `json:"removed_items"`            // picked up or deleted since the given version
+	RemovedRobots []string     `json:"removed_robots,omitempty"` // moved to another tenant or missing from a restored snapshot
+	Hazards       []Hazard     `json:"hazards,omitempty"`
+	Layout        *MapLayout   `json:"layout,omitempty"` // sent whenever the map layout changed
+}
+
+// PaginatedItems represents a filtered, sorted page of world items
+type PaginatedItems struct {
+	AvailableItems []string `json:"available_items"` // IDs of the items on this page
+	TotalCount     int      `json:"total_count"`
+	Page           PageInfo `json:"page"`
+	Items          []Item   `json:"items"`
+	Links          []Link   `json:"links"`
+}
+
+// InventoryItem is an item a robot carries
+type InventoryItem struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Weight int    `json:"weight"`
+	Slot   int    `json:"slot"` // position in the inventory, starting at 0
+	Links  []Link `json:"links"`
+}
+
+// InventorySummary aggregates a robot's inventory
+type InventorySummary struct {
+	TotalWeight int            `json:"total_weight"`
+	SlotsUsed   int            `json:"slots_used"`
+	Types       map[string]int `json:"types"` // number of items per type
+}
+
+// Inventory represents the items a robot carries
+type Inventory struct {
+	RobotID string           `json:"robot_id"`
+	Items   []InventoryItem  `json:"items"`
+	Summary InventorySummary `json:"summary"`
+	Links   []Link           `json:"links"`
+}
+
+// RobotSearchResult is a robot matching a search query
+type RobotSearchResult struct {
+	ID    string   `json:"id"`
+	Name  string   `json:"name,omitempty"`
+	Tags  []string `json:"tags,omitempty"`
+	Score int      `json:"score"`
+}
+
+// ItemSearchResult is an item matching a search query
+type ItemSearchResult struct {
+	Item
+	Score int `json:"score"`
+}
+
+// ActionSearchResult is an action history entry matching a search query
+type ActionSearchResult struct {
+	Action
+	RobotID string `json:"robot_id"`
+	Number  int    `json:"number"` // 1-based position in the robot's history
+	Score   int    `json:"score"`
+}
+
+// SearchResults groups search hits by kind
+type SearchResults struct {
+	Query   string               `json:"query"`
+	Robots  []RobotSearchResult  `json:"robots"`
+	Items   []ItemSearchResult   `json:"items"`
+	Actions []ActionSearchResult `json:"actions"`
+
+	ActionsPage PageInfo `json:"actions_page"`
+}
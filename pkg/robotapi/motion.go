@@ -0,0 +1,271 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// motionStep is the longest distance a robot travels between two collision checks
+const motionStep = 0.25
+
+// turnCost is the energy a robot at top speed pays for a 90 degree turn
+const turnCost = 2
+
+// MotionLimits are the driving characteristics of a robot class
+type MotionLimits struct {
+	MaxSpeed     float64 `json:"max_speed"`    // cells per tick
+	Acceleration float64 `json:"acceleration"` // change of velocity per tick
+}
+
+// motionLimits per robot class. Solar robots are lighter but weaker.
+var motionLimits = map[string]MotionLimits{
+	ClassStandard: {MaxSpeed: 2, Acceleration: 0.5},
+	ClassSolar:    {MaxSpeed: 1, Acceleration: 0.25},
+}
+
+// limitsOf returns the motion limits of the robot's class
+func limitsOf(robot *Robot) MotionLimits {
+	if limits, ok := motionLimits[robot.Class]; ok {
+		return limits
+	}
+	return motionLimits[ClassStandard]
+}
+
+// Vector is a point or velocity in continuous coordinates. Cell (x, y) of
+// the grid covers the square from x-0.5 to x+0.5 and y-0.5 to y+0.5.
+type Vector struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Length returns the length of the vector
+func (v Vector) Length() float64 {
+	return math.Hypot(v.X, v.Y)
+}
+
+// Scale returns the vector multiplied by the factor
+func (v Vector) Scale(factor float64) Vector {
+	return Vector{X: v.X * factor, Y: v.Y * factor}
+}
+
+// Heading returns the direction of the vector in degrees clockwise from
+// north (positive Y)
+func (v Vector) Heading() float64 {
+	heading := math.Atan2(v.X, v.Y) * 180 / math.Pi
+	if heading < 0 {
+		heading += 360
+	}
+	return heading
+}
+
+// approach moves the vector towards the target by at most the given step
+func (v Vector) approach(target Vector, step float64) Vector {
+	delta := Vector{X: target.X - v.X, Y: target.Y - v.Y}
+	if delta.Length() <= step {
+		return target
+	}
+	delta = delta.Scale(step / delta.Length())
+	return Vector{X: v.X + delta.X, Y: v.Y + delta.Y}
+}
+
+// cell returns the grid cell containing the point on the given floor
+func (v Vector) cell(z int) Position {
+	return Position{X: int(math.Round(v.X)), Y: int(math.Round(v.Y)), Z: z}
+}
+
+// cloneVector copies an optional vector
+func cloneVector(v *Vector) *Vector {
+	if v == nil {
+		return nil
+	}
+	copied := *v
+	return &copied
+}
+
+// directionHeadings gives the heading of a robot that has not driven yet
+var directionHeadings = map[string]float64{"north": 0, "east": 90, "south": 180, "west": 270}
+
+// angleBetween returns the smaller angle between two headings in degrees
+func angleBetween(a, b float64) float64 {
+	angle := math.Mod(math.Abs(a-b), 360)
+	if angle > 180 {
+		angle = 360 - angle
+	}
+	return angle
+}
+
+// VelocityRequest is the payload for the velocity endpoint
+type VelocityRequest struct {
+	X *float64 `json:"x" binding:"required"`
+	Y *float64 `json:"y" binding:"required"`
+}
+
+// exactPosition returns the continuous position of the robot. Robots that
+// were placed or moved on the grid stand in the center of their cell.
+func (r *Robot) exactPosition() Vector {
+	if r.Exact != nil && r.Exact.cell(r.Position.Z) == r.Position {
+		return *r.Exact
+	}
+	return Vector{X: float64(r.Position.X), Y: float64(r.Position.Y)}
+}
+
+// currentHeading returns the direction the robot last drove in, or the
+// direction it faces if it never drove
+func (r *Robot) currentHeading() float64 {
+	if r.hasHeading {
+		return r.heading
+	}
+	return directionHeadings[r.Direction]
+}
+
+// stop ends a continuous movement at once and records why
+func (r *Robot) stop(reason string) {
+	r.Velocity = nil
+	r.Commanded = nil
+	appendAction(r, "stop", "Stopped: "+reason, gin.H{"reason": reason})
+}
+
+// TickMotion moves every driving robot by one tick. The velocity follows the
+// commanded velocity within the acceleration limit of the robot's class.
+// Entering a new cell costs the given energy like a move on the grid; robots
+// stop in front of blocked cells and when their energy runs out. Stunned
+// robots stand still and slowed robots travel at half speed. It is
+// registered as a simulation system in continuous mode.
+func (s *RobotStorage) TickMotion(cost int) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	layout := s.layout()
+	for _, robot := range s.robots {
+		if (robot.Velocity == nil && robot.Commanded == nil) || robot.CanPerform("move") != nil {
+			continue
+		}
+
+		var velocity, commanded Vector
+		if robot.Velocity != nil {
+			velocity = *robot.Velocity
+		}
+		if robot.Commanded != nil {
+			commanded = *robot.Commanded
+		}
+		velocity = velocity.approach(commanded, limitsOf(robot).Acceleration)
+		robot.Velocity = &velocity
+		if velocity == (Vector{}) {
+			robot.Velocity = nil
+			robot.Commanded = nil
+			s.robotChanged(robot.ID)
+			continue
+		}
+		robot.heading, robot.hasHeading = velocity.Heading(), true
+
+		travel := velocity
+		if robot.HasEffect(EffectSlowed) {
+			travel = travel.Scale(0.5)
+		}
+		position := robot.exactPosition()
+		before := robot.Energy
+		steps := int(math.Ceil(travel.Length() / motionStep))
+		for i := 0; i < steps; i++ {
+			next := Vector{X: position.X + travel.X/float64(steps), Y: position.Y + travel.Y/float64(steps)}
+			cell := next.cell(robot.Position.Z)
+			if wrapped := layout.Wrapped(cell); wrapped != cell {
+				// Leaving a torus on one edge continues on the opposite one
+				next = Vector{X: next.X + float64(wrapped.X-cell.X), Y: next.Y + float64(wrapped.Y-cell.Y)}
+				cell = wrapped
+			}
+			if cell != robot.Position {
+				if err := layout.CanMove(robot.Position, cell); err != nil {
+					robot.stop("the way is blocked")
+					break
+				}
+				cost := layout.MoveCost(cell, cost)
+				if robot.Energy < cost {
+					robot.stop("not enough energy to move")
+					break
+				}
+				robot.Energy -= cost
+				robot.Position = cell
+			}
+			position = next
+		}
+
+		s.bookEnergy(robot.ID, EnergyMotion, before, robot.Energy)
+
+		robot.Exact = &position
+		s.reindexRobot(robot)
+		s.robotChanged(robot.ID)
+	}
+}
+
+// SetVelocity commands the velocity a robot should drive at; a zero velocity
+// brakes it to a halt. Commands faster than the robot's class allows are
+// clamped to its top speed. Changing the direction while driving costs
+// energy, the more the sharper the turn and the faster the robot. Only
+// available in continuous mode.
+func (h *RobotHandler) SetVelocity(c *gin.Context) {
+	if !h.continuous {
+		c.JSON(http.StatusConflict, gin.H{"error": "Velocity control needs the continuous world mode"})
+		return
+	}
+
+	var velocityReq VelocityRequest
+	if err := bindJSON(c, &velocityReq); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+
+	var commanded Vector
+	var cost int
+	robot, err := h.world(c).UpdateRobot(c.Param("id"), func(robot *Robot) error {
+		commanded = Vector{X: *velocityReq.X, Y: *velocityReq.Y}
+		limits := limitsOf(robot)
+		if commanded == (Vector{}) {
+			if robot.Commanded != nil {
+				robot.Commanded = nil
+				appendCommandAction(robot, requestOrigin(c), "stop", "Braking", gin.H{"reason": "braking"})
+			}
+			return nil
+		}
+		if speed := commanded.Length(); speed > limits.MaxSpeed {
+			commanded = commanded.Scale(limits.MaxSpeed / speed)
+		}
+		if err := robot.CanPerform("move"); err != nil {
+			return errActionNotAllowed(err)
+		}
+
+		if robot.Velocity != nil {
+			angle := angleBetween(robot.currentHeading(), commanded.Heading())
+			cost = int(math.Ceil(turnCost * angle / 90 * robot.Velocity.Length() / limits.MaxSpeed))
+		}
+		if robot.Energy == 0 || robot.Energy < cost {
+			return errors.New("Not enough energy to move")
+		}
+
+		robot.Energy -= cost
+		exact := robot.exactPosition()
+		robot.Exact = &exact
+		robot.Commanded = &commanded
+		appendCommandAction(robot, requestOrigin(c), "velocity", fmt.Sprintf("Set velocity to (%g,%g)", commanded.X, commanded.Y),
+			gin.H{"velocity": commanded, "energy_cost": cost})
+		return nil
+	})
+	if err != nil {
+		updateFailed(c, err)
+		return
+	}
+
+	h.bus(c).PublishCommand(commandID(c), "robot_velocity_changed", robot.ID, gin.H{"commanded_velocity": commanded})
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "Velocity set",
+		"position":           robot.Position,
+		"exact_position":     robot.exactPosition(),
+		"velocity":           robot.Velocity,
+		"commanded_velocity": commanded,
+		"turn_cost":          cost,
+		"energy":             robot.Energy,
+	})
+}
@@ -0,0 +1,124 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVelocityNeedsContinuousMode(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	handler := NewRobotHandler(storage)
+	router.POST("/robot/:id/velocity", handler.SetVelocity)
+
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot1/velocity", `{"x": 1, "y": 0}`).Code)
+}
+
+func TestContinuousMotion(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	handler := NewRobotHandler(storage)
+	handler.SetContinuous(true)
+	router.POST("/robot/:id/velocity", handler.SetVelocity)
+	router.GET("/robot/:id/status", handler.GetStatus)
+
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/robot/robot1/velocity", `{"x": 1}`).Code)
+	assert.Equal(t, http.StatusNotFound, send(router, "POST", "/robot/nobody/velocity", `{"x": 1, "y": 0}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/velocity", `{"x": -0.3, "y": 0}`).Code)
+
+	// -0.3 stays in the start cell, -0.6 crosses into the next one
+	storage.TickMotion(1)
+	robot, _ := storage.GetRobot("robot1")
+	assert.InDelta(t, -0.3, robot.exactPosition().X, 1e-9)
+	assert.Equal(t, Position{X: 0, Y: 0}, robot.Position)
+	assert.Equal(t, 100, robot.Energy)
+
+	storage.TickMotion(1)
+	robot, _ = storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: -1, Y: 0}, robot.Position)
+	assert.Equal(t, 99, robot.Energy)
+
+	var status map[string]interface{}
+	json.Unmarshal(send(router, "GET", "/robot/robot1/status", "").Body.Bytes(), &status)
+	assert.InDelta(t, -0.6, status["exact_position"].(map[string]interface{})["x"], 1e-9)
+
+	// The robot stops in front of an obstacle
+	assert.NoError(t, storage.PlaceObstacle(Position{X: -2, Y: 0}))
+	for i := 0; i < 5; i++ {
+		storage.TickMotion(1)
+	}
+	robot, _ = storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: -1, Y: 0}, robot.Position)
+	assert.Nil(t, robot.Velocity)
+	assert.Greater(t, robot.exactPosition().X, -1.5)
+
+	// Grid moves put the robot back into the center of its cell
+	w := send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	robot, _ = storage.GetRobot("robot1")
+	assert.Equal(t, Vector{X: -1, Y: 1}, robot.exactPosition())
+
+	// A zero velocity stops the robot
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/velocity", `{"x": 0, "y": -1}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/velocity", `{"x": 0, "y": 0}`).Code)
+	storage.TickMotion(1)
+	robot, _ = storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: -1, Y: 1}, robot.Position)
+}
+
+func TestMotionCannotTunnel(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	assert.NoError(t, storage.PlaceObstacle(Position{X: 1, Y: 0}))
+	storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Velocity = &Vector{X: 2}
+		robot.Commanded = &Vector{X: 2}
+		return nil
+	})
+
+	storage.TickMotion(1)
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 0, Y: 0}, robot.Position, "fast robots must not skip obstacles")
+}
+
+func TestMomentum(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	handler := NewRobotHandler(storage)
+	handler.SetContinuous(true)
+	router.POST("/robot/:id/velocity", handler.SetVelocity)
+
+	// Commands are clamped to the top speed of the class
+	var response map[string]interface{}
+	w := send(router, "POST", "/robot/robot2/velocity", `{"x": 3, "y": 0}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, map[string]interface{}{"x": 1.0, "y": 0.0}, response["commanded_velocity"])
+
+	// Solar robots accelerate slowly
+	storage.TickMotion(0)
+	robot, _ := storage.GetRobot("robot2")
+	assert.Equal(t, &Vector{X: 0.25}, robot.Velocity)
+	storage.TickMotion(0)
+	robot, _ = storage.GetRobot("robot2")
+	assert.Equal(t, &Vector{X: 0.5}, robot.Velocity)
+	assert.Equal(t, 90.0, robot.currentHeading())
+
+	// Turning at top speed costs energy, the sharper the turn the more
+	send(router, "POST", "/robot/robot1/velocity", `{"x": 2, "y": 0}`)
+	for i := 0; i < 4; i++ {
+		storage.TickMotion(0)
+	}
+	json.Unmarshal(send(router, "POST", "/robot/robot1/velocity", `{"x": 0, "y": 2}`).Body.Bytes(), &response)
+	assert.Equal(t, 2.0, response["turn_cost"])
+	json.Unmarshal(send(router, "POST", "/robot/robot1/velocity", `{"x": -2, "y": 0}`).Body.Bytes(), &response)
+	assert.Equal(t, 4.0, response["turn_cost"])
+	assert.Equal(t, 94.0, response["energy"])
+
+	// Braking is free but takes time
+	json.Unmarshal(send(router, "POST", "/robot/robot1/velocity", `{"x": 0, "y": 0}`).Body.Bytes(), &response)
+	assert.Equal(t, 0.0, response["turn_cost"])
+	storage.TickMotion(0)
+	robot, _ = storage.GetRobot("robot1")
+	assert.Equal(t, &Vector{X: 1.5}, robot.Velocity)
+}
@@ -0,0 +1,50 @@
+package robotapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+)
+
+// NewClientCertTLSConfig creates a TLS configuration that asks clients for
+// a certificate signed by one of the CAs in the PEM bundle. Clients without a
+// certificate can still connect and authenticate by other means.
+func NewClientCertTLSConfig(caFile string) (*tls.Config, error) {
+	bundle, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(bundle) {
+		return nil, errors.New("no certificates found in client CA bundle")
+	}
+
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.VerifyClientCertIfGiven,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// certificatePrincipal maps a verified client certificate to a principal.
+// The common name identifies the device; it is prefixed with "cert:" so it
+// never collides with API key names. It returns nil if the connection has no
+// verified client certificate.
+func certificatePrincipal(state *tls.ConnectionState) *Principal {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	commonName := state.VerifiedChains[0][0].Subject.CommonName
+	if commonName == "" {
+		return nil
+	}
+	return &Principal{
+		ID:       "cert:" + commonName,
+		Name:     commonName,
+		Provider: "certificate",
+		Roles:    []string{RoleUser},
+	}
+}
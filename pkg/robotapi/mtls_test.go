@@ -0,0 +1,78 @@
+package robotapi
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// withClientCert simulates a TLS connection with a verified client certificate
+func withClientCert(req *http.Request, commonName string) {
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+	req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+}
+
+func TestClientCertificateAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+
+	keys, _ := NewAPIKeyStore("")
+	auth := NewAuthenticator(keys, nil, NewSessionStore(0))
+	auth.EnableClientCertificates()
+
+	router := gin.New()
+	router.GET("/me", Authenticate(auth), handler.GetMe)
+	router.POST("/robots", Authenticate(auth), handler.CreateRobot)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/me", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/robots", bytes.NewBufferString(`{"id": "rover"}`))
+	req.Header.Set("Content-Type", "application/json")
+	withClientCert(req, "rover-7")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	robot, _ := storage.GetRobot("rover")
+	assert.Equal(t, "cert:rover-7", robot.Owner)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/me", nil)
+	withClientCert(req, "rover-7")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"cert:rover-7"`)
+	assert.Contains(t, w.Body.String(), `"provider":"certificate"`)
+}
+
+func TestCertificatePrincipalRequiresVerifiedChain(t *testing.T) {
+	assert.Nil(t, certificatePrincipal(nil))
+	assert.Nil(t, certificatePrincipal(&tls.ConnectionState{}))
+
+	unverified := &x509.Certificate{Subject: pkix.Name{CommonName: "rover"}}
+	assert.Nil(t, certificatePrincipal(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{unverified}}))
+}
+
+func TestNewClientCertTLSConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(path, []byte("not a certificate"), 0o600)
+
+	_, err := NewClientCertTLSConfig(path)
+	assert.Error(t, err)
+
+	_, err = NewClientCertTLSConfig(filepath.Join(t.TempDir(), "missing.pem"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,270 @@
+package robotapi
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCConfig configures validation of ID tokens from an OpenID Connect provider
+type OIDCConfig struct {
+	Issuer     string // e.g. https://keycloak.example.org/realms/mkss2
+	Audience   string // client ID the tokens must be issued for
+	RolesClaim string // dotted path to the roles claim, e.g. "realm_access.roles"
+	AdminRole  string // provider role that maps to the admin role
+}
+
+// OIDCVerifier validates RS256 signed ID tokens against the provider's JWKS
+type OIDCVerifier struct {
+	config      OIDCConfig
+	client      *http.Client
+	keys        map[string]*rsa.PublicKey
+	lastRefresh time.Time
+	clock       Clock
+	mutex       sync.Mutex
+}
+
+// NewOIDCVerifier creates a verifier; keys are fetched lazily on first use.
+// Issuer and audience are required.
+func NewOIDCVerifier(config OIDCConfig) (*OIDCVerifier, error) {
+	if config.Issuer == "" || config.Audience == "" {
+		return nil, errors.New("OIDC issuer and audience are required")
+	}
+	if config.RolesClaim == "" {
+		config.RolesClaim = "roles"
+	}
+	if config.AdminRole == "" {
+		config.AdminRole = "admin"
+	}
+	return &OIDCVerifier{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   make(map[string]*rsa.PublicKey),
+		clock:  SystemClock{},
+	}, nil
+}
+
+// SetClock replaces the clock token lifetimes and key refreshes are checked with
+func (v *OIDCVerifier) SetClock(clock Clock) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.clock = clock
+}
+
+// Verify checks the token's signature and standard claims and maps it to a principal
+func (v *OIDCVerifier) Verify(token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported signing algorithm %q", header.Alg)
+	}
+
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed signature")
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return nil, errors.New("invalid signature")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if err := v.checkClaims(claims); err != nil {
+		return nil, err
+	}
+
+	return v.principal(claims)
+}
+
+// checkClaims validates issuer, audience and the token's lifetime
+func (v *OIDCVerifier) checkClaims(claims map[string]interface{}) error {
+	const leeway = time.Minute
+	v.mutex.Lock()
+	now := v.clock.Now()
+	v.mutex.Unlock()
+
+	if iss, _ := claims["iss"].(string); iss != v.config.Issuer {
+		return errors.New("wrong issuer")
+	}
+
+	audienceOK := false
+	switch aud := claims["aud"].(type) {
+	case string:
+		audienceOK = aud == v.config.Audience
+	case []interface{}:
+		for _, entry := range aud {
+			if entry == v.config.Audience {
+				audienceOK = true
+			}
+		}
+	}
+	if !audienceOK {
+		return errors.New("wrong audience")
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(leeway)) {
+		return errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(leeway).Before(time.Unix(int64(nbf), 0)) {
+		return errors.New("token not valid yet")
+	}
+	return nil
+}
+
+// principal maps token claims to a principal. The ID is the immutable sub
+// claim, prefixed with "oidc:" so it can never collide with API key names.
+// Everyone gets the user role, the configured provider role additionally
+// maps to admin.
+func (v *OIDCVerifier) principal(claims map[string]interface{}) (*Principal, error) {
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return nil, errors.New("missing sub claim")
+	}
+	principal := &Principal{ID: "oidc:" + sub, Provider: "oidc", Roles: []string{RoleUser}}
+
+	principal.Name, _ = claims["name"].(string)
+	if principal.Name == "" {
+		principal.Name, _ = claims["preferred_username"].(string)
+	}
+	principal.Email, _ = claims["email"].(string)
+	principal.Tenant, _ = claims["tenant"].(string)
+
+	// Follow the dotted path to the roles claim
+	var value interface{} = claims
+	for _, segment := range strings.Split(v.config.RolesClaim, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			value = nil
+			break
+		}
+		value = object[segment]
+	}
+	if roles, ok := value.([]interface{}); ok {
+		for _, role := range roles {
+			if role == v.config.AdminRole {
+				principal.Roles = append(principal.Roles, RoleAdmin)
+			}
+		}
+	}
+	return principal, nil
+}
+
+// key returns the public key with the given ID, refreshing the key set if it
+// is unknown. Refreshes are limited to one every 30 seconds.
+func (v *OIDCVerifier) key(kid string) (*rsa.PublicKey, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	if key, exists := v.keys[kid]; exists {
+		return key, nil
+	}
+	now := v.clock.Now()
+	if now.Sub(v.lastRefresh) < 30*time.Second {
+		return nil, errors.New("unknown signing key")
+	}
+
+	v.lastRefresh = now
+	keys, err := v.fetchKeys()
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch signing keys: %w", err)
+	}
+	v.keys = keys
+
+	if key, exists := v.keys[kid]; exists {
+		return key, nil
+	}
+	return nil, errors.New("unknown signing key")
+}
+
+// fetchKeys loads the provider's JWKS via the discovery document
+func (v *OIDCVerifier) fetchKeys() (map[string]*rsa.PublicKey, error) {
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	issuer := strings.TrimSuffix(v.config.Issuer, "/")
+	if err := v.getJSON(issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.N)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.E)
+		if errN != nil || errE != nil {
+			continue
+		}
+		keys[jwk.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	return keys, nil
+}
+
+func (v *OIDCVerifier) getJSON(url string, target interface{}) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// decodeSegment decodes a base64url encoded JSON part of a JWT
+func decodeSegment(segment string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return errors.New("malformed token")
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		return errors.New("malformed token")
+	}
+	return nil
+}
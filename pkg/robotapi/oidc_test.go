@@ -0,0 +1,189 @@
+package robotapi
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// testProvider is a minimal OIDC provider serving discovery and JWKS
+type testProvider struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	provider := &testProvider{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"jwks_uri": provider.server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	provider.server = httptest.NewServer(mux)
+	t.Cleanup(provider.server.Close)
+	return provider
+}
+
+// token signs the given claims; iss, aud and exp are filled in if missing
+func (p *testProvider) token(claims map[string]interface{}) string {
+	if _, ok := claims["iss"]; !ok {
+		claims["iss"] = p.server.URL
+	}
+	if _, ok := claims["aud"]; !ok {
+		claims["aud"] = "robot-api"
+	}
+	if _, ok := claims["exp"]; !ok {
+		claims["exp"] = time.Now().Add(time.Hour).Unix()
+	}
+
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, _ := rsa.SignPKCS1v15(rand.Reader, p.key, crypto.SHA256, digest[:])
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func (p *testProvider) verifier() *OIDCVerifier {
+	verifier, _ := NewOIDCVerifier(OIDCConfig{
+		Issuer:     p.server.URL,
+		Audience:   "robot-api",
+		RolesClaim: "realm_access.roles",
+	})
+	return verifier
+}
+
+func TestNewOIDCVerifierRequiresAudience(t *testing.T) {
+	_, err := NewOIDCVerifier(OIDCConfig{Issuer: "https://sso.example.org"})
+	assert.Error(t, err)
+}
+
+func TestOIDCVerify(t *testing.T) {
+	provider := newTestProvider(t)
+	verifier := provider.verifier()
+
+	principal, err := verifier.Verify(provider.token(map[string]interface{}{
+		"sub":                "1234",
+		"preferred_username": "alice",
+		"email":              "alice@example.org",
+		"realm_access":       map[string]interface{}{"roles": []string{"admin"}},
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, "oidc:1234", principal.ID, "the mutable username is not used as ID")
+	assert.Equal(t, "alice", principal.Name)
+	assert.Equal(t, "alice@example.org", principal.Email)
+	assert.Equal(t, "oidc", principal.Provider)
+	assert.True(t, principal.HasRole(RoleUser))
+	assert.True(t, principal.HasRole(RoleAdmin))
+
+	principal, err = verifier.Verify(provider.token(map[string]interface{}{"sub": "5678"}))
+	assert.NoError(t, err)
+	assert.Equal(t, "oidc:5678", principal.ID)
+	assert.False(t, principal.HasRole(RoleAdmin))
+
+	_, err = verifier.Verify(provider.token(map[string]interface{}{"preferred_username": "alice"}))
+	assert.Error(t, err, "tokens without sub are rejected")
+}
+
+func TestOIDCVerifyRejectsInvalidTokens(t *testing.T) {
+	provider := newTestProvider(t)
+	verifier := provider.verifier()
+
+	_, err := verifier.Verify(provider.token(map[string]interface{}{"sub": "1", "aud": "other"}))
+	assert.Error(t, err)
+
+	_, err = verifier.Verify(provider.token(map[string]interface{}{"sub": "1", "iss": "https://evil.example"}))
+	assert.Error(t, err)
+
+	_, err = verifier.Verify(provider.token(map[string]interface{}{"sub": "1", "exp": time.Now().Add(-time.Hour).Unix()}))
+	assert.Error(t, err)
+
+	// Tamper with the payload after signing
+	token := provider.token(map[string]interface{}{"sub": "1"})
+	forged := provider.token(map[string]interface{}{"sub": "2"})
+	_, err = verifier.Verify(token[:len(token)-10] + forged[len(forged)-10:])
+	assert.Error(t, err)
+
+	_, err = verifier.Verify("not-a-token")
+	assert.Error(t, err)
+}
+
+func TestMeWithOIDC(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	provider := newTestProvider(t)
+
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	storage.CreateRobot(&Robot{ID: "mine", Owner: "oidc:alice-sub"})
+	storage.CreateRobot(&Robot{ID: "api-alices", Owner: "alice"})
+
+	keys, _ := NewAPIKeyStore("")
+	auth := NewAuthenticator(keys, provider.verifier(), NewSessionStore(time.Minute))
+	router := gin.New()
+	router.GET("/me", Authenticate(auth), handler.GetMe)
+	router.PATCH("/admin/world/time", RequireAdmin("", auth), handler.SetTimeSpeed)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/me", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Same username as the API key "alice", but a different principal
+	userToken := provider.token(map[string]interface{}{"sub": "alice-sub", "preferred_username": "alice"})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/me", nil)
+	req.Header.Set("Authorization", "Bearer "+userToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Principal Principal `json:"principal"`
+		Robots    []string  `json:"robots"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "oidc:alice-sub", response.Principal.ID)
+	assert.Equal(t, []string{"mine"}, response.Robots)
+
+	// Only the admin role may use admin endpoints
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PATCH", "/admin/world/time", nil)
+	req.Header.Set("Authorization", "Bearer "+userToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	adminToken := provider.token(map[string]interface{}{
+		"sub":          "carol-sub",
+		"realm_access": map[string]interface{}{"roles": []string{"admin"}},
+	})
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("PATCH", "/admin/world/time", bytes.NewBufferString(`{"speed": 2}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+adminToken)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
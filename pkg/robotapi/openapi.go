@@ -0,0 +1,24 @@
+package robotapi
+
+import (
+	_ "embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// openAPISpec describes the robot and item endpoints the generated clients
+// call. The contract tests of the clients check it against the router.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPISpec returns the OpenAPI document of the API
+func OpenAPISpec() []byte {
+	return append([]byte(nil), openAPISpec...)
+}
+
+// GetOpenAPISpec serves the OpenAPI document
+func GetOpenAPISpec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", openAPISpec)
+}
@@ -0,0 +1,93 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bodySchemas are the OpenAPI schemas of the request bodies the spec
+// describes, keyed by method and route path without the base path
+var bodySchemas = map[string]string{
+	"POST /robots":           "CreateRobotRequest",
+	"POST /robot/:id/move":   "MoveRequest",
+	"PATCH /robot/:id/state": "StateUpdateRequest",
+}
+
+// MethodOptions describes what a method of a resource accepts
+type MethodOptions struct {
+	Body *BodyOptions `json:"body,omitempty"` // nil if the method takes no body
+}
+
+// BodyOptions describes an accepted request body
+type BodyOptions struct {
+	ContentType string          `json:"content_type"`
+	Schema      string          `json:"schema,omitempty"` // URL of the JSON schema in the OpenAPI document
+	Example     json.RawMessage `json:"example,omitempty"`
+}
+
+// ResourceOptions is the response to OPTIONS requests
+type ResourceOptions struct {
+	Path    string                   `json:"path"`
+	Allow   []string                 `json:"allow"`
+	Methods map[string]MethodOptions `json:"methods"`
+}
+
+// RegisterOptions adds an OPTIONS route to every path of the router. It
+// answers with the Allow header, which lists HEAD for GET resources, and a
+// description of the bodies the methods accept, so clients can discover
+// what a resource supports. It must be called after all other routes were
+// added; CORS preflight requests are still answered by the CORS middleware
+// before they reach these routes.
+func RegisterOptions(router *gin.Engine, basePath string) {
+	methods := map[string][]string{}
+	for _, route := range router.Routes() {
+		methods[route.Path] = append(methods[route.Path], route.Method)
+	}
+	for path, allowed := range methods {
+		if containsString(allowed, http.MethodOptions) {
+			continue
+		}
+		allowed = append(allowed, http.MethodOptions)
+		if containsString(allowed, http.MethodGet) {
+			allowed = append(allowed, http.MethodHead)
+		}
+		sort.Strings(allowed)
+		router.OPTIONS(path, resourceOptions(path, strings.TrimPrefix(path, basePath), allowed))
+	}
+}
+
+// resourceOptions returns the OPTIONS handler of a path
+func resourceOptions(path, route string, allowed []string) gin.HandlerFunc {
+	if route == "" {
+		route = "/"
+	}
+	return func(c *gin.Context) {
+		options := ResourceOptions{
+			Path:    path,
+			Allow:   allowed,
+			Methods: make(map[string]MethodOptions, len(allowed)),
+		}
+		for _, method := range allowed {
+			var described MethodOptions
+			schema, hasSchema := bodySchemas[method+" "+route]
+			example, hasExample := postmanExamples[method+" "+route]
+			if hasSchema || hasExample {
+				described.Body = &BodyOptions{ContentType: "application/json"}
+				if hasSchema {
+					described.Body.Schema = requestBaseURL(c) + "/openapi.json#/components/schemas/" + schema
+				}
+				if hasExample {
+					described.Body.Example = json.RawMessage(example)
+				}
+			}
+			options.Methods[method] = described
+		}
+
+		c.Header("Allow", strings.Join(allowed, ", "))
+		c.JSON(http.StatusOK, options)
+	}
+}
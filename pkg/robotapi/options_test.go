@@ -0,0 +1,62 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOptionsDescribeResources(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/robot/:id/status", func(c *gin.Context) {})
+	router.POST("/robot/:id/move", func(c *gin.Context) {})
+	router.PATCH("/robot/:id/state", func(c *gin.Context) {})
+	router.GET("/robot/:id/state", func(c *gin.Context) {})
+	RegisterOptions(router, "")
+
+	w := send(router, "OPTIONS", "/robot/robot1/move", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "OPTIONS, POST", w.Header().Get("Allow"))
+	var options ResourceOptions
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &options))
+	assert.Equal(t, "/robot/:id/move", options.Path)
+	require.NotNil(t, options.Methods["POST"].Body)
+	assert.Equal(t, "application/json", options.Methods["POST"].Body.ContentType)
+	assert.Equal(t, "http:///openapi.json#/components/schemas/MoveRequest", options.Methods["POST"].Body.Schema)
+	assert.JSONEq(t, `{"direction": "up"}`, string(options.Methods["POST"].Body.Example))
+	assert.Nil(t, options.Methods["OPTIONS"].Body)
+
+	w = send(router, "OPTIONS", "/robot/robot1/state", "")
+	assert.Equal(t, "GET, HEAD, OPTIONS, PATCH", w.Header().Get("Allow"))
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &options))
+	assert.Nil(t, options.Methods["GET"].Body, "GET takes no body")
+	assert.NotNil(t, options.Methods["PATCH"].Body)
+}
+
+func TestOptionsOnServer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.BasePath = "/robots-api"
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+
+	w := send(router, "OPTIONS", "/robots-api/items", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "GET, HEAD, OPTIONS", w.Header().Get("Allow"))
+
+	// Preflight requests are still answered by the CORS middleware
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("OPTIONS", "/robots-api/robot/robot1/move", nil)
+	req.Header.Set("Origin", "http://localhost:3000")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Allow"))
+}
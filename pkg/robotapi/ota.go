@@ -0,0 +1,424 @@
+package robotapi
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Rollout states
+const (
+	RolloutRunning    = "running"
+	RolloutCompleted  = "completed"
+	RolloutRolledBack = "rolled_back"
+	RolloutCancelled  = "cancelled"
+)
+
+// defaultRolloutStageTicks is the number of ticks between two rollout stages
+const defaultRolloutStageTicks = 10
+
+// defaultRolloutStages are the fleet percentages updated by a rollout
+// unless the request gives its own
+var defaultRolloutStages = []int{10, 50, 100}
+
+// FirmwareArtifact is a firmware image robots can be flashed with
+type FirmwareArtifact struct {
+	Version      string    `json:"version" binding:"required"`
+	URL          string    `json:"url" binding:"required"`
+	Checksum     string    `json:"checksum" binding:"required"` // "sha256:<hex>"
+	Notes        string    `json:"notes,omitempty"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
+
+// validate checks version, download URL and checksum of the artifact
+func (a FirmwareArtifact) validate() error {
+	if _, err := ParseFirmwareVersion(a.Version); err != nil {
+		return err
+	}
+	if parsed, err := url.Parse(a.URL); err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	digest, found := strings.CutPrefix(a.Checksum, "sha256:")
+	if decoded, err := hex.DecodeString(digest); !found || err != nil || len(decoded) != 32 {
+		return errors.New("checksum must be given as sha256:<64 hex digits>")
+	}
+	return nil
+}
+
+// RolloutRequest schedules a firmware rollout. The fleet is either a list of
+// robot IDs or all robots with a tag; without both every robot is updated.
+type RolloutRequest struct {
+	Version string   `json:"version" binding:"required"`
+	Robots  []string `json:"robots"`
+	Fleet   string   `json:"fleet"`  // tag of the robots to update
+	Stages  []int    `json:"stages"` // ascending fleet percentages ending with 100
+}
+
+// Rollout flashes a firmware version onto a fleet in stages. Every stage
+// updates robots up to its share of the fleet, in the order of their IDs.
+type Rollout struct {
+	ID        string    `json:"id"`
+	TaskID    string    `json:"task_id"`
+	Version   string    `json:"version"`
+	Robots    []string  `json:"robots"`
+	Stages    []int     `json:"stages"`
+	Stage     int       `json:"stage"` // number of completed stages
+	Updated   []string  `json:"updated"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+
+	previous map[string]string // robot ID -> firmware before the rollout
+	wait     int64             // ticks until the next stage
+}
+
+// snapshot copies the public part of the rollout
+func (r *Rollout) snapshot() Rollout {
+	return Rollout{
+		ID:        r.ID,
+		TaskID:    r.TaskID,
+		Version:   r.Version,
+		Robots:    slices.Clone(r.Robots),
+		Stages:    slices.Clone(r.Stages),
+		Stage:     r.Stage,
+		Updated:   slices.Clone(r.Updated),
+		Status:    r.Status,
+		CreatedAt: r.CreatedAt,
+	}
+}
+
+// OTAManager registers firmware artifacts and drives rollouts. Rollouts
+// advance by one stage every stageTicks simulation ticks and are tracked
+// as "firmware_rollout" tasks.
+type OTAManager struct {
+	storage    *RobotStorage
+	tasks      *TaskStore
+	events     *EventBus
+	stageTicks int64
+
+	artifacts map[string]FirmwareArtifact // normalized version -> artifact
+	rollouts  map[string]*Rollout
+	clock     Clock
+	mutex     sync.Mutex
+}
+
+// NewOTAManager creates a manager that moves rollouts on every stageTicks ticks
+func NewOTAManager(storage *RobotStorage, tasks *TaskStore, events *EventBus, stageTicks int64) *OTAManager {
+	return &OTAManager{
+		storage:    storage,
+		tasks:      tasks,
+		events:     events,
+		stageTicks: stageTicks,
+		artifacts:  make(map[string]FirmwareArtifact),
+		rollouts:   make(map[string]*Rollout),
+		clock:      SystemClock{},
+	}
+}
+
+// SetClock replaces the clock artifacts are registered with
+func (m *OTAManager) SetClock(clock Clock) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.clock = clock
+}
+
+// Register adds a firmware artifact; every version can be registered once
+func (m *OTAManager) Register(artifact FirmwareArtifact) (FirmwareArtifact, error) {
+	if err := artifact.validate(); err != nil {
+		return FirmwareArtifact{}, err
+	}
+	version, _ := ParseFirmwareVersion(artifact.Version)
+	artifact.Version = version.String()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	artifact.RegisteredAt = m.clock.Now().UTC()
+	if _, exists := m.artifacts[artifact.Version]; exists {
+		return FirmwareArtifact{}, fmt.Errorf("firmware %s is already registered", artifact.Version)
+	}
+	m.artifacts[artifact.Version] = artifact
+	return artifact, nil
+}
+
+// Artifacts returns all registered artifacts ordered by version
+func (m *OTAManager) Artifacts() []FirmwareArtifact {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	artifacts := make([]FirmwareArtifact, 0, len(m.artifacts))
+	for _, artifact := range m.artifacts {
+		artifacts = append(artifacts, artifact)
+	}
+	sort.Slice(artifacts, func(i, j int) bool {
+		a, _ := ParseFirmwareVersion(artifacts[i].Version)
+		b, _ := ParseFirmwareVersion(artifacts[j].Version)
+		return a.Less(b)
+	})
+	return artifacts
+}
+
+// Start schedules a rollout; its first stage runs on the next tick
+func (m *OTAManager) Start(request RolloutRequest) (Rollout, error) {
+	version, err := ParseFirmwareVersion(request.Version)
+	if err != nil {
+		return Rollout{}, err
+	}
+	stages := request.Stages
+	if len(stages) == 0 {
+		stages = defaultRolloutStages
+	}
+	for i, percent := range stages {
+		if percent < 1 || percent > 100 || (i > 0 && percent <= stages[i-1]) {
+			return Rollout{}, errors.New("stages must be ascending percentages between 1 and 100")
+		}
+	}
+	if stages[len(stages)-1] != 100 {
+		return Rollout{}, errors.New("the last stage must update 100% of the fleet")
+	}
+
+	robots := slices.Clone(request.Robots)
+	if len(robots) == 0 {
+		for _, robot := range m.storage.GetAllRobots() {
+			if request.Fleet == "" || containsString(robot.Tags, request.Fleet) {
+				robots = append(robots, robot.ID)
+			}
+		}
+	}
+	sort.Strings(robots)
+	robots = slices.Compact(robots)
+	for _, id := range robots {
+		if _, err := m.storage.GetRobot(id); err != nil {
+			return Rollout{}, fmt.Errorf("robot %s not found", id)
+		}
+	}
+	if len(robots) == 0 {
+		return Rollout{}, errors.New("the fleet is empty")
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, exists := m.artifacts[version.String()]; !exists {
+		return Rollout{}, fmt.Errorf("firmware %s is not registered", version)
+	}
+
+	// Rollouts run in the background over many ticks
+	task := m.tasks.CreateWithPriority("firmware_rollout", PriorityLow)
+	rollout := &Rollout{
+		ID:        task.ID,
+		TaskID:    task.ID,
+		Version:   version.String(),
+		Robots:    robots,
+		Stages:    slices.Clone(stages),
+		Updated:   []string{},
+		Status:    RolloutRunning,
+		CreatedAt: task.CreatedAt,
+		previous:  make(map[string]string),
+	}
+	m.rollouts[rollout.ID] = rollout
+	m.syncTask(rollout)
+	context.AfterFunc(m.tasks.Context(task.ID), func() { m.cancel(rollout.ID) })
+	return rollout.snapshot(), nil
+}
+
+// Get returns a copy of a rollout
+func (m *OTAManager) Get(id string) (Rollout, bool) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	rollout, exists := m.rollouts[id]
+	if !exists {
+		return Rollout{}, false
+	}
+	return rollout.snapshot(), true
+}
+
+// Tick advances running rollouts. It is registered as a simulation system.
+func (m *OTAManager) Tick(tick int64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, rollout := range m.rollouts {
+		if rollout.Status != RolloutRunning {
+			continue
+		}
+		if rollout.wait > 0 {
+			rollout.wait--
+			continue
+		}
+		m.advance(rollout)
+		rollout.wait = m.stageTicks
+	}
+}
+
+// advance runs the next stage of a rollout; callers must hold the lock
+func (m *OTAManager) advance(rollout *Rollout) {
+	percent := rollout.Stages[rollout.Stage]
+	target := (len(rollout.Robots)*percent + 99) / 100
+	for _, id := range rollout.Robots[len(rollout.Updated):target] {
+		// Robots deleted in the meantime are skipped
+		m.storage.UpdateRobot(id, func(robot *Robot) error {
+			rollout.previous[id] = robot.Firmware
+			robot.Firmware = rollout.Version
+			appendAction(robot, "firmware", fmt.Sprintf("Flashed firmware %s", rollout.Version),
+				gin.H{"firmware": rollout.Version, "rollout": rollout.ID})
+			return nil
+		})
+		rollout.Updated = append(rollout.Updated, id)
+	}
+
+	rollout.Stage++
+	m.events.Publish("rollout_stage_completed", "", gin.H{"rollout": rollout.ID, "stage": rollout.Stage, "percent": percent})
+	if rollout.Stage == len(rollout.Stages) {
+		rollout.Status = RolloutCompleted
+		m.events.Publish("rollout_completed", "", gin.H{"rollout": rollout.ID, "version": rollout.Version})
+	}
+	m.syncTask(rollout)
+}
+
+// cancel stops a running rollout when its task is cancelled. The robots
+// flashed so far keep the new firmware until the rollout is rolled back.
+func (m *OTAManager) cancel(id string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	rollout := m.rollouts[id]
+	if rollout.Status != RolloutRunning {
+		return
+	}
+	rollout.Status = RolloutCancelled
+	m.events.Publish("rollout_cancelled", "", gin.H{"rollout": rollout.ID, "robots": rollout.Updated})
+	m.tasks.Update(rollout.TaskID, func(task *Task) {
+		task.Status = TaskCancelled
+		task.Error = "cancelled"
+		task.Result = rollout.snapshot()
+	})
+}
+
+// Rollback stops a rollout and restores the previous firmware on every
+// robot it updated
+func (m *OTAManager) Rollback(id string) (Rollout, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	rollout, exists := m.rollouts[id]
+	if !exists {
+		return Rollout{}, errors.New("rollout not found")
+	}
+	if rollout.Status == RolloutRolledBack {
+		return rollout.snapshot(), nil
+	}
+
+	for _, robotID := range rollout.Updated {
+		previous, flashed := rollout.previous[robotID]
+		if !flashed {
+			continue
+		}
+		m.storage.UpdateRobot(robotID, func(robot *Robot) error {
+			robot.Firmware = previous
+			appendAction(robot, "firmware", fmt.Sprintf("Rolled back firmware %s", rollout.Version),
+				gin.H{"firmware": previous, "rollout": rollout.ID, "rolled_back": rollout.Version})
+			return nil
+		})
+	}
+	rollout.Status = RolloutRolledBack
+	m.events.Publish("rollout_rolled_back", "", gin.H{"rollout": rollout.ID, "robots": rollout.Updated})
+	m.tasks.Update(rollout.TaskID, func(task *Task) {
+		task.Status = TaskCancelled
+		task.Error = "rolled back"
+		task.Result = rollout.snapshot()
+	})
+	return rollout.snapshot(), nil
+}
+
+// syncTask mirrors the rollout into its task; callers must hold the lock
+func (m *OTAManager) syncTask(rollout *Rollout) {
+	m.tasks.Update(rollout.TaskID, func(task *Task) {
+		task.Status = TaskRunning
+		task.Robots = rollout.Robots
+		if rollout.Status == RolloutCompleted {
+			task.Status = TaskSucceeded
+		}
+		task.Progress = float64(rollout.Stage) / float64(len(rollout.Stages))
+		task.Result = rollout.snapshot()
+	})
+}
+
+// RegisterFirmware adds a firmware artifact that rollouts can install
+func (h *RobotHandler) RegisterFirmware(c *gin.Context) {
+	var artifact FirmwareArtifact
+	if err := bindJSON(c, &artifact); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "version, url and checksum are required", "details": bindErrorDetails(err)})
+		return
+	}
+	if err := artifact.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	artifact, err := h.ota.Register(artifact)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, artifact)
+}
+
+// ListFirmware returns all registered firmware artifacts
+func (h *RobotHandler) ListFirmware(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"artifacts": h.ota.Artifacts()})
+}
+
+// StartRollout schedules a staged firmware rollout and returns the task
+// that tracks it
+func (h *RobotHandler) StartRollout(c *gin.Context) {
+	var request RolloutRequest
+	if err := bindJSON(c, &request); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+
+	rollout, err := h.ota.Start(request)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	baseURL := requestBaseURL(c)
+	c.JSON(http.StatusAccepted, gin.H{
+		"rollout": rollout,
+		"links": []Link{
+			{Rel: "self", Href: fmt.Sprintf("%s/admin/rollouts/%s", baseURL, rollout.ID)},
+			{Rel: "task", Href: fmt.Sprintf("%s/admin/tasks/%s", baseURL, rollout.TaskID)},
+			{Rel: "rollback", Href: fmt.Sprintf("%s/admin/rollouts/%s/rollback", baseURL, rollout.ID)},
+		},
+	})
+}
+
+// GetRollout returns the progress of a rollout
+func (h *RobotHandler) GetRollout(c *gin.Context) {
+	rollout, exists := h.ota.Get(c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rollout not found"})
+		return
+	}
+	c.JSON(http.StatusOK, rollout)
+}
+
+// RollbackRollout stops a rollout and restores the previous firmware
+func (h *RobotHandler) RollbackRollout(c *gin.Context) {
+	rollout, err := h.ota.Rollback(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rollout not found"})
+		return
+	}
+	c.JSON(http.StatusOK, rollout)
+}
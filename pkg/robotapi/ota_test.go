@@ -0,0 +1,92 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFirmwareRollout(t *testing.T) {
+	router, storage, events := setupMapRouter()
+	tasks := NewTaskStore()
+	ota := NewOTAManager(storage, tasks, events, 0)
+	handler := NewRobotHandler(storage)
+	handler.SetTasks(tasks)
+	handler.SetOTA(ota)
+	router.POST("/admin/firmware", handler.RegisterFirmware)
+	router.GET("/admin/firmware", handler.ListFirmware)
+	router.POST("/admin/rollouts", handler.StartRollout)
+	router.GET("/admin/rollouts/:id", handler.GetRollout)
+	router.POST("/admin/rollouts/:id/rollback", handler.RollbackRollout)
+	router.GET("/admin/tasks", handler.ListTasks)
+	router.GET("/admin/tasks/:id", handler.GetTask)
+
+	storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Firmware = "1.0.0"
+		return nil
+	})
+
+	checksum := "sha256:" + strings.Repeat("ab", 32)
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/admin/firmware", `{"version": "2.0", "url": "https://fw.example/2.0.bin", "checksum": "md5:123"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/admin/firmware", `{"version": "2.0", "url": "fw.bin", "checksum": "`+checksum+`"}`).Code)
+	assert.Equal(t, http.StatusCreated, send(router, "POST", "/admin/firmware", `{"version": "v2.0", "url": "https://fw.example/2.0.bin", "checksum": "`+checksum+`"}`).Code)
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/admin/firmware", `{"version": "2.0.0", "url": "https://fw.example/2.0.bin", "checksum": "`+checksum+`"}`).Code)
+	assert.Contains(t, send(router, "GET", "/admin/firmware", "").Body.String(), `"version":"2.0.0"`)
+
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/admin/rollouts", `{"version": "3.0"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/admin/rollouts", `{"version": "2.0", "stages": [50, 20, 100]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/admin/rollouts", `{"version": "2.0", "stages": [50]}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/admin/rollouts", `{"version": "2.0", "robots": ["ghost"]}`).Code)
+
+	w := send(router, "POST", "/admin/rollouts", `{"version": "2.0", "robots": ["robot2", "robot1"], "stages": [50, 100]}`)
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var started struct {
+		Rollout Rollout `json:"rollout"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &started)
+	rollout := started.Rollout
+	assert.Equal(t, []string{"robot1", "robot2"}, rollout.Robots)
+
+	var task Task
+	json.Unmarshal(send(router, "GET", "/admin/tasks/"+rollout.TaskID, "").Body.Bytes(), &task)
+	assert.Equal(t, "firmware_rollout", task.Type)
+	assert.Equal(t, TaskRunning, task.Status)
+
+	// The first stage flashes half of the fleet
+	ota.Tick(1)
+	json.Unmarshal(send(router, "GET", "/admin/rollouts/"+rollout.ID, "").Body.Bytes(), &rollout)
+	assert.Equal(t, []string{"robot1"}, rollout.Updated)
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, "2.0.0", robot.Firmware)
+	robot, _ = storage.GetRobot("robot2")
+	assert.Equal(t, "", robot.Firmware)
+
+	ota.Tick(2)
+	json.Unmarshal(send(router, "GET", "/admin/tasks/"+rollout.TaskID, "").Body.Bytes(), &task)
+	assert.Equal(t, TaskSucceeded, task.Status)
+	assert.Equal(t, 1.0, task.Progress)
+	robot, _ = storage.GetRobot("robot2")
+	assert.Equal(t, "2.0.0", robot.Firmware)
+
+	w = send(router, "POST", "/admin/rollouts/"+rollout.ID+"/rollback", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &rollout)
+	assert.Equal(t, RolloutRolledBack, rollout.Status)
+	robot, _ = storage.GetRobot("robot1")
+	assert.Equal(t, "1.0.0", robot.Firmware)
+	robot, _ = storage.GetRobot("robot2")
+	assert.Equal(t, "", robot.Firmware)
+	assert.Equal(t, http.StatusNotFound, send(router, "POST", "/admin/rollouts/unknown/rollback", "").Code)
+
+	assert.Contains(t, send(router, "GET", "/admin/tasks?type=firmware_rollout&status=succeeded", "").Body.String(), rollout.TaskID)
+	assert.Equal(t, `{"tasks":[]}`, send(router, "GET", "/admin/tasks?status=failed", "").Body.String())
+
+	var types []string
+	for _, event := range events.Since(0) {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{"rollout_stage_completed", "rollout_stage_completed", "rollout_completed", "rollout_rolled_back"}, types)
+}
@@ -0,0 +1,317 @@
+package robotapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead" // gave up after the maximum number of attempts
+)
+
+// Outbox defaults
+const (
+	defaultDeliveryAttempts = 8
+	deliveryBaseBackoff     = time.Second
+	deliveryMaxBackoff      = 5 * time.Minute
+	deliveryTimeout         = 10 * time.Second
+	maxDeliveredKept        = 1000
+)
+
+// ErrDeliveryNotFound is returned for unknown delivery IDs
+var ErrDeliveryNotFound = errors.New("delivery not found")
+
+// Sink receives events outside the API, e.g. a webhook or a message broker
+type Sink interface {
+	Name() string
+	Deliver(ctx context.Context, event Event) error
+}
+
+// WebhookSink posts every event as JSON to a URL. Any 2xx status counts as
+// delivered.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewWebhookSink creates a webhook sink for the URL
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{URL: url, Client: &http.Client{Timeout: deliveryTimeout}}
+}
+
+// Name identifies the sink by its URL
+func (w *WebhookSink) Name() string {
+	return "webhook:" + w.URL
+}
+
+// Deliver posts the event
+func (w *WebhookSink) Deliver(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-Type", event.Type)
+	req.Header.Set("X-Event-Sequence", fmt.Sprint(event.Sequence))
+
+	resp, err := w.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// Delivery is one event on its way to one sink
+type Delivery struct {
+	ID          string     `json:"id"`
+	Sink        string     `json:"sink"`
+	Event       Event      `json:"event"`
+	Status      string     `json:"status"`
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	NextAttempt *time.Time `json:"next_attempt,omitempty"` // only while pending
+	CreatedAt   time.Time  `json:"created_at"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+// Outbox records every published event for every sink before anything is
+// sent, and retries failed deliveries with exponential backoff until they
+// succeed or are dead-lettered
+type Outbox struct {
+	sinks       map[string]Sink
+	deliveries  map[string]*Delivery
+	delivered   []string // IDs of delivered entries, oldest first
+	pending     int      // deliveries not delivered or dead-lettered yet
+	maxAttempts int
+	ids         IDGenerator
+	clock       Clock
+	attach      func(event Event) Event // nil if events are delivered as published
+	mutex       sync.Mutex
+}
+
+// NewOutbox creates an outbox for the sinks. Deliveries are given up after
+// maxAttempts failures.
+func NewOutbox(sinks []Sink, maxAttempts int) *Outbox {
+	outbox := &Outbox{
+		sinks:       make(map[string]Sink),
+		deliveries:  make(map[string]*Delivery),
+		maxAttempts: maxAttempts,
+		ids:         UUIDGenerator{},
+		clock:       SystemClock{},
+	}
+	for _, sink := range sinks {
+		outbox.sinks[sink.Name()] = sink
+	}
+	return outbox
+}
+
+// SetClock replaces the clock that retries are scheduled with
+func (o *Outbox) SetClock(clock Clock) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.clock = clock
+}
+
+// SetAttachments sets a function that adds data to events before they are
+// recorded for the sinks, for data too large or too private for the event
+// log itself
+func (o *Outbox) SetAttachments(attach func(event Event) Event) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.attach = attach
+}
+
+// HasSinks reports whether events are delivered anywhere
+func (o *Outbox) HasSinks() bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return len(o.sinks) > 0
+}
+
+// Record adds a pending delivery of the event for every sink. It is meant
+// to be registered with EventBus.OnPublish.
+func (o *Outbox) Record(event Event) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if o.attach != nil && len(o.sinks) > 0 {
+		event = o.attach(event)
+	}
+	now := o.clock.Now()
+	for name := range o.sinks {
+		next := now
+		delivery := &Delivery{ID: o.ids.NewID(), Sink: name, Event: event, Status: DeliveryPending, NextAttempt: &next, CreatedAt: now}
+		o.deliveries[delivery.ID] = delivery
+		o.pending++
+	}
+}
+
+// due returns copies of the pending deliveries whose next attempt is due
+func (o *Outbox) due() []Delivery {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	now := o.clock.Now()
+	due := []Delivery{}
+	for _, delivery := range o.deliveries {
+		if delivery.Status == DeliveryPending && !delivery.NextAttempt.After(now) {
+			due = append(due, *delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].Event.Sequence < due[j].Event.Sequence
+	})
+	return due
+}
+
+// Flush attempts all due deliveries once
+func (o *Outbox) Flush(ctx context.Context) {
+	for _, delivery := range o.due() {
+		deliverCtx, cancel := context.WithTimeout(ctx, deliveryTimeout)
+		err := o.sinks[delivery.Sink].Deliver(deliverCtx, delivery.Event)
+		cancel()
+		o.finish(delivery.ID, err)
+	}
+}
+
+// finish records the outcome of an attempt
+func (o *Outbox) finish(id string, err error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	delivery, exists := o.deliveries[id]
+	if !exists || delivery.Status != DeliveryPending {
+		return
+	}
+	now := o.clock.Now()
+	delivery.Attempts++
+	if err == nil {
+		o.pending--
+		delivery.Status = DeliveryDelivered
+		delivery.LastError = ""
+		delivery.NextAttempt = nil
+		delivery.DeliveredAt = &now
+		o.delivered = append(o.delivered, id)
+		if len(o.delivered) > maxDeliveredKept {
+			delete(o.deliveries, o.delivered[0])
+			o.delivered = o.delivered[1:]
+		}
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= o.maxAttempts {
+		o.pending--
+		delivery.Status = DeliveryDead
+		delivery.NextAttempt = nil
+		return
+	}
+	backoff := deliveryBaseBackoff << (delivery.Attempts - 1)
+	if backoff > deliveryMaxBackoff || backoff <= 0 {
+		backoff = deliveryMaxBackoff
+	}
+	next := now.Add(backoff)
+	delivery.NextAttempt = &next
+}
+
+// Requeue gives a dead delivery a fresh set of attempts
+func (o *Outbox) Requeue(id string) (Delivery, error) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	delivery, exists := o.deliveries[id]
+	if !exists {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	if delivery.Status != DeliveryDead {
+		return Delivery{}, fmt.Errorf("only dead deliveries can be requeued, this one is %s", delivery.Status)
+	}
+	next := o.clock.Now()
+	o.pending++
+	delivery.Status = DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttempt = &next
+	return *delivery, nil
+}
+
+// Pending returns the number of deliveries waiting for their sinks
+func (o *Outbox) Pending() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.pending
+}
+
+// List returns copies of the deliveries with the given status, or all if
+// status is empty, oldest event first
+func (o *Outbox) List(status string) []Delivery {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	deliveries := []Delivery{}
+	for _, delivery := range o.deliveries {
+		if status == "" || delivery.Status == status {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].Event.Sequence != deliveries[j].Event.Sequence {
+			return deliveries[i].Event.Sequence < deliveries[j].Event.Sequence
+		}
+		return deliveries[i].Sink < deliveries[j].Sink
+	})
+	return deliveries
+}
+
+// Run flushes due deliveries every interval until ctx is done
+func (o *Outbox) Run(ctx context.Context, interval time.Duration) {
+	ticker := o.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			o.Flush(ctx)
+		}
+	}
+}
+
+// ListDeliveries shows the outbox, optionally filtered by ?status=
+func (h *RobotHandler) ListDeliveries(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"deliveries": h.outbox.List(c.Query("status"))})
+}
+
+// RequeueDelivery retries a dead delivery
+func (h *RobotHandler) RequeueDelivery(c *gin.Context) {
+	delivery, err := h.outbox.Requeue(c.Param("id"))
+	if errors.Is(err, ErrDeliveryNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
@@ -0,0 +1,115 @@
+package robotapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboxRetriesWithBackoff(t *testing.T) {
+	var failures atomic.Int32
+	failures.Store(2)
+	var received []Event
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures.Add(-1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var event Event
+		json.NewDecoder(r.Body).Decode(&event)
+		received = append(received, event)
+	}))
+	defer webhook.Close()
+
+	clock := NewFakeClock(time.Unix(0, 0))
+	outbox := NewOutbox([]Sink{NewWebhookSink(webhook.URL)}, 5)
+	outbox.SetClock(clock)
+	events := NewEventBus()
+	events.OnPublish(outbox.Record)
+	events.Publish("robot_attacked", "robot2", gin.H{"attacker": "robot1"})
+
+	ctx := context.Background()
+	outbox.Flush(ctx)
+	pending := outbox.List(DeliveryPending)
+	assert.Len(t, pending, 1)
+	assert.Equal(t, 1, outbox.Pending())
+	assert.Equal(t, 1, pending[0].Attempts)
+	assert.Contains(t, pending[0].LastError, "503")
+	assert.Equal(t, clock.Now().Add(time.Second), *pending[0].NextAttempt)
+
+	// Nothing is retried before the backoff is over, which doubles
+	outbox.Flush(ctx)
+	assert.Equal(t, 1, outbox.List(DeliveryPending)[0].Attempts)
+	clock.Advance(time.Second)
+	outbox.Flush(ctx)
+	assert.Equal(t, clock.Now().Add(2*time.Second), *outbox.List(DeliveryPending)[0].NextAttempt)
+
+	clock.Advance(2 * time.Second)
+	outbox.Flush(ctx)
+	delivered := outbox.List(DeliveryDelivered)
+	assert.Len(t, delivered, 1)
+	assert.Equal(t, 3, delivered[0].Attempts)
+	assert.Len(t, received, 1)
+	assert.Equal(t, "robot_attacked", received[0].Type)
+	assert.Empty(t, outbox.List(DeliveryPending))
+	assert.Equal(t, 0, outbox.Pending())
+}
+
+func TestOutboxDeadLettersAndRequeues(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var healthy atomic.Bool
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer webhook.Close()
+
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	config.Webhooks = []string{webhook.URL}
+	config.DeliveryAttempts = 1
+	server, err := New(config)
+	assert.NoError(t, err)
+	router := server.Router()
+
+	placeInRange(t, server.Storage(), "robot1", "robot2")
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/attack/robot2", "").Code)
+	server.outbox.Flush(context.Background())
+
+	admin := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	var list struct {
+		Deliveries []Delivery `json:"deliveries"`
+	}
+	json.Unmarshal(admin("GET", "/admin/deliveries?status=dead").Body.Bytes(), &list)
+	assert.Len(t, list.Deliveries, 1)
+	dead := list.Deliveries[0]
+	assert.Equal(t, "robot_attacked", dead.Event.Type)
+	assert.Equal(t, "webhook:"+webhook.URL, dead.Sink)
+	assert.Equal(t, 0, server.outbox.Pending())
+
+	// Dead deliveries stay put until an admin requeues them
+	healthy.Store(true)
+	server.outbox.Flush(context.Background())
+	assert.Equal(t, http.StatusOK, admin("POST", "/admin/deliveries/"+dead.ID+"/requeue").Code)
+	assert.Equal(t, http.StatusConflict, admin("POST", "/admin/deliveries/"+dead.ID+"/requeue").Code)
+	assert.Equal(t, 1, server.outbox.Pending())
+	assert.Equal(t, http.StatusNotFound, admin("POST", "/admin/deliveries/unknown/requeue").Code)
+	server.outbox.Flush(context.Background())
+	json.Unmarshal(admin("GET", "/admin/deliveries?status=delivered").Body.Bytes(), &list)
+	assert.Len(t, list.Deliveries, 1)
+	assert.Equal(t, dead.ID, list.Deliveries[0].ID)
+}
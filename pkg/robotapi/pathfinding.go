@@ -0,0 +1,192 @@
+package robotapi
+
+import (
+	"container/heap"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxPathDistance limits how far away a path target may be
+const maxPathDistance = 200
+
+// pathMargin is how far a path may leave the rectangle spanned by start and
+// target to get around obstacles on an unlimited map
+const pathMargin = 10
+
+// pathNode is an entry of the A* open list
+type pathNode struct {
+	position Position
+	cost     int // steps from the start
+	estimate int // cost plus the remaining distance
+	index    int
+}
+
+// pathQueue orders open nodes by their estimated total cost
+type pathQueue []*pathNode
+
+func (q pathQueue) Len() int { return len(q) }
+func (q pathQueue) Less(i, j int) bool {
+	return q[i].estimate < q[j].estimate
+}
+func (q pathQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index, q[j].index = i, j
+}
+func (q *pathQueue) Push(x interface{}) {
+	node := x.(*pathNode)
+	node.index = len(*q)
+	*q = append(*q, node)
+}
+func (q *pathQueue) Pop() interface{} {
+	old := *q
+	node := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return node
+}
+
+// flatMoves lists the moves within a floor in a fixed order so paths are stable
+var flatMoves = []string{"up", "right", "down", "left"}
+
+// pathMoves adds the moves between floors
+var pathMoves = append(flatMoves[:len(flatMoves):len(flatMoves)], "ascend", "descend")
+
+// searchArea returns the cells a path search may visit: the whole map if it
+// has bounds, otherwise the rectangle spanned by start and goal plus
+// pathMargin
+func searchArea(start, goal Position, layout MapLayout) Bounds {
+	if layout.Bounds != nil {
+		return *layout.Bounds
+	}
+	return Bounds{
+		MinX: min(start.X, goal.X) - pathMargin,
+		MinY: min(start.Y, goal.Y) - pathMargin,
+		MaxX: max(start.X, goal.X) + pathMargin,
+		MaxY: max(start.Y, goal.Y) + pathMargin,
+	}
+}
+
+// FindPath plans the shortest path from start to goal with A* within the
+// given area, taking only steps the passable function allows. Bounds only
+// limit X and Y, so floors are only reachable through passable steps. It
+// returns the move directions, or false if the goal cannot be reached.
+func FindPath(start, goal Position, area Bounds, passable func(from, to Position) bool) ([]string, bool) {
+	return findPath(MapLayout{}, start, goal, area, passable)
+}
+
+// findPath is FindPath on a map that may wrap around its edges, where paths
+// may leave the map on one edge to reach the goal sooner
+func findPath(layout MapLayout, start, goal Position, area Bounds, passable func(from, to Position) bool) ([]string, bool) {
+	type step struct {
+		from      Position
+		direction string
+	}
+	came := map[Position]step{}
+	costs := map[Position]int{start: 0}
+	open := &pathQueue{}
+	heap.Push(open, &pathNode{position: start, estimate: layout.Distance(start, goal)})
+
+	for open.Len() > 0 {
+		node := heap.Pop(open).(*pathNode)
+		if node.position == goal {
+			var moves []string
+			for p := goal; p != start; p = came[p].from {
+				moves = append(moves, came[p].direction)
+			}
+			for i, j := 0, len(moves)-1; i < j; i, j = i+1, j-1 {
+				moves[i], moves[j] = moves[j], moves[i]
+			}
+			return moves, true
+		}
+		if node.cost > costs[node.position] {
+			continue // outdated entry
+		}
+
+		for _, direction := range pathMoves {
+			next := layout.Step(node.position, directions[direction])
+			if !area.Contains(next) || !passable(node.position, next) {
+				continue
+			}
+			cost := node.cost + 1
+			if known, seen := costs[next]; seen && known <= cost {
+				continue
+			}
+			costs[next] = cost
+			came[next] = step{from: node.position, direction: direction}
+			heap.Push(open, &pathNode{position: next, cost: cost, estimate: cost + layout.Distance(next, goal)})
+		}
+	}
+	return nil, false
+}
+
+// GetPath previews the route of a robot to a target cell without moving it.
+// Hazards are avoided like obstacles of the map; the response lists the planned moves,
+// the cells along the way, the energy cost in the current weather and the
+// hazards between robot and target.
+func (h *RobotHandler) GetPath(c *gin.Context) {
+	robot, err := h.world(c).GetRobot(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	toX, errX := strconv.Atoi(c.Query("toX"))
+	toY, errY := strconv.Atoi(c.Query("toY"))
+	toZ, errZ := strconv.Atoi(c.DefaultQuery("toZ", "0"))
+	if errX != nil || errY != nil || errZ != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "toX, toY and toZ must be integers"})
+		return
+	}
+	goal := Position{X: toX, Y: toY, Z: toZ}
+	layout := h.world(c).GetLayout()
+	if layout.Distance(robot.Position, goal) > maxPathDistance {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Target is more than " + strconv.Itoa(maxPathDistance) + " cells away"})
+		return
+	}
+	if layout.Blocked(goal) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The target is blocked or outside the map"})
+		return
+	}
+
+	// Obstacles are the hazards that reach into the searched area, in
+	// addition to the blocked cells of the map
+	area := searchArea(robot.Position, goal, layout)
+	obstacles := h.world(c).HazardsNear(robot.Position, (area.MaxX-area.MinX)+(area.MaxY-area.MinY))
+	passable := func(from, to Position) bool {
+		if layout.CanMove(from, to) != nil {
+			return false
+		}
+		for _, hazard := range obstacles {
+			if to != goal && hazard.coversOn(layout, to) {
+				return false
+			}
+		}
+		return true
+	}
+
+	moves, found := findPath(layout, robot.Position, goal, area, passable)
+	if !found {
+		c.JSON(http.StatusConflict, gin.H{"error": "No path to the target", "obstacles": obstacles})
+		return
+	}
+
+	path := []Position{}
+	position := robot.Position
+	for _, direction := range moves {
+		position = layout.Step(position, directions[direction])
+		path = append(path, position)
+	}
+
+	cost := len(moves) * h.weather.Current().MoveCost
+	c.JSON(http.StatusOK, gin.H{
+		"from":        robot.Position,
+		"to":          goal,
+		"moves":       append([]string{}, moves...),
+		"path":        path,
+		"steps":       len(moves),
+		"energy_cost": cost,
+		"reachable":   cost <= robot.Energy,
+		"obstacles":   obstacles,
+	})
+}
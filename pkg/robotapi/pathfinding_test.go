@@ -0,0 +1,100 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindPath(t *testing.T) {
+	area := Bounds{MinX: -20, MinY: -20, MaxX: 20, MaxY: 20}
+	open := func(from, to Position) bool { return to.Z == 0 }
+	moves, found := FindPath(Position{X: 0, Y: 0}, Position{X: 2, Y: -1}, area, open)
+	assert.True(t, found)
+	assert.Len(t, moves, 3)
+
+	// A wall at x=1 from y=-3 to y=3 forces a detour
+	wall := func(from, to Position) bool { return to.Z == 0 && !(to.X == 1 && to.Y >= -3 && to.Y <= 3) }
+	moves, found = FindPath(Position{X: 0, Y: 0}, Position{X: 2, Y: 0}, area, wall)
+	assert.True(t, found)
+	assert.Len(t, moves, 10)
+
+	// A goal enclosed by obstacles cannot be reached
+	enclosed := func(from, to Position) bool { return to.Z == 0 && to.DistanceTo(Position{X: 5, Y: 5}) != 1 }
+	_, found = FindPath(Position{X: 0, Y: 0}, Position{X: 5, Y: 5}, area, enclosed)
+	assert.False(t, found)
+}
+
+func TestGetPath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	router := gin.New()
+	router.GET("/robot/:id/path", handler.GetPath)
+
+	// Lava lies at (5,5); the robot at (5,4) has to go around it
+	storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Position = Position{X: 5, Y: 4}
+		return nil
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/robot/robot1/path?toX=5&toY=6", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Moves     []string   `json:"moves"`
+		Path      []Position `json:"path"`
+		Steps     int        `json:"steps"`
+		Reachable bool       `json:"reachable"`
+		Obstacles []Hazard   `json:"obstacles"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 4, response.Steps)
+	assert.Equal(t, Position{X: 5, Y: 6}, response.Path[len(response.Path)-1])
+	assert.NotContains(t, response.Path, Position{X: 5, Y: 5})
+	assert.True(t, response.Reachable)
+	assert.Len(t, response.Obstacles, 2)
+
+	// Nothing moved
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 5, Y: 4}, robot.Position)
+
+	for _, query := range []string{"?toX=1", "?toX=500&toY=0"} {
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/robot/robot1/path"+query, nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+}
+
+func TestGetPathOnTorus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	assert.NoError(t, storage.SetBounds(&Bounds{MinX: -8, MinY: 0, MaxX: 12, MaxY: 12}))
+	storage.SetWrap(true)
+	handler := NewRobotHandler(storage)
+	router := gin.New()
+	router.GET("/robot/:id/path", handler.GetPath)
+
+	// From (0,0) the top row is closer across the bottom edge
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/robot/robot1/path?toX=0&toY=11", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Moves []string   `json:"moves"`
+		Path  []Position `json:"path"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []string{"down", "down"}, response.Moves)
+	assert.Equal(t, []Position{{X: 0, Y: 12}, {X: 0, Y: 11}}, response.Path)
+}
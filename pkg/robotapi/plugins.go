@@ -0,0 +1,358 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"plugin"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"aufgabe-2/pkg/pubsub"
+)
+
+// actionNamePattern restricts custom action names to URL friendly words
+var actionNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// ParamSpec declares a parameter of a custom action
+type ParamSpec struct {
+	Type     string   `json:"type" binding:"required"` // "number", "string" or "boolean"
+	Required bool     `json:"required,omitempty"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	Enum     []string `json:"enum,omitempty"` // allowed strings
+}
+
+// check validates a parameter value against the spec
+func (p ParamSpec) check(name string, value interface{}) error {
+	switch p.Type {
+	case "number":
+		number, ok := value.(float64)
+		if !ok {
+			return fmt.Errorf("%s must be a number", name)
+		}
+		if (p.Min != nil && number < *p.Min) || (p.Max != nil && number > *p.Max) {
+			return fmt.Errorf("%s is out of range", name)
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", name)
+		}
+		if len(p.Enum) > 0 && !slices.Contains(p.Enum, text) {
+			return fmt.Errorf("%s must be one of %v", name, p.Enum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s must be a boolean", name)
+		}
+	}
+	return nil
+}
+
+// ActionPlugin is a custom action served under /robot/:id/custom/:name.
+// Plugins registered through the API are declarative; Go plugins can add
+// their own validation and effect.
+type ActionPlugin struct {
+	Name        string               `json:"name" binding:"required"`
+	Description string               `json:"description,omitempty"`
+	EnergyCost  int                  `json:"energy_cost"`
+	HistoryType string               `json:"history_type,omitempty"` // action type in the history, defaults to the name
+	Permission  string               `json:"permission,omitempty"`   // permission needed by delegates, defaults to "update"
+	Params      map[string]ParamSpec `json:"params,omitempty"`
+	Source      string               `json:"source"` // "go" or "api"
+
+	// Validate rejects requests before any energy is spent
+	Validate func(robot *Robot, params map[string]interface{}) error `json:"-"`
+	// Apply changes the robot and returns a result for the response. It runs
+	// while the robot is locked and must not call the storage.
+	Apply func(robot *Robot, params map[string]interface{}) (interface{}, error) `json:"-"`
+}
+
+// normalize fills in defaults and validates the definition
+func (p *ActionPlugin) normalize() error {
+	if !actionNamePattern.MatchString(p.Name) {
+		return errors.New("name must be 1-32 lowercase letters, digits, '-' or '_', starting with a letter")
+	}
+	if p.EnergyCost < 0 {
+		return errors.New("energy_cost must not be negative")
+	}
+	if p.HistoryType == "" {
+		p.HistoryType = p.Name
+	}
+	if p.Permission == "" {
+		p.Permission = PermUpdate
+	}
+	if !validPermission(p.Permission) {
+		return fmt.Errorf("unknown permission %q", p.Permission)
+	}
+	for name, spec := range p.Params {
+		if spec.Type != "number" && spec.Type != "string" && spec.Type != "boolean" {
+			return fmt.Errorf("parameter %s has unknown type %q", name, spec.Type)
+		}
+	}
+	return nil
+}
+
+// validate checks request parameters against the declared ones and the
+// plugin's own validation
+func (p ActionPlugin) validate(robot *Robot, params map[string]interface{}) error {
+	for name := range params {
+		if _, declared := p.Params[name]; !declared {
+			return fmt.Errorf("unknown parameter %s", name)
+		}
+	}
+	for name, spec := range p.Params {
+		value, given := params[name]
+		if !given {
+			if spec.Required {
+				return fmt.Errorf("%s is required", name)
+			}
+			continue
+		}
+		if err := spec.check(name, value); err != nil {
+			return err
+		}
+	}
+	if p.Validate != nil {
+		return p.Validate(robot, params)
+	}
+	return nil
+}
+
+// PluginRegistry holds the custom actions robots can perform, and gives
+// plugins the domain events to react to
+type PluginRegistry struct {
+	plugins map[string]ActionPlugin
+	events  *pubsub.Bus[Event]
+	mutex   sync.RWMutex
+}
+
+// NewPluginRegistry creates an empty registry with a bus no events are
+// published on yet
+func NewPluginRegistry() *PluginRegistry {
+	return &PluginRegistry{plugins: make(map[string]ActionPlugin), events: pubsub.New[Event]()}
+}
+
+// SetEvents replaces the bus plugins subscribe to domain events on
+func (r *PluginRegistry) SetEvents(events *pubsub.Bus[Event]) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = events
+}
+
+// Events returns the bus the domain events of all worlds are published on:
+// the default world's on the subjects EventSubject names, e.g.
+// "robot.robot1.robot_moved", and the tenants' below "tenants.<tenant ID>".
+// Plugins subscribe in RegisterActions, e.g. to "robot.*.item_picked_up" or
+// "tenants.*.>", preferably with pubsub.Async: synchronous handlers run while
+// the world's event bus is locked and must not publish events.
+func (r *PluginRegistry) Events() *pubsub.Bus[Event] {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.events
+}
+
+// Register adds a custom action; names can only be registered once.
+// Actions registered from Go code count as Go plugins.
+func (r *PluginRegistry) Register(action ActionPlugin) error {
+	if action.Source == "" {
+		action.Source = "go"
+	}
+	if err := action.normalize(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if _, exists := r.plugins[action.Name]; exists {
+		return fmt.Errorf("action %s is already registered", action.Name)
+	}
+	r.plugins[action.Name] = action
+	return nil
+}
+
+// Unregister removes a custom action
+func (r *PluginRegistry) Unregister(name string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	_, exists := r.plugins[name]
+	delete(r.plugins, name)
+	return exists
+}
+
+// Get returns a custom action by name
+func (r *PluginRegistry) Get(name string) (ActionPlugin, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	action, exists := r.plugins[name]
+	return action, exists
+}
+
+// List returns all custom actions ordered by name
+func (r *PluginRegistry) List() []ActionPlugin {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	actions := make([]ActionPlugin, 0, len(r.plugins))
+	for _, action := range r.plugins {
+		actions = append(actions, action)
+	}
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Name < actions[j].Name
+	})
+	return actions
+}
+
+// LoadPlugin opens a Go plugin (built with -buildmode=plugin) and calls its
+// exported RegisterActions(*PluginRegistry) error function
+func (r *PluginRegistry) LoadPlugin(path string) error {
+	opened, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+	symbol, err := opened.Lookup("RegisterActions")
+	if err != nil {
+		return err
+	}
+	register, ok := symbol.(func(*PluginRegistry) error)
+	if !ok {
+		return fmt.Errorf("%s: RegisterActions has the wrong signature", path)
+	}
+	return register(r)
+}
+
+// RequireActionPermission checks the permission the requested custom
+// action declares. Unknown actions are left to the handler.
+func (h *RobotHandler) RequireActionPermission() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		action, exists := h.plugins.Get(c.Param("action"))
+		if !exists {
+			c.Next()
+			return
+		}
+		h.RequirePermission(action.Permission)(c)
+	}
+}
+
+// CustomAction performs a registered custom action. The JSON body holds
+// the action's parameters and may be empty.
+func (h *RobotHandler) CustomAction(c *gin.Context) {
+	id := c.Param("id")
+	action, exists := h.plugins.Get(c.Param("action"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown action"})
+		return
+	}
+
+	params := map[string]interface{}{}
+	if err := bindJSON(c, &params); err != nil && !errors.Is(err, io.EOF) {
+		invalidRequest(c, err)
+		return
+	}
+
+	robot, err := h.world(c).GetRobot(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+	if !checkEffects(c, robot, action.HistoryType) {
+		return
+	}
+	if err := action.validate(robot, params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if isDryRun(c) {
+		if robot.Energy < action.EnergyCost {
+			c.JSON(http.StatusConflict, gin.H{"error": "Not enough energy for " + action.Name})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"dry_run":     true,
+			"action":      action.Name,
+			"energy_cost": action.EnergyCost,
+			"energy":      robot.Energy - action.EnergyCost,
+		})
+		return
+	}
+
+	var result interface{}
+	robot, err = h.world(c).UpdateRobot(id, func(robot *Robot) error {
+		if err := robot.CanPerform(action.HistoryType); err != nil {
+			return errActionNotAllowed(err)
+		}
+		if robot.Energy < action.EnergyCost {
+			return errors.New("Not enough energy for " + action.Name)
+		}
+		if action.Apply != nil {
+			applied, err := action.Apply(robot, params)
+			if err != nil {
+				return err
+			}
+			result = applied
+		}
+
+		robot.Energy -= action.EnergyCost
+		appendCommandAction(robot, requestOrigin(c), action.HistoryType, fmt.Sprintf("Performed %s", action.Name),
+			gin.H{"action": action.Name, "params": params, "energy_cost": action.EnergyCost})
+		return nil
+	})
+	if err != nil {
+		updateFailed(c, err)
+		return
+	}
+	h.bus(c).PublishCommand(commandID(c), "custom_action", id, gin.H{"action": action.Name, "params": params, "result": result})
+
+	response := gin.H{
+		"message":     fmt.Sprintf("Action %s performed", action.Name),
+		"action":      action.Name,
+		"energy_cost": action.EnergyCost,
+		"energy":      robot.Energy,
+	}
+	if result != nil {
+		response["result"] = result
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// RegisterAction adds a declarative custom action at runtime
+func (h *RobotHandler) RegisterAction(c *gin.Context) {
+	var action ActionPlugin
+	if err := bindJSON(c, &action); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	action.Source = "api"
+	if err := action.normalize(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.plugins.Register(action); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, action)
+}
+
+// ListActions returns all custom actions
+func (h *RobotHandler) ListActions(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"actions": h.plugins.List()})
+}
+
+// UnregisterAction removes a custom action
+func (h *RobotHandler) UnregisterAction(c *gin.Context) {
+	if !h.plugins.Unregister(c.Param("name")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown action"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
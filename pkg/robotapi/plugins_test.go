@@ -0,0 +1,114 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"aufgabe-2/pkg/pubsub"
+)
+
+func TestCustomActions(t *testing.T) {
+	router, storage, events := setupMapRouter()
+	plugins := NewPluginRegistry()
+	handler := NewRobotHandler(storage)
+	handler.SetEventBus(events)
+	handler.SetPlugins(plugins)
+	router.POST("/robot/:id/custom/:action", handler.CustomAction)
+	router.GET("/admin/plugins", handler.ListActions)
+	router.POST("/admin/plugins", handler.RegisterAction)
+	router.DELETE("/admin/plugins/:name", handler.UnregisterAction)
+
+	maxPower := 100.0
+	err := plugins.Register(ActionPlugin{
+		Name:        "laser",
+		EnergyCost:  7,
+		HistoryType: "weapon",
+		Permission:  PermAttack,
+		Params:      map[string]ParamSpec{"power": {Type: "number", Required: true, Max: &maxPower}},
+		Validate: func(robot *Robot, params map[string]interface{}) error {
+			if robot.Energy < 50 {
+				return errors.New("the laser needs a charged battery")
+			}
+			return nil
+		},
+		Apply: func(robot *Robot, params map[string]interface{}) (interface{}, error) {
+			return gin.H{"burned": params["power"]}, nil
+		},
+	})
+	assert.NoError(t, err)
+	assert.Error(t, plugins.Register(ActionPlugin{Name: "laser"}), "names are unique")
+	assert.Error(t, plugins.Register(ActionPlugin{Name: "Bad Name"}))
+
+	assert.Equal(t, http.StatusNotFound, send(router, "POST", "/robot/robot1/custom/rocket", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/robot/robot1/custom/laser", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/robot/robot1/custom/laser", `{"power": 500}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/robot/robot1/custom/laser", `{"power": 5, "color": "red"}`).Code)
+
+	before, _ := storage.GetRobot("robot1")
+	w := send(router, "POST", "/robot/robot1/custom/laser", `{"power": 40}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, map[string]interface{}{"burned": 40.0}, response["result"])
+
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, before.Energy-7, robot.Energy)
+	last := robot.Actions[len(robot.Actions)-1]
+	assert.Equal(t, "weapon", last.Type)
+	assert.Equal(t, "Performed laser", last.Details)
+	assert.Equal(t, "custom_action", events.Since(0)[0].Type)
+
+	// Declarative actions can be added through the API
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/admin/plugins", `{"name": "wave", "params": {"hand": {"type": "glove"}}}`).Code)
+	assert.Equal(t, http.StatusCreated, send(router, "POST", "/admin/plugins", `{"name": "wave", "energy_cost": 1, "params": {"hand": {"type": "string", "enum": ["left", "right"]}}}`).Code)
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/admin/plugins", `{"name": "wave"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/robot/robot1/custom/wave", `{"hand": "both"}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/custom/wave", `{"hand": "left"}`).Code)
+
+	var listed struct {
+		Actions []ActionPlugin `json:"actions"`
+	}
+	json.Unmarshal(send(router, "GET", "/admin/plugins", "").Body.Bytes(), &listed)
+	assert.Len(t, listed.Actions, 2)
+	assert.Equal(t, "go", listed.Actions[0].Source)
+	assert.Equal(t, "api", listed.Actions[1].Source)
+	assert.Equal(t, "update", listed.Actions[1].Permission)
+
+	assert.Equal(t, http.StatusNoContent, send(router, "DELETE", "/admin/plugins/wave", "").Code)
+	assert.Equal(t, http.StatusNotFound, send(router, "POST", "/robot/robot1/custom/wave", "").Code)
+}
+
+func TestPluginsReactToDomainEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.Tenants = []string{"acme"}
+	server, err := New(config)
+	require.NoError(t, err)
+
+	// A plugin would subscribe in its RegisterActions
+	var subjects []string
+	_, err = server.Handler().plugins.Events().Subscribe("*.*.robot_moved", func(subject string, event Event) {
+		subjects = append(subjects, subject+" "+event.RobotID)
+	})
+	require.NoError(t, err)
+	_, tenantEvents, err := server.Handler().plugins.Events().Channel("tenants.*.>", pubsub.Buffer(4))
+	require.NoError(t, err)
+
+	tenant, _ := server.tenants.Get("acme")
+	server.Events().Publish("robot_moved", "robot1", nil)
+	server.Events().Publish("item_picked_up", "robot1", nil)
+	tenant.Events.Publish("robot_moved", "robot2", nil)
+	server.Events().Publish("weather_changed", "", nil)
+
+	assert.Equal(t, []string{"robot.robot1.robot_moved robot1"}, subjects)
+	event := <-tenantEvents
+	assert.Equal(t, "robot_moved", event.Type)
+	assert.Equal(t, "robot2", event.RobotID)
+	assert.Equal(t, "world.weather_changed", EventSubject(Event{Type: "weather_changed"}))
+}
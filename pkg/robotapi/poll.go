@@ -0,0 +1,112 @@
+package robotapi
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/gin-gonic/gin/render"
+)
+
+// Long-polling waits at most maxPollWait, by default defaultPollWait
+const (
+	defaultPollWait = 25 * time.Second
+	maxPollWait     = 60 * time.Second
+)
+
+// writeEvents answers with a batch of events as JSON, or as MessagePack if
+// the client accepts it, which is more compact for high-frequency telemetry
+func writeEvents(c *gin.Context, body gin.H) {
+	c.Writer.Header().Add("Vary", "Accept")
+	switch c.NegotiateFormat(gin.MIMEJSON, binding.MIMEMSGPACK2, binding.MIMEMSGPACK) {
+	case binding.MIMEMSGPACK2, binding.MIMEMSGPACK:
+		c.Render(http.StatusOK, render.MsgPack{Data: body})
+	default:
+		c.JSON(http.StatusOK, body)
+	}
+}
+
+// pollWait reads how long a long poll may block from the wait parameter. If
+// it is invalid, the request is answered with 400 and ok is false.
+func pollWait(c *gin.Context) (wait time.Duration, ok bool) {
+	wait = defaultPollWait
+	if value := c.Query("wait"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > maxPollWait {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "wait must be between 0 and " + strconv.Itoa(int(maxPollWait.Seconds())) + " seconds"})
+			return 0, false
+		}
+		wait = time.Duration(seconds) * time.Second
+	}
+	return wait, true
+}
+
+// PollEvents returns the events of a robot and world-wide events published
+// after the since sequence number. Without since only new events are
+// returned. If there are none, the request blocks until one is published or
+// wait seconds have passed; the response then has an empty event list.
+//
+// With snapshot=true it answers at once with the complete world instead, and
+// next is the sequence number the world includes all events up to. Events
+// the log no longer holds are a gap the client cannot bridge, so a since
+// before them is answered with 410 and the client starts over from a new
+// snapshot.
+func (h *RobotHandler) PollEvents(c *gin.Context) {
+	id := c.Param("id")
+	if _, err := h.world(c).GetRobot(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	if c.Query("snapshot") == "true" {
+		if c.Query("since") != "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "snapshot cannot be combined with since"})
+			return
+		}
+		// Events are published after their changes, so every event up to
+		// the sequence read first is part of the snapshot
+		next := h.bus(c).Sequence()
+		writeEvents(c, gin.H{"snapshot": h.world(c).ChangesSince(0), "events": []Event{}, "next": next})
+		return
+	}
+
+	since := h.bus(c).Sequence()
+	if value := c.Query("since"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an event sequence number"})
+			return
+		}
+		since = parsed
+	}
+	if !h.bus(c).Retained(since) {
+		c.JSON(http.StatusGone, gin.H{"error": "Events after since are no longer available, fetch a new snapshot"})
+		return
+	}
+
+	wait, ok := pollWait(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), wait)
+	defer cancel()
+	events := h.bus(c).Wait(ctx, since, func(event Event) bool {
+		return event.RobotID == id || event.RobotID == ""
+	})
+
+	// The log may have moved on past since while waiting
+	if !h.bus(c).Retained(since) {
+		c.JSON(http.StatusGone, gin.H{"error": "Events after since are no longer available, fetch a new snapshot"})
+		return
+	}
+
+	next := since
+	if len(events) > 0 {
+		next = events[len(events)-1].Sequence
+	}
+	writeEvents(c, gin.H{"events": events, "next": next})
+}
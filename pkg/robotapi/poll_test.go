@@ -0,0 +1,155 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ugorji/go/codec"
+)
+
+type pollResponse struct {
+	Events []Event `json:"events"`
+	Next   int64   `json:"next"`
+}
+
+func setupPollRouter() (*gin.Engine, *EventBus) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	events := NewEventBus()
+	handler.SetEventBus(events)
+
+	router := gin.New()
+	router.GET("/robot/:id/events/poll", handler.PollEvents)
+	return router, events
+}
+
+func poll(router *gin.Engine, query string) (int, pollResponse) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/robot/robot1/events/poll"+query, nil)
+	router.ServeHTTP(w, req)
+	var response pollResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+func TestPollEventsReturnsLoggedEvents(t *testing.T) {
+	router, events := setupPollRouter()
+	events.Publish("robot_moved", "robot1", nil)
+	events.Publish("robot_moved", "robot2", nil)
+	events.Publish("weather_changed", "", nil)
+
+	code, response := poll(router, "?since=0&wait=0")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response.Events, 2, "other robots' events are filtered out")
+	assert.Equal(t, int64(3), response.Next)
+}
+
+func TestPollEventsBlocksUntilEvent(t *testing.T) {
+	router, events := setupPollRouter()
+
+	go func() {
+		// Publish once the poll has subscribed to the bus
+		assert.Eventually(t, func() bool { return len(events.Subscribers()) == 1 }, time.Second, time.Millisecond)
+		events.Publish("robot_moved", "robot2", nil)
+		events.Publish("robot_attacked", "robot1", nil)
+	}()
+
+	start := time.Now()
+	code, response := poll(router, "?wait=5")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Len(t, response.Events, 1)
+	assert.Equal(t, "robot_attacked", response.Events[0].Type)
+	assert.Equal(t, int64(2), response.Next)
+}
+
+func TestPollEventsTimesOut(t *testing.T) {
+	router, _ := setupPollRouter()
+
+	code, response := poll(router, "?wait=0")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, response.Events)
+	assert.Equal(t, int64(0), response.Next)
+
+	code, _ = poll(router, "?wait=3600")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestPollEventsAsMessagePack(t *testing.T) {
+	router, events := setupPollRouter()
+	events.Publish("robot_moved", "robot1", gin.H{"x": 1.5, "y": 2})
+
+	for _, accept := range []string{"application/msgpack", "application/x-msgpack"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/robot/robot1/events/poll?since=0&wait=0", nil)
+		req.Header.Set("Accept", accept)
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/msgpack; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "Accept", w.Header().Get("Vary"))
+
+		var response pollResponse
+		require.NoError(t, codec.NewDecoderBytes(w.Body.Bytes(), &codec.MsgpackHandle{}).Decode(&response))
+		require.Len(t, response.Events, 1)
+		assert.Equal(t, "robot_moved", response.Events[0].Type)
+		assert.Equal(t, "robot1", response.Events[0].RobotID)
+		assert.Equal(t, int64(1), response.Next)
+	}
+
+	// JSON stays the default
+	code, response := poll(router, "?since=0&wait=0")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response.Events, 1)
+}
+
+func TestPollEventsSnapshot(t *testing.T) {
+	router, events := setupPollRouter()
+	events.Publish("robot_moved", "robot1", nil)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/robot/robot1/events/poll?snapshot=true", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Snapshot WorldChanges `json:"snapshot"`
+		Events   []Event      `json:"events"`
+		Next     int64        `json:"next"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Snapshot.Robots, 2)
+	assert.Len(t, response.Snapshot.Items, 5)
+	assert.Empty(t, response.Events)
+	assert.Equal(t, int64(1), response.Next)
+
+	// The deltas continue from next
+	events.Publish("robot_moved", "robot1", nil)
+	code, deltas := poll(router, "?since=1&wait=0")
+	assert.Equal(t, http.StatusOK, code)
+	require.Len(t, deltas.Events, 1)
+	assert.Equal(t, int64(2), deltas.Events[0].Sequence)
+
+	code, _ = poll(router, "?snapshot=true&since=1")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestPollEventsDetectsGaps(t *testing.T) {
+	router, events := setupPollRouter()
+	for i := 0; i <= maxEventLog; i++ {
+		events.Publish("robot_moved", "robot1", nil)
+	}
+
+	// Event 1 has left the log
+	code, _ := poll(router, "?since=0&wait=0")
+	assert.Equal(t, http.StatusGone, code)
+	code, response := poll(router, "?since=1&wait=0")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, response.Events, maxEventLog)
+}
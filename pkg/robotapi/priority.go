@@ -0,0 +1,270 @@
+package robotapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultPriorityAging is how long a command waits before it moves up a lane
+const defaultPriorityAging = time.Second
+
+// Priority lanes of commands and tasks
+const (
+	PriorityLow    CommandPriority = "low"
+	PriorityNormal CommandPriority = "normal"
+	PriorityHigh   CommandPriority = "high"
+)
+
+// CommandPriority is the lane of a command or task. Of the commands waiting
+// for a robot, those in higher lanes run first; a running command is never
+// preempted.
+type CommandPriority string
+
+// NewCommandPriority returns the lane with the given name; "" is normal
+func NewCommandPriority(name string) (CommandPriority, error) {
+	switch priority := CommandPriority(name); priority {
+	case "":
+		return PriorityNormal, nil
+	case PriorityLow, PriorityNormal, PriorityHigh:
+		return priority, nil
+	default:
+		return "", fmt.Errorf("unknown priority %q", name)
+	}
+}
+
+// rank orders the lanes
+func (p CommandPriority) rank() int {
+	switch p {
+	case PriorityLow:
+		return 0
+	case PriorityHigh:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// DefaultCommandPriorities are the lanes of action types without a
+// configured one: defending cannot wait behind routine work
+func DefaultCommandPriorities() map[string]CommandPriority {
+	return map[string]CommandPriority{"custom:defend": PriorityHigh}
+}
+
+// ParseCommandPriorities reads lanes given as action=priority pairs, e.g.
+// "attack=high,maintenance=low", on top of the defaults
+func ParseCommandPriorities(config string) (map[string]CommandPriority, error) {
+	priorities := DefaultCommandPriorities()
+	for _, pair := range strings.Split(config, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		action, name, found := strings.Cut(pair, "=")
+		priority, err := NewCommandPriority(name)
+		if !found || action == "" || name == "" || err != nil {
+			return nil, errors.New("priorities must be given as action=priority pairs of low, normal or high")
+		}
+		priorities[action] = priority
+	}
+	return priorities, nil
+}
+
+// queuedCommand is a command waiting for its robot
+type queuedCommand struct {
+	priority CommandPriority
+	queued   time.Time
+	ready    chan struct{} // closed when it is the command's turn
+}
+
+// robotLanes are the commands running and waiting for one robot
+type robotLanes struct {
+	busy    bool
+	waiting []*queuedCommand
+}
+
+// CommandLanes runs the commands for each robot one at a time, and of the
+// waiting ones those in the highest lane first. So that low lanes do not
+// starve while higher ones stay busy, a command moves up a lane for every
+// aging interval it waited.
+type CommandLanes struct {
+	priorities map[string]CommandPriority // by action type
+	aging      time.Duration
+	clock      Clock
+	robots     map[string]*robotLanes
+	mutex      sync.Mutex
+}
+
+// NewCommandLanes creates the lanes; priorities map action types to lanes
+func NewCommandLanes(priorities map[string]CommandPriority, aging time.Duration, clock Clock) *CommandLanes {
+	return &CommandLanes{priorities: priorities, aging: aging, clock: clock, robots: make(map[string]*robotLanes)}
+}
+
+// effectiveRank is the rank of a waiting command including its aging
+func (l *CommandLanes) effectiveRank(command *queuedCommand, now time.Time) int {
+	rank := command.priority.rank()
+	if l.aging > 0 {
+		rank += int(now.Sub(command.queued) / l.aging)
+	}
+	return rank
+}
+
+// Acquire waits until it is the command's turn on the robot and returns the
+// function that ends the turn. It fails if ctx is done first.
+func (l *CommandLanes) Acquire(ctx context.Context, robotID string, priority CommandPriority) (func(), error) {
+	l.mutex.Lock()
+	robot, exists := l.robots[robotID]
+	if !exists {
+		robot = &robotLanes{}
+		l.robots[robotID] = robot
+	}
+	release := func() { l.release(robotID) }
+	if !robot.busy {
+		robot.busy = true
+		l.mutex.Unlock()
+		return release, nil
+	}
+	command := &queuedCommand{priority: priority, queued: l.clock.Now(), ready: make(chan struct{})}
+	robot.waiting = append(robot.waiting, command)
+	l.mutex.Unlock()
+
+	select {
+	case <-command.ready:
+		return release, nil
+	case <-ctx.Done():
+		l.mutex.Lock()
+		select {
+		case <-command.ready:
+			l.mutex.Unlock()
+			l.release(robotID) // the turn came just now, pass it on
+		default:
+			robot.waiting = removeCommand(robot.waiting, command)
+			l.mutex.Unlock()
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// TryAcquire takes the robot's turn if no command runs or waits for it and
+// returns the function that ends the turn
+func (l *CommandLanes) TryAcquire(robotID string) (func(), bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if _, exists := l.robots[robotID]; exists {
+		return nil, false
+	}
+	l.robots[robotID] = &robotLanes{busy: true}
+	return func() { l.release(robotID) }, true
+}
+
+// removeCommand drops a command from a queue
+func removeCommand(queue []*queuedCommand, command *queuedCommand) []*queuedCommand {
+	for i, queued := range queue {
+		if queued == command {
+			return append(queue[:i], queue[i+1:]...)
+		}
+	}
+	return queue
+}
+
+// release ends the running command's turn and gives it to the waiting
+// command with the highest rank, the longest waiting among equals
+func (l *CommandLanes) release(robotID string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	robot := l.robots[robotID]
+	if len(robot.waiting) == 0 {
+		delete(l.robots, robotID)
+		return
+	}
+	now := l.clock.Now()
+	next := 0
+	for i, command := range robot.waiting[1:] {
+		if l.effectiveRank(command, now) > l.effectiveRank(robot.waiting[next], now) {
+			next = i + 1
+		}
+	}
+	command := robot.waiting[next]
+	robot.waiting = append(robot.waiting[:next], robot.waiting[next+1:]...)
+	close(command.ready)
+}
+
+// Waiting returns the number of commands waiting per robot
+func (l *CommandLanes) Waiting() map[string]int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	waiting := make(map[string]int)
+	for robotID, robot := range l.robots {
+		if len(robot.waiting) > 0 {
+			waiting[robotID] = len(robot.waiting)
+		}
+	}
+	return waiting
+}
+
+// commandPriority is the lane of the request's command: the one asked for in
+// X-Command-Priority, or the one of its action type. Only admins pick the
+// lane, anybody else would just put their own commands first.
+func (l *CommandLanes) commandPriority(c *gin.Context) (CommandPriority, error) {
+	principal := currentPrincipal(c)
+	if name := c.GetHeader("X-Command-Priority"); name != "" && principal != nil && principal.HasRole(RoleAdmin) {
+		return NewCommandPriority(name)
+	}
+	if priority, exists := l.priorities[commandAction(c)]; exists {
+		return priority, nil
+	}
+	return PriorityNormal, nil
+}
+
+// Schedule is the middleware running the commands of the robot in the :id
+// path parameter in their lanes. Reads are not queued.
+func (l *CommandLanes) Schedule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || isDryRun(c) {
+			c.Next()
+			return
+		}
+		priority, err := l.commandPriority(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid X-Command-Priority, use low, normal or high"})
+			return
+		}
+		c.Set("priority", priority)
+		release, err := l.Acquire(c.Request.Context(), currentTenant(c)+"/"+c.Param("id"), priority)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Command was cancelled while waiting for the robot"})
+			return
+		}
+		defer release()
+		c.Next()
+	}
+}
+
+// requestPriority is the lane Schedule gave the request, normal for
+// requests it did not schedule. Tasks started by the request keep it.
+func requestPriority(c *gin.Context) CommandPriority {
+	if priority, exists := c.Get("priority"); exists {
+		return priority.(CommandPriority)
+	}
+	return PriorityNormal
+}
+
+// acquireLane waits for the robot's turn in the lane of the request, like
+// Schedule does for requests. Tasks take a turn for every step, so they do
+// not hold the robot between steps and higher lanes can go first. Without
+// lanes every step runs right away.
+func (h *RobotHandler) acquireLane(ctx context.Context, c *gin.Context, robotID string) (func(), error) {
+	if h.lanes == nil {
+		return func() {}, nil
+	}
+	return h.lanes.Acquire(ctx, currentTenant(c)+"/"+robotID, requestPriority(c))
+}
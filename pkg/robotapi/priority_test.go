@@ -0,0 +1,236 @@
+package robotapi
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCommandPriorities(t *testing.T) {
+	priorities, err := ParseCommandPriorities("attack=high, maintenance=low")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]CommandPriority{
+		"attack":        PriorityHigh,
+		"maintenance":   PriorityLow,
+		"custom:defend": PriorityHigh,
+	}, priorities)
+
+	for _, config := range []string{"attack", "attack=urgent", "=high", "attack="} {
+		_, err := ParseCommandPriorities(config)
+		assert.Error(t, err, config)
+	}
+}
+
+// queueCommands acquires the robot first, then queues a command per
+// priority in order and returns the order the queued ones ran in
+func queueCommands(t *testing.T, lanes *CommandLanes, between func(), priorities ...CommandPriority) []CommandPriority {
+	release, err := lanes.Acquire(context.Background(), "robot1", PriorityNormal)
+	require.NoError(t, err)
+
+	var order []CommandPriority
+	var mutex sync.Mutex
+	var done sync.WaitGroup
+	for i, priority := range priorities {
+		done.Add(1)
+		go func(priority CommandPriority) {
+			defer done.Done()
+			release, err := lanes.Acquire(context.Background(), "robot1", priority)
+			assert.NoError(t, err)
+			mutex.Lock()
+			order = append(order, priority)
+			mutex.Unlock()
+			release()
+		}(priority)
+		require.Eventually(t, func() bool { return lanes.Waiting()["robot1"] == i+1 }, time.Second, time.Millisecond)
+		between()
+	}
+
+	release()
+	done.Wait()
+	assert.Empty(t, lanes.Waiting())
+	return order
+}
+
+func TestCommandLanesRunHigherPrioritiesFirst(t *testing.T) {
+	lanes := NewCommandLanes(nil, 0, SystemClock{})
+	order := queueCommands(t, lanes, func() {}, PriorityLow, PriorityNormal, PriorityLow, PriorityHigh)
+	assert.Equal(t, []CommandPriority{PriorityHigh, PriorityNormal, PriorityLow, PriorityLow}, order)
+}
+
+func TestCommandLanesAgeWaitingCommands(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	lanes := NewCommandLanes(nil, time.Second, clock)
+
+	// The low command waited three seconds, which outranks a fresh high one
+	order := queueCommands(t, lanes, func() { clock.Advance(3 * time.Second) }, PriorityLow, PriorityHigh)
+	assert.Equal(t, []CommandPriority{PriorityLow, PriorityHigh}, order)
+
+	// Without aging it would starve
+	lanes = NewCommandLanes(nil, 0, clock)
+	order = queueCommands(t, lanes, func() { clock.Advance(3 * time.Second) }, PriorityLow, PriorityHigh)
+	assert.Equal(t, []CommandPriority{PriorityHigh, PriorityLow}, order)
+}
+
+func TestCommandLanesAreCancelled(t *testing.T) {
+	lanes := NewCommandLanes(nil, 0, SystemClock{})
+	release, err := lanes.Acquire(context.Background(), "robot1", PriorityNormal)
+	require.NoError(t, err)
+
+	// Other robots do not wait
+	other, err := lanes.Acquire(context.Background(), "robot2", PriorityNormal)
+	require.NoError(t, err)
+	other()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = lanes.Acquire(ctx, "robot1", PriorityHigh)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, lanes.Waiting())
+
+	release()
+	release, err = lanes.Acquire(context.Background(), "robot1", PriorityLow)
+	require.NoError(t, err)
+	release()
+}
+
+func sendWithHeader(router *gin.Engine, method, path, body, header, value string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(header, value)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestCommandPriorityHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+
+	// Without an admin behind it the header is ignored
+	w := sendWithHeader(router, "POST", "/robot/robot1/move", `{"direction": "up"}`, "X-Command-Priority", "urgent")
+	assert.Equal(t, 200, w.Code)
+
+	// Background tasks run in the low lane
+	task := server.handler.tasks.CreateWithPriority("test", PriorityLow)
+	assert.Equal(t, PriorityLow, task.Priority)
+	assert.Equal(t, PriorityNormal, server.handler.tasks.Create("test").Priority)
+	w = adminRequest(router, "GET", "/admin/tasks?priority=low", "")
+	assert.Contains(t, w.Body.String(), task.ID)
+	assert.NotContains(t, w.Body.String(), `"priority":"normal"`)
+}
+
+func TestOnlyAdminsPickTheLane(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	lanes := NewCommandLanes(DefaultCommandPriorities(), 0, NewFakeClock(time.Unix(0, 0)))
+	priority := func(principal *Principal, header string) (CommandPriority, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("POST", "/robot/robot1/move", nil)
+		c.Request.Header.Set("X-Command-Priority", header)
+		c.Params = gin.Params{{Key: "id", Value: "robot1"}}
+		if principal != nil {
+			c.Set("principal", principal)
+		}
+		return lanes.commandPriority(c)
+	}
+
+	for _, principal := range []*Principal{nil, {ID: "alice", Roles: []string{RoleUser}}} {
+		got, err := priority(principal, "high")
+		require.NoError(t, err)
+		assert.Equal(t, PriorityNormal, got, "clients cannot raise their own commands")
+	}
+
+	admin := &Principal{ID: "root", Roles: []string{RoleUser, RoleAdmin}}
+	got, err := priority(admin, "high")
+	require.NoError(t, err)
+	assert.Equal(t, PriorityHigh, got)
+	_, err = priority(admin, "urgent")
+	assert.Error(t, err)
+}
+
+func TestTasksAreListedByLane(t *testing.T) {
+	clock := NewFakeClock(time.Unix(0, 0))
+	tasks := NewTaskStore()
+	tasks.SetClock(clock)
+	created := func(priority CommandPriority) Task {
+		clock.Advance(time.Second)
+		return tasks.CreateWithPriority("test", priority)
+	}
+	finished := created(PriorityHigh)
+	tasks.Update(finished.ID, func(task *Task) { task.Status = TaskSucceeded })
+	low := created(PriorityLow)
+	high := created(PriorityHigh)
+	normal := created(PriorityNormal)
+	newerNormal := created(PriorityNormal)
+
+	ids := []string{}
+	for _, task := range tasks.List() {
+		ids = append(ids, task.ID)
+	}
+	assert.Equal(t, []string{high.ID, newerNormal.ID, normal.ID, low.ID, finished.ID}, ids)
+}
+
+func TestBatchStepsTakeTheLaneOfTheirRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.CommandPriorities = map[string]CommandPriority{"batch": PriorityHigh}
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+	before, _ := server.Storage().GetRobot("robot1")
+
+	// The task keeps the lane of the request
+	hold, err := server.lanes.Acquire(context.Background(), "/robot1", PriorityNormal)
+	require.NoError(t, err)
+	var w *httptest.ResponseRecorder
+	done := make(chan struct{})
+	go func() {
+		w = send(router, "POST", "/robot/robot1/batch", `{"steps": [{"action": "move", "direction": "up"}]}`)
+		close(done)
+	}()
+
+	// The request waits for its turn, then answers at once; its step
+	// waits for a turn of its own after the request gave the robot back
+	require.Eventually(t, func() bool { return server.lanes.Waiting()["/robot1"] == 1 }, time.Second, time.Millisecond)
+	hold()
+	<-done
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	tasks := server.handler.tasks.List()
+	require.Len(t, tasks, 1)
+	assert.Equal(t, PriorityHigh, tasks[0].Priority)
+	assert.Eventually(t, func() bool {
+		task, _ := server.handler.tasks.Get(tasks[0].ID)
+		return task.Status == TaskSucceeded
+	}, time.Second, time.Millisecond)
+	after, _ := server.Storage().GetRobot("robot1")
+	assert.Equal(t, before.Position.Y+1, after.Position.Y)
+
+	// A step waits for its turn while another command holds the robot
+	hold, err = server.lanes.Acquire(context.Background(), "/robot1", PriorityNormal)
+	require.NoError(t, err)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("POST", "/robot/robot1/batch", nil)
+	c.Set("priority", PriorityHigh)
+	reported := make(chan BatchReport)
+	go func() {
+		reported <- server.handler.runBatch(context.Background(), c, "robot1", []BatchStep{{Action: "move", Direction: "up"}})
+	}()
+	require.Eventually(t, func() bool { return server.lanes.Waiting()["/robot1"] == 1 }, time.Second, time.Millisecond)
+	moved, _ := server.Storage().GetRobot("robot1")
+	assert.Equal(t, after.Position, moved.Position)
+	hold()
+	assert.Equal(t, TaskSucceeded, (<-reported).Status)
+	moved, _ = server.Storage().GetRobot("robot1")
+	assert.Equal(t, after.Position.Y+1, moved.Position.Y)
+}
@@ -0,0 +1,590 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProgramTask is the type of the tasks program runs are tracked as
+const ProgramTask = "program"
+
+// Limits of robot programs
+const (
+	maxProgramInstructions = 200
+	defaultProgramRunSteps = 100
+	maxProgramRunSteps     = 1000
+)
+
+// Program states
+const (
+	ProgramReady  = "ready"  // the next instruction can be executed
+	ProgramHalted = "halted" // HALT or the end of the program was reached
+	ProgramFailed = "failed" // an instruction failed; stepping retries it
+)
+
+// ErrProgramBusy is returned when a program is executed or replaced while it runs
+var ErrProgramBusy = errors.New("the program is running")
+
+// errProgramNotFound is returned for robots without a program
+var errProgramNotFound = errors.New("no program uploaded")
+
+var (
+	labelPattern     = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	conditionPattern = regexp.MustCompile(`^(?i:IF)\s+([A-Za-z]+)\s*(<=|>=|==|!=|<|>)\s*(-?\d+)\s+(?i:GOTO)\s+(\S+)$`)
+)
+
+// turns maps a facing and a turn to the new facing
+var turns = map[string]map[string]string{
+	"north": {"left": "west", "right": "east", "around": "south"},
+	"east":  {"left": "north", "right": "south", "around": "west"},
+	"south": {"left": "east", "right": "west", "around": "north"},
+	"west":  {"left": "south", "right": "north", "around": "east"},
+}
+
+// forwards maps a facing to the direction MOVE without argument moves in
+var forwards = map[string]string{"north": "up", "east": "right", "south": "down", "west": "left"}
+
+// programVariables are the robot values IF conditions can compare
+var programVariables = map[string]func(robot *Robot) int{
+	"energy": func(robot *Robot) int { return robot.Energy },
+	"x":      func(robot *Robot) int { return robot.Position.X },
+	"y":      func(robot *Robot) int { return robot.Position.Y },
+	"z":      func(robot *Robot) int { return robot.Position.Z },
+	"items":  func(robot *Robot) int { return len(robot.Inventory) },
+}
+
+// Instruction is a parsed instruction of a robot program
+type Instruction struct {
+	Line int      `json:"line"` // in the source, starting at 1
+	Op   string   `json:"op"`
+	Args []string `json:"args,omitempty"`
+
+	target    int    // index of the instruction GOTO and IF jump to
+	variable  string // compared by IF
+	operator  string
+	threshold int
+}
+
+// Program is a robot's uploaded program and how far its execution got
+type Program struct {
+	RobotID      string        `json:"robot_id"`
+	Source       string        `json:"source"`
+	Instructions []Instruction `json:"instructions"`
+	PC           int           `json:"pc"`    // index of the next instruction
+	Steps        int           `json:"steps"` // instructions executed since the upload
+	Status       string        `json:"status"`
+	Error        string        `json:"error,omitempty"`
+	UpdatedAt    time.Time     `json:"updated_at"`
+}
+
+// ProgramStep is the outcome of executing one instruction
+type ProgramStep struct {
+	PC     int    `json:"pc"`
+	Line   int    `json:"line"`
+	Op     string `json:"op"`
+	Jumped bool   `json:"jumped,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// ParseProgram parses the source of a robot program, one instruction per
+// line:
+//
+//	MOVE [up|down|left|right|ascend|descend]  moves, forward if no direction is given
+//	TURN left|right|around                    changes the facing
+//	PICKUP <item>, PUTDOWN <item>             picks up or puts down an item
+//	WAIT                                      does nothing for a step
+//	GOTO <label|line>                         continues elsewhere
+//	IF <variable> <op> <number> GOTO <target> jumps if the robot's energy, x, y, z or items compare true
+//	HALT                                      ends the program
+//
+// Keywords are case-insensitive, "#" starts a comment and "name:" labels the
+// next instruction.
+func ParseProgram(source string) ([]Instruction, error) {
+	instructions := []Instruction{}
+	labels := make(map[string]int)
+	lines := make(map[int]int) // source line -> instruction index
+	targets := make(map[int]string)
+
+	for number, line := range strings.Split(source, "\n") {
+		number++
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+		line = strings.TrimSpace(line)
+		if label, rest, found := strings.Cut(line, ":"); found {
+			label = strings.TrimSpace(label)
+			if !labelPattern.MatchString(label) {
+				return nil, fmt.Errorf("line %d: invalid label %q", number, label)
+			}
+			if _, exists := labels[label]; exists {
+				return nil, fmt.Errorf("line %d: label %s is defined twice", number, label)
+			}
+			labels[label] = len(instructions)
+			line = strings.TrimSpace(rest)
+		}
+		if line == "" {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		instruction := Instruction{Line: number, Op: strings.ToUpper(fields[0]), Args: fields[1:]}
+		arity := func(min, max int) error {
+			if len(instruction.Args) < min || len(instruction.Args) > max {
+				return fmt.Errorf("line %d: wrong number of arguments for %s", number, instruction.Op)
+			}
+			return nil
+		}
+		var err error
+		switch instruction.Op {
+		case "MOVE":
+			if err = arity(0, 1); err == nil && len(instruction.Args) == 1 {
+				instruction.Args[0] = strings.ToLower(instruction.Args[0])
+				if _, ok := directions[instruction.Args[0]]; !ok {
+					err = fmt.Errorf("line %d: invalid direction %q", number, instruction.Args[0])
+				}
+			}
+		case "TURN":
+			if err = arity(1, 1); err == nil {
+				instruction.Args[0] = strings.ToLower(instruction.Args[0])
+				if _, ok := turns["north"][instruction.Args[0]]; !ok {
+					err = fmt.Errorf("line %d: turn left, right or around", number)
+				}
+			}
+		case "PICKUP", "PUTDOWN":
+			err = arity(1, 1)
+		case "WAIT", "HALT":
+			err = arity(0, 0)
+		case "GOTO":
+			if err = arity(1, 1); err == nil {
+				targets[len(instructions)] = instruction.Args[0]
+			}
+		case "IF":
+			match := conditionPattern.FindStringSubmatch(line)
+			if match == nil {
+				err = fmt.Errorf("line %d: expected IF <variable> <op> <number> GOTO <target>", number)
+				break
+			}
+			instruction.variable = strings.ToLower(match[1])
+			if _, ok := programVariables[instruction.variable]; !ok {
+				err = fmt.Errorf("line %d: unknown variable %q", number, match[1])
+				break
+			}
+			instruction.operator = match[2]
+			instruction.threshold, _ = strconv.Atoi(match[3])
+			instruction.Args = []string{instruction.variable, match[2], match[3], match[4]}
+			targets[len(instructions)] = match[4]
+		default:
+			err = fmt.Errorf("line %d: unknown instruction %s", number, fields[0])
+		}
+		if err != nil {
+			return nil, err
+		}
+		lines[number] = len(instructions)
+		instructions = append(instructions, instruction)
+		if len(instructions) > maxProgramInstructions {
+			return nil, fmt.Errorf("programs have at most %d instructions", maxProgramInstructions)
+		}
+	}
+
+	for index := range instructions {
+		target, jumps := targets[index]
+		if !jumps {
+			continue
+		}
+		jump, isLabel := labels[target]
+		if !isLabel {
+			line, err := strconv.Atoi(target)
+			if jump, isLabel = lines[line]; err != nil || !isLabel {
+				return nil, fmt.Errorf("line %d: unknown jump target %s", instructions[index].Line, target)
+			}
+		}
+		instructions[index].target = jump
+	}
+	return instructions, nil
+}
+
+// holds evaluates the condition of an IF instruction for the robot
+func (in Instruction) holds(robot *Robot) bool {
+	value := programVariables[in.variable](robot)
+	switch in.operator {
+	case "<":
+		return value < in.threshold
+	case "<=":
+		return value <= in.threshold
+	case ">":
+		return value > in.threshold
+	case ">=":
+		return value >= in.threshold
+	case "==":
+		return value == in.threshold
+	}
+	return value != in.threshold
+}
+
+// ProgramStore keeps the programs of the robots of all worlds
+type ProgramStore struct {
+	programs map[string]*Program // by tenant and robot ID
+	busy     map[string]bool     // programs being executed
+	mutex    sync.Mutex
+}
+
+// NewProgramStore creates an empty store
+func NewProgramStore() *ProgramStore {
+	return &ProgramStore{programs: make(map[string]*Program), busy: make(map[string]bool)}
+}
+
+func programKey(tenant, robotID string) string {
+	return tenant + "/" + robotID
+}
+
+// Get returns a copy of a robot's program
+func (s *ProgramStore) Get(tenant, robotID string) (Program, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	program, exists := s.programs[programKey(tenant, robotID)]
+	if !exists {
+		return Program{}, false
+	}
+	return *program, true
+}
+
+// Put replaces a robot's program, unless the old one is running
+func (s *ProgramStore) Put(tenant string, program Program) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := programKey(tenant, program.RobotID)
+	if s.busy[key] {
+		return ErrProgramBusy
+	}
+	s.programs[key] = &program
+	return nil
+}
+
+// Delete removes a robot's program, unless it is running
+func (s *ProgramStore) Delete(tenant, robotID string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := programKey(tenant, robotID)
+	if s.busy[key] {
+		return false, ErrProgramBusy
+	}
+	_, exists := s.programs[key]
+	delete(s.programs, key)
+	return exists, nil
+}
+
+// acquire returns a copy of a robot's program for execution; it cannot be
+// executed or replaced elsewhere until it is released
+func (s *ProgramStore) acquire(tenant, robotID string) (Program, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := programKey(tenant, robotID)
+	program, exists := s.programs[key]
+	if !exists {
+		return Program{}, errProgramNotFound
+	}
+	if s.busy[key] {
+		return Program{}, ErrProgramBusy
+	}
+	s.busy[key] = true
+	return *program, nil
+}
+
+// release stores the state of an acquired program
+func (s *ProgramStore) release(tenant string, program Program) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := programKey(tenant, program.RobotID)
+	s.programs[key] = &program
+	delete(s.busy, key)
+}
+
+// turnRobot changes the facing of a robot
+func (h *RobotHandler) turnRobot(c *gin.Context, id, turn string) error {
+	robot, err := h.world(c).UpdateRobot(id, func(robot *Robot) error {
+		if err := robot.CanPerform("turn"); err != nil {
+			return errActionNotAllowed(err)
+		}
+		from := robot.Direction
+		if _, known := turns[from]; !known {
+			from = "north"
+		}
+		robot.Direction = turns[from][turn]
+		appendCommandAction(robot, requestOrigin(c), "turn", fmt.Sprintf("Turned %s to face %s", turn, robot.Direction),
+			gin.H{"from": from, "to": robot.Direction})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	h.bus(c).PublishCommand(commandID(c), "robot_turned", id, gin.H{"direction": robot.Direction})
+	return nil
+}
+
+// execute runs the program's next instruction and advances it. A failed
+// instruction leaves the program counter where it is.
+func (h *RobotHandler) execute(c *gin.Context, program *Program) ProgramStep {
+	if program.PC >= len(program.Instructions) {
+		program.Status = ProgramHalted
+		return ProgramStep{PC: program.PC, Op: "HALT"}
+	}
+	in := program.Instructions[program.PC]
+	step := ProgramStep{PC: program.PC, Line: in.Line, Op: in.Op}
+	next := program.PC + 1
+
+	var err error
+	switch in.Op {
+	case "MOVE":
+		// Explicit directions and items were validated on upload
+		if len(in.Args) == 1 {
+			err = h.runStep(c, program.RobotID, BatchStep{Action: "move", Direction: in.Args[0]}, nil)
+			break
+		}
+		var robot *Robot
+		if robot, err = h.world(c).GetRobot(program.RobotID); err == nil {
+			direction, known := forwards[robot.Direction]
+			if !known {
+				direction = forwards["north"]
+			}
+			err = h.runStep(c, program.RobotID, BatchStep{Action: "move", Direction: direction}, nil)
+		}
+	case "PICKUP", "PUTDOWN":
+		err = h.runStep(c, program.RobotID, BatchStep{Action: strings.ToLower(in.Op), Item: in.Args[0]}, nil)
+	case "TURN":
+		err = h.turnRobot(c, program.RobotID, in.Args[0])
+	case "GOTO":
+		next = in.target
+		step.Jumped = true
+	case "IF":
+		var robot *Robot
+		if robot, err = h.world(c).GetRobot(program.RobotID); err == nil && in.holds(robot) {
+			next = in.target
+			step.Jumped = true
+		}
+	case "HALT":
+		next = len(program.Instructions)
+	}
+
+	program.UpdatedAt = h.world(c).Clock().Now().UTC()
+	if err != nil {
+		program.Status = ProgramFailed
+		program.Error = err.Error()
+		step.Error = err.Error()
+		return step
+	}
+	program.Steps++
+	program.PC = next
+	program.Error = ""
+	program.Status = ProgramReady
+	if program.PC >= len(program.Instructions) {
+		program.Status = ProgramHalted
+	}
+	return step
+}
+
+// UploadProgram replaces a robot's program and resets its execution. The
+// source is sent as text/plain or as {"source": "..."}.
+func (h *RobotHandler) UploadProgram(c *gin.Context) {
+	id := c.Param("id")
+	var request struct {
+		Source string `json:"source" binding:"required"`
+	}
+	if strings.HasPrefix(c.ContentType(), "text/plain") {
+		body, err := readBody(c)
+		if err != nil {
+			invalidRequest(c, err)
+			return
+		}
+		request.Source = string(body)
+	} else if err := bindJSON(c, &request); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if _, err := h.world(c).GetRobot(id); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	instructions, err := ParseProgram(request.Source)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid program: " + err.Error()})
+		return
+	}
+	for _, in := range instructions {
+		var step BatchStep
+		switch in.Op {
+		case "MOVE":
+			if len(in.Args) == 0 {
+				continue
+			}
+			step = BatchStep{Action: "move", Direction: in.Args[0]}
+		case "PICKUP", "PUTDOWN":
+			step = BatchStep{Action: strings.ToLower(in.Op), Item: in.Args[0]}
+		default:
+			continue
+		}
+		if err := h.validateStep(c, step); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid program: line %d: %v", in.Line, err)})
+			return
+		}
+	}
+
+	program := Program{
+		RobotID:      id,
+		Source:       request.Source,
+		Instructions: instructions,
+		Status:       ProgramReady,
+		UpdatedAt:    h.world(c).Clock().Now().UTC(),
+	}
+	if len(instructions) == 0 {
+		program.Status = ProgramHalted
+	}
+	if err := h.programs.Put(currentTenant(c), program); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "The program is running"})
+		return
+	}
+	h.bus(c).PublishCommand(commandID(c), "program_uploaded", id, gin.H{"instructions": len(instructions)})
+	c.JSON(http.StatusOK, gin.H{"program": program})
+}
+
+// GetProgram returns a robot's program and how far its execution got
+func (h *RobotHandler) GetProgram(c *gin.Context) {
+	program, exists := h.programs.Get(currentTenant(c), c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No program uploaded"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"program": program})
+}
+
+// DeleteProgram removes a robot's program
+func (h *RobotHandler) DeleteProgram(c *gin.Context) {
+	removed, err := h.programs.Delete(currentTenant(c), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "The program is running"})
+		return
+	}
+	if !removed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No program uploaded"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// acquireProgram takes a robot's program for execution or answers why it
+// cannot be executed
+func (h *RobotHandler) acquireProgram(c *gin.Context) (Program, bool) {
+	program, err := h.programs.acquire(currentTenant(c), c.Param("id"))
+	switch {
+	case errors.Is(err, errProgramNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No program uploaded"})
+		return program, false
+	case err != nil:
+		c.JSON(http.StatusConflict, gin.H{"error": "The program is running"})
+		return program, false
+	}
+	if program.Status == ProgramHalted {
+		h.programs.release(currentTenant(c), program)
+		c.JSON(http.StatusConflict, gin.H{"error": "The program has halted, upload it again to restart"})
+		return program, false
+	}
+	return program, true
+}
+
+// StepProgram executes the next instruction of a robot's program
+func (h *RobotHandler) StepProgram(c *gin.Context) {
+	program, ok := h.acquireProgram(c)
+	if !ok {
+		return
+	}
+	step := h.execute(c, &program)
+	h.programs.release(currentTenant(c), program)
+
+	status := http.StatusOK
+	if step.Error != "" {
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"step": step, "program": program})
+}
+
+// RunProgram starts executing a robot's program as a task until it halts,
+// an instruction fails, the task is cancelled or max_steps instructions
+// ran. It answers with 202 and the task right away; the trace is the task's
+// result.
+func (h *RobotHandler) RunProgram(c *gin.Context) {
+	maxSteps := defaultProgramRunSteps
+	if value := c.Query("max_steps"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxProgramRunSteps {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("max_steps must be between 1 and %d", maxProgramRunSteps)})
+			return
+		}
+		maxSteps = parsed
+	}
+	program, ok := h.acquireProgram(c)
+	if !ok {
+		return
+	}
+
+	task := h.tasks.CreateFor(currentTenant(c), ProgramTask, requestPriority(c), program.RobotID)
+	go h.runProgramTask(taskContext(c), task.ID, program, maxSteps)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"task":    task,
+		"program": program,
+		"links":   robotTaskLinks(c, program.RobotID, task.ID),
+	})
+}
+
+// runProgramTask runs an acquired program and records the trace in the task
+func (h *RobotHandler) runProgramTask(c *gin.Context, taskID string, program Program, maxSteps int) {
+	h.tasks.Update(taskID, func(task *Task) {
+		task.Status = TaskRunning
+	})
+	ctx := h.tasks.Context(taskID)
+	trace := []ProgramStep{}
+	program.Status = ProgramReady // a failed instruction is retried
+	for len(trace) < maxSteps && program.Status == ProgramReady && ctx.Err() == nil {
+		release, err := h.acquireLane(ctx, c, program.RobotID)
+		if err != nil {
+			break
+		}
+		trace = append(trace, h.execute(c, &program))
+		release()
+		h.tasks.Update(taskID, func(task *Task) {
+			task.Progress = float64(len(trace)) / float64(maxSteps)
+		})
+	}
+	h.programs.release(currentTenant(c), program)
+
+	h.tasks.Update(taskID, func(task *Task) {
+		task.Status = TaskSucceeded
+		switch {
+		case program.Status == ProgramFailed:
+			task.Status = TaskFailed
+			task.Error = program.Error
+		case ctx.Err() != nil:
+			task.Status = TaskCancelled
+		}
+		task.Progress = 1
+		task.Result = gin.H{"steps": len(trace), "pc": program.PC, "status": program.Status, "trace": trace}
+	})
+	if program.Status == ProgramFailed {
+		log.Printf("Program of robot %s failed at line %d: %s", program.RobotID, program.Instructions[program.PC].Line, program.Error)
+	}
+	h.bus(c).PublishCommand(commandID(c), "program_ran", program.RobotID, gin.H{"task": taskID, "steps": len(trace), "status": program.Status})
+}
@@ -0,0 +1,166 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupProgramRouter() (*gin.Engine, *RobotHandler, *RobotStorage) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	router := gin.New()
+	router.GET("/robot/:id/program", handler.GetProgram)
+	router.PUT("/robot/:id/program", handler.UploadProgram)
+	router.DELETE("/robot/:id/program", handler.DeleteProgram)
+	router.POST("/robot/:id/program/step", handler.StepProgram)
+	router.POST("/robot/:id/program/run", handler.RunProgram)
+	router.GET("/robot/:id/tasks/:taskId", handler.GetRobotTask)
+	return router, handler, storage
+}
+
+// programRun is the result of a program run task
+type programRun struct {
+	Steps  int           `json:"steps"`
+	PC     int           `json:"pc"`
+	Status string        `json:"status"`
+	Trace  []ProgramStep `json:"trace"`
+}
+
+// runProgram runs robot1's program and waits for the run to finish
+func runProgram(t *testing.T, router *gin.Engine, query string) (Task, programRun) {
+	t.Helper()
+	w := send(router, "POST", "/robot/robot1/program/run"+query, "")
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response struct {
+		Task Task `json:"task"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	var run programRun
+	task := awaitTask(t, router, "robot1", response.Task.ID, &run)
+	return task, run
+}
+
+// staircase walks robot1 diagonally up to y = 3 unless its energy runs low
+const staircase = `# climb the stairs
+loop: IF energy<20 GOTO done
+MOVE          # forward, the robot faces north
+TURN right
+MOVE
+turn LEFT
+IF y < 3 GOTO loop
+done:
+HALT`
+
+func TestProgramStepAndRun(t *testing.T) {
+	router, handler, storage := setupProgramRouter()
+	w := send(router, "PUT", "/robot/robot1/program", `{"source": `+jsonString(staircase)+`}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var response struct {
+		Step    ProgramStep   `json:"step"`
+		Trace   []ProgramStep `json:"trace"`
+		Task    string        `json:"task"`
+		Program Program       `json:"program"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Program.Instructions, 7)
+	assert.Equal(t, Instruction{Line: 4, Op: "TURN", Args: []string{"right"}}, response.Program.Instructions[2])
+	assert.Equal(t, ProgramReady, response.Program.Status)
+
+	// The condition does not hold, so the first step falls through to MOVE
+	w = send(router, "POST", "/robot/robot1/program/step", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, ProgramStep{PC: 0, Line: 2, Op: "IF"}, response.Step)
+	send(router, "POST", "/robot/robot1/program/step", "")
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 0, Y: 1}, robot.Position)
+
+	task, run := runProgram(t, router, "")
+	assert.Equal(t, TaskSucceeded, task.Status)
+	assert.Equal(t, []string{"robot1"}, task.Robots)
+	assert.Equal(t, ProgramHalted, run.Status)
+	assert.Len(t, run.Trace, 17)
+	assert.Equal(t, ProgramStep{PC: 5, Line: 7, Op: "IF", Jumped: true}, run.Trace[3])
+	w = send(router, "GET", "/robot/robot1/program", "")
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.Equal(t, ProgramHalted, response.Program.Status)
+	assert.Equal(t, 19, response.Program.Steps)
+
+	robot, _ = storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 3, Y: 3}, robot.Position)
+	assert.Equal(t, "north", robot.Direction)
+	assert.Equal(t, "turn", robot.Actions[len(robot.Actions)-1].Type)
+	assert.False(t, handler.tasks.Active("", ProgramTask, "robot1"))
+
+	// A halted program has to be uploaded again
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot1/program/step", "").Code)
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot1/program/run", "").Code)
+
+	assert.Equal(t, http.StatusNoContent, send(router, "DELETE", "/robot/robot1/program", "").Code)
+	assert.Equal(t, http.StatusNotFound, send(router, "GET", "/robot/robot1/program", "").Code)
+	assert.Equal(t, http.StatusNotFound, send(router, "POST", "/robot/robot1/program/step", "").Code)
+}
+
+func TestProgramFailuresAreRetried(t *testing.T) {
+	router, _, storage := setupProgramRouter()
+	require.NoError(t, storage.PlaceObstacle(Position{X: -1, Y: 0}))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("PUT", "/robot/robot1/program", bytes.NewBufferString("MOVE left\nWAIT\nGOTO 1"))
+	req.Header.Set("Content-Type", "text/plain")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	task, run := runProgram(t, router, "?max_steps=5")
+	assert.Equal(t, TaskFailed, task.Status)
+	assert.Equal(t, ProgramFailed, run.Status)
+	assert.Equal(t, 0, run.PC)
+	assert.NotEmpty(t, run.Trace[0].Error)
+
+	// Once the way is clear, the failed move is retried and the loop runs
+	// until the step limit
+	require.True(t, storage.RemoveObstacle(Position{X: -1, Y: 0}))
+	task, run = runProgram(t, router, "?max_steps=5")
+	assert.Equal(t, TaskSucceeded, task.Status)
+	assert.Equal(t, ProgramReady, run.Status)
+	assert.Equal(t, 2, run.PC)
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: -2, Y: 0}, robot.Position)
+
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/robot/robot1/program/run?max_steps=0", "").Code)
+}
+
+func TestInvalidPrograms(t *testing.T) {
+	router, _, _ := setupProgramRouter()
+	for _, source := range []string{
+		"FLY",
+		"MOVE north",
+		"TURN up",
+		"PICKUP",
+		"GOTO nowhere",
+		"GOTO 7",
+		"IF fuel < 3 GOTO 1",
+		"IF energy is low GOTO 1",
+		"a: WAIT\na: HALT",
+		"PICKUP ../item",
+	} {
+		w := send(router, "PUT", "/robot/robot1/program", `{"source": `+jsonString(source)+`}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, source)
+	}
+	assert.Equal(t, http.StatusNotFound, send(router, "PUT", "/robot/ghost/program", `{"source": "WAIT"}`).Code)
+}
+
+func jsonString(s string) string {
+	encoded, _ := json.Marshal(s)
+	return string(encoded)
+}
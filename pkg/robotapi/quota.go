@@ -0,0 +1,133 @@
+package robotapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParseQuotas reads daily action quotas from a comma separated list of
+// "action=limit" pairs, e.g. "move=1000,attack=50". Actions without a quota
+// are unlimited.
+func ParseQuotas(config string) (map[string]int, error) {
+	quotas := make(map[string]int)
+	for _, pair := range strings.Split(config, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		action, value, found := strings.Cut(pair, "=")
+		limit, err := strconv.Atoi(value)
+		if !found || action == "" || err != nil || limit < 0 {
+			return nil, errors.New("quotas must be given as action=limit pairs")
+		}
+		quotas[action] = limit
+	}
+	return quotas, nil
+}
+
+// quotaDay returns the UTC calendar day quotas are counted for
+func quotaDay(now time.Time) string {
+	return now.UTC().Format("2006-01-02")
+}
+
+// rollQuotaDay drops all counters once a new day started; callers must hold the lock
+func (s *RobotStorage) rollQuotaDay(now time.Time) {
+	if day := quotaDay(now); day != s.quotaDay {
+		s.quotaDay = day
+		s.quotaUsage = make(map[string]map[string]int)
+	}
+}
+
+// ConsumeQuota counts one action of a robot against its daily limit. It
+// returns the number of actions left today and whether the action was allowed.
+func (s *RobotStorage) ConsumeQuota(robotID, action string, limit int, now time.Time) (int, bool) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	s.rollQuotaDay(now)
+	used := s.quotaUsage[robotID][action]
+	if used >= limit {
+		return 0, false
+	}
+
+	if s.quotaUsage[robotID] == nil {
+		s.quotaUsage[robotID] = make(map[string]int)
+	}
+	s.quotaUsage[robotID][action] = used + 1
+	return limit - used - 1, true
+}
+
+// RefundQuota gives back an action that was counted but failed
+func (s *RobotStorage) RefundQuota(robotID, action string) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if s.quotaUsage[robotID][action] > 0 {
+		s.quotaUsage[robotID][action]--
+	}
+}
+
+// ResetQuotas clears the counters when the day changed. It is registered as
+// a simulation system, so counters are reset even for idle robots.
+func (s *RobotStorage) ResetQuotas(now time.Time) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	s.rollQuotaDay(now)
+}
+
+// QuotaUsage returns how many actions of each type were counted today
+func (s *RobotStorage) QuotaUsage() map[string]int {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	s.rollQuotaDay(s.clock.Now())
+	usage := make(map[string]int)
+	for _, actions := range s.quotaUsage {
+		for action, used := range actions {
+			usage[action] += used
+		}
+	}
+	return usage
+}
+
+// RequireQuota counts the request against the daily quota of the robot in
+// the :id path parameter and answers 429 once it is used up. Failed requests
+// and dry runs don't count.
+func (h *RobotHandler) RequireQuota(action string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit, limited := h.quotas[action]
+		if !limited || isDryRun(c) {
+			c.Next()
+			return
+		}
+
+		now := h.world(c).Clock().Now()
+		id := c.Param("id")
+		remaining, ok := h.world(c).ConsumeQuota(id, action, limit, now)
+
+		y, m, d := now.UTC().Date()
+		reset := time.Date(y, m, d+1, 0, 0, 0, 0, time.UTC)
+		c.Header("X-Quota-Limit", strconv.Itoa(limit))
+		c.Header("X-Quota-Remaining", strconv.Itoa(remaining))
+		c.Header("X-Quota-Reset", strconv.FormatInt(reset.Unix(), 10))
+
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"error": "Daily quota exceeded for action " + action,
+			})
+			return
+		}
+
+		c.Next()
+		// HEAD requests see the quota headers of the GET without using it up
+		if c.Writer.Status() >= http.StatusBadRequest || isHeadRequest(c) {
+			h.world(c).RefundQuota(id, action)
+		}
+	}
+}
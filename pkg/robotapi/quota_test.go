@@ -0,0 +1,65 @@
+package robotapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseQuotas(t *testing.T) {
+	quotas, err := ParseQuotas("move=1000, attack=50")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"move": 1000, "attack": 50}, quotas)
+
+	_, err = ParseQuotas("move")
+	assert.Error(t, err)
+	_, err = ParseQuotas("move=-1")
+	assert.Error(t, err)
+}
+
+func TestQuotaResetsOnNewDay(t *testing.T) {
+	storage := NewRobotStorage()
+	day := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+
+	remaining, ok := storage.ConsumeQuota("robot1", "move", 1, day)
+	assert.True(t, ok)
+	assert.Equal(t, 0, remaining)
+	_, ok = storage.ConsumeQuota("robot1", "move", 1, day)
+	assert.False(t, ok)
+
+	storage.ResetQuotas(day.Add(2 * time.Hour))
+	_, ok = storage.ConsumeQuota("robot1", "move", 1, day.Add(2*time.Hour))
+	assert.True(t, ok)
+}
+
+func TestMoveQuota(t *testing.T) {
+	router, storage := setupTestRouter()
+	handler := NewRobotHandler(storage)
+	handler.SetQuotas(map[string]int{"move": 2})
+	router.POST("/limited/:id/move", handler.RequireQuota("move"), handler.MoveRobot)
+
+	move := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/limited/robot1/move", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := move(`{"direction": "up"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-Quota-Remaining"))
+
+	// Failed requests are refunded
+	assert.Equal(t, http.StatusBadRequest, move(`{"direction": "sideways"}`).Code)
+
+	assert.Equal(t, http.StatusOK, move(`{"direction": "up"}`).Code)
+	w = move(`{"direction": "up"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("X-Quota-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("X-Quota-Reset"))
+}
@@ -0,0 +1,152 @@
+package robotapi
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Random streams; each has its own sequence derived from the match seed, so
+// drawing from one does not shift the outcomes of another
+const (
+	RandomCombat  = "combat"  // hit and damage rolls
+	RandomWeather = "weather" // weather changes and attacks lost to it
+	RandomSensors = "sensors" // sensor noise
+	RandomMaps    = "maps"    // seeds of generated maps that were not given one
+	RandomFaults  = "faults"  // which requests injected faults hit
+	RandomItems   = "items"   // types and positions of spawned items
+)
+
+// RandomService is the single source of randomness of a match. It is seeded
+// once per match and the seed is published as a random_seeded event, so
+// replaying the match's commands with that seed gives the same outcomes.
+type RandomService struct {
+	seed     int64
+	seededAt time.Time
+	streams  map[string]*dice
+	clock    Clock
+	mutex    sync.Mutex
+}
+
+// NewRandomService creates a service whose streams are derived from seed
+func NewRandomService(seed int64) *RandomService {
+	clock := SystemClock{}
+	return &RandomService{seed: seed, seededAt: clock.Now(), streams: make(map[string]*dice), clock: clock}
+}
+
+// SetClock replaces the clock seeds are dated with, dating the current one anew
+func (r *RandomService) SetClock(clock Clock) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.clock, r.seededAt = clock, clock.Now()
+}
+
+// Seed returns the seed of the current match
+func (r *RandomService) Seed() int64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.seed
+}
+
+// Reseed starts a new match: every stream starts over from the new seed
+func (r *RandomService) Reseed(seed int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.seed, r.seededAt = seed, r.clock.Now()
+	for name, stream := range r.streams {
+		stream.reseed(streamSeed(seed, name))
+	}
+}
+
+// stream returns the named stream, which follows reseeds
+func (r *RandomService) stream(name string) *dice {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	stream, exists := r.streams[name]
+	if !exists {
+		stream = newDice(streamSeed(r.seed, name))
+		r.streams[name] = stream
+	}
+	return stream
+}
+
+// Int63 draws a non-negative number from the named stream
+func (r *RandomService) Int63(name string) int64 {
+	return r.stream(name).int63()
+}
+
+// streamSeed derives the seed of a stream from the match seed
+func streamSeed(seed int64, name string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(name))
+	return seed ^ int64(hash.Sum64())
+}
+
+// reseed starts the dice over from seed
+func (d *dice) reseed(seed int64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.rng = rand.New(rand.NewSource(seed))
+}
+
+// int63 returns a non-negative random number
+func (d *dice) int63() int64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.rng.Int63()
+}
+
+// publishSeed records the seed of the match in the event log of every world
+func (h *RobotHandler) publishSeed() {
+	seed := h.random.Seed()
+	h.events.Publish("random_seeded", "", gin.H{"seed": seed})
+	for _, tenant := range h.tenants.All() {
+		tenant.Events.Publish("random_seeded", "", gin.H{"seed": seed})
+	}
+}
+
+// seedResponse describes the current match's randomness
+func (h *RobotHandler) seedResponse() gin.H {
+	h.random.mutex.Lock()
+	defer h.random.mutex.Unlock()
+
+	streams := make([]string, 0, len(h.random.streams))
+	for name := range h.random.streams {
+		streams = append(streams, name)
+	}
+	sort.Strings(streams)
+	return gin.H{"seed": h.random.seed, "seeded_at": h.random.seededAt, "streams": streams}
+}
+
+// GetRandomSeed returns the seed of the current match
+func (h *RobotHandler) GetRandomSeed(c *gin.Context) {
+	c.JSON(http.StatusOK, h.seedResponse())
+}
+
+// ReseedRandom starts a new match with the seed from the body, or a fresh
+// one if none is given
+func (h *RobotHandler) ReseedRandom(c *gin.Context) {
+	var request struct {
+		Seed *int64 `json:"seed"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := bindJSON(c, &request); err != nil {
+			invalidRequest(c, err)
+			return
+		}
+	}
+	seed := rand.Int63()
+	if request.Seed != nil {
+		seed = *request.Seed
+	}
+	h.random.Reseed(seed)
+	h.publishSeed()
+	c.JSON(http.StatusOK, h.seedResponse())
+}
@@ -0,0 +1,89 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomServiceStreams(t *testing.T) {
+	rolls := func(random *RandomService, name string) []int {
+		stream := random.stream(name)
+		return []int{stream.roll(1, 20), stream.roll(1, 20), stream.roll(1, 20), stream.roll(1, 20)}
+	}
+
+	first, second := NewRandomService(7), NewRandomService(7)
+	second.Int63(RandomWeather) // other streams do not shift combat rolls
+	combat := rolls(first, RandomCombat)
+	assert.Equal(t, combat, rolls(second, RandomCombat))
+	assert.NotEqual(t, combat, rolls(first, RandomSensors))
+
+	// Reseeding starts the streams handed out before over
+	stream := first.stream(RandomCombat)
+	first.Reseed(7)
+	assert.Equal(t, combat, []int{stream.roll(1, 20), stream.roll(1, 20), stream.roll(1, 20), stream.roll(1, 20)})
+	first.Reseed(8)
+	assert.Equal(t, int64(8), first.Seed())
+	assert.NotEqual(t, combat, rolls(first, RandomCombat))
+}
+
+func TestSeededMatchesReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.CombatRules = "dice"
+	config.RandomSeed = 42
+	config.AdminToken = "secret"
+
+	attacks := func() []gin.H {
+		server, err := New(config)
+		assert.NoError(t, err)
+		router := server.Router()
+		placeInRange(t, server.Storage(), "robot1", "robot2")
+		for i := 0; i < 5; i++ {
+			send(router, "POST", "/robot/robot1/attack/robot2", "")
+		}
+		outcomes := []gin.H{}
+		for _, event := range server.Events().Since(0) {
+			switch event.Type {
+			case "random_seeded":
+				assert.Equal(t, gin.H{"seed": int64(42)}, event.Data)
+			case "robot_attacked":
+				data := event.Data.(gin.H)
+				outcomes = append(outcomes, gin.H{"hit": data["hit"], "damage": data["damage"]})
+			}
+		}
+		return outcomes
+	}
+	outcomes := attacks()
+	assert.Len(t, outcomes, 5)
+	assert.Equal(t, outcomes, attacks())
+}
+
+func TestReseedRandom(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.RandomSeed = 1
+	config.AdminToken = "secret"
+	server, err := New(config)
+	assert.NoError(t, err)
+	router := server.Router()
+
+	var seed struct {
+		Seed int64 `json:"seed"`
+	}
+	json.Unmarshal(adminRequest(router, "GET", "/admin/random", "").Body.Bytes(), &seed)
+	assert.Equal(t, int64(1), seed.Seed)
+
+	w := adminRequest(router, "POST", "/admin/random", `{"seed": 99}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &seed)
+	assert.Equal(t, int64(99), seed.Seed)
+	events := server.Events().Since(0)
+	assert.Equal(t, "random_seeded", events[len(events)-1].Type)
+
+	assert.Equal(t, http.StatusOK, sendAdmin(router, "/admin/random").Code, "a fresh seed without a body")
+	assert.Equal(t, http.StatusBadRequest, adminRequest(router, "POST", "/admin/random", `{"seed": "x"}`).Code)
+}
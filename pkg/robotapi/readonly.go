@@ -0,0 +1,148 @@
+package robotapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readOnlyExempt are the paths that stay writable in read-only mode: the
+// toggle itself, the emergency stop and the storage migration it is meant for
+var readOnlyExempt = []string{"/admin/read-only", "/admin/estop", "/admin/migrate-storage"}
+
+// ReadOnlyState is the public view of the read-only mode
+type ReadOnlyState struct {
+	Enabled    bool       `json:"enabled"`
+	Since      *time.Time `json:"since,omitempty"`
+	Reason     string     `json:"reason,omitempty"`
+	RetryAfter int        `json:"retry_after,omitempty"` // seconds, sent as Retry-After
+}
+
+// ReadOnlyMode puts the whole API into maintenance: mutating requests are
+// rejected while reads and streams keep working
+type ReadOnlyMode struct {
+	state ReadOnlyState
+	clock Clock
+	mutex sync.RWMutex
+}
+
+// NewReadOnlyMode creates a disabled read-only mode
+func NewReadOnlyMode() *ReadOnlyMode {
+	return &ReadOnlyMode{clock: SystemClock{}}
+}
+
+// SetClock replaces the clock the mode is dated with
+func (m *ReadOnlyMode) SetClock(clock Clock) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.clock = clock
+}
+
+// State returns the current state of the read-only mode
+func (m *ReadOnlyMode) State() ReadOnlyState {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.state
+}
+
+// Enable switches the API to read-only and reports whether it was writable
+// before. Enabling it again only updates the reason and retry hint.
+func (m *ReadOnlyMode) Enable(reason string, retryAfter int) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.state.Enabled {
+		m.state.Reason = reason
+		m.state.RetryAfter = retryAfter
+		return false
+	}
+	now := m.clock.Now().UTC()
+	m.state = ReadOnlyState{Enabled: true, Since: &now, Reason: reason, RetryAfter: retryAfter}
+	return true
+}
+
+// Disable makes the API writable again and reports whether it was read-only before
+func (m *ReadOnlyMode) Disable() bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if !m.state.Enabled {
+		return false
+	}
+	m.state = ReadOnlyState{}
+	return true
+}
+
+// RejectWhenReadOnly answers mutating requests with a 503 maintenance problem
+// document while the API is read-only
+func RejectWhenReadOnly(mode *ReadOnlyMode) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+		default:
+			state := mode.State()
+			if state.Enabled && !readOnlyExempted(strings.TrimPrefix(c.Request.URL.Path, c.GetString("base_path"))) {
+				if state.RetryAfter > 0 {
+					c.Header("Retry-After", strconv.Itoa(state.RetryAfter))
+				}
+				c.Header("Content-Type", "application/problem+json")
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+					"type":   "about:blank",
+					"title":  http.StatusText(http.StatusServiceUnavailable),
+					"status": http.StatusServiceUnavailable,
+					"detail": "The API is read-only for maintenance",
+					"reason": state.Reason,
+					"since":  state.Since,
+				})
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func readOnlyExempted(path string) bool {
+	for _, prefix := range readOnlyExempt {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// EnableReadOnly puts the API into read-only mode, e.g. for the duration of a
+// storage migration
+func (h *RobotHandler) EnableReadOnly(c *gin.Context) {
+	var request struct {
+		Reason     string `json:"reason"`
+		RetryAfter int    `json:"retry_after" binding:"min=0"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := bindJSON(c, &request); err != nil {
+			invalidRequest(c, err)
+			return
+		}
+	}
+
+	if h.readOnly.Enable(request.Reason, request.RetryAfter) {
+		h.events.PublishCommand(commandID(c), "read_only_enabled", "", gin.H{"reason": request.Reason})
+	}
+	c.JSON(http.StatusOK, gin.H{"read_only": h.readOnly.State()})
+}
+
+// DisableReadOnly makes the API writable again
+func (h *RobotHandler) DisableReadOnly(c *gin.Context) {
+	if h.readOnly.Disable() {
+		h.events.PublishCommand(commandID(c), "read_only_disabled", "", nil)
+	}
+	c.JSON(http.StatusOK, gin.H{"read_only": h.readOnly.State()})
+}
+
+// GetReadOnly returns the state of the read-only mode
+func (h *RobotHandler) GetReadOnly(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"read_only": h.readOnly.State()})
+}
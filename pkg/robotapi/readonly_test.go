@@ -0,0 +1,93 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadOnlyMode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	events := NewEventBus()
+	handler.SetEventBus(events)
+	mode := NewReadOnlyMode()
+	handler.SetReadOnlyMode(mode)
+
+	router := gin.New()
+	router.Use(RejectWhenReadOnly(mode))
+	router.GET("/robot/:id/status", handler.GetStatus)
+	router.POST("/robot/:id/move", handler.MoveRobot)
+	router.GET("/admin/read-only", handler.GetReadOnly)
+	router.POST("/admin/read-only", handler.EnableReadOnly)
+	router.DELETE("/admin/read-only", handler.DisableReadOnly)
+
+	var response struct {
+		ReadOnly ReadOnlyState `json:"read_only"`
+	}
+	w := send(router, "POST", "/admin/read-only", `{"reason": "storage migration", "retry_after": 120}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.True(t, response.ReadOnly.Enabled)
+	assert.NotNil(t, response.ReadOnly.Since)
+	assert.Equal(t, "storage migration", response.ReadOnly.Reason)
+
+	// Mutating requests get a maintenance problem, reads still work
+	before, _ := storage.GetRobot("robot1")
+	w = send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "120", w.Header().Get("Retry-After"))
+	var problem map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &problem)
+	assert.Equal(t, "The API is read-only for maintenance", problem["detail"])
+	assert.Equal(t, "storage migration", problem["reason"])
+	assert.Equal(t, http.StatusOK, send(router, "GET", "/robot/robot1/status", "").Code)
+
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, before.Position, robot.Position)
+
+	// Enabling again keeps the original start
+	since := *response.ReadOnly.Since
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/admin/read-only", "").Code)
+	assert.Equal(t, since, *mode.State().Since)
+	assert.Empty(t, mode.State().Reason)
+
+	w = send(router, "DELETE", "/admin/read-only", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	json.Unmarshal(w.Body.Bytes(), &response)
+	assert.False(t, response.ReadOnly.Enabled)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+
+	var types []string
+	for _, event := range events.Since(0) {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{"read_only_enabled", "read_only_disabled", "robot_moved"}, types)
+}
+
+func TestReadOnlyModeExemptions(t *testing.T) {
+	mode := NewReadOnlyMode()
+	mode.Enable("", 0)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("base_path", "/api")
+		c.Next()
+	}, RejectWhenReadOnly(mode))
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router.POST("/api/admin/migrate-storage", ok)
+	router.POST("/api/admin/estop", ok)
+	router.POST("/api/robot/:id/move", ok)
+
+	assert.Equal(t, http.StatusNoContent, send(router, "POST", "/api/admin/migrate-storage", "").Code)
+	assert.Equal(t, http.StatusNoContent, send(router, "POST", "/api/admin/estop", "").Code)
+	w := send(router, "POST", "/api/robot/robot1/move", "")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Empty(t, w.Header().Get("Retry-After"))
+}
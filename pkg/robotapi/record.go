@@ -0,0 +1,141 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// recordedHeaders are the request headers golden files keep. Credentials
+// are left out, so recordings can be committed; replays add their own.
+var recordedHeaders = []string{"Content-Type", "X-Tenant-ID"}
+
+// GoldenExchange is a recorded request with the response it got
+type GoldenExchange struct {
+	Request  GoldenRequest  `json:"request"`
+	Response GoldenResponse `json:"response"`
+}
+
+// GoldenRequest is the recorded part of a request
+type GoldenRequest struct {
+	Method string            `json:"method"`
+	URL    string            `json:"url"` // path and query
+	Header map[string]string `json:"header,omitempty"`
+	Body   json.RawMessage   `json:"body,omitempty"`
+}
+
+// GoldenResponse is the recorded part of a response
+type GoldenResponse struct {
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body,omitempty"`
+}
+
+// GoldenBody stores a body in a golden file: JSON as is, anything else, like
+// NDJSON or HTML, as a JSON string
+func GoldenBody(data []byte) json.RawMessage {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if json.Valid(data) {
+		return json.RawMessage(bytes.TrimSpace(data))
+	}
+	quoted, _ := json.Marshal(string(data))
+	return quoted
+}
+
+// WriteGoldenFile stores an exchange as indented JSON
+func WriteGoldenFile(path string, exchange GoldenExchange) error {
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false) // keep links readable
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(exchange); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data.Bytes(), 0o644)
+}
+
+// Recorder writes every request and its response to a golden file in a
+// directory, numbered in the order the requests finished, e.g.
+// 0003_POST_robot_robot1_move.json. The files can be replayed as a
+// regression test with robotapitest.Replay.
+type Recorder struct {
+	dir   string
+	next  int
+	mutex sync.Mutex
+}
+
+// NewRecorder records into dir, after the golden files already there
+func NewRecorder(dir string) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	existing, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{dir: dir, next: len(existing) + 1}, nil
+}
+
+// unsafeFileChars are replaced in the file names of golden files
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// Record is the middleware writing the golden files. It has to run after
+// Compress, so the bodies it sees are not compressed yet.
+func (r *Recorder) Record() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			if body, err = readBody(c); errors.Is(err, errBodyTooLarge) {
+				bodyTooLarge(c)
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		writer := &teeWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		exchange := GoldenExchange{
+			Request: GoldenRequest{
+				Method: c.Request.Method,
+				URL:    c.Request.URL.RequestURI(),
+				Body:   GoldenBody(body),
+			},
+			Response: GoldenResponse{Status: writer.Status(), Body: GoldenBody(writer.body.Bytes())},
+		}
+		for _, name := range recordedHeaders {
+			if value := c.GetHeader(name); value != "" {
+				if exchange.Request.Header == nil {
+					exchange.Request.Header = make(map[string]string)
+				}
+				exchange.Request.Header[name] = value
+			}
+		}
+		if err := r.write(exchange); err != nil {
+			log.Printf("Recording %s %s failed: %v", c.Request.Method, c.Request.URL.Path, err)
+		}
+	}
+}
+
+// write stores an exchange in the next golden file
+func (r *Recorder) write(exchange GoldenExchange) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	path := strings.Trim(unsafeFileChars.ReplaceAllString(strings.SplitN(exchange.Request.URL, "?", 2)[0], "_"), "_")
+	name := fmt.Sprintf("%04d_%s_%s.json", r.next, exchange.Request.Method, path)
+	r.next++
+	return WriteGoldenFile(filepath.Join(r.dir, name), exchange)
+}
@@ -0,0 +1,68 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordGoldenFiles(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	dir := t.TempDir()
+	config := DefaultConfig()
+	config.RecordDir = dir
+	config.APIKeys = "alice=key1"
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+
+	request := httptest.NewRequest("POST", "/robot/robot1/move", strings.NewReader(`{"direction": "up"}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-API-Key", "key1")
+	request.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	send(router, "GET", "/health", "")
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+	assert.Equal(t, "0001_POST_robot_robot1_move.json", filepath.Base(files[0]))
+	assert.Equal(t, "0002_GET_health.json", filepath.Base(files[1]))
+
+	data, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "key1", "credentials are not recorded")
+	var exchange GoldenExchange
+	require.NoError(t, json.Unmarshal(data, &exchange))
+	assert.Equal(t, "POST", exchange.Request.Method)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json"}, exchange.Request.Header)
+	assert.JSONEq(t, `{"direction": "up"}`, string(exchange.Request.Body))
+	assert.Equal(t, http.StatusOK, exchange.Response.Status)
+	var result struct {
+		Position Position `json:"position"`
+	}
+	require.NoError(t, json.Unmarshal(exchange.Response.Body, &result), "the body is recorded uncompressed")
+	assert.Equal(t, Position{X: 0, Y: 1}, result.Position)
+
+	// A new recorder continues after the existing files
+	recorder, err := NewRecorder(dir)
+	require.NoError(t, err)
+	assert.Equal(t, 3, recorder.next)
+}
+
+func TestGoldenBody(t *testing.T) {
+	assert.Nil(t, GoldenBody(nil))
+	assert.Equal(t, `{"a":1}`, string(GoldenBody([]byte("{\"a\":1}\n"))))
+	assert.Equal(t, `"{\"a\":1}\n{\"a\":2}\n"`, string(GoldenBody([]byte("{\"a\":1}\n{\"a\":2}\n"))))
+}
@@ -0,0 +1,440 @@
+package robotapi
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Device registration states
+const (
+	RegistrationPending  = "pending"
+	RegistrationApproved = "approved"
+	RegistrationRejected = "rejected"
+)
+
+// RegistrationTokenHeader carries the secret a device got with its
+// registration, needed to poll it
+const RegistrationTokenHeader = "X-Registration-Token"
+
+// Bounds of the open registration endpoint. Pending registrations expire
+// after registrationTTL, rejected ones that long after the rejection.
+const (
+	registrationTTL           = 24 * time.Hour
+	maxPendingRegistrations   = 1000
+	maxRegistrationsPerClient = 5 // per registrationWindow
+	registrationWindow        = time.Hour
+)
+
+// ErrRegistrationNotFound is returned for unknown registration IDs
+var ErrRegistrationNotFound = errors.New("registration not found")
+
+// ErrRegistrationResolved is returned for registrations that are not
+// pending anymore
+var ErrRegistrationResolved = errors.New("registration is not pending anymore")
+
+// Errors of registrations beyond the bounds
+var (
+	errTooManyRegistrations          = errors.New("Too many pending registrations, retry later")
+	errTooManyRegistrationsPerClient = errors.New("Too many registrations from this client, retry later")
+)
+
+// hardwareIDPattern is the format of hardware IDs, e.g. serial numbers or
+// MAC addresses
+var hardwareIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// DeviceRegistration is a robot's request to join the fleet. An admin
+// approves it, which creates the robot and issues the device its
+// credentials.
+type DeviceRegistration struct {
+	ID         string     `json:"id"`
+	HardwareID string     `json:"hardware_id"`
+	Name       string     `json:"name,omitempty"`
+	Class      string     `json:"class"`
+	Firmware   string     `json:"firmware,omitempty"`
+	Tenant     string     `json:"tenant,omitempty"`
+	Status     string     `json:"status"`
+	RobotID    string     `json:"robot_id,omitempty"`  // created on approval
+	Principal  string     `json:"principal,omitempty"` // owner of the robot, the name of the issued API key
+	Reason     string     `json:"reason,omitempty"`    // given with a rejection
+	CreatedAt  time.Time  `json:"created_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+
+	token  string // secret the device polls the registration with
+	apiKey string // issued on approval, until the device collected it
+}
+
+// RegistrationRequest is the payload a device registers with
+type RegistrationRequest struct {
+	HardwareID string `json:"hardware_id" binding:"required"`
+	Name       string `json:"name"`
+	Class      string `json:"class"`
+	Firmware   string `json:"firmware"`
+}
+
+// RegistrationStore keeps track of device registrations and of how many
+// registrations each client sent recently
+type RegistrationStore struct {
+	registrations map[string]*DeviceRegistration
+	requests      map[string][]time.Time // client -> times of its registrations within registrationWindow
+	ids           IDGenerator
+	clock         Clock
+	mutex         sync.Mutex
+}
+
+// NewRegistrationStore creates an empty registration store dating its
+// registrations by the clock
+func NewRegistrationStore(clock Clock) *RegistrationStore {
+	return &RegistrationStore{
+		registrations: make(map[string]*DeviceRegistration),
+		requests:      make(map[string][]time.Time),
+		ids:           UUIDGenerator{},
+		clock:         clock,
+	}
+}
+
+// expire drops pending registrations nobody decided on in time, rejected
+// ones the device had time to learn about and the requests that left the
+// window; callers must hold the lock
+func (s *RegistrationStore) expire(now time.Time) {
+	for id, registration := range s.registrations {
+		switch {
+		case registration.Status == RegistrationPending && !now.Before(registration.CreatedAt.Add(registrationTTL)),
+			registration.Status == RegistrationRejected && !now.Before(registration.ResolvedAt.Add(registrationTTL)):
+			delete(s.registrations, id)
+		}
+	}
+	for client, times := range s.requests {
+		for len(times) > 0 && !now.Before(times[0].Add(registrationWindow)) {
+			times = times[1:]
+		}
+		if len(times) == 0 {
+			delete(s.requests, client)
+		} else {
+			s.requests[client] = times
+		}
+	}
+}
+
+// Request registers a pending registration sent by the client and returns
+// it with the token the device polls it with. A hardware ID can only have
+// one pending or approved registration per tenant. Clients can send
+// maxRegistrationsPerClient registrations per registrationWindow, and at
+// most maxPendingRegistrations wait for a decision.
+func (s *RegistrationStore) Request(registration DeviceRegistration, client string) (DeviceRegistration, string, error) {
+	token, err := randomSecret()
+	if err != nil {
+		return DeviceRegistration{}, "", err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.clock.Now()
+	s.expire(now)
+	if len(s.requests[client]) >= maxRegistrationsPerClient {
+		return DeviceRegistration{}, "", errTooManyRegistrationsPerClient
+	}
+	s.requests[client] = append(s.requests[client], now)
+
+	pending := 0
+	for _, existing := range s.registrations {
+		if existing.HardwareID == registration.HardwareID && existing.Tenant == registration.Tenant && existing.Status != RegistrationRejected {
+			return DeviceRegistration{}, "", fmt.Errorf("Device %s is already %s", registration.HardwareID, existing.Status)
+		}
+		if existing.Status == RegistrationPending {
+			pending++
+		}
+	}
+	if pending >= maxPendingRegistrations {
+		return DeviceRegistration{}, "", errTooManyRegistrations
+	}
+	registration.ID = s.ids.NewID()
+	registration.Status = RegistrationPending
+	registration.CreatedAt = now.UTC()
+	registration.token = token
+	s.registrations[registration.ID] = &registration
+	return registration, token, nil
+}
+
+// List returns the registrations with the status, all if it is empty,
+// oldest first
+func (s *RegistrationStore) List(status string) []DeviceRegistration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expire(s.clock.Now())
+	registrations := []DeviceRegistration{}
+	for _, registration := range s.registrations {
+		if status == "" || registration.Status == status {
+			registrations = append(registrations, *registration)
+		}
+	}
+	sort.Slice(registrations, func(i, j int) bool {
+		return registrations[i].CreatedAt.Before(registrations[j].CreatedAt)
+	})
+	return registrations
+}
+
+// Collect returns a registration to the device holding its token. The API
+// key issued on approval is handed out only once.
+func (s *RegistrationStore) Collect(id, token string) (DeviceRegistration, string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expire(s.clock.Now())
+	registration, exists := s.registrations[id]
+	if !exists || subtle.ConstantTimeCompare([]byte(registration.token), []byte(token)) != 1 {
+		return DeviceRegistration{}, "", ErrRegistrationNotFound
+	}
+	apiKey := registration.apiKey
+	registration.apiKey = ""
+	return *registration, apiKey, nil
+}
+
+// Resolve changes a pending registration under the store's lock, so it is
+// resolved only once. If resolve fails the registration stays pending.
+func (s *RegistrationStore) Resolve(id string, resolve func(registration *DeviceRegistration) error) (DeviceRegistration, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.clock.Now()
+	s.expire(now)
+	registration, exists := s.registrations[id]
+	if !exists {
+		return DeviceRegistration{}, ErrRegistrationNotFound
+	}
+	if registration.Status != RegistrationPending {
+		return *registration, ErrRegistrationResolved
+	}
+	resolved := *registration
+	if err := resolve(&resolved); err != nil {
+		return *registration, err
+	}
+	resolvedAt := now.UTC()
+	resolved.ResolvedAt = &resolvedAt
+	*registration = resolved
+	return resolved, nil
+}
+
+// randomSecret returns 32 random bytes in hex
+func randomSecret() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// RegisterDevice takes a device's registration request. Devices have no
+// credentials yet, so the endpoint is open, bounded per client IP address;
+// the response carries the token the device polls its registration with
+// until an admin decided on it.
+func (h *RobotHandler) RegisterDevice(c *gin.Context) {
+	var request RegistrationRequest
+	if err := bindJSON(c, &request); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if !hardwareIDPattern.MatchString(request.HardwareID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Hardware IDs consist of up to 64 letters, digits, '.', '_' and '-'"})
+		return
+	}
+	if request.Class == "" {
+		request.Class = ClassStandard
+	}
+	if request.Class != ClassStandard && request.Class != ClassSolar {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid robot class"})
+		return
+	}
+	if _, err := ParseFirmwareVersion(request.Firmware); request.Firmware != "" && err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid firmware version"})
+		return
+	}
+
+	registration, token, err := h.devices.Request(DeviceRegistration{
+		HardwareID: request.HardwareID,
+		Name:       request.Name,
+		Class:      request.Class,
+		Firmware:   request.Firmware,
+		Tenant:     currentTenant(c),
+	}, c.ClientIP())
+	switch {
+	case errors.Is(err, errTooManyRegistrationsPerClient):
+		c.Header("Retry-After", strconv.Itoa(int(registrationWindow.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": err.Error(), "limit": maxRegistrationsPerClient})
+		return
+	case errors.Is(err, errTooManyRegistrations):
+		c.Header("Retry-After", strconv.Itoa(int(registrationWindow.Seconds())))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error(), "limit": maxPendingRegistrations})
+		return
+	case err != nil:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	h.bus(c).PublishCommand(commandID(c), "device_registration_requested", "", gin.H{"registration": registration.ID, "hardware_id": registration.HardwareID})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"registration": registration,
+		"token":        token,
+		"links":        []Link{{Rel: "self", Href: fmt.Sprintf("%s/registrations/%s", requestBaseURL(c), registration.ID)}},
+	})
+}
+
+// GetRegistration shows a device its registration. Once it is approved, the
+// response carries the device's API key, but only the first time.
+func (h *RobotHandler) GetRegistration(c *gin.Context) {
+	registration, apiKey, err := h.devices.Collect(c.Param("registrationId"), c.GetHeader(RegistrationTokenHeader))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Registration not found"})
+		return
+	}
+
+	response := gin.H{"registration": registration}
+	if apiKey != "" {
+		response["credentials"] = gin.H{"principal": registration.Principal, "api_key": apiKey}
+	}
+	if registration.RobotID != "" {
+		response["links"] = []Link{{Rel: "robot", Href: fmt.Sprintf("%s/robot/%s/status", requestBaseURL(c), registration.RobotID)}}
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// ListRegistrations returns the device registrations, optionally only the
+// ones with the status given in the query
+func (h *RobotHandler) ListRegistrations(c *gin.Context) {
+	status := c.Query("status")
+	switch status {
+	case "", RegistrationPending, RegistrationApproved, RegistrationRejected:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status, use pending, approved or rejected"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"registrations": h.devices.List(status)})
+}
+
+// ApproveRegistration creates the robot of a pending registration in the
+// device's tenant, owned by a principal named after the device. With API
+// key authentication on, the principal gets an API key the device collects
+// from its registration.
+func (h *RobotHandler) ApproveRegistration(c *gin.Context) {
+	registration, err := h.devices.Resolve(c.Param("id"), func(registration *DeviceRegistration) error {
+		principal := "device-" + registration.HardwareID
+		if registration.Tenant != "" {
+			principal = registration.Tenant + "/" + principal
+		}
+		if h.keys.Has(principal) {
+			return fmt.Errorf("An API key named %s already exists", principal)
+		}
+
+		robot, err := h.createDeviceRobot(*registration, principal)
+		if err != nil {
+			return err
+		}
+		if h.keys.Enabled() {
+			apiKey, err := randomSecret()
+			if err != nil {
+				return err
+			}
+			h.keys.Add(principal, apiKey)
+			registration.apiKey = apiKey
+		}
+		registration.Status = RegistrationApproved
+		registration.RobotID = robot.ID
+		registration.Principal = principal
+		return nil
+	})
+	if err != nil {
+		registrationFailed(c, err)
+		return
+	}
+
+	data := gin.H{"registration": registration.ID, "hardware_id": registration.HardwareID, "owner": registration.Principal}
+	world := h.worldOf(registration.Tenant)
+	world.AddCommandAction(registration.RobotID, requestOrigin(c), "create", "Robot was created by device registration", data)
+	h.busOf(registration.Tenant).PublishCommand(commandID(c), "robot_created", registration.RobotID, data)
+
+	c.JSON(http.StatusOK, gin.H{
+		"registration": registration,
+		"links":        []Link{{Rel: "robot", Href: fmt.Sprintf("%s/robot/%s/status", requestBaseURL(c), registration.RobotID)}},
+	})
+}
+
+// RejectRegistration declines a pending registration. The device may
+// register again afterwards.
+func (h *RobotHandler) RejectRegistration(c *gin.Context) {
+	var request struct {
+		Reason string `json:"reason"`
+	}
+	if err := bindJSON(c, &request); err != nil && !errors.Is(err, io.EOF) {
+		invalidRequest(c, err)
+		return
+	}
+
+	registration, err := h.devices.Resolve(c.Param("id"), func(registration *DeviceRegistration) error {
+		registration.Status = RegistrationRejected
+		registration.Reason = request.Reason
+		return nil
+	})
+	if err != nil {
+		registrationFailed(c, err)
+		return
+	}
+	h.busOf(registration.Tenant).PublishCommand(commandID(c), "device_registration_rejected", "", gin.H{"registration": registration.ID, "hardware_id": registration.HardwareID})
+	c.JSON(http.StatusOK, gin.H{"registration": registration})
+}
+
+// createDeviceRobot creates the robot of a registration at the next spawn
+// point of the device's world
+func (h *RobotHandler) createDeviceRobot(registration DeviceRegistration, owner string) (*Robot, error) {
+	world := h.worldOf(registration.Tenant)
+	robot := &Robot{
+		Name:      registration.Name,
+		Direction: "north",
+		Class:     registration.Class,
+		Energy:    MaxEnergy,
+		Firmware:  registration.Firmware,
+		Owner:     owner,
+		Inventory: []string{},
+	}
+	if spawn, ok := world.NextSpawnPoint(); ok {
+		robot.Position = spawn
+	}
+	// Retry in the unlikely case that a generated ID collides
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		robot.ID = h.ids.NewID()
+		err = world.CreateRobot(robot)
+		var limitErr *LimitError
+		if err == nil || errors.As(err, &limitErr) {
+			break
+		}
+	}
+	return robot, err
+}
+
+// registrationFailed answers a failed registration resolution
+func registrationFailed(c *gin.Context, err error) {
+	var limitErr *LimitError
+	switch {
+	case errors.Is(err, ErrRegistrationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Registration not found"})
+	case errors.Is(err, ErrRegistrationResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": "Registration is not pending anymore"})
+	case errors.As(err, &limitErr):
+		abortWithLimit(c, http.StatusForbidden, limitErr)
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	}
+}
@@ -0,0 +1,175 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupRegistrations returns a router with API key authentication and the
+// registration endpoints on a fake clock; admin endpoints are not protected
+// here
+func setupRegistrations() (*gin.Engine, *RobotStorage, *FakeClock) {
+	gin.SetMode(gin.TestMode)
+	clock := NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	storage := NewRobotStorage()
+	storage.SetClock(clock)
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	keys, _ := NewAPIKeyStore("alice=alice-key")
+	handler.SetAPIKeys(keys)
+	authenticate := Authenticate(NewAuthenticator(keys, nil, handler.sessions))
+
+	router := gin.New()
+	router.POST("/registrations", handler.RegisterDevice)
+	router.GET("/registrations/:registrationId", handler.GetRegistration)
+	router.GET("/admin/registrations", handler.ListRegistrations)
+	router.POST("/admin/registrations/:id/approve", handler.ApproveRegistration)
+	router.POST("/admin/registrations/:id/reject", handler.RejectRegistration)
+	router.GET("/robot/:id/status", authenticate, handler.GetStatus)
+	return router, storage, clock
+}
+
+type registrationResponse struct {
+	Registration DeviceRegistration `json:"registration"`
+	Token        string             `json:"token"`
+	Credentials  *struct {
+		Principal string `json:"principal"`
+		APIKey    string `json:"api_key"`
+	} `json:"credentials"`
+}
+
+func register(t *testing.T, router *gin.Engine, body string) (int, registrationResponse) {
+	w := send(router, "POST", "/registrations", body)
+	var response registrationResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+func pollRegistration(router *gin.Engine, id, token string) (int, registrationResponse) {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/registrations/"+id, nil)
+	req.Header.Set(RegistrationTokenHeader, token)
+	router.ServeHTTP(w, req)
+	var response registrationResponse
+	json.Unmarshal(w.Body.Bytes(), &response)
+	return w.Code, response
+}
+
+func TestDeviceRegistrationIsApproved(t *testing.T) {
+	router, storage, _ := setupRegistrations()
+
+	code, registered := register(t, router, `{"hardware_id": "SN-0042", "name": "Rover", "firmware": "1.2.0"}`)
+	require.Equal(t, http.StatusAccepted, code)
+	assert.Equal(t, RegistrationPending, registered.Registration.Status)
+	assert.NotEmpty(t, registered.Token)
+	id := registered.Registration.ID
+
+	code, _ = register(t, router, `{"hardware_id": "SN-0042"}`)
+	assert.Equal(t, http.StatusConflict, code, "one pending registration per device")
+
+	code, _ = pollRegistration(router, id, "guessed")
+	assert.Equal(t, http.StatusNotFound, code, "only the device can see its registration")
+	code, polled := pollRegistration(router, id, registered.Token)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Nil(t, polled.Credentials)
+
+	w := send(router, "GET", "/admin/registrations?status=pending", "")
+	assert.Contains(t, w.Body.String(), "SN-0042")
+
+	require.Equal(t, http.StatusOK, send(router, "POST", "/admin/registrations/"+id+"/approve", "").Code)
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/admin/registrations/"+id+"/approve", "").Code)
+
+	// The device collects its key once and controls the new robot with it
+	_, polled = pollRegistration(router, id, registered.Token)
+	assert.Equal(t, RegistrationApproved, polled.Registration.Status)
+	require.NotNil(t, polled.Credentials)
+	assert.Equal(t, "device-SN-0042", polled.Credentials.Principal)
+	_, again := pollRegistration(router, id, registered.Token)
+	assert.Nil(t, again.Credentials)
+
+	robot, err := storage.GetRobot(polled.Registration.RobotID)
+	require.NoError(t, err)
+	assert.Equal(t, "device-SN-0042", robot.Owner)
+	assert.Equal(t, "Rover", robot.Name)
+	assert.Equal(t, "1.2.0", robot.Firmware)
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/robot/"+robot.ID+"/status", nil)
+	req.Header.Set("X-API-Key", polled.Credentials.APIKey)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	code, _ = register(t, router, `{"hardware_id": "SN-0042"}`)
+	assert.Equal(t, http.StatusConflict, code, "approved devices cannot register again")
+}
+
+func TestDeviceRegistrationIsRejected(t *testing.T) {
+	router, storage, _ := setupRegistrations()
+	robots := len(storage.GetAllRobots())
+
+	_, registered := register(t, router, `{"hardware_id": "SN-0007"}`)
+	w := send(router, "POST", "/admin/registrations/"+registered.Registration.ID+"/reject", `{"reason": "unknown device"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	_, polled := pollRegistration(router, registered.Registration.ID, registered.Token)
+	assert.Equal(t, RegistrationRejected, polled.Registration.Status)
+	assert.Equal(t, "unknown device", polled.Registration.Reason)
+	assert.Len(t, storage.GetAllRobots(), robots)
+
+	code, _ := register(t, router, `{"hardware_id": "SN-0007"}`)
+	assert.Equal(t, http.StatusAccepted, code, "rejected devices may try again")
+
+	for _, body := range []string{`{}`, `{"hardware_id": "SN 7"}`, `{"hardware_id": "SN-8", "class": "hover"}`, `{"hardware_id": "SN-9", "firmware": "new"}`} {
+		code, _ := register(t, router, body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+	}
+	assert.Equal(t, http.StatusNotFound, send(router, "POST", "/admin/registrations/missing/approve", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/admin/registrations?status=lost", "").Code)
+}
+
+func TestRegistrationsAreBounded(t *testing.T) {
+	router, _, clock := setupRegistrations()
+
+	var first registrationResponse
+	for i := 0; i < maxRegistrationsPerClient; i++ {
+		code, registered := register(t, router, fmt.Sprintf(`{"hardware_id": "SN-%d"}`, i))
+		require.Equal(t, http.StatusAccepted, code)
+		if i == 0 {
+			first = registered
+		}
+	}
+	w := send(router, "POST", "/registrations", `{"hardware_id": "SN-flood"}`)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "3600", w.Header().Get("Retry-After"))
+
+	// The client may register again once the window moved on
+	clock.Advance(registrationWindow)
+	code, _ := register(t, router, `{"hardware_id": "SN-flood"}`)
+	assert.Equal(t, http.StatusAccepted, code)
+
+	// Registrations nobody decided on expire, and free their hardware ID
+	clock.Advance(registrationTTL - registrationWindow)
+	code, _ = pollRegistration(router, first.Registration.ID, first.Token)
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.Contains(t, send(router, "GET", "/admin/registrations", "").Body.String(), "SN-flood")
+	code, _ = register(t, router, `{"hardware_id": "SN-0"}`)
+	assert.Equal(t, http.StatusAccepted, code)
+}
+
+func TestPendingRegistrationsAreCapped(t *testing.T) {
+	store := NewRegistrationStore(NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)))
+	for i := 0; i < maxPendingRegistrations; i++ {
+		_, _, err := store.Request(DeviceRegistration{HardwareID: fmt.Sprintf("SN-%d", i)}, fmt.Sprintf("client-%d", i))
+		require.NoError(t, err)
+	}
+	_, _, err := store.Request(DeviceRegistration{HardwareID: "SN-more"}, "client-more")
+	assert.ErrorIs(t, err, errTooManyRegistrations)
+}
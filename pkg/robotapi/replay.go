@@ -0,0 +1,326 @@
+package robotapi
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Replay files are gzipped NDJSON: a ReplayHeader line followed by one
+// ReplayEvent per line
+const (
+	ReplayFormat      = "robot-api-replay"
+	ReplayVersion     = 1
+	ReplayContentType = "application/gzip"
+)
+
+// Limits of imported replay files
+const (
+	maxReplaySize   = 8 << 20  // bytes of the uploaded file
+	maxReplayLine   = 64 << 10 // bytes of one decompressed line
+	maxReplayEvents = 100000
+)
+
+// Types of replay events
+const (
+	ReplayAttackEvent = "attack" // a robot attacked in a duel
+	ReplayDuelEvent   = "duel"   // a duel is over
+	ReplayMatchEvent  = "match"  // the match is over
+)
+
+// Fighter is a robot as it entered a match
+type Fighter struct {
+	ID     string `json:"id"`
+	Class  string `json:"class,omitempty"`
+	Energy int    `json:"energy"`
+	Armor  int    `json:"armor,omitempty"`
+}
+
+// robot returns a robot with the fighter's stats
+func (f *Fighter) robot() *Robot {
+	return &Robot{ID: f.ID, Class: f.Class, Energy: f.Energy, Armor: f.Armor}
+}
+
+// MatchSide is a participant of a match with its team
+type MatchSide struct {
+	Participant string     `json:"participant"`
+	Seed        int        `json:"seed"`   // registration order, decides drawn bracket matches
+	Robots      []*Fighter `json:"robots"` // null for robots missing from the world
+}
+
+// MatchScenario is everything a match depends on besides its seed, so it
+// can be played again on any instance
+type MatchScenario struct {
+	Tournament  string         `json:"tournament"`
+	Match       int            `json:"match"`
+	Format      string         `json:"format"`
+	CombatRules string         `json:"combat_rules"`
+	Rounding    EnergyRounding `json:"energy_rounding"`
+	Accuracy    int            `json:"accuracy"` // of the weather, in percent
+	Rounds      int            `json:"rounds"`
+	Sides       [2]MatchSide   `json:"sides"`
+}
+
+// validate checks an imported scenario against the limits of tournaments
+func (s MatchScenario) validate() error {
+	if s.Format != TournamentRoundRobin && s.Format != TournamentBracket {
+		return fmt.Errorf("unknown tournament format %q", s.Format)
+	}
+	if _, err := NewEnergyRounding(string(s.Rounding)); err != nil {
+		return err
+	}
+	if s.Rounds < 1 || s.Rounds > maxSimulatedRounds {
+		return fmt.Errorf("rounds must be between 1 and %d", maxSimulatedRounds)
+	}
+	if s.Accuracy < 0 || s.Accuracy > 100 {
+		return errors.New("accuracy must be between 0 and 100")
+	}
+	for _, side := range s.Sides {
+		if side.Participant == "" {
+			return errors.New("a side has no participant")
+		}
+		if len(side.Robots) > maxTeamSize {
+			return fmt.Errorf("teams have at most %d robots", maxTeamSize)
+		}
+		for _, fighter := range side.Robots {
+			if fighter != nil && (fighter.ID == "" || fighter.Energy < 0 || fighter.Armor < 0) {
+				return fmt.Errorf("invalid robot in team %q", side.Participant)
+			}
+		}
+	}
+	return nil
+}
+
+// ReplayHeader is the first line of a replay file
+type ReplayHeader struct {
+	Format   string        `json:"format"` // always ReplayFormat
+	Version  int           `json:"version"`
+	Seed     int64         `json:"seed"` // of the match's dice
+	Scenario MatchScenario `json:"scenario"`
+	PlayedAt time.Time     `json:"played_at"`
+	Events   int           `json:"events"`
+}
+
+// ReplayEvent is a step of a replayed match
+type ReplayEvent struct {
+	Type           string `json:"type"`
+	Duel           int    `json:"duel,omitempty"` // starting at 1
+	Round          int    `json:"round,omitempty"`
+	Attacker       string `json:"attacker,omitempty"`
+	Target         string `json:"target,omitempty"`
+	Hit            bool   `json:"hit,omitempty"`
+	Damage         int    `json:"damage,omitempty"`
+	Effect         string `json:"effect,omitempty"`
+	AttackerEnergy int    `json:"attacker_energy,omitempty"`
+	TargetEnergy   int    `json:"target_energy,omitempty"`
+	A              string `json:"a,omitempty"`
+	B              string `json:"b,omitempty"`
+	Winner         string `json:"winner,omitempty"`
+	DamageA        int    `json:"damage_a,omitempty"`
+	DamageB        int    `json:"damage_b,omitempty"`
+	Tiebreak       bool   `json:"tiebreak,omitempty"`
+}
+
+// attackEvent describes an attack after it was applied
+func attackEvent(duel, round int, attacker, target *Robot, result CombatResult) ReplayEvent {
+	event := ReplayEvent{
+		Type:           ReplayAttackEvent,
+		Duel:           duel,
+		Round:          round,
+		Attacker:       attacker.ID,
+		Target:         target.ID,
+		Hit:            result.Hit,
+		Damage:         result.Damage,
+		AttackerEnergy: attacker.Energy,
+		TargetEnergy:   target.Energy,
+	}
+	if result.Effect != nil {
+		event.Effect = result.Effect.Type
+	}
+	return event
+}
+
+// duelEvent describes the result of a duel
+func duelEvent(number int, duel Duel) ReplayEvent {
+	return ReplayEvent{
+		Type:    ReplayDuelEvent,
+		Duel:    number,
+		A:       duel.A,
+		B:       duel.B,
+		Winner:  duel.Winner,
+		DamageA: duel.DamageA,
+		DamageB: duel.DamageB,
+	}
+}
+
+// replayMatch plays a scenario and returns its events and results
+func replayMatch(scenario MatchScenario, seed int64) ([]ReplayEvent, TournamentMatch, error) {
+	match := TournamentMatch{ID: scenario.Match, A: scenario.Sides[0].Participant, B: scenario.Sides[1].Participant, Status: MatchPlayed}
+	events := []ReplayEvent{}
+	err := playScenario(scenario, seed, &match, func(event ReplayEvent) {
+		events = append(events, event)
+	})
+	return events, match, err
+}
+
+// WriteReplay writes a replay file
+func WriteReplay(w io.Writer, header ReplayHeader, events []ReplayEvent) error {
+	header.Format, header.Version, header.Events = ReplayFormat, ReplayVersion, len(events)
+	compressed := gzip.NewWriter(w)
+	encoder := json.NewEncoder(compressed)
+	if err := encoder.Encode(header); err != nil {
+		return err
+	}
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			return err
+		}
+	}
+	return compressed.Close()
+}
+
+// ReadReplay reads a replay file, gzipped or plain NDJSON
+func ReadReplay(r io.Reader) (ReplayHeader, []ReplayEvent, error) {
+	var header ReplayHeader
+	buffered := bufio.NewReader(r)
+	if magic, _ := buffered.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		decompressed, err := gzip.NewReader(buffered)
+		if err != nil {
+			return header, nil, err
+		}
+		buffered = bufio.NewReader(decompressed)
+	}
+
+	lines := bufio.NewScanner(buffered)
+	lines.Buffer(make([]byte, 0, 4096), maxReplayLine)
+	if !lines.Scan() {
+		if err := lines.Err(); err != nil {
+			return header, nil, err
+		}
+		return header, nil, errors.New("the file is empty")
+	}
+	if err := json.Unmarshal(lines.Bytes(), &header); err != nil {
+		return header, nil, fmt.Errorf("header: %w", err)
+	}
+	if header.Format != ReplayFormat {
+		return header, nil, fmt.Errorf("unknown format %q", header.Format)
+	}
+	if header.Version != ReplayVersion {
+		return header, nil, fmt.Errorf("unsupported version %d", header.Version)
+	}
+
+	events := []ReplayEvent{}
+	for lines.Scan() {
+		if len(bytes.TrimSpace(lines.Bytes())) == 0 {
+			continue
+		}
+		if len(events) == maxReplayEvents {
+			return header, nil, fmt.Errorf("more than %d events", maxReplayEvents)
+		}
+		var event ReplayEvent
+		if err := json.Unmarshal(lines.Bytes(), &event); err != nil {
+			return header, nil, fmt.Errorf("event %d: %w", len(events)+1, err)
+		}
+		events = append(events, event)
+	}
+	if err := lines.Err(); err != nil {
+		return header, nil, err
+	}
+	return header, events, nil
+}
+
+// ExportMatchReplay downloads a replay file of a played tournament match
+func (h *RobotHandler) ExportMatchReplay(c *gin.Context) {
+	tournament, exists := h.tournaments.Get(currentTenant(c), c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tournament not found"})
+		return
+	}
+	number, err := strconv.Atoi(c.Param("match"))
+	if err != nil || number < 1 || number > len(tournament.Matches) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Match not found"})
+		return
+	}
+	match := tournament.Matches[number-1]
+	if match.Status != MatchPlayed || match.scenario == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "The match has not been played"})
+		return
+	}
+
+	// The scenario and the seed determine the match, so playing it again
+	// gives the events it was played with
+	seed := tournament.Seed + int64(match.ID)
+	events, _, err := replayMatch(*match.scenario, seed)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	var file bytes.Buffer
+	if err := WriteReplay(&file, ReplayHeader{Seed: seed, Scenario: *match.scenario, PlayedAt: *match.PlayedAt}, events); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-match-%d.replay.ndjson.gz"`, tournament.ID, match.ID))
+	c.Data(http.StatusOK, ReplayContentType, file.Bytes())
+}
+
+// ImportReplay plays the match of an uploaded replay file again from its
+// seed and scenario and compares the events with the recorded ones. The
+// response has the replayed events and results and, if they differ, the
+// first event that does.
+func (h *RobotHandler) ImportReplay(c *gin.Context) {
+	data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxReplaySize+1))
+	if err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if len(data) > maxReplaySize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "The replay file is too large"})
+		return
+	}
+	header, recorded, err := ReadReplay(bytes.NewReader(data))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid replay file: " + err.Error()})
+		return
+	}
+	if err := header.Scenario.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid replay scenario: " + err.Error()})
+		return
+	}
+	replayed, match, err := replayMatch(header.Scenario, header.Seed)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid replay scenario: " + err.Error()})
+		return
+	}
+
+	response := gin.H{
+		"replay":   header,
+		"verified": true,
+		"match":    match,
+		"events":   replayed,
+	}
+	for i := 0; i < max(len(recorded), len(replayed)); i++ {
+		divergence := gin.H{"index": i}
+		if i < len(recorded) {
+			divergence["recorded"] = recorded[i]
+		}
+		if i < len(replayed) {
+			divergence["replayed"] = replayed[i]
+		}
+		if i >= len(recorded) || i >= len(replayed) || recorded[i] != replayed[i] {
+			response["verified"] = false
+			response["divergence"] = divergence
+			break
+		}
+	}
+	c.JSON(http.StatusOK, response)
+}
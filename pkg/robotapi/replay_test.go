@@ -0,0 +1,138 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type replayResponse struct {
+	Replay     ReplayHeader    `json:"replay"`
+	Verified   bool            `json:"verified"`
+	Match      TournamentMatch `json:"match"`
+	Events     []ReplayEvent   `json:"events"`
+	Divergence *struct {
+		Index    int          `json:"index"`
+		Recorded *ReplayEvent `json:"recorded"`
+		Replayed *ReplayEvent `json:"replayed"`
+	} `json:"divergence"`
+}
+
+// setupReplayTournament starts a bracket tournament of two teams and returns
+// the router, the handler and the tournament's ID
+func setupReplayTournament(t *testing.T) (*gin.Engine, *RobotHandler, string) {
+	router, handler := setupTournamentRouter(t)
+	router.GET("/tournaments/:id/matches/:match/replay", handler.ExportMatchReplay)
+	router.POST("/replays", handler.ImportReplay)
+
+	id := tournamentRequest(t, router, "POST", "/tournaments", `{"name": "Replay Cup", "format": "bracket", "seed": 7, "rounds": 20}`, http.StatusCreated).Tournament.ID
+	for _, participant := range []string{
+		`{"id": "heavy", "robots": ["tank", "drone"]}`,
+		`{"id": "light", "robots": ["scout", "robot1"]}`,
+	} {
+		tournamentRequest(t, router, "POST", "/tournaments/"+id+"/participants", participant, http.StatusCreated)
+	}
+	tournamentRequest(t, router, "POST", "/tournaments/"+id+"/start", "", http.StatusOK)
+	return router, handler, id
+}
+
+func importReplay(t *testing.T, router *gin.Engine, file []byte) replayResponse {
+	w := send(router, "POST", "/replays", string(file))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response replayResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestExportAndImportMatchReplay(t *testing.T) {
+	router, handler, id := setupReplayTournament(t)
+	assert.Equal(t, http.StatusConflict, send(router, "GET", "/tournaments/"+id+"/matches/1/replay", "").Code)
+	played := tournamentRequest(t, router, "POST", "/tournaments/"+id+"/run", "", http.StatusOK).Played[0]
+
+	// Later changes to the robots do not change the recorded match
+	_, err := handler.storage.UpdateRobot("tank", func(robot *Robot) error {
+		robot.Energy = 1
+		return nil
+	})
+	require.NoError(t, err)
+
+	w := send(router, "GET", "/tournaments/"+id+"/matches/1/replay", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, ReplayContentType, w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "match-1.replay.ndjson.gz")
+	file := w.Body.Bytes()
+
+	header, events, err := ReadReplay(bytes.NewReader(file))
+	require.NoError(t, err)
+	assert.Equal(t, int64(8), header.Seed)
+	assert.Equal(t, len(events), header.Events)
+	assert.Equal(t, 20, header.Scenario.Rounds)
+	assert.Equal(t, 100, header.Scenario.Sides[0].Robots[0].Energy)
+	assert.Equal(t, ReplayAttackEvent, events[0].Type)
+	last := events[len(events)-1]
+	assert.Equal(t, ReplayMatchEvent, last.Type)
+	assert.Equal(t, played.Winner, last.Winner)
+
+	// Another instance plays the match again and gets the same events
+	other := gin.New()
+	other.POST("/replays", NewRobotHandler(NewRobotStorage()).ImportReplay)
+	response := importReplay(t, other, file)
+	assert.True(t, response.Verified)
+	assert.Nil(t, response.Divergence)
+	assert.Equal(t, events, response.Events)
+	assert.Equal(t, played.Duels, response.Match.Duels)
+	assert.Equal(t, played.Winner, response.Match.Winner)
+
+	// A tampered file is detected
+	for i := range events {
+		if events[i].Type == ReplayDuelEvent {
+			events[i].Winner = "nobody"
+			break
+		}
+	}
+	var tampered bytes.Buffer
+	require.NoError(t, WriteReplay(&tampered, header, events))
+	response = importReplay(t, router, tampered.Bytes())
+	assert.False(t, response.Verified)
+	require.NotNil(t, response.Divergence)
+	assert.Equal(t, "nobody", response.Divergence.Recorded.Winner)
+	assert.NotEqual(t, "nobody", response.Divergence.Replayed.Winner)
+}
+
+func TestImportPlainReplay(t *testing.T) {
+	router, _, _ := setupReplayTournament(t)
+	header := `{"format": "robot-api-replay", "version": 1, "seed": 1, "scenario": {"format": "round_robin", "combat_rules": "percentage", "accuracy": 100, "rounds": 5,
+		"sides": [{"participant": "a", "robots": [{"id": "r1", "energy": 10}]}, {"participant": "b", "robots": [null]}]}}`
+	response := importReplay(t, router, []byte(strings.ReplaceAll(header, "\n", "")+"\n"))
+	assert.False(t, response.Verified, "no events were recorded")
+	assert.Equal(t, "a", response.Match.Winner)
+	assert.Equal(t, []ReplayEvent{
+		{Type: ReplayDuelEvent, Duel: 1, A: "r1", Winner: "r1"},
+		{Type: ReplayMatchEvent, Winner: "a"},
+	}, response.Events)
+}
+
+func TestReplayErrors(t *testing.T) {
+	router, _, id := setupReplayTournament(t)
+	assert.Equal(t, http.StatusNotFound, send(router, "GET", "/tournaments/nope/matches/1/replay", "").Code)
+	assert.Equal(t, http.StatusNotFound, send(router, "GET", "/tournaments/"+id+"/matches/9/replay", "").Code)
+
+	for name, file := range map[string]string{
+		"empty":          "",
+		"garbage":        "not a replay",
+		"other format":   `{"format": "pcap", "version": 1}`,
+		"newer version":  `{"format": "robot-api-replay", "version": 2}`,
+		"broken gzip":    "\x1f\x8b\x08garbage",
+		"invalid rounds": `{"format": "robot-api-replay", "version": 1, "scenario": {"format": "bracket", "rounds": 0, "sides": [{"participant": "a"}, {"participant": "b"}]}}`,
+		"unknown rules":  `{"format": "robot-api-replay", "version": 1, "scenario": {"format": "bracket", "combat_rules": "chess", "rounds": 1, "sides": [{"participant": "a"}, {"participant": "b"}]}}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/replays", file).Code, name)
+	}
+	assert.Equal(t, http.StatusRequestEntityTooLarge, send(router, "POST", "/replays", strings.Repeat(" ", maxReplaySize+1)).Code)
+}
@@ -0,0 +1,174 @@
+// Package robotapitest replays golden files recorded by the robot API
+// (Config.RecordDir) against a handler, so requests made while exploring the
+// API by hand become a regression test:
+//
+//	server, _ := robotapi.New(robotapi.DefaultConfig())
+//	robotapitest.Replay(t, server.Router(), "testdata/golden", robotapitest.Options{})
+package robotapitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"aufgabe-2/pkg/robotapi"
+)
+
+// volatileFields change from run to run and are not compared
+var volatileFields = []string{"command_id"}
+
+// Options adjust a replay
+type Options struct {
+	// Header is added to every request, e.g. the credentials recordings
+	// leave out
+	Header http.Header
+	// Ignore lists further JSON fields whose values are not compared, at
+	// any depth, e.g. generated IDs
+	Ignore []string
+	// Update rewrites the golden files with the responses the handler
+	// gives now instead of comparing them
+	Update bool
+}
+
+// Replay sends the requests of the golden files in dir to the handler in
+// the order they were recorded and compares status and body of every
+// response with the recording. Timestamps only have to be timestamps on
+// both sides. Each file is a subtest, and a mismatch does not stop the
+// replay.
+func Replay(t *testing.T, handler http.Handler, dir string, options Options) {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no golden files in %s", dir)
+	}
+	sort.Strings(files)
+
+	ignore := map[string]bool{}
+	for _, field := range append(volatileFields, options.Ignore...) {
+		ignore[field] = true
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			replayFile(t, handler, file, options, ignore)
+		})
+	}
+}
+
+func replayFile(t *testing.T, handler http.Handler, file string, options Options, ignore map[string]bool) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exchange robotapi.GoldenExchange
+	if err := json.Unmarshal(data, &exchange); err != nil {
+		t.Fatalf("invalid golden file: %v", err)
+	}
+
+	var body []byte
+	if len(exchange.Request.Body) > 0 {
+		body = exchange.Request.Body
+		var text string
+		if json.Unmarshal(body, &text) == nil {
+			body = []byte(text) // a body that was not JSON
+		}
+	}
+	request := httptest.NewRequest(exchange.Request.Method, exchange.Request.URL, bytes.NewReader(body))
+	for name, value := range exchange.Request.Header {
+		request.Header.Set(name, value)
+	}
+	for name, values := range options.Header {
+		request.Header[name] = values
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	actual := robotapi.GoldenResponse{Status: recorder.Code, Body: robotapi.GoldenBody(recorder.Body.Bytes())}
+
+	if options.Update {
+		exchange.Response = actual
+		if err := robotapi.WriteGoldenFile(file, exchange); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	if actual.Status != exchange.Response.Status {
+		t.Errorf("%s %s: status %d, recorded %d", exchange.Request.Method, exchange.Request.URL, actual.Status, exchange.Response.Status)
+	}
+	if diff := Compare(exchange.Response.Body, actual.Body, ignore); diff != "" {
+		t.Errorf("%s %s: %s", exchange.Request.Method, exchange.Request.URL, diff)
+	}
+}
+
+// Compare reports how two golden bodies differ, ignoring the values of the
+// given fields and the values of timestamps; it returns "" if they match
+func Compare(expected, actual json.RawMessage, ignore map[string]bool) string {
+	var want, got interface{}
+	if len(expected) > 0 {
+		if err := json.Unmarshal(expected, &want); err != nil {
+			return "invalid recorded body: " + err.Error()
+		}
+	}
+	if len(actual) > 0 {
+		if err := json.Unmarshal(actual, &got); err != nil {
+			return "invalid body: " + err.Error()
+		}
+	}
+	want, got = normalize(lines(want), ignore), normalize(lines(got), ignore)
+	wantJSON, _ := json.MarshalIndent(want, "", "  ")
+	gotJSON, _ := json.MarshalIndent(got, "", "  ")
+	if bytes.Equal(wantJSON, gotJSON) {
+		return ""
+	}
+	return "body differs from the recording\nrecorded: " + string(wantJSON) + "\nactual:   " + string(gotJSON)
+}
+
+// lines decodes a text body of JSON lines, like an NDJSON export, so its
+// lines are compared as JSON
+func lines(body interface{}) interface{} {
+	text, isText := body.(string)
+	if !isText {
+		return body
+	}
+	decoded := []interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		var value interface{}
+		if err := json.Unmarshal([]byte(line), &value); err != nil {
+			return body
+		}
+		decoded = append(decoded, value)
+	}
+	return decoded
+}
+
+// normalize blanks the ignored fields and timestamps of a decoded body
+func normalize(value interface{}, ignore map[string]bool) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if ignore[key] {
+				value[key] = "<ignored>"
+				continue
+			}
+			value[key] = normalize(field, ignore)
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = normalize(item, ignore)
+		}
+	case string:
+		if _, err := time.Parse(time.RFC3339Nano, value); err == nil {
+			return "<timestamp>"
+		}
+	}
+	return value
+}
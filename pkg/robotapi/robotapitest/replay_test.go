@@ -0,0 +1,53 @@
+package robotapitest
+
+import (
+	"flag"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"aufgabe-2/pkg/robotapi"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+func testRouter(t *testing.T, recordDir string) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	config := robotapi.DefaultConfig()
+	config.RandomSeed = 1
+	config.RecordDir = recordDir
+	server, err := robotapi.New(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server.Router()
+}
+
+func TestReplayRecordedSession(t *testing.T) {
+	Replay(t, testRouter(t, ""), "testdata/golden", Options{Update: *update})
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	dir := t.TempDir()
+	router := testRouter(t, dir)
+	for _, body := range []string{`{"direction": "up"}`, `{"direction": "right"}`} {
+		request := httptest.NewRequest("POST", "/robot/robot2/move", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(httptest.NewRecorder(), request)
+	}
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/robot/robot2/actions/export", nil))
+
+	Replay(t, testRouter(t, ""), dir, Options{})
+}
+
+func TestCompare(t *testing.T) {
+	ignore := map[string]bool{"command_id": true}
+	assert.Empty(t, Compare([]byte(`{"at": "2026-01-01T00:00:00Z", "command_id": "a"}`),
+		[]byte(`{"at": "2026-10-16T12:00:00.5Z", "command_id": "b"}`), ignore))
+	assert.Empty(t, Compare(nil, nil, ignore))
+	assert.Contains(t, Compare([]byte(`{"energy": 99}`), []byte(`{"energy": 98}`), ignore), "differs")
+	assert.NotEmpty(t, Compare([]byte(`{"at": "2026-01-01T00:00:00Z"}`), []byte(`{"at": "soon"}`), ignore))
+}
@@ -0,0 +1,301 @@
+package robotapi
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rosReconnectDelay is the pause before the bridge reconnects to rosbridge
+const rosReconnectDelay = 5 * time.Second
+
+// rosConn is a connection to a rosbridge server speaking its JSON protocol
+type rosConn interface {
+	WriteJSON(v interface{}) error
+	ReadJSON(v interface{}) error
+	Close() error
+}
+
+// rosMessage is an operation of the rosbridge protocol
+type rosMessage struct {
+	Op      string          `json:"op"`
+	ID      string          `json:"id,omitempty"`
+	Topic   string          `json:"topic,omitempty"`
+	Type    string          `json:"type,omitempty"`
+	Msg     json.RawMessage `json:"msg,omitempty"`
+	Service string          `json:"service,omitempty"`
+	Args    interface{}     `json:"args,omitempty"`
+	Result  *bool           `json:"result,omitempty"`
+}
+
+// rosOdometry is the part of a nav_msgs/msg/Odometry message the bridge uses
+type rosOdometry struct {
+	Pose struct {
+		Pose struct {
+			Position struct {
+				X float64 `json:"x"`
+				Y float64 `json:"y"`
+			} `json:"position"`
+			Orientation rosQuaternion `json:"orientation"`
+		} `json:"pose"`
+	} `json:"pose"`
+}
+
+// rosQuaternion is a geometry_msgs/msg/Quaternion
+type rosQuaternion struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+	W float64 `json:"w"`
+}
+
+// yawQuaternion turns a robot heading (degrees clockwise from north) into
+// a ROS orientation (counterclockwise from +x around z)
+func yawQuaternion(heading float64) rosQuaternion {
+	yaw := (90 - heading) * math.Pi / 180
+	return rosQuaternion{Z: math.Sin(yaw / 2), W: math.Cos(yaw / 2)}
+}
+
+// heading turns the orientation back into degrees clockwise from north
+func (q rosQuaternion) heading() float64 {
+	yaw := math.Atan2(2*(q.W*q.Z+q.X*q.Y), 1-2*(q.Y*q.Y+q.Z*q.Z))
+	return math.Mod(450-yaw*180/math.Pi, 360)
+}
+
+// rosName makes a robot ID usable as a ROS name token, which must start
+// with a letter and may only contain letters, digits and underscores
+func rosName(id string) string {
+	name := strings.ReplaceAll(id, "-", "_")
+	if name == "" || name[0] < 'A' || (name[0] > 'Z' && name[0] < 'a') || name[0] > 'z' {
+		name = "r_" + name
+	}
+	return name
+}
+
+// ROSBridge mirrors robot commands to a ROS 2 system through rosbridge and
+// feeds odometry back into the robots' positions. Every robot gets the
+// topics <namespace>/<robot>/goal_pose, cmd_vel, command and odom.
+type ROSBridge struct {
+	storage   *RobotStorage
+	events    *EventBus
+	dial      func() (rosConn, error)
+	namespace string
+
+	odomTopics map[string]string // odom topic -> robot ID
+	advertised map[string]bool
+	mutex      sync.Mutex
+}
+
+// NewROSBridge creates a bridge that connects through dial, e.g. to
+// ws://localhost:9090. The namespace prefixes all topics and may be empty.
+func NewROSBridge(storage *RobotStorage, events *EventBus, dial func() (rosConn, error), namespace string) *ROSBridge {
+	return &ROSBridge{
+		storage:   storage,
+		events:    events,
+		dial:      dial,
+		namespace: "/" + strings.Trim(namespace, "/"),
+	}
+}
+
+// DialROSBridge returns a dial function for a rosbridge WebSocket URL
+func DialROSBridge(url string) func() (rosConn, error) {
+	return func() (rosConn, error) {
+		return dialWebSocket(url)
+	}
+}
+
+// Run keeps the bridge connected until ctx is done
+func (b *ROSBridge) Run(ctx context.Context) {
+	for {
+		conn, err := b.dial()
+		if err == nil {
+			err = b.serve(ctx, conn)
+			conn.Close()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		log.Printf("ROS bridge disconnected, retrying in %s: %v", rosReconnectDelay, err)
+
+		select {
+		case <-time.After(rosReconnectDelay):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// topic returns the name of a robot's topic
+func (b *ROSBridge) topic(robotID, name string) string {
+	return strings.TrimSuffix(b.namespace, "/") + "/" + rosName(robotID) + "/" + name
+}
+
+// serve mirrors events over one connection until it fails or ctx is done
+func (b *ROSBridge) serve(ctx context.Context, conn rosConn) error {
+	// Subscribe before the odometry topics so no new robot is missed
+	id, events := b.events.Subscribe()
+	defer b.events.Unsubscribe(id)
+
+	b.mutex.Lock()
+	b.odomTopics = make(map[string]string)
+	b.advertised = make(map[string]bool)
+	b.mutex.Unlock()
+	for _, robot := range b.storage.GetAllRobots() {
+		if err := b.subscribeOdometry(conn, robot.ID); err != nil {
+			return err
+		}
+	}
+
+	failed := make(chan error, 1)
+	go func() {
+		for {
+			var message rosMessage
+			if err := conn.ReadJSON(&message); err != nil {
+				failed <- err
+				return
+			}
+			b.receive(message)
+		}
+	}()
+
+	for {
+		select {
+		case event, open := <-events:
+			if !open {
+				return ErrSlowConsumer
+			}
+			if err := b.mirror(conn, event); err != nil {
+				return err
+			}
+		case err := <-failed:
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// subscribeOdometry asks rosbridge for the odometry of a robot
+func (b *ROSBridge) subscribeOdometry(conn rosConn, robotID string) error {
+	topic := b.topic(robotID, "odom")
+	b.mutex.Lock()
+	b.odomTopics[topic] = robotID
+	b.mutex.Unlock()
+	return conn.WriteJSON(rosMessage{Op: "subscribe", Topic: topic, Type: "nav_msgs/msg/Odometry"})
+}
+
+// publish sends a message, advertising the topic on first use
+func (b *ROSBridge) publish(conn rosConn, topic, msgType string, msg interface{}) error {
+	b.mutex.Lock()
+	advertised := b.advertised[topic]
+	b.advertised[topic] = true
+	b.mutex.Unlock()
+	if !advertised {
+		if err := conn.WriteJSON(rosMessage{Op: "advertise", Topic: topic, Type: msgType}); err != nil {
+			return err
+		}
+	}
+
+	encoded, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return conn.WriteJSON(rosMessage{Op: "publish", Topic: topic, Msg: encoded})
+}
+
+// mirror forwards a command event to ROS. Grid moves become navigation
+// goals, velocities become cmd_vel in the map frame, other robot commands
+// are sent as JSON on the command topic and the emergency stop is a service.
+func (b *ROSBridge) mirror(conn rosConn, event Event) error {
+	data, _ := event.Data.(gin.H)
+	switch event.Type {
+	case "robot_created":
+		return b.subscribeOdometry(conn, event.RobotID)
+	case "robot_moved":
+		robot, err := b.storage.GetRobot(event.RobotID)
+		if err != nil {
+			return nil
+		}
+		position, _ := data["position"].(Position)
+		return b.publish(conn, b.topic(event.RobotID, "goal_pose"), "geometry_msgs/msg/PoseStamped", gin.H{
+			"header": gin.H{"frame_id": "map"},
+			"pose": gin.H{
+				"position":    gin.H{"x": position.X, "y": position.Y, "z": 0},
+				"orientation": yawQuaternion(robot.currentHeading()),
+			},
+		})
+	case "robot_velocity_changed":
+		velocity, _ := data["commanded_velocity"].(Vector)
+		return b.publish(conn, b.topic(event.RobotID, "cmd_vel"), "geometry_msgs/msg/Twist", gin.H{
+			"linear":  gin.H{"x": velocity.X, "y": velocity.Y, "z": 0},
+			"angular": gin.H{"x": 0, "y": 0, "z": 0},
+		})
+	case "item_picked_up", "item_put_down", "robot_attacked", "maintenance_started", "maintenance_ended":
+		robotID, command := event.RobotID, gin.H{"command": event.Type}
+		for key, value := range data {
+			command[key] = value
+		}
+		// Attack events belong to the target, the command to the attacker
+		if attacker, ok := data["attacker"].(string); ok {
+			robotID = attacker
+			command["target"] = event.RobotID
+			delete(command, "attacker")
+		}
+		encoded, err := json.Marshal(command)
+		if err != nil {
+			return err
+		}
+		return b.publish(conn, b.topic(robotID, "command"), "std_msgs/msg/String", gin.H{"data": string(encoded)})
+	case "estop_engaged", "estop_cleared":
+		return conn.WriteJSON(rosMessage{
+			Op:      "call_service",
+			ID:      event.Type,
+			Service: strings.TrimSuffix(b.namespace, "/") + "/emergency_stop",
+			Type:    "std_srvs/srv/SetBool",
+			Args:    gin.H{"data": event.Type == "estop_engaged"},
+		})
+	}
+	return nil
+}
+
+// receive handles a message from rosbridge
+func (b *ROSBridge) receive(message rosMessage) {
+	switch message.Op {
+	case "publish":
+		b.mutex.Lock()
+		robotID, isOdometry := b.odomTopics[message.Topic]
+		b.mutex.Unlock()
+		if !isOdometry {
+			return
+		}
+		var odometry rosOdometry
+		if err := json.Unmarshal(message.Msg, &odometry); err != nil {
+			log.Printf("ROS bridge: invalid odometry on %s: %v", message.Topic, err)
+			return
+		}
+		pose := odometry.Pose.Pose
+		b.storage.ApplyOdometry(robotID, Vector{X: pose.Position.X, Y: pose.Position.Y}, pose.Orientation.heading())
+	case "service_response":
+		if message.Result != nil && !*message.Result {
+			log.Printf("ROS bridge: service call %s failed", message.ID)
+		}
+	}
+}
+
+// ApplyOdometry moves a robot to the pose its hardware reports. The real
+// world is authoritative, so obstacles and energy are not checked.
+func (s *RobotStorage) ApplyOdometry(robotID string, exact Vector, heading float64) error {
+	_, err := s.UpdateRobot(robotID, func(robot *Robot) error {
+		robot.Position = exact.cell(robot.Position.Z)
+		robot.Exact = &exact
+		robot.heading, robot.hasHeading = heading, true
+		return nil
+	})
+	return err
+}
@@ -0,0 +1,141 @@
+package robotapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeROSConn is an in-memory rosbridge connection
+type fakeROSConn struct {
+	sent     chan rosMessage
+	incoming chan rosMessage
+	closed   chan struct{}
+}
+
+func newFakeROSConn() *fakeROSConn {
+	return &fakeROSConn{sent: make(chan rosMessage, 64), incoming: make(chan rosMessage, 64), closed: make(chan struct{})}
+}
+
+func (c *fakeROSConn) WriteJSON(v interface{}) error {
+	encoded, _ := json.Marshal(v)
+	var message rosMessage
+	json.Unmarshal(encoded, &message)
+	c.sent <- message
+	return nil
+}
+
+func (c *fakeROSConn) ReadJSON(v interface{}) error {
+	select {
+	case message := <-c.incoming:
+		encoded, _ := json.Marshal(message)
+		return json.Unmarshal(encoded, v)
+	case <-c.closed:
+		return io.EOF
+	}
+}
+
+func (c *fakeROSConn) Close() error {
+	return nil
+}
+
+func (c *fakeROSConn) next(t *testing.T) rosMessage {
+	select {
+	case message := <-c.sent:
+		return message
+	case <-time.After(time.Second):
+		t.Fatal("no message sent to rosbridge")
+		return rosMessage{}
+	}
+}
+
+func TestROSBridge(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	events := NewEventBus()
+	conn := newFakeROSConn()
+	bridge := NewROSBridge(storage, events, func() (rosConn, error) { return conn, nil }, "fleet")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go bridge.Run(ctx)
+
+	subscribed := map[string]bool{}
+	for range storage.GetAllRobots() {
+		message := conn.next(t)
+		assert.Equal(t, "subscribe", message.Op)
+		assert.Equal(t, "nav_msgs/msg/Odometry", message.Type)
+		subscribed[message.Topic] = true
+	}
+	assert.True(t, subscribed["/fleet/robot1/odom"])
+
+	// Grid moves become navigation goals, advertised on first use
+	events.Publish("robot_moved", "robot1", gin.H{"position": Position{X: 1, Y: 2}, "direction": "up"})
+	message := conn.next(t)
+	assert.Equal(t, rosMessage{Op: "advertise", Topic: "/fleet/robot1/goal_pose", Type: "geometry_msgs/msg/PoseStamped"}, message)
+	message = conn.next(t)
+	assert.Equal(t, "publish", message.Op)
+	assert.Contains(t, string(message.Msg), `"position":{"x":1,"y":2,"z":0}`)
+
+	events.Publish("robot_attacked", "robot2", gin.H{"attacker": "robot1", "hit": true, "damage": 10})
+	conn.next(t)
+	message = conn.next(t)
+	assert.Equal(t, "/fleet/robot1/command", message.Topic)
+	var command struct {
+		Data string `json:"data"`
+	}
+	json.Unmarshal(message.Msg, &command)
+	assert.JSONEq(t, `{"command": "robot_attacked", "target": "robot2", "hit": true, "damage": 10}`, command.Data)
+
+	events.Publish("estop_engaged", "", gin.H{"reason": "test"})
+	message = conn.next(t)
+	assert.Equal(t, "call_service", message.Op)
+	assert.Equal(t, "/fleet/emergency_stop", message.Service)
+
+	// New robots get their odometry subscribed, with IDs made valid ROS names
+	events.Publish("robot_created", "7f-arm", nil)
+	assert.Equal(t, "/fleet/r_7f_arm/odom", conn.next(t).Topic)
+
+	// Odometry is authoritative for the robot's position and heading
+	conn.incoming <- rosMessage{Op: "publish", Topic: "/fleet/robot1/odom",
+		Msg: json.RawMessage(`{"pose": {"pose": {"position": {"x": 3.4, "y": -1.2}, "orientation": {"z": 0, "w": 1}}}}`)}
+	assert.Eventually(t, func() bool {
+		robot, _ := storage.GetRobot("robot1")
+		return robot.Position == Position{X: 3, Y: -1}
+	}, time.Second, 10*time.Millisecond)
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, &Vector{X: 3.4, Y: -1.2}, robot.Exact)
+	assert.InDelta(t, 90, robot.currentHeading(), 1e-9)
+}
+
+func TestWebSocketFrames(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	clientConn := &wsConn{conn: client, reader: bufio.NewReader(client), mask: true}
+	serverConn := &wsConn{conn: server, reader: bufio.NewReader(server)}
+
+	// Long enough for the 16 bit length encoding
+	sent := map[string]string{"op": "publish", "topic": strings.Repeat("x", 300)}
+	go clientConn.WriteJSON(sent)
+	var received map[string]string
+	assert.NoError(t, serverConn.ReadJSON(&received))
+	assert.Equal(t, sent, received)
+
+	// Pings are answered while waiting for the next message
+	go func() {
+		server.Write([]byte{0x80 | wsPing, 2, 'h', 'i'})
+		serverConn.readFrame()
+		server.Write([]byte{0x80 | wsClose, 0})
+		serverConn.readFrame()
+	}()
+	assert.ErrorIs(t, clientConn.ReadJSON(&received), io.EOF)
+}
@@ -0,0 +1,214 @@
+package robotapi
+
+import (
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Kinds of searchable documents
+const (
+	searchRobot  = "robot"
+	searchItem   = "item"
+	searchAction = "action"
+)
+
+// searchDoc identifies a document in the search index. Actions are
+// identified by the robot ID and their 1-based position in the history.
+type searchDoc struct {
+	kind   string
+	id     string
+	action int
+}
+
+// SearchIndex is an in-memory inverted index from tokens to documents
+type SearchIndex struct {
+	postings map[string]map[searchDoc]int // token -> document -> term frequency
+	docs     map[searchDoc][]string       // document -> tokens, needed for removal
+	tokens   []string                     // sorted vocabulary for prefix lookups
+	mutex    sync.RWMutex
+}
+
+// NewSearchIndex creates an empty search index
+func NewSearchIndex() *SearchIndex {
+	return &SearchIndex{
+		postings: make(map[string]map[searchDoc]int),
+		docs:     make(map[searchDoc][]string),
+	}
+}
+
+// tokenize splits text into lower-case words of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Index adds a document, replacing a previous version with the same key
+func (ix *SearchIndex) Index(doc searchDoc, texts ...string) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+
+	ix.remove(doc)
+
+	var tokens []string
+	for _, text := range texts {
+		tokens = append(tokens, tokenize(text)...)
+	}
+	for _, token := range tokens {
+		if ix.postings[token] == nil {
+			ix.postings[token] = make(map[searchDoc]int)
+			i := sort.SearchStrings(ix.tokens, token)
+			ix.tokens = slices.Insert(ix.tokens, i, token)
+		}
+		ix.postings[token][doc]++
+	}
+	ix.docs[doc] = tokens
+}
+
+// Remove deletes a document from the index
+func (ix *SearchIndex) Remove(doc searchDoc) {
+	ix.mutex.Lock()
+	defer ix.mutex.Unlock()
+	ix.remove(doc)
+}
+
+func (ix *SearchIndex) remove(doc searchDoc) {
+	for _, token := range ix.docs[doc] {
+		postings, exists := ix.postings[token]
+		if !exists {
+			continue // token appeared twice in the document
+		}
+		delete(postings, doc)
+		if len(postings) == 0 {
+			delete(ix.postings, token)
+			i := sort.SearchStrings(ix.tokens, token)
+			ix.tokens = slices.Delete(ix.tokens, i, i+1)
+		}
+	}
+	delete(ix.docs, doc)
+}
+
+// searchHit is a scored document matching a query
+type searchHit struct {
+	doc   searchDoc
+	score int
+}
+
+// Search returns all documents matching at least one query token, ordered by
+// relevance. Exact token matches score higher than prefix matches.
+func (ix *SearchIndex) Search(query string) []searchHit {
+	ix.mutex.RLock()
+	defer ix.mutex.RUnlock()
+
+	scores := make(map[searchDoc]int)
+	for _, term := range tokenize(query) {
+		// All tokens starting with the term form a contiguous range of the
+		// sorted vocabulary, beginning with the term itself if it is known
+		for i := sort.SearchStrings(ix.tokens, term); i < len(ix.tokens); i++ {
+			token := ix.tokens[i]
+			if !strings.HasPrefix(token, term) {
+				break
+			}
+			weight := 1
+			if token == term {
+				weight = 3
+			}
+			for doc, frequency := range ix.postings[token] {
+				scores[doc] += weight * frequency
+			}
+		}
+	}
+
+	hits := make([]searchHit, 0, len(scores))
+	for doc, score := range scores {
+		hits = append(hits, searchHit{doc: doc, score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		if hits[i].doc.id != hits[j].doc.id {
+			return hits[i].doc.id < hits[j].doc.id
+		}
+		return hits[i].doc.action < hits[j].doc.action
+	})
+	return hits
+}
+
+// reindexRobot updates the search index for a robot and any actions added
+// since it was last indexed; callers must hold the lock
+func (s *RobotStorage) reindexRobot(robot *Robot) {
+	s.index.Index(searchDoc{kind: searchRobot, id: robot.ID},
+		append([]string{robot.ID, robot.Name, robot.Class}, robot.Tags...)...)
+
+	for i := s.indexedActions[robot.ID]; i < len(robot.Actions); i++ {
+		action := robot.Actions[i]
+		s.index.Index(searchDoc{kind: searchAction, id: robot.ID, action: i + 1}, action.Type, action.Details)
+	}
+	s.indexedActions[robot.ID] = len(robot.Actions)
+}
+
+// reindexItem updates the search index for an item; callers must hold the lock
+func (s *RobotStorage) reindexItem(item *Item) {
+	s.index.Index(searchDoc{kind: searchItem, id: item.ID}, item.ID, item.Type)
+}
+
+// Search looks up robots, items and actions matching the query and returns
+// them grouped by kind, each group ordered by relevance. Action histories
+// grow without bound, so only the requested page of actions is returned.
+func (s *RobotStorage) Search(query string, page, size int) SearchResults {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	results := SearchResults{
+		Query:   query,
+		Robots:  []RobotSearchResult{},
+		Items:   []ItemSearchResult{},
+		Actions: []ActionSearchResult{},
+	}
+
+	var hits, actionHits []searchHit
+	for _, hit := range s.index.Search(query) {
+		if hit.doc.kind == searchAction {
+			actionHits = append(actionHits, hit)
+		} else {
+			hits = append(hits, hit)
+		}
+	}
+	pageInfo, startIndex, endIndex := paginate(len(actionHits), page, size)
+	results.ActionsPage = pageInfo
+
+	for _, hit := range append(hits, actionHits[startIndex:endIndex]...) {
+		switch hit.doc.kind {
+		case searchRobot:
+			if robot, exists := s.robots[hit.doc.id]; exists {
+				results.Robots = append(results.Robots, RobotSearchResult{
+					ID:    robot.ID,
+					Name:  robot.Name,
+					Tags:  robot.Tags,
+					Score: hit.score,
+				})
+			}
+		case searchItem:
+			if item, exists := s.items[hit.doc.id]; exists {
+				results.Items = append(results.Items, ItemSearchResult{
+					Item:  *item,
+					Score: hit.score,
+				})
+			}
+		case searchAction:
+			if robot, exists := s.robots[hit.doc.id]; exists && hit.doc.action <= len(robot.Actions) {
+				results.Actions = append(results.Actions, ActionSearchResult{
+					RobotID: robot.ID,
+					Number:  hit.doc.action,
+					Action:  robot.Actions[hit.doc.action-1],
+					Score:   hit.score,
+				})
+			}
+		}
+	}
+	return results
+}
@@ -0,0 +1,102 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearchIndexRanking(t *testing.T) {
+	index := NewSearchIndex()
+	index.Index(searchDoc{kind: searchItem, id: "a"}, "battery pack")
+	index.Index(searchDoc{kind: searchItem, id: "b"}, "batteryless tool")
+	index.Index(searchDoc{kind: searchItem, id: "c"}, "tool")
+
+	hits := index.Search("battery")
+	assert.Len(t, hits, 2)
+	assert.Equal(t, "a", hits[0].doc.id) // exact match beats prefix match
+	assert.Equal(t, "b", hits[1].doc.id)
+
+	index.Remove(searchDoc{kind: searchItem, id: "a"})
+	assert.Len(t, index.Search("battery"), 1)
+}
+
+func TestSearchEndpoint(t *testing.T) {
+	router, _ := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/search?q=battery", nil)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var results SearchResults
+	err := json.Unmarshal(w.Body.Bytes(), &results)
+	assert.NoError(t, err)
+	assert.Len(t, results.Items, 2)
+	assert.Empty(t, results.Robots)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/search", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestSearchIsUpdatedOnWrites(t *testing.T) {
+	router, storage := setupTestRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/robots", bytes.NewBufferString(`{"id": "scout", "name": "Night Owl", "tags": ["recon"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	storage.AddAction("robot2", "move", "Moved through the swamp")
+
+	results := storage.Search("owl recon", 1, 20)
+	assert.Len(t, results.Robots, 1)
+	assert.Equal(t, "scout", results.Robots[0].ID)
+	assert.Equal(t, 6, results.Robots[0].Score)
+
+	results = storage.Search("swamp", 1, 20)
+	assert.Len(t, results.Actions, 1)
+	assert.Equal(t, "robot2", results.Actions[0].RobotID)
+	assert.Equal(t, 4, results.Actions[0].Number)
+}
+
+func TestSearchIndexPrefixRange(t *testing.T) {
+	index := NewSearchIndex()
+	index.Index(searchDoc{kind: searchItem, id: "a"}, "tool toolbox")
+	index.Index(searchDoc{kind: searchItem, id: "b"}, "tomato top")
+	assert.Equal(t, []string{"tomato", "tool", "toolbox", "top"}, index.tokens)
+
+	hits := index.Search("too")
+	assert.Len(t, hits, 1)
+	assert.Equal(t, 2, hits[0].score)
+
+	index.Remove(searchDoc{kind: searchItem, id: "a"})
+	assert.Equal(t, []string{"tomato", "top"}, index.tokens)
+}
+
+func TestSearchPaginatesActions(t *testing.T) {
+	router, storage := setupTestRouter()
+	for i := 0; i < 5; i++ {
+		storage.AddAction("robot1", "scan", "Scanned the crater")
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/search?q=crater&page=2&size=2", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var results SearchResults
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	assert.Len(t, results.Actions, 2)
+	assert.Equal(t, 5, results.ActionsPage.TotalElements)
+	assert.Equal(t, 2, results.ActionsPage.Number)
+	assert.True(t, results.ActionsPage.HasNext)
+}
@@ -0,0 +1,134 @@
+package robotapi
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIContentSecurityPolicy forbids JSON responses to load or run anything and
+// to be framed
+const APIContentSecurityPolicy = "default-src 'none'; frame-ancestors 'none'"
+
+// SecurityHeaders are the protective headers of a route group. Every response
+// tells browsers not to guess content types and to send no referrer.
+type SecurityHeaders struct {
+	ContentSecurityPolicy string        // empty leaves the header out
+	HSTSMaxAge            time.Duration // how long browsers stick to HTTPS; only sent over HTTPS, 0 leaves it out
+}
+
+// Middleware sets the headers on the group's responses
+func (h SecurityHeaders) Middleware() gin.HandlerFunc {
+	hsts := "max-age=" + strconv.Itoa(int(h.HSTSMaxAge.Seconds()))
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		header.Set("X-Content-Type-Options", "nosniff")
+		header.Set("Referrer-Policy", "no-referrer")
+		if h.ContentSecurityPolicy != "" {
+			header.Set("Content-Security-Policy", h.ContentSecurityPolicy)
+		}
+		if h.HSTSMaxAge > 0 && c.GetString("scheme") == "https" {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}
+}
+
+// inlineBlocks finds the inline scripts and styles of a page
+var inlineBlocks = regexp.MustCompile(`(?s)<(script|style)>(.*?)</(?:script|style)>`)
+
+// pageContentSecurityPolicy allows a page its own inline scripts and styles,
+// by their hashes, and requests to the API next to it, but nothing else
+func pageContentSecurityPolicy(page []byte) string {
+	sources := map[string][]string{}
+	for _, block := range inlineBlocks.FindAllSubmatch(page, -1) {
+		sum := sha256.Sum256(block[2])
+		kind := string(block[1])
+		sources[kind] = append(sources[kind], "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+	}
+	return strings.Join([]string{
+		"default-src 'none'",
+		"script-src " + strings.Join(append([]string{"'self'"}, sources["script"]...), " "),
+		"style-src " + strings.Join(append([]string{"'self'"}, sources["style"]...), " "),
+		"connect-src 'self'",
+		"img-src 'self' data:",
+		"base-uri 'none'",
+		"form-action 'self'",
+		"frame-ancestors 'none'",
+	}, "; ")
+}
+
+// CSRFProtection guards against cross-site request forgery. Browsers send
+// client certificates on their own, so a page on another site could act with
+// the user's certificate; browser requests authenticated by one must carry a
+// token in X-CSRF-Token that only pages able to read GET /csrf-token have.
+// API keys, signatures, session and ID tokens are sent explicitly by the
+// client and need no token, nor do requests that do not come from a browser.
+type CSRFProtection struct {
+	secret []byte
+}
+
+// NewCSRFProtection creates the protection; instances sharing the secret
+// accept each other's tokens. An empty secret picks a random one.
+func NewCSRFProtection(secret string) *CSRFProtection {
+	if secret == "" {
+		random := make([]byte, 32)
+		rand.Read(random)
+		return &CSRFProtection{secret: random}
+	}
+	return &CSRFProtection{secret: []byte(secret)}
+}
+
+// Token returns the token of a principal
+func (p *CSRFProtection) Token(principalID string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(principalID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// fromBrowser reports whether a request was sent by a browser, which adds
+// Origin to cross-origin writes and Sec-Fetch-Site to all requests
+func fromBrowser(c *gin.Context) bool {
+	return c.GetHeader("Origin") != "" || c.GetHeader("Sec-Fetch-Site") != ""
+}
+
+// Protect rejects unsafe browser requests authenticated by a client
+// certificate without a valid token. It must run after Authenticate.
+func (p *CSRFProtection) Protect() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		principal := currentPrincipal(c)
+		if principal == nil || principal.Provider != "certificate" || !fromBrowser(c) {
+			c.Next()
+			return
+		}
+		if !hmac.Equal([]byte(c.GetHeader("X-CSRF-Token")), []byte(p.Token(principal.ID))) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing or invalid CSRF token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// GetToken returns the caller's token for X-CSRF-Token
+func (p *CSRFProtection) GetToken(c *gin.Context) {
+	principal := currentPrincipal(c)
+	if principal == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Authentication is disabled, no CSRF token needed"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"csrf_token": p.Token(principal.ID)})
+}
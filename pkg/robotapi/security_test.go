@@ -0,0 +1,108 @@
+package robotapi
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	server, err := New(DefaultConfig())
+	require.NoError(t, err)
+	router := server.Router()
+
+	w := send(router, "GET", "/health", "")
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+	assert.Equal(t, APIContentSecurityPolicy, w.Header().Get("Content-Security-Policy"))
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"), "plain HTTP")
+
+	req := httptest.NewRequest("GET", "/health", nil)
+	req.Header.Set("X-Forwarded-Proto", "https")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "max-age=31536000", w.Header().Get("Strict-Transport-Security"))
+
+	// The console may run its own inline script, and nothing else
+	w = send(router, "GET", "/console", "")
+	policy := w.Header().Get("Content-Security-Policy")
+	assert.Contains(t, policy, "default-src 'none'")
+	assert.Contains(t, policy, "connect-src 'self'")
+	script := inlineBlocks.FindAllSubmatch(consolePage, -1)[1]
+	require.Equal(t, "script", string(script[1]))
+	sum := sha256.Sum256(script[2])
+	assert.Contains(t, policy, "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+	assert.NotContains(t, policy, "unsafe-inline")
+}
+
+func TestCSRFProtection(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+
+	keys, _ := NewAPIKeyStore(testAPIKeys)
+	auth := NewAuthenticator(keys, nil, NewSessionStore(0))
+	auth.EnableClientCertificates()
+	csrf := NewCSRFProtection("shared")
+
+	router := gin.New()
+	router.GET("/csrf-token", Authenticate(auth), csrf.GetToken)
+	router.POST("/robots", Authenticate(auth), csrf.Protect(), handler.CreateRobot)
+
+	create := func(id string, prepare func(req *http.Request)) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/robots", bytes.NewBufferString(`{"id": "`+id+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		prepare(req)
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A page on another site submitting with the user's certificate
+	assert.Equal(t, http.StatusForbidden, create("forged", func(req *http.Request) {
+		withClientCert(req, "rover-7")
+		req.Header.Set("Origin", "https://evil.example")
+	}))
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/csrf-token", nil)
+	withClientCert(req, "rover-7")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		CSRFToken string `json:"csrf_token"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, NewCSRFProtection("shared").Token("cert:rover-7"), response.CSRFToken)
+
+	assert.Equal(t, http.StatusCreated, create("rover", func(req *http.Request) {
+		withClientCert(req, "rover-7")
+		req.Header.Set("Sec-Fetch-Site", "same-origin")
+		req.Header.Set("X-CSRF-Token", response.CSRFToken)
+	}))
+	// Tokens are bound to the certificate
+	assert.Equal(t, http.StatusForbidden, create("other", func(req *http.Request) {
+		withClientCert(req, "rover-8")
+		req.Header.Set("Sec-Fetch-Site", "same-origin")
+		req.Header.Set("X-CSRF-Token", response.CSRFToken)
+	}))
+
+	// Devices and clients with explicit credentials need no token
+	assert.Equal(t, http.StatusCreated, create("device", func(req *http.Request) {
+		withClientCert(req, "rover-9")
+	}))
+	assert.Equal(t, http.StatusCreated, create("keyed", func(req *http.Request) {
+		req.Header.Set("X-API-Key", "alice-key")
+		req.Header.Set("Origin", "https://app.example.com")
+	}))
+}
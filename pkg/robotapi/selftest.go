@@ -0,0 +1,254 @@
+package robotapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Self-test modes
+const (
+	SelfTestStrict   = "strict"   // refuse to start if a check fails
+	SelfTestDegraded = "degraded" // start read-only if a check fails
+	SelfTestOff      = "off"
+)
+
+// Self-test checks, run for every world
+const (
+	CheckSeed       = "seed"       // robots and items lie on valid cells, no two robots share one
+	CheckReferences = "references" // inventories, grants, spawn points and floor links point at valid things
+	CheckStorage    = "storage"    // the world survives a snapshot round trip unchanged
+)
+
+// Outcomes of a self-test and its checks
+const (
+	SelfTestPassed = "passed"
+	SelfTestFailed = "failed"
+)
+
+// SelfTestCheck is the outcome of one check of one world
+type SelfTestCheck struct {
+	Name     string   `json:"name"`
+	Tenant   string   `json:"tenant,omitempty"`
+	Status   string   `json:"status"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+// SelfTestReport is the outcome of the startup self-test
+type SelfTestReport struct {
+	Status   string          `json:"status"`
+	Mode     string          `json:"mode"`
+	Degraded bool            `json:"degraded"` // the API was put into read-only mode
+	RanAt    time.Time       `json:"ran_at"`
+	Checks   []SelfTestCheck `json:"checks"`
+}
+
+// Failed returns the failed checks
+func (r SelfTestReport) Failed() []SelfTestCheck {
+	failed := []SelfTestCheck{}
+	for _, check := range r.Checks {
+		if check.Status == SelfTestFailed {
+			failed = append(failed, check)
+		}
+	}
+	return failed
+}
+
+// RunSelfTest checks the default world and the worlds of all tenants
+func RunSelfTest(storage *RobotStorage, tenants *TenantStore, mode string, now time.Time) SelfTestReport {
+	report := SelfTestReport{Status: SelfTestPassed, Mode: mode, RanAt: now.UTC(), Checks: []SelfTestCheck{}}
+	check := func(tenant string, world *RobotStorage) {
+		for _, name := range []string{CheckSeed, CheckReferences, CheckStorage} {
+			var problems []string
+			switch name {
+			case CheckSeed:
+				problems = world.seedProblems()
+			case CheckReferences:
+				problems = world.referenceProblems()
+			case CheckStorage:
+				problems = world.roundTripProblems()
+			}
+			result := SelfTestCheck{Name: name, Tenant: tenant, Status: SelfTestPassed}
+			if len(problems) > 0 {
+				result.Status = SelfTestFailed
+				result.Problems = problems
+				report.Status = SelfTestFailed
+			}
+			report.Checks = append(report.Checks, result)
+		}
+	}
+	check("", storage)
+	for _, tenant := range tenants.All() {
+		check(tenant.ID, tenant.Storage)
+	}
+	return report
+}
+
+// seedProblems finds robots sharing a cell and robots or items on cells they
+// cannot be on
+func (s *RobotStorage) seedProblems() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	problems := []string{}
+	robots := make(map[Position]string) // cell -> first robot on it
+	for _, id := range s.sortedRobotIDs() {
+		p := s.robots[id].Position
+		if other, taken := robots[p]; taken {
+			problems = append(problems, fmt.Sprintf("robots %s and %s share (%d,%d,%d)", other, id, p.X, p.Y, p.Z))
+		} else {
+			robots[p] = id
+		}
+		if s.bounds != nil && !s.bounds.Contains(p) {
+			problems = append(problems, fmt.Sprintf("robot %s at (%d,%d) outside the bounds", id, p.X, p.Y))
+		}
+		if s.obstacles[p] {
+			problems = append(problems, fmt.Sprintf("robot %s at (%d,%d) on an obstacle", id, p.X, p.Y))
+		}
+	}
+	for _, id := range s.sortedItemIDs() {
+		item := s.items[id]
+		if item.carried {
+			continue
+		}
+		if s.bounds != nil && !s.bounds.Contains(item.Position) {
+			problems = append(problems, fmt.Sprintf("item %s at (%d,%d) outside the bounds", id, item.Position.X, item.Position.Y))
+		}
+		if s.obstacles[item.Position] {
+			problems = append(problems, fmt.Sprintf("item %s at (%d,%d) on an obstacle", id, item.Position.X, item.Position.Y))
+		}
+	}
+	for _, violation := range s.invariantViolations() {
+		if violation.Invariant != InvariantPosition {
+			problems = append(problems, fmt.Sprintf("%s %s: %s", violation.Invariant, violation.Subject, violation.Details))
+		}
+	}
+	return problems
+}
+
+// referenceProblems finds inventories holding unknown items, grants for
+// unknown robots and spawn points or floor links robots cannot use
+func (s *RobotStorage) referenceProblems() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	problems := []string{}
+	for _, id := range s.sortedRobotIDs() {
+		for _, itemID := range s.robots[id].Inventory {
+			if _, exists := s.items[itemID]; !exists {
+				problems = append(problems, fmt.Sprintf("robot %s carries unknown item %s", id, itemID))
+			}
+		}
+	}
+	unknown := []string{}
+	for robotID := range s.grants {
+		if _, exists := s.robots[robotID]; !exists {
+			unknown = append(unknown, robotID)
+		}
+	}
+	sort.Strings(unknown)
+	for _, robotID := range unknown {
+		problems = append(problems, fmt.Sprintf("permissions granted on unknown robot %s", robotID))
+	}
+	layout := s.layout()
+	for _, p := range layout.SpawnPoints {
+		if layout.Blocked(p) {
+			problems = append(problems, fmt.Sprintf("spawn point (%d,%d,%d) is blocked", p.X, p.Y, p.Z))
+		}
+	}
+	for _, link := range layout.FloorLinks {
+		upper := Position{X: link.Position.X, Y: link.Position.Y, Z: link.Position.Z + 1}
+		if layout.Blocked(link.Position) || layout.Blocked(upper) {
+			p := link.Position
+			problems = append(problems, fmt.Sprintf("%s at (%d,%d,%d) leads from or to a blocked cell", link.Type, p.X, p.Y, p.Z))
+		}
+	}
+	return problems
+}
+
+// roundTripProblems archives the world like the snapshot archiver, restores
+// it into an empty world and compares the result with the original
+func (s *RobotStorage) roundTripProblems() []string {
+	original := s.Snapshot()
+	var data bytes.Buffer
+	writer := gzip.NewWriter(&data)
+	if err := json.NewEncoder(writer).Encode(original); err != nil {
+		return []string{"cannot encode snapshot: " + err.Error()}
+	}
+	if err := writer.Close(); err != nil {
+		return []string{"cannot compress snapshot: " + err.Error()}
+	}
+	snapshot, err := ReadSnapshot(&data)
+	if err != nil {
+		return []string{"cannot read snapshot: " + err.Error()}
+	}
+
+	restored := NewRobotStorage()
+	restored.SetClock(s.Clock())
+	restored.Restore(snapshot)
+	copied := restored.Snapshot()
+	copied.CreatedAt = original.CreatedAt
+
+	problems := []string{}
+	if want, got := len(original.Robots), len(copied.Robots); want != got {
+		problems = append(problems, fmt.Sprintf("%d of %d robots restored", got, want))
+	}
+	if want, got := len(original.Items), len(copied.Items); want != got {
+		problems = append(problems, fmt.Sprintf("%d of %d items restored", got, want))
+	}
+	want, _ := json.Marshal(original)
+	got, _ := json.Marshal(copied)
+	if len(problems) == 0 && !bytes.Equal(want, got) {
+		problems = append(problems, "restored world differs from the original")
+	}
+	return problems
+}
+
+// sortedItemIDs returns the IDs of all items in order; callers must hold the lock
+func (s *RobotStorage) sortedItemIDs() []string {
+	ids := make([]string, 0, len(s.items))
+	for id := range s.items {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// selfTest runs the startup self-test of the configured mode. In strict mode
+// a failure is returned as an error, in degraded mode it puts the API into
+// read-only mode.
+func selfTest(mode string, storage *RobotStorage, tenants *TenantStore, readOnly *ReadOnlyMode, now time.Time) (*SelfTestReport, error) {
+	if mode == SelfTestOff {
+		return nil, nil
+	}
+	report := RunSelfTest(storage, tenants, mode, now)
+	failed := report.Failed()
+	if len(failed) == 0 {
+		return &report, nil
+	}
+	for _, check := range failed {
+		log.Printf("Self-test %s of world %q failed: %v", check.Name, check.Tenant, check.Problems)
+	}
+	if mode == SelfTestStrict {
+		return &report, fmt.Errorf("self-test failed: %d checks failed", len(failed))
+	}
+	readOnly.Enable("self-test failed", 0)
+	report.Degraded = true
+	return &report, nil
+}
+
+// GetSelfTest returns the report of the startup self-test
+func (h *RobotHandler) GetSelfTest(c *gin.Context) {
+	if h.selfTest == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The self-test is disabled"})
+		return
+	}
+	c.JSON(http.StatusOK, h.selfTest)
+}
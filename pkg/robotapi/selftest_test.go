@@ -0,0 +1,92 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelfTestPassesOnExampleWorld(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	config.Tenants = []string{"acme"}
+	server, err := New(config)
+	assert.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin/selftest", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	server.Router().ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var report SelfTestReport
+	json.Unmarshal(w.Body.Bytes(), &report)
+	assert.Equal(t, SelfTestPassed, report.Status)
+	assert.Equal(t, SelfTestDegraded, report.Mode)
+	assert.False(t, report.Degraded)
+	assert.Len(t, report.Checks, 6) // three checks of two worlds
+	assert.Equal(t, "acme", report.Checks[5].Tenant)
+	assert.Empty(t, report.Failed())
+}
+
+// brokenWorld returns a world with overlapping robots, an item on an
+// obstacle and a grant on a robot that does not exist
+func brokenWorld() *RobotStorage {
+	storage := NewRobotStorage()
+	storage.CreateRobot(&Robot{ID: "a", Energy: MaxEnergy, Position: Position{X: 1, Y: 1}})
+	storage.CreateRobot(&Robot{ID: "b", Energy: MaxEnergy, Position: Position{X: 1, Y: 1}})
+	storage.PlaceItem("crate", Position{X: 3, Y: 3})
+	storage.obstacles[Position{X: 3, Y: 3}] = true
+	storage.grants["ghost"] = map[string][]string{"alice": {PermMove}}
+	return storage
+}
+
+func TestSelfTestFindsBrokenSeeds(t *testing.T) {
+	report := RunSelfTest(brokenWorld(), NewTenantStore(NewRobotStorage), SelfTestStrict, time.Now())
+	assert.Equal(t, SelfTestFailed, report.Status)
+
+	failed := report.Failed()
+	assert.Len(t, failed, 2)
+	assert.Equal(t, CheckSeed, failed[0].Name)
+	assert.Equal(t, []string{
+		"robots a and b share (1,1,0)",
+		"item crate at (3,3) on an obstacle",
+	}, failed[0].Problems)
+	assert.Equal(t, CheckReferences, failed[1].Name)
+	assert.Equal(t, []string{"permissions granted on unknown robot ghost"}, failed[1].Problems)
+}
+
+func TestSelfTestModes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.Storage = brokenWorld()
+	config.SelfTest = SelfTestStrict
+	_, err := New(config)
+	assert.ErrorContains(t, err, "self-test failed: 2 checks failed")
+
+	// Degraded, the API starts read-only
+	config.Storage = brokenWorld()
+	config.SelfTest = SelfTestDegraded
+	server, err := New(config)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, send(server.Router(), "GET", "/robot/a/status", "").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, send(server.Router(), "POST", "/robot/a/move", `{"direction": "up"}`).Code)
+	assert.True(t, server.Handler().selfTest.Degraded)
+
+	config.Storage = brokenWorld()
+	config.SelfTest = SelfTestOff
+	server, err = New(config)
+	assert.NoError(t, err)
+	assert.Nil(t, server.Handler().selfTest)
+	assert.Equal(t, http.StatusOK, send(server.Router(), "POST", "/robot/a/move", `{"direction": "up"}`).Code)
+
+	t.Setenv("SELF_TEST", "sometimes")
+	_, err = ConfigFromEnv()
+	assert.ErrorContains(t, err, "invalid self-test mode")
+}
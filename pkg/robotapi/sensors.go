@@ -0,0 +1,72 @@
+package robotapi
+
+import "math"
+
+// Sensor qualities
+const (
+	SensorPerfect  = "perfect"
+	SensorStandard = "standard"
+	SensorCheap    = "cheap"
+)
+
+// SensorQuality describes how reliably a robot's sensor detects other robots
+type SensorQuality struct {
+	Noise    float64 // standard deviation of the reported position in cells
+	MissRate float64 // chance that a robot in range is not reported
+}
+
+// sensorQualities lists the sensors robots can be built with
+var sensorQualities = map[string]SensorQuality{
+	SensorPerfect:  {},
+	SensorStandard: {Noise: 0.5, MissRate: 0.05},
+	SensorCheap:    {Noise: 1.5, MissRate: 0.2},
+}
+
+// sensorOf returns the sensor quality of the robot; robots without a sensor
+// setting have perfect sensors
+func sensorOf(robot *Robot) SensorQuality {
+	return sensorQualities[robot.Sensor]
+}
+
+// gauss returns a normally distributed value with the given standard deviation
+func (d *dice) gauss(deviation float64) float64 {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.rng.NormFloat64() * deviation
+}
+
+// chance returns true with the given probability
+func (d *dice) chance(probability float64) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.rng.Float64() < probability
+}
+
+// sense reports what the scanning robot's sensor makes of another robot in
+// range. It returns false if the sensor missed the robot. Noisy sightings
+// contain the estimated float position and its standard deviation, and the
+// cell and distance derived from the estimate.
+func sense(noise *dice, layout MapLayout, scanner, other *Robot) (RobotSighting, bool) {
+	sighting := RobotSighting{
+		ID:          other.ID,
+		Position:    other.Position,
+		Distance:    layout.Distance(scanner.Position, other.Position),
+		Maintenance: other.Maintenance,
+	}
+	sensor := sensorOf(scanner)
+	if noise == nil || sensor == (SensorQuality{}) {
+		return sighting, true
+	}
+	if noise.chance(sensor.MissRate) {
+		return RobotSighting{}, false
+	}
+
+	exact := other.exactPosition()
+	estimate := Vector{X: exact.X + noise.gauss(sensor.Noise), Y: exact.Y + noise.gauss(sensor.Noise)}
+	estimate = Vector{X: math.Round(estimate.X*100) / 100, Y: math.Round(estimate.Y*100) / 100}
+	sighting.Position = layout.Wrapped(estimate.cell(other.Position.Z))
+	sighting.Distance = layout.Distance(scanner.Position, sighting.Position)
+	sighting.Estimate = &estimate
+	sighting.Uncertainty = sensor.Noise
+	return sighting, true
+}
@@ -0,0 +1,59 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSensorNoise(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	handler := NewRobotHandler(storage)
+	handler.SetSensorNoise(1)
+	router.POST("/noisy/robots", handler.CreateRobot)
+	router.GET("/noisy/robot/:id/scan", handler.Scan)
+
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/noisy/robots", `{"sensor": "psychic"}`).Code)
+	assert.Equal(t, http.StatusCreated, send(router, "POST", "/noisy/robots", `{"id": "cheap", "sensor": "cheap", "position": {"x": 1, "y": 0}}`).Code)
+
+	// Robots with perfect sensors see exact positions
+	var result ScanResult
+	json.Unmarshal(send(router, "GET", "/noisy/robot/robot1/scan?radius=3", "").Body.Bytes(), &result)
+	assert.Equal(t, SensorPerfect, result.Sensor)
+	assert.Equal(t, []RobotSighting{{ID: "cheap", Position: Position{X: 1, Y: 0}, Distance: 1}}, result.Robots)
+
+	// Cheap sensors scatter the reported position and miss robots now and then
+	misses, exact := 0, 0
+	for i := 0; i < 200; i++ {
+		var result ScanResult
+		json.Unmarshal(send(router, "GET", "/noisy/robot/cheap/scan?radius=3", "").Body.Bytes(), &result)
+		assert.Equal(t, SensorCheap, result.Sensor)
+		if len(result.Robots) == 0 {
+			misses++
+			continue
+		}
+		sighting := result.Robots[0]
+		assert.Equal(t, 1.5, sighting.Uncertainty)
+		assert.Equal(t, sighting.Estimate.cell(0), sighting.Position)
+		if sighting.Position == (Position{X: 0, Y: 0}) {
+			exact++
+		}
+	}
+	assert.InDelta(t, 40, misses, 20)
+	assert.Less(t, exact, 100)
+}
+
+func TestSensorNoiseDisabled(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	handler := NewRobotHandler(storage)
+	router.POST("/exact/robots", handler.CreateRobot)
+	router.GET("/exact/robot/:id/scan", handler.Scan)
+	send(router, "POST", "/exact/robots", `{"id": "cheap", "sensor": "cheap", "position": {"x": 1, "y": 0}}`)
+
+	var result ScanResult
+	json.Unmarshal(send(router, "GET", "/exact/robot/cheap/scan?radius=3", "").Body.Bytes(), &result)
+	assert.Empty(t, result.Sensor)
+	assert.Equal(t, []RobotSighting{{ID: "robot1", Position: Position{X: 0, Y: 0}, Distance: 1}}, result.Robots)
+}
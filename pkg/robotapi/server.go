@@ -0,0 +1,1276 @@
+package robotapi
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"aufgabe-2/pkg/pubsub"
+)
+
+// Config configures a Server. Start from DefaultConfig or ConfigFromEnv;
+// zero values are taken literally.
+type Config struct {
+	Port        string // port Run listens on
+	BasePath    string // prefix of all routes, e.g. "/robots-api"; empty mounts at the root
+	EnableHTTPS bool
+	TLSCertFile string
+	TLSKeyFile  string
+	ClientCA    string // PEM bundle of CAs for client certificates, needs EnableHTTPS
+
+	CORS       CORSPolicy    // web apps allowed to call the API from a browser
+	HSTSMaxAge time.Duration // Strict-Transport-Security of HTTPS responses; 0 leaves it out
+
+	MaxBodyBytes int64  // of JSON request bodies; uploads like behavior modules have limits of their own
+	CSRFSecret   string // shared by all instances; empty picks a random one
+
+	CombatRules        string  // "percentage", "dice" or "armor"
+	EnergyRounding     string  // "truncate", "round" or "carry"
+	IDStrategy         string  // "uuid" or "snowflake"
+	IDCheck            IDCheck // stricter rules for client-supplied IDs, e.g. per tenant; nil keeps the common format
+	Language           string  // language of responses to clients without Accept-Language
+	TickInterval       time.Duration
+	WeatherChangeTicks int64   // 0 keeps it sunny
+	ClockSpeed         float64 // world minutes per tick
+	Quotas             map[string]int
+	SessionTTL         time.Duration
+	MultiFloor         bool
+	Continuous         bool
+	Wrap               bool // bounded maps are a torus
+	BatteryCurve       string
+	MinFirmware        string // empty accepts every firmware
+	Plugins            []string
+	SensorNoise        bool
+	RolloutStageTicks  int64
+	Behaviors          BehaviorLimits // sandbox of the robots' WebAssembly behavior modules
+	SpectatorDelay     time.Duration  // events are shown to spectators this late
+	Spawner            SpawnerConfig  // items the simulation adds over time
+	DecommissionGrace  time.Duration  // decommissioned robots are archived this late
+	BundleRetention    time.Duration  // bundles of archived robots are kept this long
+	HandlerTimeout     time.Duration  // queries get twice as long
+	CacheTTL           time.Duration  // 0 disables the cache
+
+	APIKeys    string      // "name=key,..."
+	OIDC       *OIDCConfig // nil disables ID tokens
+	AdminToken string      // empty disables token access to /admin
+
+	Tenants []string // isolated worlds besides the default one
+
+	ROSBridgeURL string // empty disables the ROS bridge
+	ROSNamespace string
+
+	Webhooks         []string // URLs every event is posted to
+	Sinks            []Sink   // further event consumers, e.g. a message broker
+	DeliveryAttempts int      // failed deliveries are dead-lettered after this many attempts
+
+	SnapshotBucket    string // empty disables snapshot archival
+	SnapshotEndpoint  string // S3 compatible endpoint, e.g. https://storage.googleapis.com
+	SnapshotRegion    string
+	SnapshotAccessKey string
+	SnapshotSecretKey string
+	SnapshotPrefix    string
+	SnapshotInterval  time.Duration
+	// SnapshotArchive replaces the bucket, e.g. with another object store
+	SnapshotArchive SnapshotArchive
+
+	InstanceID string        // name of this instance in the leader election
+	LeaseTTL   time.Duration // how long a silent leader keeps the simulation
+	// Leases is shared by all instances, only the leader runs the
+	// simulation; nil makes this instance the only one
+	Leases LeaseStore
+
+	DatabaseDriver string // database/sql driver linked into the binary; empty skips migrations
+	DatabaseURL    string
+	Migrate        string // MigrateAuto, MigrateOnly or MigrateOff
+	MigrateImport  string // JSON snapshot imported into the database once
+	SelfTest       string // SelfTestStrict, SelfTestDegraded or SelfTestOff
+
+	Anomalies AnomalyThresholds // rates above which robots are flagged
+
+	CheckInvariants bool // check the world's invariants after every change, for tests and staging
+	FaultInjection  bool // let admins inject latency, errors and dropped responses
+
+	MirrorURL string // mutating requests are copied to this base URL; empty disables mirroring
+
+	Admission AdmissionLimits // queue depths beyond which mutating requests are rejected
+	Streams   StreamLimits    // caps on long polls and streamed exports
+	// Subscribers bounds the events buffered for each subscriber of the buses
+	Subscribers SubscriberLimits
+	// IdleTimeout closes keep-alive connections without a request for so long
+	IdleTimeout time.Duration
+
+	// SLOTargets are the latency objectives of robot commands by action type
+	SLOTargets     SLOTargets
+	LogSLOBreaches bool // log every command slower than its objective
+
+	// CommandPriorities are the lanes of robot commands by action type
+	CommandPriorities map[string]CommandPriority
+	PriorityAging     time.Duration // waiting commands move up a lane so often; 0 never
+
+	RandomSeed int64 // seed of the match's randomness; 0 picks one
+
+	RecordDir string // golden files of all requests are written here; empty disables recording
+
+	// Clock replaces the real time, e.g. with a FakeClock in tests
+	Clock Clock
+	// Storage replaces the default in-memory world with the initial robots
+	Storage *RobotStorage
+	// Authenticator replaces the one built from APIKeys, OIDC and ClientCA
+	Authenticator *Authenticator
+}
+
+// DefaultConfig returns the configuration used without any environment variables
+func DefaultConfig() Config {
+	return Config{
+		Port:               "8080",
+		Language:           SourceLanguage,
+		CORS:               CORSPolicy{Origins: []string{"*"}},
+		HSTSMaxAge:         365 * 24 * time.Hour,
+		MaxBodyBytes:       defaultMaxBodyBytes,
+		TickInterval:       time.Second,
+		WeatherChangeTicks: 60,
+		ClockSpeed:         1,
+		SessionTTL:         15 * time.Minute,
+		RolloutStageTicks:  defaultRolloutStageTicks,
+		Behaviors:          DefaultBehaviorLimits(),
+		SpectatorDelay:     DefaultSpectatorDelay,
+		DecommissionGrace:  DefaultDecommissionGrace,
+		BundleRetention:    DefaultDecommissionRetention,
+		Spawner:            SpawnerConfig{Types: defaultSpawnTypes, Density: DefaultSpawnDensity, Area: DefaultSpawnArea},
+		HandlerTimeout:     5 * time.Second,
+		CacheTTL:           time.Second,
+		InstanceID:         defaultInstanceID(),
+		LeaseTTL:           15 * time.Second,
+		DeliveryAttempts:   defaultDeliveryAttempts,
+		SnapshotEndpoint:   "https://s3.amazonaws.com",
+		SnapshotRegion:     "us-east-1",
+		SnapshotPrefix:     "snapshots/",
+		SnapshotInterval:   time.Hour,
+		Migrate:            MigrateAuto,
+		SelfTest:           SelfTestDegraded,
+		Anomalies:          DefaultAnomalyThresholds(),
+		IdleTimeout:        2 * time.Minute,
+		Subscribers:        DefaultSubscriberLimits(),
+		CommandPriorities:  DefaultCommandPriorities(),
+		PriorityAging:      defaultPriorityAging,
+	}
+}
+
+// ConfigFromEnv reads the configuration from the environment variables
+// documented in the README
+func ConfigFromEnv() (Config, error) {
+	config := DefaultConfig()
+	if port := os.Getenv("PORT"); port != "" {
+		config.Port = port
+	}
+	config.BasePath = os.Getenv("BASE_PATH")
+	config.EnableHTTPS = os.Getenv("ENABLE_HTTPS") == "true"
+	config.TLSCertFile = os.Getenv("TLS_CERT_FILE")
+	config.TLSKeyFile = os.Getenv("TLS_KEY_FILE")
+	config.ClientCA = os.Getenv("TLS_CLIENT_CA_FILE")
+	var origins []string
+	for _, origin := range strings.Split(os.Getenv("CORS_ORIGINS"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	policy, err := NewCORSPolicy(os.Getenv("CORS_PRESET"), origins)
+	if err != nil {
+		return config, fmt.Errorf("invalid CORS configuration: %w", err)
+	}
+	if credentials, err := strconv.ParseBool(os.Getenv("CORS_CREDENTIALS")); err == nil {
+		policy.Credentials = credentials
+	}
+	config.CORS = policy
+	if seconds, err := strconv.Atoi(os.Getenv("HSTS_MAX_AGE_SECONDS")); err == nil && seconds >= 0 {
+		config.HSTSMaxAge = time.Duration(seconds) * time.Second
+	}
+	config.CSRFSecret = os.Getenv("CSRF_SECRET")
+	if bytes, err := strconv.ParseInt(os.Getenv("MAX_BODY_BYTES"), 10, 64); err == nil && bytes > 0 {
+		config.MaxBodyBytes = bytes
+	}
+
+	config.CombatRules = os.Getenv("COMBAT_RULES")
+	config.EnergyRounding = os.Getenv("ENERGY_ROUNDING")
+	config.IDStrategy = os.Getenv("ROBOT_ID_STRATEGY")
+	if language := os.Getenv("DEFAULT_LANGUAGE"); language != "" {
+		config.Language = language
+	}
+	if ms, err := strconv.Atoi(os.Getenv("SIM_TICK_MS")); err == nil && ms > 0 {
+		config.TickInterval = time.Duration(ms) * time.Millisecond
+	}
+	if ticks, err := strconv.ParseInt(os.Getenv("WEATHER_CHANGE_TICKS"), 10, 64); err == nil && ticks >= 0 {
+		config.WeatherChangeTicks = ticks
+	}
+	if speed, err := strconv.ParseFloat(os.Getenv("CLOCK_SPEED"), 64); err == nil && speed >= 0 {
+		config.ClockSpeed = speed
+	}
+	quotas, err := ParseQuotas(os.Getenv("QUOTAS"))
+	if err != nil {
+		return config, fmt.Errorf("invalid quota configuration: %w", err)
+	}
+	config.Quotas = quotas
+	targets, err := ParseSLOTargets(os.Getenv("SLO_TARGETS"))
+	if err != nil {
+		return config, fmt.Errorf("invalid SLO configuration: %w", err)
+	}
+	config.SLOTargets = targets
+	config.LogSLOBreaches = os.Getenv("SLO_LOG_BREACHES") == "true"
+	priorities, err := ParseCommandPriorities(os.Getenv("COMMAND_PRIORITIES"))
+	if err != nil {
+		return config, fmt.Errorf("invalid priority configuration: %w", err)
+	}
+	config.CommandPriorities = priorities
+	if ms, err := strconv.Atoi(os.Getenv("COMMAND_PRIORITY_AGING_MS")); err == nil && ms >= 0 {
+		config.PriorityAging = time.Duration(ms) * time.Millisecond
+	}
+	if minutes, err := strconv.Atoi(os.Getenv("SESSION_TTL_MINUTES")); err == nil && minutes > 0 {
+		config.SessionTTL = time.Duration(minutes) * time.Minute
+	}
+	config.MultiFloor = os.Getenv("MULTI_FLOOR") == "true"
+	switch mode := os.Getenv("WORLD_MODE"); mode {
+	case "", "grid":
+	case "continuous":
+		config.Continuous = true
+	default:
+		return config, fmt.Errorf("invalid world mode %q", mode)
+	}
+	config.Wrap = os.Getenv("WORLD_WRAP") == "true"
+	config.BatteryCurve = os.Getenv("BATTERY_CURVE")
+	config.MinFirmware = os.Getenv("MIN_FIRMWARE")
+	for _, path := range strings.Split(os.Getenv("PLUGINS"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			config.Plugins = append(config.Plugins, path)
+		}
+	}
+	config.SensorNoise = os.Getenv("SENSOR_NOISE") == "true"
+	if ticks, err := strconv.Atoi(os.Getenv("ROLLOUT_STAGE_TICKS")); err == nil && ticks >= 0 {
+		config.RolloutStageTicks = int64(ticks)
+	}
+	if pages, err := strconv.Atoi(os.Getenv("BEHAVIOR_MEMORY_PAGES")); err == nil && pages > 0 && pages <= 65536 {
+		config.Behaviors.MemoryPages = uint32(pages)
+	}
+	if ms, err := strconv.Atoi(os.Getenv("BEHAVIOR_TIMEOUT_MS")); err == nil && ms > 0 {
+		config.Behaviors.Timeout = time.Duration(ms) * time.Millisecond
+	}
+	if seconds, err := strconv.Atoi(os.Getenv("SPECTATOR_DELAY_SECONDS")); err == nil && seconds >= 0 {
+		config.SpectatorDelay = time.Duration(seconds) * time.Second
+	}
+	if seconds, err := strconv.Atoi(os.Getenv("DECOMMISSION_GRACE_SECONDS")); err == nil && seconds >= 0 {
+		config.DecommissionGrace = time.Duration(seconds) * time.Second
+	}
+	if days, err := strconv.Atoi(os.Getenv("DECOMMISSION_RETENTION_DAYS")); err == nil && days > 0 {
+		config.BundleRetention = time.Duration(days) * 24 * time.Hour
+	}
+	if ticks, err := strconv.ParseInt(os.Getenv("ITEM_SPAWN_TICKS"), 10, 64); err == nil && ticks >= 0 {
+		config.Spawner.Every = ticks
+	}
+	spawnTypes, err := ParseSpawnTypes(os.Getenv("ITEM_SPAWN_TYPES"))
+	if err != nil {
+		return config, fmt.Errorf("invalid item spawner configuration: %w", err)
+	}
+	config.Spawner.Types = spawnTypes
+	if density, err := strconv.ParseFloat(os.Getenv("ITEM_SPAWN_DENSITY"), 64); err == nil && density > 0 && density <= 1 {
+		config.Spawner.Density = density
+	}
+	if ms, err := strconv.Atoi(os.Getenv("HANDLER_TIMEOUT_MS")); err == nil && ms > 0 {
+		config.HandlerTimeout = time.Duration(ms) * time.Millisecond
+	}
+	if ms, err := strconv.Atoi(os.Getenv("CACHE_TTL_MS")); err == nil && ms >= 0 {
+		config.CacheTTL = time.Duration(ms) * time.Millisecond
+	}
+
+	config.APIKeys = os.Getenv("API_KEYS")
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		config.OIDC = &OIDCConfig{
+			Issuer:     issuer,
+			Audience:   os.Getenv("OIDC_AUDIENCE"),
+			RolesClaim: os.Getenv("OIDC_ROLES_CLAIM"),
+			AdminRole:  os.Getenv("OIDC_ADMIN_ROLE"),
+		}
+	}
+	config.AdminToken = os.Getenv("ADMIN_TOKEN")
+	for _, tenant := range strings.Split(os.Getenv("TENANTS"), ",") {
+		if tenant = strings.TrimSpace(tenant); tenant != "" {
+			config.Tenants = append(config.Tenants, tenant)
+		}
+	}
+
+	config.ROSBridgeURL = os.Getenv("ROS_BRIDGE_URL")
+	config.ROSNamespace = os.Getenv("ROS_NAMESPACE")
+
+	for _, url := range strings.Split(os.Getenv("WEBHOOK_URLS"), ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			config.Webhooks = append(config.Webhooks, url)
+		}
+	}
+	if attempts, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS")); err == nil && attempts > 0 {
+		config.DeliveryAttempts = attempts
+	}
+
+	config.SnapshotBucket = os.Getenv("SNAPSHOT_BUCKET")
+	if endpoint := os.Getenv("SNAPSHOT_ENDPOINT"); endpoint != "" {
+		config.SnapshotEndpoint = endpoint
+	}
+	if region := os.Getenv("SNAPSHOT_REGION"); region != "" {
+		config.SnapshotRegion = region
+	}
+	config.SnapshotAccessKey = os.Getenv("SNAPSHOT_ACCESS_KEY")
+	config.SnapshotSecretKey = os.Getenv("SNAPSHOT_SECRET_KEY")
+	if prefix, set := os.LookupEnv("SNAPSHOT_PREFIX"); set {
+		config.SnapshotPrefix = prefix
+	}
+	if minutes, err := strconv.Atoi(os.Getenv("SNAPSHOT_INTERVAL_MINUTES")); err == nil && minutes > 0 {
+		config.SnapshotInterval = time.Duration(minutes) * time.Minute
+	}
+
+	if instance := os.Getenv("INSTANCE_ID"); instance != "" {
+		config.InstanceID = instance
+	}
+	if seconds, err := strconv.Atoi(os.Getenv("LEADER_LEASE_SECONDS")); err == nil && seconds > 0 {
+		config.LeaseTTL = time.Duration(seconds) * time.Second
+	}
+
+	config.DatabaseDriver = os.Getenv("DATABASE_DRIVER")
+	config.DatabaseURL = os.Getenv("DATABASE_URL")
+	switch mode := os.Getenv("MIGRATE"); mode {
+	case "":
+	case MigrateAuto, MigrateOnly, MigrateOff:
+		config.Migrate = mode
+	default:
+		return config, fmt.Errorf("invalid migration mode %q", mode)
+	}
+	config.MigrateImport = os.Getenv("MIGRATE_IMPORT")
+	switch mode := os.Getenv("SELF_TEST"); mode {
+	case "":
+	case SelfTestStrict, SelfTestDegraded, SelfTestOff:
+		config.SelfTest = mode
+	default:
+		return config, fmt.Errorf("invalid self-test mode %q", mode)
+	}
+
+	if moves, err := strconv.Atoi(os.Getenv("ANOMALY_MOVES_PER_SECOND")); err == nil && moves > 0 {
+		config.Anomalies.MovesPerSecond = moves
+	}
+	if actions, err := strconv.Atoi(os.Getenv("ANOMALY_ACTIONS_PER_SECOND")); err == nil && actions > 0 {
+		config.Anomalies.ActionsPerSecond = actions
+	}
+	config.CheckInvariants = os.Getenv("CHECK_INVARIANTS") == "true"
+	config.FaultInjection = os.Getenv("FAULT_INJECTION") == "true"
+	config.MirrorURL = os.Getenv("MIRROR_URL")
+	if tasks, err := strconv.Atoi(os.Getenv("MAX_PENDING_TASKS")); err == nil && tasks >= 0 {
+		config.Admission.MaxPendingTasks = tasks
+	}
+	if events, err := strconv.Atoi(os.Getenv("MAX_PENDING_EVENTS")); err == nil && events >= 0 {
+		config.Admission.MaxPendingEvents = events
+	}
+	if streams, err := strconv.Atoi(os.Getenv("MAX_STREAMS")); err == nil && streams >= 0 {
+		config.Streams.MaxStreams = streams
+	}
+	if streams, err := strconv.Atoi(os.Getenv("MAX_STREAMS_PER_CLIENT")); err == nil && streams >= 0 {
+		config.Streams.MaxStreamsPerClient = streams
+	}
+	if seconds, err := strconv.Atoi(os.Getenv("IDLE_TIMEOUT_SECONDS")); err == nil && seconds > 0 {
+		config.IdleTimeout = time.Duration(seconds) * time.Second
+	}
+	if size, err := strconv.Atoi(os.Getenv("EVENT_BUFFER_SIZE")); err == nil && size > 0 {
+		config.Subscribers.Buffer = size
+	}
+	if policy := os.Getenv("SLOW_CONSUMER_POLICY"); policy != "" {
+		config.Subscribers.Policy = policy
+	}
+
+	if value := os.Getenv("RANDOM_SEED"); value != "" {
+		seed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return config, fmt.Errorf("invalid random seed %q", value)
+		}
+		config.RandomSeed = seed
+	}
+	config.RecordDir = os.Getenv("RECORD_DIR")
+	return config, nil
+}
+
+// defaultInstanceID names the instance after its host, which is the pod
+// name on Kubernetes
+func defaultInstanceID() string {
+	if hostname, err := os.Hostname(); err == nil {
+		return hostname
+	}
+	return "robot-api"
+}
+
+// Server is the robot API with its world and simulation, ready to be run
+// standalone or embedded into another program
+type Server struct {
+	config       Config
+	storage      *RobotStorage
+	events       *EventBus
+	handler      *RobotHandler
+	tenants      *TenantStore
+	simulation   *Simulation
+	housekeeping *Simulation // per-instance systems, run on every instance
+	elector      *LeaderElector
+	outbox       *Outbox
+	snapshots    *SnapshotArchiver
+	analytics    *Analytics
+	anomalies    *AnomalyDetector
+	recorder     *Recorder
+	invariants   *InvariantChecker
+	faults       *FaultInjector
+	mirror       *Mirror
+	admission    *AdmissionController
+	streams      *ConnectionLimiter
+	slo          *SLOTracker
+	lanes        *CommandLanes
+	router       *gin.Engine
+	tlsConfig    *tls.Config
+	catalog      *Catalog
+	csrf         *CSRFProtection
+}
+
+// New wires up a server from the configuration
+func New(config Config) (*Server, error) {
+	if config.ClientCA != "" && !config.EnableHTTPS {
+		return nil, errors.New("a client CA requires HTTPS")
+	}
+	if err := config.CORS.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid CORS configuration: %w", err)
+	}
+	config.BasePath = "/" + strings.Trim(config.BasePath, "/")
+	if config.BasePath == "/" {
+		config.BasePath = ""
+	}
+
+	timeSource := config.Clock
+	if timeSource == nil {
+		timeSource = SystemClock{}
+	}
+	storage := config.Storage
+	if storage == nil {
+		storage = NewRobotStorage()
+		storage.SetClock(timeSource) // dates the example history
+		storage.Initialize()
+	}
+	storage.SetClock(timeSource)
+	handler := NewRobotHandler(storage)
+
+	// All randomness of the match comes from one seed, recorded as an event
+	seed := config.RandomSeed
+	if seed == 0 {
+		seed = rand.Int63()
+	}
+	random := NewRandomService(seed)
+	random.SetClock(timeSource)
+	handler.SetRandom(random)
+
+	combat, err := NewRandomCombatResolver(config.CombatRules, random)
+	if err != nil {
+		return nil, fmt.Errorf("invalid combat configuration: %w", err)
+	}
+	handler.SetCombatResolver(combat)
+	rounding, err := NewEnergyRounding(config.EnergyRounding)
+	if err != nil {
+		return nil, fmt.Errorf("invalid energy configuration: %w", err)
+	}
+	handler.SetEnergyRounding(rounding)
+	handler.SetIDCheck(config.IDCheck)
+
+	if config.Language == "" {
+		config.Language = SourceLanguage
+	}
+	catalog, err := LoadCatalog()
+	if err != nil {
+		return nil, fmt.Errorf("invalid language configuration: %w", err)
+	}
+	if !catalog.Supports(config.Language) {
+		return nil, fmt.Errorf("invalid language configuration: %q is not one of %v", config.Language, catalog.Languages())
+	}
+
+	ids, err := NewIDGenerator(config.IDStrategy)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ID configuration: %w", err)
+	}
+	handler.SetIDGenerator(ids)
+
+	events := NewEventBus()
+	events.SetClock(timeSource)
+	if err := events.SetSubscriberLimits(config.Subscribers); err != nil {
+		return nil, fmt.Errorf("invalid event subscriber configuration: %w", err)
+	}
+	handler.SetEventBus(events)
+	handler.SetSpectatorDelay(config.SpectatorDelay)
+	handler.SetDecommissionGrace(config.DecommissionGrace)
+	handler.SetDecommissionRetention(config.BundleRetention)
+	weather := NewRandomWeather(events, config.WeatherChangeTicks, random)
+	handler.SetWeather(weather)
+	spawner, err := NewItemSpawner(config.Spawner, random)
+	if err != nil {
+		return nil, fmt.Errorf("invalid item spawner configuration: %w", err)
+	}
+	handler.SetSpawner(spawner)
+	clock := NewWorldClock(events, config.ClockSpeed)
+	handler.SetClock(clock)
+	handler.SetQuotas(config.Quotas)
+	sessions := NewSessionStore(config.SessionTTL)
+	handler.SetSessions(sessions)
+	handler.SetMultiFloor(config.MultiFloor)
+	handler.SetContinuous(config.Continuous)
+
+	batteryCurve, err := NewDegradationCurve(config.BatteryCurve)
+	if err != nil {
+		return nil, fmt.Errorf("invalid battery configuration: %w", err)
+	}
+	storage.SetBatteryCurve(batteryCurve)
+	storage.SetWrap(config.Wrap)
+
+	// Every tenant gets a world of its own, seeded like the default one
+	tenants := NewTenantStore(func() *RobotStorage {
+		world := newExampleWorld()
+		world.SetBatteryCurve(batteryCurve)
+		world.SetWrap(config.Wrap)
+		return world
+	})
+	tenants.SetClock(timeSource)
+	tenants.SetSubscriberLimits(config.Subscribers)
+	// The events of every world are republished on one bus for extensions
+	domainEvents := pubsub.New[Event]()
+	events.Forward(domainEvents, "")
+	tenants.ForwardEvents(domainEvents)
+	for _, id := range config.Tenants {
+		if _, err := tenants.Create(id); err != nil {
+			return nil, fmt.Errorf("invalid tenant configuration: %w", err)
+		}
+	}
+	handler.SetTenants(tenants)
+
+	// Dashboards read projections of every tenant's events
+	analytics := NewAnalytics()
+	analytics.SetClock(timeSource)
+	analytics.For(events)
+	for _, tenant := range tenants.All() {
+		analytics.For(tenant.Events)
+	}
+	handler.SetAnalytics(analytics)
+
+	// Suspicious activity of every tenant is flagged for the admins
+	anomalies := NewAnomalyDetector(config.Anomalies)
+	anomalies.SetClock(timeSource)
+	anomalies.Watch("", storage, events)
+	for _, tenant := range tenants.All() {
+		anomalies.Watch(tenant.ID, tenant.Storage, tenant.Events)
+	}
+	handler.SetAnomalyDetector(anomalies)
+	handler.publishSeed()
+
+	// Broken world invariants are logged and published, in tests and staging
+	var invariants *InvariantChecker
+	if config.CheckInvariants {
+		invariants = NewInvariantChecker()
+		invariants.SetClock(timeSource)
+		invariants.Watch("", storage, events)
+		for _, tenant := range tenants.All() {
+			invariants.Watch(tenant.ID, tenant.Storage, tenant.Events)
+		}
+		handler.SetInvariantChecker(invariants)
+	}
+
+	// Faults for testing clients' retries are only injected when enabled
+	var faults *FaultInjector
+	if config.FaultInjection {
+		faults = NewFaultInjector(random.stream(RandomFaults))
+		handler.SetFaultInjector(faults)
+	}
+
+	// Mutating requests can be mirrored to a deployment under test
+	var mirror *Mirror
+	if config.MirrorURL != "" {
+		mirror, err = NewMirror(config.MirrorURL)
+		if err != nil {
+			return nil, err
+		}
+		handler.SetMirror(mirror)
+	}
+
+	if config.MinFirmware != "" {
+		version, err := ParseFirmwareVersion(config.MinFirmware)
+		if err != nil {
+			return nil, fmt.Errorf("invalid firmware configuration: %w", err)
+		}
+		handler.SetMinFirmware(version)
+	}
+
+	// Plugins react to the events of all worlds
+	plugins := NewPluginRegistry()
+	plugins.SetEvents(domainEvents)
+	for _, path := range config.Plugins {
+		if err := plugins.LoadPlugin(path); err != nil {
+			return nil, fmt.Errorf("invalid plugin %s: %w", path, err)
+		}
+	}
+	handler.SetPlugins(plugins)
+
+	if config.SensorNoise {
+		handler.EnableSensorNoise()
+	}
+
+	// Every event is recorded for every sink before it is delivered
+	sinks := append([]Sink{}, config.Sinks...)
+	for _, url := range config.Webhooks {
+		sinks = append(sinks, NewWebhookSink(url))
+	}
+	if config.DeliveryAttempts < 1 {
+		return nil, errors.New("at least one delivery attempt is needed")
+	}
+	outbox := NewOutbox(sinks, config.DeliveryAttempts)
+	outbox.SetClock(timeSource)
+	if len(sinks) > 0 {
+		events.OnPublish(outbox.Record)
+	}
+	// Exported bundles only go to the sinks, not into the event log
+	outbox.SetAttachments(handler.AttachBundle)
+	handler.SetOutbox(outbox)
+
+	// Snapshots of every world go to the bucket on a schedule
+	archive := config.SnapshotArchive
+	if archive == nil && config.SnapshotBucket != "" {
+		archive = NewS3Archive(config.SnapshotEndpoint, config.SnapshotRegion, config.SnapshotBucket,
+			config.SnapshotAccessKey, config.SnapshotSecretKey)
+	}
+	var snapshots *SnapshotArchiver
+	if archive != nil {
+		if config.SnapshotInterval <= 0 {
+			return nil, errors.New("the snapshot interval must be positive")
+		}
+		snapshots = NewSnapshotArchiver(storage, archive, config.SnapshotPrefix)
+		snapshots.SetTenants(tenants)
+		handler.SetSnapshots(snapshots)
+	}
+
+	tasks := NewTaskStore()
+	tasks.SetClock(timeSource)
+	ota := NewOTAManager(storage, tasks, events, config.RolloutStageTicks)
+	ota.SetClock(timeSource)
+	handler.SetTasks(tasks)
+	handler.SetOTA(ota)
+
+	// Mutating requests are shed while the task queue or the deliveries back up
+	admission := NewAdmissionController(tasks, outbox, config.Admission)
+	handler.SetAdmissionController(admission)
+
+	// Command latencies are compared with their objectives
+	slo := NewSLOTracker(config.SLOTargets, config.LogSLOBreaches, timeSource)
+	handler.SetSLOTracker(slo)
+
+	// Long-lived requests are capped, so dashboards cannot use up the connections
+	streams := NewConnectionLimiter(config.Streams)
+	handler.SetConnectionLimiter(streams)
+
+	// Commands take turns on each robot, by lane
+	lanes := NewCommandLanes(config.CommandPriorities, config.PriorityAging, timeSource)
+	handler.SetLanes(lanes)
+
+	// Only the leader ticks the shared world: the clock, the weather and
+	// everything that happens to the robots over time
+	simulation := NewSimulation(config.TickInterval)
+	simulation.SetClock(timeSource)
+	simulation.AddSystem(clock.Tick)
+	simulation.AddSystem(weather.Tick)
+	// The worlds of all tenants share the weather and the clock
+	simulation.AddSystem(func(tick int64) {
+		tickWorld := func(world *RobotStorage) {
+			world.TickHazards(tick)
+			world.TickEffects(tick)
+			if config.Continuous {
+				world.TickMotion(weather.Current().MoveCost)
+			}
+			world.Recharge(clock.RechargeRate)
+			world.ResetQuotas(world.Clock().Now())
+		}
+		tickWorld(storage)
+		tenants.EachWorld(tickWorld)
+	})
+	// Zones see where the robots ended up after the worlds have ticked
+	simulation.AddSystem(handler.TickZones)
+
+	// Every instance keeps what it holds in memory itself: its play
+	// sessions, rollouts, behavior modules, spawners and decommissions
+	housekeeping := NewSimulation(config.TickInterval)
+	housekeeping.SetClock(timeSource)
+	housekeeping.AddSystem(sessions.Sweep)
+	housekeeping.AddSystem(ota.Tick)
+	// Behavior modules decide on every tick
+	behaviors := NewBehaviorEngine(config.Behaviors)
+	handler.SetBehaviors(behaviors)
+	housekeeping.AddSystem(handler.TickBehaviors)
+	// Long-running worlds get new items to pick up
+	housekeeping.AddSystem(handler.TickSpawner)
+	// Decommissioned robots leave the worlds once their grace period is over
+	housekeeping.AddSystem(handler.TickDecommissions)
+
+	// The emergency stop pauses both loops and blocks all mutating requests
+	estop := NewEmergencyStop(simulation, housekeeping)
+	estop.SetClock(timeSource)
+	handler.SetEmergencyStop(estop)
+
+	// Read-only mode keeps reads and streams alive during maintenance
+	readOnly := NewReadOnlyMode()
+	readOnly.SetClock(timeSource)
+	handler.SetReadOnlyMode(readOnly)
+
+	// The loaded worlds are checked before serving them
+	report, err := selfTest(config.SelfTest, storage, tenants, readOnly, timeSource.Now())
+	if err != nil {
+		return nil, err
+	}
+	handler.SetSelfTestReport(report)
+
+	apiKeys, err := NewAPIKeyStore(config.APIKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid API key configuration: %w", err)
+	}
+	handler.SetAPIKeys(apiKeys)
+	auth := config.Authenticator
+	if auth == nil {
+		var oidc *OIDCVerifier
+		if config.OIDC != nil {
+			oidc, err = NewOIDCVerifier(*config.OIDC)
+			if err != nil {
+				return nil, fmt.Errorf("invalid OIDC configuration: %w", err)
+			}
+			oidc.SetClock(timeSource)
+		}
+		auth = NewAuthenticator(apiKeys, oidc, sessions)
+	}
+	auth.SetTenants(tenants)
+
+	// Devices can authenticate with client certificates signed by the client CA
+	var tlsConfig *tls.Config
+	if config.ClientCA != "" {
+		tlsConfig, err = NewClientCertTLSConfig(config.ClientCA)
+		if err != nil {
+			return nil, fmt.Errorf("invalid client CA configuration: %w", err)
+		}
+		auth.EnableClientCertificates()
+	}
+
+	// Of several instances sharing the leases only the leader ticks the world
+	leases := config.Leases
+	if leases == nil {
+		memory := NewMemoryLeases()
+		memory.SetClock(timeSource)
+		leases = memory
+	}
+	if config.LeaseTTL <= 0 {
+		return nil, errors.New("the leader lease TTL must be positive")
+	}
+	elector := NewLeaderElector(leases, config.InstanceID, config.LeaseTTL)
+	elector.SetClock(timeSource)
+
+	server := &Server{
+		config:       config,
+		storage:      storage,
+		events:       events,
+		handler:      handler,
+		tenants:      tenants,
+		simulation:   simulation,
+		housekeeping: housekeeping,
+		elector:      elector,
+		outbox:       outbox,
+		snapshots:    snapshots,
+		analytics:    analytics,
+		anomalies:    anomalies,
+		invariants:   invariants,
+		faults:       faults,
+		mirror:       mirror,
+		admission:    admission,
+		streams:      streams,
+		slo:          slo,
+		lanes:        lanes,
+		tlsConfig:    tlsConfig,
+		catalog:      catalog,
+		csrf:         NewCSRFProtection(config.CSRFSecret),
+	}
+	if config.RecordDir != "" {
+		recorder, err := NewRecorder(config.RecordDir)
+		if err != nil {
+			return nil, fmt.Errorf("cannot record requests: %w", err)
+		}
+		server.recorder = recorder
+	}
+	server.router = server.routes(auth, estop, readOnly)
+	return server, nil
+}
+
+// Router returns the HTTP handler with all routes below the base path
+func (s *Server) Router() *gin.Engine {
+	return s.router
+}
+
+// Handler returns the robot handler, e.g. to plug in a device adapter,
+// hooks or custom actions before the server runs
+func (s *Server) Handler() *RobotHandler {
+	return s.handler
+}
+
+// Storage returns the world the server operates on
+func (s *Server) Storage() *RobotStorage {
+	return s.storage
+}
+
+// Events returns the bus all domain events are published on
+func (s *Server) Events() *EventBus {
+	return s.events
+}
+
+// Invariants returns the invariant checker, nil unless Config.CheckInvariants
+// is set
+func (s *Server) Invariants() *InvariantChecker {
+	return s.invariants
+}
+
+// routes builds the router
+func (s *Server) routes(auth *Authenticator, estop *EmergencyStop, readOnly *ReadOnlyMode) *gin.Engine {
+	handler := s.handler
+	router := gin.New()
+	basePath := s.config.BasePath
+
+	// HEAD and overridden methods are routed again before anything else
+	// sees the request
+	router.Use(MethodOverride(router), gin.Logger(), gin.Recovery())
+
+	// Measure command latencies from the moment requests arrive
+	router.Use(s.slo.Track())
+
+	// Add middleware to detect HTTPS from headers (for proxy/load balancer scenarios)
+	router.Use(func(c *gin.Context) {
+		// Check for common HTTPS detection headers
+		if c.GetHeader("X-Forwarded-Proto") == "https" ||
+			c.GetHeader("X-Forwarded-SSL") == "on" ||
+			c.GetHeader("X-URL-Scheme") == "https" {
+			c.Set("scheme", "https")
+		} else if c.Request.TLS != nil {
+			c.Set("scheme", "https")
+		} else {
+			c.Set("scheme", "http")
+		}
+		c.Set("base_path", basePath)
+		c.Next()
+	})
+
+	// JSON responses must not be sniffed, framed or run anything
+	router.Use(SecurityHeaders{ContentSecurityPolicy: APIContentSecurityPolicy, HSTSMaxAge: s.config.HSTSMaxAge}.Middleware())
+
+	// Requests run in the default tenant's world unless X-Tenant-ID or
+	// the caller's credentials name another one
+	router.Use(ResolveTenant(s.tenants))
+
+	// Configure CORS middleware
+	router.Use(s.config.CORS.Middleware())
+
+	// Compress responses for clients that accept gzip
+	router.Use(Compress())
+	router.Use(Localize(s.catalog, s.config.Language))
+
+	// Bodies are read within the limit from here on
+	router.Use(LimitBody(s.config.MaxBodyBytes))
+
+	// Record golden files of the uncompressed exchanges
+	if s.recorder != nil {
+		router.Use(s.recorder.Record())
+	}
+
+	// Tag mutating requests with a command ID
+	router.Use(AssignCommandID())
+
+	// Shed mutating requests while the queues back up
+	router.Use(s.admission.Admit())
+
+	// Copy mutating requests to the deployment under test
+	if s.mirror != nil {
+		router.Use(s.mirror.Capture())
+	}
+
+	router.Use(RejectDuringEStop(estop))
+	router.Use(RejectWhenReadOnly(readOnly))
+
+	// Inject faults configured by the admins
+	if s.faults != nil {
+		router.Use(s.faults.Inject())
+	}
+
+	root := router.Group(basePath)
+
+	// Add enhanced health check endpoint
+	// Shows which instance runs the simulation
+	root.GET("/leader", func(c *gin.Context) {
+		c.JSON(http.StatusOK, s.elector.Status())
+	})
+
+	root.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{
+			"status":    "healthy",
+			"timestamp": s.events.Clock().Now().UTC(),
+			"version":   "1.0.0",
+			"service":   "robot-api",
+		})
+	})
+
+	root.GET("/openapi.json", GetOpenAPISpec)
+	root.GET("/devtools/postman", GetPostmanCollection(router, basePath))
+	root.GET("/console", SecurityHeaders{ContentSecurityPolicy: consolePolicy, HSTSMaxAge: s.config.HSTSMaxAge}.Middleware(), GetConsole)
+
+	// Add a simple root endpoint for basic connectivity test
+	root.GET("/", func(c *gin.Context) {
+		scheme := c.GetString("scheme")
+		if scheme == "" {
+			scheme = "http"
+		}
+
+		endpoints := []string{
+			"/health",
+			"/leader",
+			"/openapi.json",
+			"/devtools/postman",
+			"/console",
+			"/me",
+			"/robots",
+			"/sessions",
+			"/registrations",
+			"/csrf-token",
+			"/search",
+			"/robot/{id}/status",
+			"/robot/{id}/inventory",
+			"/robot/{id}/move",
+			"/robot/{id}/velocity",
+			"/robot/{id}/pickup/{itemId}",
+			"/robot/{id}/putdown/{itemId}",
+			"/robot/{id}/replace-battery",
+			"/robot/{id}/maintenance",
+			"/robot/{id}/state",
+			"/robot/{id}/actions",
+			"/robot/{id}/attack/{targetId}",
+			"/robot/{id}/scan",
+			"/robot/{id}/custom/{action}",
+			"/robot/{id}/permissions",
+			"/robot/{id}/decommission",
+			"/world/map",
+			"/world/weather",
+			"/world/time",
+			"/analytics/robots",
+			"/analytics/heatmap",
+			"/analytics/damage",
+			"/analytics/combat",
+			"/analytics/energy",
+		}
+		// Endpoints are listed as clients reach them, i.e. with all prefixes
+		prefix := forwardedPrefix(c) + basePath
+		for i, endpoint := range endpoints {
+			endpoints[i] = prefix + endpoint
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":       "Robot API Server is running",
+			"version":       "1.0.0",
+			"scheme":        scheme,
+			"https_enabled": scheme == "https",
+			"endpoints":     endpoints,
+		})
+	})
+
+	// Commands time out after the handler timeout, queries over whole
+	// histories or the map get twice as long. A circuit breaker in front
+	// of the storage rejects requests while the backend keeps failing.
+	commandTimeout := s.config.HandlerTimeout
+	queryTimeout := 2 * commandTimeout
+	storageBreaker := NewCircuitBreaker(5, 30*time.Second)
+
+	// Hot read paths are cached, a TTL of 0 disables the cache
+	cache := NewResponseCache(s.config.CacheTTL, func() uint64 {
+		return s.storage.Version() + s.tenants.Version()
+	})
+
+	authenticate := Authenticate(auth)
+	// Public routes only leave the default tenant for callers of another one
+	public := ScopePublic(s.config.AdminToken, auth)
+
+	// Add items endpoint to check available items
+	root.GET("/items", public, CircuitBreak(storageBreaker), cache.Cached(), WithTimeout(queryTimeout, handler.ListItems))
+	root.GET("/search", public, CircuitBreak(storageBreaker), WithTimeout(queryTimeout, handler.Search))
+
+	// Browsers writing with a client certificate need a CSRF token
+	protect := s.csrf.Protect()
+
+	root.GET("/me", authenticate, handler.GetMe)
+	root.GET("/csrf-token", authenticate, s.csrf.GetToken)
+	root.POST("/simulate/battle", authenticate, protect, CircuitBreak(storageBreaker), WithTimeout(queryTimeout, handler.SimulateBattle))
+	root.POST("/robots/status", authenticate, protect, CircuitBreak(storageBreaker), WithTimeout(queryTimeout, handler.BatchStatus))
+	root.POST("/robots", authenticate, protect, CircuitBreak(storageBreaker), WithTimeout(commandTimeout, handler.CreateRobot))
+	root.GET("/transfers", authenticate, handler.ListTransfers)
+	root.POST("/transfers/:transferId/accept", authenticate, protect, CircuitBreak(storageBreaker), handler.AcceptTransfer)
+	root.POST("/transfers/:transferId/reject", authenticate, protect, handler.RejectTransfer)
+	root.POST("/sessions", authenticate, protect, handler.CreateSession)
+	// Devices register before they have credentials
+	root.POST("/registrations", CircuitBreak(storageBreaker), handler.RegisterDevice)
+	root.GET("/registrations/:registrationId", handler.GetRegistration)
+	// The download link carries its token, so it works without credentials
+	root.GET("/decommissions/:decommissionId/bundle", handler.GetDecommissionBundle)
+
+	// Tournaments only read the robots, matches run in the battle simulator
+	tournaments := root.Group("/tournaments", authenticate, protect, CircuitBreak(storageBreaker))
+	{
+		tournaments.GET("", handler.ListTournaments)
+		tournaments.POST("", WithTimeout(commandTimeout, handler.CreateTournament))
+		tournaments.GET("/:id", handler.GetTournament)
+		tournaments.POST("/:id/participants", WithTimeout(commandTimeout, handler.RegisterParticipant))
+		tournaments.POST("/:id/start", WithTimeout(commandTimeout, handler.StartTournament))
+		tournaments.POST("/:id/run", WithTimeout(queryTimeout, handler.RunTournament))
+		tournaments.GET("/:id/matches/:match/replay", WithTimeout(queryTimeout, handler.ExportMatchReplay))
+	}
+	root.POST("/replays", authenticate, protect, WithTimeout(queryTimeout, handler.ImportReplay))
+	root.DELETE("/sessions/current", handler.RevokeSession)
+
+	// Tasks are not scheduled in the robot's lane: a cancellation must not
+	// wait behind the commands it cancels
+	robotTasks := root.Group("/robot", authenticate, protect, handler.RequireValidIDs())
+	{
+		robotTasks.GET("/:id/tasks", handler.ListTasks)
+		robotTasks.GET("/:id/tasks/:taskId", handler.GetRobotTask)
+		robotTasks.DELETE("/:id/tasks/:taskId", handler.RequirePermission(PermUpdate), handler.CancelRobotTask)
+	}
+
+	api := root.Group("/robot", authenticate, protect, handler.RequireValidIDs(), CircuitBreak(storageBreaker), handler.RequireActionCapacity(), s.lanes.Schedule())
+	{
+		api.GET("/:id/status", cache.Cached(), WithTimeout(commandTimeout, handler.GetStatus))
+		api.GET("/:id/inventory", WithTimeout(commandTimeout, handler.GetInventory))
+
+		api.POST("/:id/move", handler.RequirePermission(PermMove), handler.RequireFirmware(), handler.RequireQuota("move"),
+			WithTimeout(commandTimeout, handler.MoveRobot))
+		api.POST("/:id/velocity", handler.RequirePermission(PermMove), handler.RequireFirmware(), handler.RequireQuota("move"),
+			WithTimeout(commandTimeout, handler.SetVelocity))
+
+		api.POST("/:id/pickup/:itemId", handler.RequirePermission(PermItems), handler.RequireFirmware(), handler.RequireQuota("pickup"),
+			WithTimeout(commandTimeout, handler.PickupItem))
+		api.POST("/:id/putdown/:itemId", handler.RequirePermission(PermItems), handler.RequireFirmware(), handler.RequireQuota("putdown"),
+			WithTimeout(commandTimeout, handler.PutdownItem))
+
+		api.POST("/:id/maintenance", handler.RequirePermission(PermUpdate), WithTimeout(commandTimeout, handler.SetMaintenance))
+		api.POST("/:id/replace-battery", handler.RequirePermission(PermItems), WithTimeout(commandTimeout, handler.ReplaceBattery))
+
+		api.PATCH("/:id/state", handler.RequirePermission(PermUpdate), WithTimeout(commandTimeout, handler.UpdateState))
+
+		api.GET("/:id/actions", WithTimeout(queryTimeout, handler.GetActions))
+		api.GET("/:id/path", WithTimeout(queryTimeout, handler.GetPath))
+		// Streamed responses are not buffered by WithTimeout
+		api.GET("/:id/actions/export", s.streams.Limit(), handler.ExportActions)
+		// Long-polling blocks longer than the handler timeout on purpose
+		api.GET("/:id/events/poll", s.streams.Limit(), handler.PollEvents)
+
+		api.POST("/:id/attack/:targetId", handler.RequirePermission(PermAttack), handler.RequireFirmware(), handler.RequireQuota("attack"),
+			WithTimeout(commandTimeout, handler.AttackRobot))
+
+		api.GET("/:id/scan", handler.RequirePermission(PermScan), handler.RequireFirmware(), handler.RequireQuota("scan"),
+			WithTimeout(queryTimeout, handler.Scan))
+
+		// Batches run as tasks after the answer, see GET /robot/{id}/tasks/{taskId}
+		api.POST("/:id/batch", handler.RequirePermission(PermMove), handler.RequirePermission(PermItems), handler.RequireFirmware(),
+			handler.RunBatch)
+
+		// Program runs are tasks as well, they go on after the answer
+		api.GET("/:id/program", handler.GetProgram)
+		api.PUT("/:id/program", handler.RequirePermission(PermUpdate), WithTimeout(commandTimeout, handler.UploadProgram))
+		api.DELETE("/:id/program", handler.RequirePermission(PermUpdate), handler.DeleteProgram)
+		api.POST("/:id/program/step", handler.RequirePermission(PermMove), handler.RequirePermission(PermItems), handler.RequireFirmware(),
+			WithTimeout(commandTimeout, handler.StepProgram))
+		api.POST("/:id/program/run", handler.RequirePermission(PermMove), handler.RequirePermission(PermItems), handler.RequireFirmware(),
+			handler.RunProgram)
+
+		// Behavior modules act on every tick of the simulation
+		api.GET("/:id/behavior", handler.GetBehavior)
+		api.PUT("/:id/behavior", handler.RequirePermission(PermUpdate), handler.RequirePermission(PermMove), handler.RequirePermission(PermItems),
+			WithTimeout(commandTimeout, handler.UploadBehavior))
+		api.DELETE("/:id/behavior", handler.RequirePermission(PermUpdate), handler.DeleteBehavior)
+
+		api.POST("/:id/custom/:action", handler.RequireActionPermission(), handler.RequireFirmware(), handler.RequireQuota("custom"),
+			WithTimeout(commandTimeout, handler.CustomAction))
+
+		api.POST("/:id/transfer-ownership", WithTimeout(commandTimeout, handler.TransferOwnership))
+		api.POST("/:id/decommission", WithTimeout(commandTimeout, handler.DecommissionRobot))
+
+		api.GET("/:id/permissions", WithTimeout(commandTimeout, handler.GetPermissions))
+		api.POST("/:id/permissions", WithTimeout(commandTimeout, handler.GrantPermissions))
+	}
+
+	world := root.Group("/world")
+	{
+		world.GET("/map", public, cache.Cached(), handler.GetWorldMap)
+		world.GET("/changes", authenticate, CircuitBreak(storageBreaker), handler.GetWorldChanges)
+		world.GET("/weather", public, handler.GetWeather)
+		world.GET("/time", public, handler.GetTime)
+		world.GET("/zones", public, handler.GetZones)
+		// Spectators need no account, they only see delayed public events
+		world.GET("/spectate", public, s.streams.Limit(), handler.SpectateEvents)
+	}
+
+	analytics := root.Group("/analytics", authenticate, CircuitBreak(storageBreaker))
+	{
+		analytics.GET("/robots", handler.GetRobotStats)
+		analytics.GET("/robots/:id", handler.RequireValidIDs(), handler.GetRobotStatsByID)
+		analytics.GET("/heatmap", handler.GetHeatmap)
+		analytics.GET("/damage", handler.GetDamageMatrix)
+		analytics.GET("/combat", handler.GetCombat)
+		analytics.GET("/energy", handler.GetEnergyReport)
+	}
+
+	admin := root.Group("/admin", RequireAdmin(s.config.AdminToken, auth))
+	{
+		admin.PATCH("/world/time", handler.SetTimeSpeed)
+		admin.PUT("/world/obstacles/:x/:y", handler.PlaceObstacle)
+		admin.DELETE("/world/obstacles/:x/:y", handler.RemoveObstacle)
+		admin.PUT("/world/bounds", handler.SetBounds)
+		admin.DELETE("/world/bounds", handler.RemoveBounds)
+		admin.PUT("/world/spawns", handler.SetSpawnPoints)
+		admin.POST("/world/generate", handler.GenerateWorld)
+		admin.POST("/world/links", handler.AddFloorLink)
+		admin.DELETE("/world/links/:x/:y/:z", handler.RemoveFloorLink)
+		admin.PUT("/world/zones/:name", handler.SetZone)
+		admin.DELETE("/world/zones/:name", handler.RemoveZone)
+		// Contains every robot's history, so the snapshot is admin only
+		admin.GET("/world/snapshot", CircuitBreak(storageBreaker), s.streams.Limit(), handler.GetWorldSnapshot)
+		admin.GET("/estop", handler.GetEStop)
+		admin.POST("/estop", handler.EngageEStop)
+		admin.POST("/estop/clear", handler.ClearEStop)
+		admin.GET("/read-only", handler.GetReadOnly)
+		admin.POST("/read-only", handler.EnableReadOnly)
+		admin.DELETE("/read-only", handler.DisableReadOnly)
+		admin.GET("/selftest", handler.GetSelfTest)
+		admin.GET("/firmware", handler.ListFirmware)
+		admin.POST("/firmware", handler.RegisterFirmware)
+		admin.POST("/rollouts", handler.StartRollout)
+		admin.GET("/rollouts/:id", handler.GetRollout)
+		admin.POST("/rollouts/:id/rollback", handler.RollbackRollout)
+		admin.GET("/tasks", handler.ListTasks)
+		admin.GET("/tasks/:id", handler.GetTask)
+		admin.DELETE("/tasks/:id", handler.CancelTask)
+		admin.GET("/plugins", handler.ListActions)
+		admin.POST("/plugins", handler.RegisterAction)
+		admin.DELETE("/plugins/:name", handler.UnregisterAction)
+		admin.GET("/registrations", handler.ListRegistrations)
+		admin.POST("/registrations/:id/approve", CircuitBreak(storageBreaker), handler.ApproveRegistration)
+		admin.POST("/registrations/:id/reject", handler.RejectRegistration)
+		admin.GET("/tenants", handler.ListTenants)
+		admin.POST("/tenants", handler.CreateTenant)
+		admin.PUT("/tenants/:id/limits", handler.SetTenantLimits)
+		admin.POST("/snapshots", handler.ArchiveSnapshot)
+		admin.GET("/audit", handler.GetAudit)
+		admin.GET("/anomalies", handler.ListAnomalies)
+		admin.GET("/invariants", handler.ListInvariantViolations)
+		admin.GET("/faults", handler.GetFaults)
+		admin.POST("/faults", handler.AddFault)
+		admin.DELETE("/faults", handler.ClearFaults)
+		admin.DELETE("/faults/:id", handler.RemoveFault)
+		admin.GET("/mirror", handler.GetMirrorStats)
+		admin.POST("/migrate-storage", handler.MigrateStorage)
+		admin.GET("/queues", handler.GetQueueDepths)
+		admin.GET("/slo", handler.GetSLOReport)
+		admin.GET("/connections", handler.GetConnectionStats)
+		admin.GET("/random", handler.GetRandomSeed)
+		admin.POST("/random", handler.ReseedRandom)
+		admin.POST("/restore", handler.RestoreSnapshot)
+		admin.GET("/deliveries", handler.ListDeliveries)
+		admin.POST("/deliveries/:id/requeue", handler.RequeueDelivery)
+		admin.GET("/cache", func(c *gin.Context) {
+			c.JSON(http.StatusOK, cache.Stats())
+		})
+	}
+
+	// Every resource describes itself to OPTIONS requests
+	RegisterOptions(router, basePath)
+	return router
+}
+
+// lead runs the work only one instance may do: the simulation of the shared
+// world and the scheduled snapshots. Per-instance housekeeping runs on every
+// instance, see Run.
+func (s *Server) lead(ctx context.Context) {
+	if s.snapshots != nil {
+		go s.snapshots.Run(ctx, s.config.SnapshotInterval)
+	}
+	s.simulation.Run(ctx)
+}
+
+// Run migrates the database, serves the API on the configured port and runs
+// the simulation until ctx is done, then shuts down gracefully
+func (s *Server) Run(ctx context.Context) error {
+	if s.config.Migrate != MigrateOff {
+		if err := Migrate(ctx, s.config); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	server := &http.Server{
+		Addr:        ":" + s.config.Port,
+		Handler:     s.router,
+		TLSConfig:   s.tlsConfig,
+		IdleTimeout: s.config.IdleTimeout,
+		ConnState:   s.streams.TrackConnection,
+	}
+
+	failed := make(chan error, 1)
+	go func() {
+		if s.config.EnableHTTPS {
+			log.Printf("Starting robot API server with HTTPS on port %s...", s.config.Port)
+			err := server.ListenAndServeTLS(s.config.TLSCertFile, s.config.TLSKeyFile)
+			if err == nil || err == http.ErrServerClosed {
+				return
+			}
+			log.Printf("HTTPS failed, falling back to HTTP: %v", err)
+		}
+		log.Printf("Starting robot API server on port %s...", s.config.Port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			failed <- err
+		}
+	}()
+
+	// Run the simulation until shutdown
+	simCtx, stopSimulation := context.WithCancel(ctx)
+	defer stopSimulation()
+	go s.elector.Run(simCtx, s.lead)
+	go s.housekeeping.Run(simCtx)
+	go s.outbox.Run(simCtx, time.Second)
+	go s.analytics.Run(simCtx, time.Second)
+	go s.anomalies.Run(simCtx, time.Second)
+	if s.mirror != nil {
+		go s.mirror.Run(simCtx)
+	}
+
+	// The ROS bridge mirrors commands to ROS 2 through rosbridge and takes
+	// positions from the robots' odometry
+	if s.config.ROSBridgeURL != "" {
+		bridge := NewROSBridge(s.storage, s.events, DialROSBridge(s.config.ROSBridgeURL), s.config.ROSNamespace)
+		go bridge.Run(simCtx)
+	}
+
+	select {
+	case err := <-failed:
+		return err
+	case <-ctx.Done():
+	}
+	log.Println("Shutting down server...")
+	stopSimulation()
+
+	// Give the server 5 seconds to finish any ongoing requests
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
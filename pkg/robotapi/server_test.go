@@ -0,0 +1,200 @@
+package robotapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// sendAdmin posts to an admin endpoint with the admin token
+func sendAdmin(router *gin.Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", path, nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestServerUnderBasePath(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	assert.NoError(t, storage.CreateRobot(&Robot{ID: "embedded", Energy: MaxEnergy}))
+
+	config := DefaultConfig()
+	config.BasePath = "/robots-api/"
+	config.Storage = storage
+	config.AdminToken = "secret"
+	server, err := New(config)
+	assert.NoError(t, err)
+	assert.Same(t, storage, server.Storage())
+	router := server.Router()
+
+	assert.Equal(t, http.StatusOK, send(router, "GET", "/robots-api/health", "").Code)
+	assert.Equal(t, http.StatusNotFound, send(router, "GET", "/health", "").Code)
+
+	// Only the given storage is served, and links point below the base path
+	assert.Equal(t, http.StatusNotFound, send(router, "GET", "/robots-api/robot/robot1/status", "").Code)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "http://api.example/robots-api/robot/embedded/status", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var status struct {
+		Links []Link `json:"links"`
+	}
+	json.Unmarshal(w.Body.Bytes(), &status)
+	assert.Equal(t, "http://api.example/robots-api/robot/embedded/status", status.Links[0].Href)
+
+	// The emergency stop can be cleared below the base path as well
+	assert.Equal(t, http.StatusOK, sendAdmin(router, "/robots-api/admin/estop").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, send(router, "POST", "/robots-api/robot/embedded/move", `{"direction": "up"}`).Code)
+	assert.Equal(t, http.StatusOK, sendAdmin(router, "/robots-api/admin/estop/clear").Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robots-api/robot/embedded/move", `{"direction": "up"}`).Code)
+}
+
+func TestServerConfigValidation(t *testing.T) {
+	config := DefaultConfig()
+	config.CombatRules = "chess"
+	_, err := New(config)
+	assert.ErrorContains(t, err, "invalid combat configuration")
+
+	config = DefaultConfig()
+	config.ClientCA = "ca.pem"
+	_, err = New(config)
+	assert.Error(t, err, "client certificates need HTTPS")
+
+	t.Setenv("WORLD_MODE", "hex")
+	_, err = ConfigFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("WORLD_MODE", "continuous")
+	t.Setenv("BASE_PATH", "/api")
+	t.Setenv("QUOTAS", "move=10")
+	config, err = ConfigFromEnv()
+	assert.NoError(t, err)
+	assert.True(t, config.Continuous)
+	assert.Equal(t, "/api", config.BasePath)
+	assert.Equal(t, map[string]int{"move": 10}, config.Quotas)
+}
+
+func TestServerRunStopsWithContext(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.Port = "0"
+	server, err := New(config)
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- server.Run(ctx) }()
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(6 * time.Second):
+		t.Fatal("Run did not return after the context was cancelled")
+	}
+}
+
+func TestReverseProxyPrefix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.BasePath = "/robots"
+	server, err := New(config)
+	assert.NoError(t, err)
+	router := server.Router()
+
+	// The ingress routes /api/* here and strips /api
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-Forwarded-Proto", "https")
+		req.Header.Set("X-Forwarded-Host", "fleet.example, internal.proxy")
+		req.Header.Set("X-Forwarded-Prefix", "/api/")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var actions PaginatedActions
+	json.Unmarshal(get("/robots/robot/robot1/actions?page=1&size=1").Body.Bytes(), &actions)
+	assert.Equal(t, "https://fleet.example/api/robots/robot/robot1/actions/1", actions.Actions[0].Links[0].Href)
+	assert.Equal(t, "https://fleet.example/api/robots/robot/robot1/actions?page=2&size=1", actions.Links[0].Href)
+
+	var items struct {
+		Links []Link `json:"links"`
+	}
+	json.Unmarshal(get("/robots/items?size=1").Body.Bytes(), &items)
+	assert.Contains(t, items.Links[0].Href, "https://fleet.example/api/robots/items?")
+
+	var root struct {
+		Endpoints []string `json:"endpoints"`
+	}
+	json.Unmarshal(get("/robots/").Body.Bytes(), &root)
+	assert.Contains(t, root.Endpoints, "/api/robots/health")
+}
+
+func TestServersShareStorage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	config := DefaultConfig()
+	config.Storage = storage
+	config.CacheTTL = 0
+	placeInRange(t, storage, "robot1", "robot2")
+
+	// Two instances in front of one world attack concurrently
+	var routers []*gin.Engine
+	for i := 0; i < 2; i++ {
+		server, err := New(config)
+		assert.NoError(t, err)
+		routers = append(routers, server.Router())
+	}
+	before, _ := storage.GetRobot("robot1")
+	targetBefore, _ := storage.GetRobot("robot2")
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	succeeded, hits, damage := 0, 0, 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(router *gin.Engine) {
+			defer wg.Done()
+			w := send(router, "POST", "/robot/robot1/attack/robot2", "")
+			if w.Code != http.StatusOK {
+				return
+			}
+			var result struct {
+				Hit    bool `json:"hit"`
+				Damage int  `json:"damage_dealt"`
+			}
+			json.Unmarshal(w.Body.Bytes(), &result)
+			mutex.Lock()
+			defer mutex.Unlock()
+			succeeded++
+			if result.Hit {
+				hits++
+				damage += result.Damage
+			}
+		}(routers[i%2])
+	}
+	wg.Wait()
+
+	// Every attack changed attacker and target together
+	attacker, _ := storage.GetRobot("robot1")
+	target, _ := storage.GetRobot("robot2")
+	assert.Positive(t, succeeded)
+	assert.Equal(t, succeeded, len(attacker.Actions)-len(before.Actions))
+	damaged := 0
+	for _, action := range target.Actions[len(targetBefore.Actions):] {
+		if action.Type == "damaged" {
+			damaged++
+		}
+	}
+	assert.Equal(t, hits, damaged)
+	assert.Equal(t, max(targetBefore.Energy-damage, 0), target.Energy)
+}
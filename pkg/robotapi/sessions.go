@@ -0,0 +1,105 @@
+package robotapi
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// Session is a short-lived play token that controls a single robot on
+// behalf of the principal who issued it
+type Session struct {
+	Token     string    `json:"token"`
+	RobotID   string    `json:"robot_id"`
+	Principal Principal `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SessionStore keeps the issued play tokens in memory
+type SessionStore struct {
+	sessions map[string]*Session
+	ttl      time.Duration
+	now      func() time.Time
+	mutex    sync.RWMutex
+}
+
+// NewSessionStore creates a store whose sessions live at most ttl
+func NewSessionStore(ttl time.Duration) *SessionStore {
+	return &SessionStore{
+		sessions: make(map[string]*Session),
+		ttl:      ttl,
+		now:      time.Now,
+	}
+}
+
+// MaxTTL returns the longest lifetime a session may have
+func (s *SessionStore) MaxTTL() time.Duration {
+	return s.ttl
+}
+
+// Issue creates a session for the robot. The session acts as the issuing
+// principal, but never with more than the user role. ttl is capped at the
+// store's maximum; zero selects the maximum.
+func (s *SessionStore) Issue(principal Principal, robotID string, ttl time.Duration) (*Session, error) {
+	if ttl <= 0 || ttl > s.ttl {
+		ttl = s.ttl
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+
+	principal.Provider = "session"
+	principal.Roles = []string{RoleUser}
+	principal.Robot = robotID
+
+	session := &Session{
+		Token:     hex.EncodeToString(raw),
+		RobotID:   robotID,
+		Principal: principal,
+		ExpiresAt: s.now().Add(ttl),
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.sessions[session.Token] = session
+	return session, nil
+}
+
+// Lookup returns the principal of a valid, unexpired session
+func (s *SessionStore) Lookup(token string) (*Principal, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	session, exists := s.sessions[token]
+	if !exists || !s.now().Before(session.ExpiresAt) {
+		return nil, false
+	}
+	principal := session.Principal
+	return &principal, true
+}
+
+// Revoke ends a session; it reports whether the session existed
+func (s *SessionStore) Revoke(token string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, exists := s.sessions[token]
+	delete(s.sessions, token)
+	return exists
+}
+
+// Sweep removes expired sessions. It is registered as a simulation system.
+func (s *SessionStore) Sweep(tick int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+	for token, session := range s.sessions {
+		if !now.Before(session.ExpiresAt) {
+			delete(s.sessions, token)
+		}
+	}
+}
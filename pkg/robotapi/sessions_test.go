@@ -0,0 +1,99 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func doSessionRequest(router *gin.Engine, method, path, token, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Session-Token", token)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSessionStoreExpiry(t *testing.T) {
+	store := NewSessionStore(time.Minute)
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	session, err := store.Issue(Principal{ID: "alice", Roles: []string{RoleUser, RoleAdmin}}, "robot1", time.Hour)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(time.Minute), session.ExpiresAt, "TTL is capped")
+
+	principal, ok := store.Lookup(session.Token)
+	assert.True(t, ok)
+	assert.Equal(t, "alice", principal.ID)
+	assert.Equal(t, "robot1", principal.Robot)
+	assert.False(t, principal.HasRole(RoleAdmin), "sessions never carry admin rights")
+
+	now = now.Add(2 * time.Minute)
+	_, ok = store.Lookup(session.Token)
+	assert.False(t, ok)
+
+	store.Sweep(0)
+	assert.False(t, store.Revoke(session.Token))
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	router, _ := setupTestRouterWithKeys(testAPIKeys)
+
+	assert.Equal(t, http.StatusUnauthorized, doRequest(router, "POST", "/sessions", "", `{"robot_id": "robot1"}`).Code)
+	assert.Equal(t, http.StatusNotFound, doRequest(router, "POST", "/sessions", "alice-key", `{"robot_id": "nope"}`).Code)
+
+	w := doRequest(router, "POST", "/sessions", "alice-key", `{"robot_id": "robot1", "ttl_seconds": 60}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	token := response["token"].(string)
+
+	move := `{"direction": "up"}`
+	assert.Equal(t, http.StatusOK, doSessionRequest(router, "POST", "/robot/robot1/move", token, move).Code)
+	assert.Equal(t, http.StatusForbidden, doSessionRequest(router, "POST", "/robot/robot2/move", token, move).Code)
+	assert.Equal(t, http.StatusForbidden, doSessionRequest(router, "POST", "/sessions", token, `{"robot_id": "robot1"}`).Code)
+
+	assert.NotContains(t, w.Body.String(), "/sessions/"+token, "the token never appears in URLs")
+	assert.Equal(t, http.StatusNotFound, doRequest(router, "DELETE", "/sessions/current", "", "").Code)
+	assert.Equal(t, http.StatusOK, doSessionRequest(router, "DELETE", "/sessions/current", token, "").Code)
+	assert.Equal(t, http.StatusNotFound, doSessionRequest(router, "DELETE", "/sessions/current", token, "").Code)
+	assert.Equal(t, http.StatusUnauthorized, doSessionRequest(router, "POST", "/robot/robot1/move", token, move).Code)
+}
+
+func TestSessionCannotExceedIssuerPermissions(t *testing.T) {
+	router, _ := setupTestRouterWithKeys(testAPIKeys)
+
+	assert.Equal(t, http.StatusCreated, doRequest(router, "POST", "/robots", "alice-key", `{"id": "alices"}`).Code)
+
+	// Bob may open a session on alice's robot but can't move it through the session
+	w := doRequest(router, "POST", "/sessions", "bob-key", `{"robot_id": "alices"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+
+	w = doSessionRequest(router, "POST", "/robot/alices/move", response["token"].(string), `{"direction": "up"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestSessionCannotManagePermissions(t *testing.T) {
+	router, _ := setupTestRouterWithKeys(testAPIKeys)
+
+	assert.Equal(t, http.StatusCreated, doRequest(router, "POST", "/robots", "alice-key", `{"id": "alices"}`).Code)
+	w := doRequest(router, "POST", "/sessions", "alice-key", `{"robot_id": "alices"}`)
+	var response map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &response)
+	token := response["token"].(string)
+
+	grant := `{"grantee": "bob", "permissions": ["move"]}`
+	assert.Equal(t, http.StatusForbidden, doSessionRequest(router, "POST", "/robot/alices/permissions", token, grant).Code)
+	assert.Equal(t, http.StatusForbidden, doSessionRequest(router, "GET", "/robot/alices/permissions", token, "").Code)
+	assert.Equal(t, http.StatusOK, doRequest(router, "POST", "/robot/alices/permissions", "alice-key", grant).Code)
+}
@@ -0,0 +1,75 @@
+package robotapi
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func doSignedRequest(router *gin.Engine, method, path, keyID, secret string, timestamp int64, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Key-ID", keyID)
+	req.Header.Set("X-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("X-Signature", SignRequest(secret, method, path, timestamp, []byte(body)))
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSignedRequest(t *testing.T) {
+	router, storage := setupTestRouterWithKeys(testAPIKeys)
+	now := time.Now().Unix()
+
+	w := doSignedRequest(router, "POST", "/robot/robot1/move", "alice", "alice-key", now, `{"direction": "up"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, 1, robot.Position.Y, "handler still reads the body")
+
+	// The same signature again is a replay
+	w = doSignedRequest(router, "POST", "/robot/robot1/move", "alice", "alice-key", now, `{"direction": "up"}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "Replayed")
+}
+
+func TestSignedRequestRejected(t *testing.T) {
+	router, _ := setupTestRouterWithKeys(testAPIKeys)
+	now := time.Now().Unix()
+
+	// Wrong secret
+	w := doSignedRequest(router, "GET", "/robot/robot1/status", "alice", "bob-key", now, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Unknown key ID
+	w = doSignedRequest(router, "GET", "/robot/robot1/status", "mallory", "alice-key", now, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Expired: outside the time window
+	w = doSignedRequest(router, "GET", "/robot/robot1/status", "alice", "alice-key", now-600, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// Body changed after signing
+	req, _ := http.NewRequest("POST", "/robot/robot1/move", bytes.NewBufferString(`{"direction": "down"}`))
+	req.Header.Set("X-Key-ID", "alice")
+	req.Header.Set("X-Timestamp", strconv.FormatInt(now, 10))
+	req.Header.Set("X-Signature", SignRequest("alice-key", "POST", "/robot/robot1/move", now, []byte(`{"direction": "up"}`)))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestSignedRequestBodyLimit(t *testing.T) {
+	router, _ := setupTestRouterWithKeys(testAPIKeys)
+
+	body := `{"direction": "up", "padding": "` + strings.Repeat("x", maxSignedBodyBytes) + `"}`
+	w := doSignedRequest(router, "POST", "/robot/robot1/move", "alice", "alice-key", time.Now().Unix(), body)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "too large")
+}
@@ -0,0 +1,102 @@
+package robotapi
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// TickFunc is a simulation system that is invoked once per tick
+type TickFunc func(tick int64)
+
+// Simulation drives time-based world mechanics with a fixed tick interval
+type Simulation struct {
+	interval time.Duration
+	systems  []TickFunc
+	tick     int64
+	paused   bool
+	clock    Clock
+	mutex    sync.Mutex
+}
+
+// NewSimulation creates a simulation that ticks at the given interval
+func NewSimulation(interval time.Duration) *Simulation {
+	return &Simulation{interval: interval, clock: SystemClock{}}
+}
+
+// SetClock replaces the clock whose ticker drives Run
+func (s *Simulation) SetClock(clock Clock) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clock = clock
+}
+
+// AddSystem registers a system; systems run in registration order
+func (s *Simulation) AddSystem(system TickFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.systems = append(s.systems, system)
+}
+
+// Tick returns the number of completed ticks
+func (s *Simulation) Tick() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.tick
+}
+
+// Pause stops the simulation loop from ticking until Resume is called
+func (s *Simulation) Pause() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.paused = true
+}
+
+// Resume lets a paused simulation loop tick again
+func (s *Simulation) Resume() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.paused = false
+}
+
+// Paused reports whether the simulation loop is paused
+func (s *Simulation) Paused() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.paused
+}
+
+// Step advances the simulation by exactly one tick
+func (s *Simulation) Step() {
+	s.mutex.Lock()
+	s.tick++
+	tick := s.tick
+	systems := append([]TickFunc(nil), s.systems...)
+	s.mutex.Unlock()
+
+	for _, system := range systems {
+		system(tick)
+	}
+}
+
+// Run steps the simulation until the context is cancelled, skipping ticks
+// while it is paused
+func (s *Simulation) Run(ctx context.Context) {
+	s.mutex.Lock()
+	ticker := s.clock.NewTicker(s.interval)
+	s.mutex.Unlock()
+	defer ticker.Stop()
+
+	log.Printf("Simulation running with a tick interval of %s", s.interval)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			if !s.Paused() {
+				s.Step()
+			}
+		}
+	}
+}
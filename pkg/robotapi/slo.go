@@ -0,0 +1,221 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sloSamples is how many of the latest latencies are kept per action type
+// for the percentiles
+const sloSamples = 1024
+
+// SLOTargets are the latency objectives of commands by action type; "*" is
+// the objective of action types without their own
+type SLOTargets map[string]time.Duration
+
+// ParseSLOTargets reads targets given as action=duration pairs, e.g.
+// "move=50ms,attack=100ms,*=250ms"
+func ParseSLOTargets(config string) (SLOTargets, error) {
+	targets := make(SLOTargets)
+	for _, pair := range strings.Split(config, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		action, value, found := strings.Cut(pair, "=")
+		target, err := time.ParseDuration(value)
+		if !found || action == "" || err != nil || target <= 0 {
+			return nil, errors.New("SLO targets must be given as action=duration pairs")
+		}
+		targets[action] = target
+	}
+	return targets, nil
+}
+
+// target returns the objective of an action type, 0 if there is none
+func (t SLOTargets) target(action string) time.Duration {
+	if target, exists := t[action]; exists {
+		return target
+	}
+	return t["*"]
+}
+
+// CommandLatency sums up the latencies of one action type, in milliseconds
+type CommandLatency struct {
+	Action   string  `json:"action"`
+	Count    int64   `json:"count"` // commands measured since the start
+	P50      float64 `json:"p50_ms"`
+	P95      float64 `json:"p95_ms"`
+	P99      float64 `json:"p99_ms"`
+	Max      float64 `json:"max_ms"`
+	Target   float64 `json:"target_ms,omitempty"`
+	Breaches int64   `json:"breaches"` // commands slower than the target
+}
+
+// latencyWindow holds the latest latencies of an action type
+type latencyWindow struct {
+	samples  []time.Duration // ring buffer of at most sloSamples
+	next     int
+	count    int64
+	breaches int64
+}
+
+// SLOTracker measures how long commands take from the moment the request is
+// received until the change is committed, per action type, and compares
+// that with the objectives. Percentiles are over the latest sloSamples
+// commands of each type.
+type SLOTracker struct {
+	targets     SLOTargets
+	logBreaches bool
+	clock       Clock
+	actions     map[string]*latencyWindow
+	mutex       sync.Mutex
+}
+
+// NewSLOTracker creates a tracker; logBreaches logs every command slower
+// than its target
+func NewSLOTracker(targets SLOTargets, logBreaches bool, clock Clock) *SLOTracker {
+	return &SLOTracker{targets: targets, logBreaches: logBreaches, clock: clock, actions: make(map[string]*latencyWindow)}
+}
+
+// Observe records the latency of a committed command
+func (t *SLOTracker) Observe(action string, latency time.Duration) {
+	target := t.targets.target(action)
+	breach := target > 0 && latency > target
+
+	t.mutex.Lock()
+	window, exists := t.actions[action]
+	if !exists {
+		window = &latencyWindow{}
+		t.actions[action] = window
+	}
+	if len(window.samples) < sloSamples {
+		window.samples = append(window.samples, latency)
+	} else {
+		window.samples[window.next] = latency
+		window.next = (window.next + 1) % sloSamples
+	}
+	window.count++
+	if breach {
+		window.breaches++
+	}
+	t.mutex.Unlock()
+
+	if breach && t.logBreaches {
+		log.Printf("SLO breach: %s took %s, target is %s", action, latency, target)
+	}
+}
+
+// milliseconds converts a latency for the report
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// percentile picks the nearest-rank percentile of sorted latencies
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (len(sorted)*p + 99) / 100
+	return sorted[max(rank, 1)-1]
+}
+
+// Report returns the latencies of all action types measured so far
+func (t *SLOTracker) Report() []CommandLatency {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	report := make([]CommandLatency, 0, len(t.actions))
+	for action, window := range t.actions {
+		sorted := append([]time.Duration{}, window.samples...)
+		sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+		report = append(report, CommandLatency{
+			Action:   action,
+			Count:    window.count,
+			P50:      milliseconds(percentile(sorted, 50)),
+			P95:      milliseconds(percentile(sorted, 95)),
+			P99:      milliseconds(percentile(sorted, 99)),
+			Max:      milliseconds(sorted[len(sorted)-1]),
+			Target:   milliseconds(t.targets.target(action)),
+			Breaches: window.breaches,
+		})
+	}
+	sort.Slice(report, func(i, j int) bool { return report[i].Action < report[j].Action })
+	return report
+}
+
+// WriteMetrics writes the report in the Prometheus text format
+func (t *SLOTracker) WriteMetrics(w io.Writer) {
+	report := t.Report()
+	fmt.Fprintln(w, "# HELP robot_command_latency_seconds Latency from request receipt to state commit.")
+	fmt.Fprintln(w, "# TYPE robot_command_latency_seconds summary")
+	for _, latency := range report {
+		for _, quantile := range []struct {
+			name  string
+			value float64
+		}{{"0.5", latency.P50}, {"0.95", latency.P95}, {"0.99", latency.P99}} {
+			fmt.Fprintf(w, "robot_command_latency_seconds{action=%q,quantile=%q} %s\n", latency.Action, quantile.name, seconds(quantile.value))
+		}
+		fmt.Fprintf(w, "robot_command_latency_seconds_count{action=%q} %d\n", latency.Action, latency.Count)
+	}
+	fmt.Fprintln(w, "# HELP robot_command_slo_breaches_total Commands slower than their latency objective.")
+	fmt.Fprintln(w, "# TYPE robot_command_slo_breaches_total counter")
+	for _, latency := range report {
+		fmt.Fprintf(w, "robot_command_slo_breaches_total{action=%q} %d\n", latency.Action, latency.Breaches)
+	}
+}
+
+// seconds formats milliseconds as seconds for the metrics
+func seconds(ms float64) string {
+	return strconv.FormatFloat(ms/1000, 'f', -1, 64)
+}
+
+// commandAction names the action type of a robot command: the route segment
+// after /robot/:id/, with the name of custom actions. Other requests are no
+// commands and have none.
+func commandAction(c *gin.Context) string {
+	if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead {
+		return ""
+	}
+	_, rest, found := strings.Cut(c.FullPath(), "/robot/:id/")
+	if !found {
+		return ""
+	}
+	action, _, _ := strings.Cut(rest, "/")
+	if action == "custom" {
+		return "custom:" + c.Param("action")
+	}
+	return action
+}
+
+// Track measures the commands; it must be the first middleware so queueing
+// in the others counts too
+func (t *SLOTracker) Track() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		received := t.clock.Now()
+		c.Next()
+		// Only commands that changed the state count
+		if action := commandAction(c); action != "" && c.Writer.Status() < http.StatusMultipleChoices {
+			t.Observe(action, t.clock.Now().Sub(received))
+		}
+	}
+}
+
+// GetSLOReport reports the command latencies against their objectives, as
+// JSON or, for Accept: text/plain, as Prometheus metrics
+func (h *RobotHandler) GetSLOReport(c *gin.Context) {
+	if c.NegotiateFormat(gin.MIMEJSON, gin.MIMEPlain) == gin.MIMEPlain {
+		c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		c.Status(http.StatusOK)
+		h.slo.WriteMetrics(c.Writer)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"commands": h.slo.Report()})
+}
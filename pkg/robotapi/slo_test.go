@@ -0,0 +1,113 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSLOTargets(t *testing.T) {
+	targets, err := ParseSLOTargets("move=50ms, attack=0.1s,*=250ms")
+	require.NoError(t, err)
+	assert.Equal(t, 50*time.Millisecond, targets.target("move"))
+	assert.Equal(t, 100*time.Millisecond, targets.target("attack"))
+	assert.Equal(t, 250*time.Millisecond, targets.target("pickup"))
+
+	for _, config := range []string{"move", "move=fast", "=50ms", "move=-1s"} {
+		_, err := ParseSLOTargets(config)
+		assert.Error(t, err, config)
+	}
+}
+
+func TestSLOTrackerPercentiles(t *testing.T) {
+	tracker := NewSLOTracker(SLOTargets{"move": 90 * time.Millisecond}, false, SystemClock{})
+	for ms := 100; ms >= 1; ms-- {
+		tracker.Observe("move", time.Duration(ms)*time.Millisecond)
+	}
+	tracker.Observe("scan", 3*time.Millisecond)
+
+	assert.Equal(t, []CommandLatency{
+		{Action: "move", Count: 100, P50: 50, P95: 95, P99: 99, Max: 100, Target: 90, Breaches: 10},
+		{Action: "scan", Count: 1, P50: 3, P95: 3, P99: 3, Max: 3},
+	}, tracker.Report())
+
+	// Percentiles are over the latest commands only
+	for i := 0; i < sloSamples; i++ {
+		tracker.Observe("move", time.Millisecond)
+	}
+	move := tracker.Report()[0]
+	assert.Equal(t, int64(100+sloSamples), move.Count)
+	assert.Equal(t, 1.0, move.Max)
+	assert.Equal(t, int64(10), move.Breaches)
+}
+
+func TestSLOTrackerMeasuresCommits(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	tracker := NewSLOTracker(nil, false, clock)
+	router := gin.New()
+	router.Use(tracker.Track())
+	slow := func(status int) gin.HandlerFunc {
+		return func(c *gin.Context) {
+			clock.Advance(40 * time.Millisecond)
+			c.Status(status)
+		}
+	}
+	router.POST("/robot/:id/move", slow(http.StatusOK))
+	router.POST("/robot/:id/attack/:targetId", slow(http.StatusConflict))
+	router.POST("/robot/:id/custom/:action", slow(http.StatusOK))
+	router.GET("/robot/:id/status", slow(http.StatusOK))
+
+	for _, request := range [][2]string{
+		{"POST", "/robot/robot1/move"},
+		{"POST", "/robot/robot1/attack/robot2"}, // rejected, nothing committed
+		{"POST", "/robot/robot1/custom/dance"},
+		{"GET", "/robot/robot1/status"}, // no command
+	} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(request[0], request[1], nil))
+	}
+
+	report := tracker.Report()
+	require.Len(t, report, 2)
+	assert.Equal(t, "custom:dance", report[0].Action)
+	assert.Equal(t, "move", report[1].Action)
+	assert.Equal(t, 40.0, report[1].P99)
+}
+
+func TestGetSLOReport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	config.Clock = NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	config.SLOTargets = SLOTargets{"*": 100 * time.Millisecond}
+	server, err := New(config)
+	require.NoError(t, err)
+	router := server.Router()
+
+	require.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+
+	w := adminRequest(router, "GET", "/admin/slo", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Commands []CommandLatency `json:"commands"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []CommandLatency{{Action: "move", Count: 1, Target: 100}}, response.Commands)
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/admin/slo", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Accept", "text/plain")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+	assert.Contains(t, w.Body.String(), `robot_command_latency_seconds{action="move",quantile="0.99"} 0`)
+	assert.Contains(t, w.Body.String(), `robot_command_latency_seconds_count{action="move"} 1`)
+	assert.Contains(t, w.Body.String(), `robot_command_slo_breaches_total{action="move"} 0`)
+}
@@ -0,0 +1,477 @@
+package robotapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrSnapshotNotFound is returned for snapshots missing in the archive
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// WorldSnapshot is the complete state of a world, enough to restore it
+type WorldSnapshot struct {
+	CreatedAt time.Time                      `json:"created_at"`
+	Robots    []*Robot                       `json:"robots"`
+	Items     []Item                         `json:"items"` // including carried ones
+	Hazards   []Hazard                       `json:"hazards"`
+	Layout    MapLayout                      `json:"layout"`
+	Grants    map[string]map[string][]string `json:"grants"`          // robot ID -> grantee -> permissions
+	Audit     []AuditRecord                  `json:"audit,omitempty"` // origins of the robots' actions
+}
+
+// Snapshot copies the complete world state
+func (s *RobotStorage) Snapshot() WorldSnapshot {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.snapshot()
+}
+
+// VersionedSnapshot copies the complete world state together with the world
+// version it was taken at
+func (s *RobotStorage) VersionedSnapshot() (WorldSnapshot, uint64) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.snapshot(), s.version
+}
+
+// snapshot copies the world state; callers must hold the lock
+func (s *RobotStorage) snapshot() WorldSnapshot {
+	snapshot := WorldSnapshot{
+		CreatedAt: s.clock.Now().UTC(),
+		Robots:    make([]*Robot, 0, len(s.robots)),
+		Items:     make([]Item, 0, len(s.items)),
+		Hazards:   append([]Hazard{}, s.hazards...),
+		Layout:    s.layout(),
+		Grants:    make(map[string]map[string][]string),
+		Audit:     s.audit("", ""),
+	}
+	for _, id := range s.sortedRobotIDs() {
+		snapshot.Robots = append(snapshot.Robots, s.robots[id].clone())
+	}
+	for _, item := range s.items {
+		snapshot.Items = append(snapshot.Items, *item)
+	}
+	sort.Slice(snapshot.Items, func(i, j int) bool {
+		return snapshot.Items[i].ID < snapshot.Items[j].ID
+	})
+	for robotID, grantees := range s.grants {
+		snapshot.Grants[robotID] = make(map[string][]string)
+		for grantee, permissions := range grantees {
+			snapshot.Grants[robotID][grantee] = append([]string{}, permissions...)
+		}
+	}
+	return snapshot
+}
+
+// Restore replaces the world with the snapshot. Items in a robot's
+// inventory are carried again. The world version keeps growing, so clients
+// syncing changes see every restored entity as changed and every entity
+// missing from the snapshot as removed.
+func (s *RobotStorage) Restore(snapshot WorldSnapshot) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	for id := range s.robots {
+		s.robotChanged(id) // reported as removed unless restored below
+	}
+	for id := range s.items {
+		s.itemChanged(id)
+	}
+	s.robots = make(map[string]*Robot)
+	s.items = make(map[string]*Item)
+	s.index = NewSearchIndex()
+	s.indexedActions = make(map[string]int)
+
+	carried := make(map[string]bool)
+	for _, robot := range snapshot.Robots {
+		robot = robot.clone()
+		s.robots[robot.ID] = robot
+		s.reindexRobot(robot)
+		s.robotChanged(robot.ID)
+		for _, itemID := range robot.Inventory {
+			carried[itemID] = true
+		}
+	}
+	s.restoreAudit(snapshot.Audit)
+	for _, item := range snapshot.Items {
+		item := item
+		item.carried = carried[item.ID]
+		s.items[item.ID] = &item
+		s.reindexItem(&item)
+		s.itemChanged(item.ID)
+	}
+
+	s.grants = make(map[string]map[string][]string)
+	for robotID, grantees := range snapshot.Grants {
+		s.grants[robotID] = make(map[string][]string)
+		for grantee, permissions := range grantees {
+			s.grants[robotID][grantee] = append([]string{}, permissions...)
+		}
+	}
+
+	s.hazards = append([]Hazard{}, snapshot.Hazards...)
+	s.hazardsChanged()
+
+	s.bounds = snapshot.Layout.Bounds
+	s.obstacles = make(map[Position]bool)
+	for _, p := range snapshot.Layout.Obstacles {
+		s.obstacles[p] = true
+	}
+	s.floorLinks = make(map[Position]string)
+	for _, link := range snapshot.Layout.FloorLinks {
+		s.floorLinks[link.Position] = link.Type
+	}
+	s.spawnPoints = append([]Position{}, snapshot.Layout.SpawnPoints...)
+	s.nextSpawn = 0
+	s.setZones(snapshot.Layout.Zones)
+	s.layoutChanged()
+}
+
+// SnapshotArchive stores snapshots outside the process
+type SnapshotArchive interface {
+	Put(ctx context.Context, name string, data []byte) error
+	// Get returns ErrSnapshotNotFound for unknown names
+	Get(ctx context.Context, name string) ([]byte, error)
+}
+
+// S3Archive keeps snapshots in a bucket of an S3 compatible object store.
+// Google Cloud Storage works through its XML API with HMAC keys, using the
+// endpoint https://storage.googleapis.com and the region "auto".
+type S3Archive struct {
+	Endpoint  string // e.g. https://s3.eu-central-1.amazonaws.com
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Client    *http.Client
+	now       func() time.Time
+}
+
+// NewS3Archive creates an archive for the bucket
+func NewS3Archive(endpoint, region, bucket, accessKey, secretKey string) *S3Archive {
+	return &S3Archive{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Region:    region,
+		Bucket:    bucket,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		Client:    &http.Client{Timeout: time.Minute},
+		now:       time.Now,
+	}
+}
+
+// Put uploads a snapshot
+func (a *S3Archive) Put(ctx context.Context, name string, data []byte) error {
+	resp, err := a.do(ctx, http.MethodPut, name, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("uploading %s failed: %s", name, resp.Status)
+	}
+	return nil
+}
+
+// Get downloads a snapshot
+func (a *S3Archive) Get(ctx context.Context, name string) ([]byte, error) {
+	resp, err := a.do(ctx, http.MethodGet, name, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return io.ReadAll(resp.Body)
+	case http.StatusNotFound:
+		return nil, ErrSnapshotNotFound
+	default:
+		return nil, fmt.Errorf("downloading %s failed: %s", name, resp.Status)
+	}
+}
+
+// do sends a request for an object, signed with AWS Signature Version 4
+func (a *S3Archive) do(ctx context.Context, method, name string, body []byte) (*http.Response, error) {
+	path := "/" + a.Bucket + "/" + s3Escape(name)
+	req, err := http.NewRequestWithContext(ctx, method, a.Endpoint+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	now := a.now().UTC()
+	stamp := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", stamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	canonical := strings.Join([]string{
+		method,
+		path,
+		"", // no query
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + stamp,
+		"",
+		"host;x-amz-content-sha256;x-amz-date",
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + a.Region + "/s3/aws4_request"
+	toSign := "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := []byte("AWS4" + a.SecretKey)
+	for _, part := range []string{day, a.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, toSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=%s",
+		a.AccessKey, scope, signature))
+
+	return a.Client.Do(req)
+}
+
+// s3Escape encodes an object key for the canonical request; slashes
+// separate segments and stay as they are
+func s3Escape(key string) string {
+	var escaped strings.Builder
+	for _, b := range []byte(key) {
+		switch {
+		case 'a' <= b && b <= 'z', 'A' <= b && b <= 'Z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/':
+			escaped.WriteByte(b)
+		default:
+			fmt.Fprintf(&escaped, "%%%02X", b)
+		}
+	}
+	return escaped.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// SnapshotArchiver uploads gzipped JSON snapshots of the default world and
+// the worlds of all tenants
+type SnapshotArchiver struct {
+	storage *RobotStorage
+	tenants *TenantStore // nil without tenants
+	archive SnapshotArchive
+	prefix  string
+}
+
+// NewSnapshotArchiver creates an archiver for the world; object names
+// start with prefix
+func NewSnapshotArchiver(storage *RobotStorage, archive SnapshotArchive, prefix string) *SnapshotArchiver {
+	return &SnapshotArchiver{storage: storage, archive: archive, prefix: prefix}
+}
+
+// SetTenants makes the archiver archive the tenants' worlds as well
+func (a *SnapshotArchiver) SetTenants(tenants *TenantStore) {
+	a.tenants = tenants
+}
+
+// worldOf returns the storage of a tenant, the default world for ""
+func (a *SnapshotArchiver) worldOf(tenantID string) (*RobotStorage, bool) {
+	if tenantID == "" {
+		return a.storage, true
+	}
+	if a.tenants == nil {
+		return nil, false
+	}
+	tenant, exists := a.tenants.Get(tenantID)
+	if !exists {
+		return nil, false
+	}
+	return tenant.Storage, true
+}
+
+// namePrefix returns the start of the object names of a tenant's
+// snapshots; the default world's snapshots have no tenant part
+func (a *SnapshotArchiver) namePrefix(tenantID string) string {
+	if tenantID == "" {
+		return a.prefix
+	}
+	return a.prefix + "tenants/" + tenantID + "/"
+}
+
+// TenantOf returns the tenant whose world the named snapshot was taken of
+func (a *SnapshotArchiver) TenantOf(name string) string {
+	rest, found := strings.CutPrefix(name, a.prefix+"tenants/")
+	if !found {
+		return ""
+	}
+	tenantID, _, _ := strings.Cut(rest, "/")
+	return tenantID
+}
+
+// Archive uploads a snapshot of a tenant's world and returns its name. The
+// default world has the empty tenant ID.
+func (a *SnapshotArchiver) Archive(ctx context.Context, tenantID string) (string, error) {
+	storage, exists := a.worldOf(tenantID)
+	if !exists {
+		return "", ErrTenantNotFound
+	}
+	snapshot := storage.Snapshot()
+	var data bytes.Buffer
+	writer := gzip.NewWriter(&data)
+	if err := json.NewEncoder(writer).Encode(snapshot); err != nil {
+		return "", err
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	name := a.namePrefix(tenantID) + "world-" + snapshot.CreatedAt.Format("20060102T150405Z") + ".json.gz"
+	return name, a.archive.Put(ctx, name, data.Bytes())
+}
+
+// Load downloads and decodes a snapshot
+func (a *SnapshotArchiver) Load(ctx context.Context, name string) (WorldSnapshot, error) {
+	var snapshot WorldSnapshot
+	data, err := a.archive.Get(ctx, name)
+	if err != nil {
+		return snapshot, err
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return snapshot, &invalidSnapshotError{err}
+	}
+	if err := json.NewDecoder(reader).Decode(&snapshot); err != nil {
+		return snapshot, &invalidSnapshotError{err}
+	}
+	if err := snapshot.checkIDs(); err != nil {
+		return snapshot, &invalidSnapshotError{err}
+	}
+	return snapshot, nil
+}
+
+// checkIDs rejects snapshots with IDs clients could not have chosen
+func (snapshot WorldSnapshot) checkIDs() error {
+	for _, robot := range snapshot.Robots {
+		if err := CheckID(RobotIDs, robot.ID); err != nil {
+			return fmt.Errorf("robot %q: %w", robot.ID, err)
+		}
+	}
+	for _, item := range snapshot.Items {
+		if err := CheckID(ItemIDs, item.ID); err != nil {
+			return fmt.Errorf("item %q: %w", item.ID, err)
+		}
+	}
+	return nil
+}
+
+// invalidSnapshotError marks archive objects that are no snapshots
+type invalidSnapshotError struct {
+	err error
+}
+
+func (e *invalidSnapshotError) Error() string {
+	return "invalid snapshot: " + e.err.Error()
+}
+
+// Run archives the default world and every tenant's world every interval of
+// the default world's clock until ctx is done
+func (a *SnapshotArchiver) Run(ctx context.Context, interval time.Duration) {
+	ticker := a.storage.Clock().NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			worlds := []string{""}
+			if a.tenants != nil {
+				for _, tenant := range a.tenants.All() {
+					worlds = append(worlds, tenant.ID)
+				}
+			}
+			for _, tenantID := range worlds {
+				name, err := a.Archive(ctx, tenantID)
+				if err != nil {
+					log.Printf("Archiving the world of tenant %q failed: %v", tenantID, err)
+					continue
+				}
+				log.Printf("Archived the world of tenant %q as %s", tenantID, name)
+			}
+		}
+	}
+}
+
+// ArchiveSnapshot uploads a snapshot of the world right away
+func (h *RobotHandler) ArchiveSnapshot(c *gin.Context) {
+	if h.snapshots == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Snapshot archive is not configured"})
+		return
+	}
+	name, err := h.snapshots.Archive(c.Request.Context(), currentTenant(c))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"snapshot": name})
+}
+
+// RestoreSnapshot replaces the world with the snapshot named in the
+// snapshot parameter
+func (h *RobotHandler) RestoreSnapshot(c *gin.Context) {
+	if h.snapshots == nil {
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "Snapshot archive is not configured"})
+		return
+	}
+	name := c.Query("snapshot")
+	if name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "snapshot is required"})
+		return
+	}
+	// A tenant's world is only ever replaced by a snapshot of itself
+	if h.snapshots.TenantOf(name) != currentTenant(c) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Snapshot was taken of another tenant's world"})
+		return
+	}
+
+	snapshot, err := h.snapshots.Load(c.Request.Context(), name)
+	var invalid *invalidSnapshotError
+	switch {
+	case errors.Is(err, ErrSnapshotNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snapshot not found"})
+		return
+	case errors.As(err, &invalid):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.world(c).Restore(snapshot)
+	h.bus(c).PublishCommand(commandID(c), "world_restored", "", gin.H{"snapshot": name, "created_at": snapshot.CreatedAt})
+	c.JSON(http.StatusOK, gin.H{
+		"snapshot":   name,
+		"created_at": snapshot.CreatedAt,
+		"robots":     len(snapshot.Robots),
+		"items":      len(snapshot.Items),
+	})
+}
@@ -0,0 +1,192 @@
+package robotapi
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeBucket is a minimal S3 endpoint keeping objects in memory
+type fakeBucket struct {
+	objects map[string][]byte
+	mutex   sync.Mutex
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=key/") || !strings.Contains(auth, "/auto/s3/aws4_request") ||
+		r.Header.Get("X-Amz-Content-Sha256") == "" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		b.objects[r.URL.Path], _ = io.ReadAll(r.Body)
+	case http.MethodGet:
+		object, exists := b.objects[r.URL.Path]
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(object)
+	}
+}
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	storage.GrantPermissions("robot1", "bob", []string{PermMove})
+	storage.PlaceObstacle(Position{X: 5, Y: 5})
+	_, err := storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Inventory = append(robot.Inventory, "item1")
+		return nil
+	})
+	assert.NoError(t, err)
+	storage.RemoveItem("item1")
+	snapshot := storage.Snapshot()
+
+	restored := NewRobotStorage()
+	restored.Restore(snapshot)
+	assert.Equal(t, storage.GetAllRobots(), restored.GetAllRobots())
+	assert.Equal(t, storage.GetAvailableItems(), restored.GetAvailableItems())
+	assert.Equal(t, 5, restored.ItemCount())
+	assert.Equal(t, storage.GetLayout(), restored.GetLayout())
+	assert.Equal(t, storage.GetPermissions("robot1"), restored.GetPermissions("robot1"))
+	assert.NotEmpty(t, restored.Search("robot1", 1, 10).Robots)
+}
+
+func TestArchiveAndRestore(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	bucket := &fakeBucket{objects: make(map[string][]byte)}
+	endpoint := httptest.NewServer(bucket)
+	defer endpoint.Close()
+
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	config.CacheTTL = 0
+	config.SnapshotArchive = NewS3Archive(endpoint.URL, "auto", "backups", "key", "secret-key")
+	server, err := New(config)
+	assert.NoError(t, err)
+	router := server.Router()
+
+	before, _ := server.Storage().GetRobot("robot1")
+	name, err := server.snapshots.Archive(context.Background(), "")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(name, "snapshots/world-"))
+	assert.Contains(t, bucket.objects, "/backups/"+name)
+
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	assert.Equal(t, http.StatusCreated, send(router, "POST", "/robots", `{"id": "newcomer"}`).Code)
+
+	version := server.Storage().Version()
+	w := sendAdmin(router, "/admin/restore?snapshot="+name)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"newcomer"}, server.Storage().ChangesSince(version).RemovedRobots,
+		"syncing clients learn about robots missing from the snapshot")
+	after, _ := server.Storage().GetRobot("robot1")
+	assert.Equal(t, before.Position, after.Position)
+	assert.Len(t, after.Actions, len(before.Actions))
+	_, err = server.Storage().GetRobot("newcomer")
+	assert.ErrorIs(t, err, ErrRobotNotFound)
+	events := server.Events().Since(0)
+	assert.Equal(t, "world_restored", events[len(events)-1].Type)
+
+	assert.Equal(t, http.StatusBadRequest, sendAdmin(router, "/admin/restore").Code)
+	assert.Equal(t, http.StatusNotFound, sendAdmin(router, "/admin/restore?snapshot=missing.json.gz").Code)
+	bucket.objects["/backups/broken.json.gz"] = []byte("not gzip")
+	assert.Equal(t, http.StatusUnprocessableEntity, sendAdmin(router, "/admin/restore?snapshot=broken.json.gz").Code)
+	assert.Equal(t, http.StatusCreated, sendAdmin(router, "/admin/snapshots").Code)
+}
+
+func TestSnapshotsNeedAnArchive(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	server, err := New(config)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotImplemented, sendAdmin(server.Router(), "/admin/restore?snapshot=x").Code)
+}
+
+func TestS3Signature(t *testing.T) {
+	archive := NewS3Archive("https://storage.example", "eu-central-1", "bucket", "AKID", "secret")
+	archive.now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+	var auth string
+	archive.Client = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		auth = r.Header.Get("Authorization")
+		assert.Equal(t, "/bucket/a%20b/c.json.gz", r.URL.EscapedPath())
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})}
+
+	assert.NoError(t, archive.Put(context.Background(), "a b/c.json.gz", []byte("data")))
+	assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20261016/eu-central-1/s3/aws4_request, "+
+		"SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature="))
+}
+
+// roundTripFunc turns a function into an HTTP transport
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestEveryTenantsWorldIsArchived(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	bucket := &fakeBucket{objects: make(map[string][]byte)}
+	endpoint := httptest.NewServer(bucket)
+	defer endpoint.Close()
+
+	clock := NewFakeClock(time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	config.Tenants = []string{"lab1"}
+	config.Clock = clock
+	config.SnapshotArchive = NewS3Archive(endpoint.URL, "auto", "backups", "key", "secret-key")
+	server, err := New(config)
+	assert.NoError(t, err)
+	router := server.Router()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go server.snapshots.Run(ctx, time.Minute)
+	assert.Eventually(t, func() bool {
+		clock.Advance(time.Minute)
+		bucket.mutex.Lock()
+		defer bucket.mutex.Unlock()
+		return len(bucket.objects) >= 2
+	}, time.Second, 5*time.Millisecond)
+	cancel()
+
+	var tenantSnapshot string
+	bucket.mutex.Lock()
+	for object := range bucket.objects {
+		if name := strings.TrimPrefix(object, "/backups/"); strings.HasPrefix(name, "snapshots/tenants/lab1/world-") {
+			tenantSnapshot = name
+		}
+	}
+	bucket.mutex.Unlock()
+	assert.NotEmpty(t, tenantSnapshot, "the tenant's world is archived on its own")
+
+	restore := func(tenant string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("POST", "/admin/restore?snapshot="+tenantSnapshot, nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusConflict, restore(""), "a tenant's snapshot never replaces another world")
+	assert.Equal(t, http.StatusOK, restore("lab1"))
+}
@@ -0,0 +1,176 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Defaults of the item spawner
+const (
+	DefaultSpawnDensity = 0.05 // share of cells that may hold loose items
+	maxSpawnAttempts    = 20   // random cells tried before a spawn is skipped
+)
+
+// DefaultSpawnArea is where items spawn on maps without bounds
+var DefaultSpawnArea = Bounds{MinX: -20, MinY: -20, MaxX: 20, MaxY: 20}
+
+// SpawnType is an item type the spawner creates
+type SpawnType struct {
+	Type   string `json:"type"`
+	Weight int    `json:"weight"` // of the created items
+}
+
+// defaultSpawnTypes are the resource types with the weights of the example
+// world's items
+var defaultSpawnTypes = []SpawnType{{Type: "battery", Weight: 1}, {Type: "tool", Weight: 2}, {Type: "armor_plate", Weight: 5}}
+
+// ParseSpawnTypes reads the item types to spawn from a comma separated list
+// of "type=weight" pairs, e.g. "battery=1,tool=3". An empty list spawns the
+// resource types of generated maps.
+func ParseSpawnTypes(config string) ([]SpawnType, error) {
+	types := []SpawnType{}
+	for _, pair := range strings.Split(config, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		itemType, value, found := strings.Cut(pair, "=")
+		weight, err := strconv.Atoi(value)
+		if !found || itemType == "" || err != nil || weight < 0 {
+			return nil, errors.New("spawn types must be given as type=weight pairs")
+		}
+		types = append(types, SpawnType{Type: itemType, Weight: weight})
+	}
+	if len(types) == 0 {
+		return append([]SpawnType{}, defaultSpawnTypes...), nil
+	}
+	return types, nil
+}
+
+// SpawnerConfig configures the item spawner
+type SpawnerConfig struct {
+	Every   int64       // ticks between spawns; 0 turns the spawner off
+	Types   []SpawnType // drawn with equal chance
+	Density float64     // the spawner stops once this share of cells holds loose items
+	Area    Bounds      // used on maps without bounds
+}
+
+// ItemSpawner puts new items into the worlds over time, so long-running
+// worlds do not run out of pickups
+type ItemSpawner struct {
+	config SpawnerConfig
+	dice   *dice
+}
+
+// NewItemSpawner creates a spawner that draws from the items stream of the
+// random service
+func NewItemSpawner(config SpawnerConfig, random *RandomService) (*ItemSpawner, error) {
+	if config.Every < 0 {
+		return nil, errors.New("the spawn interval must not be negative")
+	}
+	if config.Every == 0 {
+		return &ItemSpawner{config: config}, nil
+	}
+	if config.Density <= 0 || config.Density > 1 {
+		return nil, errors.New("the spawn density must be above 0 and at most 1")
+	}
+	if config.Area.MinX > config.Area.MaxX || config.Area.MinY > config.Area.MaxY {
+		return nil, errors.New("the spawn area is empty")
+	}
+	if len(config.Types) == 0 {
+		return nil, errors.New("there are no item types to spawn")
+	}
+	return &ItemSpawner{config: config, dice: random.stream(RandomItems)}, nil
+}
+
+// Due reports whether the spawner creates items on the tick
+func (sp *ItemSpawner) Due(tick int64) bool {
+	return sp.config.Every > 0 && tick%sp.config.Every == 0
+}
+
+// Spawn creates one item of a random type on a random free cell of the
+// world, unless the world already holds as many loose items as the density
+// allows or its item limit is reached. It returns false if nothing spawned.
+func (sp *ItemSpawner) Spawn(world *RobotStorage) (Item, bool) {
+	kind := sp.config.Types[sp.dice.roll(1, len(sp.config.Types))-1]
+	return world.spawnItem(kind, sp.config.Area, sp.config.Density, sp.dice)
+}
+
+// spawnItem places an item of the type on a random cell that is within the
+// map, not blocked, not occupied and not covered by a hazard
+func (s *RobotStorage) spawnItem(kind SpawnType, area Bounds, density float64, dice *dice) (Item, bool) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if s.bounds != nil {
+		area = *s.bounds
+	}
+	if limit := s.limits.MaxItems; limit > 0 && len(s.items) >= limit {
+		return Item{}, false
+	}
+	cells := (area.MaxX - area.MinX + 1) * (area.MaxY - area.MinY + 1)
+	loose := 0
+	for _, item := range s.items {
+		if !item.carried && item.Position.Z == 0 && area.Contains(item.Position) {
+			loose++
+		}
+	}
+	if loose >= int(math.Ceil(density*float64(cells))) {
+		return Item{}, false
+	}
+
+	for attempt := 0; attempt < maxSpawnAttempts; attempt++ {
+		p := Position{
+			X: area.MinX + dice.roll(1, area.MaxX-area.MinX+1) - 1,
+			Y: area.MinY + dice.roll(1, area.MaxY-area.MinY+1) - 1,
+		}
+		if s.obstacles[p] || s.occupied(p) || s.hazardAt(p) != nil {
+			continue
+		}
+		for {
+			s.spawnedItems++
+			if _, exists := s.items[s.spawnedID()]; !exists {
+				break
+			}
+		}
+		item := &Item{ID: s.spawnedID(), Type: kind.Type, Weight: kind.Weight, Position: p}
+		s.items[item.ID] = item
+		s.reindexItem(item)
+		s.itemChanged(item.ID)
+		return *item, true
+	}
+	return Item{}, false
+}
+
+// spawnedID returns the ID of the latest spawned item; callers must hold
+// the lock
+func (s *RobotStorage) spawnedID() string {
+	return fmt.Sprintf("spawned-%d", s.spawnedItems)
+}
+
+// SetSpawner replaces the item spawner
+func (h *RobotHandler) SetSpawner(spawner *ItemSpawner) {
+	h.spawner = spawner
+}
+
+// TickSpawner spawns an item in every world when the spawner is due and
+// publishes the spawns. It is registered as a simulation system.
+func (h *RobotHandler) TickSpawner(tick int64) {
+	if h.spawner == nil || !h.spawner.Due(tick) {
+		return
+	}
+	spawn := func(world *RobotStorage, bus *EventBus) {
+		if item, spawned := h.spawner.Spawn(world); spawned {
+			bus.Publish("item_spawned", "", gin.H{"item_id": item.ID, "type": item.Type, "weight": item.Weight, "position": item.Position})
+		}
+	}
+	spawn(h.storage, h.events)
+	for _, tenant := range h.tenants.All() {
+		spawn(tenant.Storage, tenant.Events)
+	}
+}
@@ -0,0 +1,109 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSpawnTypes(t *testing.T) {
+	types, err := ParseSpawnTypes("battery=1, tool=3")
+	require.NoError(t, err)
+	assert.Equal(t, []SpawnType{{Type: "battery", Weight: 1}, {Type: "tool", Weight: 3}}, types)
+
+	types, err = ParseSpawnTypes("")
+	require.NoError(t, err)
+	assert.Equal(t, defaultSpawnTypes, types)
+
+	for _, config := range []string{"battery", "=1", "tool=heavy", "tool=-1"} {
+		_, err := ParseSpawnTypes(config)
+		assert.Error(t, err, config)
+	}
+}
+
+// setupSpawner returns a handler whose empty 10x10 world gets an item on
+// every tick until a tenth of its cells hold one
+func setupSpawner(t *testing.T, seed int64) (*RobotHandler, *EventBus) {
+	storage := NewRobotStorage()
+	require.NoError(t, storage.SetBounds(&Bounds{MaxX: 9, MaxY: 9}))
+	require.NoError(t, storage.PlaceObstacle(Position{X: 3, Y: 3}))
+	storage.AddHazard(Hazard{Type: HazardLava, Center: Position{X: 6, Y: 6}, Radius: 1, DamagePerTick: 10})
+
+	handler := NewRobotHandler(storage)
+	events := NewEventBus()
+	handler.SetEventBus(events)
+	spawner, err := NewItemSpawner(SpawnerConfig{
+		Every:   1,
+		Types:   []SpawnType{{Type: "battery", Weight: 1}, {Type: "crystal", Weight: 4}},
+		Density: 0.1,
+		Area:    DefaultSpawnArea,
+	}, NewRandomService(seed))
+	require.NoError(t, err)
+	handler.SetSpawner(spawner)
+	return handler, events
+}
+
+func TestSpawnerFillsTheWorldUpToTheDensity(t *testing.T) {
+	handler, events := setupSpawner(t, 42)
+	for tick := int64(1); tick <= 30; tick++ {
+		handler.TickSpawner(tick)
+	}
+
+	items := handler.storage.GetWorldItems()
+	require.Len(t, items, 10, "a tenth of 100 cells")
+	layout := handler.storage.GetLayout()
+	for _, item := range items {
+		assert.Contains(t, []string{"battery", "crystal"}, item.Type)
+		assert.False(t, layout.Blocked(item.Position), item.ID)
+		assert.Nil(t, handler.storage.HazardAt(item.Position), item.ID)
+	}
+
+	spawned := events.Since(0)
+	require.Len(t, spawned, 10)
+	assert.Equal(t, "item_spawned", spawned[0].Type)
+	assert.Equal(t, "spawned-1", spawned[0].Data.(gin.H)["item_id"])
+
+	// Taking an item away makes room for another one
+	handler.storage.RemoveItem("spawned-1")
+	handler.TickSpawner(31)
+	assert.Len(t, events.Since(0), 11)
+}
+
+func TestSpawnsAreListedAndRepeatable(t *testing.T) {
+	first, _ := setupSpawner(t, 7)
+	second, _ := setupSpawner(t, 7)
+	for tick := int64(1); tick <= 3; tick++ {
+		first.TickSpawner(tick)
+		second.TickSpawner(tick)
+	}
+	assert.ElementsMatch(t, first.storage.GetWorldItems(), second.storage.GetWorldItems(), "the same seed spawns the same items")
+
+	router := gin.New()
+	router.GET("/items", first.ListItems)
+	w := send(router, "GET", "/items", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var response PaginatedItems
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []string{"spawned-1", "spawned-2", "spawned-3"}, response.AvailableItems)
+}
+
+func TestSpawnerConfiguration(t *testing.T) {
+	random := NewRandomService(1)
+	spawner, err := NewItemSpawner(SpawnerConfig{}, random)
+	require.NoError(t, err, "a spawner that is off needs no settings")
+	assert.False(t, spawner.Due(1))
+
+	for name, config := range map[string]SpawnerConfig{
+		"negative interval": {Every: -1},
+		"no density":        {Every: 1, Types: defaultSpawnTypes, Area: DefaultSpawnArea},
+		"empty area":        {Every: 1, Types: defaultSpawnTypes, Density: 0.1, Area: Bounds{MinX: 1}},
+		"no types":          {Every: 1, Density: 0.1, Area: DefaultSpawnArea},
+	} {
+		_, err := NewItemSpawner(config, random)
+		assert.Error(t, err, name)
+	}
+}
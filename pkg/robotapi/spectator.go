@@ -0,0 +1,175 @@
+package robotapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultSpectatorDelay is how long events are held back from spectators
+const DefaultSpectatorDelay = 30 * time.Second
+
+// spectatorCheckInterval is how often a waiting spectator poll looks for
+// events that have become old enough to be shown
+const spectatorCheckInterval = 250 * time.Millisecond
+
+// spectatorEventData lists the event types spectators see and the fields of
+// their data they see. Events of other types are held back completely, so
+// new event types stay private until they are added here. Nothing about
+// what robots carry, how they are programmed or who controls them is shown.
+var spectatorEventData = map[string][]string{
+	"robot_created":          {},
+	"robot_moved":            {"position", "direction"},
+	"robot_turned":           {"direction"},
+	"robot_velocity_changed": {"commanded_velocity"},
+	"robot_attacked":         {"attacker", "hit", "damage", "position"},
+	"robot_decommissioned":   {},
+	"robot_archived":         {},
+	"maintenance_started":    {},
+	"maintenance_ended":      {},
+	"item_picked_up":         {},
+	"item_put_down":          {"item", "position"},
+	"item_spawned":           {"item_id", "type", "weight", "position"},
+	"battery_replaced":       {},
+	"custom_action":          {"action"},
+	"behavior_uploaded":      {},
+	"behavior_failed":        {},
+	"program_uploaded":       {},
+	"program_ran":            {},
+	ZoneEntered:              {"zone"},
+	ZoneLeft:                 {"zone"},
+	ZoneCaptured:             {"zone"},
+	"map_changed":            {"change", "position", "bounds", "spawn_points", "link", "zone"},
+	"weather_changed":        {"from", "to"},
+	"day_started":            {"day"},
+	"night_started":          {"day"},
+	"world_restored":         {},
+	"estop_engaged":          {},
+	"estop_cleared":          {},
+
+	"tournament_created":                {"tournament", "format"},
+	"tournament_participant_registered": {"tournament", "participant"},
+	"tournament_started":                {"tournament", "matches"},
+	"tournament_match_played":           {"tournament", "match", "a", "b", "winner"},
+	"tournament_finished":               {"tournament", "champion"},
+}
+
+// SetSpectatorDelay sets how long events are held back from spectators
+func (h *RobotHandler) SetSpectatorDelay(delay time.Duration) {
+	h.spectatorDelay = delay
+}
+
+// spectatorView returns an event as spectators see it: only with the data
+// fields allowed for its type and without the request that caused it. It
+// reports false for events spectators do not see at all.
+func spectatorView(event Event) (Event, bool) {
+	fields, shown := spectatorEventData[event.Type]
+	if !shown {
+		return Event{}, false
+	}
+	event.CommandID = ""
+	if event.Data == nil {
+		return event, true
+	}
+
+	// Event data has many shapes, as JSON they are all objects
+	encoded, err := json.Marshal(event.Data)
+	if err != nil {
+		event.Data = nil
+		return event, true
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal(encoded, &data); err != nil {
+		event.Data = nil
+		return event, true
+	}
+	visible := make(map[string]interface{})
+	for _, field := range fields {
+		if value, exists := data[field]; exists {
+			visible[field] = value
+		}
+	}
+	event.Data = visible
+	return event, true
+}
+
+// spectatorEvents returns the events after since that are old enough to be
+// shown to spectators, as they see them, and the sequence of the last event
+// that is old enough, shown or not
+func (h *RobotHandler) spectatorEvents(bus *EventBus, since int64) ([]Event, int64) {
+	cutoff := bus.Clock().Now().Add(-h.spectatorDelay)
+	events := []Event{}
+	next := since
+	for _, event := range bus.Since(since) {
+		if event.Timestamp.After(cutoff) {
+			break
+		}
+		next = event.Sequence
+		if view, shown := spectatorView(event); shown {
+			events = append(events, view)
+		}
+	}
+	return events, next
+}
+
+// SpectateEvents returns the events of all robots and world-wide events like
+// PollEvents does, but only once they are older than the spectator delay and
+// without private data, so an audience can follow a match without giving
+// the competitors an edge. Without since it starts at the latest event that
+// may be shown already.
+func (h *RobotHandler) SpectateEvents(c *gin.Context) {
+	bus := h.bus(c)
+
+	var since int64
+	if value := c.Query("since"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an event sequence number"})
+			return
+		}
+		since = parsed
+	} else {
+		logged := bus.Since(0)
+		since = bus.Sequence()
+		if len(logged) > 0 {
+			since = logged[0].Sequence - 1
+		}
+		_, since = h.spectatorEvents(bus, since)
+	}
+	if !bus.Retained(since) {
+		c.JSON(http.StatusGone, gin.H{"error": "Events after since are no longer available"})
+		return
+	}
+
+	wait, ok := pollWait(c)
+	if !ok {
+		return
+	}
+
+	// Events come of age as time passes, not when new ones are published
+	ctx, cancel := context.WithTimeout(c.Request.Context(), wait)
+	defer cancel()
+	ticker := bus.Clock().NewTicker(spectatorCheckInterval)
+	defer ticker.Stop()
+	events, next := h.spectatorEvents(bus, since)
+	for waiting := true; waiting && len(events) == 0; {
+		select {
+		case <-ticker.C():
+			events, next = h.spectatorEvents(bus, since)
+		case <-ctx.Done():
+			waiting = false
+		}
+	}
+
+	// Older events may have left the log while waiting
+	if !bus.Retained(since) {
+		c.JSON(http.StatusGone, gin.H{"error": "Events after since are no longer available"})
+		return
+	}
+
+	writeEvents(c, gin.H{"events": events, "next": next, "delay": h.spectatorDelay.Seconds()})
+}
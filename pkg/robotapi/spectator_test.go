@@ -0,0 +1,113 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type spectatorResponse struct {
+	Events []Event `json:"events"`
+	Next   int64   `json:"next"`
+	Delay  float64 `json:"delay"`
+}
+
+func setupSpectatorRouter() (*gin.Engine, *EventBus, *FakeClock) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	clock := NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	events := NewEventBus()
+	events.SetClock(clock)
+	handler.SetEventBus(events)
+
+	router := gin.New()
+	router.GET("/world/spectate", handler.SpectateEvents)
+	return router, events, clock
+}
+
+func spectate(t *testing.T, router *gin.Engine, query string) spectatorResponse {
+	w := send(router, "GET", "/world/spectate"+query, "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response spectatorResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestSpectatorsSeeEventsAfterTheDelay(t *testing.T) {
+	router, events, clock := setupSpectatorRouter()
+	events.PublishCommand("cmd-1", "item_picked_up", "robot1", gin.H{"item": "battery", "inventory": []string{"battery"}})
+	events.Publish("robot_moved", "robot2", gin.H{"position": Position{X: 1}, "direction": "right"})
+
+	response := spectate(t, router, "?since=0&wait=0")
+	assert.Empty(t, response.Events, "the events are too recent")
+	assert.Equal(t, int64(0), response.Next)
+	assert.Equal(t, DefaultSpectatorDelay.Seconds(), response.Delay)
+
+	clock.Advance(DefaultSpectatorDelay)
+	events.Publish("weather_changed", "", map[string]string{"weather": "rain"})
+	response = spectate(t, router, "?since=0&wait=0")
+	require.Len(t, response.Events, 2, "events of all robots, but not the new one")
+	assert.Equal(t, int64(2), response.Next)
+
+	pickup := response.Events[0]
+	assert.Equal(t, "item_picked_up", pickup.Type)
+	assert.Equal(t, "robot1", pickup.RobotID)
+	assert.Empty(t, pickup.CommandID)
+	assert.Empty(t, pickup.Data, "what the robot carries is private")
+	assert.Equal(t, "right", response.Events[1].Data.(map[string]interface{})["direction"])
+
+	// Without since spectators start at the latest event already shown
+	assert.Empty(t, spectate(t, router, "?wait=0").Events)
+	assert.Equal(t, int64(2), spectate(t, router, "?wait=0").Next)
+}
+
+func TestSpectatorPollWaitsForEventsToComeOfAge(t *testing.T) {
+	router, events, clock := setupSpectatorRouter()
+	events.Publish("robot_moved", "robot1", nil)
+
+	go func() {
+		// Once the poll waits, the event comes of age on its next check
+		assert.Eventually(t, func() bool { return clock.Tickers() == 1 }, time.Second, time.Millisecond)
+		clock.Advance(DefaultSpectatorDelay)
+	}()
+	response := spectate(t, router, "?since=0&wait=5")
+	assert.Len(t, response.Events, 1)
+	assert.Equal(t, 0, clock.Tickers(), "the poll stops its ticker")
+}
+
+func TestSpectatorErrors(t *testing.T) {
+	router, events, _ := setupSpectatorRouter()
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/world/spectate?since=x", "").Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "GET", "/world/spectate?wait=600", "").Code)
+
+	for i := 0; i < maxEventLog+5; i++ {
+		events.Publish("robot_moved", "robot1", nil)
+	}
+	assert.Equal(t, http.StatusGone, send(router, "GET", "/world/spectate?since=1&wait=0", "").Code)
+}
+
+func TestSpectatorsSeeOnlyAllowedEvents(t *testing.T) {
+	router, events, clock := setupSpectatorRouter()
+	events.Publish("random_seeded", "", gin.H{"seed": 42})
+	events.PublishCommand("cmd-1", "device_registration_requested", "", gin.H{"registration": "reg-1", "hardware_id": "hw-1"})
+	events.PublishCommand("cmd-2", "ownership_transfer_offered", "robot1", gin.H{"transfer": "t-1", "to": "bob"})
+	events.PublishCommand("cmd-3", "robot_created", "robot3", gin.H{"registration": "reg-1", "hardware_id": "hw-1", "owner": "alice"})
+	events.Publish("robot_moved", "robot1", gin.H{"position": Position{X: 1}, "direction": "right", "secret": "x"})
+	events.Publish("something_new", "robot1", gin.H{"plan": "win"})
+	clock.Advance(DefaultSpectatorDelay)
+
+	response := spectate(t, router, "?since=0&wait=0")
+	require.Len(t, response.Events, 2, "event types spectators may see are listed, others are held back")
+	assert.Equal(t, "robot_created", response.Events[0].Type)
+	assert.Empty(t, response.Events[0].Data, "how a robot was registered is private")
+	assert.Equal(t, map[string]interface{}{"position": map[string]interface{}{"x": float64(1), "y": float64(0)}, "direction": "right"},
+		response.Events[1].Data, "only the allowed fields are shown")
+	assert.Equal(t, int64(6), response.Next, "held back events are skipped, not waited for")
+}
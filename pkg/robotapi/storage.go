@@ -0,0 +1,661 @@
+package robotapi
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrRobotNotFound is returned for unknown robot IDs
+var ErrRobotNotFound = errors.New("robot not found")
+
+// ErrItemNotFound is returned when an item is unknown or not where it is
+// expected
+var ErrItemNotFound = errors.New("item not found")
+
+// RobotStorage provides in-memory storage for robots
+type RobotStorage struct {
+	robots  map[string]*Robot
+	items   map[string]*Item // all known items, including carried ones
+	hazards []Hazard
+	battery DegradationCurve
+
+	bounds      *Bounds // nil for an unlimited map
+	wrap        bool    // a bounded map is a torus
+	obstacles   map[Position]bool
+	floorLinks  map[Position]string // lower end -> link type
+	spawnPoints []Position
+	nextSpawn   int
+	zones       map[string]Zone
+	zoneStates  map[string]*zoneState
+	grants      map[string]map[string][]string // robot ID -> grantee -> permissions
+	mutex       sync.RWMutex
+
+	index          *SearchIndex
+	indexedActions map[string]int // number of actions per robot already in the index
+
+	version        uint64            // incremented on every change of robots, items or hazards
+	robotVersions  map[string]uint64 // robot ID -> version of its last change
+	itemVersions   map[string]uint64 // item ID -> version of its last change
+	hazardsVersion uint64            // version of the last hazard change
+	layoutVersion  uint64            // version of the last map layout change
+
+	reserved map[string]bool // robots whose device is carrying out a command
+
+	quotaDay   string                    // UTC day the quota counters belong to
+	quotaUsage map[string]map[string]int // robot ID -> action -> count today
+
+	limits       TenantLimits
+	spawnedItems int // number in the ID of the latest spawned item
+
+	energy *EnergyLedger
+	clock  Clock
+
+	invariantReport invariantReport // nil unless invariant checks are on
+	checkedVersion  uint64          // version the invariants were last checked at
+}
+
+// NewRobotStorage creates a new instance of RobotStorage
+func NewRobotStorage() *RobotStorage {
+	return &RobotStorage{
+		robots: make(map[string]*Robot),
+		items:  make(map[string]*Item),
+		grants: make(map[string]map[string][]string),
+
+		battery: NoDegradation{},
+
+		obstacles:  make(map[Position]bool),
+		floorLinks: make(map[Position]string),
+
+		index:          NewSearchIndex(),
+		indexedActions: make(map[string]int),
+
+		robotVersions: make(map[string]uint64),
+		itemVersions:  make(map[string]uint64),
+		reserved:      make(map[string]bool),
+
+		quotaUsage: make(map[string]map[string]int),
+
+		energy: NewEnergyLedger(),
+		clock:  SystemClock{},
+	}
+}
+
+// SetClock replaces the clock of the world's quotas, snapshots and energy
+// ledger
+func (s *RobotStorage) SetClock(clock Clock) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	s.clock = clock
+	s.energy.setClock(clock)
+}
+
+// Clock returns the clock of the world
+func (s *RobotStorage) Clock() Clock {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.clock
+}
+
+// GetRobot retrieves a copy of a robot by ID. Changes to the copy only take
+// effect through SaveRobot or UpdateRobot.
+func (s *RobotStorage) GetRobot(id string) (*Robot, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	robot, exists := s.robots[id]
+	if !exists {
+		return nil, ErrRobotNotFound
+	}
+	return robot.clone(), nil
+}
+
+// GetAllRobots returns copies of all robots ordered by ID
+func (s *RobotStorage) GetAllRobots() []*Robot {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	robots := make([]*Robot, 0, len(s.robots))
+	for _, robot := range s.robots {
+		robots = append(robots, robot.clone())
+	}
+	sort.Slice(robots, func(i, j int) bool {
+		return robots[i].ID < robots[j].ID
+	})
+	return robots
+}
+
+// RobotIDs returns the IDs of all robots in order
+func (s *RobotStorage) RobotIDs() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.sortedRobotIDs()
+}
+
+// sortedRobotIDs lists all robot IDs in order; callers must hold the lock
+func (s *RobotStorage) sortedRobotIDs() []string {
+	ids := make([]string, 0, len(s.robots))
+	for id := range s.robots {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// CreateRobot adds a new robot, failing if the ID is already taken
+func (s *RobotStorage) CreateRobot(robot *Robot) error {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if _, exists := s.robots[robot.ID]; exists {
+		return errors.New("robot already exists")
+	}
+	if s.limits.MaxRobots > 0 && len(s.robots) >= s.limits.MaxRobots {
+		return &LimitError{Resource: "robots", Limit: s.limits.MaxRobots, Used: len(s.robots)}
+	}
+	s.robots[robot.ID] = robot.clone()
+	s.reindexRobot(robot)
+	s.robotChanged(robot.ID)
+	return nil
+}
+
+// RemoveRobot takes a robot out of the world together with its grants and
+// quota counters, e.g. when it moves to another tenant. The removed robot is
+// returned as a copy.
+func (s *RobotStorage) RemoveRobot(id string) (*Robot, error) {
+	return s.RemoveRobotIf(id, nil)
+}
+
+// RemoveRobotIf removes a robot like RemoveRobot, but only if check, which
+// runs under the storage lock, allows it. A nil check always allows it.
+func (s *RobotStorage) RemoveRobotIf(id string, check func(robot *Robot) error) (*Robot, error) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	robot, exists := s.robots[id]
+	if !exists {
+		return nil, ErrRobotNotFound
+	}
+	if check != nil {
+		if err := check(robot.clone()); err != nil {
+			return nil, err
+		}
+	}
+	delete(s.robots, id)
+	delete(s.grants, id)
+	delete(s.quotaUsage, id)
+	s.index.Remove(searchDoc{kind: searchRobot, id: id})
+	for i := 0; i < s.indexedActions[id]; i++ {
+		s.index.Remove(searchDoc{kind: searchAction, id: id, action: i + 1})
+	}
+	delete(s.indexedActions, id)
+	s.robotChanged(id)
+	return robot.clone(), nil
+}
+
+// SaveRobot saves a copy of a robot to storage, replacing the stored robot.
+// Handlers should prefer UpdateRobot, which does not overwrite changes the
+// simulation made in the meantime.
+func (s *RobotStorage) SaveRobot(robot *Robot) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	s.robots[robot.ID] = robot.clone()
+	s.reindexRobot(robot)
+	s.robotChanged(robot.ID)
+}
+
+// UpdateRobot changes a robot atomically. The update runs under the storage
+// lock on a copy; if it returns an error nothing is changed. Energy the
+// update adds wears the battery. The updated robot is returned as a copy.
+func (s *RobotStorage) UpdateRobot(id string, update func(robot *Robot) error) (*Robot, error) {
+	robots, err := s.UpdateRobots([]string{id}, func(robots []*Robot) error {
+		return update(robots[0])
+	})
+	if err != nil {
+		return nil, err
+	}
+	return robots[0], nil
+}
+
+// UpdateRobots changes several robots in one step, e.g. attacker and
+// target of an attack: either all changes are applied or, if the update
+// returns an error, none. A robot listed twice is passed as the same copy.
+func (s *RobotStorage) UpdateRobots(ids []string, update func(robots []*Robot) error) ([]*Robot, error) {
+	s.mutex.Lock()
+	defer s.unlock()
+	return s.updateRobots(ids, update)
+}
+
+// updateRobots is UpdateRobots; callers must hold the lock
+func (s *RobotStorage) updateRobots(ids []string, update func(robots []*Robot) error) ([]*Robot, error) {
+	copies := make(map[string]*Robot, len(ids))
+	robots := make([]*Robot, len(ids))
+	for i, id := range ids {
+		if _, seen := copies[id]; !seen {
+			stored, exists := s.robots[id]
+			if !exists {
+				return nil, ErrRobotNotFound
+			}
+			copies[id] = stored.clone()
+		}
+		robots[i] = copies[id]
+	}
+
+	if err := update(robots); err != nil {
+		return nil, err
+	}
+	now := s.clock.Now()
+	for id, robot := range copies {
+		stored := s.robots[id]
+		stampActions(robot, len(stored.Actions), now)
+		if robot.Energy > stored.Energy {
+			s.charged(robot, robot.Energy-stored.Energy)
+		}
+		if source := energySource(stored, robot); source != EnergyOther || robot.Energy != stored.Energy {
+			s.energy.Record(id, source, robot.Energy-stored.Energy) // free actions count, too
+		}
+		s.robots[id] = robot
+		s.reindexRobot(robot)
+		s.robotChanged(id)
+	}
+
+	updated := make([]*Robot, len(robots))
+	for i, robot := range robots {
+		updated[i] = robot.clone()
+	}
+	return updated, nil
+}
+
+// AddAction adds an action to a robot's history
+func (s *RobotStorage) AddAction(robotID, actionType, details string) error {
+	return s.AddCommandAction(robotID, ActionOrigin{}, actionType, details, nil)
+}
+
+// AddCommandAction adds an action caused by the request of the given origin
+// to a robot's history
+func (s *RobotStorage) AddCommandAction(robotID string, origin ActionOrigin, actionType, details string, data interface{}) error {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	robot, exists := s.robots[robotID]
+	if !exists {
+		return ErrRobotNotFound
+	}
+
+	appendCommandAction(robot, origin, actionType, details, data)
+	stampActions(robot, len(robot.Actions)-1, s.clock.Now())
+	s.reindexRobot(robot)
+	s.robotChanged(robotID)
+	return nil
+}
+
+// Version returns a counter that changes whenever robots, items or hazards
+// change. Caches use it to detect stale entries.
+func (s *RobotStorage) Version() uint64 {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.version
+}
+
+// changed marks the world state as modified; callers must hold the lock
+func (s *RobotStorage) changed() {
+	s.version++
+}
+
+// robotChanged marks a robot as modified; callers must hold the lock
+func (s *RobotStorage) robotChanged(id string) {
+	s.changed()
+	s.robotVersions[id] = s.version
+}
+
+// itemChanged marks an item as modified; callers must hold the lock
+func (s *RobotStorage) itemChanged(id string) {
+	s.changed()
+	s.itemVersions[id] = s.version
+}
+
+// hazardsChanged marks the hazards as modified; callers must hold the lock
+func (s *RobotStorage) hazardsChanged() {
+	s.changed()
+	s.hazardsVersion = s.version
+}
+
+// appendAction records an action on a robot; callers must hold the lock
+func appendAction(robot *Robot, actionType, details string, data interface{}) {
+	appendCommandAction(robot, ActionOrigin{}, actionType, details, data)
+}
+
+// appendCommandAction records an action caused by a request on a robot;
+// callers must hold the lock
+func appendCommandAction(robot *Robot, origin ActionOrigin, actionType, details string, data interface{}) {
+	robot.Actions = append(robot.Actions, Action{
+		Type:      actionType,
+		Timestamp: time.Now(),
+		Details:   details,
+		Data:      data,
+		CommandID: origin.CommandID,
+		Actor:     origin.Actor,
+		ClientIP:  origin.ClientIP,
+		UserAgent: origin.UserAgent,
+	})
+}
+
+// stampActions dates the actions of a robot from index on with the time of
+// the world's clock
+func stampActions(robot *Robot, from int, now time.Time) {
+	for i := from; i < len(robot.Actions); i++ {
+		robot.Actions[i].Timestamp = now
+	}
+}
+
+// Recharge adds energy to every robot according to the given rate, up to
+// the capacity of its battery
+func (s *RobotStorage) Recharge(rate func(robot *Robot) int) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	for _, robot := range s.robots {
+		if robot.Energy >= robot.maxEnergy() {
+			continue
+		}
+		before := robot.Energy
+		energy := min(rate(robot), robot.maxEnergy()-robot.Energy)
+		robot.Energy += energy
+		s.charged(robot, energy)
+		s.bookEnergy(robot.ID, EnergyCharging, before, robot.Energy)
+		s.robotChanged(robot.ID)
+	}
+}
+
+// ItemExists checks if an item exists in the world
+func (s *RobotStorage) ItemExists(itemID string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	item, exists := s.items[itemID]
+	return exists && !item.carried
+}
+
+// GetItem retrieves an item by ID, whether it lies in the world or is carried
+func (s *RobotStorage) GetItem(itemID string) (Item, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	item, exists := s.items[itemID]
+	if !exists {
+		return Item{}, ErrItemNotFound
+	}
+	return *item, nil
+}
+
+// AddItem adds an item to the world. Unknown items are created as
+// miscellaneous items weighing 1.
+func (s *RobotStorage) AddItem(itemID string) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	defer s.itemChanged(itemID)
+
+	if item, exists := s.items[itemID]; exists {
+		item.carried = false
+		return
+	}
+	item := &Item{ID: itemID, Type: "misc", Weight: 1}
+	s.items[itemID] = item
+	s.reindexItem(item)
+}
+
+// PlaceItem puts an item into the world at the given position
+func (s *RobotStorage) PlaceItem(itemID string, position Position) {
+	s.mutex.Lock()
+	defer s.unlock()
+	s.placeItem(itemID, position)
+}
+
+// placeItem is PlaceItem; callers must hold the lock
+func (s *RobotStorage) placeItem(itemID string, position Position) {
+	item, exists := s.items[itemID]
+	if !exists {
+		item = &Item{ID: itemID, Type: "misc", Weight: 1}
+		s.items[itemID] = item
+		s.reindexItem(item)
+	}
+	item.Position = position
+	item.carried = false
+	s.itemChanged(itemID)
+}
+
+// SaveItem stores an item including its metadata in the world
+func (s *RobotStorage) SaveItem(item Item) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	s.items[item.ID] = &item
+	s.reindexItem(&item)
+	s.itemChanged(item.ID)
+}
+
+// RemoveItem removes an item from the world. The item stays known so its
+// metadata survives while it is carried.
+func (s *RobotStorage) RemoveItem(itemID string) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if item, exists := s.items[itemID]; exists {
+		item.carried = true
+		s.itemChanged(itemID)
+	}
+}
+
+// DeleteItem removes an item for good, for example when it is used up
+func (s *RobotStorage) DeleteItem(itemID string) {
+	s.mutex.Lock()
+	defer s.unlock()
+	s.deleteItem(itemID)
+}
+
+// deleteItem is DeleteItem; callers must hold the lock
+func (s *RobotStorage) deleteItem(itemID string) {
+	if _, exists := s.items[itemID]; exists {
+		delete(s.items, itemID)
+		s.index.Remove(searchDoc{kind: searchItem, id: itemID})
+		s.itemChanged(itemID)
+	}
+}
+
+// PickupItem updates a robot that picks up an item and takes the item out
+// of the world in the same step, so two robots cannot pick up the same
+// item. It fails with ErrItemNotFound unless the item lies in the world.
+func (s *RobotStorage) PickupItem(robotID, itemID string, update func(robot *Robot) error) (*Robot, error) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	item, exists := s.items[itemID]
+	if !exists || item.carried {
+		return nil, ErrItemNotFound
+	}
+	robots, err := s.updateRobots([]string{robotID}, func(robots []*Robot) error {
+		return update(robots[0])
+	})
+	if err != nil {
+		return nil, err
+	}
+	item.carried = true
+	s.itemChanged(itemID)
+	return robots[0], nil
+}
+
+// PutdownItem updates a robot that puts down an item and places the item
+// at the robot's new position in the same step
+func (s *RobotStorage) PutdownItem(robotID, itemID string, update func(robot *Robot) error) (*Robot, error) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	robots, err := s.updateRobots([]string{robotID}, func(robots []*Robot) error {
+		return update(robots[0])
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.placeItem(itemID, robots[0].Position)
+	return robots[0], nil
+}
+
+// ConsumeItem updates a robot that uses up an item from its inventory and
+// deletes the item in the same step
+func (s *RobotStorage) ConsumeItem(robotID, itemID string, update func(robot *Robot) error) (*Robot, error) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	robots, err := s.updateRobots([]string{robotID}, func(robots []*Robot) error {
+		return update(robots[0])
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.deleteItem(itemID)
+	return robots[0], nil
+}
+
+// GetAvailableItems returns a list of all available items in the world
+func (s *RobotStorage) GetAvailableItems() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var items []string
+	for itemID, item := range s.items {
+		if !item.carried {
+			items = append(items, itemID)
+		}
+	}
+	sort.Strings(items)
+	return items
+}
+
+// GetWorldItems returns copies of all items lying in the world
+func (s *RobotStorage) GetWorldItems() []Item {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	items := []Item{}
+	for _, item := range s.items {
+		if !item.carried {
+			items = append(items, *item)
+		}
+	}
+	return items
+}
+
+// ItemCount returns the number of items, including carried ones
+func (s *RobotStorage) ItemCount() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.items)
+}
+
+// Initialize storage with some example data
+func (s *RobotStorage) Initialize() {
+	now := s.clock.Now()
+
+	// Create some example robots
+	robot1 := &Robot{
+		ID:        "robot1",
+		Position:  Position{X: 0, Y: 0},
+		Direction: "north",
+		Class:     ClassStandard,
+		Energy:    100,
+		Inventory: []string{}, // Start with empty inventory for testing
+		Actions: []Action{
+			{
+				Type:      "create",
+				Timestamp: now.Add(-24 * time.Hour),
+				Details:   "Robot was created",
+			},
+			{
+				Type:      "move",
+				Timestamp: now.Add(-12 * time.Hour),
+				Details:   "Moved north",
+			},
+			{
+				Type:      "pickup",
+				Timestamp: now.Add(-6 * time.Hour),
+				Details:   "Picked up item1",
+			},
+			{
+				Type:      "putdown",
+				Timestamp: now.Add(-3 * time.Hour),
+				Details:   "Put down item1",
+			},
+			{
+				Type:      "update",
+				Timestamp: now.Add(-1 * time.Hour),
+				Details:   "Updated energy to 100",
+			},
+			{
+				Type:      "move",
+				Timestamp: now.Add(-30 * time.Minute),
+				Details:   "Moved east",
+			},
+			{
+				Type:      "attack",
+				Timestamp: now.Add(-15 * time.Minute),
+				Details:   "Attacked robot2",
+			},
+		},
+	}
+
+	robot2 := &Robot{
+		ID:        "robot2",
+		Position:  Position{X: 10, Y: 10},
+		Direction: "south",
+		Class:     ClassSolar,
+		Energy:    100,
+		Inventory: []string{},
+		Actions: []Action{
+			{
+				Type:      "create",
+				Timestamp: now.Add(-24 * time.Hour),
+				Details:   "Robot was created",
+			},
+			{
+				Type:      "move",
+				Timestamp: now.Add(-10 * time.Hour),
+				Details:   "Moved south",
+			},
+			{
+				Type:      "damaged",
+				Timestamp: now.Add(-15 * time.Minute),
+				Details:   "Damaged by robot1",
+			},
+		},
+	}
+
+	// Initialize items - always ensure these are available for testing
+	s.items["item1"] = &Item{ID: "item1", Type: "battery", Weight: 1, Position: Position{X: 1, Y: 1}}
+	s.items["item2"] = &Item{ID: "item2", Type: "tool", Weight: 3, Position: Position{X: 2, Y: 0}}
+	s.items["item3"] = &Item{ID: "item3", Type: "tool", Weight: 2, Position: Position{X: -3, Y: 4}}
+	s.items["item4"] = &Item{ID: "item4", Type: "armor_plate", Weight: 5, Position: Position{X: 8, Y: 9}} // Additional item for testing
+	s.items["item5"] = &Item{ID: "item5", Type: "battery", Weight: 1, Position: Position{X: 10, Y: 12}}   // Additional item for testing
+
+	// Hazards on the map
+	s.hazards = []Hazard{
+		{Type: HazardLava, Center: Position{X: 5, Y: 5}, Radius: 0, DamagePerTick: 10},
+		{Type: HazardRadiation, Center: Position{X: -5, Y: 5}, Radius: 2, DamagePerTick: 3},
+	}
+
+	s.robots["robot1"] = robot1
+	s.robots["robot2"] = robot2
+
+	for _, robot := range s.robots {
+		s.reindexRobot(robot)
+		s.robotChanged(robot.ID)
+	}
+	for _, item := range s.items {
+		s.reindexItem(item)
+		s.itemChanged(item.ID)
+	}
+	s.hazardsChanged()
+}
@@ -0,0 +1,288 @@
+package robotapi
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StorageMigrationTask is the task type of storage migrations
+const StorageMigrationTask = "storage_migration"
+
+// maxMigrationPasses caps how often a world that changed during its copy is
+// copied again
+const maxMigrationPasses = 3
+
+// StorageMigrationRequest names the SQL database the world is copied to
+type StorageMigrationRequest struct {
+	Driver string `json:"driver" binding:"required"`
+	URL    string `json:"url" binding:"required"`
+}
+
+// StorageMigration reports the progress of a storage migration
+type StorageMigration struct {
+	Tenant   string `json:"tenant,omitempty"`
+	Driver   string `json:"driver"`
+	Pass     int    `json:"pass"` // copies made so far, including the running one
+	Robots   int    `json:"robots"`
+	Items    int    `json:"items"`
+	Actions  int    `json:"actions"`
+	Rows     int    `json:"rows"`     // rows written by the running pass
+	Total    int    `json:"total"`    // rows the running pass writes
+	Version  uint64 `json:"version"`  // world version copied
+	Behind   uint64 `json:"behind"`   // changes made to the world after the last copy
+	Verified bool   `json:"verified"` // the consistency check passed
+}
+
+// copyWorld copies the world to the migrator's database. While the API keeps
+// serving, each pass copies a consistent snapshot in one transaction; if the
+// world changed in the meantime it is copied again, up to maxMigrationPasses
+// times. The database is then checked against the last copy. Report is
+// called whenever the migration progressed.
+func (m *Migrator) copyWorld(ctx context.Context, storage *RobotStorage, migration StorageMigration, report func(StorageMigration, float64)) (StorageMigration, error) {
+	var snapshot WorldSnapshot
+	for pass := 1; pass <= maxMigrationPasses; pass++ {
+		snapshot, migration.Version = storage.VersionedSnapshot()
+		migration.Pass = pass
+		migration.Robots = len(snapshot.Robots)
+		migration.Items = len(snapshot.Items)
+		migration.Actions = 0
+		for _, robot := range snapshot.Robots {
+			migration.Actions += len(robot.Actions)
+		}
+		migration.Rows = 0
+		migration.Total = worldRows(snapshot)
+
+		err := inTransaction(ctx, m.db, func(tx *sql.Tx) error {
+			return m.insertWorld(ctx, tx, snapshot, func() {
+				migration.Rows++
+				// The first pass is most of the work, the check and later
+				// passes the rest
+				progress := 0.9
+				if pass == 1 {
+					progress *= float64(migration.Rows) / float64(migration.Total)
+				}
+				report(migration, progress)
+			})
+		})
+		if err != nil {
+			return migration, fmt.Errorf("pass %d: %w", pass, err)
+		}
+		if storage.Version() == migration.Version {
+			break
+		}
+	}
+
+	migration.Behind = storage.Version() - migration.Version
+	if err := m.VerifyWorld(ctx, snapshot); err != nil {
+		return migration, err
+	}
+	migration.Verified = true
+	return migration, nil
+}
+
+// worldRows counts the rows insertWorld writes for a snapshot
+func worldRows(snapshot WorldSnapshot) int {
+	rows := len(snapshot.Robots) + len(snapshot.Items) + len(snapshot.Hazards) + 1 // the map layout
+	for _, robot := range snapshot.Robots {
+		rows += len(robot.Actions)
+	}
+	for _, grantees := range snapshot.Grants {
+		for _, permissions := range grantees {
+			rows += len(permissions)
+		}
+	}
+	return rows
+}
+
+// VerifyWorld checks that the database holds the robots and items of the
+// snapshot, and as many actions, hazards and grants
+func (m *Migrator) VerifyWorld(ctx context.Context, snapshot WorldSnapshot) error {
+	robots := make([]string, 0, len(snapshot.Robots))
+	actions := 0
+	for _, robot := range snapshot.Robots {
+		robots = append(robots, robot.ID)
+		actions += len(robot.Actions)
+	}
+	items := make([]string, 0, len(snapshot.Items))
+	for _, item := range snapshot.Items {
+		items = append(items, item.ID)
+	}
+	grants := 0
+	for _, grantees := range snapshot.Grants {
+		for _, permissions := range grantees {
+			grants += len(permissions)
+		}
+	}
+
+	problems := []string{}
+	for _, table := range []struct {
+		name string
+		ids  []string
+	}{{"robots", robots}, {"items", items}} {
+		stored, err := m.queryIDs(ctx, "SELECT id FROM "+table.name)
+		if err != nil {
+			return err
+		}
+		missing, unexpected := compareIDs(table.ids, stored)
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("%s missing: %s", table.name, strings.Join(missing, ", ")))
+		}
+		if len(unexpected) > 0 {
+			problems = append(problems, fmt.Sprintf("%s unexpected: %s", table.name, strings.Join(unexpected, ", ")))
+		}
+	}
+	for _, table := range []struct {
+		name string
+		rows int
+	}{{"robot_actions", actions}, {"hazards", len(snapshot.Hazards)}, {"grants", grants}} {
+		var count int
+		if err := m.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM "+table.name).Scan(&count); err != nil {
+			return err
+		}
+		if count != table.rows {
+			problems = append(problems, fmt.Sprintf("%s has %d rows instead of %d", table.name, count, table.rows))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("consistency check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// queryIDs returns the strings of a single-column query
+func (m *Migrator) queryIDs(ctx context.Context, query string) ([]string, error) {
+	rows, err := m.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// compareIDs returns the sorted IDs that are expected but not stored, and
+// stored but not expected
+func compareIDs(expected, stored []string) (missing, unexpected []string) {
+	found := make(map[string]bool, len(stored))
+	for _, id := range stored {
+		found[id] = true
+	}
+	for _, id := range expected {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+		delete(found, id)
+	}
+	for id := range found {
+		unexpected = append(unexpected, id)
+	}
+	sort.Strings(missing)
+	sort.Strings(unexpected)
+	return missing, unexpected
+}
+
+// MigrateStorage starts copying the world of the request's tenant, with the
+// robots' action histories, to a SQL database. The database schema is
+// migrated first. The copy runs as a task while the API keeps serving; only
+// one migration runs at a time. The world stays in memory: nothing reads the
+// copy, so this only prepares and verifies a database.
+func (h *RobotHandler) MigrateStorage(c *gin.Context) {
+	var request StorageMigrationRequest
+	if err := bindJSON(c, &request); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	db, err := sql.Open(request.Driver, request.URL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	migrator, err := NewMigrator(db, request.Driver)
+	if err != nil {
+		db.Close()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	migrator.SetClock(h.world(c).Clock())
+	if !h.migrating.TryLock() {
+		db.Close()
+		c.JSON(http.StatusConflict, gin.H{"error": "A storage migration is already running"})
+		return
+	}
+
+	task := h.tasks.CreateFor(currentTenant(c), StorageMigrationTask, PriorityLow)
+	migration := StorageMigration{Tenant: currentTenant(c), Driver: request.Driver}
+	go h.migrateStorage(task.ID, migrator, h.world(c), h.bus(c), migration)
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"task":  task,
+		"links": []Link{{Rel: "task", Href: fmt.Sprintf("%s/admin/tasks/%s", requestBaseURL(c), task.ID)}},
+	})
+}
+
+// migrateStorage runs a storage migration and records it in its task
+func (h *RobotHandler) migrateStorage(taskID string, migrator *Migrator, storage *RobotStorage, events *EventBus, migration StorageMigration) {
+	defer h.migrating.Unlock()
+	defer migrator.db.Close()
+
+	report := func(migration StorageMigration, progress float64) {
+		h.tasks.Update(taskID, func(task *Task) {
+			task.Status = TaskRunning
+			task.Progress = progress
+			task.Result = migration
+		})
+	}
+	report(migration, 0)
+
+	ctx := h.tasks.Context(taskID)
+	err := migrator.db.PingContext(ctx)
+	if err == nil {
+		_, err = migrator.Up(ctx)
+	}
+	if err == nil {
+		migration, err = migrator.copyWorld(ctx, storage, migration, report)
+	}
+
+	if err != nil && ctx.Err() != nil {
+		log.Printf("Storage migration %s cancelled after %d of %d rows", taskID, migration.Rows, migration.Total)
+		h.tasks.Update(taskID, func(task *Task) {
+			task.Status = TaskCancelled
+			task.Error = "cancelled"
+			task.Result = migration
+		})
+		events.Publish("storage_migration_cancelled", "", gin.H{"task": taskID, "driver": migration.Driver})
+		return
+	}
+	if err != nil {
+		log.Printf("Storage migration %s failed: %v", taskID, err)
+		h.tasks.Update(taskID, func(task *Task) {
+			task.Status = TaskFailed
+			task.Error = err.Error()
+			task.Result = migration
+		})
+		events.Publish("storage_migration_failed", "", gin.H{"task": taskID, "error": err.Error()})
+		return
+	}
+	log.Printf("Storage migration %s copied %d robots and %d items in %d passes", taskID, migration.Robots, migration.Items, migration.Pass)
+	h.tasks.Update(taskID, func(task *Task) {
+		task.Status = TaskSucceeded
+		task.Progress = 1
+		task.Result = migration
+	})
+	events.Publish("storage_migration_completed", "", gin.H{"task": taskID, "driver": migration.Driver, "behind": migration.Behind})
+}
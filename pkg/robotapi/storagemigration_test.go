@@ -0,0 +1,137 @@
+package robotapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupMigrationServer(t *testing.T) *Server {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.AdminToken = "secret"
+	server, err := New(config)
+	require.NoError(t, err)
+	return server
+}
+
+// migrateStorage starts a migration to the test's fake database and waits
+// for its task to finish
+func migrateStorage(t *testing.T, router *gin.Engine) Task {
+	w := adminRequest(router, "POST", "/admin/migrate-storage", `{"driver": "fakesql", "url": "`+t.Name()+`"}`)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var response struct {
+		Task Task `json:"task"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, StorageMigrationTask, response.Task.Type)
+
+	var task Task
+	require.Eventually(t, func() bool {
+		w := adminRequest(router, "GET", "/admin/tasks/"+response.Task.ID, "")
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+		return task.Done()
+	}, 5*time.Second, 10*time.Millisecond)
+	return task
+}
+
+func TestMigrateStorage(t *testing.T) {
+	_, database := fakeDB(t)
+	server := setupMigrationServer(t)
+	router := server.Router()
+	require.Equal(t, http.StatusOK, adminRequest(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+
+	task := migrateStorage(t, router)
+	require.Equal(t, TaskSucceeded, task.Status, task.Error)
+	assert.Equal(t, 1.0, task.Progress)
+	result := task.Result.(map[string]interface{})
+	assert.Equal(t, true, result["verified"])
+	assert.Equal(t, 1.0, result["pass"])
+	assert.Equal(t, 2.0, result["robots"])
+	assert.Equal(t, 5.0, result["items"])
+	assert.Equal(t, result["total"], result["rows"])
+
+	assert.Equal(t, []int64{1, 2, 3, 4}, database.versions)
+	assert.ElementsMatch(t, []string{"robot1", "robot2"}, database.tables["robots"])
+	assert.Len(t, database.tables["items"], 5)
+	assert.Len(t, database.tables["robot_actions"], int(result["actions"].(float64)))
+
+	// Migrating again replaces the copy
+	task = migrateStorage(t, router)
+	require.Equal(t, TaskSucceeded, task.Status, task.Error)
+	assert.Len(t, database.tables["items"], 5)
+}
+
+func TestMigrateStorageChecksTheCopy(t *testing.T) {
+	_, database := fakeDB(t)
+	database.lose = "item3"
+	router := setupMigrationServer(t).Router()
+
+	task := migrateStorage(t, router)
+	assert.Equal(t, TaskFailed, task.Status)
+	assert.Equal(t, "consistency check failed: items missing: item3", task.Error)
+
+	// A failed copy is rolled back
+	database.lose = ""
+	database.failOn = "INSERT INTO items"
+	task = migrateStorage(t, router)
+	assert.Equal(t, TaskFailed, task.Status)
+	assert.Contains(t, task.Error, "pass 1: item item1")
+	assert.Len(t, database.executed("INSERT INTO robots"), 2)
+}
+
+func TestMigrateStorageCopiesChangesAgain(t *testing.T) {
+	db, database := fakeDB(t)
+	migrator, err := NewMigrator(db, "fakesql")
+	require.NoError(t, err)
+	_, err = migrator.Up(context.Background())
+	require.NoError(t, err)
+	storage := NewRobotStorage()
+	storage.Initialize()
+
+	// The world changes during the first pass only
+	changed := false
+	migration, err := migrator.copyWorld(context.Background(), storage, StorageMigration{}, func(StorageMigration, float64) {
+		if !changed {
+			changed = true
+			storage.PlaceItem("item1", Position{X: 3, Y: 3})
+		}
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 2, migration.Pass)
+	assert.Equal(t, uint64(0), migration.Behind)
+	assert.True(t, migration.Verified)
+	assert.Len(t, database.executed("INSERT INTO robots"), 4)
+
+	// A world that never stands still is copied as it was at the last pass
+	migration, err = migrator.copyWorld(context.Background(), storage, StorageMigration{}, func(migration StorageMigration, _ float64) {
+		if migration.Rows == 1 {
+			storage.PlaceItem("item1", Position{X: migration.Pass, Y: 3})
+		}
+	})
+	require.NoError(t, err)
+	assert.Equal(t, maxMigrationPasses, migration.Pass)
+	assert.Equal(t, uint64(1), migration.Behind)
+	assert.True(t, migration.Verified)
+}
+
+func TestMigrateStorageRequests(t *testing.T) {
+	fakeDB(t)
+	server := setupMigrationServer(t)
+	router := server.Router()
+
+	for _, body := range []string{``, `{"driver": "fakesql"}`, `{"driver": "nosuchdriver", "url": "x"}`} {
+		assert.Equal(t, http.StatusBadRequest, adminRequest(router, "POST", "/admin/migrate-storage", body).Code, body)
+	}
+
+	server.handler.migrating.Lock()
+	w := adminRequest(router, "POST", "/admin/migrate-storage", `{"driver": "fakesql", "url": "`+t.Name()+`"}`)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	server.handler.migrating.Unlock()
+}
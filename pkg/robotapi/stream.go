@@ -0,0 +1,103 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamFlushInterval is the number of elements written between flushes
+const streamFlushInterval = 100
+
+// jsonArrayStream writes a JSON array element by element, so large
+// responses never have to be held in memory as a whole
+type jsonArrayStream struct {
+	c       *gin.Context
+	encoder *json.Encoder
+	count   int
+}
+
+// startArray writes the opening of a JSON array; prefix is written before it
+func startArray(c *gin.Context, prefix string) *jsonArrayStream {
+	c.Writer.WriteString(prefix + "[")
+	return &jsonArrayStream{c: c, encoder: json.NewEncoder(c.Writer)}
+}
+
+// Add encodes one element and reports whether the client is still there
+func (s *jsonArrayStream) Add(value interface{}) bool {
+	if s.count > 0 {
+		s.c.Writer.WriteString(",")
+	}
+	if err := s.encoder.Encode(value); err != nil {
+		return false
+	}
+	s.count++
+	if s.count%streamFlushInterval == 0 {
+		s.c.Writer.Flush()
+	}
+	return s.c.Request.Context().Err() == nil
+}
+
+// End closes the array; suffix is written after it
+func (s *jsonArrayStream) End(suffix string) {
+	s.c.Writer.WriteString("]" + suffix)
+}
+
+// GetWorldSnapshot streams the complete world state: every robot with its
+// full action history, all items and all hazards. Robots are read one at a
+// time, so memory use does not grow with the size of the world.
+func (h *RobotHandler) GetWorldSnapshot(c *gin.Context) {
+	c.Header("Content-Type", "application/json; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	robots := startArray(c, `{"robots":`)
+	for _, id := range h.world(c).RobotIDs() {
+		robot, err := h.world(c).GetRobot(id)
+		if err != nil {
+			continue // removed while streaming
+		}
+		if !robots.Add(robot) {
+			return
+		}
+	}
+	robots.End("")
+
+	items := startArray(c, `,"items":`)
+	for _, item := range h.world(c).GetWorldItems() {
+		if !items.Add(item) {
+			return
+		}
+	}
+	items.End("")
+
+	hazards := startArray(c, `,"hazards":`)
+	for _, hazard := range h.world(c).GetHazards() {
+		if !hazards.Add(hazard) {
+			return
+		}
+	}
+	hazards.End("}")
+}
+
+// ExportActions streams a robot's complete action history as
+// newline-delimited JSON, one action per line
+func (h *RobotHandler) ExportActions(c *gin.Context) {
+	robot, err := h.world(c).GetRobot(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Status(http.StatusOK)
+	encoder := json.NewEncoder(c.Writer)
+	for i, action := range robot.Actions {
+		if err := encoder.Encode(action); err != nil || c.Request.Context().Err() != nil {
+			return
+		}
+		if (i+1)%streamFlushInterval == 0 {
+			c.Writer.Flush()
+		}
+	}
+}
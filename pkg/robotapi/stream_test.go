@@ -0,0 +1,99 @@
+package robotapi
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupStreamRouter() (*gin.Engine, *RobotStorage) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+
+	router := gin.New()
+	router.Use(Compress())
+	router.GET("/world/snapshot", handler.GetWorldSnapshot)
+	router.GET("/robot/:id/actions/export", handler.ExportActions)
+	router.GET("/world/map", handler.GetWorldMap)
+	return router, storage
+}
+
+func TestWorldSnapshot(t *testing.T) {
+	router, storage := setupStreamRouter()
+	for i := 0; i < 250; i++ {
+		storage.CreateRobot(&Robot{ID: fmt.Sprintf("bulk%03d", i), Inventory: []string{}})
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/world/snapshot", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var snapshot struct {
+		Robots  []Robot  `json:"robots"`
+		Items   []Item   `json:"items"`
+		Hazards []Hazard `json:"hazards"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &snapshot))
+	assert.Len(t, snapshot.Robots, 252)
+	assert.Equal(t, "bulk000", snapshot.Robots[0].ID)
+	assert.Len(t, snapshot.Robots[250].Actions, 7, "robots include their full history")
+	assert.Len(t, snapshot.Items, 5)
+	assert.Len(t, snapshot.Hazards, 2)
+}
+
+func TestExportActions(t *testing.T) {
+	router, _ := setupStreamRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/robot/robot1/actions/export", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	assert.Len(t, lines, 7)
+	var action Action
+	assert.NoError(t, json.Unmarshal([]byte(lines[0]), &action))
+	assert.Equal(t, "create", action.Type)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/robot/nope/actions/export", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCompress(t *testing.T) {
+	router, _ := setupStreamRouter()
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/world/map", nil)
+	req.Header.Set("Accept-Encoding", "br, gzip")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+
+	reader, err := gzip.NewReader(w.Body)
+	assert.NoError(t, err)
+	var worldMap WorldMap
+	assert.NoError(t, json.NewDecoder(bufio.NewReader(reader)).Decode(&worldMap))
+	assert.Len(t, worldMap.Robots, 2)
+
+	// Without gzip in Accept-Encoding the response is sent as is
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/world/map", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0")
+	router.ServeHTTP(w, req)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.True(t, json.Valid(w.Body.Bytes()))
+}
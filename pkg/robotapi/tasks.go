@@ -0,0 +1,381 @@
+package robotapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Task states
+const (
+	TaskPending   = "pending"
+	TaskRunning   = "running"
+	TaskSucceeded = "succeeded"
+	TaskFailed    = "failed"
+	TaskCancelled = "cancelled"
+)
+
+// taskCancelWait is how long cancelling a running task waits for it to stop
+const taskCancelWait = 5 * time.Second
+
+// ErrTaskNotFound is returned for unknown task IDs
+var ErrTaskNotFound = errors.New("task not found")
+
+// ErrTaskFinished is returned when cancelling a task that already finished
+var ErrTaskFinished = errors.New("task already finished")
+
+// Task is a long-running operation clients can observe through the task API
+type Task struct {
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	Status    string          `json:"status"`
+	Priority  CommandPriority `json:"priority"`
+	Tenant    string          `json:"tenant,omitempty"` // of the robots, empty for the default world
+	Robots    []string        `json:"robots,omitempty"` // robots the task works on
+	Progress  float64         `json:"progress"`         // share of the work done, 0 to 1
+	Result    interface{}     `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
+}
+
+// Done reports whether the task reached a final state
+func (t Task) Done() bool {
+	return t.Status == TaskSucceeded || t.Status == TaskFailed || t.Status == TaskCancelled
+}
+
+// taskRun is the context an unfinished task runs in
+type taskRun struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{} // closed when the task finished
+}
+
+// TaskStore keeps track of long-running tasks
+type TaskStore struct {
+	tasks map[string]*Task
+	runs  map[string]*taskRun // of unfinished tasks
+	ids   IDGenerator
+	clock Clock
+	mutex sync.RWMutex
+}
+
+// NewTaskStore creates an empty task store
+func NewTaskStore() *TaskStore {
+	return &TaskStore{tasks: make(map[string]*Task), runs: make(map[string]*taskRun), ids: UUIDGenerator{}, clock: SystemClock{}}
+}
+
+// SetClock replaces the clock tasks are dated with
+func (s *TaskStore) SetClock(clock Clock) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clock = clock
+}
+
+// Create registers a new pending task of the given type in the normal lane
+func (s *TaskStore) Create(taskType string) Task {
+	return s.CreateWithPriority(taskType, PriorityNormal)
+}
+
+// CreateWithPriority registers a new pending task of the given type and lane
+func (s *TaskStore) CreateWithPriority(taskType string, priority CommandPriority) Task {
+	return s.CreateFor("", taskType, priority)
+}
+
+// CreateFor registers a new pending task of the given type and lane working
+// on robots of a tenant. Tasks are only visible in their tenant.
+func (s *TaskStore) CreateFor(tenant, taskType string, priority CommandPriority, robots ...string) Task {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.clock.Now().UTC()
+	task := &Task{ID: s.ids.NewID(), Type: taskType, Status: TaskPending, Priority: priority, Tenant: tenant, Robots: robots,
+		CreatedAt: now, UpdatedAt: now}
+	s.tasks[task.ID] = task
+	ctx, cancel := context.WithCancel(context.Background())
+	s.runs[task.ID] = &taskRun{ctx: ctx, cancel: cancel, done: make(chan struct{})}
+	return *task
+}
+
+// Update changes a task under the store's lock. Finished tasks are not
+// changed anymore.
+func (s *TaskStore) Update(id string, update func(task *Task)) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	task, exists := s.tasks[id]
+	if !exists || task.Done() {
+		return
+	}
+	update(task)
+	task.UpdatedAt = s.clock.Now().UTC()
+	if task.Done() {
+		s.finish(id)
+	}
+}
+
+// finish releases the run of a task that reached a final state; callers
+// must hold the lock
+func (s *TaskStore) finish(id string) {
+	if run, exists := s.runs[id]; exists {
+		run.cancel()
+		close(run.done)
+		delete(s.runs, id)
+	}
+}
+
+// Context returns the context the task runs in. It is cancelled when the
+// task is, and executors should stop at the next opportunity and record the
+// task as cancelled with what they got done.
+func (s *TaskStore) Context(id string) context.Context {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if run, exists := s.runs[id]; exists {
+		return run.ctx
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+// Cancel cancels a task. A pending task is cancelled right away; a running
+// one is asked to stop through its context. The returned channel is closed
+// once the task finished.
+func (s *TaskStore) Cancel(id string) (<-chan struct{}, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	task, exists := s.tasks[id]
+	if !exists {
+		return nil, ErrTaskNotFound
+	}
+	run, exists := s.runs[id]
+	if task.Done() || !exists {
+		return nil, ErrTaskFinished
+	}
+	if task.Status == TaskPending {
+		task.Status = TaskCancelled
+		task.Error = "cancelled"
+		task.UpdatedAt = s.clock.Now().UTC()
+		s.finish(id)
+		return run.done, nil
+	}
+	run.cancel()
+	return run.done, nil
+}
+
+// CancelAll cancels every unfinished task like Cancel and returns their
+// IDs, without waiting for the running ones to stop
+func (s *TaskStore) CancelAll() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	cancelled := []string{}
+	for id, run := range s.runs {
+		task := s.tasks[id]
+		if task.Status == TaskPending {
+			task.Status = TaskCancelled
+			task.Error = "cancelled"
+			task.UpdatedAt = s.clock.Now().UTC()
+			s.finish(id)
+		} else {
+			run.cancel()
+		}
+		cancelled = append(cancelled, id)
+	}
+	sort.Strings(cancelled)
+	return cancelled
+}
+
+// Get returns a copy of a task
+func (s *TaskStore) Get(id string) (Task, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	task, exists := s.tasks[id]
+	if !exists {
+		return Task{}, false
+	}
+	return *task, true
+}
+
+// Active reports whether an unfinished task of the type works on a robot
+// of the tenant
+func (s *TaskStore) Active(tenant, taskType, robotID string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for id := range s.runs {
+		task := s.tasks[id]
+		if task.Tenant == tenant && task.Type == taskType && containsString(task.Robots, robotID) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pending returns the number of tasks that have not finished yet
+func (s *TaskStore) Pending() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	pending := 0
+	for _, task := range s.tasks {
+		if !task.Done() {
+			pending++
+		}
+	}
+	return pending
+}
+
+// List returns copies of all tasks: the unfinished ones first, by lane
+// from high to low, then the finished ones; newest first among equals
+func (s *TaskStore) List() []Task {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	tasks := make([]Task, 0, len(s.tasks))
+	for _, task := range s.tasks {
+		tasks = append(tasks, *task)
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		a, b := tasks[i], tasks[j]
+		if a.Done() != b.Done() {
+			return !a.Done()
+		}
+		if !a.Done() && a.Priority.rank() != b.Priority.rank() {
+			return a.Priority.rank() > b.Priority.rank()
+		}
+		return a.CreatedAt.After(b.CreatedAt)
+	})
+	return tasks
+}
+
+// taskOf returns a task of the request's tenant
+func (h *RobotHandler) taskOf(c *gin.Context, id string) (Task, bool) {
+	task, exists := h.tasks.Get(id)
+	if !exists || task.Tenant != currentTenant(c) {
+		return Task{}, false
+	}
+	return task, true
+}
+
+// ListTasks returns the tasks of the request's tenant, optionally filtered
+// by type, status, priority and robot
+func (h *RobotHandler) ListTasks(c *gin.Context) {
+	robotID := c.Param("id")
+	if robotID == "" {
+		robotID = c.Query("robot")
+	}
+	tasks := []Task{}
+	for _, task := range h.tasks.List() {
+		if task.Tenant != currentTenant(c) {
+			continue
+		}
+		if robotID != "" && !containsString(task.Robots, robotID) {
+			continue
+		}
+		if taskType := c.Query("type"); taskType != "" && task.Type != taskType {
+			continue
+		}
+		if status := c.Query("status"); status != "" && task.Status != status {
+			continue
+		}
+		if priority := c.Query("priority"); priority != "" && string(task.Priority) != priority {
+			continue
+		}
+		tasks = append(tasks, task)
+	}
+	c.JSON(http.StatusOK, gin.H{"tasks": tasks})
+}
+
+// taskContext returns a copy of the request's context for a task that
+// outlives the request. Its request context does not end with the
+// response; the task watches its own context to learn that it was
+// cancelled, and can still compensate its steps afterwards.
+func taskContext(c *gin.Context) *gin.Context {
+	copied := c.Copy()
+	copied.Request = c.Request.WithContext(context.WithoutCancel(c.Request.Context()))
+	return copied
+}
+
+// robotTaskLinks are the links to a task working on a robot
+func robotTaskLinks(c *gin.Context, robotID, taskID string) []Link {
+	href := fmt.Sprintf("%s/robot/%s/tasks/%s", requestBaseURL(c), robotID, taskID)
+	return []Link{{Rel: "task", Href: href}, {Rel: "cancel", Href: href}}
+}
+
+// GetRobotTask returns a task working on the robot in the :id path
+// parameter
+func (h *RobotHandler) GetRobotTask(c *gin.Context) {
+	task, exists := h.taskOf(c, c.Param("taskId"))
+	if !exists || !containsString(task.Robots, c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+// GetTask returns a single task of the request's tenant
+func (h *RobotHandler) GetTask(c *gin.Context) {
+	task, exists := h.taskOf(c, c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
+
+// CancelTask cancels any task of the request's tenant
+func (h *RobotHandler) CancelTask(c *gin.Context) {
+	task, exists := h.taskOf(c, c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	h.cancelTask(c, task)
+}
+
+// CancelRobotTask cancels a task working on the robot in the :id path
+// parameter
+func (h *RobotHandler) CancelRobotTask(c *gin.Context) {
+	task, exists := h.taskOf(c, c.Param("taskId"))
+	if !exists || !containsString(task.Robots, c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+	h.cancelTask(c, task)
+}
+
+// cancelTask cancels a task and answers with its final state, which holds
+// the partial result. If a running task does not stop in time, the answer is
+// 202 with its current state.
+func (h *RobotHandler) cancelTask(c *gin.Context, task Task) {
+	done, err := h.tasks.Cancel(task.ID)
+	if errors.Is(err, ErrTaskFinished) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Task already finished"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	select {
+	case <-done:
+	case <-time.After(taskCancelWait):
+	case <-c.Request.Context().Done():
+	}
+	task, _ = h.tasks.Get(task.ID)
+	if !task.Done() {
+		c.JSON(http.StatusAccepted, task)
+		return
+	}
+	c.JSON(http.StatusOK, task)
+}
@@ -0,0 +1,144 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCancelTasks(t *testing.T) {
+	tasks := NewTaskStore()
+
+	// Pending tasks are cancelled right away
+	pending := tasks.Create("test")
+	done, err := tasks.Cancel(pending.ID)
+	require.NoError(t, err)
+	<-done
+	task, _ := tasks.Get(pending.ID)
+	assert.Equal(t, TaskCancelled, task.Status)
+	assert.Error(t, tasks.Context(pending.ID).Err())
+
+	// Running tasks stop through their context and record what they got done
+	running := tasks.Create("test")
+	tasks.Update(running.ID, func(task *Task) { task.Status = TaskRunning })
+	ctx := tasks.Context(running.ID)
+	go func() {
+		<-ctx.Done()
+		tasks.Update(running.ID, func(task *Task) {
+			task.Status = TaskCancelled
+			task.Result = "half"
+		})
+	}()
+	done, err = tasks.Cancel(running.ID)
+	require.NoError(t, err)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the task did not stop")
+	}
+	task, _ = tasks.Get(running.ID)
+	assert.Equal(t, TaskCancelled, task.Status)
+	assert.Equal(t, "half", task.Result)
+
+	_, err = tasks.Cancel(running.ID)
+	assert.ErrorIs(t, err, ErrTaskFinished)
+	_, err = tasks.Cancel("ghost")
+	assert.ErrorIs(t, err, ErrTaskNotFound)
+}
+
+func TestCancelRollout(t *testing.T) {
+	router, storage, events := setupMapRouter()
+	tasks := NewTaskStore()
+	ota := NewOTAManager(storage, tasks, events, 0)
+	handler := NewRobotHandler(storage)
+	handler.SetTasks(tasks)
+	handler.SetOTA(ota)
+	router.GET("/robot/:id/tasks", handler.ListTasks)
+	router.DELETE("/robot/:id/tasks/:taskId", handler.CancelRobotTask)
+	router.GET("/admin/rollouts/:id", handler.GetRollout)
+
+	_, err := ota.Register(FirmwareArtifact{Version: "2.0", URL: "https://fw.example/2.0.bin", Checksum: "sha256:" + strings.Repeat("ab", 32)})
+	require.NoError(t, err)
+	rollout, err := ota.Start(RolloutRequest{Version: "2.0", Robots: []string{"robot1", "robot2"}, Stages: []int{50, 100}})
+	require.NoError(t, err)
+	ota.Tick(1)
+
+	var list struct {
+		Tasks []Task `json:"tasks"`
+	}
+	json.Unmarshal(send(router, "GET", "/robot/robot2/tasks", "").Body.Bytes(), &list)
+	require.Len(t, list.Tasks, 1)
+	assert.Equal(t, rollout.TaskID, list.Tasks[0].ID)
+
+	assert.Equal(t, http.StatusNotFound, send(router, "DELETE", "/robot/robot3/tasks/"+rollout.TaskID, "").Code)
+
+	w := send(router, "DELETE", "/robot/robot2/tasks/"+rollout.TaskID, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	var task Task
+	json.Unmarshal(w.Body.Bytes(), &task)
+	assert.Equal(t, TaskCancelled, task.Status)
+	assert.Equal(t, 0.5, task.Progress)
+	assert.Equal(t, []any{"robot1"}, task.Result.(map[string]any)["updated"])
+
+	// The rollout stops where it was
+	ota.Tick(2)
+	json.Unmarshal(send(router, "GET", "/admin/rollouts/"+rollout.ID, "").Body.Bytes(), &rollout)
+	assert.Equal(t, RolloutCancelled, rollout.Status)
+	assert.Equal(t, []string{"robot1"}, rollout.Updated)
+	robot, _ := storage.GetRobot("robot2")
+	assert.Equal(t, "", robot.Firmware)
+
+	assert.Equal(t, http.StatusConflict, send(router, "DELETE", "/robot/robot2/tasks/"+rollout.TaskID, "").Code)
+}
+
+func TestTasksStayInTheirTenant(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tenants := NewTenantStore(newExampleWorld)
+	tenants.Create("lab1")
+	tenants.Create("lab2")
+	handler := NewRobotHandler(NewRobotStorage())
+	router := gin.New()
+	router.Use(ResolveTenant(tenants))
+	router.GET("/robot/:id/tasks", handler.ListTasks)
+	router.DELETE("/robot/:id/tasks/:taskId", handler.CancelRobotTask)
+	router.GET("/admin/tasks/:id", handler.GetTask)
+
+	task := handler.tasks.CreateFor("lab1", "test", PriorityNormal, "robot1")
+	assert.Equal(t, "lab1", task.Tenant)
+
+	var list struct {
+		Tasks []Task `json:"tasks"`
+	}
+	json.Unmarshal(sendAs(router, "", "lab2", "GET", "/robot/robot1/tasks", "").Body.Bytes(), &list)
+	assert.Empty(t, list.Tasks, "robot1 of lab2 is another robot")
+	assert.Equal(t, http.StatusNotFound, sendAs(router, "", "lab2", "GET", "/admin/tasks/"+task.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, sendAs(router, "", "lab2", "DELETE", "/robot/robot1/tasks/"+task.ID, "").Code)
+	assert.Equal(t, http.StatusNotFound, sendAs(router, "", "", "DELETE", "/robot/robot1/tasks/"+task.ID, "").Code)
+
+	json.Unmarshal(sendAs(router, "", "lab1", "GET", "/robot/robot1/tasks", "").Body.Bytes(), &list)
+	assert.Len(t, list.Tasks, 1)
+	assert.Equal(t, http.StatusOK, sendAs(router, "", "lab1", "DELETE", "/robot/robot1/tasks/"+task.ID, "").Code)
+}
+
+// awaitTask polls a task working on a robot until it finished and decodes
+// its result into result
+func awaitTask(t *testing.T, router *gin.Engine, robotID, taskID string, result interface{}) Task {
+	t.Helper()
+	var task Task
+	require.Eventually(t, func() bool {
+		w := send(router, "GET", "/robot/"+robotID+"/tasks/"+taskID, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &task))
+		return task.Done()
+	}, 5*time.Second, time.Millisecond)
+	encoded, err := json.Marshal(task.Result)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(encoded, result))
+	return task
+}
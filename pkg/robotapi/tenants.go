@@ -0,0 +1,325 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"aufgabe-2/pkg/pubsub"
+)
+
+// ErrTenantNotFound is returned for tenants that do not exist
+var ErrTenantNotFound = errors.New("tenant not found")
+
+// Tenant is an isolated group of users with its own world: robots, items,
+// map, quotas and events are not shared with other tenants
+type Tenant struct {
+	ID        string
+	Storage   *RobotStorage
+	Events    *EventBus
+	CreatedAt time.Time
+}
+
+// TenantUsage summarizes what a tenant uses
+type TenantUsage struct {
+	ID        string         `json:"id"`
+	Robots    int            `json:"robots"`
+	Items     int            `json:"items"`
+	Actions   int            `json:"actions"`    // history entries of all robots
+	Events    int64          `json:"events"`     // events published so far
+	QuotaUsed map[string]int `json:"quota_used"` // actions counted against quotas today
+	Limits    TenantLimits   `json:"limits"`
+	CreatedAt *time.Time     `json:"created_at,omitempty"`
+}
+
+// TenantStore keeps the worlds of all tenants besides the default one,
+// which is the handler's own storage
+type TenantStore struct {
+	tenants  map[string]*Tenant
+	newWorld func() *RobotStorage
+	clock    Clock
+	// subscribers bounds the buffers of the tenants' event subscribers
+	subscribers SubscriberLimits
+	topics      *pubsub.Bus[Event] // new tenants' events are forwarded here, nil if not
+	mutex       sync.RWMutex
+}
+
+// NewTenantStore creates a store whose tenants get worlds from newWorld
+func NewTenantStore(newWorld func() *RobotStorage) *TenantStore {
+	return &TenantStore{tenants: make(map[string]*Tenant), newWorld: newWorld, clock: SystemClock{}, subscribers: DefaultSubscriberLimits()}
+}
+
+// SetClock replaces the clock of new tenants' worlds and event buses
+func (s *TenantStore) SetClock(clock Clock) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.clock = clock
+}
+
+// SetSubscriberLimits replaces the subscriber limits of new tenants' event
+// buses
+func (s *TenantStore) SetSubscriberLimits(limits SubscriberLimits) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.subscribers = limits
+}
+
+// ForwardEvents republishes the events of tenants created from now on on the
+// bus, below "tenants.<tenant ID>"
+func (s *TenantStore) ForwardEvents(topics *pubsub.Bus[Event]) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.topics = topics
+}
+
+// newExampleWorld creates a world seeded with the example data
+func newExampleWorld() *RobotStorage {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	return storage
+}
+
+// Create adds a tenant with a fresh world
+func (s *TenantStore) Create(id string) (*Tenant, error) {
+	if !robotIDPattern.MatchString(id) {
+		return nil, errors.New("tenant IDs must consist of 1-64 letters, digits, '-' or '_'")
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, exists := s.tenants[id]; exists {
+		return nil, fmt.Errorf("tenant %s already exists", id)
+	}
+	tenant := &Tenant{ID: id, Storage: s.newWorld(), Events: NewEventBus(), CreatedAt: s.clock.Now().UTC()}
+	tenant.Storage.SetClock(s.clock)
+	tenant.Events.SetClock(s.clock)
+	if err := tenant.Events.SetSubscriberLimits(s.subscribers); err != nil {
+		return nil, err
+	}
+	if s.topics != nil {
+		tenant.Events.Forward(s.topics, "tenants."+id)
+	}
+	s.tenants[id] = tenant
+	return tenant, nil
+}
+
+// Get returns a tenant
+func (s *TenantStore) Get(id string) (*Tenant, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	tenant, exists := s.tenants[id]
+	return tenant, exists
+}
+
+// All returns all tenants ordered by ID
+func (s *TenantStore) All() []*Tenant {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	tenants := make([]*Tenant, 0, len(s.tenants))
+	for _, tenant := range s.tenants {
+		tenants = append(tenants, tenant)
+	}
+	sort.Slice(tenants, func(i, j int) bool {
+		return tenants[i].ID < tenants[j].ID
+	})
+	return tenants
+}
+
+// EachWorld calls fn for the world of every tenant. It lets simulation
+// systems tick all tenants.
+func (s *TenantStore) EachWorld(fn func(storage *RobotStorage)) {
+	for _, tenant := range s.All() {
+		fn(tenant.Storage)
+	}
+}
+
+// Version changes whenever any tenant's world changes
+func (s *TenantStore) Version() uint64 {
+	var version uint64
+	s.EachWorld(func(storage *RobotStorage) {
+		version += storage.Version()
+	})
+	return version
+}
+
+// usage summarizes a world and its events
+func usage(id string, storage *RobotStorage, events *EventBus) TenantUsage {
+	usage := TenantUsage{ID: id, Items: storage.ItemCount(), Events: events.Sequence()}
+	for _, robot := range storage.GetAllRobots() {
+		usage.Robots++
+		usage.Actions += len(robot.Actions)
+	}
+	usage.QuotaUsed = storage.QuotaUsage()
+	usage.Limits = storage.Limits()
+	return usage
+}
+
+// currentTenant returns the ID of the tenant the request is scoped to; the
+// default tenant has the empty ID
+func currentTenant(c *gin.Context) string {
+	return c.GetString("tenant")
+}
+
+// world returns the storage of the request's tenant
+func (h *RobotHandler) world(c *gin.Context) *RobotStorage {
+	return h.worldOf(currentTenant(c))
+}
+
+// worldOf returns the storage of a tenant
+func (h *RobotHandler) worldOf(tenantID string) *RobotStorage {
+	if tenant, exists := h.tenants.Get(tenantID); exists {
+		return tenant.Storage
+	}
+	return h.storage
+}
+
+// bus returns the event bus of the request's tenant
+func (h *RobotHandler) bus(c *gin.Context) *EventBus {
+	return h.busOf(currentTenant(c))
+}
+
+// busOf returns the event bus of a tenant
+func (h *RobotHandler) busOf(tenantID string) *EventBus {
+	if tenant, exists := h.tenants.Get(tenantID); exists {
+		return tenant.Events
+	}
+	return h.events
+}
+
+// ResolveTenant scopes the request to the tenant named in the X-Tenant-ID
+// header. Authentication later overrides it with the caller's own tenant.
+func ResolveTenant(tenants *TenantStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if id := c.GetHeader("X-Tenant-ID"); id != "" {
+			if _, exists := tenants.Get(id); !exists {
+				c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Unknown tenant"})
+				return
+			}
+			c.Set("tenant", id)
+		}
+		c.Next()
+	}
+}
+
+// ScopePublic scopes the requests of routes that need no credentials.
+// Callers sending credentials are scoped as by Authenticate, anonymous
+// callers stay in the default tenant: naming another one in X-Tenant-ID
+// takes credentials or the admin token.
+func ScopePublic(adminToken string, auth *Authenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !auth.Enabled() || hasAdminToken(c, adminToken) {
+			c.Next()
+			return
+		}
+
+		principal, err := auth.Identify(c)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		if principal == nil {
+			if currentTenant(c) != "" {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "API key or ID token required for tenant access"})
+				return
+			}
+			c.Next()
+			return
+		}
+		if !scopeToPrincipal(c, principal, auth.tenants) {
+			return
+		}
+
+		c.Set("principal", principal)
+		c.Next()
+	}
+}
+
+// scopeToPrincipal binds the request to the caller's tenant. Asking for
+// another tenant than the own one is rejected. Only admins without a tenant
+// keep the one from the header; other callers without a tenant belong to
+// the default tenant.
+func scopeToPrincipal(c *gin.Context, principal *Principal, tenants *TenantStore) bool {
+	if principal.Tenant == "" {
+		if currentTenant(c) != "" && !principal.HasRole(RoleAdmin) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Cross-tenant access is not allowed"})
+			return false
+		}
+		return true
+	}
+	var known bool
+	if tenants != nil {
+		_, known = tenants.Get(principal.Tenant)
+	}
+	if !known {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Unknown tenant " + principal.Tenant})
+		return false
+	}
+	if requested := currentTenant(c); requested != "" && requested != principal.Tenant {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Cross-tenant access is not allowed"})
+		return false
+	}
+	c.Set("tenant", principal.Tenant)
+	return true
+}
+
+// tenantOfName returns the tenant part of credential names like "lab1/alice"
+func tenantOfName(name string) string {
+	tenant, _, found := strings.Cut(name, "/")
+	if !found {
+		return ""
+	}
+	return tenant
+}
+
+// CreateTenant adds a tenant with a fresh world and optional limits
+func (h *RobotHandler) CreateTenant(c *gin.Context) {
+	var request struct {
+		ID     string       `json:"id" binding:"required"`
+		Limits TenantLimits `json:"limits"`
+	}
+	if err := bindJSON(c, &request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id is required", "details": bindErrorDetails(err)})
+		return
+	}
+	if !robotIDPattern.MatchString(request.ID) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tenant ID"})
+		return
+	}
+	if err := request.Limits.validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tenant, err := h.tenants.Create(request.ID)
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	tenant.Storage.SetLimits(request.Limits)
+	h.analytics.For(tenant.Events)
+	h.anomalies.Watch(tenant.ID, tenant.Storage, tenant.Events)
+	if h.invariants != nil {
+		h.invariants.Watch(tenant.ID, tenant.Storage, tenant.Events)
+	}
+	tenant.Events.Publish("random_seeded", "", gin.H{"seed": h.random.Seed()})
+	c.JSON(http.StatusCreated, usage(tenant.ID, tenant.Storage, tenant.Events))
+}
+
+// ListTenants returns the usage of the default tenant and all others
+func (h *RobotHandler) ListTenants(c *gin.Context) {
+	tenants := []TenantUsage{usage("", h.storage, h.events)}
+	for _, tenant := range h.tenants.All() {
+		summary := usage(tenant.ID, tenant.Storage, tenant.Events)
+		summary.CreatedAt = &tenant.CreatedAt
+		tenants = append(tenants, summary)
+	}
+	c.JSON(http.StatusOK, gin.H{"tenants": tenants})
+}
@@ -0,0 +1,195 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTenantServer serves the default tenant and the tenants lab1 and lab2
+func setupTenantServer(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.APIKeys = "alice=alice-key,lab1/carol=carol-key,lab2/dave=dave-key,lab3/eve=eve-key"
+	config.AdminToken = "secret"
+	config.Tenants = []string{"lab1", "lab2"}
+	config.CacheTTL = 0
+	server, err := New(config)
+	assert.NoError(t, err)
+	return server.Router()
+}
+
+// sendAs sends a request with the given API key and X-Tenant-ID header
+func sendAs(router *gin.Engine, key, tenant, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("X-API-Key", key)
+	}
+	if tenant != "" {
+		req.Header.Set("X-Tenant-ID", tenant)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestTenantsHaveSeparateWorlds(t *testing.T) {
+	router := setupTenantServer(t)
+
+	// Moving robot1 of lab1 leaves robot1 of the default tenant and lab2 alone
+	assert.Equal(t, http.StatusOK, sendAs(router, "carol-key", "", "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+
+	position := func(key string) Position {
+		var robot Robot
+		json.Unmarshal(sendAs(router, key, "", "GET", "/robot/robot1/status", "").Body.Bytes(), &robot)
+		return robot.Position
+	}
+	assert.Equal(t, 1, position("carol-key").Y-position("dave-key").Y)
+	assert.Equal(t, position("alice-key"), position("dave-key"))
+
+	// Robots created in a tenant only exist there
+	assert.Equal(t, http.StatusCreated, sendAs(router, "dave-key", "", "POST", "/robots", `{"id": "lab2-only"}`).Code)
+	assert.Equal(t, http.StatusOK, sendAs(router, "dave-key", "", "GET", "/robot/lab2-only/status", "").Code)
+	assert.Equal(t, http.StatusNotFound, sendAs(router, "carol-key", "", "GET", "/robot/lab2-only/status", "").Code)
+	assert.Equal(t, http.StatusNotFound, sendAs(router, "alice-key", "", "GET", "/robot/lab2-only/status", "").Code)
+}
+
+func TestTenantAccessChecks(t *testing.T) {
+	router := setupTenantServer(t)
+
+	// Tenant users cannot ask for another tenant
+	w := sendAs(router, "carol-key", "lab2", "GET", "/robot/robot1/status", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Cross-tenant")
+	assert.Equal(t, http.StatusOK, sendAs(router, "carol-key", "lab1", "GET", "/robot/robot1/status", "").Code)
+
+	// Keys of unregistered tenants are rejected
+	assert.Equal(t, http.StatusForbidden, sendAs(router, "eve-key", "", "GET", "/robot/robot1/status", "").Code)
+	// Unknown tenants in the header are not found
+	assert.Equal(t, http.StatusNotFound, sendAs(router, "alice-key", "lab9", "GET", "/robot/robot1/status", "").Code)
+	// Keys without a tenant belong to the default tenant, they cannot switch either
+	w = sendAs(router, "alice-key", "lab2", "POST", "/robot/robot1/move", `{"direction": "up"}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "Cross-tenant")
+
+	// The principal shows its tenant
+	var me struct {
+		Principal Principal `json:"principal"`
+	}
+	json.Unmarshal(sendAs(router, "carol-key", "", "GET", "/me", "").Body.Bytes(), &me)
+	assert.Equal(t, "lab1", me.Principal.Tenant)
+
+	// Sessions stay in the tenant they were issued for
+	var session struct {
+		Token string `json:"token"`
+	}
+	json.Unmarshal(sendAs(router, "dave-key", "", "POST", "/sessions", `{"robot_id": "robot1"}`).Body.Bytes(), &session)
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/robot/robot1/move", strings.NewReader(`{"direction": "up"}`))
+	req.Header.Set("X-Session-Token", session.Token)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var moved, untouched Robot
+	json.Unmarshal(sendAs(router, "dave-key", "", "GET", "/robot/robot1/status", "").Body.Bytes(), &moved)
+	json.Unmarshal(sendAs(router, "alice-key", "", "GET", "/robot/robot1/status", "").Body.Bytes(), &untouched)
+	assert.Equal(t, untouched.Position.Y+1, moved.Position.Y)
+}
+
+func TestTenantAdministration(t *testing.T) {
+	router := setupTenantServer(t)
+	admin := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusCreated, admin("POST", "/admin/tenants", `{"id": "lab3"}`).Code)
+	assert.Equal(t, http.StatusConflict, admin("POST", "/admin/tenants", `{"id": "lab3"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, admin("POST", "/admin/tenants", `{"id": "no/slashes"}`).Code)
+	assert.Equal(t, http.StatusOK, sendAs(router, "eve-key", "", "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+
+	var response struct {
+		Tenants []TenantUsage `json:"tenants"`
+	}
+	json.Unmarshal(admin("GET", "/admin/tenants", "").Body.Bytes(), &response)
+	assert.Len(t, response.Tenants, 4)
+	assert.Equal(t, "", response.Tenants[0].ID)
+	lab3 := response.Tenants[3]
+	assert.Equal(t, "lab3", lab3.ID)
+	assert.Equal(t, response.Tenants[0].Robots, lab3.Robots)
+	assert.Equal(t, 5, lab3.Items)
+	assert.Equal(t, response.Tenants[0].Actions+1, lab3.Actions)
+	assert.Equal(t, int64(2), lab3.Events, "the random seed and the move")
+}
+
+func TestOnlyAdminsSwitchTenants(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tenants := NewTenantStore(newExampleWorld)
+	_, err := tenants.Create("lab1")
+	assert.NoError(t, err)
+	scope := func(principal *Principal) (*httptest.ResponseRecorder, string, bool) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Set("tenant", "lab1") // as asked for in X-Tenant-ID
+		allowed := scopeToPrincipal(c, principal, tenants)
+		return w, currentTenant(c), allowed
+	}
+
+	_, tenant, allowed := scope(&Principal{ID: "oidc:root", Roles: []string{RoleUser, RoleAdmin}})
+	assert.True(t, allowed)
+	assert.Equal(t, "lab1", tenant)
+
+	w, _, allowed := scope(&Principal{ID: "alice", Roles: []string{RoleUser}})
+	assert.False(t, allowed)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestPublicRoutesStayInTheirTenant(t *testing.T) {
+	router := setupTenantServer(t)
+	// lab1's world differs from the others by carol's move
+	assert.Equal(t, http.StatusOK, sendAs(router, "carol-key", "", "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+
+	routes := []string{"/items", "/search?q=moved", "/world/map", "/world/weather", "/world/time", "/world/zones", "/world/spectate"}
+	for _, route := range routes {
+		// Anonymous callers and keys of other tenants cannot name lab1
+		w := sendAs(router, "", "lab1", "GET", route, "")
+		assert.Equal(t, http.StatusUnauthorized, w.Code, route)
+		w = sendAs(router, "alice-key", "lab1", "GET", route, "")
+		assert.Equal(t, http.StatusForbidden, w.Code, route)
+		w = sendAs(router, "dave-key", "lab1", "GET", route, "")
+		assert.Equal(t, http.StatusForbidden, w.Code, route)
+	}
+
+	// lab1's own keys and the admin token see lab1 with carol's move,
+	// anonymous callers the default tenant
+	moves := func(key, tenant string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/search?q=moved", nil)
+		if key == "secret" {
+			req.Header.Set("Authorization", "Bearer secret")
+		} else if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var results SearchResults
+		json.Unmarshal(w.Body.Bytes(), &results)
+		return results.ActionsPage.TotalElements
+	}
+	anonymous := moves("", "")
+	assert.Equal(t, anonymous, moves("alice-key", ""))
+	assert.Equal(t, anonymous+1, moves("carol-key", ""))
+	assert.Equal(t, anonymous+1, moves("carol-key", "lab1"))
+	assert.Equal(t, anonymous+1, moves("secret", "lab1"))
+}
@@ -0,0 +1,760 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TournamentTask is the type of the tasks tournament runs are tracked as
+const TournamentTask = "tournament"
+
+// Tournament formats
+const (
+	TournamentRoundRobin = "round_robin" // everyone meets everyone once
+	TournamentBracket    = "bracket"     // single elimination
+)
+
+// Tournament states
+const (
+	TournamentRegistration = "registration" // participants can register
+	TournamentRunning      = "running"      // the schedule is fixed, matches are played
+	TournamentFinished     = "finished"     // all matches were played
+)
+
+// Match states
+const (
+	MatchWaiting   = "waiting"   // a bracket match whose participants are not decided yet
+	MatchScheduled = "scheduled" // ready to be played
+	MatchPlayed    = "played"
+	MatchBye       = "bye" // a bracket participant without opponent advances
+)
+
+// Limits of tournaments
+const (
+	maxTournamentParticipants = 64
+	maxTeamSize               = 8
+)
+
+// Points of a round robin match
+const (
+	pointsWin  = 3
+	pointsDraw = 1
+)
+
+// ErrTournamentNotFound is returned for unknown tournament IDs
+var ErrTournamentNotFound = errors.New("tournament not found")
+
+// TournamentRequest creates a tournament
+type TournamentRequest struct {
+	Name   string `json:"name" binding:"required"`
+	Format string `json:"format"` // TournamentRoundRobin (default) or TournamentBracket
+	Rounds int    `json:"rounds"` // battle rounds of a duel, default 50
+	Seed   int64  `json:"seed"`   // seed for the dice, default 1
+}
+
+// Participant is a robot or a team of robots taking part in a tournament
+type Participant struct {
+	ID     string   `json:"id" binding:"required"`
+	Name   string   `json:"name,omitempty"`
+	Robots []string `json:"robots" binding:"required,min=1"`
+}
+
+// Duel is a battle between two robots of a match. A robot missing from the
+// world or from a smaller team loses its duel without a fight.
+type Duel struct {
+	A       string `json:"a,omitempty"` // robot IDs
+	B       string `json:"b,omitempty"`
+	Winner  string `json:"winner,omitempty"` // empty for a draw
+	DamageA int    `json:"damage_a"`
+	DamageB int    `json:"damage_b"`
+}
+
+// TournamentMatch is a match between two participants. The robots at the
+// same position of the two teams fight a duel; the participant winning more
+// duels wins the match.
+type TournamentMatch struct {
+	ID       int        `json:"id"` // position in the schedule, starting at 1
+	Round    int        `json:"round"`
+	A        string     `json:"a,omitempty"` // participant IDs
+	B        string     `json:"b,omitempty"`
+	Status   string     `json:"status"`
+	Winner   string     `json:"winner,omitempty"`   // empty for a draw
+	Tiebreak bool       `json:"tiebreak,omitempty"` // a drawn bracket match was decided by damage or seed
+	Duels    []Duel     `json:"duels,omitempty"`
+	PlayedAt *time.Time `json:"played_at,omitempty"`
+
+	next     int            // index of the bracket match the winner advances to, -1 for the final
+	nextSlot int            // 0 for A, 1 for B
+	scenario *MatchScenario // what the match was played with, for replays
+}
+
+// Standing is a participant's record in a tournament
+type Standing struct {
+	Participant string `json:"participant"`
+	Played      int    `json:"played"`
+	Wins        int    `json:"wins"`
+	Draws       int    `json:"draws"`
+	Losses      int    `json:"losses"`
+	Points      int    `json:"points"`
+	DuelWins    int    `json:"duel_wins"`
+	DuelLosses  int    `json:"duel_losses"`
+	Eliminated  bool   `json:"eliminated,omitempty"`
+}
+
+// Tournament schedules matches between its participants and runs them with
+// the battle simulator. Real robots are only read, never changed.
+type Tournament struct {
+	ID           string            `json:"id"`
+	Name         string            `json:"name"`
+	Format       string            `json:"format"`
+	Rounds       int               `json:"rounds"`
+	Seed         int64             `json:"seed"`
+	Status       string            `json:"status"`
+	Participants []Participant     `json:"participants"`
+	Matches      []TournamentMatch `json:"matches"`
+	Champion     string            `json:"champion,omitempty"`
+	CreatedAt    time.Time         `json:"created_at"`
+
+	tenant string
+}
+
+// clone copies the tournament, so it can be handed out without the lock
+func (t *Tournament) clone() Tournament {
+	clone := *t
+	clone.Participants = slices.Clone(t.Participants)
+	clone.Matches = make([]TournamentMatch, len(t.Matches))
+	for i, match := range t.Matches {
+		match.Duels = slices.Clone(match.Duels)
+		clone.Matches[i] = match
+	}
+	return clone
+}
+
+// participant returns a participant by ID
+func (t *Tournament) participant(id string) (Participant, int) {
+	for i, participant := range t.Participants {
+		if participant.ID == id {
+			return participant, i
+		}
+	}
+	return Participant{}, -1
+}
+
+// register adds a participant while registration is open
+func (t *Tournament) register(participant Participant) error {
+	if t.Status != TournamentRegistration {
+		return errors.New("Registration is closed")
+	}
+	if len(t.Participants) >= maxTournamentParticipants {
+		return fmt.Errorf("a tournament has at most %d participants", maxTournamentParticipants)
+	}
+	if len(participant.Robots) > maxTeamSize {
+		return fmt.Errorf("a team has at most %d robots", maxTeamSize)
+	}
+	if _, index := t.participant(participant.ID); index >= 0 {
+		return fmt.Errorf("participant %s is already registered", participant.ID)
+	}
+	for _, robot := range participant.Robots {
+		for _, other := range t.Participants {
+			if slices.Contains(other.Robots, robot) {
+				return fmt.Errorf("robot %s already plays for %s", robot, other.ID)
+			}
+		}
+	}
+	if participant.Name == "" {
+		participant.Name = participant.ID
+	}
+	t.Participants = append(t.Participants, participant)
+	return nil
+}
+
+// start closes the registration and schedules the matches
+func (t *Tournament) start() error {
+	if t.Status != TournamentRegistration {
+		return errors.New("The tournament has already started")
+	}
+	if len(t.Participants) < 2 {
+		return errors.New("A tournament needs at least two participants")
+	}
+	ids := make([]string, len(t.Participants))
+	for i, participant := range t.Participants {
+		ids[i] = participant.ID
+	}
+	if t.Format == TournamentBracket {
+		t.Matches = bracketSchedule(ids)
+	} else {
+		t.Matches = roundRobinSchedule(ids)
+	}
+	t.Status = TournamentRunning
+	t.finish()
+	return nil
+}
+
+// roundRobinSchedule pairs everyone with everyone with the circle method:
+// the first participant stays, the others rotate by one seat per round. With
+// an odd number of participants one of them sits out every round.
+func roundRobinSchedule(ids []string) []TournamentMatch {
+	seats := slices.Clone(ids)
+	if len(seats)%2 == 1 {
+		seats = append(seats, "")
+	}
+	matches := []TournamentMatch{}
+	for round := 1; round < len(seats); round++ {
+		for i := 0; i < len(seats)/2; i++ {
+			a, b := seats[i], seats[len(seats)-1-i]
+			if a == "" || b == "" {
+				continue
+			}
+			matches = append(matches, TournamentMatch{ID: len(matches) + 1, Round: round, A: a, B: b, Status: MatchScheduled, next: -1})
+		}
+		// Rotate all seats but the first
+		seats = append([]string{seats[0], seats[len(seats)-1]}, seats[1:len(seats)-1]...)
+	}
+	return matches
+}
+
+// bracketSeeds returns the seeds of a bracket of the given size in the order
+// of their first-round slots, so the top seeds meet as late as possible
+func bracketSeeds(size int) []int {
+	seeds := []int{0}
+	for len(seeds) < size {
+		next := make([]int, 0, 2*len(seeds))
+		for _, seed := range seeds {
+			next = append(next, seed, 2*len(seeds)-1-seed)
+		}
+		seeds = next
+	}
+	return seeds
+}
+
+// bracketSchedule creates all matches of a single elimination bracket. The
+// participants are seeded in registration order; if their number is no
+// power of two, the top seeds get byes.
+func bracketSchedule(ids []string) []TournamentMatch {
+	size := 2
+	for size < len(ids) {
+		size *= 2
+	}
+	seeds := bracketSeeds(size)
+	participant := func(seed int) string {
+		if seed < len(ids) {
+			return ids[seed]
+		}
+		return ""
+	}
+
+	matches := []TournamentMatch{}
+	first := 0 // index of the first match of the previous round
+	for round, count := 1, size/2; count >= 1; round, count = round+1, count/2 {
+		start := len(matches)
+		for i := 0; i < count; i++ {
+			match := TournamentMatch{ID: len(matches) + 1, Round: round, Status: MatchWaiting, next: -1}
+			if round == 1 {
+				match.A, match.B = participant(seeds[2*i]), participant(seeds[2*i+1])
+				match.Status = MatchScheduled
+			}
+			matches = append(matches, match)
+		}
+		if round > 1 {
+			for i := first; i < start; i++ {
+				matches[i].next = start + (i-first)/2
+				matches[i].nextSlot = (i - first) % 2
+			}
+		}
+		first = start
+	}
+
+	// Participants without opponent advance at once
+	for i := range matches {
+		if matches[i].Round == 1 && matches[i].B == "" {
+			matches[i].Status = MatchBye
+			matches[i].Winner = matches[i].A
+			advance(matches, i)
+		}
+	}
+	return matches
+}
+
+// advance puts the winner of a bracket match into its next match
+func advance(matches []TournamentMatch, index int) {
+	match := matches[index]
+	if match.next < 0 {
+		return
+	}
+	next := &matches[match.next]
+	if match.nextSlot == 0 {
+		next.A = match.Winner
+	} else {
+		next.B = match.Winner
+	}
+	if next.A != "" && next.B != "" {
+		next.Status = MatchScheduled
+	}
+}
+
+// finish ends the tournament once no match is left to play
+func (t *Tournament) finish() {
+	for _, match := range t.Matches {
+		if match.Status == MatchScheduled || match.Status == MatchWaiting {
+			return
+		}
+	}
+	t.Status = TournamentFinished
+	if t.Format == TournamentBracket {
+		t.Champion = t.Matches[len(t.Matches)-1].Winner
+	} else if standings := t.Standings(); len(standings) > 0 {
+		t.Champion = standings[0].Participant
+	}
+}
+
+// Upcoming returns the matches not played yet in the order of the schedule
+func (t *Tournament) Upcoming() []TournamentMatch {
+	upcoming := []TournamentMatch{}
+	for _, match := range t.Matches {
+		if match.Status == MatchScheduled || match.Status == MatchWaiting {
+			upcoming = append(upcoming, match)
+		}
+	}
+	return upcoming
+}
+
+// Standings ranks the participants by points, then by duel difference, then
+// by registration order. Bracket participants still in the race come first.
+func (t *Tournament) Standings() []Standing {
+	standings := make([]Standing, len(t.Participants))
+	for i, participant := range t.Participants {
+		standings[i].Participant = participant.ID
+	}
+	for _, match := range t.Matches {
+		if match.Status != MatchPlayed {
+			continue
+		}
+		_, a := t.participant(match.A)
+		_, b := t.participant(match.B)
+		for _, duel := range match.Duels {
+			switch {
+			case duel.Winner == "":
+			case duel.Winner == duel.A:
+				standings[a].DuelWins++
+				standings[b].DuelLosses++
+			default:
+				standings[b].DuelWins++
+				standings[a].DuelLosses++
+			}
+		}
+		standings[a].Played++
+		standings[b].Played++
+		switch match.Winner {
+		case "":
+			standings[a].Draws++
+			standings[b].Draws++
+			standings[a].Points += pointsDraw
+			standings[b].Points += pointsDraw
+		case match.A:
+			standings[a].Wins++
+			standings[b].Losses++
+			standings[a].Points += pointsWin
+			standings[b].Eliminated = t.Format == TournamentBracket
+		default:
+			standings[b].Wins++
+			standings[a].Losses++
+			standings[b].Points += pointsWin
+			standings[a].Eliminated = t.Format == TournamentBracket
+		}
+	}
+
+	order := make(map[string]int, len(t.Participants))
+	for i, participant := range t.Participants {
+		order[participant.ID] = i
+	}
+	sort.SliceStable(standings, func(i, j int) bool {
+		a, b := standings[i], standings[j]
+		if a.Eliminated != b.Eliminated {
+			return !a.Eliminated
+		}
+		if a.Points != b.Points {
+			return a.Points > b.Points
+		}
+		if a.DuelWins-a.DuelLosses != b.DuelWins-b.DuelLosses {
+			return a.DuelWins-a.DuelLosses > b.DuelWins-b.DuelLosses
+		}
+		return order[a.Participant] < order[b.Participant]
+	})
+	return standings
+}
+
+// TournamentStore keeps the tournaments of all worlds
+type TournamentStore struct {
+	tournaments map[string]*Tournament
+	ids         IDGenerator
+	now         func() time.Time
+	mutex       sync.Mutex
+}
+
+// NewTournamentStore creates an empty store
+func NewTournamentStore() *TournamentStore {
+	return &TournamentStore{tournaments: make(map[string]*Tournament), ids: UUIDGenerator{}, now: time.Now}
+}
+
+// Create adds a tournament to a tenant's world
+func (s *TournamentStore) Create(tenant string, tournament Tournament) Tournament {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tournament.ID = s.ids.NewID()
+	tournament.Status = TournamentRegistration
+	tournament.Participants = []Participant{}
+	tournament.Matches = []TournamentMatch{}
+	tournament.CreatedAt = s.now().UTC()
+	tournament.tenant = tenant
+	s.tournaments[tournament.ID] = &tournament
+	return tournament.clone()
+}
+
+// Get returns a copy of a tenant's tournament
+func (s *TournamentStore) Get(tenant, id string) (Tournament, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tournament, exists := s.tournaments[id]
+	if !exists || tournament.tenant != tenant {
+		return Tournament{}, false
+	}
+	return tournament.clone(), true
+}
+
+// List returns a tenant's tournaments, oldest first
+func (s *TournamentStore) List(tenant string) []Tournament {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tournaments := []Tournament{}
+	for _, tournament := range s.tournaments {
+		if tournament.tenant == tenant {
+			tournaments = append(tournaments, tournament.clone())
+		}
+	}
+	sort.Slice(tournaments, func(i, j int) bool {
+		if !tournaments[i].CreatedAt.Equal(tournaments[j].CreatedAt) {
+			return tournaments[i].CreatedAt.Before(tournaments[j].CreatedAt)
+		}
+		return tournaments[i].ID < tournaments[j].ID
+	})
+	return tournaments
+}
+
+// Update changes a tenant's tournament under the store's lock. If update
+// fails the tournament stays as it was.
+func (s *TournamentStore) Update(tenant, id string, update func(tournament *Tournament) error) (Tournament, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tournament, exists := s.tournaments[id]
+	if !exists || tournament.tenant != tenant {
+		return Tournament{}, ErrTournamentNotFound
+	}
+	updated := tournament.clone()
+	if err := update(&updated); err != nil {
+		return tournament.clone(), err
+	}
+	s.tournaments[id] = &updated
+	return updated.clone(), nil
+}
+
+// matchScenario captures what a match depends on besides its seed: the
+// rules and the fighting robots as they are now
+func (h *RobotHandler) matchScenario(world *RobotStorage, tournament *Tournament, match *TournamentMatch) MatchScenario {
+	scenario := MatchScenario{
+		Tournament:  tournament.ID,
+		Match:       match.ID,
+		Format:      tournament.Format,
+		CombatRules: h.combat.Name(),
+		Rounding:    h.rounding,
+		Accuracy:    h.weather.Current().Accuracy,
+		Rounds:      tournament.Rounds,
+	}
+	for i, id := range []string{match.A, match.B} {
+		participant, seed := tournament.participant(id)
+		side := MatchSide{Participant: id, Seed: seed}
+		for _, robotID := range participant.Robots {
+			robot, err := world.GetRobot(robotID)
+			if err != nil {
+				side.Robots = append(side.Robots, nil)
+				continue
+			}
+			side.Robots = append(side.Robots, &Fighter{ID: robot.ID, Class: robot.Class, Energy: robot.Energy, Armor: robot.Armor})
+		}
+		scenario.Sides[i] = side
+	}
+	return scenario
+}
+
+// playMatch fights the duels of a match with the configured combat rules.
+// Every match rolls its own dice from the tournament's seed, so the results
+// do not depend on when the matches are run.
+func (h *RobotHandler) playMatch(world *RobotStorage, tournament *Tournament, match *TournamentMatch, now time.Time) error {
+	scenario := h.matchScenario(world, tournament, match)
+	if err := playScenario(scenario, tournament.Seed+int64(match.ID), match, nil); err != nil {
+		return err
+	}
+	match.Status = MatchPlayed
+	playedAt := now
+	match.PlayedAt = &playedAt
+	match.scenario = &scenario
+	return nil
+}
+
+// playScenario fights the duels of a match and sets their results and the
+// winner. The same scenario and seed always give the same results. If
+// observe is given, it sees every attack and result as a replay event.
+func playScenario(scenario MatchScenario, seed int64, match *TournamentMatch, observe func(event ReplayEvent)) error {
+	resolver, err := NewSeededCombatResolver(scenario.CombatRules, seed)
+	if err != nil {
+		return err
+	}
+	weather := newDice(seed)
+	if observe == nil {
+		observe = func(ReplayEvent) {}
+	}
+
+	a, b := scenario.Sides[0], scenario.Sides[1]
+	fighter := func(team []*Fighter, i int) *Robot {
+		if i >= len(team) || team[i] == nil {
+			return nil
+		}
+		return team[i].robot()
+	}
+
+	match.Duels = nil
+	var wins, damage [2]int
+	for i := 0; i < max(len(a.Robots), len(b.Robots)); i++ {
+		robotA, robotB := fighter(a.Robots, i), fighter(b.Robots, i)
+		var duel Duel
+		switch {
+		case robotA == nil && robotB == nil:
+			continue
+		case robotB == nil:
+			duel = Duel{A: robotA.ID, Winner: robotA.ID}
+		case robotA == nil:
+			duel = Duel{B: robotB.ID, Winner: robotB.ID}
+		default:
+			duel = Duel{A: robotA.ID, B: robotB.ID}
+			var winner int
+			winner, duel.DamageA, duel.DamageB = simulateBattle(resolver, scenario.Rounding, weather, scenario.Accuracy, robotA, robotB, scenario.Rounds,
+				func(round int, attacker, target *Robot, result CombatResult) {
+					observe(attackEvent(len(match.Duels)+1, round, attacker, target, result))
+				})
+			switch winner {
+			case 1:
+				duel.Winner = robotA.ID
+			case 2:
+				duel.Winner = robotB.ID
+			}
+		}
+		switch {
+		case duel.Winner == "":
+		case duel.Winner == duel.A:
+			wins[0]++
+		default:
+			wins[1]++
+		}
+		damage[0] += duel.DamageA
+		damage[1] += duel.DamageB
+		match.Duels = append(match.Duels, duel)
+		observe(duelEvent(len(match.Duels), duel))
+	}
+
+	match.Winner, match.Tiebreak = "", false
+	switch {
+	case wins[0] > wins[1]:
+		match.Winner = a.Participant
+	case wins[1] > wins[0]:
+		match.Winner = b.Participant
+	case scenario.Format == TournamentBracket:
+		// Somebody has to advance: the side that dealt more damage, else the higher seed
+		match.Tiebreak = true
+		match.Winner = a.Participant
+		if damage[1] > damage[0] || (damage[1] == damage[0] && b.Seed < a.Seed) {
+			match.Winner = b.Participant
+		}
+	}
+	observe(ReplayEvent{Type: ReplayMatchEvent, Winner: match.Winner, Tiebreak: match.Tiebreak})
+	return nil
+}
+
+// CreateTournament opens the registration of a tournament
+func (h *RobotHandler) CreateTournament(c *gin.Context) {
+	request := TournamentRequest{Format: TournamentRoundRobin, Rounds: 50, Seed: 1}
+	if err := bindJSON(c, &request); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if request.Format != TournamentRoundRobin && request.Format != TournamentBracket {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown tournament format %q", request.Format)})
+		return
+	}
+	if request.Rounds < 1 || request.Rounds > maxSimulatedRounds {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("rounds must be between 1 and %d", maxSimulatedRounds)})
+		return
+	}
+
+	tournament := h.tournaments.Create(currentTenant(c), Tournament{
+		Name:   request.Name,
+		Format: request.Format,
+		Rounds: request.Rounds,
+		Seed:   request.Seed,
+	})
+	h.bus(c).PublishCommand(commandID(c), "tournament_created", "", gin.H{"tournament": tournament.ID, "format": tournament.Format})
+	c.JSON(http.StatusCreated, gin.H{"tournament": tournament})
+}
+
+// ListTournaments returns the tournaments of the caller's world
+func (h *RobotHandler) ListTournaments(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"tournaments": h.tournaments.List(currentTenant(c))})
+}
+
+// GetTournament returns a tournament with its standings and the matches
+// still to be played
+func (h *RobotHandler) GetTournament(c *gin.Context) {
+	tournament, exists := h.tournaments.Get(currentTenant(c), c.Param("id"))
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tournament not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"tournament": tournament,
+		"standings":  tournament.Standings(),
+		"upcoming":   tournament.Upcoming(),
+	})
+}
+
+// RegisterParticipant adds a robot or a team to a tournament whose
+// registration is open
+func (h *RobotHandler) RegisterParticipant(c *gin.Context) {
+	var participant Participant
+	if err := bindJSON(c, &participant); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	for _, id := range participant.Robots {
+		if _, err := h.world(c).GetRobot(id); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Robot %s not found", id)})
+			return
+		}
+	}
+
+	tournament, err := h.tournaments.Update(currentTenant(c), c.Param("id"), func(tournament *Tournament) error {
+		return tournament.register(participant)
+	})
+	if errors.Is(err, ErrTournamentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tournament not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	h.bus(c).PublishCommand(commandID(c), "tournament_participant_registered", "", gin.H{"tournament": tournament.ID, "participant": participant.ID})
+	c.JSON(http.StatusCreated, gin.H{"tournament": tournament})
+}
+
+// StartTournament closes the registration and schedules the matches
+func (h *RobotHandler) StartTournament(c *gin.Context) {
+	tournament, err := h.tournaments.Update(currentTenant(c), c.Param("id"), (*Tournament).start)
+	if errors.Is(err, ErrTournamentNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tournament not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	h.bus(c).PublishCommand(commandID(c), "tournament_started", "", gin.H{"tournament": tournament.ID, "matches": len(tournament.Matches)})
+	c.JSON(http.StatusOK, gin.H{"tournament": tournament, "upcoming": tournament.Upcoming()})
+}
+
+// RunTournament plays the scheduled matches one after another as a task,
+// all of them or the next ?matches=n
+func (h *RobotHandler) RunTournament(c *gin.Context) {
+	limit := -1
+	if value := c.Query("matches"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "matches must be a positive number"})
+			return
+		}
+		limit = parsed
+	}
+	if _, exists := h.tournaments.Get(currentTenant(c), c.Param("id")); !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tournament not found"})
+		return
+	}
+
+	task := h.tasks.CreateFor(currentTenant(c), TournamentTask, requestPriority(c))
+	h.tasks.Update(task.ID, func(task *Task) {
+		task.Status = TaskRunning
+	})
+	ctx := h.tasks.Context(task.ID)
+	var played []TournamentMatch
+	tournament, err := h.tournaments.Update(currentTenant(c), c.Param("id"), func(tournament *Tournament) error {
+		if tournament.Status != TournamentRunning {
+			return errors.New("The tournament is not running")
+		}
+		// Bracket matches become playable as earlier ones finish
+		for i := 0; i < len(tournament.Matches) && limit != 0 && ctx.Err() == nil; i++ {
+			match := &tournament.Matches[i]
+			if match.Status != MatchScheduled {
+				continue
+			}
+			if err := h.playMatch(h.world(c), tournament, match, h.world(c).Clock().Now().UTC()); err != nil {
+				return err
+			}
+			advance(tournament.Matches, i)
+			played = append(played, *match)
+			limit--
+		}
+		tournament.finish()
+		return nil
+	})
+
+	h.tasks.Update(task.ID, func(task *Task) {
+		task.Status = TaskSucceeded
+		switch {
+		case err != nil:
+			task.Status = TaskFailed
+			task.Error = err.Error()
+		case ctx.Err() != nil:
+			task.Status = TaskCancelled
+		}
+		task.Progress = 1
+		task.Result = gin.H{"tournament": tournament.ID, "played": len(played)}
+	})
+	if err != nil {
+		updateFailed(c, err)
+		return
+	}
+
+	for _, match := range played {
+		h.bus(c).PublishCommand(commandID(c), "tournament_match_played", "", gin.H{
+			"tournament": tournament.ID, "match": match.ID, "a": match.A, "b": match.B, "winner": match.Winner,
+		})
+	}
+	if tournament.Status == TournamentFinished && len(played) > 0 {
+		h.bus(c).PublishCommand(commandID(c), "tournament_finished", "", gin.H{"tournament": tournament.ID, "champion": tournament.Champion})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"task":       task.ID,
+		"played":     played,
+		"tournament": tournament,
+		"standings":  tournament.Standings(),
+		"upcoming":   tournament.Upcoming(),
+	})
+}
@@ -0,0 +1,214 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTournamentRouter(t *testing.T) (*gin.Engine, *RobotHandler) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	for _, robot := range []*Robot{
+		{ID: "tank", Energy: 100, Armor: 60},
+		{ID: "scout", Energy: 30},
+		{ID: "drone", Energy: 10},
+	} {
+		require.NoError(t, storage.CreateRobot(robot))
+	}
+	handler := NewRobotHandler(storage)
+	router := gin.New()
+	router.GET("/tournaments", handler.ListTournaments)
+	router.POST("/tournaments", handler.CreateTournament)
+	router.GET("/tournaments/:id", handler.GetTournament)
+	router.POST("/tournaments/:id/participants", handler.RegisterParticipant)
+	router.POST("/tournaments/:id/start", handler.StartTournament)
+	router.POST("/tournaments/:id/run", handler.RunTournament)
+	return router, handler
+}
+
+type tournamentResponse struct {
+	Task       string            `json:"task"`
+	Played     []TournamentMatch `json:"played"`
+	Tournament Tournament        `json:"tournament"`
+	Standings  []Standing        `json:"standings"`
+	Upcoming   []TournamentMatch `json:"upcoming"`
+}
+
+func tournamentRequest(t *testing.T, router *gin.Engine, method, path, body string, status int) tournamentResponse {
+	w := send(router, method, path, body)
+	require.Equal(t, status, w.Code, w.Body.String())
+	var response tournamentResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	return response
+}
+
+func TestRoundRobinSchedule(t *testing.T) {
+	matches := roundRobinSchedule([]string{"a", "b", "c", "d"})
+	require.Len(t, matches, 6)
+	pairs := map[[2]string]bool{}
+	for _, match := range matches {
+		pairs[[2]string{min(match.A, match.B), max(match.A, match.B)}] = true
+	}
+	assert.Len(t, pairs, 6, "everyone meets everyone once")
+	for round := 1; round <= 3; round++ {
+		seen := map[string]bool{}
+		for _, match := range matches {
+			if match.Round == round {
+				assert.False(t, seen[match.A] || seen[match.B], "nobody plays twice in round %d", round)
+				seen[match.A], seen[match.B] = true, true
+			}
+		}
+		assert.Len(t, seen, 4)
+	}
+
+	// With an odd number, somebody sits out every round
+	assert.Len(t, roundRobinSchedule([]string{"a", "b", "c"}), 3)
+}
+
+func TestBracketSchedule(t *testing.T) {
+	assert.Equal(t, []int{0, 7, 3, 4, 1, 6, 2, 5}, bracketSeeds(8))
+
+	matches := bracketSchedule([]string{"a", "b", "c"})
+	require.Len(t, matches, 3)
+	assert.Equal(t, MatchBye, matches[0].Status)
+	assert.Equal(t, TournamentMatch{ID: 2, Round: 1, A: "b", B: "c", Status: MatchScheduled, next: 2, nextSlot: 1}, matches[1])
+	assert.Equal(t, "a", matches[2].A)
+	assert.Equal(t, MatchWaiting, matches[2].Status)
+}
+
+func TestRoundRobinTournament(t *testing.T) {
+	router, handler := setupTournamentRouter(t)
+	created := tournamentRequest(t, router, "POST", "/tournaments", `{"name": "Spring Cup", "seed": 3}`, http.StatusCreated)
+	id := created.Tournament.ID
+	assert.Equal(t, TournamentRoundRobin, created.Tournament.Format)
+	assert.Equal(t, TournamentRegistration, created.Tournament.Status)
+
+	for _, participant := range []string{
+		`{"id": "heavy", "robots": ["tank"]}`,
+		`{"id": "light", "name": "Light Team", "robots": ["scout", "drone"]}`,
+		`{"id": "solo", "robots": ["robot1"]}`,
+	} {
+		tournamentRequest(t, router, "POST", "/tournaments/"+id+"/participants", participant, http.StatusCreated)
+	}
+	started := tournamentRequest(t, router, "POST", "/tournaments/"+id+"/start", "", http.StatusOK)
+	assert.Len(t, started.Upcoming, 3)
+
+	// The first match is played on its own
+	first := tournamentRequest(t, router, "POST", "/tournaments/"+id+"/run?matches=1", "", http.StatusOK)
+	require.Len(t, first.Played, 1)
+	assert.Len(t, first.Upcoming, 2)
+	task, _ := handler.tasks.Get(first.Task)
+	assert.Equal(t, TaskSucceeded, task.Status)
+
+	rest := tournamentRequest(t, router, "POST", "/tournaments/"+id+"/run", "", http.StatusOK)
+	assert.Len(t, rest.Played, 2)
+	assert.Empty(t, rest.Upcoming)
+	assert.Equal(t, TournamentFinished, rest.Tournament.Status)
+	assert.Equal(t, rest.Standings[0].Participant, rest.Tournament.Champion)
+
+	points := 0
+	for _, standing := range rest.Standings {
+		assert.Equal(t, 2, standing.Played)
+		assert.Equal(t, standing.Wins*pointsWin+standing.Draws*pointsDraw, standing.Points)
+		points += standing.Points
+	}
+	assert.GreaterOrEqual(t, points, 3*pointsDraw*2)
+
+	// The team has a second robot, so its second duel is won without a fight
+	for _, match := range rest.Tournament.Matches {
+		if match.A == "light" || match.B == "light" {
+			require.Len(t, match.Duels, 2)
+			assert.Equal(t, "drone", match.Duels[1].Winner)
+		}
+	}
+
+	// The seed makes the results reproducible
+	again := tournamentRequest(t, router, "POST", "/tournaments", `{"name": "Rematch", "seed": 3}`, http.StatusCreated)
+	for _, participant := range []string{
+		`{"id": "heavy", "robots": ["tank"]}`,
+		`{"id": "light", "robots": ["scout", "drone"]}`,
+		`{"id": "solo", "robots": ["robot1"]}`,
+	} {
+		tournamentRequest(t, router, "POST", "/tournaments/"+again.Tournament.ID+"/participants", participant, http.StatusCreated)
+	}
+	tournamentRequest(t, router, "POST", "/tournaments/"+again.Tournament.ID+"/start", "", http.StatusOK)
+	rematch := tournamentRequest(t, router, "POST", "/tournaments/"+again.Tournament.ID+"/run", "", http.StatusOK)
+	assert.Equal(t, rest.Standings, rematch.Standings)
+
+	var list struct {
+		Tournaments []Tournament `json:"tournaments"`
+	}
+	json.Unmarshal(send(router, "GET", "/tournaments", "").Body.Bytes(), &list)
+	require.Len(t, list.Tournaments, 2)
+	assert.Equal(t, "Spring Cup", list.Tournaments[0].Name)
+}
+
+func TestBracketTournament(t *testing.T) {
+	router, _ := setupTournamentRouter(t)
+	id := tournamentRequest(t, router, "POST", "/tournaments", `{"name": "Knockout", "format": "bracket"}`, http.StatusCreated).Tournament.ID
+	for _, participant := range []string{
+		`{"id": "heavy", "robots": ["tank"]}`,
+		`{"id": "scout", "robots": ["scout"]}`,
+		`{"id": "drone", "robots": ["drone"]}`,
+	} {
+		tournamentRequest(t, router, "POST", "/tournaments/"+id+"/participants", participant, http.StatusCreated)
+	}
+
+	// The top seed has a bye, the final waits for the other semi-final
+	started := tournamentRequest(t, router, "POST", "/tournaments/"+id+"/start", "", http.StatusOK)
+	require.Len(t, started.Upcoming, 2)
+	assert.Equal(t, MatchScheduled, started.Upcoming[0].Status)
+	assert.Equal(t, MatchWaiting, started.Upcoming[1].Status)
+	assert.Equal(t, "heavy", started.Upcoming[1].A)
+
+	semi := tournamentRequest(t, router, "POST", "/tournaments/"+id+"/run?matches=1", "", http.StatusOK)
+	require.Len(t, semi.Played, 1)
+	assert.NotEmpty(t, semi.Played[0].Winner, "bracket matches always have a winner")
+	require.Len(t, semi.Upcoming, 1)
+	assert.Equal(t, MatchScheduled, semi.Upcoming[0].Status)
+	assert.Equal(t, semi.Played[0].Winner, semi.Upcoming[0].B)
+
+	final := tournamentRequest(t, router, "POST", "/tournaments/"+id+"/run", "", http.StatusOK)
+	assert.Equal(t, TournamentFinished, final.Tournament.Status)
+	assert.Equal(t, final.Played[0].Winner, final.Tournament.Champion)
+	assert.Equal(t, final.Tournament.Champion, final.Standings[0].Participant)
+	assert.False(t, final.Standings[0].Eliminated)
+	assert.True(t, final.Standings[1].Eliminated)
+	assert.True(t, final.Standings[2].Eliminated)
+
+	got := tournamentRequest(t, router, "GET", "/tournaments/"+id, "", http.StatusOK)
+	assert.Equal(t, final.Tournament.Champion, got.Tournament.Champion)
+}
+
+func TestTournamentErrors(t *testing.T) {
+	router, _ := setupTournamentRouter(t)
+	send := func(method, path, body string) int {
+		return send(router, method, path, body).Code
+	}
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/tournaments", `{"name": "Cup", "format": "swiss"}`))
+	assert.Equal(t, http.StatusBadRequest, send("POST", "/tournaments", `{"name": "Cup", "rounds": 5000}`))
+	assert.Equal(t, http.StatusNotFound, send("GET", "/tournaments/nope", ""))
+	assert.Equal(t, http.StatusNotFound, send("POST", "/tournaments/nope/run", ""))
+
+	id := tournamentRequest(t, router, "POST", "/tournaments", `{"name": "Cup"}`, http.StatusCreated).Tournament.ID
+	path := "/tournaments/" + id
+	assert.Equal(t, http.StatusBadRequest, send("POST", path+"/participants", `{"id": "ghosts", "robots": ["ghost"]}`))
+	assert.Equal(t, http.StatusBadRequest, send("POST", path+"/participants", `{"id": "empty", "robots": []}`))
+	assert.Equal(t, http.StatusCreated, send("POST", path+"/participants", `{"id": "heavy", "robots": ["tank"]}`))
+	assert.Equal(t, http.StatusConflict, send("POST", path+"/participants", `{"id": "heavy", "robots": ["scout"]}`))
+	assert.Equal(t, http.StatusConflict, send("POST", path+"/participants", `{"id": "twice", "robots": ["tank"]}`))
+	assert.Equal(t, http.StatusConflict, send("POST", path+"/start", ""), "one participant is not enough")
+	assert.Equal(t, http.StatusConflict, send("POST", path+"/run", ""), "not started yet")
+
+	assert.Equal(t, http.StatusCreated, send("POST", path+"/participants", `{"id": "light", "robots": ["scout"]}`))
+	assert.Equal(t, http.StatusOK, send("POST", path+"/start", ""))
+	assert.Equal(t, http.StatusConflict, send("POST", path+"/start", ""))
+	assert.Equal(t, http.StatusConflict, send("POST", path+"/participants", `{"id": "late", "robots": ["drone"]}`))
+	assert.Equal(t, http.StatusBadRequest, send("POST", path+"/run?matches=0", ""))
+}
@@ -0,0 +1,410 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Ownership transfer states
+const (
+	TransferPending  = "pending"
+	TransferAccepted = "accepted"
+	TransferRejected = "rejected"
+	TransferExpired  = "expired"
+)
+
+// transferTTL is how long the receiving party has to accept a transfer
+const transferTTL = 24 * time.Hour
+
+// ErrTransferNotFound is returned for unknown transfer IDs
+var ErrTransferNotFound = errors.New("transfer not found")
+
+// ErrTransferResolved is returned for transfers that are not pending anymore
+var ErrTransferResolved = errors.New("transfer is not pending anymore")
+
+// OwnershipTransfer is an offer to hand a robot over to another principal,
+// possibly of another tenant. It takes effect once the receiving party
+// accepts it.
+type OwnershipTransfer struct {
+	ID         string    `json:"id"`
+	RobotID    string    `json:"robot_id"`
+	From       string    `json:"from"`
+	To         string    `json:"to"`
+	FromTenant string    `json:"from_tenant,omitempty"`
+	ToTenant   string    `json:"to_tenant,omitempty"`
+	Status     string    `json:"status"`
+	Revoked    []string  `json:"revoked,omitempty"` // grantees whose permissions ended with the transfer
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// TransferRequest is the payload of the transfer endpoint
+type TransferRequest struct {
+	To string `json:"to" binding:"required"` // principal ID of the new owner
+}
+
+// TransferStore keeps track of ownership transfers
+type TransferStore struct {
+	transfers map[string]*OwnershipTransfer
+	ids       IDGenerator
+	clock     Clock
+	mutex     sync.Mutex
+}
+
+// NewTransferStore creates an empty transfer store dating its transfers by
+// the clock
+func NewTransferStore(clock Clock) *TransferStore {
+	return &TransferStore{transfers: make(map[string]*OwnershipTransfer), ids: UUIDGenerator{}, clock: clock}
+}
+
+// expire marks a transfer whose time ran out; callers must hold the lock
+func (s *TransferStore) expire(transfer *OwnershipTransfer) {
+	if transfer.Status == TransferPending && !s.clock.Now().Before(transfer.ExpiresAt) {
+		transfer.Status = TransferExpired
+	}
+}
+
+// Offer registers a pending transfer. A robot can have one pending transfer
+// at a time.
+func (s *TransferStore) Offer(transfer OwnershipTransfer) (OwnershipTransfer, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, existing := range s.transfers {
+		s.expire(existing)
+		if existing.Status == TransferPending && existing.RobotID == transfer.RobotID && existing.FromTenant == transfer.FromTenant {
+			return OwnershipTransfer{}, errors.New("A transfer of this robot is already pending")
+		}
+	}
+	transfer.ID = s.ids.NewID()
+	transfer.Status = TransferPending
+	transfer.CreatedAt = s.clock.Now().UTC()
+	transfer.ExpiresAt = transfer.CreatedAt.Add(transferTTL)
+	s.transfers[transfer.ID] = &transfer
+	return transfer, nil
+}
+
+// Get returns a copy of a transfer
+func (s *TransferStore) Get(id string) (OwnershipTransfer, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	transfer, exists := s.transfers[id]
+	if !exists {
+		return OwnershipTransfer{}, false
+	}
+	s.expire(transfer)
+	return *transfer, true
+}
+
+// Of returns the transfers a principal offered or was offered, newest first
+func (s *TransferStore) Of(principalID string) []OwnershipTransfer {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	transfers := []OwnershipTransfer{}
+	for _, transfer := range s.transfers {
+		s.expire(transfer)
+		if transfer.From == principalID || transfer.To == principalID {
+			transfers = append(transfers, *transfer)
+		}
+	}
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].CreatedAt.After(transfers[j].CreatedAt)
+	})
+	return transfers
+}
+
+// Resolve changes a pending transfer under the store's lock, so it is
+// resolved only once. If resolve fails the transfer stays pending.
+func (s *TransferStore) Resolve(id string, resolve func(transfer *OwnershipTransfer) error) (OwnershipTransfer, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	transfer, exists := s.transfers[id]
+	if !exists {
+		return OwnershipTransfer{}, ErrTransferNotFound
+	}
+	s.expire(transfer)
+	if transfer.Status != TransferPending {
+		return *transfer, ErrTransferResolved
+	}
+	resolved := *transfer
+	if err := resolve(&resolved); err != nil {
+		return *transfer, err
+	}
+	*transfer = resolved
+	return resolved, nil
+}
+
+// recipientTenant returns the tenant a principal belongs to. API key names
+// carry their tenant ("lab1/alice"); principals of other providers stay in
+// the current tenant.
+func recipientTenant(principalID, current string) string {
+	if strings.Contains(principalID, ":") {
+		return current
+	}
+	return tenantOfName(principalID)
+}
+
+// TransferOwnership offers the robot in the :id path parameter to another
+// principal. Only the owner can offer a robot, and the transfer takes
+// effect once the receiving party accepts it.
+func (h *RobotHandler) TransferOwnership(c *gin.Context) {
+	principal := currentPrincipal(c)
+	if principal == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication is disabled"})
+		return
+	}
+	id := c.Param("id")
+	robot, err := h.world(c).GetRobot(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Robot not found"})
+		return
+	}
+	if principal.Provider == "session" || robot.Owner != principal.ID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the owner can transfer the robot"})
+		return
+	}
+	if robot.Decommissioned {
+		c.JSON(http.StatusConflict, gin.H{"error": "Decommissioned robots cannot change owners"})
+		return
+	}
+
+	var request TransferRequest
+	if err := bindJSON(c, &request); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if request.To == principal.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The robot already belongs to this principal"})
+		return
+	}
+	// As for grants, plain names must belong to a configured API key
+	if !strings.Contains(request.To, ":") && !h.keys.Has(request.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unknown recipient %q", request.To)})
+		return
+	}
+	toTenant := recipientTenant(request.To, currentTenant(c))
+	if _, exists := h.tenants.Get(toTenant); toTenant != "" && !exists {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown tenant " + toTenant})
+		return
+	}
+	if toTenant != currentTenant(c) && len(robot.Inventory) > 0 {
+		// Items belong to their world and cannot leave it
+		c.JSON(http.StatusConflict, gin.H{"error": "Robots carrying items cannot move to another tenant"})
+		return
+	}
+
+	transfer, err := h.transfers.Offer(OwnershipTransfer{
+		RobotID:    id,
+		From:       principal.ID,
+		To:         request.To,
+		FromTenant: currentTenant(c),
+		ToTenant:   toTenant,
+	})
+	if err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	h.world(c).AddCommandAction(id, requestOrigin(c), "ownership", fmt.Sprintf("Offered ownership to %s", request.To),
+		gin.H{"transfer": transfer.ID, "from": transfer.From, "to": transfer.To, "status": transfer.Status})
+	h.bus(c).PublishCommand(commandID(c), "ownership_transfer_offered", id, gin.H{"transfer": transfer.ID, "to": transfer.To})
+
+	baseURL := requestBaseURL(c)
+	c.JSON(http.StatusAccepted, gin.H{
+		"transfer": transfer,
+		"links": []Link{
+			{Rel: "accept", Href: fmt.Sprintf("%s/transfers/%s/accept", baseURL, transfer.ID)},
+			{Rel: "reject", Href: fmt.Sprintf("%s/transfers/%s/reject", baseURL, transfer.ID)},
+		},
+	})
+}
+
+// ListTransfers returns the transfers the caller offered or was offered
+func (h *RobotHandler) ListTransfers(c *gin.Context) {
+	principal := currentPrincipal(c)
+	if principal == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication is disabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"transfers": h.transfers.Of(principal.ID)})
+}
+
+// AcceptTransfer lets the receiving party take over the robot. The previous
+// owner's delegated permissions are revoked; a robot offered to another
+// tenant moves into that tenant's world.
+func (h *RobotHandler) AcceptTransfer(c *gin.Context) {
+	principal := currentPrincipal(c)
+	if principal == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication is disabled"})
+		return
+	}
+
+	transfer, err := h.transfers.Resolve(c.Param("transferId"), func(transfer *OwnershipTransfer) error {
+		if transfer.To != principal.ID || principal.Provider == "session" {
+			return errTransferForbidden
+		}
+		revoked, err := h.transferRobot(*transfer)
+		if err != nil {
+			return err
+		}
+		transfer.Status = TransferAccepted
+		transfer.Revoked = revoked
+		return nil
+	})
+	if err != nil {
+		transferFailed(c, err)
+		return
+	}
+
+	data := gin.H{"transfer": transfer.ID, "from": transfer.From, "to": transfer.To, "status": transfer.Status, "revoked": transfer.Revoked}
+	if transfer.FromTenant != transfer.ToTenant {
+		data["from_tenant"] = transfer.FromTenant
+		data["to_tenant"] = transfer.ToTenant
+	}
+	h.worldOf(transfer.ToTenant).AddCommandAction(transfer.RobotID, requestOrigin(c), "ownership",
+		fmt.Sprintf("Ownership transferred from %s to %s", transfer.From, transfer.To), data)
+	h.busOf(transfer.FromTenant).PublishCommand(commandID(c), "ownership_transferred", transfer.RobotID, data)
+	if transfer.FromTenant != transfer.ToTenant {
+		h.busOf(transfer.ToTenant).PublishCommand(commandID(c), "ownership_transferred", transfer.RobotID, data)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"transfer": transfer,
+		"links":    []Link{{Rel: "robot", Href: fmt.Sprintf("%s/robot/%s/status", requestBaseURL(c), transfer.RobotID)}},
+	})
+}
+
+// RejectTransfer ends a pending transfer: the receiving party declines it or
+// the owner withdraws it
+func (h *RobotHandler) RejectTransfer(c *gin.Context) {
+	principal := currentPrincipal(c)
+	if principal == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication is disabled"})
+		return
+	}
+
+	transfer, err := h.transfers.Resolve(c.Param("transferId"), func(transfer *OwnershipTransfer) error {
+		if (transfer.To != principal.ID && transfer.From != principal.ID) || principal.Provider == "session" {
+			return errTransferForbidden
+		}
+		transfer.Status = TransferRejected
+		return nil
+	})
+	if err != nil {
+		transferFailed(c, err)
+		return
+	}
+
+	data := gin.H{"transfer": transfer.ID, "from": transfer.From, "to": transfer.To, "status": transfer.Status, "by": principal.ID}
+	h.worldOf(transfer.FromTenant).AddCommandAction(transfer.RobotID, requestOrigin(c), "ownership",
+		fmt.Sprintf("Ownership transfer to %s rejected by %s", transfer.To, principal.ID), data)
+	h.busOf(transfer.FromTenant).PublishCommand(commandID(c), "ownership_transfer_rejected", transfer.RobotID, data)
+	c.JSON(http.StatusOK, gin.H{"transfer": transfer})
+}
+
+// errTransferForbidden is returned when the caller is not a party of the
+// transfer
+var errTransferForbidden = errors.New("Not a party of this transfer")
+
+// transferFailed answers a failed transfer resolution
+func transferFailed(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrTransferNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+	case errors.Is(err, errTransferForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, ErrTransferResolved):
+		c.JSON(http.StatusConflict, gin.H{"error": "Transfer is not pending anymore"})
+	default:
+		updateFailed(c, err)
+	}
+}
+
+// transferRobot hands the robot of a transfer over to the new owner and
+// revokes all grants on it. Robots changing tenants are taken out of the old
+// world and added to the new one, at a spawn point if their cell is blocked
+// there.
+func (h *RobotHandler) transferRobot(transfer OwnershipTransfer) ([]string, error) {
+	from := h.worldOf(transfer.FromTenant)
+	// Checked under the world's lock, so the robot cannot change owners or
+	// be decommissioned in between
+	transferable := func(robot *Robot) error {
+		if robot.Owner != transfer.From {
+			return errors.New("The robot changed owners in the meantime")
+		}
+		if robot.Decommissioned {
+			return errors.New("Decommissioned robots cannot change owners")
+		}
+		return nil
+	}
+
+	if transfer.FromTenant == transfer.ToTenant {
+		_, err := from.UpdateRobot(transfer.RobotID, func(robot *Robot) error {
+			if err := transferable(robot); err != nil {
+				return err
+			}
+			robot.Owner = transfer.To
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		return from.RevokeAllPermissions(transfer.RobotID), nil
+	}
+
+	grants := from.GetPermissions(transfer.RobotID)
+	removed, err := from.RemoveRobotIf(transfer.RobotID, func(robot *Robot) error {
+		if err := transferable(robot); err != nil {
+			return err
+		}
+		if len(robot.Inventory) > 0 {
+			return errors.New("Robots carrying items cannot move to another tenant")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	to := h.worldOf(transfer.ToTenant)
+	moved := removed.clone()
+	moved.Owner = transfer.To
+	if to.GetLayout().Blocked(moved.Position) {
+		if spawn, ok := to.NextSpawnPoint(); ok {
+			moved.Position = spawn
+		}
+	}
+	if err := to.CreateRobot(moved); err != nil {
+		// Put the robot back where it was
+		from.CreateRobot(removed)
+		for grantee, permissions := range grants {
+			from.GrantPermissions(removed.ID, grantee, permissions)
+		}
+		var limitErr *LimitError
+		if errors.As(err, &limitErr) {
+			return nil, fmt.Errorf("The receiving tenant cannot take the robot: %w", err)
+		}
+		return nil, errors.New("Robot ID already taken in the receiving tenant")
+	}
+	// The behavior stays behind with the old world
+	h.behaviors.Delete(transfer.FromTenant, removed.ID)
+	return sortedKeys(grants), nil
+}
+
+// sortedKeys returns the keys of a grant map in order
+func sortedKeys(grants map[string][]string) []string {
+	keys := make([]string, 0, len(grants))
+	for key := range grants {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,154 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupTransferServer(t *testing.T) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	config := DefaultConfig()
+	config.APIKeys = "alice=alice-key,bob=bob-key,lab1/carol=carol-key"
+	config.Tenants = []string{"lab1"}
+	config.CacheTTL = 0
+	server, err := New(config)
+	require.NoError(t, err)
+	return server.Router()
+}
+
+// offerTransfer offers a robot and returns the transfer's ID
+func offerTransfer(t *testing.T, router *gin.Engine, key, robotID, to string) string {
+	w := sendAs(router, key, "", "POST", "/robot/"+robotID+"/transfer-ownership", `{"to": "`+to+`"}`)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	var offered struct {
+		Transfer OwnershipTransfer `json:"transfer"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &offered))
+	assert.Equal(t, TransferPending, offered.Transfer.Status)
+	return offered.Transfer.ID
+}
+
+func TestTransferOwnership(t *testing.T) {
+	router := setupTransferServer(t)
+	require.Equal(t, http.StatusCreated, sendAs(router, "alice-key", "", "POST", "/robots", `{"id": "rover"}`).Code)
+	require.Equal(t, http.StatusOK, sendAs(router, "alice-key", "", "POST", "/robot/rover/permissions", `{"grantee": "lab1/carol", "permissions": ["move"]}`).Code)
+
+	// Only the owner can offer the robot, and only to known principals
+	assert.Equal(t, http.StatusForbidden, sendAs(router, "bob-key", "", "POST", "/robot/rover/transfer-ownership", `{"to": "bob"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, sendAs(router, "alice-key", "", "POST", "/robot/rover/transfer-ownership", `{"to": "mallory"}`).Code)
+
+	id := offerTransfer(t, router, "alice-key", "rover", "bob")
+	assert.Equal(t, http.StatusConflict, sendAs(router, "alice-key", "", "POST", "/robot/rover/transfer-ownership", `{"to": "bob"}`).Code,
+		"one pending transfer per robot")
+	assert.Contains(t, sendAs(router, "bob-key", "", "GET", "/transfers", "").Body.String(), id)
+
+	// Only the receiving party can accept
+	assert.Equal(t, http.StatusForbidden, sendAs(router, "alice-key", "", "POST", "/transfers/"+id+"/accept", "").Code)
+	w := sendAs(router, "bob-key", "", "POST", "/transfers/"+id+"/accept", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var accepted struct {
+		Transfer OwnershipTransfer `json:"transfer"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &accepted))
+	assert.Equal(t, TransferAccepted, accepted.Transfer.Status)
+	assert.Equal(t, []string{"lab1/carol"}, accepted.Transfer.Revoked)
+	assert.Equal(t, http.StatusConflict, sendAs(router, "bob-key", "", "POST", "/transfers/"+id+"/accept", "").Code)
+
+	// The previous owner and the grantees lost control, the history tells why
+	assert.Equal(t, http.StatusForbidden, sendAs(router, "alice-key", "", "POST", "/robot/rover/move", `{"direction": "up"}`).Code)
+	assert.Equal(t, http.StatusOK, sendAs(router, "bob-key", "", "POST", "/robot/rover/move", `{"direction": "up"}`).Code)
+	var permissions struct {
+		Owner       string              `json:"owner"`
+		Permissions map[string][]string `json:"permissions"`
+	}
+	json.Unmarshal(sendAs(router, "bob-key", "", "GET", "/robot/rover/permissions", "").Body.Bytes(), &permissions)
+	assert.Equal(t, "bob", permissions.Owner)
+	assert.Empty(t, permissions.Permissions)
+	actions := sendAs(router, "bob-key", "", "GET", "/robot/rover/actions?size=20", "").Body.String()
+	assert.Contains(t, actions, "Offered ownership to bob")
+	assert.Contains(t, actions, "Ownership transferred from alice to bob")
+}
+
+func TestDecommissionedRobotsKeepTheirOwner(t *testing.T) {
+	router := setupTransferServer(t)
+	require.Equal(t, http.StatusCreated, sendAs(router, "alice-key", "", "POST", "/robots", `{"id": "rover"}`).Code)
+	id := offerTransfer(t, router, "alice-key", "rover", "bob")
+	require.Equal(t, http.StatusAccepted, sendAs(router, "alice-key", "", "POST", "/robot/rover/decommission", "").Code)
+
+	assert.Equal(t, http.StatusConflict, sendAs(router, "bob-key", "", "POST", "/transfers/"+id+"/accept", "").Code,
+		"a pending offer cannot be accepted anymore")
+	require.Equal(t, http.StatusOK, sendAs(router, "alice-key", "", "POST", "/transfers/"+id+"/reject", "").Code)
+	assert.Equal(t, http.StatusConflict, sendAs(router, "alice-key", "", "POST", "/robot/rover/transfer-ownership", `{"to": "bob"}`).Code)
+	assert.Contains(t, sendAs(router, "alice-key", "", "GET", "/robot/rover/permissions", "").Body.String(), `"owner":"alice"`)
+}
+
+func TestTransferOwnershipToAnotherTenant(t *testing.T) {
+	router := setupTransferServer(t)
+	require.Equal(t, http.StatusCreated, sendAs(router, "alice-key", "", "POST", "/robots", `{"id": "rover"}`).Code)
+
+	// Declined and withdrawn offers change nothing
+	id := offerTransfer(t, router, "alice-key", "rover", "lab1/carol")
+	assert.Equal(t, http.StatusForbidden, sendAs(router, "bob-key", "", "POST", "/transfers/"+id+"/reject", "").Code)
+	assert.Equal(t, http.StatusOK, sendAs(router, "carol-key", "", "POST", "/transfers/"+id+"/reject", "").Code)
+	assert.Equal(t, http.StatusConflict, sendAs(router, "carol-key", "", "POST", "/transfers/"+id+"/accept", "").Code)
+	id = offerTransfer(t, router, "alice-key", "rover", "lab1/carol")
+	assert.Equal(t, http.StatusOK, sendAs(router, "alice-key", "", "POST", "/transfers/"+id+"/reject", "").Code)
+	assert.Equal(t, http.StatusOK, sendAs(router, "alice-key", "", "GET", "/robot/rover/status", "").Code)
+
+	// Accepting moves the robot with its history into the other world
+	id = offerTransfer(t, router, "alice-key", "rover", "lab1/carol")
+	assert.Equal(t, http.StatusOK, sendAs(router, "carol-key", "", "POST", "/transfers/"+id+"/accept", "").Code)
+	assert.Equal(t, http.StatusNotFound, sendAs(router, "alice-key", "", "GET", "/robot/rover/status", "").Code)
+	assert.Equal(t, http.StatusOK, sendAs(router, "carol-key", "", "GET", "/robot/rover/status", "").Code)
+	actions := sendAs(router, "carol-key", "", "GET", "/robot/rover/actions?size=20", "").Body.String()
+	assert.Contains(t, actions, "Robot was created")
+	assert.Contains(t, actions, "Ownership transferred from alice to lab1/carol")
+
+	var changes WorldChanges
+	json.Unmarshal(sendAs(router, "alice-key", "", "GET", "/world/changes?since=1", "").Body.Bytes(), &changes)
+	assert.Equal(t, []string{"rover"}, changes.RemovedRobots)
+}
+
+func TestTransferStoreExpires(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC))
+	store := NewTransferStore(clock)
+	transfer, err := store.Offer(OwnershipTransfer{RobotID: "rover", From: "alice", To: "bob"})
+	require.NoError(t, err)
+
+	clock.Advance(transferTTL)
+	_, err = store.Resolve(transfer.ID, func(transfer *OwnershipTransfer) error { return nil })
+	assert.ErrorIs(t, err, ErrTransferResolved)
+	transfer, _ = store.Get(transfer.ID)
+	assert.Equal(t, TransferExpired, transfer.Status)
+
+	// A new offer can be made
+	_, err = store.Offer(OwnershipTransfer{RobotID: "rover", From: "alice", To: "bob"})
+	assert.NoError(t, err)
+}
+
+func TestTransferChecksTheOwnerWhenItTakesEffect(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	_, err := handler.tenants.Create("lab1")
+	require.NoError(t, err)
+	_, err = storage.UpdateRobot("robot1", func(robot *Robot) error {
+		robot.Owner = "mallory" // took over after alice offered the robot
+		return nil
+	})
+	require.NoError(t, err)
+
+	for _, toTenant := range []string{"", "lab1"} {
+		_, err := handler.transferRobot(OwnershipTransfer{RobotID: "robot1", From: "alice", To: "bob", ToTenant: toTenant})
+		assert.Error(t, err, toTenant)
+		robot, err := storage.GetRobot("robot1")
+		require.NoError(t, err)
+		assert.Equal(t, "mallory", robot.Owner)
+	}
+}
@@ -0,0 +1,109 @@
+package robotapi
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// WeatherCondition describes how a type of weather affects the world
+type WeatherCondition struct {
+	Name     string `json:"name"`
+	MoveCost int    `json:"move_cost"` // energy needed per step
+	Accuracy int    `json:"accuracy"`  // chance in percent that a hit lands
+}
+
+// weatherConditions lists all known weather types
+var weatherConditions = map[string]WeatherCondition{
+	"sunny": {Name: "sunny", MoveCost: 0, Accuracy: 100}, // moving is free in good weather
+	"rain":  {Name: "rain", MoveCost: 1, Accuracy: 85},
+	"storm": {Name: "storm", MoveCost: 2, Accuracy: 60},
+}
+
+// weatherOrder keeps random weather selection deterministic for a given seed
+var weatherOrder = []string{"sunny", "rain", "storm"}
+
+// Weather holds the current global weather and changes it over time
+type Weather struct {
+	current     WeatherCondition
+	since       time.Time
+	changeEvery int64
+	dice        *dice
+	events      *EventBus
+	mutex       sync.RWMutex
+}
+
+// NewWeather creates sunny weather that changes randomly every changeEvery
+// simulation ticks. A changeEvery of 0 keeps the weather fixed.
+func NewWeather(events *EventBus, changeEvery int64, seed int64) *Weather {
+	return newWeather(events, changeEvery, newDice(seed))
+}
+
+// NewRandomWeather creates weather like NewWeather that draws from the
+// weather stream of the random service
+func NewRandomWeather(events *EventBus, changeEvery int64, random *RandomService) *Weather {
+	return newWeather(events, changeEvery, random.stream(RandomWeather))
+}
+
+// newWeather dates the weather with the clock of the bus, so Since matches
+// the time of the weather_changed events
+func newWeather(events *EventBus, changeEvery int64, dice *dice) *Weather {
+	return &Weather{
+		current:     weatherConditions["sunny"],
+		since:       events.Clock().Now(),
+		changeEvery: changeEvery,
+		dice:        dice,
+		events:      events,
+	}
+}
+
+// Current returns the active weather condition
+func (w *Weather) Current() WeatherCondition {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.current
+}
+
+// Since returns when the current weather started
+func (w *Weather) Since() time.Time {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	return w.since
+}
+
+// Set changes the weather to the named condition
+func (w *Weather) Set(name string) error {
+	condition, exists := weatherConditions[name]
+	if !exists {
+		return fmt.Errorf("unknown weather %q", name)
+	}
+
+	w.mutex.Lock()
+	previous := w.current
+	w.current = condition
+	w.since = w.events.Clock().Now()
+	w.mutex.Unlock()
+
+	if previous.Name != condition.Name {
+		w.events.Publish("weather_changed", "", map[string]string{
+			"from": previous.Name,
+			"to":   condition.Name,
+		})
+	}
+	return nil
+}
+
+// Tick rolls for a new weather condition every changeEvery ticks.
+// It is registered as a simulation system.
+func (w *Weather) Tick(tick int64) {
+	if w.changeEvery <= 0 || tick%w.changeEvery != 0 {
+		return
+	}
+	next := weatherOrder[w.dice.roll(1, len(weatherOrder))-1]
+	w.Set(next)
+}
+
+// Hits decides whether a hit still lands under the current weather
+func (w *Weather) Hits() bool {
+	return w.dice.roll(1, 100) <= w.Current().Accuracy
+}
@@ -0,0 +1,64 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeatherChangesOnTick(t *testing.T) {
+	events := NewEventBus()
+	weather := NewWeather(events, 2, 3)
+
+	weather.Tick(1)
+	assert.Empty(t, events.Since(0))
+
+	// Roll until the weather actually changes to something else
+	for tick := int64(2); weather.Current().Name == "sunny"; tick += 2 {
+		weather.Tick(tick)
+	}
+
+	changes := events.Since(0)
+	assert.Len(t, changes, 1)
+	assert.Equal(t, "weather_changed", changes[0].Type)
+	assert.Equal(t, int64(1), changes[0].Sequence)
+}
+
+func TestWeatherSetUnknown(t *testing.T) {
+	weather := NewWeather(NewEventBus(), 0, 0)
+	assert.Error(t, weather.Set("snow"))
+	assert.Equal(t, "sunny", weather.Current().Name)
+}
+
+func TestStormMakesMovingExpensive(t *testing.T) {
+	router, storage := setupTestRouter()
+	handler := NewRobotHandler(storage)
+	handler.weather.Set("storm")
+	router.POST("/storm/:id/move", handler.MoveRobot)
+	router.GET("/storm/weather", handler.GetWeather)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/storm/robot1/move", bytes.NewBufferString(`{"direction": "left"}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, 98, robot.Energy)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/storm/weather", nil)
+	router.ServeHTTP(w, req)
+
+	var response struct {
+		Weather WeatherCondition `json:"weather"`
+	}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "storm", response.Weather.Name)
+	assert.Equal(t, 60, response.Weather.Accuracy)
+}
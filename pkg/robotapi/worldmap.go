@@ -0,0 +1,501 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Errors of the map editor
+var (
+	ErrOutOfBounds  = errors.New("position is outside the map")
+	ErrCellOccupied = errors.New("cell is occupied")
+	ErrCellBlocked  = errors.New("cell is blocked by an obstacle")
+)
+
+// Bounds limits the map to a rectangle; both corners are part of the map
+type Bounds struct {
+	MinX int `json:"min_x"`
+	MinY int `json:"min_y"`
+	MaxX int `json:"max_x"`
+	MaxY int `json:"max_y"`
+}
+
+// Contains reports whether the cell lies within the bounds
+func (b Bounds) Contains(p Position) bool {
+	return p.X >= b.MinX && p.X <= b.MaxX && p.Y >= b.MinY && p.Y <= b.MaxY
+}
+
+// Floor link types
+const (
+	LinkElevator = "elevator"
+	LinkRamp     = "ramp"
+)
+
+// FloorLink connects a cell with the cell directly above it
+type FloorLink struct {
+	Type     string   `json:"type"`
+	Position Position `json:"position"` // the lower end
+}
+
+// MapLayout is the editable structure of the map. Without bounds the map is
+// unlimited; bounds only limit X and Y. With Wrap a bounded map is a torus.
+type MapLayout struct {
+	Bounds      *Bounds     `json:"bounds,omitempty"`
+	Wrap        bool        `json:"wrap,omitempty"` // set by the configuration, not by editing
+	Obstacles   []Position  `json:"obstacles"`
+	SpawnPoints []Position  `json:"spawn_points"`
+	FloorLinks  []FloorLink `json:"floor_links,omitempty"`
+	Zones       []Zone      `json:"zones,omitempty"` // in order of their names
+}
+
+// Torus reports whether moving off an edge of the map leads onto the
+// opposite edge. Only maps with bounds wrap around.
+func (l MapLayout) Torus() bool {
+	return l.Wrap && l.Bounds != nil
+}
+
+// Wrapped returns the cell a position stands for: on a torus coordinates
+// beyond an edge continue from the opposite edge, on other maps the
+// position is unchanged
+func (l MapLayout) Wrapped(p Position) Position {
+	if !l.Torus() {
+		return p
+	}
+	p.X = l.Bounds.MinX + modulo(p.X-l.Bounds.MinX, l.Bounds.MaxX-l.Bounds.MinX+1)
+	p.Y = l.Bounds.MinY + modulo(p.Y-l.Bounds.MinY, l.Bounds.MaxY-l.Bounds.MinY+1)
+	return p
+}
+
+// Step returns the cell a move by delta leads to
+func (l MapLayout) Step(p, delta Position) Position {
+	return l.Wrapped(p.Add(delta))
+}
+
+// Distance returns the Manhattan distance between two positions like
+// Position.DistanceTo, on a torus the shorter way around in X and Y
+func (l MapLayout) Distance(a, b Position) int {
+	if !l.Torus() {
+		return a.DistanceTo(b)
+	}
+	around := func(d, size int) int {
+		d = modulo(d, size)
+		return min(d, size-d)
+	}
+	dz := a.Z - b.Z
+	if dz < 0 {
+		dz = -dz
+	}
+	return around(a.X-b.X, l.Bounds.MaxX-l.Bounds.MinX+1) + around(a.Y-b.Y, l.Bounds.MaxY-l.Bounds.MinY+1) + dz
+}
+
+// modulo returns the non-negative remainder of a divided by n
+func modulo(a, n int) int {
+	return ((a % n) + n) % n
+}
+
+// geometry returns the parts of the layout that distances and moves depend
+// on, without copying obstacles; callers must hold the lock
+func (s *RobotStorage) geometry() MapLayout {
+	return MapLayout{Bounds: s.bounds, Wrap: s.wrap}
+}
+
+// SetWrap makes a bounded map a torus, on which robots leaving one edge
+// enter on the opposite one
+func (s *RobotStorage) SetWrap(enabled bool) {
+	s.mutex.Lock()
+	defer s.unlock()
+	s.wrap = enabled
+	s.layoutChanged()
+}
+
+// Blocked reports whether robots cannot enter the cell
+func (l MapLayout) Blocked(p Position) bool {
+	if l.Bounds != nil && !l.Bounds.Contains(p) {
+		return true
+	}
+	for _, obstacle := range l.Obstacles {
+		if obstacle == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Linked reports whether an elevator or ramp connects the two cells
+func (l MapLayout) Linked(a, b Position) bool {
+	if a.X != b.X || a.Y != b.Y || (a.Z-b.Z != 1 && b.Z-a.Z != 1) {
+		return false
+	}
+	lower := min(a.Z, b.Z)
+	for _, link := range l.FloorLinks {
+		if link.Position == (Position{X: a.X, Y: a.Y, Z: lower}) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanMove checks a single step of a robot. Changing floors needs a link.
+func (l MapLayout) CanMove(from, to Position) error {
+	if l.Blocked(to) {
+		return errors.New("The way is blocked")
+	}
+	if from.Z != to.Z && !l.Linked(from, to) {
+		return errors.New("There is no elevator or ramp here")
+	}
+	return nil
+}
+
+// GetLayout returns a copy of the map layout with obstacles in order
+func (s *RobotStorage) GetLayout() MapLayout {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.layout()
+}
+
+// layout copies the map layout; callers must hold the lock
+func (s *RobotStorage) layout() MapLayout {
+	layout := MapLayout{
+		Wrap:        s.wrap,
+		Obstacles:   make([]Position, 0, len(s.obstacles)),
+		SpawnPoints: append([]Position{}, s.spawnPoints...),
+	}
+	if len(s.zones) > 0 {
+		layout.Zones = s.sortedZones()
+	}
+	if s.bounds != nil {
+		bounds := *s.bounds
+		layout.Bounds = &bounds
+	}
+	for p := range s.obstacles {
+		layout.Obstacles = append(layout.Obstacles, p)
+	}
+	sort.Slice(layout.Obstacles, func(i, j int) bool {
+		return positionLess(layout.Obstacles[i], layout.Obstacles[j])
+	})
+	for p, linkType := range s.floorLinks {
+		layout.FloorLinks = append(layout.FloorLinks, FloorLink{Type: linkType, Position: p})
+	}
+	sort.Slice(layout.FloorLinks, func(i, j int) bool {
+		return positionLess(layout.FloorLinks[i].Position, layout.FloorLinks[j].Position)
+	})
+	return layout
+}
+
+// positionLess orders positions by floor, then X, then Y
+func positionLess(a, b Position) bool {
+	if a.Z != b.Z {
+		return a.Z < b.Z
+	}
+	return a.X < b.X || (a.X == b.X && a.Y < b.Y)
+}
+
+// linkedCell reports whether an elevator or ramp starts or ends on the cell;
+// callers must hold the lock
+func (s *RobotStorage) linkedCell(p Position) bool {
+	_, below := s.floorLinks[Position{X: p.X, Y: p.Y, Z: p.Z - 1}]
+	_, above := s.floorLinks[p]
+	return below || above
+}
+
+// occupied reports whether a robot or a world item is on the cell; callers
+// must hold the lock
+func (s *RobotStorage) occupied(p Position) bool {
+	for _, robot := range s.robots {
+		if robot.Position == p {
+			return true
+		}
+	}
+	for _, item := range s.items {
+		if !item.carried && item.Position == p {
+			return true
+		}
+	}
+	return false
+}
+
+// layoutChanged marks the map layout as modified; callers must hold the lock
+func (s *RobotStorage) layoutChanged() {
+	s.changed()
+	s.layoutVersion = s.version
+}
+
+// PlaceObstacle blocks a cell. Cells with robots or items cannot be blocked.
+func (s *RobotStorage) PlaceObstacle(p Position) error {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if s.bounds != nil && !s.bounds.Contains(p) {
+		return ErrOutOfBounds
+	}
+	if s.occupied(p) || s.linkedCell(p) {
+		return ErrCellOccupied
+	}
+	for _, spawn := range s.spawnPoints {
+		if spawn == p {
+			return ErrCellOccupied
+		}
+	}
+	s.obstacles[p] = true
+	s.layoutChanged()
+	return nil
+}
+
+// RemoveObstacle clears a cell and reports whether it was blocked
+func (s *RobotStorage) RemoveObstacle(p Position) bool {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if !s.obstacles[p] {
+		return false
+	}
+	delete(s.obstacles, p)
+	s.layoutChanged()
+	return true
+}
+
+// SetBounds resizes the map; nil removes all limits. Robots, world items,
+// obstacles and spawn points must all stay inside the new bounds.
+func (s *RobotStorage) SetBounds(bounds *Bounds) error {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if bounds != nil {
+		if bounds.MinX > bounds.MaxX || bounds.MinY > bounds.MaxY {
+			return errors.New("min_x and min_y must not exceed max_x and max_y")
+		}
+		for _, robot := range s.robots {
+			if !bounds.Contains(robot.Position) {
+				return fmt.Errorf("%w: robot %s", ErrCellOccupied, robot.ID)
+			}
+		}
+		for _, item := range s.items {
+			if !item.carried && !bounds.Contains(item.Position) {
+				return fmt.Errorf("%w: item %s", ErrCellOccupied, item.ID)
+			}
+		}
+		for p := range s.obstacles {
+			if !bounds.Contains(p) {
+				return fmt.Errorf("%w: obstacle at (%d,%d)", ErrCellOccupied, p.X, p.Y)
+			}
+		}
+		for _, p := range s.spawnPoints {
+			if !bounds.Contains(p) {
+				return fmt.Errorf("%w: spawn point at (%d,%d)", ErrCellOccupied, p.X, p.Y)
+			}
+		}
+		copied := *bounds
+		bounds = &copied
+	}
+	s.bounds = bounds
+	s.layoutChanged()
+	return nil
+}
+
+// SetSpawnPoints replaces the cells new robots are placed on
+func (s *RobotStorage) SetSpawnPoints(points []Position) error {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	for _, p := range points {
+		if s.bounds != nil && !s.bounds.Contains(p) {
+			return fmt.Errorf("%w: (%d,%d)", ErrOutOfBounds, p.X, p.Y)
+		}
+		if s.obstacles[p] {
+			return fmt.Errorf("%w: (%d,%d)", ErrCellBlocked, p.X, p.Y)
+		}
+	}
+	s.spawnPoints = append([]Position{}, points...)
+	s.nextSpawn = 0
+	s.layoutChanged()
+	return nil
+}
+
+// AddFloorLink connects a cell with the cell above it. Neither end may be
+// blocked.
+func (s *RobotStorage) AddFloorLink(link FloorLink) error {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if link.Type != LinkElevator && link.Type != LinkRamp {
+		return fmt.Errorf("type must be %q or %q", LinkElevator, LinkRamp)
+	}
+	upper := Position{X: link.Position.X, Y: link.Position.Y, Z: link.Position.Z + 1}
+	if s.bounds != nil && !s.bounds.Contains(link.Position) {
+		return ErrOutOfBounds
+	}
+	if s.obstacles[link.Position] || s.obstacles[upper] {
+		return ErrCellBlocked
+	}
+	s.floorLinks[link.Position] = link.Type
+	s.layoutChanged()
+	return nil
+}
+
+// RemoveFloorLink removes the link starting at the cell and reports whether
+// there was one
+func (s *RobotStorage) RemoveFloorLink(p Position) bool {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if _, exists := s.floorLinks[p]; !exists {
+		return false
+	}
+	delete(s.floorLinks, p)
+	s.layoutChanged()
+	return true
+}
+
+// NextSpawnPoint returns the spawn points in turn; false if there are none
+func (s *RobotStorage) NextSpawnPoint() (Position, bool) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if len(s.spawnPoints) == 0 {
+		return Position{}, false
+	}
+	p := s.spawnPoints[s.nextSpawn%len(s.spawnPoints)]
+	s.nextSpawn++
+	return p, true
+}
+
+// mapEditFailed answers a failed map edit: 409 for conflicts with the
+// current world, 400 for invalid input
+func mapEditFailed(c *gin.Context, err error) {
+	status := http.StatusBadRequest
+	if errors.Is(err, ErrCellOccupied) || errors.Is(err, ErrCellBlocked) {
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{"error": err.Error()})
+}
+
+// cellParams reads the :x and :y path parameters and the optional floor
+// from the :z path parameter or the z query parameter
+func (h *RobotHandler) cellParams(c *gin.Context) (Position, bool) {
+	x, errX := strconv.Atoi(c.Param("x"))
+	y, errY := strconv.Atoi(c.Param("y"))
+	z, errZ := 0, error(nil)
+	if floor := c.Param("z") + c.Query("z"); floor != "" {
+		z, errZ = strconv.Atoi(floor)
+	}
+	if errX != nil || errY != nil || errZ != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Coordinates must be integers"})
+		return Position{}, false
+	}
+	if z != 0 && !h.multiFloor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multi-floor maps are disabled"})
+		return Position{}, false
+	}
+	return Position{X: x, Y: y, Z: z}, true
+}
+
+// mapChanged publishes a map change so dashboards can re-render
+func (h *RobotHandler) mapChanged(c *gin.Context, change string, data gin.H) {
+	data["change"] = change
+	h.bus(c).PublishCommand(commandID(c), "map_changed", "", data)
+}
+
+// PlaceObstacle blocks the cell in the path
+func (h *RobotHandler) PlaceObstacle(c *gin.Context) {
+	p, ok := h.cellParams(c)
+	if !ok {
+		return
+	}
+	if err := h.world(c).PlaceObstacle(p); err != nil {
+		mapEditFailed(c, err)
+		return
+	}
+	h.mapChanged(c, "obstacle_placed", gin.H{"position": p})
+	c.JSON(http.StatusOK, h.world(c).GetLayout())
+}
+
+// RemoveObstacle clears the cell in the path
+func (h *RobotHandler) RemoveObstacle(c *gin.Context) {
+	p, ok := h.cellParams(c)
+	if !ok {
+		return
+	}
+	if !h.world(c).RemoveObstacle(p) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No obstacle at this cell"})
+		return
+	}
+	h.mapChanged(c, "obstacle_removed", gin.H{"position": p})
+	c.JSON(http.StatusOK, h.world(c).GetLayout())
+}
+
+// SetBounds resizes the map
+func (h *RobotHandler) SetBounds(c *gin.Context) {
+	var bounds Bounds
+	if err := bindJSON(c, &bounds); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if err := h.world(c).SetBounds(&bounds); err != nil {
+		mapEditFailed(c, err)
+		return
+	}
+	h.mapChanged(c, "resized", gin.H{"bounds": bounds})
+	c.JSON(http.StatusOK, h.world(c).GetLayout())
+}
+
+// RemoveBounds makes the map unlimited again
+func (h *RobotHandler) RemoveBounds(c *gin.Context) {
+	h.world(c).SetBounds(nil)
+	h.mapChanged(c, "resized", gin.H{"bounds": nil})
+	c.JSON(http.StatusOK, h.world(c).GetLayout())
+}
+
+// SetSpawnPoints replaces the spawn points
+func (h *RobotHandler) SetSpawnPoints(c *gin.Context) {
+	var request struct {
+		SpawnPoints []Position `json:"spawn_points" binding:"required"`
+	}
+	if err := bindJSON(c, &request); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if err := h.world(c).SetSpawnPoints(request.SpawnPoints); err != nil {
+		mapEditFailed(c, err)
+		return
+	}
+	h.mapChanged(c, "spawn_points", gin.H{"spawn_points": request.SpawnPoints})
+	c.JSON(http.StatusOK, h.world(c).GetLayout())
+}
+
+// AddFloorLink places an elevator or ramp
+func (h *RobotHandler) AddFloorLink(c *gin.Context) {
+	if !h.multiFloor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multi-floor maps are disabled"})
+		return
+	}
+	var link FloorLink
+	if err := bindJSON(c, &link); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	if err := h.world(c).AddFloorLink(link); err != nil {
+		mapEditFailed(c, err)
+		return
+	}
+	h.mapChanged(c, "floor_link_added", gin.H{"link": link})
+	c.JSON(http.StatusOK, h.world(c).GetLayout())
+}
+
+// RemoveFloorLink removes the elevator or ramp starting at the cell
+func (h *RobotHandler) RemoveFloorLink(c *gin.Context) {
+	p, ok := h.cellParams(c)
+	if !ok {
+		return
+	}
+	if !h.world(c).RemoveFloorLink(p) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No elevator or ramp at this cell"})
+		return
+	}
+	h.mapChanged(c, "floor_link_removed", gin.H{"position": p})
+	c.JSON(http.StatusOK, h.world(c).GetLayout())
+}
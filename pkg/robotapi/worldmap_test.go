@@ -0,0 +1,143 @@
+package robotapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupMapRouter() (*gin.Engine, *RobotStorage, *EventBus) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	events := NewEventBus()
+	handler.SetEventBus(events)
+
+	router := gin.New()
+	router.POST("/robots", handler.CreateRobot)
+	router.POST("/robot/:id/move", handler.MoveRobot)
+	router.GET("/world/map", handler.GetWorldMap)
+	router.PUT("/admin/world/obstacles/:x/:y", handler.PlaceObstacle)
+	router.DELETE("/admin/world/obstacles/:x/:y", handler.RemoveObstacle)
+	router.PUT("/admin/world/bounds", handler.SetBounds)
+	router.DELETE("/admin/world/bounds", handler.RemoveBounds)
+	router.PUT("/admin/world/spawns", handler.SetSpawnPoints)
+	return router, storage, events
+}
+
+func send(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestObstacles(t *testing.T) {
+	router, _, events := setupMapRouter()
+
+	assert.Equal(t, http.StatusOK, send(router, "PUT", "/admin/world/obstacles/0/1", "").Code)
+	assert.Equal(t, http.StatusConflict, send(router, "PUT", "/admin/world/obstacles/0/0", "").Code, "robot1 stands there")
+	assert.Equal(t, http.StatusConflict, send(router, "PUT", "/admin/world/obstacles/1/1", "").Code, "item1 lies there")
+
+	// Robots cannot walk into obstacles
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	assert.Equal(t, http.StatusBadRequest, send(router, "POST", "/robots", `{"position": {"x": 0, "y": 1}}`).Code)
+
+	var worldMap WorldMap
+	json.Unmarshal(send(router, "GET", "/world/map", "").Body.Bytes(), &worldMap)
+	assert.Equal(t, []Position{{X: 0, Y: 1}}, worldMap.Obstacles)
+
+	assert.Equal(t, http.StatusOK, send(router, "DELETE", "/admin/world/obstacles/0/1", "").Code)
+	assert.Equal(t, http.StatusNotFound, send(router, "DELETE", "/admin/world/obstacles/0/1", "").Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+
+	changes := 0
+	for _, event := range events.Since(0) {
+		if event.Type == "map_changed" {
+			changes++
+		}
+	}
+	assert.Equal(t, 2, changes)
+}
+
+func TestBounds(t *testing.T) {
+	router, _, _ := setupMapRouter()
+
+	w := send(router, "PUT", "/admin/world/bounds", `{"min_x": -5, "min_y": -5, "max_x": 5, "max_y": 5}`)
+	assert.Equal(t, http.StatusConflict, w.Code, "robot2 at (10,10) would be outside")
+	assert.Contains(t, w.Body.String(), "robot2")
+
+	assert.Equal(t, http.StatusBadRequest, send(router, "PUT", "/admin/world/bounds", `{"min_x": 5, "max_x": 0}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "PUT", "/admin/world/bounds", `{"min_x": -3, "min_y": 0, "max_x": 10, "max_y": 12}`).Code)
+
+	// The left edge is the end of the world
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "left"}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "left"}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "left"}`).Code)
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot1/move", `{"direction": "left"}`).Code)
+
+	assert.Equal(t, http.StatusOK, send(router, "DELETE", "/admin/world/bounds", "").Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "left"}`).Code)
+}
+
+func TestSpawnPoints(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	storage.PlaceObstacle(Position{X: 3, Y: 3})
+
+	assert.Equal(t, http.StatusConflict, send(router, "PUT", "/admin/world/spawns", `{"spawn_points": [{"x": 3, "y": 3}]}`).Code)
+	assert.Equal(t, http.StatusOK, send(router, "PUT", "/admin/world/spawns", `{"spawn_points": [{"x": 7, "y": 0}, {"x": 0, "y": 7}]}`).Code)
+
+	positions := []Position{}
+	for i := 0; i < 3; i++ {
+		var response struct {
+			Robot Robot `json:"robot"`
+		}
+		json.Unmarshal(send(router, "POST", "/robots", `{}`).Body.Bytes(), &response)
+		positions = append(positions, response.Robot.Position)
+	}
+	assert.Equal(t, []Position{{X: 7, Y: 0}, {X: 0, Y: 7}, {X: 7, Y: 0}}, positions)
+}
+
+func TestTorusGeometry(t *testing.T) {
+	torus := MapLayout{Bounds: &Bounds{MinX: 0, MinY: 0, MaxX: 9, MaxY: 9}, Wrap: true}
+	assert.Equal(t, Position{X: 9, Y: 0}, torus.Wrapped(Position{X: -1, Y: 10}))
+	assert.Equal(t, Position{X: 0, Y: 5, Z: 1}, torus.Step(Position{X: 9, Y: 5, Z: 1}, directions["right"]))
+	assert.Equal(t, 2, torus.Distance(Position{X: 0, Y: 0}, Position{X: 9, Y: 9}))
+	assert.Equal(t, 9, torus.Distance(Position{X: 0, Y: 0}, Position{X: 5, Y: 4}))
+
+	// Only bounded maps wrap around
+	flat := MapLayout{Bounds: torus.Bounds}
+	assert.Equal(t, 18, flat.Distance(Position{X: 0, Y: 0}, Position{X: 9, Y: 9}))
+	assert.Equal(t, Position{X: -1, Y: 10}, MapLayout{Wrap: true}.Wrapped(Position{X: -1, Y: 10}))
+}
+
+func TestTorusMoves(t *testing.T) {
+	router, storage, _ := setupMapRouter()
+	assert.NoError(t, storage.SetBounds(&Bounds{MinX: -8, MinY: 0, MaxX: 12, MaxY: 12}))
+	storage.SetWrap(true)
+
+	// Leaving the bottom edge enters at the top
+	w := send(router, "POST", "/robot/robot1/move", `{"direction": "down"}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	robot, _ := storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 0, Y: 12}, robot.Position)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`).Code)
+	robot, _ = storage.GetRobot("robot1")
+	assert.Equal(t, Position{X: 0, Y: 0}, robot.Position)
+
+	// Hazards reach across the edges as well
+	storage.AddHazard(Hazard{Type: HazardLava, Center: Position{X: 12, Y: 12}, Radius: 1, DamagePerTick: 5})
+	assert.NotNil(t, storage.HazardAt(Position{X: -8, Y: 12}))
+	assert.Nil(t, storage.HazardAt(Position{X: -7, Y: 11}))
+
+	var worldMap WorldMap
+	json.Unmarshal(send(router, "GET", "/world/map", "").Body.Bytes(), &worldMap)
+	assert.True(t, worldMap.Wrap)
+}
@@ -0,0 +1,340 @@
+package robotapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Zone types
+const (
+	ZoneSafe    = "safe"    // robots inside can neither attack nor be attacked
+	ZoneBoost   = "boost"   // entering a cell costs less energy
+	ZoneCapture = "capture" // a robot alone inside scores points every tick
+)
+
+// Defaults of zones that leave their rule's value out
+const (
+	DefaultZoneMoveCost      = 50 // percent of the normal move cost
+	DefaultZonePointsPerTick = 1
+)
+
+// Types of zone events
+const (
+	ZoneEntered  = "zone_entered"
+	ZoneLeft     = "zone_left"
+	ZoneCaptured = "zone_captured" // another robot holds a capture zone
+)
+
+// Zone is a named area of the map with a special rule
+type Zone struct {
+	Name            string `json:"name"`
+	Type            string `json:"type"`
+	Area            Bounds `json:"area"`
+	Floor           int    `json:"floor,omitempty"`
+	MoveCostPercent int    `json:"move_cost_percent,omitempty"` // boost zones
+	PointsPerTick   int    `json:"points_per_tick,omitempty"`   // capture zones
+}
+
+// Contains reports whether the cell is part of the zone
+func (z Zone) Contains(p Position) bool {
+	return p.Z == z.Floor && z.Area.Contains(p)
+}
+
+// validate checks a zone and fills in the defaults of its rule
+func (z *Zone) validate() error {
+	if !robotIDPattern.MatchString(z.Name) {
+		return errors.New("zone names may only contain letters, digits, dashes and underscores")
+	}
+	if z.Area.MinX > z.Area.MaxX || z.Area.MinY > z.Area.MaxY {
+		return errors.New("the zone's area is empty")
+	}
+	switch z.Type {
+	case ZoneSafe:
+	case ZoneBoost:
+		if z.MoveCostPercent == 0 {
+			z.MoveCostPercent = DefaultZoneMoveCost
+		}
+		if z.MoveCostPercent < 0 || z.MoveCostPercent > 100 {
+			return errors.New("move_cost_percent must be between 0 and 100")
+		}
+	case ZoneCapture:
+		if z.PointsPerTick == 0 {
+			z.PointsPerTick = DefaultZonePointsPerTick
+		}
+		if z.PointsPerTick < 0 {
+			return errors.New("points_per_tick must not be negative")
+		}
+	default:
+		return fmt.Errorf("type must be %q, %q or %q", ZoneSafe, ZoneBoost, ZoneCapture)
+	}
+	return nil
+}
+
+// ZoneAt returns the first zone of the type containing the cell, in order
+// of their names
+func (l MapLayout) ZoneAt(p Position, zoneType string) *Zone {
+	for i := range l.Zones {
+		if l.Zones[i].Type == zoneType && l.Zones[i].Contains(p) {
+			return &l.Zones[i]
+		}
+	}
+	return nil
+}
+
+// MoveCost returns the energy needed to enter a cell, given the normal cost
+func (l MapLayout) MoveCost(p Position, cost int) int {
+	if zone := l.ZoneAt(p, ZoneBoost); zone != nil {
+		return cost * zone.MoveCostPercent / 100
+	}
+	return cost
+}
+
+// ZoneEvent is a change of a zone's occupants or holder
+type ZoneEvent struct {
+	Type    string
+	Zone    string
+	RobotID string
+}
+
+// zoneState is what the simulation remembers about a zone
+type zoneState struct {
+	occupants map[string]bool // as of the last update
+	holder    string          // the robot that scored last in a capture zone
+	scores    map[string]int  // robot ID -> points in a capture zone
+}
+
+// ZoneStatus is a zone with the robots in it and the capture scores
+type ZoneStatus struct {
+	Zone
+	Occupants []string       `json:"occupants"`
+	Holder    string         `json:"holder,omitempty"`
+	Scores    map[string]int `json:"scores,omitempty"`
+}
+
+// SetZone adds a zone or replaces the one with the same name. Scores of a
+// replaced capture zone are kept.
+func (s *RobotStorage) SetZone(zone Zone) (Zone, error) {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if err := zone.validate(); err != nil {
+		return zone, err
+	}
+	if s.bounds != nil {
+		for _, corner := range []Position{{X: zone.Area.MinX, Y: zone.Area.MinY}, {X: zone.Area.MaxX, Y: zone.Area.MaxY}} {
+			if !s.bounds.Contains(corner) {
+				return zone, ErrOutOfBounds
+			}
+		}
+	}
+	if s.zones == nil {
+		s.zones = make(map[string]Zone)
+	}
+	s.zones[zone.Name] = zone
+	s.layoutChanged()
+	return zone, nil
+}
+
+// RemoveZone removes a zone and reports whether there was one
+func (s *RobotStorage) RemoveZone(name string) bool {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	if _, exists := s.zones[name]; !exists {
+		return false
+	}
+	delete(s.zones, name)
+	delete(s.zoneStates, name)
+	s.layoutChanged()
+	return true
+}
+
+// setZones replaces all zones and forgets their occupants and scores;
+// callers must hold the lock
+func (s *RobotStorage) setZones(zones []Zone) {
+	s.zones = make(map[string]Zone, len(zones))
+	for _, zone := range zones {
+		s.zones[zone.Name] = zone
+	}
+	s.zoneStates = nil
+}
+
+// sortedZones returns the zones in order of their names; callers must hold
+// the lock
+func (s *RobotStorage) sortedZones() []Zone {
+	zones := make([]Zone, 0, len(s.zones))
+	for _, zone := range s.zones {
+		zones = append(zones, zone)
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		return zones[i].Name < zones[j].Name
+	})
+	return zones
+}
+
+// occupantsOf returns the robots in a zone in order of their IDs; callers
+// must hold the lock
+func (s *RobotStorage) occupantsOf(zone Zone) []string {
+	occupants := []string{}
+	for _, id := range s.sortedRobotIDs() {
+		if zone.Contains(s.robots[id].Position) {
+			occupants = append(occupants, id)
+		}
+	}
+	return occupants
+}
+
+// GetZones returns all zones with the robots in them and their scores
+func (s *RobotStorage) GetZones() []ZoneStatus {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	zones := []ZoneStatus{}
+	for _, zone := range s.sortedZones() {
+		status := ZoneStatus{Zone: zone, Occupants: s.occupantsOf(zone)}
+		if state := s.zoneStates[zone.Name]; state != nil {
+			status.Holder = state.holder
+			if len(state.scores) > 0 {
+				status.Scores = make(map[string]int, len(state.scores))
+				for id, score := range state.scores {
+					status.Scores[id] = score
+				}
+			}
+		}
+		zones = append(zones, status)
+	}
+	return zones
+}
+
+// UpdateZones compares the robots in every zone with the last update and
+// returns who entered and who left. It is called after robots move.
+func (s *RobotStorage) UpdateZones() []ZoneEvent {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	return s.updateZones(false)
+}
+
+// TickZones updates the zones like UpdateZones and awards the points of
+// capture zones: a robot alone in one scores its points, several robots
+// block each other. It is registered as a simulation system.
+func (s *RobotStorage) TickZones(tick int64) []ZoneEvent {
+	s.mutex.Lock()
+	defer s.unlock()
+
+	return s.updateZones(true)
+}
+
+// updateZones is UpdateZones, optionally scoring; callers must hold the lock
+func (s *RobotStorage) updateZones(score bool) []ZoneEvent {
+	events := []ZoneEvent{}
+	if s.zoneStates == nil {
+		s.zoneStates = make(map[string]*zoneState)
+	}
+	for _, zone := range s.sortedZones() {
+		state := s.zoneStates[zone.Name]
+		if state == nil {
+			state = &zoneState{occupants: map[string]bool{}, scores: map[string]int{}}
+			s.zoneStates[zone.Name] = state
+		}
+
+		occupants := s.occupantsOf(zone)
+		inside := make(map[string]bool, len(occupants))
+		for _, id := range occupants {
+			inside[id] = true
+			if !state.occupants[id] {
+				events = append(events, ZoneEvent{Type: ZoneEntered, Zone: zone.Name, RobotID: id})
+			}
+		}
+		left := []string{}
+		for id := range state.occupants {
+			if !inside[id] {
+				left = append(left, id)
+			}
+		}
+		sort.Strings(left)
+		for _, id := range left {
+			events = append(events, ZoneEvent{Type: ZoneLeft, Zone: zone.Name, RobotID: id})
+		}
+		state.occupants = inside
+
+		if score && zone.Type == ZoneCapture && len(occupants) == 1 {
+			holder := occupants[0]
+			state.scores[holder] += zone.PointsPerTick
+			if state.holder != holder {
+				state.holder = holder
+				events = append(events, ZoneEvent{Type: ZoneCaptured, Zone: zone.Name, RobotID: holder})
+			}
+		}
+	}
+	return events
+}
+
+// publishZoneEvents publishes zone events on a world's bus
+func publishZoneEvents(bus *EventBus, events []ZoneEvent) {
+	for _, event := range events {
+		bus.Publish(event.Type, event.RobotID, gin.H{"zone": event.Zone})
+	}
+}
+
+// TickZones scores the capture zones of all worlds and publishes who
+// entered and left zones since the last tick. It is registered as a
+// simulation system after the worlds have moved.
+func (h *RobotHandler) TickZones(tick int64) {
+	publishZoneEvents(h.events, h.storage.TickZones(tick))
+	for _, tenant := range h.tenants.All() {
+		publishZoneEvents(tenant.Events, tenant.Storage.TickZones(tick))
+	}
+}
+
+// safeZone returns the name of the safe zone the attacker or the target is
+// in, or "" if attacks between them are allowed
+func safeZone(layout MapLayout, attacker, target *Robot) string {
+	for _, robot := range []*Robot{attacker, target} {
+		if zone := layout.ZoneAt(robot.Position, ZoneSafe); zone != nil {
+			return zone.Name
+		}
+	}
+	return ""
+}
+
+// GetZones lists the zones with the robots in them and the capture scores
+func (h *RobotHandler) GetZones(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"zones": h.world(c).GetZones()})
+}
+
+// SetZone adds or replaces the zone named in the path
+func (h *RobotHandler) SetZone(c *gin.Context) {
+	var zone Zone
+	if err := bindJSON(c, &zone); err != nil {
+		invalidRequest(c, err)
+		return
+	}
+	zone.Name = c.Param("name")
+	if zone.Floor != 0 && !h.multiFloor {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Multi-floor maps are disabled"})
+		return
+	}
+	zone, err := h.world(c).SetZone(zone)
+	if err != nil {
+		mapEditFailed(c, err)
+		return
+	}
+	h.mapChanged(c, "zone_set", gin.H{"zone": zone})
+	c.JSON(http.StatusOK, h.world(c).GetLayout())
+}
+
+// RemoveZone removes the zone named in the path
+func (h *RobotHandler) RemoveZone(c *gin.Context) {
+	name := c.Param("name")
+	if !h.world(c).RemoveZone(name) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Zone not found"})
+		return
+	}
+	h.mapChanged(c, "zone_removed", gin.H{"zone": name})
+	c.JSON(http.StatusOK, h.world(c).GetLayout())
+}
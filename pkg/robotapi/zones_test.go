@@ -0,0 +1,143 @@
+package robotapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupZoneRouter() (*gin.Engine, *RobotHandler, *EventBus) {
+	gin.SetMode(gin.TestMode)
+	storage := NewRobotStorage()
+	storage.Initialize()
+	handler := NewRobotHandler(storage)
+	events := NewEventBus()
+	handler.SetEventBus(events)
+
+	router := gin.New()
+	router.POST("/robot/:id/move", handler.MoveRobot)
+	router.POST("/robot/:id/attack/:targetId", handler.AttackRobot)
+	router.GET("/world/zones", handler.GetZones)
+	router.PUT("/admin/world/bounds", handler.SetBounds)
+	router.PUT("/admin/world/zones/:name", handler.SetZone)
+	router.DELETE("/admin/world/zones/:name", handler.RemoveZone)
+	return router, handler, events
+}
+
+// zoneEvents returns the zone events on the bus as "type zone robot"
+func zoneEvents(events *EventBus) []string {
+	found := []string{}
+	for _, event := range events.Since(0) {
+		if zone, ok := event.Data.(gin.H)["zone"].(string); ok && event.Type != "map_changed" {
+			found = append(found, event.Type+" "+zone+" "+event.RobotID)
+		}
+	}
+	return found
+}
+
+func TestSafeZones(t *testing.T) {
+	router, handler, _ := setupZoneRouter()
+	placeInRange(t, handler.storage, "robot1", "robot2")
+	assert.Equal(t, http.StatusOK, send(router, "PUT", "/admin/world/zones/base", `{"type": "safe", "area": {"min_x": -1, "min_y": -1, "max_x": 1, "max_y": 1}}`).Code)
+
+	// Robots in a safe zone can neither attack nor be attacked
+	w := send(router, "POST", "/robot/robot1/attack/robot2", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), `"zone":"base"`)
+	assert.Equal(t, http.StatusConflict, send(router, "POST", "/robot/robot2/attack/robot1", "").Code)
+
+	assert.Equal(t, http.StatusOK, send(router, "DELETE", "/admin/world/zones/base", "").Code)
+	assert.Equal(t, http.StatusNotFound, send(router, "DELETE", "/admin/world/zones/base", "").Code)
+	assert.Equal(t, http.StatusOK, send(router, "POST", "/robot/robot1/attack/robot2", "").Code)
+}
+
+func TestBoostZonesLowerTheMoveCost(t *testing.T) {
+	router, handler, _ := setupZoneRouter()
+	require.NoError(t, handler.weather.Set("storm"))
+	assert.Equal(t, http.StatusOK, send(router, "PUT", "/admin/world/zones/road", `{"type": "boost", "area": {"min_x": 0, "min_y": 1, "max_x": 0, "max_y": 3}}`).Code)
+
+	var moved struct {
+		EnergyCost int `json:"energy_cost"`
+		Energy     int `json:"energy"`
+	}
+	w := send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &moved))
+	assert.Equal(t, 1, moved.EnergyCost, "half of the storm's cost")
+	assert.Equal(t, 99, moved.Energy)
+
+	// Leaving the zone costs the full price again
+	w = send(router, "POST", "/robot/robot1/move", `{"direction": "right"}`)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &moved))
+	assert.Equal(t, 2, moved.EnergyCost)
+}
+
+func TestZoneEntryAndExitEvents(t *testing.T) {
+	router, _, events := setupZoneRouter()
+	assert.Equal(t, http.StatusOK, send(router, "PUT", "/admin/world/zones/hill", `{"type": "capture", "area": {"min_x": 0, "min_y": 1, "max_x": 0, "max_y": 1}}`).Code)
+
+	send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`)
+	send(router, "POST", "/robot/robot1/move", `{"direction": "up"}`)
+	assert.Equal(t, []string{"zone_entered hill robot1", "zone_left hill robot1"}, zoneEvents(events))
+}
+
+func TestCaptureZonesAwardPoints(t *testing.T) {
+	storage := NewRobotStorage()
+	storage.Initialize()
+	_, err := storage.SetZone(Zone{Name: "hill", Type: ZoneCapture, Area: Bounds{MinX: 0, MinY: 0, MaxX: 10, MaxY: 10}, PointsPerTick: 5})
+	require.NoError(t, err)
+
+	// Both robots are inside and block each other
+	assert.Equal(t, []ZoneEvent{
+		{Type: ZoneEntered, Zone: "hill", RobotID: "robot1"},
+		{Type: ZoneEntered, Zone: "hill", RobotID: "robot2"},
+	}, storage.TickZones(1))
+	assert.Empty(t, storage.GetZones()[0].Scores)
+
+	_, err = storage.UpdateRobot("robot2", func(robot *Robot) error {
+		robot.Position = Position{X: 20, Y: 20}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []ZoneEvent{
+		{Type: ZoneLeft, Zone: "hill", RobotID: "robot2"},
+		{Type: ZoneCaptured, Zone: "hill", RobotID: "robot1"},
+	}, storage.TickZones(2))
+	assert.Empty(t, storage.TickZones(3))
+
+	zone := storage.GetZones()[0]
+	assert.Equal(t, []string{"robot1"}, zone.Occupants)
+	assert.Equal(t, "robot1", zone.Holder)
+	assert.Equal(t, map[string]int{"robot1": 10}, zone.Scores)
+
+	// Zones are part of the map and survive snapshots
+	restored := NewRobotStorage()
+	restored.Restore(storage.Snapshot())
+	assert.Equal(t, storage.GetLayout().Zones, restored.GetLayout().Zones)
+}
+
+func TestInvalidZones(t *testing.T) {
+	router, _, _ := setupZoneRouter()
+	assert.Equal(t, http.StatusOK, send(router, "PUT", "/admin/world/bounds", `{"min_x": -5, "min_y": -5, "max_x": 15, "max_y": 15}`).Code)
+	for name, body := range map[string]string{
+		"unknown type":   `{"type": "lava", "area": {"max_x": 1, "max_y": 1}}`,
+		"empty area":     `{"type": "safe", "area": {"min_x": 2, "max_x": 1}}`,
+		"out of bounds":  `{"type": "safe", "area": {"max_x": 20, "max_y": 1}}`,
+		"too expensive":  `{"type": "boost", "area": {"max_x": 1}, "move_cost_percent": 150}`,
+		"negative score": `{"type": "capture", "area": {"max_x": 1}, "points_per_tick": -1}`,
+		"other floor":    `{"type": "safe", "area": {"max_x": 1}, "floor": 1}`,
+	} {
+		assert.Equal(t, http.StatusBadRequest, send(router, "PUT", "/admin/world/zones/z", body).Code, name)
+	}
+	assert.Equal(t, http.StatusBadRequest, send(router, "PUT", "/admin/world/zones/no%20spaces", `{"type": "safe", "area": {}}`).Code)
+
+	var zones struct {
+		Zones []ZoneStatus `json:"zones"`
+	}
+	require.NoError(t, json.Unmarshal(send(router, "GET", "/world/zones", "").Body.Bytes(), &zones))
+	assert.Empty(t, zones.Zones)
+}